@@ -0,0 +1,169 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/decred/tumblebit/tumbler"
+)
+
+// The audit log keeps records of fairness tests of sessions in a file per
+// epoch, one JSON entry per line. Every entry carries the hash of the
+// preceding line of the epoch, so a log that has been rewritten can't be
+// reconciled with entries obtained earlier. Logs are published along with
+// epoch manifests.
+
+// auditEntry is a line of the audit log of an epoch.
+type auditEntry struct {
+	Prev   string               `json:"prev"`
+	Record *tumbler.AuditRecord `json:"record"`
+}
+
+// auditLog appends audit records to the logs of their epochs.
+type auditLog struct {
+	dir string
+
+	mu   sync.Mutex
+	last map[int32]string // Hash of the last line of every epoch log
+}
+
+func newAuditLog(dir string) (*auditLog, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &auditLog{dir: dir, last: make(map[int32]string)}, nil
+}
+
+// path returns the path of the log of the epoch.
+func (l *auditLog) path(epoch int32) string {
+	return filepath.Join(l.dir, fmt.Sprintf("epoch-%d.jsonl", epoch))
+}
+
+// lastHash returns the hash of the last line of the log of the epoch,
+// reading the log if it's not known yet. It must be called with the mutex
+// held.
+func (l *auditLog) lastHash(epoch int32) (string, error) {
+	if h, ok := l.last[epoch]; ok {
+		return h, nil
+	}
+	f, err := os.Open(l.path(epoch))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var h string
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 1 && line[len(line)-1] == '\n' {
+			h = auditHash(line[:len(line)-1])
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return h, nil
+}
+
+// auditHash returns the hex encoded hash of a line of the log.
+func auditHash(line []byte) string {
+	h := sha256.Sum256(line)
+	return hex.EncodeToString(h[:])
+}
+
+// record appends the record to the log of its epoch. Failures are logged
+// but don't interrupt the session.
+func (l *auditLog) record(rec *tumbler.AuditRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.append(rec); err != nil {
+		log.Errorf("Failed to record the audit of a session of epoch "+
+			"%d: %v", rec.Epoch, err)
+	}
+}
+
+// append writes the record to the log of its epoch. It must be called with
+// the mutex held.
+func (l *auditLog) append(rec *tumbler.AuditRecord) error {
+	prev, err := l.lastHash(rec.Epoch)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(&auditEntry{Prev: prev, Record: rec})
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(l.path(rec.Epoch),
+		os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		// The hash is read from the log again with the next record.
+		delete(l.last, rec.Epoch)
+		return err
+	}
+	l.last[rec.Epoch] = auditHash(line)
+	return nil
+}
+
+// auditHandler serves the log of the epoch selected with the epoch query
+// parameter.
+type auditHandler struct {
+	log *auditLog
+}
+
+func (h auditHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	epoch, err := strconv.ParseInt(r.URL.Query().Get("epoch"), 10, 32)
+	if err != nil || epoch <= 0 {
+		http.Error(w, "invalid epoch", http.StatusBadRequest)
+		return
+	}
+
+	f, err := os.Open(h.log.path(int32(epoch)))
+	if os.IsNotExist(err) {
+		http.Error(w, "unknown epoch", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Errorf("Failed to serve the audit log of epoch %d: %v",
+			epoch, err)
+		http.Error(w, "temporary failure",
+			http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	io.Copy(w, f)
+}
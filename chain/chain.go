@@ -0,0 +1,109 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package chain abstracts the blockchain specific facilities required to
+// compose TumbleBit contracts: address encoding, script construction and
+// transaction serialization. Contracts use a Backend to stay independent
+// of the underlying network, so that Bitcoin-family chains can be
+// supported alongside Decred.
+package chain
+
+// AddressType is a bitmask describing kinds of addresses.
+type AddressType int
+
+const (
+	PayToEdwardsPubKey AddressType = 1 << iota
+	PayToPubKey
+	PayToPubKeyHash
+	PayToSecSchnorrPubKey
+	PayToScriptHash
+)
+
+// Address is a payment address of a particular network.
+type Address interface {
+	// String returns the string encoding of the address. For public key
+	// addresses this is the encoding of the public key itself.
+	String() string
+
+	// EncodeAddress returns the string encoding of the payment address.
+	EncodeAddress() string
+
+	// ScriptAddress returns the raw bytes of the address used in
+	// scripts.
+	ScriptAddress() []byte
+}
+
+// AddressCodec decodes and encodes addresses of the network.
+type AddressCodec interface {
+	// DecodeAddress decodes the string encoding of an address and makes
+	// sure that it's intended for use on the network.
+	DecodeAddress(addr string) (Address, error)
+
+	// AddressType returns the kind of the address or zero if it's not
+	// known.
+	AddressType(addr Address) AddressType
+
+	// ScriptHashAddress returns a pay to script hash address of the
+	// script.
+	ScriptHashAddress(script []byte) (Address, error)
+
+	// PayToAddrScript returns an output script paying to the address.
+	PayToAddrScript(addr Address) ([]byte, error)
+}
+
+// ScriptBuilder composes scripts of the TumbleBit contracts.
+type ScriptBuilder interface {
+	// EscrowScript returns a script locking funds in a 2-of-2 multisig
+	// of the payer and the redeemer that can be refunded by the payer
+	// after the locktime.
+	EscrowScript(pkPayer, pkRedeemer []byte, lockTime int64) ([]byte, error)
+
+	// OfferScript returns a script releasing funds to the redeemer that
+	// reveals preimages of hashes computed with hashOp, or to the payer
+	// after the locktime.
+	OfferScript(pkPayer, pkRedeemer []byte, hashes [][]byte, hashOp byte, lockTime int64) ([]byte, error)
+
+	// EscrowScriptCSV is like EscrowScript but the refund is possible
+	// once the escrow has the specified number of confirmations.
+	EscrowScriptCSV(pkPayer, pkRedeemer []byte, sequence int64) ([]byte, error)
+
+	// OfferScriptCSV is like OfferScript but the refund is possible once
+	// the escrow has the specified number of confirmations.
+	OfferScriptCSV(pkPayer, pkRedeemer []byte, hashes [][]byte, hashOp byte, sequence int64) ([]byte, error)
+
+	// RefundSigScript returns the signature script refunding the
+	// contract with the payer's signature.
+	RefundSigScript(contract, sig []byte) ([]byte, error)
+
+	// RedeemSigScript returns the signature script redeeming the offer
+	// contract with the redeemer's signature and hash preimages.
+	RedeemSigScript(contract, sig []byte, secrets [][]byte) ([]byte, error)
+
+	// EscrowRedeemSigScript returns the signature script redeeming the
+	// escrow contract with signatures of both parties.
+	EscrowRedeemSigScript(contract, payerSig, redeemerSig []byte) ([]byte, error)
+
+	// PushedData returns data pushes of the script.
+	PushedData(script []byte) ([][]byte, error)
+}
+
+// TxSerializer inspects serialized transactions of the network.
+type TxSerializer interface {
+	// TxHash returns the hash identifying the serialized transaction.
+	TxHash(tx []byte) ([]byte, error)
+
+	// SignatureScript returns the signature script of the specified
+	// input of the serialized transaction.
+	SignatureScript(tx []byte, in uint32) ([]byte, error)
+}
+
+// Backend provides all facilities of a particular network.
+type Backend interface {
+	// Name returns the name of the network.
+	Name() string
+
+	AddressCodec
+	ScriptBuilder
+	TxSerializer
+}
@@ -0,0 +1,282 @@
+// Copyright (c) 2015-2016 The btcsuite developers
+// Copyright (c) 2016-2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package decred implements the chain backend for Decred networks.
+package decred
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrd/chaincfg/chainec"
+	"github.com/decred/dcrd/dcrutil"
+	"github.com/decred/dcrd/txscript"
+	"github.com/decred/dcrd/wire"
+	"github.com/decred/tumblebit/chain"
+)
+
+type backend struct {
+	params *chaincfg.Params
+}
+
+// New returns the chain backend for the Decred network described by the
+// chain parameters.
+func New(params *chaincfg.Params) chain.Backend {
+	return &backend{params: params}
+}
+
+func (b *backend) Name() string {
+	return b.params.Name
+}
+
+func (b *backend) DecodeAddress(a string) (chain.Address, error) {
+	addr, err := dcrutil.DecodeAddress(a)
+	if err != nil {
+		return nil, err
+	}
+	if !addr.IsForNet(b.params) {
+		return nil, fmt.Errorf("address %v is not intended for use on %v",
+			a, b.params.Name)
+	}
+	return addr, nil
+}
+
+func (b *backend) AddressType(addr chain.Address) chain.AddressType {
+	switch a := addr.(type) {
+	case *dcrutil.AddressEdwardsPubKey:
+		return chain.PayToEdwardsPubKey
+	case *dcrutil.AddressSecpPubKey:
+		return chain.PayToPubKey
+	case *dcrutil.AddressPubKeyHash:
+		if a.DSA(a.Net()) == chainec.ECTypeSecp256k1 {
+			return chain.PayToPubKeyHash
+		}
+	case *dcrutil.AddressSecSchnorrPubKey:
+		return chain.PayToSecSchnorrPubKey
+	case *dcrutil.AddressScriptHash:
+		return chain.PayToScriptHash
+	}
+	return 0
+}
+
+func (b *backend) ScriptHashAddress(script []byte) (chain.Address, error) {
+	return dcrutil.NewAddressScriptHash(script, b.params)
+}
+
+func (b *backend) PayToAddrScript(addr chain.Address) ([]byte, error) {
+	a, ok := addr.(dcrutil.Address)
+	if !ok {
+		return nil, fmt.Errorf("address %v is not a Decred address",
+			addr.EncodeAddress())
+	}
+	return txscript.PayToAddrScript(a)
+}
+
+// EscrowScript returns an output script that may be redeemed by one of
+// two signature scripts:
+//
+//   <payer sig> <redeemer sig> 1
+//
+//   <payer sig> 0
+//
+// The first signature script is the normal redemption path done by the
+// other party and requires both tumbler and client signatures. The second
+// signature script is the refund path performed by us, but the refund can
+// only be performed after locktime.
+func (b *backend) EscrowScript(pkPayer, pkRedeemer []byte, locktime int64) ([]byte, error) {
+	return buildEscrowContract(pkPayer, pkRedeemer, locktime,
+		txscript.OP_CHECKLOCKTIMEVERIFY)
+}
+
+// EscrowScriptCSV returns an output script redeemed like the one returned
+// by EscrowScript, except that the refund path is available once the
+// escrow output has been confirmed for the number of blocks specified by
+// the sequence, which is enforced with OP_CHECKSEQUENCEVERIFY.
+func (b *backend) EscrowScriptCSV(pkPayer, pkRedeemer []byte, sequence int64) ([]byte, error) {
+	if err := checkSequence(sequence); err != nil {
+		return nil, err
+	}
+	return buildEscrowContract(pkPayer, pkRedeemer, sequence,
+		txscript.OP_CHECKSEQUENCEVERIFY)
+}
+
+// checkSequence makes sure the relative locktime is a positive number of
+// blocks that fits into the sequence number of an input.
+func checkSequence(sequence int64) error {
+	if sequence <= 0 || sequence > wire.SequenceLockTimeMask {
+		return fmt.Errorf("invalid relative locktime %d", sequence)
+	}
+	return nil
+}
+
+// buildEscrowContract composes the escrow script with the refund path
+// verifying the lock with the specified opcode.
+func buildEscrowContract(pkPayer, pkRedeemer []byte, lock int64, lockOp byte) ([]byte, error) {
+	sb := txscript.NewScriptBuilder()
+
+	sb.AddOp(txscript.OP_IF) // Normal redeem path
+	{
+		// Check 2-of-2 multisig.
+		sb.AddOp(txscript.OP_2)
+		sb.AddData(pkPayer)
+		sb.AddData(pkRedeemer)
+		sb.AddOp(txscript.OP_2)
+		sb.AddOp(txscript.OP_CHECKMULTISIG)
+	}
+	sb.AddOp(txscript.OP_ELSE) // Refund path
+	{
+		// Verify the lock and drop it off the stack (which is not
+		// done by CLTV and CSV).
+		sb.AddInt64(lock)
+		sb.AddOp(lockOp)
+		sb.AddOp(txscript.OP_DROP)
+
+		// Verify our signature is being used to redeem the output.
+		sb.AddData(pkPayer)
+		sb.AddOp(txscript.OP_CHECKSIG)
+	}
+	sb.AddOp(txscript.OP_ENDIF)
+
+	return sb.Script()
+}
+
+// OfferScript returns an output script that may be redeemed by one of
+// two signature scripts:
+//
+//   OP_RIPEMD160, h1, OP_EQUALVERIFY
+//   OP_RIPEMD160, h2, OP_EQUALVERIFY
+//   ...
+//   <redeemer sig> 1
+//
+//  Or:
+//
+//   <payer sig> 0
+//
+// The first signature script is the normal redemption path done by the
+// other party and requires hash preimages and a tumbler signature. The
+// second signature script is the refund path performed by the client,
+// but the refund can only be performed after locktime.
+func (b *backend) OfferScript(pkPayer, pkRedeemer []byte, hashes [][]byte, hashOp byte, locktime int64) ([]byte, error) {
+	return buildOfferContract(pkPayer, pkRedeemer, hashes, hashOp,
+		locktime, txscript.OP_CHECKLOCKTIMEVERIFY)
+}
+
+// OfferScriptCSV returns an output script redeemed like the one returned
+// by OfferScript, except that the refund path is available once the
+// escrow output has been confirmed for the number of blocks specified by
+// the sequence, which is enforced with OP_CHECKSEQUENCEVERIFY.
+func (b *backend) OfferScriptCSV(pkPayer, pkRedeemer []byte, hashes [][]byte, hashOp byte, sequence int64) ([]byte, error) {
+	if err := checkSequence(sequence); err != nil {
+		return nil, err
+	}
+	return buildOfferContract(pkPayer, pkRedeemer, hashes, hashOp,
+		sequence, txscript.OP_CHECKSEQUENCEVERIFY)
+}
+
+// buildOfferContract composes the offer script with the refund path
+// verifying the lock with the specified opcode.
+func buildOfferContract(pkPayer, pkRedeemer []byte, hashes [][]byte, hashOp byte, lock int64, lockOp byte) ([]byte, error) {
+	sb := txscript.NewScriptBuilder()
+
+	sb.AddOp(txscript.OP_IF) // Normal redeem path
+	{
+		for _, h := range hashes {
+			sb.AddOp(hashOp)
+			sb.AddData(h)
+			sb.AddOp(txscript.OP_EQUALVERIFY)
+		}
+		// Check redeemer's signature.
+		sb.AddData(pkRedeemer)
+		sb.AddOp(txscript.OP_CHECKSIG)
+	}
+	sb.AddOp(txscript.OP_ELSE) // Refund path
+	{
+		// Verify the lock and drop it off the stack (which is not
+		// done by CLTV and CSV).
+		sb.AddInt64(lock)
+		sb.AddOp(lockOp)
+		sb.AddOp(txscript.OP_DROP)
+
+		// Verify our signature is being used to redeem the output.
+		sb.AddData(pkPayer)
+		sb.AddOp(txscript.OP_CHECKSIG)
+	}
+	sb.AddOp(txscript.OP_ENDIF)
+
+	return sb.Script()
+}
+
+// RefundSigScript returns the signature script to refund a contract
+// output using the contract author's signature after the locktime has
+// been reached. This function assumes P2SH and appends the contract as
+// the final data push.
+func (b *backend) RefundSigScript(contract, sig []byte) ([]byte, error) {
+	sb := txscript.NewScriptBuilder()
+	sb.AddData(sig)
+	sb.AddInt64(0)
+	sb.AddData(contract)
+	return sb.Script()
+}
+
+// RedeemSigScript returns the signature script to redeem a contract
+// output using the redeemer's signature and secret values. This function
+// assumes P2SH and appends the contract as the final data push.
+func (b *backend) RedeemSigScript(contract, sig []byte, secrets [][]byte) ([]byte, error) {
+	sb := txscript.NewScriptBuilder()
+	sb.AddData(sig)
+	for _, secret := range secrets {
+		sb.AddData(secret)
+	}
+	sb.AddInt64(1)
+	sb.AddData(contract)
+	return sb.Script()
+}
+
+// EscrowRedeemSigScript returns the signature script to redeem an escrow
+// contract output. Signatures must follow the order of public keys in the
+// 2-of-2 multisig. Decred's OP_CHECKMULTISIG doesn't consume an extra
+// stack element, so no dummy push is required. This function assumes P2SH
+// and appends the contract as the final data push.
+func (b *backend) EscrowRedeemSigScript(contract, payerSig, redeemerSig []byte) ([]byte, error) {
+	sb := txscript.NewScriptBuilder()
+	sb.AddData(payerSig)
+	sb.AddData(redeemerSig)
+	sb.AddInt64(1)
+	sb.AddData(contract)
+	return sb.Script()
+}
+
+func (b *backend) PushedData(script []byte) ([][]byte, error) {
+	return txscript.PushedData(script)
+}
+
+func deserializeTx(txBytes []byte) (*wire.MsgTx, error) {
+	var tx wire.MsgTx
+	if err := tx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+		return nil, fmt.Errorf("failed to deserialize tx: %v", err)
+	}
+	return &tx, nil
+}
+
+func (b *backend) TxHash(txBytes []byte) ([]byte, error) {
+	tx, err := deserializeTx(txBytes)
+	if err != nil {
+		return nil, err
+	}
+	hash := tx.TxHash()
+	return hash[:], nil
+}
+
+func (b *backend) SignatureScript(txBytes []byte, in uint32) ([]byte, error) {
+	tx, err := deserializeTx(txBytes)
+	if err != nil {
+		return nil, err
+	}
+	if int(in) >= len(tx.TxIn) {
+		return nil, fmt.Errorf("transaction doesn't have input %d", in)
+	}
+	return tx.TxIn[in].SignatureScript, nil
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// normalizeFingerprint converts a hex encoded SHA256 certificate
+// fingerprint, optionally separated with colons, to the lower case form
+// without separators.
+func normalizeFingerprint(fp string) (string, error) {
+	fp = strings.ToLower(strings.Replace(fp, ":", "", -1))
+	b, err := hex.DecodeString(fp)
+	if err != nil {
+		return "", err
+	}
+	if len(b) != sha256.Size {
+		return "", fmt.Errorf("fingerprint must be %d bytes long",
+			sha256.Size)
+	}
+	return fp, nil
+}
+
+// certFingerprint returns the SHA256 fingerprint of a DER encoded
+// certificate.
+func certFingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// serverTLSConfig returns the TLS configuration of the RPC server. When a
+// client CA or authorized client certificates are configured, clients must
+// present a certificate during the handshake. Certificates are verified
+// against the CA, if any, and their fingerprints are checked against the
+// allow-list, if one is provided.
+func serverTLSConfig(certs *rpcCerts) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		GetCertificate: certs.getCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+
+	if cfg.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s",
+				cfg.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	} else if len(cfg.AuthorizedCerts) != 0 {
+		// Self-signed client certificates are identified by their
+		// fingerprints alone.
+		tlsConfig.ClientAuth = tls.RequireAnyClientCert
+	} else if len(cfg.AdminCerts) != 0 {
+		// Ordinary clients may connect without a certificate, admin
+		// certificates are checked when the admin service is used.
+		tlsConfig.ClientAuth = tls.RequestClientCert
+	}
+
+	if len(cfg.AuthorizedCerts) != 0 {
+		authorized := make(map[string]struct{}, len(cfg.AuthorizedCerts)+
+			len(cfg.AdminCerts))
+		for _, fp := range cfg.AuthorizedCerts {
+			authorized[fp] = struct{}{}
+		}
+		for _, fp := range cfg.AdminCerts {
+			authorized[fp] = struct{}{}
+		}
+		tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("no client certificate")
+			}
+			fp := certFingerprint(rawCerts[0])
+			if _, ok := authorized[fp]; !ok {
+				log.Warnf("Rejected client certificate %s", fp)
+				return errors.New("client certificate is not authorized")
+			}
+			return nil
+		}
+	}
+
+	if tlsConfig.ClientAuth != tls.NoClientCert {
+		log.Infof("Client certificate authentication enabled")
+	}
+
+	return tlsConfig, nil
+}
+
+// authorizeAdmin makes sure the peer has presented one of the admin
+// certificates during the TLS handshake.
+func authorizeAdmin(ctx context.Context) error {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return status.Error(codes.PermissionDenied, "unknown peer")
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.PeerCertificates) == 0 {
+		return status.Error(codes.PermissionDenied,
+			"admin certificate required")
+	}
+	fp := certFingerprint(tlsInfo.State.PeerCertificates[0].Raw)
+	for _, admin := range cfg.AdminCerts {
+		if fp == admin {
+			return nil
+		}
+	}
+	log.Warnf("Rejected admin request from %s with certificate %s",
+		p.Addr, fp)
+	return status.Error(codes.PermissionDenied,
+		"client certificate is not authorized")
+}
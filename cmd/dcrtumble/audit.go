@@ -0,0 +1,128 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/decred/tumblebit/tumbler"
+)
+
+// auditTimeout limits the time spent fetching an audit log.
+const auditTimeout = 30 * time.Second
+
+// maxAuditLogSize limits the size of an audit log fetched from the
+// tumbler.
+const maxAuditLogSize = 256 << 20
+
+// auditEntry is a line of the audit log of an epoch. Prev is the hash of
+// the preceding line.
+type auditEntry struct {
+	Prev   string               `json:"prev"`
+	Record *tumbler.AuditRecord `json:"record"`
+}
+
+// AuditEpoch fetches the audit log of the epoch published by the tumbler
+// at the URL and verifies every fairness test recorded in it. All tests
+// must have been run with the parameters used by dcrtumble. The hash of
+// the last entry is reported. Passing it as the head of the log fetched
+// later on makes sure the log has only been appended to since.
+func AuditEpoch(ctx context.Context, endpoint string, epoch int32, head string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return fmt.Errorf("Unsupported audit log URL scheme %q", u.Scheme)
+	}
+	q := u.Query()
+	q.Set("epoch", strconv.Itoa(int(epoch)))
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: auditTimeout}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Audit log responded with %s", resp.Status)
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxAuditLogSize))
+	if err != nil {
+		return err
+	}
+
+	expected := tumbler.Parameters{
+		RealTransactionCount: RealTransactionCount,
+		FakeTransactionCount: FakeTransactionCount,
+		RealPreimageCount:    RealPreimageCount,
+		FakePreimageCount:    FakePreimageCount,
+	}
+	var last string
+	seen := head == ""
+	counts := make(map[string]int)
+	lines := bytes.Split(b, []byte{'\n'})
+	// The last line is either empty or being appended to.
+	for i, line := range lines[:len(lines)-1] {
+		var e auditEntry
+		if err = json.Unmarshal(line, &e); err != nil {
+			return fmt.Errorf("Malformed audit log entry %d: %v", i, err)
+		}
+		if e.Prev != last {
+			return fmt.Errorf("Audit log entry %d doesn't follow the "+
+				"preceding entry", i)
+		}
+		h := sha256.Sum256(line)
+		last = hex.EncodeToString(h[:])
+		seen = seen || last == head
+
+		rec := e.Record
+		switch {
+		case rec == nil:
+			return fmt.Errorf("Audit log entry %d has no record", i)
+		case rec.Epoch != epoch:
+			return fmt.Errorf("Audit log entry %d records a session "+
+				"of epoch %d", i, rec.Epoch)
+		case rec.Parameters != expected:
+			return fmt.Errorf("Audit log entry %d records a session "+
+				"run with parameters %+v", i, rec.Parameters)
+		}
+		if err = tumbler.VerifyAuditRecord(rec); err != nil {
+			return fmt.Errorf("Audit log entry %d failed to verify: %v",
+				i, err)
+		}
+		counts[rec.Protocol]++
+	}
+
+	if !seen {
+		return fmt.Errorf("Audit log doesn't contain the entry %s, "+
+			"it has been rewritten", head)
+	}
+
+	fmt.Printf("Epoch %d: verified %d Puzzle-Promise and %d "+
+		"Puzzle-Solver fairness tests\n", epoch,
+		counts[tumbler.AuditPuzzlePromise],
+		counts[tumbler.AuditPuzzleSolver])
+	if last != "" {
+		fmt.Printf("Audit log head %s\n", last)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+
+	pb "github.com/decred/tumblebit/rpc/tumblerrpc"
+
+	"google.golang.org/grpc/status"
+)
+
+// When the tumbler rejects the transaction disclosure of the payee it
+// reports the failed check along with the offending transactions. The
+// failure is recorded in the transcript of the escrow along with the
+// disclosure and the commitments it must open, which is enough to tell the
+// party at fault: the payee if its disclosure doesn't verify, the tumbler
+// otherwise.
+
+// Parties blamed for a rejected disclosure.
+const (
+	blamePayee   = "payee"
+	blameTumbler = "tumbler"
+)
+
+// transcriptValidationFailure is the kind of transcript entries recording
+// rejected disclosures.
+const transcriptValidationFailure = "validation-failure"
+
+// disclosureError is returned by FinalizeEscrow when the tumbler has
+// rejected the transaction disclosure.
+type disclosureError struct {
+	failure *pb.ValidationFailure
+	err     error
+}
+
+func (e *disclosureError) Error() string {
+	return e.err.Error()
+}
+
+// validationFailure returns the failure detailing the error returned by
+// the tumbler, if any.
+func validationFailure(err error) *pb.ValidationFailure {
+	st, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+	for _, d := range st.Details() {
+		if f, ok := d.(*pb.ValidationFailure); ok {
+			return f
+		}
+	}
+	return nil
+}
+
+// failureTranscript records the disclosure rejected by the tumbler, the
+// check it has reported as failed and the party at fault.
+type failureTranscript struct {
+	Check            string     `json:"check"`
+	Indices          []uint32   `json:"indices,omitempty"`
+	Blame            string     `json:"blame"`
+	TxHashes         []hexBytes `json:"tx_hashes"`
+	Salt             hexBytes   `json:"salt"`
+	RandomPads       []hexBytes `json:"random_pads"`
+	RealTxList       hexBytes   `json:"real_tx_list"`
+	FakeTxList       hexBytes   `json:"fake_tx_list"`
+	RealSetHash      hexBytes   `json:"real_set_hash"`
+	FakeSetHash      hexBytes   `json:"fake_set_hash"`
+	PayoutAddress    string     `json:"payout_address,omitempty"`
+	PayoutNonce      hexBytes   `json:"payout_nonce,omitempty"`
+	PayoutCommitment hexBytes   `json:"payout_commitment,omitempty"`
+}
+
+// blame returns the party at fault for the rejected disclosure.
+func (f *failureTranscript) blame() string {
+	if f.Check == pb.ValidationCheck_PAYOUT_COMMITMENT.String() {
+		if len(f.PayoutCommitment) != 0 && !bytes.Equal(f.PayoutCommitment,
+			payoutCommitment(f.PayoutNonce, f.PayoutAddress)) {
+			return blamePayee
+		}
+		return blameTumbler
+	}
+	_, _, err := verifyDisclosure(&puzzlePromiseChallenge{
+		txHashes:    bytesList(f.TxHashes),
+		salt:        f.Salt,
+		randomPads:  bytesList(f.RandomPads),
+		realTxList:  f.RealTxList,
+		fakeTxList:  f.FakeTxList,
+		realSetHash: f.RealSetHash,
+		fakeSetHash: f.FakeSetHash,
+	})
+	if err != nil {
+		return blamePayee
+	}
+	return blameTumbler
+}
+
+// String describes the failure for the user.
+func (f *failureTranscript) String() string {
+	s := fmt.Sprintf("tumbler rejected the disclosure failing the %s check",
+		f.Check)
+	if len(f.Indices) != 0 {
+		s += fmt.Sprintf(" of transactions %v", f.Indices)
+	}
+	return fmt.Sprintf("%s, the %s is at fault", s, f.Blame)
+}
+
+// recordValidationFailure assigns the blame for the disclosure rejected
+// by the tumbler, reports it and records it in the transcript of the
+// escrow.
+func (tb *Tumbler) recordValidationFailure(escrowHash []byte, c *puzzlePromiseChallenge, id *payeeIdentity, failure *pb.ValidationFailure) {
+	f := &failureTranscript{
+		Check:            failure.Check.String(),
+		Indices:          failure.Indices,
+		TxHashes:         hexList(c.txHashes),
+		Salt:             c.salt,
+		RandomPads:       hexList(c.randomPads),
+		RealTxList:       c.realTxList,
+		FakeTxList:       c.fakeTxList,
+		RealSetHash:      c.realSetHash,
+		FakeSetHash:      c.fakeSetHash,
+		PayoutAddress:    id.payout,
+		PayoutNonce:      id.nonce,
+		PayoutCommitment: id.commitment,
+	}
+	f.Blame = f.blame()
+	log.Printf("Escrow %x: %v", escrowHash, f)
+	tb.recordTranscript(escrowHash, transcriptValidationFailure, f)
+}
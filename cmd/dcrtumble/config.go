@@ -6,6 +6,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -15,11 +16,16 @@ import (
 	"regexp"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/decred/dcrd/dcrutil"
+	"github.com/decred/tumblebit/internal/cfgutil"
+	"github.com/decred/tumblebit/internal/secrets"
 	"github.com/decred/tumblebit/netparams"
+	"github.com/decred/tumblebit/wallet"
 
 	flags "github.com/jessevdk/go-flags"
+	"google.golang.org/grpc/encoding"
 )
 
 var (
@@ -31,11 +37,44 @@ var (
 	defaultWalletRPCServer = "localhost"
 	defaultTumblerCertFile = filepath.Join(tbHomeDir, "rpc.cert")
 	defaultWalletCertFile  = filepath.Join(dcrwalletHomeDir, "rpc.cert")
+	defaultAccountName     = "tumblebit"
+	defaultMaxMsgSize      = 4 << 20
+	defaultOfferDeadline   = time.Hour
+	defaultDeadlineWarning = uint32(12)
+	defaultMinMixDelay     = 10 * time.Minute
+	defaultMaxMixDelay     = 6 * time.Hour
+
+	// dcrwallet rejects clients pinging more often than every five
+	// minutes.
+	defaultKeepalive        = time.Minute
+	defaultKeepaliveTimeout = 20 * time.Second
+	defaultWalletKeepalive  = 5 * time.Minute
+	defaultRedialTimeout    = 2 * time.Minute
 )
 
+// envPrefix prefixes names of environment variables overriding options,
+// e.g. DCRTUMBLE_WALLETPASS.
+const envPrefix = "DCRTUMBLE_"
+
+// compressionNone disables compression of calls.
+const compressionNone = "none"
+
 // listCommands categorizes and lists all of the usable commands along with
 // their one-line usage.
 func listCommands() {
+	fmt.Println("Commands:")
+	fmt.Println("  tumble                  Exchange a coin through the tumbler")
+	fmt.Println("  daemon                  Keep mixing funds of the account as they arrive")
+	fmt.Println("  refund [escrowhash...]  Refund expired payment offers")
+	fmt.Println("  watch [escrowhash...]   Watch escrows of the tumbler for refunds")
+	fmt.Println("  export-evidence <escrowhash> [file]")
+	fmt.Println("                          Export evidence of a failed exchange")
+	fmt.Println("  verify-evidence <file>  Verify exported evidence")
+	fmt.Println("  export-contract <escrowhash> [file]")
+	fmt.Println("                          Export the contract of an escrow")
+	fmt.Println("  verify-contract <file>  Verify scripts of an exported contract")
+	fmt.Println("  audit <url> <epoch> [head]")
+	fmt.Println("                          Verify the audit log of an epoch")
 	fmt.Println()
 }
 
@@ -50,12 +89,63 @@ type config struct {
 	WalletRPCServer  string `short:"w" long:"walletrpcserver" description:"Wallet RPC server to connect to"`
 	TumblerRPCCert   string `long:"rpccert" description:"TumbleBit RPC server certificate chain for validation"`
 	WalletRPCCert    string `long:"walletrpccert" description:"Wallet RPC server certificate chain for validation"`
+	NoCertUpdate     bool   `long:"nocertupdate" description:"Don't update the --rpccert file with certificates the TumbleBit RPC server rotates to"`
 	WalletPassword   string `long:"walletpass" description:"The private wallet password to unlocked the wallet"`
+	SecretsFile      string `long:"secretsfile" description:"Read the wallet password from this encrypted secrets file, prompting for its passphrase at startup"`
+	PromptPass       bool   `long:"promptpass" description:"Prompt for the wallet password at startup"`
+	CreateSecrets    bool   `long:"createsecrets" description:"Create the encrypted secrets file specified with --secretsfile and exit"`
 	Account          uint32 `short:"a" long:"account" description:"BIP0044 account number to use for transactions"`
 	AccountName      string `long:"accountname" description:"Name of the account to use for transactions -- NOTE: This takes precedence over the numeric specification"`
+	CreateAccount    bool   `long:"createaccount" description:"Create the account specified with --accountname (default: tumblebit) unless it exists -- NOTE: This requires the wallet password"`
 	NoTLS            bool   `long:"notls" description:"Disable TLS"`
 	TestNet          bool   `long:"testnet" description:"Connect to testnet"`
 	SimNet           bool   `long:"simnet" description:"Connect to the simulation test network"`
+	SolutionFile     string `long:"solutionfile" description:"Export the puzzle solution obtained by the payer to the specified file"`
+	JournalFile      string `long:"journal" description:"Contract journal database (default: contracts.db in the network directory)"`
+	EvidenceFile     string `long:"evidence" description:"File recording spends of escrows of the tumbler other than by the payee (default: evidence.json in the network directory)"`
+	ClientCert       string `long:"clientcert" description:"Client certificate presented to the TumbleBit RPC server"`
+	ClientKey        string `long:"clientkey" description:"Private key of the client certificate"`
+	WalletClientCert string `long:"walletclientcert" description:"Client certificate presented to the wallet when it requires client certificate authentication"`
+	WalletClientKey  string `long:"walletclientkey" description:"Private key of the client certificate presented to the wallet"`
+	SeedAddress      string `long:"seedaddress" description:"Derive blinding factors and secrets from a seed backed by the key of this wallet address"`
+	SessionKeys      bool   `long:"sessionkeys" description:"Identify the payee with a fresh key of the session and only reveal the pay-out address when the escrow is finalized"`
+	KeyPinFile       string `long:"keypins" description:"File pinning puzzle keys served by tumblers in every epoch (default: keypins.json in the network directory)"`
+	NoKeyPins        bool   `long:"nokeypins" description:"Disable pinning of puzzle keys"`
+	PuzzleFile       string `long:"puzzlestore" description:"File recording puzzles of promise sets handed out and paid for (default: puzzles.json in the network directory)"`
+	KeyLogURL        string `long:"keylog" description:"Verify puzzle keys against the key transparency log published by the tumbler at this URL"`
+	Progress         string `long:"progress" description:"Display the progress of the exchange {plain, json, tui, none}"`
+	Parallelism      int    `long:"parallelism" description:"Maximum number of puzzles and signatures verified concurrently (default: number of CPUs)"`
+	MaxMsgSize       int    `long:"maxmsgsize" description:"Maximum size in bytes of gRPC messages exchanged with the tumbler"`
+	NoStreaming      bool   `long:"nostreaming" description:"Exchange puzzles and promises in single messages even if the tumbler is able to stream them"`
+	Compression      string `long:"compression" description:"Compress puzzles and promises exchanged with the tumbler {none, gzip}"`
+	VerboseScripts   bool   `long:"verbosescripts" description:"Include the stacks of the script engine and the disassembly of the failing script in contract verification errors"`
+
+	// Connection options
+	Keepalive        time.Duration `long:"keepalive" description:"Interval of pings keeping idle connections to the tumbler alive, 0 to disable"`
+	KeepaliveTimeout time.Duration `long:"keepalivetimeout" description:"Time to wait for the acknowledgement of a ping before the connection is considered lost"`
+	WalletKeepalive  time.Duration `long:"walletkeepalive" description:"Interval of pings keeping idle connections to the wallet alive, 0 to disable"`
+	RedialTimeout    time.Duration `long:"redialtimeout" description:"Time to wait for a lost connection to the tumbler to be re-established before the session is abandoned"`
+
+	// Payment options
+	OfferDeadline   time.Duration `long:"offerdeadline" description:"Abandon the payment offer and refund its escrow once the lock time expires if the tumbler hasn't accepted the offer within this time"`
+	DeadlineWarning uint32        `long:"deadlinewarning" description:"Alert when the escrow paying the payee is within this number of blocks of its locktime and the tumbler hasn't fulfilled the payment offer"`
+	MinPhaseDelay   time.Duration `long:"minphasedelay" description:"Minimum random delay before every phase of the exchange"`
+	MaxPhaseDelay   time.Duration `long:"maxphasedelay" description:"Maximum random delay before every phase of the exchange, 0 to disable"`
+	Count           int           `long:"count" description:"Number of coins exchanged concurrently by the tumble command, with solutions exported to files numbered after the mixes"`
+	Interval        time.Duration `long:"interval" description:"Time between starting consecutive mixes of the tumble command"`
+	PayoutAccount   string        `long:"payoutaccount" description:"Name of the account receiving redeemed escrows (default: the account funding payments)"`
+	SplitOutputs    int           `long:"splitoutputs" description:"Number of outputs with random amounts and fresh addresses that redeemed and refunded escrows are split into"`
+
+	// Payee wallet options
+	PayeeWallet      string `long:"payeewallet" description:"Wallet RPC server of the payee receiving redeemed escrows, separate from the wallet funding payments (default: the same wallet)"`
+	PayeeWalletCert  string `long:"payeewalletcert" description:"Payee wallet RPC server certificate chain for validation (default: --walletrpccert)"`
+	PayeeWalletPass  string `long:"payeewalletpass" description:"The private password of the payee wallet (default: --walletpass)"`
+	PayeeAccountName string `long:"payeeaccountname" description:"Name of the payee wallet account to use for transactions (default: --accountname)"`
+
+	// Daemon options
+	QueueFile   string        `long:"queuefile" description:"File persisting mixes scheduled by the daemon (default: daemon.json in the network directory)"`
+	MinMixDelay time.Duration `long:"minmixdelay" description:"Minimum random delay of mixes scheduled by the daemon"`
+	MaxMixDelay time.Duration `long:"maxmixdelay" description:"Maximum random delay of mixes scheduled by the daemon"`
 }
 
 // cleanAndExpandPath expands environment variables and leading ~ in the
@@ -121,28 +211,47 @@ func fileExists(name string) bool {
 // line options.
 //
 // The configuration proceeds as follows:
-// 	1) Start with a default config with sane settings
-// 	2) Pre-parse the command line to check for an alternative config file
-// 	3) Load configuration file overwriting defaults with any specified options
-// 	4) Parse CLI options and overwrite/add any specified options
+//  1. Start with a default config with sane settings
+//  2. Pre-parse the environment and the command line to check for an
+//     alternative config file
+//  3. Load configuration file overwriting defaults with any specified options
+//  4. Apply DCRTUMBLE_* environment variables overwriting any specified options
+//  5. Parse CLI options and overwrite/add any specified options
 //
 // The above results in functioning properly without any config settings
-// while still allowing the user to override settings with config files and
-// command line options.  Command line options always take precedence.
+// while still allowing the user to override settings with config files, the
+// environment and command line options.  Command line options always take
+// precedence.
 func loadConfig() (*config, []string, error) {
 	// Default config.
 	cfg := config{
-		ConfigFile:     defaultConfigFile,
-		TumblerRPCCert: defaultTumblerCertFile,
-		WalletRPCCert:  defaultWalletCertFile,
+		ConfigFile:       defaultConfigFile,
+		TumblerRPCCert:   defaultTumblerCertFile,
+		WalletRPCCert:    defaultWalletCertFile,
+		Progress:         progressPlain,
+		MaxMsgSize:       defaultMaxMsgSize,
+		Compression:      compressionNone,
+		OfferDeadline:    defaultOfferDeadline,
+		DeadlineWarning:  defaultDeadlineWarning,
+		Keepalive:        defaultKeepalive,
+		KeepaliveTimeout: defaultKeepaliveTimeout,
+		WalletKeepalive:  defaultWalletKeepalive,
+		RedialTimeout:    defaultRedialTimeout,
+		Count:            1,
+		SplitOutputs:     1,
+		MinMixDelay:      defaultMinMixDelay,
+		MaxMixDelay:      defaultMaxMixDelay,
 	}
 
-	// Pre-parse the command line options to see if an alternative config
-	// file, the version flag, or the list commands flag was specified.  Any
-	// errors aside from the help message error can be ignored here since
-	// they will be caught by the final parse below.
+	// Pre-parse the environment and the command line options to see if an
+	// alternative config file, the version flag, or the list commands flag
+	// was specified.  Any errors aside from the help message error can be
+	// ignored here since they will be caught by the final parse below.
 	preCfg := cfg
 	preParser := flags.NewParser(&preCfg, flags.HelpFlag)
+	preParser.LongDescription = cfgutil.EnvDescription(envPrefix,
+		"walletpass")
+	cfgutil.ParseEnv(preParser, envPrefix)
 	_, err := preParser.Parse()
 	if err != nil {
 		if e, ok := err.(*flags.Error); ok && e.Type != flags.ErrHelp {
@@ -187,6 +296,7 @@ func loadConfig() (*config, []string, error) {
 
 	// Load additional config from file.
 	parser := flags.NewParser(&cfg, flags.Default)
+	parser.LongDescription = preParser.LongDescription
 	err = flags.NewIniParser(parser).ParseFile(preCfg.ConfigFile)
 	if err != nil {
 		if _, ok := err.(*os.PathError); !ok {
@@ -197,6 +307,13 @@ func loadConfig() (*config, []string, error) {
 		}
 	}
 
+	// Environment variables override the config file.
+	if err = cfgutil.ParseEnv(parser, envPrefix); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Parse command line options again to ensure they take precedence.
 	remainingArgs, err := parser.Parse()
 	if err != nil {
@@ -224,10 +341,117 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Run the protocol with the parameters of the network.
+	RealTransactionCount = activeNet.RealTransactionCount
+	FakeTransactionCount = activeNet.FakeTransactionCount
+	RealPreimageCount = activeNet.RealPreimageCount
+	FakePreimageCount = activeNet.FakePreimageCount
+
 	// Handle environment variable expansion in the RPC certificate path.
 	cfg.TumblerRPCCert = cleanAndExpandPath(cfg.TumblerRPCCert)
 	cfg.WalletRPCCert = cleanAndExpandPath(cfg.WalletRPCCert)
 
+	// The client certificate is only usable together with its key.
+	if (cfg.ClientCert == "") != (cfg.ClientKey == "") {
+		err := fmt.Errorf("%s: --clientcert and --clientkey must be "+
+			"specified together", "loadConfig")
+		fmt.Fprintln(os.Stderr, err)
+		return nil, nil, err
+	}
+	if cfg.ClientCert != "" {
+		cfg.ClientCert = cleanAndExpandPath(cfg.ClientCert)
+		cfg.ClientKey = cleanAndExpandPath(cfg.ClientKey)
+	}
+	if (cfg.WalletClientCert == "") != (cfg.WalletClientKey == "") {
+		err := fmt.Errorf("%s: --walletclientcert and --walletclientkey "+
+			"must be specified together", "loadConfig")
+		fmt.Fprintln(os.Stderr, err)
+		return nil, nil, err
+	}
+	if cfg.WalletClientCert != "" {
+		cfg.WalletClientCert = cleanAndExpandPath(cfg.WalletClientCert)
+		cfg.WalletClientKey = cleanAndExpandPath(cfg.WalletClientKey)
+	}
+
+	if cfg.Parallelism < 0 {
+		err := fmt.Errorf("%s: parallelism must not be negative",
+			"loadConfig")
+		fmt.Fprintln(os.Stderr, err)
+		return nil, nil, err
+	}
+	if cfg.MaxMsgSize <= 0 {
+		err := fmt.Errorf("%s: message size limit must be positive",
+			"loadConfig")
+		fmt.Fprintln(os.Stderr, err)
+		return nil, nil, err
+	}
+	if cfg.Count <= 0 {
+		err := fmt.Errorf("%s: number of mixes must be positive",
+			"loadConfig")
+		fmt.Fprintln(os.Stderr, err)
+		return nil, nil, err
+	}
+	if cfg.SplitOutputs < 1 || cfg.SplitOutputs > wallet.MaxSplitOutputs {
+		err := fmt.Errorf("%s: number of split outputs must be between "+
+			"1 and %d", "loadConfig", wallet.MaxSplitOutputs)
+		fmt.Fprintln(os.Stderr, err)
+		return nil, nil, err
+	}
+	if cfg.Interval < 0 {
+		err := fmt.Errorf("%s: interval between mixes must not be "+
+			"negative", "loadConfig")
+		fmt.Fprintln(os.Stderr, err)
+		return nil, nil, err
+	}
+	if cfg.MinMixDelay < 0 || cfg.MaxMixDelay < cfg.MinMixDelay {
+		err := fmt.Errorf("%s: mix delays must not be negative and "+
+			"the maximum must not be less than the minimum",
+			"loadConfig")
+		fmt.Fprintln(os.Stderr, err)
+		return nil, nil, err
+	}
+	if cfg.MinPhaseDelay < 0 || cfg.MaxPhaseDelay < cfg.MinPhaseDelay {
+		err := fmt.Errorf("%s: phase delays must not be negative and "+
+			"the maximum must not be less than the minimum",
+			"loadConfig")
+		fmt.Fprintln(os.Stderr, err)
+		return nil, nil, err
+	}
+	if cfg.OfferDeadline <= 0 {
+		err := fmt.Errorf("%s: offer deadline must be positive",
+			"loadConfig")
+		fmt.Fprintln(os.Stderr, err)
+		return nil, nil, err
+	}
+	if cfg.Keepalive < 0 || cfg.WalletKeepalive < 0 {
+		err := fmt.Errorf("%s: keepalive intervals must not be negative",
+			"loadConfig")
+		fmt.Fprintln(os.Stderr, err)
+		return nil, nil, err
+	}
+	if cfg.KeepaliveTimeout <= 0 || cfg.RedialTimeout <= 0 {
+		err := fmt.Errorf("%s: keepalive and redial timeouts must be "+
+			"positive", "loadConfig")
+		fmt.Fprintln(os.Stderr, err)
+		return nil, nil, err
+	}
+	if cfg.Compression != compressionNone &&
+		encoding.GetCompressor(cfg.Compression) == nil {
+		err := fmt.Errorf("%s: unknown compression %q",
+			"loadConfig", cfg.Compression)
+		fmt.Fprintln(os.Stderr, err)
+		return nil, nil, err
+	}
+
+	switch cfg.Progress {
+	case progressPlain, progressJSON, progressTUI, progressNone:
+	default:
+		err := fmt.Errorf("%s: unknown progress mode %q, modes are %s",
+			"loadConfig", cfg.Progress, strings.Join(progressModes, ", "))
+		fmt.Fprintln(os.Stderr, err)
+		return nil, nil, err
+	}
+
 	// Add default port to RPC server based on --testnet and --simnet flags
 	// if needed.
 	if cfg.TumblerRPCServer == "" {
@@ -239,9 +463,88 @@ func loadConfig() (*config, []string, error) {
 			activeNet.WalletClientPort)
 	}
 
+	if err := cfg.loadSecrets(); err != nil {
+		err := fmt.Errorf("%s: %v", "loadConfig", err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The payee wallet is reached like the wallet funding payments
+	// unless configured otherwise.
+	if cfg.PayeeWallet != "" {
+		if cfg.PayeeWallet == cfg.WalletRPCServer {
+			err := fmt.Errorf("%s: --payeewallet must differ from "+
+				"--walletrpcserver", "loadConfig")
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		if cfg.PayeeWalletCert == "" {
+			cfg.PayeeWalletCert = cfg.WalletRPCCert
+		}
+		cfg.PayeeWalletCert = cleanAndExpandPath(cfg.PayeeWalletCert)
+		if cfg.PayeeWalletPass == "" {
+			cfg.PayeeWalletPass = cfg.WalletPassword
+		}
+		if cfg.PayeeAccountName == "" {
+			cfg.PayeeAccountName = cfg.AccountName
+		}
+	}
+
+	// Creating the account derives its keys and requires the wallet
+	// password.
+	if cfg.CreateAccount {
+		if cfg.AccountName == "" {
+			cfg.AccountName = defaultAccountName
+		}
+		if cfg.WalletPassword == "" {
+			err := fmt.Errorf("%s: --createaccount requires the wallet "+
+				"password", "loadConfig")
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
 	return &cfg, remainingArgs, nil
 }
 
+// loadSecrets obtains the wallet password from the encrypted secrets file
+// or prompts for it as requested.  With --createsecrets the secrets file is
+// created instead and the process exits.
+func (cfg *config) loadSecrets() error {
+	if cfg.SecretsFile != "" {
+		cfg.SecretsFile = cleanAndExpandPath(cfg.SecretsFile)
+	}
+	switch {
+	case cfg.CreateSecrets && cfg.SecretsFile == "":
+		return errors.New("--createsecrets requires --secretsfile")
+	case cfg.PromptPass && cfg.SecretsFile != "":
+		return errors.New("--promptpass and --secretsfile can't be " +
+			"used together")
+	case cfg.CreateSecrets:
+		if err := secrets.Create(cfg.SecretsFile, cfg.WalletPassword); err != nil {
+			return err
+		}
+		fmt.Printf("Created secrets file %s\n", cfg.SecretsFile)
+		os.Exit(0)
+	case cfg.PromptPass:
+		pass, err := secrets.Prompt("Wallet password: ")
+		if err != nil {
+			return err
+		}
+		cfg.WalletPassword = string(pass)
+	case cfg.SecretsFile != "" && cfg.WalletPassword == "":
+		s, err := secrets.Load(cfg.SecretsFile)
+		if err != nil {
+			return err
+		}
+		cfg.WalletPassword = s.WalletPassword
+	}
+	return nil
+}
+
 // createDefaultConfig creates a basic config file at the given destination
 // path. For this it tries to read the dcrwallet config file at its default
 // path and extract the wallet password.
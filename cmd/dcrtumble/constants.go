@@ -4,23 +4,14 @@
 
 package main
 
-const (
-	// EpochDuration defines the duration of a single epoch, i.e.
-	// the period within which Escrow, Payment and Chash-Out phases of
-	// the TumbleBit protocol take place. Incidentally, it also specifies
-	// for how long tumbler's funds are escrowed and when it can post a
-	// redeeming transaction to reclaim those funds.
-	EpochDuration = 10
-
-	// EpochRenewal defines an interval between two consecutive epochs
-	// expressed in a number of blocks.
-	EpochRenewal = EpochDuration / 2
-
-	// PuzzleDifficulty determines Tumbler's RSA group size.
-	// Perhaps should be made more generic and expressed in terms of O(2^n)
-	// complexity, where n is 128, 192 or 256 "bits of security".
-	PuzzleDifficulty = 2048
-
+// PuzzleDifficulty determines Tumbler's RSA group size.
+// Perhaps should be made more generic and expressed in terms of O(2^n)
+// complexity, where n is 128, 192 or 256 "bits of security".
+const PuzzleDifficulty = 2048
+
+// Protocol parameters default to the production values and are replaced
+// with the presets of the selected network by loadConfig.
+var (
 	// RealTransactionCount specifies a number of real transactions that
 	// client should be supplying. The chosen values constitute to approx.
 	// ~80 bits of security, i.e. one in a 2^(42+42) chance of cheating
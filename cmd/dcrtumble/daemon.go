@@ -0,0 +1,273 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/decred/dcrd/dcrutil"
+	"github.com/decred/tumblebit/contract"
+	"github.com/decred/tumblebit/wallet"
+)
+
+// The daemon watches the spendable balance of the account funding payments
+// and schedules a mix for every denomination it covers. Mixes start after
+// random delays so that their escrows can't be linked to the deposits by
+// timing, and no more than the configured number run concurrently. The
+// schedule is persisted so that it survives restarts.
+
+// daemonPollInterval is the interval between checks of the balance and of
+// scheduled mixes due to start.
+const daemonPollInterval = time.Minute
+
+// mixFeeMargin is reserved for transaction fees of every mix on top of the
+// denomination and the commission of the tumbler.
+const mixFeeMargin = dcrutil.AtomsPerCent
+
+// scheduledMix is a mix scheduled by the daemon.
+type scheduledMix struct {
+	ID      int       `json:"id"`
+	Start   time.Time `json:"start"`
+	Started bool      `json:"started"`
+}
+
+// mixQueue holds the mixes scheduled by the daemon and persists them in a
+// JSON file.
+type mixQueue struct {
+	path string
+
+	mu     sync.Mutex
+	NextID int             `json:"next_id"`
+	Mixes  []*scheduledMix `json:"mixes"`
+}
+
+// loadMixQueue reads the queue from the file, which is created once the
+// first mix is scheduled.
+func loadMixQueue(path string) (*mixQueue, error) {
+	q := &mixQueue{path: path, NextID: 1}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(b, q); err != nil {
+		return nil, fmt.Errorf("failed to decode the mix queue: %v", err)
+	}
+	return q, nil
+}
+
+// save writes the queue to a temporary file and renames it over the queue
+// file so that the schedule isn't lost if writing fails midway. It must be
+// called with the mutex held.
+func (q *mixQueue) save() error {
+	b, err := json.MarshalIndent(q, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(q.path), 0700); err != nil {
+		return err
+	}
+	tmp := q.path + ".tmp"
+	if err = ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, q.path)
+}
+
+// dropStarted removes mixes that were in progress when the daemon last
+// stopped, since exchanges can't be continued by a new process.
+func (q *mixQueue) dropStarted() ([]*scheduledMix, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var dropped []*scheduledMix
+	mixes := q.Mixes[:0]
+	for _, m := range q.Mixes {
+		if m.Started {
+			dropped = append(dropped, m)
+			continue
+		}
+		mixes = append(mixes, m)
+	}
+	q.Mixes = mixes
+	if len(dropped) == 0 {
+		return nil, nil
+	}
+	return dropped, q.save()
+}
+
+// schedule adds a mix starting after the delay.
+func (q *mixQueue) schedule(delay time.Duration) (*scheduledMix, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	m := &scheduledMix{ID: q.NextID, Start: time.Now().Add(delay)}
+	q.NextID++
+	q.Mixes = append(q.Mixes, m)
+	return m, q.save()
+}
+
+// due marks up to n scheduled mixes whose start time has passed as
+// started and returns them.
+func (q *mixQueue) due(n int) ([]*scheduledMix, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var due []*scheduledMix
+	now := time.Now()
+	for _, m := range q.Mixes {
+		if len(due) == n {
+			break
+		}
+		if !m.Started && !m.Start.After(now) {
+			m.Started = true
+			due = append(due, m)
+		}
+	}
+	if len(due) == 0 {
+		return nil, nil
+	}
+	return due, q.save()
+}
+
+// remove removes a finished mix.
+func (q *mixQueue) remove(m *scheduledMix) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i := range q.Mixes {
+		if q.Mixes[i] == m {
+			q.Mixes = append(q.Mixes[:i], q.Mixes[i+1:]...)
+			break
+		}
+	}
+	return q.save()
+}
+
+// len returns the number of scheduled and running mixes.
+func (q *mixQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.Mixes)
+}
+
+// randomDelay returns a uniformly distributed delay between min and max.
+func randomDelay(min, max time.Duration) (time.Duration, error) {
+	if max <= min {
+		return min, nil
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max-min)+1))
+	if err != nil {
+		return 0, err
+	}
+	return min + time.Duration(n.Int64()), nil
+}
+
+// RunDaemon keeps mixing funds of the account funding payments as they
+// arrive until the context is cancelled. Redeemed escrows must be paid to
+// a different account or wallet, or they would be mixed again.
+func RunDaemon(ctx context.Context, tb *Tumbler, payer, payee *wallet.Wallet, evidence *evidenceLog, cfg *config) error {
+	if cfg.PayoutAccount == "" && cfg.PayeeWallet == "" {
+		return errors.New("The daemon requires --payoutaccount or " +
+			"--payeewallet so that mixed funds aren't mixed again")
+	}
+	path := cfg.QueueFile
+	if path == "" {
+		path = filepath.Join(dcrtumbleHomeDir, activeNet.Params.Name,
+			"daemon.json")
+	}
+	q, err := loadMixQueue(cleanAndExpandPath(path))
+	if err != nil {
+		return err
+	}
+	dropped, err := q.dropStarted()
+	if err != nil {
+		return err
+	}
+	for _, m := range dropped {
+		log.Printf("Mix %d was interrupted, its contracts can be "+
+			"watched and refunded with the watch and refund "+
+			"commands", m.ID)
+	}
+
+	cost := (contract.Denomination + tb.fee + mixFeeMargin).Atoms()
+	running := 0
+	finished := make(chan struct{})
+
+	ticker := time.NewTicker(daemonPollInterval)
+	defer ticker.Stop()
+	for {
+		// Schedule a mix for every denomination the balance covers
+		// beyond the funds of scheduled and running mixes.
+		balance, err := payer.SpendableBalance(ctx)
+		if err != nil {
+			log.Printf("Failed to obtain the balance: %v", err)
+		}
+		for err == nil && balance-int64(q.len())*cost >= cost {
+			delay, err := randomDelay(cfg.MinMixDelay, cfg.MaxMixDelay)
+			if err != nil {
+				return err
+			}
+			m, err := q.schedule(delay)
+			if err != nil {
+				return fmt.Errorf("Failed to schedule a mix: %v", err)
+			}
+			log.Printf("Scheduled mix %d to start at %v", m.ID,
+				m.Start.Format(time.RFC3339))
+		}
+
+		due, err := q.due(cfg.Count - running)
+		if err != nil {
+			return fmt.Errorf("Failed to start mixes: %v", err)
+		}
+		for _, m := range due {
+			m := m
+			running++
+			go func() {
+				defer func() { finished <- struct{}{} }()
+				err := tb.mix(m.ID).Tumble(ctx, payer, payee,
+					evidence, "")
+				if done(ctx) {
+					// Left in the queue to be reported as
+					// interrupted on the next start.
+					return
+				}
+				if err != nil {
+					log.Printf("Mix %d failed: %v", m.ID, err)
+				}
+				if err = q.remove(m); err != nil {
+					log.Printf("Failed to remove mix %d from "+
+						"the queue: %v", m.ID, err)
+				}
+			}()
+		}
+
+		select {
+		case <-ctx.Done():
+			// Running mixes return once they observe the
+			// cancellation.
+			for ; running > 0; running-- {
+				<-finished
+			}
+			return ctx.Err()
+		case <-finished:
+			running--
+		case <-ticker.C:
+		}
+	}
+}
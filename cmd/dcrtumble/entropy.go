@@ -0,0 +1,102 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/decred/tumblebit/wallet"
+)
+
+// seedMessage is signed by the wallet to obtain the seed of the
+// deterministic mode. Wallet signatures are deterministic (RFC 6979),
+// therefore the seed can be recovered from the wallet seed alone.
+const seedMessage = "dcrtumble deterministic seed v1"
+
+// Purposes of randomness derived from the seed.
+const (
+	purposePuzzlePromise = "puzzle-promise"
+	purposeClientPuzzle  = "client-puzzle"
+	purposePuzzleSolver  = "puzzle-solver"
+	purposeSessionKey    = "session-key"
+)
+
+// entropy provides randomness used to construct protocol messages. In the
+// deterministic mode blinding factors, fake factors, pads, salts and
+// permutations are derived from the seed and identifiers of the exchange
+// allowing in-flight protocol state to be regenerated. Otherwise all of
+// them are drawn from crypto/rand.
+type entropy struct {
+	seed []byte
+}
+
+// walletEntropy derives the seed of the deterministic mode from the
+// signature of seedMessage made with the key of the wallet address.
+func walletEntropy(ctx context.Context, w *wallet.Wallet, addr string) (*entropy, error) {
+	sig, err := w.SignMessage(ctx, addr, seedMessage)
+	if err != nil {
+		return nil, err
+	}
+	seed := make([]byte, sha256.Size)
+	kdf := hkdf.New(sha256.New, sig, nil, []byte(seedMessage))
+	if _, err = io.ReadFull(kdf, seed); err != nil {
+		return nil, fmt.Errorf("failed to derive seed: %v", err)
+	}
+	return &entropy{seed: seed}, nil
+}
+
+// reader returns a source of randomness for the purpose within the
+// exchange identified by id.
+func (e *entropy) reader(purpose string, id []byte) (io.Reader, error) {
+	if e == nil || e.seed == nil {
+		return rand.Reader, nil
+	}
+	info := make([]byte, 0, len(purpose)+1+len(id))
+	info = append(info, purpose...)
+	info = append(info, 0)
+	info = append(info, id...)
+	key := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, e.seed, nil, info),
+		key); err != nil {
+		return nil, fmt.Errorf("failed to derive %s key: %v", purpose,
+			err)
+	}
+	return &hmacStream{key: key}, nil
+}
+
+// hmacStream expands a key into an unbounded stream of pseudorandom bytes
+// with HMAC-SHA256 in counter mode. HKDF alone can't produce enough bytes
+// to blind all puzzles of an exchange.
+type hmacStream struct {
+	key     []byte
+	counter uint64
+	buf     []byte
+}
+
+func (s *hmacStream) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(s.buf) == 0 {
+			var ctr [8]byte
+			binary.BigEndian.PutUint64(ctr[:], s.counter)
+			s.counter++
+			mac := hmac.New(sha256.New, s.key)
+			mac.Write(ctr[:])
+			s.buf = mac.Sum(nil)
+		}
+		c := copy(p[n:], s.buf)
+		s.buf = s.buf[c:]
+		n += c
+	}
+	return n, nil
+}
@@ -0,0 +1,258 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/decred/tumblebit/contract"
+	"github.com/decred/tumblebit/wallet"
+)
+
+// The payee depends on the tumbler to solve the puzzle of the payer, and
+// the escrow of the tumbler can't be redeemed without the solution. A
+// tumbler that never fulfills the payment offer simply refunds its escrow
+// once the locktime has passed. Escrow outputs are therefore watched for
+// spends other than the redeeming transaction of the payee, which are
+// recorded as evidence of the failed exchange.
+
+// escrowWatchInterval is the interval between consecutive lookups of the
+// transaction spending a watched escrow.
+const escrowWatchInterval = time.Minute
+
+// Kinds of escrow spends recorded as evidence.
+const (
+	// The tumbler has refunded its escrow after the locktime.
+	spendTumblerRefund = "tumbler-refund"
+	// The escrow has been spent before the locktime by a transaction
+	// other than the redeeming transaction of the payee.
+	spendUnexpected = "unexpected-spend"
+)
+
+// escrowSpend is the evidence of an escrow of the tumbler spent by a
+// transaction other than the redeeming transaction of the payee.
+type escrowSpend struct {
+	Time         time.Time `json:"time"`
+	Kind         string    `json:"kind"`
+	EscrowHash   string    `json:"escrow_hash"`
+	SpenderHash  string    `json:"spender_hash"`
+	RedeemHash   string    `json:"redeem_hash,omitempty"`
+	Height       uint32    `json:"height"`
+	RefundHeight uint32    `json:"refund_height"`
+}
+
+// evidenceLog appends records of escrow spends to a file, one JSON object
+// per line.
+type evidenceLog struct {
+	path string
+	mu   sync.Mutex
+}
+
+// record appends the spend to the log unless the spend of the escrow has
+// already been recorded.
+func (l *evidenceLog) record(sp *escrowSpend) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	spends, err := l.load()
+	if err != nil {
+		return err
+	}
+	for _, s := range spends {
+		if s.EscrowHash == sp.EscrowHash {
+			return nil
+		}
+	}
+
+	b, err := json.Marshal(sp)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE,
+		0600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// load reads all recorded spends. The log is empty until the first spend
+// is recorded.
+func (l *evidenceLog) load() ([]*escrowSpend, error) {
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var spends []*escrowSpend
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var sp escrowSpend
+		if err := json.Unmarshal(line, &sp); err != nil {
+			return nil, fmt.Errorf("malformed evidence record: %v", err)
+		}
+		spends = append(spends, &sp)
+	}
+	return spends, scanner.Err()
+}
+
+// checkEscrow looks up the transaction spending the escrow of the contract
+// of the payee. It returns the spend unless the escrow is unspent or has
+// been redeemed by the payee, in which case the boolean indicates whether
+// watching the escrow is over.
+func checkEscrow(ctx context.Context, w *wallet.Wallet, con *contract.Contract) (*escrowSpend, bool, error) {
+	spender, err := w.EscrowSpender(ctx, con)
+	if err != nil || spender == nil {
+		return nil, false, err
+	}
+	var redeemHash []byte
+	if con.RedeemTx != nil {
+		hash := con.RedeemTx.TxHash()
+		redeemHash = hash[:]
+	}
+	if bytes.Equal(spender, redeemHash) {
+		return nil, true, nil
+	}
+
+	height, err := w.CurrentBlockHeight(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	refundHeight, err := w.RefundHeight(ctx, con)
+	if err != nil {
+		return nil, false, err
+	}
+	sp := &escrowSpend{
+		Time:         time.Now(),
+		Kind:         spendUnexpected,
+		EscrowHash:   hex.EncodeToString(con.EscrowHash),
+		SpenderHash:  hex.EncodeToString(spender),
+		RedeemHash:   hex.EncodeToString(redeemHash),
+		Height:       height,
+		RefundHeight: refundHeight,
+	}
+	if height >= refundHeight {
+		sp.Kind = spendTumblerRefund
+	}
+	return sp, true, nil
+}
+
+// alertSpend reports the spend of the escrow and records it as evidence.
+func alertSpend(evidence *evidenceLog, sp *escrowSpend) {
+	switch sp.Kind {
+	case spendTumblerRefund:
+		log.Printf("ALERT: escrow %s has been refunded to the tumbler "+
+			"with tx %s at block %d", sp.EscrowHash, sp.SpenderHash,
+			sp.Height)
+	default:
+		log.Printf("ALERT: escrow %s has been spent by unexpected tx "+
+			"%s at block %d before its locktime at block %d",
+			sp.EscrowHash, sp.SpenderHash, sp.Height, sp.RefundHeight)
+	}
+	if evidence == nil {
+		return
+	}
+	if err := evidence.record(sp); err != nil {
+		log.Printf("Failed to record the evidence: %v", err)
+	}
+}
+
+// WatchEscrow watches the escrow of the tumbler paying the payee until it
+// has been spent or the context is cancelled. Spends other than the
+// redeeming transaction of the payee are alerted and recorded as evidence.
+func WatchEscrow(ctx context.Context, w *wallet.Wallet, con *contract.Contract, evidence *evidenceLog) error {
+	ticker := time.NewTicker(escrowWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		sp, done, err := checkEscrow(ctx, w, con)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Failed to check escrow %x: %v", con.EscrowHash,
+				err)
+		}
+		if sp != nil {
+			alertSpend(evidence, sp)
+		}
+		if done {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// WatchEscrows watches escrows of the tumbler recorded in the journal
+// until all of them have been spent. Only escrows identified by the hex
+// encoded transaction hashes are watched if any are specified.
+func WatchEscrows(ctx context.Context, w *wallet.Wallet, journal *contract.Journal, evidence *evidenceLog, hashes []string) error {
+	var contracts []*contract.Contract
+	if len(hashes) == 0 {
+		all, err := journal.List()
+		if err != nil {
+			return fmt.Errorf("Failed to list contracts: %v", err)
+		}
+		for _, con := range all {
+			// Escrows of the tumbler are redeemed by the payee
+			// and never refunded by it.
+			if len(con.RedeemBytes) > 0 && len(con.RefundBytes) == 0 {
+				contracts = append(contracts, con)
+			}
+		}
+	}
+	for _, h := range hashes {
+		escrowHash, err := hex.DecodeString(h)
+		if err != nil {
+			return fmt.Errorf("Invalid escrow hash %q: %v", h, err)
+		}
+		con, err := journal.Load(escrowHash)
+		if err != nil {
+			return fmt.Errorf("Failed to load contract %s: %v", h, err)
+		}
+		if len(con.RedeemBytes) == 0 {
+			return fmt.Errorf("Contract %s isn't redeemed by the payee",
+				h)
+		}
+		contracts = append(contracts, con)
+	}
+
+	fmt.Printf("Watching %d escrows\n", len(contracts))
+	errs := make(chan error, len(contracts))
+	for _, con := range contracts {
+		go func(con *contract.Contract) {
+			errs <- WatchEscrow(ctx, w, con, evidence)
+		}(con)
+	}
+	for range contracts {
+		if err := <-errs; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,485 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"time"
+
+	"github.com/decred/tumblebit/contract"
+	"github.com/decred/tumblebit/puzzle"
+)
+
+// Messages exchanged with the tumbler while it escrows funds for the payee
+// are recorded in the contract journal as the transcript of the exchange.
+// The transcript is exported along with recorded spends of the escrow as
+// an evidence bundle that a third party can verify without access to the
+// wallet: the escrow pays the payee, the tumbler has promised the
+// signature of the redeeming transaction of the payee, and the escrow has
+// nevertheless been spent by the tumbler.
+
+// evidenceVersion is the version of the evidence bundle format.
+const evidenceVersion = 1
+
+// Kinds of transcript entries.
+const (
+	transcriptEscrow  = "escrow"
+	transcriptPromise = "puzzle-promise"
+	transcriptOffer   = "offer"
+	transcriptRedeem  = "redeem"
+)
+
+// hexBytes is a byte slice encoded in JSON as a hex string.
+type hexBytes []byte
+
+func (b hexBytes) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(b)), nil
+}
+
+func (b *hexBytes) UnmarshalText(text []byte) error {
+	v, err := hex.DecodeString(string(text))
+	*b = v
+	return err
+}
+
+func hexList(list [][]byte) []hexBytes {
+	l := make([]hexBytes, len(list))
+	for i := range list {
+		l[i] = list[i]
+	}
+	return l
+}
+
+func bytesList(list []hexBytes) [][]byte {
+	l := make([][]byte, len(list))
+	for i := range list {
+		l[i] = list[i]
+	}
+	return l
+}
+
+// transcriptEntry is a timestamped record of a step of the exchange.
+type transcriptEntry struct {
+	Time time.Time       `json:"time"`
+	Kind string          `json:"kind"`
+	Data json.RawMessage `json:"data"`
+}
+
+// escrowTranscript records the escrow offered by the tumbler.
+type escrowTranscript struct {
+	Epoch           int32    `json:"epoch"`
+	Amount          int64    `json:"amount"`
+	LockTime        int32    `json:"lock_time"`
+	RelativeLock    bool     `json:"relative_lock"`
+	ReceiverAddress string   `json:"receiver_address"`
+	ReceiverPubKey  string   `json:"receiver_pubkey"`
+	SenderAddress   string   `json:"sender_address"`
+	SenderPubKey    string   `json:"sender_pubkey"`
+	EscrowScript    hexBytes `json:"escrow_script"`
+	EscrowTx        hexBytes `json:"escrow_tx"`
+}
+
+// promiseTranscript records the Puzzle-Promise protocol: the challenge of
+// the payee, the promises and quotients served by the tumbler and the
+// secrets it has revealed for the fake transactions.
+type promiseTranscript struct {
+	RedeemTx    hexBytes   `json:"redeem_tx"`
+	TxHashes    []hexBytes `json:"tx_hashes"`
+	Salt        hexBytes   `json:"salt"`
+	RandomPads  []hexBytes `json:"random_pads"`
+	RealTxList  hexBytes   `json:"real_tx_list"`
+	FakeTxList  hexBytes   `json:"fake_tx_list"`
+	RealSetHash hexBytes   `json:"real_set_hash"`
+	FakeSetHash hexBytes   `json:"fake_set_hash"`
+	Puzzles     []hexBytes `json:"puzzles"`
+	Promises    []hexBytes `json:"promises"`
+	Quotients   []hexBytes `json:"quotients"`
+	Secrets     []hexBytes `json:"secrets"`
+	PuzzleKey   hexBytes   `json:"puzzle_key"`
+	PublicKey   hexBytes   `json:"public_key"`
+}
+
+// offerTranscript records the payment offer made for the solution.
+type offerTranscript struct {
+	EscrowHash hexBytes `json:"escrow_hash"`
+	Amount     int64    `json:"amount"`
+	LockTime   int32    `json:"lock_time"`
+}
+
+// redeemTranscript records the redeeming transaction of the payee.
+type redeemTranscript struct {
+	RedeemHash hexBytes `json:"redeem_hash"`
+}
+
+// recordTranscript appends the step of the exchange to the transcript of
+// the escrow of the payee kept in the journal, if one is configured.
+func (tb *Tumbler) recordTranscript(escrowHash []byte, kind string, data interface{}) {
+	if tb.journal == nil {
+		return
+	}
+	raw, err := json.Marshal(data)
+	if err == nil {
+		var entry []byte
+		entry, err = json.Marshal(&transcriptEntry{
+			Time: time.Now(),
+			Kind: kind,
+			Data: raw,
+		})
+		if err == nil {
+			err = tb.journal.AppendHistory(escrowHash, entry)
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to record the transcript: %v", err)
+	}
+}
+
+// evidenceBundle packages the transcript of the exchange, the journaled
+// contract and recorded spends of the escrow. The digest covers the
+// bundle with an empty digest, detecting accidental modifications of the
+// archive.
+type evidenceBundle struct {
+	Version    uint32            `json:"version"`
+	Network    string            `json:"network"`
+	EscrowHash hexBytes          `json:"escrow_hash"`
+	Exported   time.Time         `json:"exported"`
+	Transcript []transcriptEntry `json:"transcript"`
+	Contract   json.RawMessage   `json:"contract,omitempty"`
+	Spends     []*escrowSpend    `json:"spends"`
+	Digest     hexBytes          `json:"digest"`
+}
+
+func (b *evidenceBundle) digest() ([]byte, error) {
+	c := *b
+	c.Digest = nil
+	j, err := json.Marshal(&c)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.Sum256(j)
+	return h[:], nil
+}
+
+// ExportEvidence writes the evidence bundle of the escrow identified by
+// the hex encoded transaction hash to the file.
+func ExportEvidence(journal *contract.Journal, evidence *evidenceLog, h, path string) error {
+	escrowHash, err := hex.DecodeString(h)
+	if err != nil {
+		return fmt.Errorf("Invalid escrow hash %q: %v", h, err)
+	}
+	entries, err := journal.History(escrowHash)
+	if err != nil {
+		return fmt.Errorf("Failed to load the transcript of %s: %v", h,
+			err)
+	}
+
+	bundle := &evidenceBundle{
+		Version:    evidenceVersion,
+		Network:    activeNet.Params.Name,
+		EscrowHash: escrowHash,
+		Exported:   time.Now(),
+		Transcript: make([]transcriptEntry, len(entries)),
+	}
+	for i, e := range entries {
+		if err = json.Unmarshal(e, &bundle.Transcript[i]); err != nil {
+			return fmt.Errorf("Malformed transcript entry: %v", err)
+		}
+	}
+	con, err := journal.Load(escrowHash)
+	switch err {
+	case nil:
+		if bundle.Contract, err = con.Marshal(); err != nil {
+			return err
+		}
+	case contract.ErrContractNotFound:
+	default:
+		return fmt.Errorf("Failed to load the contract of %s: %v", h, err)
+	}
+	spends, err := evidence.load()
+	if err != nil {
+		return fmt.Errorf("Failed to load recorded spends: %v", err)
+	}
+	for _, sp := range spends {
+		if sp.EscrowHash == h {
+			bundle.Spends = append(bundle.Spends, sp)
+		}
+	}
+	if bundle.Digest, err = bundle.digest(); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(path, append(b, '\n'), 0600); err != nil {
+		return err
+	}
+	fmt.Printf("Evidence of escrow %s exported to %s\n", h, path)
+	return nil
+}
+
+// VerifyEvidence checks the evidence bundle in the file and reports the
+// findings. It fails unless the transcript proves that the tumbler has
+// escrowed funds for the payee and promised the signature of the
+// redeeming transaction of the payee, or records the disclosure of the
+// payee rejected by the tumbler.
+func VerifyEvidence(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var bundle evidenceBundle
+	if err = json.Unmarshal(b, &bundle); err != nil {
+		return fmt.Errorf("Malformed evidence bundle: %v", err)
+	}
+	if bundle.Version != evidenceVersion {
+		return fmt.Errorf("Unsupported evidence version %d",
+			bundle.Version)
+	}
+	if bundle.Network != activeNet.Params.Name {
+		return fmt.Errorf("Evidence is for network %s", bundle.Network)
+	}
+	digest, err := bundle.digest()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(digest, bundle.Digest) {
+		return errors.New("Evidence digest mismatch, the bundle has " +
+			"been modified")
+	}
+	if len(bundle.Contract) != 0 {
+		con, err := contract.Unmarshal(bundle.Contract)
+		if err != nil {
+			return fmt.Errorf("Malformed contract: %v", err)
+		}
+		if con.ChainParams != activeNet.Params ||
+			!bytes.Equal(con.EscrowHash, bundle.EscrowHash) {
+			return errors.New("Contract doesn't match the bundle")
+		}
+	}
+
+	var escrow *escrowTranscript
+	var promise *promiseTranscript
+	var failures int
+	for _, e := range bundle.Transcript {
+		var err error
+		switch e.Kind {
+		case transcriptEscrow:
+			escrow = new(escrowTranscript)
+			err = json.Unmarshal(e.Data, escrow)
+		case transcriptPromise:
+			promise = new(promiseTranscript)
+			err = json.Unmarshal(e.Data, promise)
+		case transcriptOffer:
+			var offer offerTranscript
+			err = json.Unmarshal(e.Data, &offer)
+			fmt.Printf("%s: payment offer %x made\n",
+				e.Time.Format(time.RFC3339), []byte(offer.EscrowHash))
+		case transcriptRedeem:
+			var redeem redeemTranscript
+			err = json.Unmarshal(e.Data, &redeem)
+			fmt.Printf("%s: escrow redeemed by the payee with tx %x\n",
+				e.Time.Format(time.RFC3339), []byte(redeem.RedeemHash))
+		case transcriptValidationFailure:
+			// The recorded blame is assigned anew from the
+			// recorded disclosure.
+			var failure failureTranscript
+			err = json.Unmarshal(e.Data, &failure)
+			failure.Blame = failure.blame()
+			fmt.Printf("%s: %v\n", e.Time.Format(time.RFC3339),
+				&failure)
+			failures++
+		}
+		if err != nil {
+			return fmt.Errorf("Malformed %s entry: %v", e.Kind, err)
+		}
+	}
+	if (escrow == nil || promise == nil) && failures != 0 {
+		// The exchange has been aborted with the disclosure.
+		return nil
+	}
+	if escrow == nil || promise == nil {
+		return errors.New("Transcript of the escrow is incomplete")
+	}
+
+	con, err := verifyEscrowTranscript(escrow)
+	if err != nil {
+		return fmt.Errorf("Invalid escrow: %v", err)
+	}
+	if !bytes.Equal(con.EscrowHash, bundle.EscrowHash) {
+		return errors.New("Escrow transaction doesn't match the bundle")
+	}
+	fmt.Printf("Escrow %x of epoch %d pays %s from the tumbler key %s, "+
+		"locked until %d\n", con.EscrowHash, escrow.Epoch,
+		escrow.ReceiverAddress, escrow.SenderPubKey, escrow.LockTime)
+
+	if err = verifyPromiseTranscript(con, promise); err != nil {
+		return fmt.Errorf("Invalid puzzle promises: %v", err)
+	}
+	fmt.Printf("Tumbler has promised the signature of redeeming tx %x\n",
+		con.RedeemHash)
+
+	for _, sp := range bundle.Spends {
+		switch sp.Kind {
+		case spendTumblerRefund:
+			fmt.Printf("%s: escrow refunded to the tumbler with tx %s "+
+				"at block %d\n", sp.Time.Format(time.RFC3339),
+				sp.SpenderHash, sp.Height)
+		default:
+			fmt.Printf("%s: escrow spent by unexpected tx %s at "+
+				"block %d before its locktime at block %d\n",
+				sp.Time.Format(time.RFC3339), sp.SpenderHash,
+				sp.Height, sp.RefundHeight)
+		}
+	}
+	fmt.Println("Spending transactions must be looked up on the " +
+		"blockchain to confirm the recorded spends")
+	return nil
+}
+
+// verifyEscrowTranscript makes sure the recorded escrow transaction locks
+// funds of the tumbler for the payee and returns the escrow contract.
+func verifyEscrowTranscript(e *escrowTranscript) (*contract.Contract, error) {
+	amount, err := contract.AmountFromAtoms(e.Amount)
+	if err != nil {
+		return nil, err
+	}
+	con, err := contract.New(activeNet.Params, amount, e.LockTime)
+	if err != nil {
+		return nil, err
+	}
+	if e.RelativeLock {
+		con.LockType = contract.RelativeLock
+	}
+	err = con.SetAddress(contract.ReceiverAddress, e.ReceiverAddress,
+		e.ReceiverPubKey)
+	if err != nil {
+		return nil, err
+	}
+	err = con.SetAddress(contract.SenderAddress, e.SenderAddress,
+		e.SenderPubKey)
+	if err != nil {
+		return nil, err
+	}
+	if err = con.DecodeAndValidateEscrow(e.EscrowTx, e.EscrowScript); err != nil {
+		return nil, err
+	}
+	return con, nil
+}
+
+// verifyPromiseTranscript makes sure the tumbler has opened the fake
+// transactions committed to by the payee and its promises of the real ones
+// all encrypt the signature of the redeeming transaction of the escrow.
+func verifyPromiseTranscript(con *contract.Contract, p *promiseTranscript) error {
+	err := con.ParseTransaction(contract.RedeemTransaction, p.RedeemTx)
+	if err != nil {
+		return err
+	}
+	redeemHash, err := redeemTxHash(con)
+	if err != nil {
+		return err
+	}
+
+	c := &puzzlePromiseChallenge{
+		txHashes:    bytesList(p.TxHashes),
+		salt:        p.Salt,
+		randomPads:  bytesList(p.RandomPads),
+		realTxList:  p.RealTxList,
+		fakeTxList:  p.FakeTxList,
+		realSetHash: p.RealSetHash,
+		fakeSetHash: p.FakeSetHash,
+	}
+	r := &puzzlePromiseResponse{
+		puzzles:   bytesList(p.Puzzles),
+		promises:  bytesList(p.Promises),
+		quotients: bytesList(p.Quotients),
+		secrets:   bytesList(p.Secrets),
+		puzzleKey: p.PuzzleKey,
+		publicKey: p.PublicKey,
+	}
+
+	fakeTxList, realTxList, err := verifyDisclosure(c)
+	if err != nil {
+		return err
+	}
+	n := len(c.txHashes)
+	if len(fakeTxList) != len(r.secrets) ||
+		len(r.puzzles) != n || len(r.promises) != n {
+		return errors.New("incomplete transcript")
+	}
+	for _, idx := range realTxList {
+		if !bytes.Equal(c.txHashes[idx], redeemHash) {
+			return errors.New("real transaction isn't the redeeming " +
+				"transaction of the escrow")
+		}
+	}
+
+	return validatePuzzlePromiseResponse(context.Background(), 0, c, r)
+}
+
+// verifyDisclosure makes sure the index lists and the random pads
+// disclosed by the payee open its commitments and the fake transactions
+// of the challenge, and returns the decoded fake and real index lists.
+func verifyDisclosure(c *puzzlePromiseChallenge) ([]int, []int, error) {
+	fakeTxList, err := puzzle.DecodeIndexList(c.fakeTxList)
+	if err != nil {
+		return nil, nil, err
+	}
+	realTxList, err := puzzle.DecodeIndexList(c.realTxList)
+	if err != nil {
+		return nil, nil, err
+	}
+	n := len(c.txHashes)
+	if len(fakeTxList) != len(c.randomPads) ||
+		len(fakeTxList)+len(realTxList) != n || len(c.salt) != 32 {
+		return nil, nil, errors.New("incomplete disclosure")
+	}
+	for _, l := range [][]int{fakeTxList, realTxList} {
+		for _, idx := range l {
+			if idx < 0 || idx >= n {
+				return nil, nil, errors.New("bad transaction index")
+			}
+		}
+	}
+
+	// The index lists must match the commitments made before the
+	// tumbler served its promises.
+	fakeSetHash, err := puzzle.IndexCommitment{
+		Version: puzzle.CommitmentV1,
+		Phase:   puzzle.PhaseFakeTransactions,
+	}.Commit(c.salt, fakeTxList)
+	if err != nil {
+		return nil, nil, err
+	}
+	realSetHash, err := puzzle.IndexCommitment{
+		Version: puzzle.CommitmentV1,
+		Phase:   puzzle.PhaseRealTransactions,
+	}.Commit(c.salt, realTxList)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !bytes.Equal(fakeSetHash, c.fakeSetHash) ||
+		!bytes.Equal(realSetHash, c.realSetHash) {
+		return nil, nil, errors.New("index lists don't match the " +
+			"commitments")
+	}
+
+	for i, idx := range fakeTxList {
+		if !bytes.Equal(c.txHashes[idx], puzzle.FakeTxFormat(c.randomPads[i])) {
+			return nil, nil, errors.New("fake transaction doesn't " +
+				"match its pad")
+		}
+	}
+	return fakeTxList, realTxList, nil
+}
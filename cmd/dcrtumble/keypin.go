@@ -0,0 +1,235 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+)
+
+// A tumbler serving different puzzle keys to different clients within an
+// epoch could tell them apart and link payers to payees. Fingerprints of
+// keys are therefore pinned on first use for every epoch, and any other
+// key served for the same epoch later on, be it to the payer or to the
+// payee, is rejected. Fingerprints may additionally be verified against a
+// key transparency log publishing the keys of the tumbler.
+
+// maxPinnedEpochs limits the number of epochs pinned for a tumbler, the
+// oldest ones are forgotten first.
+const maxPinnedEpochs = 1000
+
+// keyLogTimeout limits the time spent querying the key transparency log.
+const keyLogTimeout = 30 * time.Second
+
+// errKeyMismatch is returned when the tumbler serves a puzzle key that
+// differs from the one pinned or published for the epoch.
+var errKeyMismatch = errors.New("puzzle key doesn't match the key " +
+	"previously served for the epoch")
+
+// keyPins records fingerprints of puzzle keys served by tumblers in
+// every epoch. Pins are persisted in a JSON file mapping tumbler
+// addresses to fingerprints of their keys by epoch.
+type keyPins struct {
+	path   string
+	server string
+
+	mu   sync.Mutex
+	pins map[string]map[string]string
+}
+
+// loadKeyPins reads fingerprints pinned for the tumbler at the server
+// address from the file, which is created once the first key is pinned.
+func loadKeyPins(path, server string) (*keyPins, error) {
+	kp := &keyPins{
+		path:   path,
+		server: server,
+		pins:   make(map[string]map[string]string),
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return kp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(b, &kp.pins); err != nil {
+		return nil, fmt.Errorf("failed to decode key pins: %v", err)
+	}
+	return kp, nil
+}
+
+// check pins the fingerprint of the puzzle key of the epoch unless it has
+// been pinned already, in which case errKeyMismatch is returned if the
+// fingerprints differ.
+func (kp *keyPins) check(epoch int32, fingerprint []byte) error {
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+
+	pins := kp.pins[kp.server]
+	if pins == nil {
+		pins = make(map[string]string)
+		kp.pins[kp.server] = pins
+	}
+	key := strconv.Itoa(int(epoch))
+	if pinned, ok := pins[key]; ok {
+		if pinned != hex.EncodeToString(fingerprint) {
+			return errKeyMismatch
+		}
+		return nil
+	}
+	pins[key] = hex.EncodeToString(fingerprint)
+	prunePins(pins)
+	return kp.save()
+}
+
+// prunePins forgets the oldest epochs once too many have been pinned.
+func prunePins(pins map[string]string) {
+	if len(pins) <= maxPinnedEpochs {
+		return
+	}
+	epochs := make([]int, 0, len(pins))
+	for key := range pins {
+		epoch, err := strconv.Atoi(key)
+		if err != nil {
+			delete(pins, key)
+			continue
+		}
+		epochs = append(epochs, epoch)
+	}
+	sort.Ints(epochs)
+	for len(epochs) > maxPinnedEpochs {
+		delete(pins, strconv.Itoa(epochs[0]))
+		epochs = epochs[1:]
+	}
+}
+
+// save writes the pins to a temporary file and renames it over the pin
+// file so that pins aren't lost if writing fails midway.
+func (kp *keyPins) save() error {
+	b, err := json.MarshalIndent(kp.pins, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(kp.path), 0700); err != nil {
+		return err
+	}
+	tmp := kp.path + ".tmp"
+	if err = ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, kp.path)
+}
+
+// keyLogEntry is the record of the key transparency log describing the
+// puzzle key of an epoch.
+type keyLogEntry struct {
+	Epoch       int32  `json:"epoch"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// keyLog queries the key transparency log of the tumbler. Fingerprints of
+// the puzzle key of an epoch are requested with the epoch query parameter.
+type keyLog struct {
+	url    string
+	client *http.Client
+}
+
+func newKeyLog(endpoint string) (*keyLog, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, fmt.Errorf("unsupported key log URL scheme %q",
+			u.Scheme)
+	}
+	return &keyLog{
+		url:    endpoint,
+		client: &http.Client{Timeout: keyLogTimeout},
+	}, nil
+}
+
+// fingerprint returns the fingerprint of the puzzle key of the epoch
+// published by the log.
+func (kl *keyLog) fingerprint(ctx context.Context, epoch int32) ([]byte, error) {
+	u, err := url.Parse(kl.url)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("epoch", strconv.Itoa(int(epoch)))
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := kl.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("key log responded with %s", resp.Status)
+	}
+
+	var entry keyLogEntry
+	if err = json.NewDecoder(resp.Body).Decode(&entry); err != nil {
+		return nil, fmt.Errorf("failed to decode key log entry: %v", err)
+	}
+	if entry.Epoch != epoch {
+		return nil, fmt.Errorf("key log returned the key of epoch %d "+
+			"instead of %d", entry.Epoch, epoch)
+	}
+	return hex.DecodeString(entry.Fingerprint)
+}
+
+// verifyPuzzleKey makes sure the puzzle key served by the tumbler is the
+// same key that was served for the epoch before.
+func (tb *Tumbler) verifyPuzzleKey(ctx context.Context, epoch int32, key []byte) error {
+	return tb.verifyKeyHash(ctx, epoch, chainhash.HashB(key))
+}
+
+// verifyKeyHash makes sure the fingerprint of the puzzle key matches the
+// fingerprint pinned for the epoch and the one published by the key
+// transparency log, if any.
+func (tb *Tumbler) verifyKeyHash(ctx context.Context, epoch int32, fingerprint []byte) error {
+	if tb.keyLog != nil {
+		published, err := tb.keyLog.fingerprint(ctx, epoch)
+		if err != nil {
+			return fmt.Errorf("Failed to query the key log: %v", err)
+		}
+		if !bytes.Equal(published, fingerprint) {
+			return fmt.Errorf("Puzzle key of epoch %d doesn't match "+
+				"the key log", epoch)
+		}
+	}
+	if tb.keyPins == nil {
+		return nil
+	}
+	if err := tb.keyPins.check(epoch, fingerprint); err != nil {
+		if err == errKeyMismatch {
+			return fmt.Errorf("Puzzle key of epoch %d changed, the "+
+				"tumbler may be tracking clients", epoch)
+		}
+		return fmt.Errorf("Failed to pin the puzzle key: %v", err)
+	}
+	return nil
+}
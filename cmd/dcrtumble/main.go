@@ -7,16 +7,23 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
 
+	"github.com/decred/tumblebit/contract"
 	"github.com/decred/tumblebit/netparams"
 	"github.com/decred/tumblebit/wallet"
 )
@@ -47,6 +54,7 @@ func main() {
 	if err != nil {
 		os.Exit(1)
 	}
+	contract.VerboseDiagnostics = cfg.VerboseScripts
 
 	if len(args) < 1 {
 		usage("No command specified")
@@ -58,27 +66,191 @@ func main() {
 	ctx := withShutdownCancel(context.Background())
 	go shutdownListener()
 
-	tb, err := connectTumbler(ctx, cfg)
+	if args[0] == "refund" {
+		journal, err := openJournal(cfg)
+		if err != nil {
+			log.Fatalf("Failed to open the contract journal: %v", err)
+		}
+		defer journal.Close()
+
+		w, err := connectWallet(ctx, cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err = RefundOffers(ctx, w, journal, args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if args[0] == "watch" {
+		journal, err := openJournal(cfg)
+		if err != nil {
+			log.Fatalf("Failed to open the contract journal: %v", err)
+		}
+		defer journal.Close()
+
+		// Escrows of the tumbler pay to the payee.
+		w, err := connectWallet(ctx, cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		payee, err := connectPayeeWallet(ctx, cfg, w)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = WatchEscrows(ctx, payee, journal, openEvidence(cfg),
+			args[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if args[0] == "export-evidence" {
+		if len(args) < 2 || len(args) > 3 {
+			usage("Specify the escrow hash and optionally the file")
+			os.Exit(1)
+		}
+		path := fmt.Sprintf("evidence-%s.json", args[1])
+		if len(args) == 3 {
+			path = cleanAndExpandPath(args[2])
+		}
+		journal, err := openJournal(cfg)
+		if err != nil {
+			log.Fatalf("Failed to open the contract journal: %v", err)
+		}
+		defer journal.Close()
+
+		err = ExportEvidence(journal, openEvidence(cfg), args[1], path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if args[0] == "verify-evidence" {
+		if len(args) != 2 {
+			usage("Specify the evidence file")
+			os.Exit(1)
+		}
+		if err := VerifyEvidence(cleanAndExpandPath(args[1])); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("Evidence verified")
+		return
+	}
+
+	if args[0] == "audit" {
+		if len(args) < 3 || len(args) > 4 {
+			usage("Specify the audit log URL, the epoch and " +
+				"optionally the known head of the log")
+			os.Exit(1)
+		}
+		epoch, err := strconv.ParseInt(args[2], 10, 32)
+		if err != nil || epoch <= 0 {
+			usage("Invalid epoch")
+			os.Exit(1)
+		}
+		var head string
+		if len(args) == 4 {
+			head = args[3]
+		}
+		err = AuditEpoch(ctx, args[1], int32(epoch), head)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if args[0] == "export-contract" {
+		if len(args) < 2 || len(args) > 3 {
+			usage("Specify the escrow hash and optionally the file")
+			os.Exit(1)
+		}
+		path := fmt.Sprintf("contract-%s.json", args[1])
+		if len(args) == 3 {
+			path = cleanAndExpandPath(args[2])
+		}
+		journal, err := openJournal(cfg)
+		if err != nil {
+			log.Fatalf("Failed to open the contract journal: %v", err)
+		}
+		defer journal.Close()
+
+		if err = ExportContract(journal, args[1], path); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if args[0] == "verify-contract" {
+		if len(args) != 2 {
+			usage("Specify the contract file")
+			os.Exit(1)
+		}
+		if err := VerifyContract(cleanAndExpandPath(args[1])); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	display, err := newProgress(cfg.Progress, os.Stdout)
 	if err != nil {
 		log.Fatal(err)
 	}
+	display.setPhase(phaseConnect, "set up the escrow")
 
-	w, err := connectWallet(ctx, cfg)
+	tb, err := connectTumbler(ctx, cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
+	tb.progress = display
+	tb.sessionKeys = cfg.SessionKeys
+	tb.parallelism = cfg.Parallelism
+	tb.noStreaming = cfg.NoStreaming
+	tb.offerDeadline = cfg.OfferDeadline
+	tb.deadlineWarning = cfg.DeadlineWarning
+	tb.redialTimeout = cfg.RedialTimeout
+	tb.minPhaseDelay = cfg.MinPhaseDelay
+	tb.maxPhaseDelay = cfg.MaxPhaseDelay
+	if cfg.Compression != compressionNone {
+		tb.compressor = cfg.Compression
+	}
 
-	puzzle, err := tb.NewEscrow(ctx, w)
+	tb.journal, err = openJournal(cfg)
 	if err != nil {
-		log.Fatalf("Failed to setup escrow: %v", err)
+		log.Fatalf("Failed to open the contract journal: %v", err)
 	}
-	solution, err := tb.MakePayment(ctx, w, puzzle)
+	defer tb.journal.Close()
+
+	w, err := connectWallet(ctx, cfg)
 	if err != nil {
-		log.Fatalf("Failed to make payment: %v", err)
+		log.Fatal(err)
+	}
+	payee, err := connectPayeeWallet(ctx, cfg, w)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if cfg.SeedAddress != "" {
+		tb.entropy, err = walletEntropy(ctx, w, cfg.SeedAddress)
+		if err != nil {
+			log.Fatalf("Failed to derive the seed: %v", err)
+		}
+	}
+
+	switch {
+	case args[0] == "daemon":
+		err = RunDaemon(ctx, tb, w, payee, openEvidence(cfg), cfg)
+	case cfg.Count == 1:
+		err = tb.Tumble(ctx, w, payee, openEvidence(cfg),
+			cfg.SolutionFile)
+	default:
+		err = RunMixes(ctx, tb, w, payee, openEvidence(cfg), cfg)
 	}
-	err = tb.RedeemEscrow(ctx, w, puzzle, solution)
 	if err != nil {
-		log.Fatalf("Failed to redeem escrow: %v", err)
+		log.Fatal(err)
 	}
 }
 
@@ -95,7 +267,12 @@ func done(ctx context.Context) bool {
 
 func connectTumbler(ctx context.Context, cfg *config) (*Tumbler, error) {
 	conn, err := startRPCClient(ctx, cfg.TumblerRPCServer,
-		cfg.TumblerRPCCert, !cfg.NoTLS)
+		cfg.TumblerRPCCert, !cfg.NoTLS, cfg.ClientCert, cfg.ClientKey,
+		cfg.MaxMsgSize, keepalive.ClientParameters{
+			Time:                cfg.Keepalive,
+			Timeout:             cfg.KeepaliveTimeout,
+			PermitWithoutStream: true,
+		})
 	if err != nil {
 		return nil, fmt.Errorf("Unable to connect to the TumbleBit RPC "+
 			"server: %v", err)
@@ -112,15 +289,105 @@ func connectTumbler(ctx context.Context, cfg *config) (*Tumbler, error) {
 			"%v", err)
 	}
 
+	if !cfg.NoKeyPins {
+		path := cfg.KeyPinFile
+		if path == "" {
+			path = filepath.Join(dcrtumbleHomeDir,
+				activeNet.Params.Name, "keypins.json")
+		}
+		tb.keyPins, err = loadKeyPins(cleanAndExpandPath(path),
+			cfg.TumblerRPCServer)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to load puzzle key pins: %v",
+				err)
+		}
+	}
+	path := cfg.PuzzleFile
+	if path == "" {
+		path = filepath.Join(dcrtumbleHomeDir, activeNet.Params.Name,
+			"puzzles.json")
+	}
+	tb.puzzles, err = loadPuzzleStore(cleanAndExpandPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load the puzzle store: %v", err)
+	}
+	if cfg.KeyLogURL != "" {
+		tb.keyLog, err = newKeyLog(cfg.KeyLogURL)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid key log URL: %v", err)
+		}
+	}
+
+	if _, err = tb.CheckCompatibility(ctx); err != nil {
+		return nil, fmt.Errorf("Incompatible tumbler: %v", err)
+	}
+
+	if !cfg.NoTLS && !cfg.NoCertUpdate &&
+		!strings.HasPrefix(cfg.TumblerRPCServer, "unix://") {
+		err = tb.updateServerCerts(ctx, cfg.TumblerRPCCert)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to update the TumbleBit "+
+				"RPC server certificate: %v", err)
+		}
+	}
+
 	return tb, nil
 }
 
+// openJournal opens the contract journal, by default located in the
+// network specific directory of the application.
+func openJournal(cfg *config) (*contract.Journal, error) {
+	path := cfg.JournalFile
+	if path == "" {
+		dir := filepath.Join(dcrtumbleHomeDir, activeNet.Params.Name)
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, err
+		}
+		path = filepath.Join(dir, "contracts.db")
+	}
+	return contract.OpenJournal(cleanAndExpandPath(path), activeNet.Params)
+}
+
+// openEvidence returns the log recording evidence of failed exchanges, by
+// default located in the network specific directory of the application.
+func openEvidence(cfg *config) *evidenceLog {
+	path := cfg.EvidenceFile
+	if path == "" {
+		path = filepath.Join(dcrtumbleHomeDir, activeNet.Params.Name,
+			"evidence.json")
+	}
+	return &evidenceLog{path: cleanAndExpandPath(path)}
+}
+
+// connectWallet connects to the wallet funding payments, which also acts as
+// the payee unless a separate payee wallet is configured.
 func connectWallet(ctx context.Context, cfg *config) (*wallet.Wallet, error) {
-	conn, err := startRPCClient(ctx, cfg.WalletRPCServer,
-		cfg.WalletRPCCert, !cfg.NoTLS)
+	return dialWallet(ctx, cfg, cfg.WalletRPCServer, cfg.WalletRPCCert,
+		cfg.WalletPassword, cfg.AccountName)
+}
+
+// connectPayeeWallet connects to the wallet receiving redeemed escrows. It
+// returns the wallet funding payments if no separate payee wallet is
+// configured.
+func connectPayeeWallet(ctx context.Context, cfg *config, payer *wallet.Wallet) (*wallet.Wallet, error) {
+	if cfg.PayeeWallet == "" {
+		return payer, nil
+	}
+	return dialWallet(ctx, cfg, cfg.PayeeWallet, cfg.PayeeWalletCert,
+		cfg.PayeeWalletPass, cfg.PayeeAccountName)
+}
+
+func dialWallet(ctx context.Context, cfg *config, server, cert, password, accountName string) (*wallet.Wallet, error) {
+	conn, err := startRPCClient(ctx, server, cert, !cfg.NoTLS,
+		cfg.WalletClientCert, cfg.WalletClientKey, 0,
+		keepalive.ClientParameters{
+			Time:                cfg.WalletKeepalive,
+			Timeout:             cfg.KeepaliveTimeout,
+			PermitWithoutStream: true,
+		})
 	if err != nil {
-		return nil, fmt.Errorf("Unable to connect to the TumbleBit RPC "+
-			"server: %v", err)
+		return nil, fmt.Errorf("Unable to connect to the wallet RPC "+
+			"server %s: %v", server, err)
 	}
 	//defer conn.Close()
 
@@ -130,10 +397,14 @@ func connectWallet(ctx context.Context, cfg *config) (*wallet.Wallet, error) {
 
 	walletCfg := wallet.Config{
 		Account:          cfg.Account,
-		AccountName:      cfg.AccountName,
+		AccountName:      accountName,
 		ChainParams:      activeNet.Params,
 		WalletConnection: conn,
-		WalletPassword:   cfg.WalletPassword,
+		WalletPassword:   password,
+		CreateAccount:    cfg.CreateAccount,
+		Accounts:         wallet.Accounts{CashOut: cfg.PayoutAccount},
+		Retries:          wallet.DefaultRetries,
+		SplitOutputs:     cfg.SplitOutputs,
 	}
 
 	w, err := wallet.New(ctx, &walletCfg)
@@ -145,21 +416,42 @@ func connectWallet(ctx context.Context, cfg *config) (*wallet.Wallet, error) {
 	return w, nil
 }
 
-func startRPCClient(ctx context.Context, remote, ca string, tls bool) (*grpc.ClientConn, error) {
+// startRPCClient connects to the gRPC server. When a client certificate
+// and key are specified, they are presented to the server during the TLS
+// handshake. Servers listening on Unix domain sockets, specified as
+// unix://path, are connected to without TLS. Messages exchanged with the
+// server are limited to maxMsgSize bytes if it's positive. Idle connections
+// are kept alive with pings unless the keepalive interval is zero. Lost
+// connections are re-established by the client connection on its own.
+func startRPCClient(ctx context.Context, remote, ca string, useTLS bool, certFile, keyFile string, maxMsgSize int, ka keepalive.ClientParameters) (*grpc.ClientConn, error) {
 	var opts []grpc.DialOption
 
-	if tls {
+	if strings.HasPrefix(remote, "unix://") {
+		path := strings.TrimPrefix(remote, "unix://")
+		opts = append(opts, grpc.WithInsecure(),
+			grpc.WithDialer(func(_ string, timeout time.Duration) (net.Conn, error) {
+				return net.DialTimeout("unix", path, timeout)
+			}))
+	} else if useTLS {
 		host, _, err := net.SplitHostPort(remote)
 		if err != nil {
 			return nil, err
 		}
-		creds, err := credentials.NewClientTLSFromFile(ca, host)
+		creds, err := clientCredentials(ca, host, certFile, keyFile)
 		if err != nil {
 			return nil, err
 		}
 		opts = append(opts, grpc.WithTransportCredentials(creds))
 	}
 
+	if maxMsgSize > 0 {
+		opts = append(opts, grpc.WithDefaultCallOptions(
+			grpc.MaxCallRecvMsgSize(maxMsgSize),
+			grpc.MaxCallSendMsgSize(maxMsgSize)))
+	}
+	if ka.Time > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(ka))
+	}
 	opts = append(opts, grpc.WithBlock())
 
 	conn, err := grpc.DialContext(ctx, remote, opts...)
@@ -169,3 +461,28 @@ func startRPCClient(ctx context.Context, remote, ca string, tls bool) (*grpc.Cli
 
 	return conn, nil
 }
+
+func clientCredentials(ca, host, certFile, keyFile string) (credentials.TransportCredentials, error) {
+	if certFile == "" {
+		return credentials.NewClientTLSFromFile(ca, host)
+	}
+
+	pem, err := ioutil.ReadFile(ca)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", ca)
+	}
+	keyPair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the client certificate: %v",
+			err)
+	}
+	return credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{keyPair},
+		RootCAs:      pool,
+		ServerName:   host,
+	}), nil
+}
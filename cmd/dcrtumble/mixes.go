@@ -0,0 +1,122 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/decred/tumblebit/wallet"
+)
+
+// Tumble exchanges a single coin through the tumbler, acting as both the
+// payee and the payer, and exports the solution of the puzzle to the file
+// if one is specified. The payer wallet funds the payment and the payee
+// wallet receives the redeemed escrow, they may be the same wallet.
+func (tb *Tumbler) Tumble(ctx context.Context, payer, payee *wallet.Wallet, evidence *evidenceLog, solutionFile string) error {
+	puzzle, err := tb.NewEscrow(ctx, payee)
+	if err != nil {
+		return fmt.Errorf("Failed to setup escrow: %v", err)
+	}
+	go WatchEscrow(ctx, payee, puzzle.Contract, evidence)
+
+	solution, err := tb.MakePayment(ctx, payer, puzzle)
+	if err != nil {
+		return fmt.Errorf("Failed to make payment: %v", err)
+	}
+	err = tb.WaitForSolution(ctx, payer, payee, puzzle, solution)
+	if err != nil {
+		return fmt.Errorf("Failed to obtain the solution: %v", err)
+	}
+	if solutionFile != "" {
+		err = ExportSolution(cleanAndExpandPath(solutionFile), puzzle,
+			solution)
+		if err != nil {
+			return fmt.Errorf("Failed to export the solution: %v", err)
+		}
+	}
+	err = tb.RedeemEscrow(ctx, payee, puzzle, solution)
+	if err != nil {
+		return fmt.Errorf("Failed to redeem escrow: %v", err)
+	}
+	return nil
+}
+
+// mix returns a client running a separate exchange over the connection of
+// the client. Mixes share the negotiated parameters, the journal and the
+// source of randomness, while their sessions, keys and contracts are
+// independent. Progress of the mix is reported with the label of the mix.
+func (tb *Tumbler) mix(n int) *Tumbler {
+	m := *tb
+	m.sequence = 0
+	m.progress = tb.progress.forMix(n)
+	return &m
+}
+
+// mixSolutionFile returns the file the solution of the nth mix is exported
+// to, which is named after the configured file with the number of the mix
+// appended to the base name.
+func mixSolutionFile(path string, n int) string {
+	if path == "" {
+		return ""
+	}
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(path, ext), n, ext)
+}
+
+// RunMixes runs the configured number of exchanges concurrently, each
+// started the configured interval after the previous one, so that larger
+// amounts are split into multiple denominations in a single run. All
+// started mixes are completed before an error is returned if any of them
+// failed.
+func RunMixes(ctx context.Context, tb *Tumbler, payer, payee *wallet.Wallet, evidence *evidenceLog, cfg *config) error {
+	var wg sync.WaitGroup
+	errs := make([]error, cfg.Count)
+	for i := 0; i < cfg.Count; i++ {
+		if i != 0 && cfg.Interval > 0 {
+			select {
+			case <-time.After(cfg.Interval):
+			case <-ctx.Done():
+			}
+		}
+		if done(ctx) {
+			// Mixes that haven't been started count as failed.
+			for j := i; j < cfg.Count; j++ {
+				errs[j] = ctx.Err()
+			}
+			break
+		}
+
+		n := i + 1
+		m := tb.mix(n)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := m.Tumble(ctx, payer, payee, evidence,
+				mixSolutionFile(cfg.SolutionFile, n))
+			if err != nil {
+				log.Printf("Mix %d failed: %v", n, err)
+				errs[n-1] = err
+			}
+		}()
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d mixes failed", failed, cfg.Count)
+	}
+	return nil
+}
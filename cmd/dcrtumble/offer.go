@@ -0,0 +1,114 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/decred/tumblebit/contract"
+	"github.com/decred/tumblebit/wallet"
+)
+
+// Payment offers the tumbler hasn't accepted yet, usually since their
+// escrows aren't confirmed, are resubmitted once the escrow is confirmed
+// in the wallet of the payer. The interval between retries starts at
+// offerRetryInterval and doubles up to maxOfferRetryInterval.
+const (
+	offerRetryInterval    = 15 * time.Second
+	maxOfferRetryInterval = 5 * time.Minute
+)
+
+// errOfferDeadline is returned when the tumbler hasn't accepted the
+// payment offer before the deadline.
+var errOfferDeadline = errors.New("tumbler hasn't accepted the payment " +
+	"offer before the deadline")
+
+// offerRetry waits before payment offers are resubmitted to the tumbler.
+type offerRetry struct {
+	w        *wallet.Wallet
+	con      *contract.Contract
+	deadline time.Time
+	interval time.Duration
+}
+
+// backoff returns the interval before the next retry and doubles the
+// following one.
+func (r *offerRetry) backoff() time.Duration {
+	interval := r.interval
+	r.interval *= 2
+	if r.interval > maxOfferRetryInterval {
+		r.interval = maxOfferRetryInterval
+	}
+	return interval
+}
+
+// wait waits for at least the delay requested by the tumbler and until
+// the offer escrow is confirmed. errOfferDeadline is returned if the
+// offer can't be resubmitted before the deadline.
+func (r *offerRetry) wait(ctx context.Context, delay time.Duration, cause error) error {
+	if interval := r.backoff(); delay < interval {
+		delay = interval
+	}
+	log.Printf("Retrying PaymentOffer in %v: %v", delay, cause)
+	for {
+		if time.Now().Add(delay).After(r.deadline) {
+			return errOfferDeadline
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		confs, err := r.w.Confirmations(ctx, r.con.EscrowHash)
+		if err != nil {
+			return err
+		}
+		if confs > 0 {
+			return nil
+		}
+		delay = r.backoff()
+	}
+}
+
+// refundAbandonedOffer waits until the lock time of the abandoned offer
+// expires and refunds its escrow unless the tumbler has spent it in the
+// meantime.
+func (tb *Tumbler) refundAbandonedOffer(ctx context.Context, w *wallet.Wallet, con *contract.Contract) error {
+	ticker := time.NewTicker(solutionPollInterval)
+	defer ticker.Stop()
+
+	for {
+		height, err := w.CurrentBlockHeight(ctx)
+		if err != nil {
+			return err
+		}
+		refundHeight, err := w.RefundHeight(ctx, con)
+		if err != nil {
+			return err
+		}
+		if height >= refundHeight {
+			return refundOffer(ctx, w, tb.journal, con, height)
+		}
+		spent, err := w.EscrowSpent(ctx, con)
+		if err != nil {
+			return err
+		}
+		if spent {
+			return fmt.Errorf("Escrow %x has been spent by the tumbler",
+				con.EscrowHash)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
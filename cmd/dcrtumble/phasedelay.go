@@ -0,0 +1,34 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Requests of an exchange made as soon as the previous one has been served
+// leave a recognizable timing signature, which the tumbler or an observer
+// of the blockchain may use to link the escrow of the payee, the payment
+// offer and the redeeming transaction to each other. The client therefore
+// pauses for a random delay before every phase of the exchange.
+
+// pausePhase waits for a delay drawn uniformly between the minimum and the
+// maximum phase delay before the next phase of the exchange starts.
+func (tb *Tumbler) pausePhase(ctx context.Context) error {
+	if tb.maxPhaseDelay == 0 {
+		return nil
+	}
+	delay, err := randomDelay(tb.minPhaseDelay, tb.maxPhaseDelay)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
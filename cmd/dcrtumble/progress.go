@@ -0,0 +1,288 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// Progress output modes.
+const (
+	progressNone  = "none"
+	progressPlain = "plain"
+	progressJSON  = "json"
+	progressTUI   = "tui"
+)
+
+// progressModes lists the modes selectable with --progress.
+var progressModes = []string{progressPlain, progressJSON, progressTUI,
+	progressNone}
+
+// phase is a step of the exchange reported by the progress display.
+type phase int
+
+const (
+	phaseConnect phase = iota
+	phaseEscrow
+	phasePuzzlePromise
+	phaseSolutionPromise
+	phaseOffer
+	phaseAwaitSolution
+	phaseRedeem
+	phaseDone
+)
+
+var phaseNames = [...]string{
+	phaseConnect:         "connect",
+	phaseEscrow:          "escrow",
+	phasePuzzlePromise:   "puzzle-promise",
+	phaseSolutionPromise: "solution-promise",
+	phaseOffer:           "offer",
+	phaseAwaitSolution:   "await-solution",
+	phaseRedeem:          "redeem",
+	phaseDone:            "done",
+}
+
+var phaseDescriptions = [...]string{
+	phaseConnect:         "Connecting to the tumbler",
+	phaseEscrow:          "Setting up the escrow of the tumbler",
+	phasePuzzlePromise:   "Obtaining the cash-out promise",
+	phaseSolutionPromise: "Obtaining solution promises",
+	phaseOffer:           "Publishing the payment offer",
+	phaseAwaitSolution:   "Awaiting the solution of the puzzle",
+	phaseRedeem:          "Redeeming the escrow",
+	phaseDone:            "Exchange completed",
+}
+
+func (p phase) String() string {
+	if p >= 0 && int(p) < len(phaseNames) {
+		return phaseNames[p]
+	}
+	return fmt.Sprintf("phase(%d)", int(p))
+}
+
+// progressEvent is the state of the exchange reported whenever it changes.
+// Block heights and confirmations are only known while the client awaits
+// transactions to be mined. Events of concurrent mixes carry the number of
+// the mix.
+type progressEvent struct {
+	Time          time.Time `json:"time"`
+	Mix           int       `json:"mix,omitempty"`
+	Phase         string    `json:"phase"`
+	Step          int       `json:"step"`
+	Steps         int       `json:"steps"`
+	Epoch         int32     `json:"epoch,omitempty"`
+	Height        uint32    `json:"height,omitempty"`
+	Confirmations int32     `json:"confirmations"`
+	Deadline      uint32    `json:"deadline,omitempty"`
+	NextAction    string    `json:"next_action,omitempty"`
+	Message       string    `json:"message,omitempty"`
+}
+
+// progress reports the state of the exchange in one of the output modes.
+// The methods of a nil progress do nothing, so the session code reports
+// events unconditionally.
+type progress struct {
+	mu    sync.Mutex
+	out   io.Writer
+	mode  string
+	state progressEvent
+	start time.Time
+	lines int // Lines drawn by the terminal UI
+}
+
+// newProgress creates a progress display writing to the file in the
+// specified mode. The terminal UI falls back to plain output when the
+// file isn't a terminal.
+func newProgress(mode string, out *os.File) (*progress, error) {
+	switch mode {
+	case progressNone:
+		return nil, nil
+	case progressTUI:
+		if !terminal.IsTerminal(int(out.Fd())) {
+			mode = progressPlain
+		}
+	case progressPlain, progressJSON:
+	default:
+		return nil, fmt.Errorf("unknown progress mode %q, modes are %s",
+			mode, strings.Join(progressModes, ", "))
+	}
+	return &progress{
+		out:   out,
+		mode:  mode,
+		start: time.Now(),
+		state: progressEvent{Steps: int(phaseDone)},
+	}, nil
+}
+
+// forMix returns a display of the progress of the nth of concurrent mixes
+// writing to the same output. The terminal UI can't draw multiple status
+// blocks, so mixes are reported with plain output instead.
+func (p *progress) forMix(n int) *progress {
+	if p == nil {
+		return nil
+	}
+	mode := p.mode
+	if mode == progressTUI {
+		mode = progressPlain
+	}
+	return &progress{
+		out:   p.out,
+		mode:  mode,
+		start: time.Now(),
+		state: progressEvent{Mix: n, Steps: int(phaseDone)},
+	}
+}
+
+// setPhase reports that the exchange has entered the phase. The next
+// action describes what the client does once the phase completes.
+func (p *progress) setPhase(ph phase, next string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.state.Phase = ph.String()
+	p.state.Step = int(ph)
+	p.state.Height = 0
+	p.state.Confirmations = 0
+	p.state.Deadline = 0
+	p.state.NextAction = next
+	p.state.Message = phaseDescriptions[ph]
+	p.emit()
+}
+
+// setEpoch reports the epoch the exchange takes place in.
+func (p *progress) setEpoch(epoch int32) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.state.Epoch = epoch
+	p.mu.Unlock()
+}
+
+// confirmations reports the current block height, the confirmations of
+// the transaction the client awaits and the height by which the phase
+// must complete.
+func (p *progress) confirmations(height uint32, confs int32, deadline uint32) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.state.Height == height && p.state.Confirmations == confs &&
+		p.state.Deadline == deadline {
+		return
+	}
+	p.state.Height = height
+	p.state.Confirmations = confs
+	p.state.Deadline = deadline
+	p.emit()
+}
+
+// emit writes the current state in the output mode. It must be called
+// with the mutex held.
+func (p *progress) emit() {
+	p.state.Time = time.Now()
+	switch p.mode {
+	case progressJSON:
+		json.NewEncoder(p.out).Encode(&p.state)
+	case progressTUI:
+		p.draw()
+	default:
+		fmt.Fprintln(p.out, p.line())
+	}
+}
+
+// line describes the state on a single line.
+func (p *progress) line() string {
+	s := &p.state
+	prefix := s.Time.Format("15:04:05")
+	if s.Mix != 0 {
+		prefix += fmt.Sprintf(" mix %d", s.Mix)
+	}
+	str := fmt.Sprintf("%s [%d/%d] %s", prefix, s.Step, s.Steps,
+		s.Message)
+	if s.Height != 0 {
+		str += fmt.Sprintf(", block %d, %d confirmations", s.Height,
+			s.Confirmations)
+	}
+	if s.Deadline != 0 && s.Deadline != math.MaxUint32 {
+		str += fmt.Sprintf(", deadline %d", s.Deadline)
+	}
+	if s.NextAction != "" {
+		str += fmt.Sprintf("; next: %s", s.NextAction)
+	}
+	return str
+}
+
+// progressBarWidth is the number of cells of the terminal progress bar.
+const progressBarWidth = 30
+
+// draw redraws the status block of the terminal UI in place.
+func (p *progress) draw() {
+	s := &p.state
+	filled := progressBarWidth * s.Step / s.Steps
+	lines := []string{
+		fmt.Sprintf("[%s%s] %d/%d %s",
+			strings.Repeat("#", filled),
+			strings.Repeat(".", progressBarWidth-filled),
+			s.Step, s.Steps, s.Message),
+		fmt.Sprintf("  Epoch:          %s", optional(int64(s.Epoch))),
+		fmt.Sprintf("  Block height:   %s", optional(int64(s.Height))),
+		fmt.Sprintf("  Confirmations:  %d", s.Confirmations),
+		fmt.Sprintf("  Deadline:       %s", p.deadline()),
+		fmt.Sprintf("  Next action:    %s", s.NextAction),
+		fmt.Sprintf("  Elapsed:        %s",
+			time.Since(p.start).Truncate(time.Second)),
+	}
+
+	var b bytes.Buffer
+	if p.lines != 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", p.lines)
+	}
+	for _, l := range lines {
+		b.WriteString("\x1b[2K")
+		b.WriteString(l)
+		b.WriteByte('\n')
+	}
+	p.out.Write(b.Bytes())
+	p.lines = len(lines)
+}
+
+// deadline describes the height the current phase must complete by.
+func (p *progress) deadline() string {
+	s := &p.state
+	switch {
+	case s.Deadline == 0:
+		return "-"
+	case s.Deadline == math.MaxUint32:
+		return "set once the offer is mined"
+	case s.Height != 0 && s.Deadline > s.Height:
+		return fmt.Sprintf("block %d (%d blocks left)", s.Deadline,
+			s.Deadline-s.Height)
+	}
+	return fmt.Sprintf("block %d", s.Deadline)
+}
+
+func optional(v int64) string {
+	if v == 0 {
+		return "-"
+	}
+	return fmt.Sprint(v)
+}
@@ -6,15 +6,14 @@ package main
 
 import (
 	"bytes"
-	"crypto/rand"
-	"encoding/binary"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"math/big"
-	mrand "math/rand"
 
 	"github.com/decred/dcrd/chaincfg/chainec"
-	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/tumblebit/contract"
 	"github.com/decred/tumblebit/puzzle"
 	"github.com/decred/tumblebit/shuffle"
 )
@@ -30,8 +29,9 @@ type puzzleSolverChallenge struct {
 
 // createPuzzleSolverChallenge generates a shuffled set of puzzles
 // consisting of puzzle blinded with distinct random factors and fake
-// factors indistinguishable from a blinded puzzle.
-func createPuzzleSolverChallenge(p []byte, puzzleKey []byte) (*puzzleSolverChallenge, error) {
+// factors indistinguishable from a blinded puzzle. Factors and the
+// permutation are read from the source of randomness.
+func createPuzzleSolverChallenge(random io.Reader, p []byte, puzzleKey []byte) (*puzzleSolverChallenge, error) {
 	var err error
 
 	pkey, err := puzzle.ParsePubKey(puzzleKey)
@@ -56,7 +56,7 @@ func createPuzzleSolverChallenge(p []byte, puzzleKey []byte) (*puzzleSolverChall
 	for i := range puzzles {
 		if i < FakePreimageCount {
 			puzzles[i], fakeFactors[i], _, err =
-				puzzle.BlindPuzzle(&pkey, one)
+				puzzle.BlindPuzzleWithRand(random, &pkey, one)
 			if err != nil {
 				return nil, err
 			}
@@ -64,7 +64,7 @@ func createPuzzleSolverChallenge(p []byte, puzzleKey []byte) (*puzzleSolverChall
 		} else {
 			puzzles[i], realFactors[i-FakePreimageCount],
 				realInverses[i-FakePreimageCount], err =
-				puzzle.BlindPuzzle(&pkey, p)
+				puzzle.BlindPuzzleWithRand(random, &pkey, p)
 			if err != nil {
 				return nil, fmt.Errorf("failed to : %v", err)
 			}
@@ -73,17 +73,16 @@ func createPuzzleSolverChallenge(p []byte, puzzleKey []byte) (*puzzleSolverChall
 	}
 
 	// Shuffle puzzle list
-	s := shuffle.Shuffle(rand.Reader, len(puzzles), func(i, j int) {
+	perm, err := shuffle.ShuffleSecure(random, len(puzzles), func(i, j int) {
 		puzzles[i], puzzles[j] = puzzles[j], puzzles[i]
 	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to shuffle puzzles: %v", err)
+	}
 
 	// Update list indexes
-	for i := range fakePuzzleList {
-		fakePuzzleList[i] = s.Get(fakePuzzleList[i])
-	}
-	for i := range realPuzzleList {
-		realPuzzleList[i] = s.Get(realPuzzleList[i])
-	}
+	perm.Apply(fakePuzzleList)
+	perm.Apply(realPuzzleList)
 
 	serFakePuzzleList, err := puzzle.EncodeIndexList(fakePuzzleList)
 	if err != nil {
@@ -111,6 +110,7 @@ type puzzleSolverResponse struct {
 	promises  [][]byte
 	keyHashes [][]byte
 	secrets   [][]byte
+	hashLock  contract.HashLock
 }
 
 // validatePuzzleSolverResponse verifies secret keys provided by the tumbler
@@ -122,7 +122,7 @@ func validatePuzzleSolverResponse(c *puzzleSolverChallenge, r *puzzleSolverRespo
 	}
 
 	for i, idx := range fakePuzzleList {
-		if !bytes.Equal(chainhash.HashB(r.secrets[i]), r.keyHashes[idx]) {
+		if !bytes.Equal(r.hashLock.KeyHash(r.secrets[i]), r.keyHashes[idx]) {
 			return errors.New("secret hash didn't verify")
 		}
 		solution, err := puzzle.RevealSolution(r.promises[idx],
@@ -151,6 +151,42 @@ func createPreimageChallanges(c *puzzleSolverChallenge, r *puzzleSolverResponse)
 	return keyHashes, nil
 }
 
+// extractPuzzleSolution recovers the solution of the puzzle p from hash
+// preimages revealed by the tumbler in the transaction fulfilling the
+// offer. Preimages are matched against key hashes regardless of their
+// order in the signature script.
+func extractPuzzleSolution(c *puzzleSolverChallenge, r *puzzleSolverResponse, puzzleKey, p []byte, preimages [][]byte) ([]byte, error) {
+	pkey, err := puzzle.ParsePubKey(puzzleKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode puzzle key: %v", err)
+	}
+
+	realPuzzleList, err := puzzle.DecodeIndexList(c.realPuzzleList)
+	if err != nil {
+		return nil, errors.New("failed to decode an index list")
+	}
+
+	for i, idx := range realPuzzleList {
+		for _, preimage := range preimages {
+			if !bytes.Equal(r.hashLock.KeyHash(preimage), r.keyHashes[idx]) {
+				continue
+			}
+			blinded, err := puzzle.RevealSolution(r.promises[idx],
+				preimage)
+			if err != nil {
+				continue
+			}
+			solution := puzzle.UnblindPuzzle(&pkey, blinded,
+				c.realInverses[i])
+			if puzzle.ValidatePuzzle(&pkey, p, solution) {
+				return solution, nil
+			}
+		}
+	}
+
+	return nil, errors.New("revealed preimages don't solve the puzzle")
+}
+
 type puzzlePromiseChallenge struct {
 	txHashes    [][]byte
 	salt        []byte
@@ -161,7 +197,10 @@ type puzzlePromiseChallenge struct {
 	fakeSetHash []byte
 }
 
-func createPuzzlePromiseChallenge(realTxHashes [][]byte) (*puzzlePromiseChallenge, error) {
+// createPuzzlePromiseChallenge mixes hashes of real transactions with fake
+// ones made of random pads and commits to their shuffled order. Pads, the
+// permutation and the salt are read from the source of randomness.
+func createPuzzlePromiseChallenge(random io.Reader, realTxHashes [][]byte) (*puzzlePromiseChallenge, error) {
 	txh := make([][]byte, RealTransactionCount+FakeTransactionCount)
 
 	fakeTxList := make([]int, FakeTransactionCount)
@@ -171,7 +210,11 @@ func createPuzzlePromiseChallenge(realTxHashes [][]byte) (*puzzlePromiseChalleng
 	for i := range txh {
 		if i < FakeTransactionCount {
 			randomPads[i] = make([]byte, 32)
-			rand.Read(randomPads[i])
+			_, err := io.ReadFull(random, randomPads[i])
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate pad: %v",
+					err)
+			}
 			txh[i] = puzzle.FakeTxFormat(randomPads[i])
 			fakeTxList[i] = i
 		} else {
@@ -181,17 +224,17 @@ func createPuzzlePromiseChallenge(realTxHashes [][]byte) (*puzzlePromiseChalleng
 	}
 
 	// Shuffle transaction list
-	s := shuffle.Shuffle(rand.Reader, len(txh), func(i, j int) {
+	perm, err := shuffle.ShuffleSecure(random, len(txh), func(i, j int) {
 		txh[i], txh[j] = txh[j], txh[i]
 	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to shuffle transactions: %v",
+			err)
+	}
 
 	// Update list indexes
-	for i := range fakeTxList {
-		fakeTxList[i] = s.Get(fakeTxList[i])
-	}
-	for i := range realTxList {
-		realTxList[i] = s.Get(realTxList[i])
-	}
+	perm.Apply(fakeTxList)
+	perm.Apply(realTxList)
 
 	serFakeTxList, err := puzzle.EncodeIndexList(fakeTxList)
 	if err != nil {
@@ -206,19 +249,25 @@ func createPuzzlePromiseChallenge(realTxHashes [][]byte) (*puzzlePromiseChalleng
 	}
 
 	salt := make([]byte, 32)
-	if _, err = rand.Read(salt); err != nil {
+	if _, err = io.ReadFull(random, salt); err != nil {
 		return nil, fmt.Errorf("failed to generate salt: %v", err)
 	}
 
-	// Hash them up and serve.
-	fakeSetHash, err := puzzle.HashIndexList(salt, fakeTxList)
+	// Commit to them and serve.
+	fakeSetHash, err := puzzle.IndexCommitment{
+		Version: puzzle.CommitmentV1,
+		Phase:   puzzle.PhaseFakeTransactions,
+	}.Commit(salt, fakeTxList)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate index list hash: %v",
+		return nil, fmt.Errorf("failed to commit to the index list: %v",
 			err)
 	}
-	realSetHash, err := puzzle.HashIndexList(salt, realTxList)
+	realSetHash, err := puzzle.IndexCommitment{
+		Version: puzzle.CommitmentV1,
+		Phase:   puzzle.PhaseRealTransactions,
+	}.Commit(salt, realTxList)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate index list hash: %v",
+		return nil, fmt.Errorf("failed to commit to the index list: %v",
 			err)
 	}
 
@@ -242,7 +291,12 @@ type puzzlePromiseResponse struct {
 	publicKey []byte
 }
 
-func validatePuzzlePromiseResponse(c *puzzlePromiseChallenge, r *puzzlePromiseResponse) error {
+// validatePuzzlePromiseResponse makes sure secrets revealed by the tumbler
+// open the fake puzzles and decrypt valid signatures of the fake
+// transactions and that quotients link the real puzzles together. Puzzles
+// and quotients are verified by at most the specified number of concurrent
+// workers and verification stops at the first failure.
+func validatePuzzlePromiseResponse(ctx context.Context, workers int, c *puzzlePromiseChallenge, r *puzzlePromiseResponse) error {
 	pkey, err := puzzle.ParsePubKey(r.puzzleKey)
 	if err != nil {
 		return fmt.Errorf("failed to decode puzzle key: %v", err)
@@ -257,9 +311,44 @@ func validatePuzzlePromiseResponse(c *puzzlePromiseChallenge, r *puzzlePromiseRe
 		return fmt.Errorf("failed to decode real tx index list: %v", err)
 	}
 
-	for i, j := range fakeTxList {
+	if len(r.secrets) != len(fakeTxList) ||
+		len(r.promises) != len(r.puzzles) {
+		return errors.New("malformed puzzle-promise response")
+	}
+	for _, idx := range append(fakeTxList, realTxList...) {
+		if idx >= len(r.puzzles) || idx >= len(c.txHashes) {
+			return errors.New("bad tx reference")
+		}
+	}
+
+	realPuzzles := make([][]byte, len(realTxList))
+	for i, idx := range realTxList {
+		realPuzzles[i] = r.puzzles[idx]
+	}
+	chain := &puzzle.PromiseChain{
+		Key:       &pkey,
+		Puzzles:   realPuzzles,
+		Quotients: r.quotients,
+	}
+	if err = chain.Validate(); err != nil {
+		return fmt.Errorf("malformed quotients: %v", err)
+	}
+
+	// Fake transactions are followed by links of the chain.
+	n := len(fakeTxList) + chain.Len()
+	return puzzle.ForEach(ctx, workers, n, func(k int) error {
+		if k >= len(fakeTxList) {
+			err := chain.VerifyLink(k - len(fakeTxList))
+			if err != nil {
+				return fmt.Errorf("failed to verify quotients: %v",
+					err)
+			}
+			return nil
+		}
+
+		i, j := k, fakeTxList[k]
 		if !puzzle.ValidatePuzzle(&pkey, r.puzzles[j], r.secrets[i]) {
-			errors.New("obtained secrets didn't verify")
+			return errors.New("obtained secrets didn't verify")
 		}
 		sig, err := puzzle.RevealSolution(r.promises[j], r.secrets[i])
 		if err != nil {
@@ -269,58 +358,49 @@ func validatePuzzlePromiseResponse(c *puzzlePromiseChallenge, r *puzzlePromiseRe
 		if err != nil {
 			return fmt.Errorf("signature didn't verify: %v", err)
 		}
-	}
+		return nil
+	})
+}
 
-	realPuzzles := make([][]byte, len(realTxList))
-	for i, idx := range realTxList {
-		realPuzzles[i] = r.puzzles[idx]
-	}
-	if !puzzle.VerifyQuotients(&pkey, r.quotients, realPuzzles) {
-		errors.New("failed to verify quotients")
+// createClientPuzzle blinds the promised puzzle with the index for the
+// payer.
+func createClientPuzzle(random io.Reader, which int, r *puzzlePromiseResponse) ([]byte, []byte, error) {
+	pkey, err := puzzle.ParsePubKey(r.puzzleKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode puzzle "+
+			"key: %v", err)
 	}
 
-	return nil
+	puzzle, _, factor, err := puzzle.BlindPuzzleWithRand(random, &pkey,
+		r.puzzles[which])
+	if err != nil {
+		return nil, nil, err
+	}
+	return puzzle, factor, nil
 }
 
-func createClientPuzzle(c *puzzlePromiseChallenge, r *puzzlePromiseResponse) (int, []byte, []byte, error) {
-	realTxList, err := puzzle.DecodeIndexList(c.realTxList)
+// recoverPromisedSignature unblinds the solution of the puzzle handed over
+// to the payer and decrypts the signature of the cash-out transaction that
+// was promised by the tumbler.
+func recoverPromisedSignature(pp *PaymentPuzzle, solution []byte, txHash []byte) ([]byte, error) {
+	pkey, err := puzzle.ParsePubKey(pp.Key)
 	if err != nil {
-		return 0, nil, nil, fmt.Errorf("failed to decode tx index"+
-			" list: %v", err)
-	}
-
-	// Pick puzzle at random to avoid any dependencies on the known index
-	buf := make([]byte, 8)
-	if _, err := rand.Read(buf); err != nil {
-		return 0, nil, nil, fmt.Errorf("failed to generate seed:"+
-			" %v", err)
-	}
-	seed := int64(binary.LittleEndian.Uint64(buf))
-	rnd := mrand.New(mrand.NewSource(seed))
-
-	var which int
-out:
-	for {
-		which := int(rnd.Int31n(int32(len(r.puzzles))))
-		// See if which is one of real transactions
-		for _, valid := range realTxList {
-			if which == valid {
-				break out
-			}
-		}
+		return nil, fmt.Errorf("failed to decode puzzle key: %v", err)
 	}
 
-	pkey, err := puzzle.ParsePubKey(r.puzzleKey)
-	if err != nil {
-		return 0, nil, nil, fmt.Errorf("failed to decode puzzle "+
-			"key: %v", err)
+	secret := puzzle.UnblindPuzzle(&pkey, solution, pp.Factor)
+	if !puzzle.ValidatePuzzle(&pkey, pp.Origin, secret) {
+		return nil, errors.New("solution doesn't solve the puzzle")
 	}
 
-	puzzle, _, factor, err := puzzle.BlindPuzzle(&pkey, r.puzzles[which])
+	sig, err := puzzle.RevealSolution(pp.Promise, secret)
 	if err != nil {
-		return 0, nil, nil, err
+		return nil, fmt.Errorf("failed to decrypt the promise: %v", err)
+	}
+	if err = verifySignature(sig, txHash, pp.PublicKey); err != nil {
+		return nil, fmt.Errorf("signature didn't verify: %v", err)
 	}
-	return which, puzzle, factor, nil
+	return sig, nil
 }
 
 func verifySignature(sigBytes []byte, hash []byte, publicKey []byte) error {
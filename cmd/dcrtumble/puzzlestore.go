@@ -0,0 +1,233 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/decred/tumblebit/puzzle"
+)
+
+// Puzzles promised by the tumbler for the escrow of the payee may each be
+// handed to a payer only once: the tumbler would link the payments of a
+// puzzle solved twice to each other and to the payee. Puzzles are
+// therefore taken from a store that persists which of them have been
+// handed out and paid for before the puzzle leaves the client. Puzzles of
+// a promise set are handed out in the order of the promise chain linking
+// them, starting at a random puzzle, so that subsequent payments of a
+// channel are made with subsequent puzzles.
+
+// maxPuzzleSets limits the number of promise sets kept in the store, the
+// sets of the oldest epochs are forgotten first.
+const maxPuzzleSets = 1000
+
+var (
+	// errPuzzlesExhausted is returned when all puzzles of a promise set
+	// have been handed out.
+	errPuzzlesExhausted = errors.New("all puzzles of the promise set " +
+		"have been used")
+
+	// errPuzzleReused is returned when a payment is made with a puzzle
+	// that has already been paid for.
+	errPuzzleReused = errors.New("puzzle has already been paid for")
+)
+
+// puzzleSet records the use of puzzles promised for an escrow.
+type puzzleSet struct {
+	Epoch int32 `json:"epoch"`
+	// Order lists indices of the real puzzles of the promise set in the
+	// order they are handed out, the first Taken of which have been.
+	Order []int `json:"order"`
+	Taken int   `json:"taken"`
+	// Paid lists indices of the puzzles paid for.
+	Paid []int `json:"paid,omitempty"`
+}
+
+// puzzleStore persists the use of puzzles of promise sets in a JSON file
+// mapping escrow hashes to their sets.
+type puzzleStore struct {
+	path string
+
+	mu   sync.Mutex
+	sets map[string]*puzzleSet
+}
+
+// loadPuzzleStore reads the store from the file, which is created once
+// the first promise set is registered.
+func loadPuzzleStore(path string) (*puzzleStore, error) {
+	ps := &puzzleStore{
+		path: path,
+		sets: make(map[string]*puzzleSet),
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ps, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(b, &ps.sets); err != nil {
+		return nil, fmt.Errorf("failed to decode puzzle store: %v", err)
+	}
+	return ps, nil
+}
+
+// register records the promise set of the escrow made of the real puzzles
+// with the indices, listed in the order of the promise chain. The starting
+// puzzle is chosen with the source of randomness. Sets registered already
+// are kept as they are.
+func (ps *puzzleStore) register(escrowHash []byte, epoch int32, realTxList []int, random io.Reader) error {
+	if len(realTxList) == 0 {
+		return errors.New("promise set has no real puzzles")
+	}
+	var buf [8]byte
+	if _, err := io.ReadFull(random, buf[:]); err != nil {
+		return fmt.Errorf("failed to generate seed: %v", err)
+	}
+	start := int(binary.LittleEndian.Uint64(buf[:]) %
+		uint64(len(realTxList)))
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	key := hex.EncodeToString(escrowHash)
+	if _, ok := ps.sets[key]; ok {
+		return nil
+	}
+	order := make([]int, 0, len(realTxList))
+	order = append(order, realTxList[start:]...)
+	order = append(order, realTxList[:start]...)
+	ps.sets[key] = &puzzleSet{Epoch: epoch, Order: order}
+	ps.prune()
+	return ps.save()
+}
+
+// take hands out the next puzzle of the promise set of the escrow and
+// returns its index. The puzzle is recorded as taken before it's returned.
+func (ps *puzzleStore) take(escrowHash []byte) (int, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	set, ok := ps.sets[hex.EncodeToString(escrowHash)]
+	if !ok {
+		return 0, errors.New("unknown promise set")
+	}
+	if set.Taken >= len(set.Order) {
+		return 0, errPuzzlesExhausted
+	}
+	which := set.Order[set.Taken]
+	set.Taken++
+	if err := ps.save(); err != nil {
+		set.Taken--
+		return 0, err
+	}
+	return which, nil
+}
+
+// pay records the payment made for the puzzle of the promise set of the
+// escrow with the index. It fails with errPuzzleReused if the puzzle has
+// been paid for already and if it hasn't been handed out.
+func (ps *puzzleStore) pay(escrowHash []byte, which int) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	set, ok := ps.sets[hex.EncodeToString(escrowHash)]
+	if !ok {
+		return errors.New("unknown promise set")
+	}
+	for _, idx := range set.Paid {
+		if idx == which {
+			return errPuzzleReused
+		}
+	}
+	taken := false
+	for _, idx := range set.Order[:set.Taken] {
+		taken = taken || idx == which
+	}
+	if !taken {
+		return errors.New("puzzle hasn't been handed out")
+	}
+	set.Paid = append(set.Paid, which)
+	if err := ps.save(); err != nil {
+		set.Paid = set.Paid[:len(set.Paid)-1]
+		return err
+	}
+	return nil
+}
+
+// prune forgets the sets of the oldest epochs once too many have been
+// registered. It must be called with the mutex held.
+func (ps *puzzleStore) prune() {
+	if len(ps.sets) <= maxPuzzleSets {
+		return
+	}
+	keys := make([]string, 0, len(ps.sets))
+	for key := range ps.sets {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return ps.sets[keys[i]].Epoch < ps.sets[keys[j]].Epoch
+	})
+	for _, key := range keys[:len(keys)-maxPuzzleSets] {
+		delete(ps.sets, key)
+	}
+}
+
+// save writes the store to a temporary file and renames it over the store
+// file so that it isn't lost if writing fails midway. It must be called
+// with the mutex held.
+func (ps *puzzleStore) save() error {
+	b, err := json.MarshalIndent(ps.sets, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(ps.path), 0700); err != nil {
+		return err
+	}
+	tmp := ps.path + ".tmp"
+	if err = ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ps.path)
+}
+
+// takePuzzle registers the promise set obtained for the escrow and hands
+// out its next unused puzzle.
+func (tb *Tumbler) takePuzzle(escrowHash []byte, epoch int32, realTxList []byte, random io.Reader) (int, error) {
+	list, err := puzzle.DecodeIndexList(realTxList)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode tx index list: %v", err)
+	}
+	if err = tb.puzzles.register(escrowHash, epoch, list, random); err != nil {
+		return 0, err
+	}
+	return tb.puzzles.take(escrowHash)
+}
+
+// payPuzzle records the payment for the puzzle and refuses to pay for it
+// again.
+func (tb *Tumbler) payPuzzle(pp *PaymentPuzzle) error {
+	err := tb.puzzles.pay(pp.Contract.EscrowHash, pp.Index)
+	if err == errPuzzleReused {
+		return fmt.Errorf("Refusing to pay for puzzle %d of escrow %x "+
+			"again, it would link the payments", pp.Index,
+			pp.Contract.EscrowHash)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to record the payment for the "+
+			"puzzle: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math"
+
+	"github.com/decred/tumblebit/contract"
+	"github.com/decred/tumblebit/wallet"
+)
+
+// RefundOffers publishes refund transactions of payment offers recorded
+// in the journal once their locktime has passed, unless their escrows have
+// already been spent. Only contracts identified by the hex encoded escrow
+// transaction hashes are considered if any are specified.
+func RefundOffers(ctx context.Context, w *wallet.Wallet, journal *contract.Journal, hashes []string) error {
+	var contracts []*contract.Contract
+	if len(hashes) == 0 {
+		all, err := journal.List()
+		if err != nil {
+			return fmt.Errorf("Failed to list contracts: %v", err)
+		}
+		for _, con := range all {
+			if len(con.RefundBytes) > 0 {
+				contracts = append(contracts, con)
+			}
+		}
+	}
+	for _, h := range hashes {
+		escrowHash, err := hex.DecodeString(h)
+		if err != nil {
+			return fmt.Errorf("Invalid escrow hash %q: %v", h, err)
+		}
+		con, err := journal.Load(escrowHash)
+		if err != nil {
+			return fmt.Errorf("Failed to load contract %s: %v", h, err)
+		}
+		if len(con.RefundBytes) == 0 {
+			return fmt.Errorf("Contract %s can't be refunded", h)
+		}
+		contracts = append(contracts, con)
+	}
+
+	height, err := w.CurrentBlockHeight(ctx)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, con := range contracts {
+		if err = refundOffer(ctx, w, journal, con, height); err != nil {
+			fmt.Printf("Escrow %x: %v\n", con.EscrowHash, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("Failed to refund %d of %d contracts", failed,
+			len(contracts))
+	}
+	return nil
+}
+
+// refundOffer publishes the refund transaction of the contract if its
+// escrow can be refunded at the specified block height.
+func refundOffer(ctx context.Context, w *wallet.Wallet, journal *contract.Journal, con *contract.Contract, height uint32) error {
+	refundHeight, err := w.RefundHeight(ctx, con)
+	if err != nil {
+		return err
+	}
+	if refundHeight == math.MaxUint32 {
+		fmt.Printf("Escrow %x hasn't been mined yet\n", con.EscrowHash)
+		return nil
+	}
+	if height < refundHeight {
+		fmt.Printf("Escrow %x is locked until block %d\n", con.EscrowHash,
+			refundHeight)
+		return nil
+	}
+
+	spent, err := w.EscrowSpent(ctx, con)
+	if err != nil {
+		return err
+	}
+	if spent {
+		fmt.Printf("Escrow %x has already been spent\n", con.EscrowHash)
+		return nil
+	}
+
+	if err = w.PublishRefund(ctx, con); err != nil {
+		return err
+	}
+	if err = journal.Save(con); err != nil {
+		return fmt.Errorf("Failed to save the contract: %v", err)
+	}
+	fmt.Printf("Escrow %x refunded with tx %x\n", con.EscrowHash,
+		con.RefundHash)
+	return nil
+}
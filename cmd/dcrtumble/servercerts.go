@@ -0,0 +1,88 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	pb "github.com/decred/tumblebit/rpc/tumblerrpc"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// updateServerCerts fetches the certificate presented by the tumbler along
+// with the one it presents after its next rotation and pins both in the
+// certificate file, so that the client keeps connecting once the tumbler
+// rotates its certificate. The certificates are trusted since they are
+// served over a connection authenticated with the pinned ones, and only
+// accepted if they include the certificate presented on the connection.
+func (tb *Tumbler) updateServerCerts(ctx context.Context, path string) error {
+	var p peer.Peer
+	resp, err := tb.c.GetServerCerts(ctx, &pb.GetServerCertsRequest{},
+		grpc.Peer(&p))
+	if st, ok := status.FromError(err); ok && st.Code() == codes.Unimplemented {
+		// The tumbler doesn't rotate its certificate.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.PeerCertificates) == 0 {
+		return errors.New("connection is not authenticated")
+	}
+	presented := tlsInfo.State.PeerCertificates[0].Raw
+	var found bool
+	for rest := resp.Certs; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return fmt.Errorf("unexpected %s PEM block", block.Type)
+		}
+		if _, err = x509.ParseCertificate(block.Bytes); err != nil {
+			return err
+		}
+		found = found || bytes.Equal(block.Bytes, presented)
+	}
+	if !found {
+		return errors.New("tumbler served certificates other than " +
+			"the presented one")
+	}
+
+	pinned, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(pinned, resp.Certs) {
+		return nil
+	}
+	tmp := path + ".tmp"
+	if err = ioutil.WriteFile(tmp, resp.Certs, 0600); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return err
+	}
+	log.Printf("Pinned rotated certificates of the tumbler in %s, next "+
+		"rotation at %v", path, time.Unix(resp.RotationTime, 0))
+	return nil
+}
@@ -9,50 +9,70 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
+	"time"
 
-	"github.com/decred/dcrd/dcrutil"
+	"github.com/decred/dcrd/chaincfg/chainhash"
 	"github.com/decred/tumblebit/contract"
 	"github.com/decred/tumblebit/wallet"
 )
 
 type PaymentPuzzle struct {
-	Contract *contract.Contract
-	Amount   int64
-	Epoch    int32
-	Puzzle   []byte
-	Key      []byte
-	Factor   []byte
-	Origin   []byte
+	Contract  *contract.Contract
+	Amount    contract.Amount
+	Epoch     int32
+	Puzzle    []byte
+	Key       []byte
+	Factor    []byte
+	Origin    []byte
+	Promise   []byte
+	PublicKey []byte
+
+	// Index of the puzzle in the promise set.
+	Index int
 }
 
 type PuzzleSolution struct {
 	Contract *contract.Contract
 	Solution []byte
+
+	challenge *puzzleSolverChallenge
+	response  *puzzleSolverResponse
 }
 
 func (tb *Tumbler) NewEscrow(ctx context.Context, w *wallet.Wallet) (*PaymentPuzzle, error) {
-	// XXX
-	var amount int64 = dcrutil.AtomsPerCoin
+	tb.progress.setPhase(phaseEscrow, "request the cash-out promise")
 
-	recvAddr, recvPubKey, err := w.GetExtAddress(ctx)
+	amount := contract.Denomination
+
+	id, err := tb.newPayeeIdentity(ctx, w)
 	if err != nil {
-		fmt.Errorf("Failed to obtain an address for escrow: %v", err)
+		return nil, err
 	}
+	recvAddr, recvPubKey := id.address, id.publicKey
 
 	escrow, err := tb.SetupEscrow(ctx, &EscrowRequest{
-		Address:   recvAddr,
-		PublicKey: recvPubKey,
-		Amount:    amount,
+		Address:           recvAddr,
+		PublicKey:         recvPubKey,
+		Amount:            amount.Atoms(),
+		AddressCommitment: id.commitment,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("Failed to establish an escrow: %v", err)
 	}
+	tb.progress.setEpoch(escrow.Epoch)
+
+	if escrow.LockTime != tb.lockTime(escrow.Epoch) {
+		return nil, fmt.Errorf("Unexpected escrow lock time %d",
+			escrow.LockTime)
+	}
 
 	con, err := contract.New(tb.chainParams, amount,
-		escrow.Epoch+EpochDuration)
+		tb.lockTime(escrow.Epoch))
 	if err != nil {
 		return nil, fmt.Errorf("Failed to setup an escrow contract: %v", err)
 	}
+	con.LockType = tb.lockType
 
 	err = con.SetAddress(contract.ReceiverAddress, recvAddr, recvPubKey)
 	if err != nil {
@@ -65,9 +85,17 @@ func (tb *Tumbler) NewEscrow(ctx context.Context, w *wallet.Wallet) (*PaymentPuz
 		return nil, fmt.Errorf("Bad sender address: %v", err)
 	}
 
-	con.EscrowBytes = escrow.EscrowTransaction
-	con.EscrowScript = escrow.EscrowScript
+	// Make sure the tumbler has actually escrowed funds for us before
+	// engaging in the Puzzle-Promise protocol.
+	err = con.DecodeAndValidateEscrow(escrow.EscrowTransaction,
+		escrow.EscrowScript)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid escrow: %v", err)
+	}
 
+	if err = id.setPayout(con); err != nil {
+		return nil, fmt.Errorf("Bad pay-out address: %v", err)
+	}
 	if err = w.CreateRedeem(ctx, con); err != nil {
 		return nil, fmt.Errorf("Failed to create redeeming tx: %v", err)
 	}
@@ -79,12 +107,20 @@ func (tb *Tumbler) NewEscrow(ctx context.Context, w *wallet.Wallet) (*PaymentPuz
 		}
 	}
 
-	challenge, err := createPuzzlePromiseChallenge(txHashes)
+	random, err := tb.entropy.reader(purposePuzzlePromise, con.EscrowHash)
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := createPuzzlePromiseChallenge(random, txHashes)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to create a puzzle-promise "+
 			"challenge: %v", err)
 	}
 
+	tb.progress.setPhase(phasePuzzlePromise, "request solution promises")
+	if err = tb.pausePhase(ctx); err != nil {
+		return nil, err
+	}
 	promise, err := tb.GetPuzzlePromises(ctx, &SignatureChallenges{
 		Cookie:            escrow.Cookie,
 		FakeSetHash:       challenge.fakeSetHash,
@@ -104,13 +140,22 @@ func (tb *Tumbler) NewEscrow(ctx context.Context, w *wallet.Wallet) (*PaymentPuz
 			" promises")
 	}
 
+	if err = tb.pausePhase(ctx); err != nil {
+		return nil, err
+	}
 	secrets, err := tb.FinalizeEscrow(ctx, &TransactionDisclosure{
-		Cookie:     escrow.Cookie,
-		FakeTxList: challenge.fakeTxList,
-		RealTxList: challenge.realTxList,
-		RandomPads: challenge.randomPads,
-		Salt:       challenge.salt,
+		Cookie:        escrow.Cookie,
+		FakeTxList:    challenge.fakeTxList,
+		RealTxList:    challenge.realTxList,
+		RandomPads:    challenge.randomPads,
+		Salt:          challenge.salt,
+		PayoutAddress: id.payout,
+		PayoutNonce:   id.nonce,
 	})
+	if de, ok := err.(*disclosureError); ok {
+		tb.recordValidationFailure(con.EscrowHash, challenge, id,
+			de.failure)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("Failed to finalize an escrow: %v", err)
 	}
@@ -124,31 +169,91 @@ func (tb *Tumbler) NewEscrow(ctx context.Context, w *wallet.Wallet) (*PaymentPuz
 		publicKey: promise.PublicKey,
 	}
 
-	if err = validatePuzzlePromiseResponse(challenge, response); err != nil {
+	err = validatePuzzlePromiseResponse(ctx, tb.parallelism, challenge,
+		response)
+	if err != nil {
 		return nil, fmt.Errorf("Failed to validate puzzle-promise "+
 			"challenge response: %v", err)
 	}
+	err = tb.verifyPuzzleKey(ctx, escrow.Epoch, promise.PuzzleKey)
+	if err != nil {
+		return nil, err
+	}
 
 	// XXX: Make sure secrets.EscrowHash gets at least 2 confirmations
 
-	which, puzzle, factor, err := createClientPuzzle(challenge, response)
+	random, err = tb.entropy.reader(purposeClientPuzzle, con.EscrowHash)
+	if err != nil {
+		return nil, err
+	}
+	which, err := tb.takePuzzle(con.EscrowHash, escrow.Epoch,
+		challenge.realTxList, random)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to select a puzzle for a "+
+			"client: %v", err)
+	}
+	puzzle, factor, err := createClientPuzzle(random, which, response)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to create a puzzle for a "+
 			"client: %v", err)
 	}
 
+	tb.saveContract(con)
+	tb.recordTranscript(con.EscrowHash, transcriptEscrow, &escrowTranscript{
+		Epoch:           escrow.Epoch,
+		Amount:          amount.Atoms(),
+		LockTime:        escrow.LockTime,
+		RelativeLock:    tb.lockType == contract.RelativeLock,
+		ReceiverAddress: recvAddr,
+		ReceiverPubKey:  recvPubKey,
+		SenderAddress:   escrow.Address,
+		SenderPubKey:    escrow.PublicKey,
+		EscrowScript:    escrow.EscrowScript,
+		EscrowTx:        escrow.EscrowTransaction,
+	})
+	tb.recordTranscript(con.EscrowHash, transcriptPromise, &promiseTranscript{
+		RedeemTx:    con.RedeemBytes,
+		TxHashes:    hexList(challenge.txHashes),
+		Salt:        challenge.salt,
+		RandomPads:  hexList(challenge.randomPads),
+		RealTxList:  challenge.realTxList,
+		FakeTxList:  challenge.fakeTxList,
+		RealSetHash: challenge.realSetHash,
+		FakeSetHash: challenge.fakeSetHash,
+		Puzzles:     hexList(response.puzzles),
+		Promises:    hexList(response.promises),
+		Quotients:   hexList(response.quotients),
+		Secrets:     hexList(response.secrets),
+		PuzzleKey:   response.puzzleKey,
+		PublicKey:   response.publicKey,
+	})
+
 	return &PaymentPuzzle{
-		Contract: con,
-		Amount:   amount,
-		Epoch:    escrow.Epoch,
-		Puzzle:   puzzle,
-		Key:      promise.PuzzleKey,
-		Factor:   factor,
-		Origin:   promise.Puzzles[which],
+		Contract:  con,
+		Amount:    amount,
+		Epoch:     escrow.Epoch,
+		Puzzle:    puzzle,
+		Key:       promise.PuzzleKey,
+		Factor:    factor,
+		Origin:    promise.Puzzles[which],
+		Promise:   promise.Promises[which],
+		PublicKey: promise.PublicKey,
+		Index:     which,
 	}, nil
 }
 
 func (tb *Tumbler) MakePayment(ctx context.Context, w *wallet.Wallet, pp *PaymentPuzzle) (*PuzzleSolution, error) {
+	// The payer must be served the key the payee has obtained its
+	// puzzle with.
+	if err := tb.verifyPuzzleKey(ctx, pp.Epoch, pp.Key); err != nil {
+		return nil, err
+	}
+	// Every puzzle of a promise set may only be paid for once.
+	if err := tb.payPuzzle(pp); err != nil {
+		return nil, err
+	}
+	tb.progress.setPhase(phaseSolutionPromise, "publish the payment offer")
+
 	sendAddr, sendPubKey, err := w.GetExtAddress(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to obtain an address for an "+
@@ -156,12 +261,21 @@ func (tb *Tumbler) MakePayment(ctx context.Context, w *wallet.Wallet, pp *Paymen
 	}
 
 	// Create puzzles to obtain the purchase promises
-	challenge, err := createPuzzleSolverChallenge(pp.Puzzle, pp.Key)
+	random, err := tb.entropy.reader(purposePuzzleSolver,
+		chainhash.HashB(pp.Puzzle))
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := createPuzzleSolverChallenge(random, pp.Puzzle, pp.Key)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to create a puzzle-solver "+
 			"challenge: %v", err)
 	}
 
+	hashLock := tb.hashLock
+	if err = tb.pausePhase(ctx); err != nil {
+		return nil, err
+	}
 	promise, err := tb.GetSolutionPromises(ctx, &SolutionChallenges{
 		Address: sendAddr,
 		Epoch:   pp.Epoch,
@@ -180,6 +294,9 @@ func (tb *Tumbler) MakePayment(ctx context.Context, w *wallet.Wallet, pp *Paymen
 			"hashes")
 	}
 
+	if err = tb.pausePhase(ctx); err != nil {
+		return nil, err
+	}
 	secrets, err := tb.ValidateSolutions(ctx, &PuzzleDisclosure{
 		Cookie:         promise.Cookie,
 		FakePuzzleList: challenge.fakePuzzleList,
@@ -199,6 +316,7 @@ func (tb *Tumbler) MakePayment(ctx context.Context, w *wallet.Wallet, pp *Paymen
 		promises:  promise.Promises,
 		keyHashes: promise.KeyHashes,
 		secrets:   secrets.Secrets,
+		hashLock:  hashLock,
 	}
 
 	err = validatePuzzleSolverResponse(challenge, response)
@@ -213,12 +331,20 @@ func (tb *Tumbler) MakePayment(ctx context.Context, w *wallet.Wallet, pp *Paymen
 			"preimage challenges: %v", err)
 	}
 
-	con, err := contract.New(tb.chainParams, pp.Amount,
-		pp.Epoch+EpochDuration)
+	tb.progress.setPhase(phaseOffer, "await the solution")
+	if err = tb.pausePhase(ctx); err != nil {
+		return nil, err
+	}
+
+	// The offer pays the tumbler's commission on top of the amount.
+	con, err := contract.NewOffer(tb.chainParams, tb.fee,
+		tb.lockTime(pp.Epoch))
 	if err != nil {
 		return nil, fmt.Errorf("Failed to setup an escrow contract: %v",
 			err)
 	}
+	con.LockType = tb.lockType
+	con.HashLock = hashLock
 
 	err = con.SetAddress(contract.SenderAddress, sendAddr, sendPubKey)
 	if err != nil {
@@ -241,10 +367,17 @@ func (tb *Tumbler) MakePayment(ctx context.Context, w *wallet.Wallet, pp *Paymen
 	if err = w.PublishEscrow(ctx, con); err != nil {
 		return nil, fmt.Errorf("Failed to publish an escrow tx: %v", err)
 	}
+	tb.saveContract(con)
 
-	if err = tb.PaymentOffer(ctx, &PaymentOffer{
+	retry := &offerRetry{
+		w:        w,
+		con:      con,
+		deadline: time.Now().Add(tb.offerDeadline),
+		interval: offerRetryInterval,
+	}
+	_, err = tb.PaymentOffer(ctx, &PaymentOffer{
 		Cookie:            promise.Cookie,
-		Amount:            pp.Amount,
+		Amount:            con.Amount.Atoms(),
 		PublicKey:         sendPubKey,
 		EscrowHash:        con.EscrowHash,
 		EscrowScript:      con.EscrowScript,
@@ -252,19 +385,61 @@ func (tb *Tumbler) MakePayment(ctx context.Context, w *wallet.Wallet, pp *Paymen
 		Puzzle:            pp.Puzzle,
 		RealPuzzleList:    challenge.realPuzzleList,
 		RandomFactors:     challenge.realFactors,
-	}); err != nil {
+	}, retry.wait)
+	if err == errOfferDeadline {
+		log.Printf("Abandoning the payment offer: %v", err)
+		if err := tb.refundAbandonedOffer(ctx, w, con); err != nil {
+			return nil, fmt.Errorf("Failed to refund the abandoned "+
+				"offer: %v", err)
+		}
+		return nil, err
+	}
+	if err != nil {
 		return nil, fmt.Errorf("Failed to commit purchase: %v", err)
 	}
+	tb.recordTranscript(pp.Contract.EscrowHash, transcriptOffer,
+		&offerTranscript{
+			EscrowHash: con.EscrowHash,
+			Amount:     con.Amount.Atoms(),
+			LockTime:   con.LockTime,
+		})
 
 	return &PuzzleSolution{
-		Contract: con,
-		Solution: nil,
+		Contract:  con,
+		challenge: challenge,
+		response:  response,
 	}, nil
 }
 
+// RedeemEscrow recovers the tumbler's signature promised for the cash-out
+// transaction using the solution of the puzzle obtained from the payer and
+// publishes the transaction redeeming the escrow.
 func (tb *Tumbler) RedeemEscrow(ctx context.Context, w *wallet.Wallet, pp *PaymentPuzzle, sol *PuzzleSolution) error {
-	if err := w.PublishRedeem(ctx, pp.Contract, nil); err != nil {
+	if sol == nil || len(sol.Solution) == 0 {
+		return errors.New("Puzzle solution is not available")
+	}
+	tb.progress.setPhase(phaseRedeem, "")
+	if err := tb.pausePhase(ctx); err != nil {
+		return err
+	}
+
+	txHash, err := redeemTxHash(pp.Contract)
+	if err != nil {
+		return fmt.Errorf("Failed to hash redeeming tx: %v", err)
+	}
+
+	sig, err := recoverPromisedSignature(pp, sol.Solution, txHash)
+	if err != nil {
+		return fmt.Errorf("Failed to recover the cash-out signature: %v",
+			err)
+	}
+
+	if err = w.PublishRedeem(ctx, pp.Contract, sig); err != nil {
 		return fmt.Errorf("Failed to publish redeeming tx: %v", err)
 	}
+	tb.saveContract(pp.Contract)
+	tb.recordTranscript(pp.Contract.EscrowHash, transcriptRedeem,
+		&redeemTranscript{RedeemHash: pp.Contract.RedeemHash})
+	tb.progress.setPhase(phaseDone, "")
 	return nil
 }
@@ -0,0 +1,97 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"github.com/decred/tumblebit/contract"
+	"github.com/decred/tumblebit/wallet"
+)
+
+// payoutNonceSize is the size of the nonce blinding the commitment to the
+// pay-out address.
+const payoutNonceSize = 32
+
+// payeeIdentity identifies the payee to the tumbler when the escrow is set
+// up. By default the payee is identified by an address of the wallet. With
+// session keys enabled it's identified by a fresh key of the session and
+// merely commits to the pay-out address, which is revealed when the escrow
+// is finalized.
+type payeeIdentity struct {
+	address   string
+	publicKey string
+
+	// Pay-out address committed to, the nonce blinding the commitment
+	// and the commitment itself. Unset unless session keys are used.
+	payout       string
+	payoutPubKey string
+	nonce        []byte
+	commitment   []byte
+}
+
+// payoutCommitment returns the blinded hash commitment to the pay-out
+// address, SHA-256 of the nonce followed by the address.
+func payoutCommitment(nonce []byte, address string) []byte {
+	h := sha256.New()
+	h.Write(nonce)
+	h.Write([]byte(address))
+	return h.Sum(nil)
+}
+
+// newPayeeIdentity returns the identity of the payee in a new exchange.
+// The session key is derived from the seed and the pay-out address in the
+// deterministic mode, so that it can be regenerated.
+func (tb *Tumbler) newPayeeIdentity(ctx context.Context, w *wallet.Wallet) (*payeeIdentity, error) {
+	if !tb.sessionKeys {
+		addr, pubKey, err := w.GetExtAddress(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to obtain an address for "+
+				"escrow: %v", err)
+		}
+		return &payeeIdentity{address: addr, publicKey: pubKey}, nil
+	}
+
+	payout, payoutPubKey, err := w.GetPayoutAddress(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to obtain a pay-out address: %v",
+			err)
+	}
+	random, err := tb.entropy.reader(purposeSessionKey, []byte(payout))
+	if err != nil {
+		return nil, err
+	}
+	key, err := wallet.NewSessionKey(random, tb.chainParams)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, payoutNonceSize)
+	if _, err = io.ReadFull(random, nonce); err != nil {
+		return nil, fmt.Errorf("Failed to generate a nonce: %v", err)
+	}
+	w.AddSessionKey(key)
+
+	return &payeeIdentity{
+		address:      key.Address,
+		publicKey:    key.PublicKey,
+		payout:       payout,
+		payoutPubKey: payoutPubKey,
+		nonce:        nonce,
+		commitment:   payoutCommitment(nonce, payout),
+	}, nil
+}
+
+// setPayout sets the committed pay-out address as the address receiving
+// the redeemed escrow. The wallet picks one otherwise.
+func (id *payeeIdentity) setPayout(con *contract.Contract) error {
+	if id.payout == "" {
+		return nil
+	}
+	return con.SetAddress(contract.RedeemAddress, id.payout,
+		id.payoutPubKey)
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	pb "github.com/decred/tumblebit/rpc/tumblerrpc"
+)
+
+// streamChunkSize is the number of puzzles uploaded in a single message of
+// a stream.
+const streamChunkSize = 32
+
+// maxStreamedValues limits the number of puzzles or promises accepted from
+// a single stream.
+const maxStreamedValues = 4096
+
+var errStreamTooLong = errors.New("tumbler streamed too many values")
+
+// streamPuzzlePromises requests the puzzle promises with the streaming
+// variant of GetPuzzlePromises and joins the streamed chunks.
+func (tb *Tumbler) streamPuzzlePromises(ctx context.Context, req *pb.GetPuzzlePromisesRequest) (*pb.GetPuzzlePromisesResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := tb.c.StreamPuzzlePromises(ctx, req, tb.callOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	var resp *pb.GetPuzzlePromisesResponse
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if resp == nil {
+			resp = msg
+			continue
+		}
+		if len(resp.Puzzles)+len(msg.Puzzles) > maxStreamedValues ||
+			len(resp.Promises)+len(msg.Promises) > maxStreamedValues {
+			return nil, errStreamTooLong
+		}
+		resp.Puzzles = append(resp.Puzzles, msg.Puzzles...)
+		resp.Promises = append(resp.Promises, msg.Promises...)
+	}
+	if resp == nil {
+		return nil, errors.New("tumbler streamed no promises")
+	}
+	return resp, nil
+}
+
+// streamSolutionPromises uploads the puzzles in chunks with the streaming
+// variant of GetSolutionPromises and joins the streamed promises.
+func (tb *Tumbler) streamSolutionPromises(ctx context.Context, req *pb.GetSolutionPromisesRequest) (*pb.GetSolutionPromisesResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := tb.c.StreamSolutionPromises(ctx, tb.callOptions()...)
+	if err != nil {
+		return nil, err
+	}
+
+	// The first message carries all fields of the request.
+	first := *req
+	puzzles := req.Puzzles
+	if len(puzzles) > streamChunkSize {
+		first.Puzzles = puzzles[:streamChunkSize]
+	}
+	puzzles = puzzles[len(first.Puzzles):]
+	if err = stream.Send(&first); err != nil {
+		return nil, err
+	}
+	for len(puzzles) > 0 {
+		n := len(puzzles)
+		if n > streamChunkSize {
+			n = streamChunkSize
+		}
+		msg := &pb.GetSolutionPromisesRequest{Puzzles: puzzles[:n]}
+		if err = stream.Send(msg); err != nil {
+			return nil, err
+		}
+		puzzles = puzzles[n:]
+	}
+	if err = stream.CloseSend(); err != nil {
+		return nil, err
+	}
+
+	var resp *pb.GetSolutionPromisesResponse
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if resp == nil {
+			resp = msg
+			continue
+		}
+		if len(resp.Promises)+len(msg.Promises) > maxStreamedValues ||
+			len(resp.KeyHashes)+len(msg.KeyHashes) > maxStreamedValues {
+			return nil, errStreamTooLong
+		}
+		resp.Promises = append(resp.Promises, msg.Promises...)
+		resp.KeyHashes = append(resp.KeyHashes, msg.KeyHashes...)
+	}
+	if resp == nil {
+		return nil, errors.New("tumbler streamed no promises")
+	}
+	return resp, nil
+}
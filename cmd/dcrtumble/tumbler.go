@@ -8,49 +8,400 @@ package main
 import (
 	"context"
 	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrd/dcrutil"
+	"github.com/decred/tumblebit/contract"
+	"github.com/decred/tumblebit/puzzle"
 	pb "github.com/decred/tumblebit/rpc/tumblerrpc"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	_ "google.golang.org/grpc/encoding/gzip" // Registers the gzip compressor
+	"google.golang.org/grpc/status"
 )
 
+// requiredFeatures lists features of the tumbler the client can't work
+// without.
+var requiredFeatures = []string{
+	pb.FeatureCommission,
+	pb.FeaturePuzzleSchemes,
+}
+
+// optionalFeatures lists features of the tumbler the client uses when
+// they are available.
+var optionalFeatures = []string{
+	pb.FeatureSHA256HashLock,
+	pb.FeatureStreamingPromises,
+}
+
 type Tumbler struct {
 	c pb.TumblerServiceClient
+	v pb.VersionServiceClient
 
 	chainParams *chaincfg.Params
+	journal     *contract.Journal
+
+	// Commission charged by the tumbler for every payment.
+	fee contract.Amount
+
+	// Number of blocks funds stay escrowed within an epoch.
+	epochDuration int32
+
+	// Whether escrows are locked relative to their confirmation.
+	lockType contract.LockType
+
+	// Hash function payment offers lock funds with.
+	hashLock contract.HashLock
+
+	// Difficulty of the proof of work requests for solution promises
+	// starting new sessions must carry.
+	workBits int32
+
+	// Fingerprints of puzzle keys served by the tumbler and the key
+	// transparency log they are verified against, if any.
+	keyPins *keyPins
+	keyLog  *keyLog
+
+	// Puzzles of promise sets handed out and paid for.
+	puzzles *puzzleStore
+
+	// Source of randomness for protocol messages.
+	entropy *entropy
+
+	// Whether payees are identified by fresh keys of their sessions.
+	sessionKeys bool
+
+	// Maximum number of puzzles and signatures verified concurrently,
+	// one per CPU if it isn't positive.
+	parallelism int
+
+	// Whether promises are streamed, which is negotiated during the
+	// handshake unless streaming has been disabled.
+	streaming   bool
+	noStreaming bool
+
+	// Name of the compressor of calls exchanging puzzles and promises,
+	// or empty if they aren't compressed.
+	compressor string
+
+	// Time the tumbler has to accept a payment offer before it's
+	// abandoned and its escrow refunded.
+	offerDeadline time.Duration
+
+	// Number of blocks before the locktime of the escrow paying the
+	// payee at which a missing puzzle solution is alerted.
+	deadlineWarning uint32
+
+	// Time to wait for a lost connection to be re-established before
+	// requests of a session are failed.
+	redialTimeout time.Duration
+
+	// Bounds of the random delay before every phase of the exchange.
+	minPhaseDelay time.Duration
+	maxPhaseDelay time.Duration
+
+	// Display of the progress of the exchange, if any.
+	progress *progress
+
+	// Sequence number of the last request made in any session. A single
+	// counter keeps the numbers increasing within every session.
+	sequence uint64 // atomic
 }
 
 func NewTumblerClient(conn *grpc.ClientConn, chainParams *chaincfg.Params) (*Tumbler, error) {
 	tb := &Tumbler{
 		c:           pb.NewTumblerServiceClient(conn),
+		v:           pb.NewVersionServiceClient(conn),
 		chainParams: chainParams,
 	}
 
 	return tb, nil
 }
 
+// callOptions returns options of calls exchanging puzzles and promises,
+// which are compressed if a compressor is configured. Other calls carry
+// too little data to benefit from compression.
+func (tb *Tumbler) callOptions() []grpc.CallOption {
+	if tb.compressor == "" {
+		return nil
+	}
+	return []grpc.CallOption{grpc.UseCompressor(tb.compressor)}
+}
+
+// saveContract records the contract in the journal, if one is configured,
+// so that its transactions can be re-broadcast later.
+func (tb *Tumbler) saveContract(con *contract.Contract) {
+	if tb.journal == nil {
+		return
+	}
+	if err := tb.journal.Save(con); err != nil {
+		log.Printf("Failed to journal the contract: %v", err)
+	}
+}
+
+// nextSequence returns the sequence number of the next request protecting
+// it from being replayed by the network.
+func (tb *Tumbler) nextSequence() uint64 {
+	return atomic.AddUint64(&tb.sequence, 1)
+}
+
+// maxRedials limits the number of times a request of a session is retried
+// after the connection to the tumbler was lost.
+const maxRedials = 3
+
+// resumable calls fn with the sequence number of a new request of the
+// session identified by the cookie. If the connection to the tumbler is
+// lost before the tumbler has accepted the request, the session is resumed
+// once the connection is re-established and fn is called again. Requests
+// outside of sessions are numbered 0 and are not retried.
+func (tb *Tumbler) resumable(ctx context.Context, cookie []byte, fn func(seq uint64) error) error {
+	if len(cookie) == 0 {
+		return fn(0)
+	}
+	for attempt := 0; ; attempt++ {
+		seq := tb.nextSequence()
+		err := fn(seq)
+		if err == nil || attempt >= maxRedials || !connectionLost(err) {
+			return err
+		}
+
+		log.Printf("Lost the connection to the tumbler, resuming the "+
+			"session: %v", err)
+		st, rerr := tb.resumeSession(ctx, cookie)
+		if rerr != nil {
+			log.Printf("Failed to resume the session: %v", rerr)
+			return err
+		}
+		if st.Sequence >= seq {
+			// The tumbler has processed the request, but its
+			// response is lost.
+			return err
+		}
+	}
+}
+
+// resumeSession waits up to the redial timeout for the connection to the
+// tumbler to be re-established and resumes the session.
+func (tb *Tumbler) resumeSession(ctx context.Context, cookie []byte) (*SessionStatus, error) {
+	timeout := tb.redialTimeout
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return tb.ResumeSession(ctx, cookie, grpc.FailFast(false))
+}
+
+// connectionLost returns whether the request failed because the connection
+// to the tumbler was lost.
+func connectionLost(err error) bool {
+	st, ok := status.FromError(err)
+	return ok && st.Code() == codes.Unavailable
+}
+
+type TumblerInfo struct {
+	Epoch                int32
+	NextEpoch            int32
+	LockTime             int32
+	Denomination         int64
+	PuzzleKeyHash        []byte
+	PuzzleDifficulty     int32
+	FeePerKb             int64
+	EpochDuration        int32
+	EpochRenewal         int32
+	RealTransactionCount int32
+	FakeTransactionCount int32
+	RealPreimageCount    int32
+	FakePreimageCount    int32
+	PuzzleScheme         string
+	Fee                  int64
+	RelativeLockTime     bool
+	PuzzleSecurity       int32
+	PuzzlePrimes         int32
+	WorkBits             int32
+}
+
+func (tb *Tumbler) GetTumblerInfo(ctx context.Context) (*TumblerInfo, error) {
+	tir, err := tb.c.GetTumblerInfo(ctx, &pb.GetTumblerInfoRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("GetTumblerInfo %v", err)
+	}
+	return (*TumblerInfo)(tir), nil
+}
+
+// Handshake negotiates the protocol version and features with the tumbler.
+// Incompatibilities reported by the tumbler are translated into errors
+// describing what the client and the tumbler disagree on.
+func (tb *Tumbler) Handshake(ctx context.Context) error {
+	resp, err := tb.v.Handshake(ctx, &pb.HandshakeRequest{
+		ProtocolVersion:  pb.ProtocolVersion,
+		Features:         append(requiredFeatures, optionalFeatures...),
+		RequiredFeatures: requiredFeatures,
+	})
+	if err == nil {
+		tb.hashLock = contract.HashLockRIPEMD160
+		tb.streaming = false
+		for _, f := range resp.Features {
+			switch f {
+			case pb.FeatureSHA256HashLock:
+				tb.hashLock = contract.HashLockSHA256
+			case pb.FeatureStreamingPromises:
+				tb.streaming = !tb.noStreaming
+			}
+		}
+		return nil
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return fmt.Errorf("Handshake %v", err)
+	}
+	switch st.Code() {
+	case codes.Unimplemented:
+		return fmt.Errorf("Tumbler doesn't support protocol version "+
+			"negotiation and likely runs a protocol older than "+
+			"version %d", pb.ProtocolVersion)
+	case codes.FailedPrecondition:
+		for _, d := range st.Details() {
+			id, ok := d.(*pb.IncompatibilityDetail)
+			if !ok {
+				continue
+			}
+			if pb.ProtocolVersion < id.MinProtocolVersion ||
+				pb.ProtocolVersion > id.MaxProtocolVersion {
+				return fmt.Errorf("Protocol version %d isn't "+
+					"supported, the tumbler supports versions "+
+					"%d to %d", pb.ProtocolVersion,
+					id.MinProtocolVersion, id.MaxProtocolVersion)
+			}
+			return fmt.Errorf("Tumbler lacks required features: %s",
+				strings.Join(id.MissingFeatures, ", "))
+		}
+	}
+	return fmt.Errorf("Handshake %v", err)
+}
+
+// CheckCompatibility makes sure the tumbler runs the protocol with the
+// same parameters as the client and records the commission it charges.
+func (tb *Tumbler) CheckCompatibility(ctx context.Context) (*TumblerInfo, error) {
+	if err := tb.Handshake(ctx); err != nil {
+		return nil, err
+	}
+	info, err := tb.GetTumblerInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case info.Denomination != contract.Denomination.Atoms():
+		return nil, fmt.Errorf("Unsupported denomination %v",
+			dcrutil.Amount(info.Denomination))
+	case info.RealTransactionCount != int32(RealTransactionCount),
+		info.FakeTransactionCount != int32(FakeTransactionCount):
+		return nil, fmt.Errorf("Mismatched transaction counts: %d/%d",
+			info.RealTransactionCount, info.FakeTransactionCount)
+	case info.RealPreimageCount != int32(RealPreimageCount),
+		info.FakePreimageCount != int32(FakePreimageCount):
+		return nil, fmt.Errorf("Mismatched preimage counts: %d/%d",
+			info.RealPreimageCount, info.FakePreimageCount)
+	case info.Fee < 0 || info.Fee >= info.Denomination:
+		return nil, fmt.Errorf("Unreasonable fee %v",
+			dcrutil.Amount(info.Fee))
+	case info.EpochDuration <= 0:
+		return nil, fmt.Errorf("Invalid epoch duration %d",
+			info.EpochDuration)
+	case info.PuzzleDifficulty < puzzle.MinDifficulty:
+		return nil, fmt.Errorf("Insecure puzzle difficulty %d",
+			info.PuzzleDifficulty)
+	case info.WorkBits < 0 || info.WorkBits > puzzle.MaxWorkBits:
+		return nil, fmt.Errorf("Unreasonable proof of work difficulty "+
+			"%d", info.WorkBits)
+	}
+	// Puzzle schemes differ only in the way the tumbler constructs
+	// puzzles, clients merely need to recognize them.
+	if info.PuzzleScheme != "" {
+		if _, err := puzzle.LookupScheme(info.PuzzleScheme); err != nil {
+			return nil, err
+		}
+	}
+	if err = tb.verifyKeyHash(ctx, info.Epoch, info.PuzzleKeyHash); err != nil {
+		return nil, err
+	}
+	tb.fee = contract.Amount(info.Fee)
+	tb.epochDuration = info.EpochDuration
+	tb.workBits = info.WorkBits
+	tb.lockType = contract.AbsoluteLock
+	if info.RelativeLockTime {
+		tb.lockType = contract.RelativeLock
+	}
+	return info, nil
+}
+
+// lockTime returns the block height after which escrows of contracts
+// made in the epoch may be refunded, or the number of confirmations
+// escrows need before they may be refunded if the tumbler uses relative
+// locktimes.
+func (tb *Tumbler) lockTime(epoch int32) int32 {
+	if tb.lockType == contract.RelativeLock {
+		return tb.epochDuration
+	}
+	return epoch + tb.epochDuration
+}
+
 type EscrowRequest struct {
-	Address   string
-	PublicKey string
-	Amount    int64
+	Address              string
+	PublicKey            string
+	Amount               int64
+	RealTransactionCount int32
+	FakeTransactionCount int32
+	ProtocolVersion      uint32
+	AddressCommitment    []byte
+	NextEpoch            bool
 }
 
 type EscrowOffer struct {
-	Cookie            []byte
-	Epoch             int32
-	LockTime          int32
-	Address           string
-	PublicKey         string
-	EscrowScript      []byte
-	EscrowTransaction []byte
+	Cookie               []byte
+	Epoch                int32
+	LockTime             int32
+	Address              string
+	PublicKey            string
+	EscrowScript         []byte
+	EscrowTransaction    []byte
+	RealTransactionCount int32
+	FakeTransactionCount int32
 }
 
+// SetupEscrow requests an escrow of the tumbler. Escrows are bound to the
+// next epoch if it's about to be created, which leaves the payer the most
+// time to pay for the puzzle. The request is retried once if the tumbler
+// asks to, e.g. until the next epoch has been created.
 func (tb *Tumbler) SetupEscrow(ctx context.Context, er *EscrowRequest) (*EscrowOffer, error) {
+	er.RealTransactionCount = int32(RealTransactionCount)
+	er.FakeTransactionCount = int32(FakeTransactionCount)
+	er.ProtocolVersion = pb.ProtocolVersion
+	er.NextEpoch = true
 	ber, err := tb.c.SetupEscrow(ctx, (*pb.SetupEscrowRequest)(er))
+	if delay, ok := retryAfter(err); err != nil && ok {
+		log.Printf("Retrying SetupEscrow in %v: %v", delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		ber, err = tb.c.SetupEscrow(ctx, (*pb.SetupEscrowRequest)(er))
+	}
 	if err != nil {
 		return nil, fmt.Errorf("SetupEscrow %v", err)
 	}
+	if ber.RealTransactionCount != int32(RealTransactionCount) ||
+		ber.FakeTransactionCount != int32(FakeTransactionCount) {
+		return nil, fmt.Errorf("Mismatched transaction counts: %d/%d",
+			ber.RealTransactionCount, ber.FakeTransactionCount)
+	}
 	return (*EscrowOffer)(ber), nil
 }
 
@@ -59,6 +410,7 @@ type SignatureChallenges struct {
 	FakeSetHash       []byte
 	RealSetHash       []byte
 	TransactionHashes [][]byte
+	Sequence          uint64
 }
 
 type SignaturePromises struct {
@@ -68,20 +420,69 @@ type SignaturePromises struct {
 	Promises  [][]byte
 }
 
+// GetPuzzlePromises requests the promises and retries while the tumbler
+// asks to, e.g. while it awaits signatures from its offline signer.
 func (tb *Tumbler) GetPuzzlePromises(ctx context.Context, sc *SignatureChallenges) (*SignaturePromises, error) {
-	ppr, err := tb.c.GetPuzzlePromises(ctx, (*pb.GetPuzzlePromisesRequest)(sc))
-	if err != nil {
-		return nil, fmt.Errorf("GetPuzzlePromises %v", err)
+	for {
+		var ppr *pb.GetPuzzlePromisesResponse
+		err := tb.resumable(ctx, sc.Cookie, func(seq uint64) (err error) {
+			sc.Sequence = seq
+			if tb.streaming {
+				ppr, err = tb.streamPuzzlePromises(ctx,
+					(*pb.GetPuzzlePromisesRequest)(sc))
+			} else {
+				ppr, err = tb.c.GetPuzzlePromises(ctx,
+					(*pb.GetPuzzlePromisesRequest)(sc),
+					tb.callOptions()...)
+			}
+			return err
+		})
+		if err == nil {
+			return (*SignaturePromises)(ppr), nil
+		}
+		delay, ok := retryAfter(err)
+		if !ok {
+			return nil, fmt.Errorf("GetPuzzlePromises %v", err)
+		}
+		log.Printf("Retrying GetPuzzlePromises in %v: %v", delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// retryAfter returns the delay after which the failed request may be
+// retried if the tumbler has classified the error as retryable.
+func retryAfter(err error) (time.Duration, bool) {
+	st, ok := status.FromError(err)
+	if !ok {
+		return 0, false
+	}
+	for _, d := range st.Details() {
+		ed, ok := d.(*pb.ErrorDetail)
+		if !ok || ed.Category != pb.ErrorCategory_RETRYABLE {
+			continue
+		}
+		delay := time.Duration(ed.RetryAfter) * time.Second
+		if delay <= 0 {
+			delay = time.Second
+		}
+		return delay, true
 	}
-	return (*SignaturePromises)(ppr), nil
+	return 0, false
 }
 
 type TransactionDisclosure struct {
-	Cookie     []byte
-	Salt       []byte
-	FakeTxList []byte
-	RealTxList []byte
-	RandomPads [][]byte
+	Cookie        []byte
+	Salt          []byte
+	FakeTxList    []byte
+	RealTxList    []byte
+	RandomPads    [][]byte
+	Sequence      uint64
+	PayoutAddress string
+	PayoutNonce   []byte
 }
 
 type SignatureSecrets struct {
@@ -91,30 +492,76 @@ type SignatureSecrets struct {
 }
 
 func (tb *Tumbler) FinalizeEscrow(ctx context.Context, cd *TransactionDisclosure) (*SignatureSecrets, error) {
-	fer, err := tb.c.FinalizeEscrow(ctx, (*pb.FinalizeEscrowRequest)(cd))
+	var fer *pb.FinalizeEscrowResponse
+	err := tb.resumable(ctx, cd.Cookie, func(seq uint64) (err error) {
+		cd.Sequence = seq
+		fer, err = tb.c.FinalizeEscrow(ctx,
+			(*pb.FinalizeEscrowRequest)(cd), tb.callOptions()...)
+		return err
+	})
 	if err != nil {
+		if f := validationFailure(err); f != nil {
+			return nil, &disclosureError{failure: f,
+				err: fmt.Errorf("FinalizeEscrow %v", err)}
+		}
 		return nil, fmt.Errorf("FinalizeEscrow %v", err)
 	}
 	return (*SignatureSecrets)(fer), nil
 }
 
 type SolutionChallenges struct {
-	Address string
-	Epoch   int32
-	Puzzles [][]byte
+	Address           string
+	Epoch             int32
+	Puzzles           [][]byte
+	Cookie            []byte
+	RealPreimageCount int32
+	FakePreimageCount int32
+	Sequence          uint64
+	HashLock          uint32
+	Work              []byte
 }
 
 type SolutionPromises struct {
-	Cookie    []byte
-	Promises  [][]byte
-	KeyHashes [][]byte
+	Cookie            []byte
+	Promises          [][]byte
+	KeyHashes         [][]byte
+	RealPreimageCount int32
+	FakePreimageCount int32
 }
 
 func (tb *Tumbler) GetSolutionPromises(ctx context.Context, pp *SolutionChallenges) (*SolutionPromises, error) {
-	spr, err := tb.c.GetSolutionPromises(ctx, (*pb.GetSolutionPromisesRequest)(pp))
+	pp.RealPreimageCount = int32(RealPreimageCount)
+	pp.FakePreimageCount = int32(FakePreimageCount)
+	pp.HashLock = uint32(tb.hashLock)
+	var spr *pb.GetSolutionPromisesResponse
+	err := tb.resumable(ctx, pp.Cookie, func(seq uint64) (err error) {
+		pp.Sequence = seq
+		// Proofs of work are accepted once, retries need a new one.
+		if len(pp.Cookie) == 0 && tb.workBits > 0 {
+			pp.Work, err = puzzle.SolveWork(pp.Epoch, pp.Puzzles,
+				int(tb.workBits))
+			if err != nil {
+				return err
+			}
+		}
+		if tb.streaming {
+			spr, err = tb.streamSolutionPromises(ctx,
+				(*pb.GetSolutionPromisesRequest)(pp))
+		} else {
+			spr, err = tb.c.GetSolutionPromises(ctx,
+				(*pb.GetSolutionPromisesRequest)(pp),
+				tb.callOptions()...)
+		}
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("GetSolutionPromises %v", err)
 	}
+	if spr.RealPreimageCount != int32(RealPreimageCount) ||
+		spr.FakePreimageCount != int32(FakePreimageCount) {
+		return nil, fmt.Errorf("Mismatched preimage counts: %d/%d",
+			spr.RealPreimageCount, spr.FakePreimageCount)
+	}
 	return (*SolutionPromises)(spr), nil
 }
 
@@ -122,6 +569,7 @@ type PuzzleDisclosure struct {
 	Cookie         []byte
 	FakePuzzleList []byte
 	RandomFactors  [][]byte
+	Sequence       uint64
 }
 
 type SolutionSecrets struct {
@@ -129,7 +577,13 @@ type SolutionSecrets struct {
 }
 
 func (tb *Tumbler) ValidateSolutions(ctx context.Context, pd *PuzzleDisclosure) (*SolutionSecrets, error) {
-	vsr, err := tb.c.ValidateSolutions(ctx, (*pb.ValidateSolutionsRequest)(pd))
+	var vsr *pb.ValidateSolutionsResponse
+	err := tb.resumable(ctx, pd.Cookie, func(seq uint64) (err error) {
+		pd.Sequence = seq
+		vsr, err = tb.c.ValidateSolutions(ctx,
+			(*pb.ValidateSolutionsRequest)(pd), tb.callOptions()...)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("ValidateSolutions %v", err)
 	}
@@ -146,12 +600,64 @@ type PaymentOffer struct {
 	Puzzle            []byte
 	RealPuzzleList    []byte
 	RandomFactors     [][]byte
+	Capacity          int64
+	Sequence          uint64
+}
+
+type PaymentSolution struct {
+	Secrets [][]byte
 }
 
-func (tb *Tumbler) PaymentOffer(ctx context.Context, po *PaymentOffer) error {
-	_, err := tb.c.PaymentOffer(ctx, (*pb.PaymentOfferRequest)(po))
+// PaymentOffer submits the payment offer and retries while the tumbler
+// asks to, e.g. while the offer escrow awaits confirmations. The wait
+// function is called before every retry with the delay requested by the
+// tumbler and the error of the attempt, and aborts retries if it fails.
+func (tb *Tumbler) PaymentOffer(ctx context.Context, po *PaymentOffer, wait func(context.Context, time.Duration, error) error) (*PaymentSolution, error) {
+	for {
+		var por *pb.PaymentOfferResponse
+		err := tb.resumable(ctx, po.Cookie, func(seq uint64) (err error) {
+			po.Sequence = seq
+			por, err = tb.c.PaymentOffer(ctx,
+				(*pb.PaymentOfferRequest)(po))
+			return err
+		})
+		if err == nil {
+			return (*PaymentSolution)(por), nil
+		}
+		delay, ok := retryAfter(err)
+		if !ok {
+			return nil, fmt.Errorf("PaymentOffer %v", err)
+		}
+		if err = wait(ctx, delay, err); err != nil {
+			return nil, err
+		}
+	}
+}
+
+type SessionStatus struct {
+	State            string
+	Epoch            int32
+	Expires          int64
+	ChannelRemaining int64
+	Sequence         uint64
+}
+
+// ResumeSession queries the state of the session identified by the cookie
+// so that the exchange can be continued after a disconnect. Subsequent
+// requests are numbered past the last request accepted in the session.
+func (tb *Tumbler) ResumeSession(ctx context.Context, cookie []byte, opts ...grpc.CallOption) (*SessionStatus, error) {
+	rsr, err := tb.c.ResumeSession(ctx, &pb.ResumeSessionRequest{
+		Cookie: cookie,
+	}, opts...)
 	if err != nil {
-		return fmt.Errorf("PaymentOffer %v", err)
+		return nil, fmt.Errorf("ResumeSession %v", err)
+	}
+	for {
+		seq := atomic.LoadUint64(&tb.sequence)
+		if seq >= rsr.Sequence ||
+			atomic.CompareAndSwapUint64(&tb.sequence, seq, rsr.Sequence) {
+			break
+		}
 	}
-	return nil
+	return (*SessionStatus)(rsr), nil
 }
@@ -0,0 +1,108 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/decred/tumblebit/contract"
+)
+
+// ExportContract writes the contract of the escrow identified by the hex
+// encoded transaction hash to the file, so that its refund and redeem
+// transactions can be verified by VerifyContract.
+func ExportContract(journal *contract.Journal, h, path string) error {
+	escrowHash, err := hex.DecodeString(h)
+	if err != nil {
+		return fmt.Errorf("Invalid escrow hash %q: %v", h, err)
+	}
+	con, err := journal.Load(escrowHash)
+	if err != nil {
+		return fmt.Errorf("Failed to load the contract of %s: %v", h, err)
+	}
+	b, err := con.Marshal()
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(path, append(b, '\n'), 0600); err != nil {
+		return err
+	}
+	fmt.Printf("Contract of escrow %s exported to %s\n", h, path)
+	return nil
+}
+
+// VerifyContract executes the scripts of the refund and redeem
+// transactions of the serialized contract in the file against its escrow
+// and reports the outcome of each spending path. It fails if any of them
+// doesn't verify or the contract has neither.
+func VerifyContract(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	con, err := contract.Deserialize(b, activeNet.Params)
+	if err != nil {
+		return err
+	}
+	if con.EscrowTx == nil {
+		return errors.New("Contract has no escrow transaction")
+	}
+
+	paths := []struct {
+		name   string
+		tx     []byte
+		verify func() error
+	}{
+		{"refund", con.RefundBytes, con.VerifyRefundTx},
+		{"redeem", con.RedeemBytes, con.VerifyRedeemTx},
+	}
+	var verified, failed int
+	for _, p := range paths {
+		if len(p.tx) == 0 {
+			continue
+		}
+		err := p.verify()
+		switch f := err.(type) {
+		case nil:
+			fmt.Printf("The %s transaction verifies\n", p.name)
+			verified++
+			continue
+		case *contract.ScriptFailure:
+			fmt.Printf("The %s transaction fails in the %s path\n",
+				p.name, f.Path)
+			if f.Script != "" {
+				fmt.Printf("  script: %s\n", f.Script)
+			}
+			if f.Opcode != "" {
+				fmt.Printf("  opcode: %s\n", f.Opcode)
+			}
+			fmt.Printf("  error:  %v\n", f.Err)
+			if f.Stack != nil || f.AltStack != nil {
+				fmt.Printf("  stack:  %s\n",
+					contract.FormatStack(f.Stack))
+				fmt.Printf("  alt stack: %s\n",
+					contract.FormatStack(f.AltStack))
+			}
+			if f.Disasm != "" {
+				fmt.Printf("  disassembly: %s\n", f.Disasm)
+			}
+		default:
+			fmt.Printf("The %s transaction fails: %v\n", p.name, err)
+		}
+		failed++
+	}
+
+	switch {
+	case failed != 0:
+		return fmt.Errorf("%d of the contract transactions failed to "+
+			"verify", failed)
+	case verified == 0:
+		return errors.New("Contract has no refund or redeem transaction")
+	}
+	return nil
+}
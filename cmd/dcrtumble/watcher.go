@@ -0,0 +1,126 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"time"
+
+	"github.com/decred/tumblebit/wallet"
+)
+
+// solutionPollInterval is the interval between consecutive lookups of the
+// transaction fulfilling the payment offer.
+const solutionPollInterval = 30 * time.Second
+
+// WaitForSolution watches the blockchain for the transaction fulfilling
+// the payment offer, extracts hash preimages revealed by the tumbler and
+// uses them to unlock solution promises in order to obtain the solution
+// to the puzzle of the payee.
+//
+// The payee can only redeem the escrow of the tumbler until its locktime,
+// so the missing solution is alerted once the escrow is within the
+// configured number of blocks of its locktime. The payer wallet refunds
+// the offer if it expires without being fulfilled.
+func (tb *Tumbler) WaitForSolution(ctx context.Context, payer, payee *wallet.Wallet, pp *PaymentPuzzle, sol *PuzzleSolution) error {
+	tb.progress.setPhase(phaseAwaitSolution, "redeem the escrow")
+
+	ticker := time.NewTicker(solutionPollInterval)
+	defer ticker.Stop()
+
+	alerted := false
+	for {
+		ok, data, err := payer.OfferRedeemer(ctx, sol.Contract)
+		if err != nil {
+			return fmt.Errorf("Failed to look up the fulfilling tx: %v",
+				err)
+		}
+		if ok {
+			solution, err := extractPuzzleSolution(sol.challenge,
+				sol.response, pp.Key, pp.Puzzle, data)
+			if err != nil {
+				return fmt.Errorf("Failed to extract the solution: %v",
+					err)
+			}
+			sol.Solution = solution
+			return nil
+		}
+
+		height, err := payer.CurrentBlockHeight(ctx)
+		if err != nil {
+			return err
+		}
+		refundHeight, err := payer.RefundHeight(ctx, sol.Contract)
+		if err != nil {
+			return err
+		}
+		if height >= refundHeight {
+			log.Printf("ALERT: payment offer %x has expired without "+
+				"being fulfilled, refunding its escrow",
+				sol.Contract.EscrowHash)
+			err = refundOffer(ctx, payer, tb.journal, sol.Contract, height)
+			if err != nil {
+				return fmt.Errorf("Offer has expired without being "+
+					"fulfilled and failed to be refunded: %v", err)
+			}
+			return errors.New("Offer has expired without being " +
+				"fulfilled")
+		}
+		if !alerted {
+			deadline, err := payee.RefundHeight(ctx, pp.Contract)
+			if err != nil {
+				return err
+			}
+			if deadline != math.MaxUint32 &&
+				height+tb.deadlineWarning >= deadline {
+				log.Printf("ALERT: the tumbler hasn't fulfilled "+
+					"payment offer %x, escrow %x paying the payee "+
+					"can be refunded to the tumbler at block %d",
+					sol.Contract.EscrowHash, pp.Contract.EscrowHash,
+					deadline)
+				alerted = true
+			}
+		}
+		confs, err := payer.Confirmations(ctx, sol.Contract.EscrowHash)
+		if err != nil {
+			return err
+		}
+		tb.progress.confirmations(height, confs, refundHeight)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// exportedSolution is the format of the file the solution is exported to.
+type exportedSolution struct {
+	EscrowHash string `json:"escrow_hash"`
+	Puzzle     string `json:"puzzle"`
+	Solution   string `json:"solution"`
+}
+
+// ExportSolution saves the puzzle solution to the file so that it can be
+// delivered to the payee.
+func ExportSolution(path string, pp *PaymentPuzzle, sol *PuzzleSolution) error {
+	b, err := json.MarshalIndent(&exportedSolution{
+		EscrowHash: hex.EncodeToString(sol.Contract.EscrowHash),
+		Puzzle:     hex.EncodeToString(pp.Puzzle),
+		Solution:   hex.EncodeToString(sol.Solution),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), 0600)
+}
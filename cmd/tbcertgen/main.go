@@ -0,0 +1,194 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// tbcertgen issues certificates used to authenticate clients of a private
+// TumbleBit server.
+//
+// A certificate authority is created once with:
+//
+//   tbcertgen ca -out <dir>
+//
+// and used to issue a certificate for every client with:
+//
+//   tbcertgen client -ca <dir>/clientca.cert -cakey <dir>/clientca.key \
+//       -name <client> -out <dir>
+//
+// The server is started with --clientcafile pointing to the CA certificate
+// and, optionally, --authorizedclient options listing fingerprints of
+// allowed client certificates printed by this tool. Clients pass their
+// certificate and key to dcrtumble with --clientcert and --clientkey.
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	caName       = "clientca"
+	organization = "tumblebit client authentication"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n"+
+		"  tbcertgen ca -out <dir> [-validity <duration>]\n"+
+		"  tbcertgen client -ca <cert> -cakey <key> -name <name> "+
+		"-out <dir> [-validity <duration>]\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "ca":
+		err = issueCA(os.Args[2:])
+	case "client":
+		err = issueClient(os.Args[2:])
+	default:
+		usage()
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+func issueCA(args []string) error {
+	fs := flag.NewFlagSet("ca", flag.ExitOnError)
+	out := fs.String("out", ".", "Output directory")
+	validity := fs.Duration("validity", 10*365*24*time.Hour,
+		"Validity period of the certificate")
+	fs.Parse(args)
+
+	template, err := newTemplate(caName, *validity)
+	if err != nil {
+		return err
+	}
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template,
+		&key.PublicKey, key)
+	if err != nil {
+		return fmt.Errorf("failed to create the CA certificate: %v", err)
+	}
+
+	return writeKeyPair(*out, caName, der, key)
+}
+
+func issueClient(args []string) error {
+	fs := flag.NewFlagSet("client", flag.ExitOnError)
+	caCert := fs.String("ca", "", "CA certificate")
+	caKey := fs.String("cakey", "", "CA private key")
+	name := fs.String("name", "", "Name of the client")
+	out := fs.String("out", ".", "Output directory")
+	validity := fs.Duration("validity", 365*24*time.Hour,
+		"Validity period of the certificate")
+	fs.Parse(args)
+
+	if *caCert == "" || *caKey == "" || *name == "" {
+		return errors.New("-ca, -cakey and -name must be specified")
+	}
+
+	ca, err := tls.LoadX509KeyPair(*caCert, *caKey)
+	if err != nil {
+		return fmt.Errorf("failed to load the CA: %v", err)
+	}
+	parent, err := x509.ParseCertificate(ca.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("failed to parse the CA certificate: %v", err)
+	}
+
+	template, err := newTemplate(*name, *validity)
+	if err != nil {
+		return err
+	}
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent,
+		&key.PublicKey, ca.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("failed to create the client certificate: %v",
+			err)
+	}
+
+	return writeKeyPair(*out, *name, der, key)
+}
+
+func newTemplate(name string, validity time.Duration) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate a serial number: %v",
+			err)
+	}
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   name,
+			Organization: []string{organization},
+		},
+		NotBefore: now.Add(-time.Hour),
+		NotAfter:  now.Add(validity),
+	}, nil
+}
+
+// writeKeyPair writes the certificate and the private key in PEM format
+// and prints the fingerprint of the certificate.
+func writeKeyPair(dir, name string, der []byte, key *ecdsa.PrivateKey) error {
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	certFile := filepath.Join(dir, name+".cert")
+	keyFile := filepath.Join(dir, name+".key")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE",
+		Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY",
+		Bytes: keyDER})
+	if err = ioutil.WriteFile(certFile, certPEM, 0644); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		return err
+	}
+
+	fmt.Printf("Certificate: %s\n", certFile)
+	fmt.Printf("Private key: %s\n", keyFile)
+	fmt.Printf("Fingerprint: %x\n", sha256.Sum256(der))
+	return nil
+}
@@ -0,0 +1,291 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/tumblebit/puzzle"
+	pb "github.com/decred/tumblebit/rpc/tumblerrpc"
+	"github.com/decred/tumblebit/shuffle"
+)
+
+// benchClient runs synthetic exchanges against the tumbler. Responses are
+// only checked to the extent required to continue the exchange, so that
+// the client spends as little time as possible outside of the tumbler.
+type benchClient struct {
+	c           pb.TumblerServiceClient
+	chainParams *chaincfg.Params
+	info        *pb.GetTumblerInfoResponse
+	rec         *recorder
+}
+
+// call invokes fn and records its latency and outcome under the method
+// name.
+func (bc *benchClient) call(method string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	bc.rec.observe(method, time.Since(start), err)
+	if err != nil {
+		return fmt.Errorf("%s: %v", method, err)
+	}
+	return nil
+}
+
+// randomBytes returns n random bytes.
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	rand.Read(b)
+	return b
+}
+
+// exchange runs a payee session obtaining a puzzle from the tumbler
+// followed by a payer session paying for its solution.
+func (bc *benchClient) exchange(ctx context.Context) error {
+	start := time.Now()
+	pp, err := bc.payee(ctx)
+	if err == nil {
+		err = bc.payer(ctx, pp)
+	}
+	bc.rec.observe(exchangeMethod, time.Since(start), err)
+	return err
+}
+
+// paymentPuzzle is the puzzle obtained by the payee and handed over to the
+// payer.
+type paymentPuzzle struct {
+	epoch  int32
+	key    puzzle.PuzzlePubKey
+	puzzle []byte
+}
+
+// payee runs the escrow setup and the Puzzle-Promise protocol and returns
+// a blinded puzzle for the payer to solve.
+func (bc *benchClient) payee(ctx context.Context) (*paymentPuzzle, error) {
+	addr, pubKey, err := newAddress(bc.chainParams)
+	if err != nil {
+		return nil, err
+	}
+
+	var escrow *pb.SetupEscrowResponse
+	err = bc.call("SetupEscrow", func() (err error) {
+		escrow, err = bc.c.SetupEscrow(ctx, &pb.SetupEscrowRequest{
+			Address:              addr,
+			PublicKey:            pubKey,
+			Amount:               bc.info.Denomination,
+			RealTransactionCount: bc.info.RealTransactionCount,
+			FakeTransactionCount: bc.info.FakeTransactionCount,
+			ProtocolVersion:      pb.ProtocolVersion,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	realCount := int(bc.info.RealTransactionCount)
+	fakeCount := int(bc.info.FakeTransactionCount)
+	txHashes := make([][]byte, realCount+fakeCount)
+	realTxList := make([]int, realCount)
+	fakeTxList := make([]int, fakeCount)
+	randomPads := make([][]byte, fakeCount)
+	for i := range txHashes {
+		if i < realCount {
+			txHashes[i] = randomBytes(32)
+			realTxList[i] = i
+		} else {
+			randomPads[i-realCount] = randomBytes(32)
+			txHashes[i] = puzzle.FakeTxFormat(randomPads[i-realCount])
+			fakeTxList[i-realCount] = i
+		}
+	}
+	perm, err := shuffle.ShuffleSecure(rand.Reader, len(txHashes),
+		func(i, j int) {
+			txHashes[i], txHashes[j] = txHashes[j], txHashes[i]
+		})
+	if err != nil {
+		return nil, err
+	}
+	perm.Apply(fakeTxList)
+	perm.Apply(realTxList)
+
+	salt := randomBytes(32)
+	fakeSetHash, err := puzzle.IndexCommitment{
+		Version: puzzle.CommitmentV1,
+		Phase:   puzzle.PhaseFakeTransactions,
+	}.Commit(salt, fakeTxList)
+	if err != nil {
+		return nil, err
+	}
+	realSetHash, err := puzzle.IndexCommitment{
+		Version: puzzle.CommitmentV1,
+		Phase:   puzzle.PhaseRealTransactions,
+	}.Commit(salt, realTxList)
+	if err != nil {
+		return nil, err
+	}
+	fakeTxIndexes, err := puzzle.EncodeIndexList(fakeTxList)
+	if err != nil {
+		return nil, err
+	}
+	realTxIndexes, err := puzzle.EncodeIndexList(realTxList)
+	if err != nil {
+		return nil, err
+	}
+
+	var promise *pb.GetPuzzlePromisesResponse
+	err = bc.call("GetPuzzlePromises", func() (err error) {
+		promise, err = bc.c.GetPuzzlePromises(ctx,
+			&pb.GetPuzzlePromisesRequest{
+				Cookie:            escrow.Cookie,
+				FakeSetHash:       fakeSetHash,
+				RealSetHash:       realSetHash,
+				TransactionHashes: txHashes,
+				Sequence:          1,
+			})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(promise.Puzzles) != len(txHashes) {
+		return nil, errors.New("received an incomplete set of puzzles")
+	}
+
+	err = bc.call("FinalizeEscrow", func() error {
+		_, err := bc.c.FinalizeEscrow(ctx, &pb.FinalizeEscrowRequest{
+			Cookie:     escrow.Cookie,
+			Salt:       salt,
+			FakeTxList: fakeTxIndexes,
+			RealTxList: realTxIndexes,
+			RandomPads: randomPads,
+			Sequence:   2,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pkey, err := puzzle.ParsePubKey(promise.PuzzleKey)
+	if err != nil {
+		return nil, err
+	}
+	blinded, _, _, err := puzzle.BlindPuzzle(&pkey,
+		promise.Puzzles[realTxList[0]])
+	if err != nil {
+		return nil, err
+	}
+	return &paymentPuzzle{
+		epoch:  escrow.Epoch,
+		key:    pkey,
+		puzzle: blinded,
+	}, nil
+}
+
+// payer runs the Puzzle-Solver protocol for the puzzle and makes the
+// payment offer the tumbler fulfills by revealing the solution.
+func (bc *benchClient) payer(ctx context.Context, pp *paymentPuzzle) error {
+	addr, pubKey, err := newAddress(bc.chainParams)
+	if err != nil {
+		return err
+	}
+
+	realCount := int(bc.info.RealPreimageCount)
+	fakeCount := int(bc.info.FakePreimageCount)
+	puzzles := make([][]byte, realCount+fakeCount)
+	realFactors := make([][]byte, realCount)
+	realPuzzleList := make([]int, realCount)
+	fakeFactors := make([][]byte, fakeCount)
+	fakePuzzleList := make([]int, fakeCount)
+	one := big.NewInt(1).Bytes()
+	for i := range puzzles {
+		if i < fakeCount {
+			puzzles[i], fakeFactors[i], _, err =
+				puzzle.BlindPuzzle(&pp.key, one)
+			fakePuzzleList[i] = i
+		} else {
+			puzzles[i], realFactors[i-fakeCount], _, err =
+				puzzle.BlindPuzzle(&pp.key, pp.puzzle)
+			realPuzzleList[i-fakeCount] = i
+		}
+		if err != nil {
+			return err
+		}
+	}
+	perm, err := shuffle.ShuffleSecure(rand.Reader, len(puzzles),
+		func(i, j int) {
+			puzzles[i], puzzles[j] = puzzles[j], puzzles[i]
+		})
+	if err != nil {
+		return err
+	}
+	perm.Apply(fakePuzzleList)
+	perm.Apply(realPuzzleList)
+	fakePuzzleIndexes, err := puzzle.EncodeIndexList(fakePuzzleList)
+	if err != nil {
+		return err
+	}
+	realPuzzleIndexes, err := puzzle.EncodeIndexList(realPuzzleList)
+	if err != nil {
+		return err
+	}
+
+	work, err := puzzle.SolveWork(pp.epoch, puzzles, int(bc.info.WorkBits))
+	if err != nil {
+		return err
+	}
+	var promise *pb.GetSolutionPromisesResponse
+	err = bc.call("GetSolutionPromises", func() (err error) {
+		promise, err = bc.c.GetSolutionPromises(ctx,
+			&pb.GetSolutionPromisesRequest{
+				Address:           addr,
+				Epoch:             pp.epoch,
+				Puzzles:           puzzles,
+				RealPreimageCount: bc.info.RealPreimageCount,
+				FakePreimageCount: bc.info.FakePreimageCount,
+				Work:              work,
+			})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = bc.call("ValidateSolutions", func() error {
+		_, err := bc.c.ValidateSolutions(ctx, &pb.ValidateSolutionsRequest{
+			Cookie:         promise.Cookie,
+			FakePuzzleList: fakePuzzleIndexes,
+			RandomFactors:  fakeFactors,
+			Sequence:       1,
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return bc.call("PaymentOffer", func() error {
+		_, err := bc.c.PaymentOffer(ctx, &pb.PaymentOfferRequest{
+			Cookie:            promise.Cookie,
+			Amount:            bc.info.Denomination + bc.info.Fee,
+			PublicKey:         pubKey,
+			EscrowHash:        randomBytes(32),
+			EscrowScript:      randomBytes(32),
+			EscrowTransaction: randomBytes(32),
+			Puzzle:            pp.puzzle,
+			RealPuzzleList:    realPuzzleIndexes,
+			RandomFactors:     realFactors,
+			Sequence:          2,
+		})
+		return err
+	})
+}
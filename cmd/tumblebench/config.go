@@ -0,0 +1,68 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/decred/tumblebit/puzzle"
+	"github.com/decred/tumblebit/tumbler"
+
+	flags "github.com/jessevdk/go-flags"
+)
+
+const (
+	defaultSessions  = 8
+	defaultExchanges = 100
+)
+
+// config defines the configuration options for tumblebench.
+type config struct {
+	Sessions         int           `short:"n" long:"sessions" description:"Number of concurrent exchanges"`
+	Exchanges        int           `long:"exchanges" description:"Total number of exchanges to run, unlimited with --duration if 0"`
+	Duration         time.Duration `long:"duration" description:"Stop starting new exchanges after this duration"`
+	PuzzleDifficulty int           `long:"puzzledifficulty" description:"TumbleBit puzzle difficulty"`
+	PuzzleScheme     string        `long:"puzzlescheme" description:"TumbleBit puzzle scheme {rsa, rsa-fdh}"`
+	Parallelism      int           `long:"parallelism" description:"Maximum number of puzzles processed concurrently for a single exchange (default: number of CPUs)"`
+	Debug            bool          `long:"debug" description:"Write the tumbler log to standard error"`
+}
+
+// loadConfig parses command line options and validates them.
+func loadConfig() (*config, error) {
+	cfg := config{
+		Sessions:         defaultSessions,
+		Exchanges:        defaultExchanges,
+		PuzzleDifficulty: tumbler.PuzzleDifficulty,
+		PuzzleScheme:     puzzle.RSA.Name(),
+	}
+
+	parser := flags.NewParser(&cfg, flags.Default)
+	if _, err := parser.Parse(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	switch {
+	case cfg.Sessions <= 0:
+		err = errors.New("sessions must be positive")
+	case cfg.Exchanges < 0:
+		err = errors.New("exchanges must not be negative")
+	case cfg.Exchanges == 0 && cfg.Duration <= 0:
+		err = errors.New("either exchanges or duration must be specified")
+	case cfg.Parallelism < 0:
+		err = errors.New("parallelism must not be negative")
+	}
+	if err == nil {
+		_, err = puzzle.LookupScheme(cfg.PuzzleScheme)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, err
+	}
+	return &cfg, nil
+}
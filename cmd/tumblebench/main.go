@@ -0,0 +1,183 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// tumblebench measures the performance of the tumbler. It serves the
+// TumblerService backed by an in-memory wallet on a loopback interface and
+// drives it with concurrent synthetic exchanges, each consisting of a
+// payee session running the Puzzle-Promise protocol and a payer session
+// running the Puzzle-Solver protocol. Throughput, latency percentiles of
+// every call and failure rates are reported once all exchanges complete.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btclog"
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/tumblebit/puzzle"
+	"github.com/decred/tumblebit/rpc/rpcserver"
+	pb "github.com/decred/tumblebit/rpc/tumblerrpc"
+	"github.com/decred/tumblebit/tumbler"
+
+	"google.golang.org/grpc"
+)
+
+// startupTimeout limits the time spent waiting for the first epoch.
+const startupTimeout = time.Minute
+
+func main() {
+	cfg, err := loadConfig()
+	if err != nil {
+		os.Exit(1)
+	}
+	if err = run(cfg); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(cfg *config) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if cfg.Debug {
+		backend := btclog.NewBackend(os.Stderr)
+		tlog := backend.Logger("TMBL")
+		tlog.SetLevel(btclog.LevelDebug)
+		tumbler.UseLogger(tlog)
+		rpcserver.UseLogger(backend.Logger("GRPC"))
+	}
+
+	chainParams := &chaincfg.SimNetParams
+	w, err := newBenchWallet(chainParams)
+	if err != nil {
+		return err
+	}
+	scheme, err := puzzle.LookupScheme(cfg.PuzzleScheme)
+	if err != nil {
+		return err
+	}
+	tb := tumbler.NewTumbler(&tumbler.Config{
+		ChainParams:      chainParams,
+		PuzzleDifficulty: cfg.PuzzleDifficulty,
+		PuzzleScheme:     scheme,
+		Parallelism:      cfg.Parallelism,
+		Wallet:           w,
+	})
+	tbDone := make(chan error, 1)
+	go func() {
+		tbDone <- tb.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-tbDone
+	}()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	server := grpc.NewServer()
+	rpcserver.RegisterServices(server)
+	rpcserver.StartTumblerService(server, tb)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	conn, err := grpc.DialContext(ctx, lis.Addr().String(),
+		grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	c := pb.NewTumblerServiceClient(conn)
+
+	info, err := waitForEpoch(ctx, c)
+	if err != nil {
+		return fmt.Errorf("tumbler didn't start: %v", err)
+	}
+	log.Printf("Running exchanges in %d concurrent sessions, puzzle "+
+		"difficulty %d", cfg.Sessions, cfg.PuzzleDifficulty)
+
+	// New exchanges aren't started once the duration elapses or on
+	// interrupt, exchanges in progress are allowed to complete.
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		var timeout <-chan time.Time
+		if cfg.Duration > 0 {
+			timeout = time.After(cfg.Duration)
+		}
+		select {
+		case <-interrupt:
+		case <-timeout:
+		case <-ctx.Done():
+		}
+		stopOnce.Do(func() { close(stop) })
+	}()
+
+	bc := &benchClient{
+		c:           c,
+		chainParams: chainParams,
+		info:        info,
+		rec:         newRecorder(),
+	}
+	var started int64
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < cfg.Sessions; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if cfg.Exchanges > 0 && atomic.AddInt64(&started, 1) >
+					int64(cfg.Exchanges) {
+					return
+				}
+				err := bc.exchange(ctx)
+				if err != nil && cfg.Debug {
+					log.Printf("Exchange failed: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	stopOnce.Do(func() { close(stop) })
+
+	bc.rec.report(os.Stdout, elapsed)
+	return nil
+}
+
+// waitForEpoch polls the tumbler until it has created the first epoch and
+// returns the parameters it advertises.
+func waitForEpoch(ctx context.Context, c pb.TumblerServiceClient) (*pb.GetTumblerInfoResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, startupTimeout)
+	defer cancel()
+	for {
+		info, err := c.GetTumblerInfo(ctx, &pb.GetTumblerInfoRequest{})
+		if err == nil {
+			return info, nil
+		}
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-ctx.Done():
+			return nil, err
+		}
+	}
+}
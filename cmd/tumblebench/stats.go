@@ -0,0 +1,120 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/status"
+)
+
+// exchangeMethod is the name latencies of whole exchanges are recorded
+// under.
+const exchangeMethod = "Exchange"
+
+// methods lists the recorded calls in the order they are reported.
+var methods = []string{
+	"SetupEscrow",
+	"GetPuzzlePromises",
+	"FinalizeEscrow",
+	"GetSolutionPromises",
+	"ValidateSolutions",
+	"PaymentOffer",
+	exchangeMethod,
+}
+
+// recorder collects latencies and failures of calls made by concurrent
+// sessions.
+type recorder struct {
+	mu        sync.Mutex
+	latencies map[string][]time.Duration
+	failures  map[string]int
+	errors    map[string]int
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		latencies: make(map[string][]time.Duration),
+		failures:  make(map[string]int),
+		errors:    make(map[string]int),
+	}
+}
+
+// observe records the outcome of a call. Failures are tallied by the gRPC
+// status code as well.
+func (r *recorder) observe(method string, d time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.failures[method]++
+		if method != exchangeMethod {
+			r.errors[status.Code(err).String()]++
+		}
+		return
+	}
+	r.latencies[method] = append(r.latencies[method], d)
+}
+
+// percentile returns the latency below which the fraction p of sorted
+// latencies falls.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+// report writes the throughput, latency percentiles and failure rates of
+// the calls observed within the elapsed time.
+func (r *recorder) report(w io.Writer, elapsed time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	completed := len(r.latencies[exchangeMethod])
+	failed := r.failures[exchangeMethod]
+	fmt.Fprintf(w, "Exchanges: %d completed, %d failed in %v\n",
+		completed, failed, elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "Throughput: %.2f exchanges/s\n\n",
+		float64(completed)/elapsed.Seconds())
+
+	fmt.Fprintf(w, "%-20s %8s %8s %10s %10s %10s %10s\n", "Call", "OK",
+		"Failed", "p50", "p90", "p99", "max")
+	for _, m := range methods {
+		l := r.latencies[m]
+		if len(l) == 0 && r.failures[m] == 0 {
+			continue
+		}
+		sort.Slice(l, func(i, j int) bool { return l[i] < l[j] })
+		fmt.Fprintf(w, "%-20s %8d %7.2f%% %10v %10v %10v %10v\n", m,
+			len(l), 100*float64(r.failures[m])/
+				float64(len(l)+r.failures[m]),
+			percentile(l, 0.5).Round(time.Microsecond),
+			percentile(l, 0.9).Round(time.Microsecond),
+			percentile(l, 0.99).Round(time.Microsecond),
+			percentile(l, 1).Round(time.Microsecond))
+	}
+
+	if len(r.errors) == 0 {
+		return
+	}
+	codes := make([]string, 0, len(r.errors))
+	for c := range r.errors {
+		codes = append(codes, c)
+	}
+	sort.Strings(codes)
+	fmt.Fprintln(w, "\nErrors:")
+	for _, c := range codes {
+		fmt.Fprintf(w, "  %-20s %d\n", c, r.errors[c])
+	}
+}
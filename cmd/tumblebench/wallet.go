@@ -0,0 +1,119 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"sync/atomic"
+
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrd/chaincfg/chainec"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrutil"
+	"github.com/decred/tumblebit/contract"
+	"github.com/decred/tumblebit/tumbler"
+)
+
+// benchWallet implements the tumbler Wallet interface in memory so that
+// the benchmark measures the tumbler alone. Transactions are never
+// constructed, escrows are funded out of thin air and offers are always
+// confirmed.
+type benchWallet struct {
+	chainParams *chaincfg.Params
+	priv        chainec.PrivateKey
+	pub         chainec.PublicKey
+
+	escrows   uint64 // atomic
+	solutions uint64 // atomic
+}
+
+// Make sure the benchmark wallet satisfies the interface.
+var _ tumbler.Wallet = (*benchWallet)(nil)
+
+func newBenchWallet(chainParams *chaincfg.Params) (*benchWallet, error) {
+	priv, _, _, err := chainec.Secp256k1.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	w := &benchWallet{chainParams: chainParams}
+	w.priv, w.pub = chainec.Secp256k1.PrivKeyFromBytes(priv)
+	return w, nil
+}
+
+// newAddress generates a new secp256k1 key and returns the P2PKH address
+// and the encoded public key.
+func newAddress(chainParams *chaincfg.Params) (string, string, error) {
+	priv, _, _, err := chainec.Secp256k1.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	_, pub := chainec.Secp256k1.PrivKeyFromBytes(priv)
+	addr, err := dcrutil.NewAddressSecpPubKey(pub.SerializeCompressed(),
+		chainParams)
+	if err != nil {
+		return "", "", err
+	}
+	return addr.EncodeAddress(), addr.String(), nil
+}
+
+func (w *benchWallet) CurrentBlockHeight(ctx context.Context) (uint32, error) {
+	return 1000, nil
+}
+
+func (w *benchWallet) NotifyBlocks(ctx context.Context, blocks chan<- int32) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (w *benchWallet) GetCashOutAddress(ctx context.Context) (string, string, error) {
+	return newAddress(w.chainParams)
+}
+
+func (w *benchWallet) ImportEscrowScript(ctx context.Context, con *contract.Contract) error {
+	return nil
+}
+
+func (w *benchWallet) CreateEscrow(ctx context.Context, con *contract.Contract) error {
+	addr, pkey, err := newAddress(w.chainParams)
+	if err != nil {
+		return err
+	}
+	if err = con.SetAddress(contract.SenderAddress, addr, pkey); err != nil {
+		return err
+	}
+	if err = con.AddEscrowScript(); err != nil {
+		return err
+	}
+	con.EscrowBytes = chainhash.HashB(con.EscrowScript)
+	return nil
+}
+
+func (w *benchWallet) SignHashes(ctx context.Context, con *contract.Contract, txHashes [][]byte) ([][]byte, []byte, error) {
+	signatures := make([][]byte, len(txHashes))
+	for i, hash := range txHashes {
+		r, s, err := chainec.Secp256k1.Sign(w.priv, hash)
+		if err != nil {
+			return nil, nil, err
+		}
+		signatures[i] = chainec.Secp256k1.NewSignature(r, s).Serialize()
+	}
+	return signatures, w.pub.Serialize(), nil
+}
+
+func (w *benchWallet) ValidateOffer(ctx context.Context, con *contract.Contract, escrowHash []byte) (bool, error) {
+	return true, nil
+}
+
+func (w *benchWallet) PublishEscrow(ctx context.Context, con *contract.Contract) error {
+	con.EscrowHash = chainhash.HashB(con.EscrowBytes)
+	atomic.AddUint64(&w.escrows, 1)
+	return nil
+}
+
+func (w *benchWallet) PublishSolution(ctx context.Context, con *contract.Contract, secrets [][]byte) error {
+	atomic.AddUint64(&w.solutions, 1)
+	return nil
+}
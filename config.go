@@ -7,6 +7,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"os"
@@ -14,24 +15,49 @@ import (
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/btcsuite/btclog"
 	"github.com/decred/dcrd/dcrutil"
+	"github.com/decred/tumblebit/contract"
 	"github.com/decred/tumblebit/internal/cfgutil"
+	"github.com/decred/tumblebit/internal/secrets"
 	"github.com/decred/tumblebit/netparams"
+	"github.com/decred/tumblebit/puzzle"
+	"github.com/decred/tumblebit/rpc/rpcserver"
 	"github.com/decred/tumblebit/tumbler"
 	"github.com/decred/tumblebit/version"
+	"github.com/decred/tumblebit/wallet"
 
 	flags "github.com/jessevdk/go-flags"
 )
 
 const (
-	defaultCAFilename     = "dcrwallet.cert"
-	defaultConfigFilename = "tumblebit.conf"
-	defaultLogLevel       = "info"
-	defaultLogDirname     = "logs"
-	defaultLogFilename    = "tumblebit.log"
+	defaultCAFilename      = "dcrwallet.cert"
+	defaultConfigFilename  = "tumblebit.conf"
+	defaultJournalFilename = "contracts.db"
+	unixListenerPrefix     = "unix://"
+	defaultLogLevel        = "info"
+	defaultLogDirname      = "logs"
+	defaultLogFilename     = "tumblebit.log"
+	defaultJSONLogFilename = "tumblebit.json"
+	defaultAuditDirname    = "audit"
+	defaultAccountName     = "tumblebit"
+	walletRPCGRPC          = "grpc"
+	walletRPCJSON          = "jsonrpc"
+	defaultGRPCMaxMsgSize  = 4 << 20
+	defaultGRPCKeepalive   = 2 * time.Minute
+	defaultGRPCPingTimeout = 20 * time.Second
+	defaultGRPCMinPing     = 30 * time.Second
+	defaultWalletKeepalive = 5 * time.Minute
+	defaultTLSCertLifetime = 10 * 365 * 24 * time.Hour
+	minTLSCertLifetime     = time.Hour
+
+	// envPrefix prefixes names of environment variables overriding
+	// options, e.g. TUMBLEBIT_WALLETPASSWORD.
+	envPrefix = "TUMBLEBIT_"
 )
 
 var (
@@ -40,40 +66,124 @@ var (
 	defaultConfigFile   = filepath.Join(defaultAppDataDir, defaultConfigFilename)
 	defaultRPCKeyFile   = filepath.Join(defaultAppDataDir, "rpc.key")
 	defaultRPCCertFile  = filepath.Join(defaultAppDataDir, "rpc.cert")
+	defaultIdentityKey  = filepath.Join(defaultAppDataDir, "identity.key")
 	defaultLogDir       = filepath.Join(defaultAppDataDir, defaultLogDirname)
 )
 
 type config struct {
 	// General application behavior
-	ConfigFile  *cfgutil.ExplicitString `short:"C" long:"configfile" description:"Path to configuration file"`
-	ShowVersion bool                    `short:"V" long:"version" description:"Display version information and exit"`
-	AppDataDir  *cfgutil.ExplicitString `short:"A" long:"appdata" description:"Application data directory for tumblebit config, databases and logs"`
-	TestNet     bool                    `long:"testnet" description:"Use the test network"`
-	SimNet      bool                    `long:"simnet" description:"Use the simulation test network"`
-	DebugLevel  string                  `short:"d" long:"debuglevel" description:"Logging level {trace, debug, info, warn, error, critical}"`
-	LogDir      *cfgutil.ExplicitString `long:"logdir" description:"Directory to log output."`
-	MemProfile  string                  `long:"memprofile" description:"Write mem profile to the specified file"`
+	ConfigFile     *cfgutil.ExplicitString `short:"C" long:"configfile" description:"Path to configuration file"`
+	ShowVersion    bool                    `short:"V" long:"version" description:"Display version information and exit"`
+	AppDataDir     *cfgutil.ExplicitString `short:"A" long:"appdata" description:"Application data directory for tumblebit config, databases and logs"`
+	TestNet        bool                    `long:"testnet" description:"Use the test network"`
+	SimNet         bool                    `long:"simnet" description:"Use the simulation test network"`
+	DebugLevel     string                  `short:"d" long:"debuglevel" description:"Logging level {trace, debug, info, warn, error, critical}"`
+	LogDir         *cfgutil.ExplicitString `long:"logdir" description:"Directory to log output."`
+	JSONLog        bool                    `long:"jsonlog" description:"Write structured JSON records of session state transitions and errors to tumblebit.json in the log directory"`
+	MemProfile     string                  `long:"memprofile" description:"Write mem profile to the specified file on shutdown"`
+	VerboseScripts bool                    `long:"verbosescripts" description:"Include the stacks of the script engine and the disassembly of the failing script in contract verification errors"`
 
 	// RPC client options
 	RPCConnect       string                  `short:"c" long:"rpcconnect" description:"Hostname/IP and port of dcrwallet RPC server to connect to"`
+	WalletRPC        string                  `long:"walletrpc" description:"Protocol of the dcrwallet RPC server {grpc, jsonrpc}"`
+	WalletRPCUser    string                  `long:"walletrpcuser" description:"Username for dcrwallet JSON-RPC authentication"`
+	WalletRPCPass    string                  `long:"walletrpcpass" default-mask:"-" description:"Password for dcrwallet JSON-RPC authentication"`
 	CAFile           *cfgutil.ExplicitString `long:"cafile" description:"File containing root certificates to authenticate a TLS connections with dcrwallet"`
 	DisableClientTLS bool                    `long:"noclienttls" description:"Disable TLS for the RPC client -- NOTE: This is only allowed if the RPC client is connecting to localhost"`
+	WalletClientCert string                  `long:"walletclientcert" description:"Client certificate presented to dcrwallet when it requires client certificate authentication"`
+	WalletClientKey  string                  `long:"walletclientkey" description:"Private key of the client certificate presented to dcrwallet"`
 	WalletPassword   string                  `long:"walletpassword" default-mask:"-" description:"The private passphrase to unlock the wallet"`
+	SecretsFile      string                  `long:"secretsfile" description:"Read the wallet passphrase from this encrypted secrets file, prompting for its passphrase at startup"`
+	PromptPass       bool                    `long:"promptpass" description:"Prompt for the wallet passphrase at startup"`
+	CreateSecrets    bool                    `long:"createsecrets" description:"Create the encrypted secrets file specified with --secretsfile and exit"`
 	Account          uint32                  `long:"account" description:"BIP0044 account number to use for transactions"`
 	AccountName      string                  `long:"accountname" description:"Name of the account to use for transactions -- NOTE: This takes precedence over the numeric specification"`
+	CreateAccount    bool                    `long:"createaccount" description:"Create the account specified with --accountname (default: tumblebit) unless it exists -- NOTE: This requires the wallet passphrase"`
+	UnlockAccounts   bool                    `long:"unlockaccounts" description:"Unlock the accounts of the tumbler at startup and lock them on shutdown instead of sending the wallet passphrase with every signing request"`
+	FundingAccount   string                  `long:"fundingaccount" description:"Name of the account funding escrows (default: the primary account)"`
+	CashOutAccount   string                  `long:"cashoutaccount" description:"Name of the account providing epoch addresses that receive payments (default: the primary account)"`
+	FeeAccount       string                  `long:"feeaccount" description:"Name of the account receiving redeemed payments and commissions (default: the primary account)"`
+	RefundAccount    string                  `long:"refundaccount" description:"Name of the account receiving refunds of expired escrows (default: the primary account)"`
+	WalletRetries    int                     `long:"walletretries" description:"Number of times a request is retried while dcrwallet is unavailable"`
+	WalletBackoff    time.Duration           `long:"walletbackoff" description:"Delay before retrying a failed dcrwallet request, doubled with every attempt"`
+	HealthInterval   time.Duration           `long:"healthinterval" description:"Interval between dcrwallet health checks"`
+	Rebroadcast      time.Duration           `long:"rebroadcast" description:"Publish unconfirmed escrow, refund and redeem transactions again after this interval, doubled with every attempt, 0 to disable"`
+	MaxRebroadcast   time.Duration           `long:"maxrebroadcast" description:"Maximum interval between two attempts to publish an unconfirmed transaction"`
+	WalletKeepalive  time.Duration           `long:"walletkeepalive" description:"Interval of pings keeping idle connections to dcrwallet alive, 0 to disable -- NOTE: dcrwallet disconnects clients pinging more often than every five minutes"`
+	SingleInput      bool                    `long:"singleinput" description:"Fund every escrow with a single unspent output, or the outputs of a single address with --nopartialspends"`
+	NoPartialSpends  bool                    `long:"nopartialspends" description:"Spend all outputs paying to the same address together when funding escrows"`
+	EscrowMinConf    int32                   `long:"escrowminconf" description:"Minimum number of confirmations of outputs funding escrows"`
+	OfflineSignDir   string                  `long:"offlinesigndir" description:"Exchange escrow signing requests with an offline signer through this directory instead of signing with dcrwallet"`
+	AddressPoolSize  int                     `long:"addresspoolsize" description:"Number of addresses allocated ahead of time for every account branch"`
+	GapLimit         int                     `long:"gaplimit" description:"Maximum number of allocated addresses that haven't been used by a transaction or an escrow"`
+	GapPolicy        string                  `long:"gappolicy" description:"Handling of address requests once the gap limit is reached {wrap, error, ignore}"`
 
 	// RPC server options
 	RPCCert          *cfgutil.ExplicitString `long:"rpccert" description:"File containing the certificate file"`
 	RPCKey           *cfgutil.ExplicitString `long:"rpckey" description:"File containing the certificate key"`
 	TLSCurve         *cfgutil.CurveFlag      `long:"tlscurve" description:"Curve to use when generating TLS keypairs"`
+	TLSCertLifetime  time.Duration           `long:"tlscertlifetime" description:"Lifetime of generated TLS certificates, which are rotated before they expire"`
 	OneTimeTLSKey    bool                    `long:"onetimetlskey" description:"Generate a new TLS certpair at startup, but only write the certificate to disk"`
 	DisableServerTLS bool                    `long:"noservertls" description:"Disable TLS for the RPC servers -- NOTE: This is only allowed if the RPC server is bound to localhost"`
-	GRPCListeners    []string                `long:"grpclisten" description:"Listen for gRPC connections on this interface/port"`
+	GRPCListeners    []string                `long:"grpclisten" description:"Listen for gRPC connections on this interface/port, or on the Unix domain socket at unix://path"`
+	UnixSocketMode   string                  `long:"unixsocketmode" description:"Permissions of Unix domain sockets gRPC listens on, in octal"`
+	ClientCAFile     string                  `long:"clientcafile" description:"Require clients to present certificates signed by the CA in this file"`
+	AuthorizedCerts  []string                `long:"authorizedclient" description:"SHA256 fingerprint of a client certificate allowed to connect (may be specified multiple times)"`
+	AdminCerts       []string                `long:"admincert" description:"SHA256 fingerprint of a client certificate allowed to use the admin service (may be specified multiple times)"`
+	MetricsListen    string                  `long:"metricslisten" description:"Serve Prometheus metrics over HTTP on this interface/port (disabled by default)"`
+	ProfileListen    string                  `long:"profilelisten" description:"Serve pprof profiling data over HTTP on this interface/port, or on localhost if only a port is given (disabled by default)"`
+	IdentityKey      *cfgutil.ExplicitString `long:"identitykey" description:"File containing the operator identity key signing epoch manifests, generated if missing"`
+	ManifestListen   string                  `long:"manifestlisten" description:"Publish signed epoch manifests over HTTPS on this interface/port (disabled by default)"`
+	AuditLog         bool                    `long:"auditlog" description:"Record fairness tests of sessions without secrets in an append-only log per epoch in the network directory, published with epoch manifests"`
+	GRPCHealth       bool                    `long:"grpchealth" description:"Serve the standard gRPC health checking service"`
+	GRPCReflection   bool                    `long:"grpcreflection" description:"Serve the gRPC server reflection service used by tools such as grpcurl"`
+	GRPCMaxMsgSize   int                     `long:"grpcmaxmsgsize" description:"Maximum size in bytes of gRPC messages received from and sent to clients"`
+	GRPCKeepalive    time.Duration           `long:"grpckeepalive" description:"Interval of pings keeping idle gRPC connections of clients alive, 0 to disable"`
+	GRPCPingTimeout  time.Duration           `long:"grpcpingtimeout" description:"Time to wait for the acknowledgement of a ping before a gRPC connection is considered lost"`
+	GRPCMinPing      time.Duration           `long:"grpcminping" description:"Minimum interval between pings accepted from clients, which are disconnected if they ping more often"`
+	RPCTimeouts      []string                `long:"rpctimeout" description:"Limit the time spent serving a request of a TumblerService method, specified as method=duration (may be specified multiple times)"`
+	RESTListen       string                  `long:"restlisten" description:"Serve a REST/JSON gateway to the gRPC services on this interface/port (disabled by default)"`
 
 	// TumbleBit specific options
-	EpochDuration    int32 `long:"epochduration" description:"Duration of a single epoch and a TumbleBit escrow"`
-	EpochRenewal     int32 `long:"epochrenewal" description:"Interval between two consecutive epochs"`
-	PuzzleDifficulty int   `long:"puzzledifficulty" description:"TumbleBit puzzle difficulty"`
+	EpochDuration        int32               `long:"epochduration" description:"Duration of a single epoch and a TumbleBit escrow (default: depends on the network)"`
+	EpochRenewal         int32               `long:"epochrenewal" description:"Interval between two consecutive epochs (default: depends on the network)"`
+	KeyRetention         int32               `long:"keyretention" description:"Number of blocks puzzle keys are retained after their epoch expires"`
+	RelativeLockTime     bool                `long:"relativelocktime" description:"Lock escrows for an epoch duration after they are mined using OP_CHECKSEQUENCEVERIFY rather than until the end of their epoch"`
+	PuzzleDifficulty     int                 `long:"puzzledifficulty" description:"TumbleBit puzzle difficulty as the size of the RSA modulus in bits"`
+	PuzzleProfile        int                 `long:"puzzleprofile" description:"TumbleBit puzzle difficulty profile in bits of security {128, 192, 256}"`
+	PuzzleScheme         string              `long:"puzzlescheme" description:"TumbleBit puzzle scheme {rsa, rsa-fdh}"`
+	PKCS11Module         string              `long:"pkcs11module" description:"Generate puzzle keys on a hardware security module through this PKCS#11 library (default: in memory)"`
+	PKCS11Token          string              `long:"pkcs11token" description:"Label of the PKCS#11 token puzzle keys are generated on (default: the first token present)"`
+	PKCS11PIN            string              `long:"pkcs11pin" default-mask:"-" description:"PIN of the user of the PKCS#11 token"`
+	DrainTimeout         time.Duration       `long:"draintimeout" description:"Time to wait for active exchanges to complete on shutdown"`
+	Parallelism          int                 `long:"parallelism" description:"Maximum number of puzzles processed concurrently for a single exchange (default: number of CPUs)"`
+	EscrowBudget         *cfgutil.AmountFlag `long:"escrowbudget" description:"Maximum amount of DCR escrowed within a single epoch (default: the spendable balance of the funding account)"`
+	ScriptPruneDepth     int32               `long:"scriptprunedepth" description:"Number of blocks the transaction resolving a contract must be buried under before its imported escrow script is pruned"`
+	RealTransactionCount int                 `long:"realtxcount" description:"Number of real transactions in the Puzzle-Promise protocol (default: depends on the network)"`
+	FakeTransactionCount int                 `long:"faketxcount" description:"Number of fake transactions in the Puzzle-Promise protocol (default: depends on the network)"`
+	RealPreimageCount    int                 `long:"realpreimagecount" description:"Number of real puzzles in the Puzzle-Solver protocol (default: depends on the network)"`
+	FakePreimageCount    int                 `long:"fakepreimagecount" description:"Number of fake puzzles in the Puzzle-Solver protocol (default: depends on the network)"`
+	FixedFee             *cfgutil.AmountFlag `long:"fixedfee" description:"Fixed commission charged for every payment in DCR"`
+	FeeRate              float64             `long:"feerate" description:"Commission charged for every payment in percent of the denomination"`
+	BatchWindow          time.Duration       `long:"batchwindow" description:"Publish cash-out transactions in batches at epoch boundaries delaying them by at most this duration (disabled by default)"`
+	BatchJitter          time.Duration       `long:"batchjitter" description:"Spread publication of batched cash-out transactions over a random delay up to this duration"`
+	FeeBumpExpiry        int32               `long:"feebumpexpiry" description:"Let fulfilling transactions expire unless mined within this number of blocks and replace them with ones paying higher fees (disabled by default)"`
+	FeeBumpFactor        float64             `long:"feebumpfactor" description:"Multiply the fee rate of replaced fulfilling transactions by this factor"`
+	MaxBumpFeeRate       *cfgutil.AmountFlag `long:"maxbumpfeerate" description:"Maximum fee rate in DCR/kB replaced fulfilling transactions pay"`
+	ClientPuzzleQuota    int                 `long:"clientpuzzlequota" description:"Maximum number of puzzles a client address may have solved for new sessions within an epoch (0 for unlimited)"`
+	SolverWorkBits       int                 `long:"solverworkbits" description:"Require a proof of work of this difficulty in bits from clients requesting solution promises for new sessions (disabled by default)"`
+	SolverConcurrency    int                 `long:"solverconcurrency" description:"Maximum number of requests for solution promises served at a time, payment channel rounds first (0 for unlimited)"`
+	SessionTimeout       time.Duration       `long:"sessiontimeout" description:"Expire sessions after this duration (default: an epoch duration and a block)"`
+	EscrowSetupTimeout   time.Duration       `long:"escrowsetuptimeout" description:"Abort exchanges whose puzzle promises aren't validated within this duration of the escrow setup (disabled by default)"`
+	PromiseTimeout       time.Duration       `long:"promisetimeout" description:"Abort exchanges without a payment offer within this duration of the solution promises (disabled by default)"`
+	OfferTimeout         time.Duration       `long:"offertimeout" description:"Abort exchanges whose payment offer isn't confirmed within this duration (default: 3 blocks)"`
+	CashOutTimeout       time.Duration       `long:"cashouttimeout" description:"Cash out payment channels within this duration of their establishment (default: at the end of the session)"`
+	ClientDelay          time.Duration       `long:"clientdelay" description:"Extend the escrow setup and promise timeouts by this random delay clients may pause for before every request"`
+
+	// Unix domain sockets split from GRPCListeners and the permissions
+	// they are created with.
+	unixListeners  []string
+	unixSocketMode os.FileMode
 }
 
 // cleanAndExpandPath expands environement variables and leading ~ in the
@@ -201,13 +311,16 @@ func parseAndSetDebugLevels(debugLevel string) error {
 //
 // The configuration proceeds as follows:
 //      1) Start with a default config with sane settings
-//      2) Pre-parse the command line to check for an alternative config file
+//      2) Pre-parse the environment and the command line to check for an
+//         alternative config file
 //      3) Load configuration file overwriting defaults with any specified options
-//      4) Parse CLI options and overwrite/add any specified options
+//      4) Apply TUMBLEBIT_* environment variables overwriting any specified options
+//      5) Parse CLI options and overwrite/add any specified options
 //
 // The above results in tumblebit functioning properly without any config
-// settings while still allowing the user to override settings with config files
-// and command line options.  Command line options always take precedence.
+// settings while still allowing the user to override settings with config
+// files, the environment and command line options.  Command line options
+// always take precedence.
 func loadConfig(ctx context.Context) (*config, []string, error) {
 	loadConfigError := func(err error) (*config, []string, error) {
 		return nil, nil, err
@@ -223,13 +336,52 @@ func loadConfig(ctx context.Context) (*config, []string, error) {
 		RPCKey:     cfgutil.NewExplicitString(defaultRPCKeyFile),
 		RPCCert:    cfgutil.NewExplicitString(defaultRPCCertFile),
 		TLSCurve:   cfgutil.NewCurveFlag(cfgutil.CurveP521),
+		FixedFee:   cfgutil.NewAmountFlag(0),
+
+		KeyRetention: tumbler.KeyRetention,
+		PuzzleScheme: puzzle.RSA.Name(),
+		DrainTimeout: tumbler.DrainTimeout,
+		EscrowBudget: cfgutil.NewAmountFlag(0),
+		IdentityKey:  cfgutil.NewExplicitString(defaultIdentityKey),
+
+		ScriptPruneDepth: tumbler.ScriptPruneDepth,
+
+		FeeBumpFactor:  tumbler.FeeBumpFactor,
+		MaxBumpFeeRate: cfgutil.NewAmountFlag(tumbler.MaxBumpFeeRate),
+
+		ClientPuzzleQuota: tumbler.ClientPuzzleQuota,
+		SolverConcurrency: tumbler.SolverConcurrency,
+
+		UnixSocketMode:  "0600",
+		GRPCMaxMsgSize:  defaultGRPCMaxMsgSize,
+		GRPCKeepalive:   defaultGRPCKeepalive,
+		GRPCPingTimeout: defaultGRPCPingTimeout,
+		GRPCMinPing:     defaultGRPCMinPing,
+		TLSCertLifetime: defaultTLSCertLifetime,
+
+		WalletRPC:       walletRPCGRPC,
+		WalletRetries:   wallet.DefaultRetries,
+		WalletBackoff:   wallet.DefaultBackoff,
+		HealthInterval:  wallet.DefaultHealthInterval,
+		Rebroadcast:     wallet.DefaultRebroadcastInterval,
+		MaxRebroadcast:  wallet.DefaultMaxRebroadcastInterval,
+		WalletKeepalive: defaultWalletKeepalive,
+
+		AddressPoolSize: wallet.DefaultAddressPoolSize,
+		GapLimit:        wallet.DefaultGapLimit,
+		GapPolicy:       wallet.GapPolicyWrap.String(),
 	}
 
-	// Pre-parse the command line options to see if an alternative config
-	// file or the version flag was specified.
+	// Pre-parse the environment and the command line options to see if an
+	// alternative config file or the version flag was specified.
 	preCfg := cfg
 	preParser := flags.NewParser(&preCfg, flags.Default)
-	_, err := preParser.Parse()
+	preParser.LongDescription = cfgutil.EnvDescription(envPrefix,
+		"walletpassword")
+	err := cfgutil.ParseEnv(preParser, envPrefix)
+	if err == nil {
+		_, err = preParser.Parse()
+	}
 	if err != nil {
 		e, ok := err.(*flags.Error)
 		if ok && e.Type == flags.ErrHelp {
@@ -252,6 +404,7 @@ func loadConfig(ctx context.Context) (*config, []string, error) {
 	// Load additional config from file.
 	var configFileError error
 	parser := flags.NewParser(&cfg, flags.Default)
+	parser.LongDescription = preParser.LongDescription
 	configFilePath := preCfg.ConfigFile.Value
 	if preCfg.ConfigFile.ExplicitlySet() {
 		configFilePath = cleanAndExpandPath(configFilePath)
@@ -271,6 +424,13 @@ func loadConfig(ctx context.Context) (*config, []string, error) {
 		configFileError = err
 	}
 
+	// Environment variables override the config file.
+	if err = cfgutil.ParseEnv(parser, envPrefix); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return loadConfigError(err)
+	}
+
 	// Parse command line options again to ensure they take precedence.
 	remainingArgs, err := parser.Parse()
 	if err != nil {
@@ -291,6 +451,10 @@ func loadConfig(ctx context.Context) (*config, []string, error) {
 		if !cfg.RPCCert.ExplicitlySet() {
 			cfg.RPCCert.Value = filepath.Join(cfg.AppDataDir.Value, "rpc.cert")
 		}
+		if !cfg.IdentityKey.ExplicitlySet() {
+			cfg.IdentityKey.Value = filepath.Join(cfg.AppDataDir.Value,
+				"identity.key")
+		}
 		if !cfg.LogDir.ExplicitlySet() {
 			cfg.LogDir.Value = filepath.Join(cfg.AppDataDir.Value, defaultLogDirname)
 		}
@@ -329,6 +493,10 @@ func loadConfig(ctx context.Context) (*config, []string, error) {
 	// Initialize log rotation.  After log rotation has been initialized, the
 	// logger variables may be used.
 	initLogRotator(filepath.Join(cfg.LogDir.Value, defaultLogFilename))
+	if cfg.JSONLog {
+		initJSONLog(filepath.Join(cfg.LogDir.Value,
+			defaultJSONLogFilename))
+	}
 
 	// Parse, validate, and set debug log level(s).
 	if err := parseAndSetDebugLevels(cfg.DebugLevel); err != nil {
@@ -354,13 +522,30 @@ func loadConfig(ctx context.Context) (*config, []string, error) {
 		log.Warnf("%v", configFileError)
 	}
 
+	walletPort := activeNet.WalletClientPort
+	switch cfg.WalletRPC {
+	case walletRPCGRPC:
+	case walletRPCJSON:
+		walletPort = activeNet.WalletJSONRPCPort
+		if cfg.WalletKeepalive != defaultWalletKeepalive {
+			log.Warnf("The --walletkeepalive option has no effect " +
+				"with JSON-RPC")
+		}
+	default:
+		str := "%s: walletrpc must be one of grpc or jsonrpc: %s"
+		err := fmt.Errorf(str, funcName, cfg.WalletRPC)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return loadConfigError(err)
+	}
+
 	if cfg.RPCConnect == "" {
-		cfg.RPCConnect = net.JoinHostPort("localhost", activeNet.WalletClientPort)
+		cfg.RPCConnect = net.JoinHostPort("localhost", walletPort)
 	}
 
 	// Add default port to connect flag if missing.
 	cfg.RPCConnect, err = cfgutil.NormalizeAddress(cfg.RPCConnect,
-		activeNet.WalletClientPort)
+		walletPort)
 	if err != nil {
 		fmt.Fprintf(os.Stderr,
 			"Invalid rpcconnect network address: %v\n", err)
@@ -414,6 +599,52 @@ func loadConfig(ctx context.Context) (*config, []string, error) {
 		}
 	}
 
+	if (cfg.WalletClientCert == "") != (cfg.WalletClientKey == "") {
+		str := "%s: --walletclientcert and --walletclientkey must be " +
+			"specified together"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return loadConfigError(err)
+	}
+	if cfg.DisableClientTLS && cfg.WalletClientCert != "" {
+		str := "%s: the --walletclientcert option requires TLS"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return loadConfigError(err)
+	}
+
+	// Split Unix domain socket listeners, which are accessible to local
+	// clients only and served without TLS, from network listeners.
+	var netListeners []string
+	for _, addr := range cfg.GRPCListeners {
+		if !strings.HasPrefix(addr, unixListenerPrefix) {
+			netListeners = append(netListeners, addr)
+			continue
+		}
+		path := strings.TrimPrefix(addr, unixListenerPrefix)
+		if path == "" {
+			err := fmt.Errorf("%s: Unix domain socket listener '%s' "+
+				"has no path", funcName, addr)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return loadConfigError(err)
+		}
+		cfg.unixListeners = append(cfg.unixListeners,
+			cleanAndExpandPath(path))
+	}
+	cfg.GRPCListeners = netListeners
+	mode, err := strconv.ParseUint(cfg.UnixSocketMode, 8, 32)
+	if err != nil || mode&^uint64(os.ModePerm) != 0 {
+		err := fmt.Errorf("%s: invalid Unix domain socket permissions "+
+			"'%s'", funcName, cfg.UnixSocketMode)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return loadConfigError(err)
+	}
+	cfg.unixSocketMode = os.FileMode(mode)
+
 	// Default to localhost listen addresses if no listeners were manually
 	// specified.  When the RPC server is configured to be disabled, remove all
 	// listeners so it is not started.
@@ -421,7 +652,7 @@ func loadConfig(ctx context.Context) (*config, []string, error) {
 	if err != nil {
 		return loadConfigError(err)
 	}
-	if len(cfg.GRPCListeners) == 0 {
+	if len(cfg.GRPCListeners) == 0 && len(cfg.unixListeners) == 0 {
 		cfg.GRPCListeners = make([]string, 0, len(localhostAddrs))
 		for _, addr := range localhostAddrs {
 			cfg.GRPCListeners = append(cfg.GRPCListeners,
@@ -439,10 +670,19 @@ func loadConfig(ctx context.Context) (*config, []string, error) {
 		return loadConfigError(err)
 	}
 
-	// Only allow server TLS to be disabled if the RPC server is bound to
-	// localhost addresses.
+	// Only allow server TLS to be disabled if the RPC server, the REST
+	// gateway and the manifest server are bound to localhost addresses.
 	if cfg.DisableServerTLS {
-		for _, addr := range cfg.GRPCListeners {
+		listeners := cfg.GRPCListeners
+		if cfg.ManifestListen != "" {
+			listeners = append(listeners[:len(listeners):len(listeners)],
+				cfg.ManifestListen)
+		}
+		if cfg.RESTListen != "" {
+			listeners = append(listeners[:len(listeners):len(listeners)],
+				cfg.RESTListen)
+		}
+		for _, addr := range listeners {
 			host, _, err := net.SplitHostPort(addr)
 			if err != nil {
 				str := "%s: RPC listen interface '%s' is " +
@@ -464,21 +704,454 @@ func loadConfig(ctx context.Context) (*config, []string, error) {
 		}
 	}
 
+	// Client certificate authentication requires TLS.
+	if cfg.ClientCAFile != "" || len(cfg.AuthorizedCerts) != 0 ||
+		len(cfg.AdminCerts) != 0 {
+		if cfg.DisableServerTLS {
+			str := "%s: client certificate authentication may not " +
+				"be used with the --noservertls option"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return loadConfigError(err)
+		}
+	}
+	for i, fp := range cfg.AuthorizedCerts {
+		cfg.AuthorizedCerts[i], err = normalizeFingerprint(fp)
+		if err != nil {
+			err := fmt.Errorf("%s: invalid client certificate "+
+				"fingerprint %q: %v", funcName, fp, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return loadConfigError(err)
+		}
+	}
+	for i, fp := range cfg.AdminCerts {
+		cfg.AdminCerts[i], err = normalizeFingerprint(fp)
+		if err != nil {
+			err := fmt.Errorf("%s: invalid admin certificate "+
+				"fingerprint %q: %v", funcName, fp, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return loadConfigError(err)
+		}
+	}
+
+	if cfg.MetricsListen != "" {
+		if _, _, err := net.SplitHostPort(cfg.MetricsListen); err != nil {
+			str := "%s: metrics listen interface '%s' is invalid: %v"
+			err := fmt.Errorf(str, funcName, cfg.MetricsListen, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return loadConfigError(err)
+		}
+	}
+	if cfg.ProfileListen != "" {
+		// Profiles reveal internals of the tumbler, so a bare port is
+		// only served to localhost.
+		if _, err := strconv.Atoi(cfg.ProfileListen); err == nil {
+			cfg.ProfileListen = net.JoinHostPort("localhost",
+				cfg.ProfileListen)
+		}
+		if _, _, err := net.SplitHostPort(cfg.ProfileListen); err != nil {
+			str := "%s: profile listen interface '%s' is invalid: %v"
+			err := fmt.Errorf(str, funcName, cfg.ProfileListen, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return loadConfigError(err)
+		}
+	}
+	if cfg.ManifestListen != "" {
+		if _, _, err := net.SplitHostPort(cfg.ManifestListen); err != nil {
+			str := "%s: manifest listen interface '%s' is invalid: %v"
+			err := fmt.Errorf(str, funcName, cfg.ManifestListen, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return loadConfigError(err)
+		}
+	}
+	if cfg.TLSCertLifetime < minTLSCertLifetime {
+		str := "%s: TLS certificate lifetime must be at least %v: %v"
+		err := fmt.Errorf(str, funcName, minTLSCertLifetime,
+			cfg.TLSCertLifetime)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return loadConfigError(err)
+	}
+	if cfg.GRPCMaxMsgSize <= 0 {
+		str := "%s: gRPC message size limit must be positive: %d"
+		err := fmt.Errorf(str, funcName, cfg.GRPCMaxMsgSize)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return loadConfigError(err)
+	}
+	if cfg.GRPCKeepalive < 0 || cfg.WalletKeepalive < 0 ||
+		cfg.GRPCPingTimeout <= 0 || cfg.GRPCMinPing < 0 {
+		str := "%s: keepalive intervals must not be negative and the " +
+			"ping timeout must be positive"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return loadConfigError(err)
+	}
+	if cfg.RESTListen != "" {
+		if _, _, err := net.SplitHostPort(cfg.RESTListen); err != nil {
+			str := "%s: REST listen interface '%s' is invalid: %v"
+			err := fmt.Errorf(str, funcName, cfg.RESTListen, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return loadConfigError(err)
+		}
+	}
+
 	// Expand environment variable and leading ~ for filepaths.
 	cfg.CAFile.Value = cleanAndExpandPath(cfg.CAFile.Value)
 	cfg.RPCCert.Value = cleanAndExpandPath(cfg.RPCCert.Value)
 	cfg.RPCKey.Value = cleanAndExpandPath(cfg.RPCKey.Value)
+	cfg.IdentityKey.Value = cleanAndExpandPath(cfg.IdentityKey.Value)
+	if cfg.ClientCAFile != "" {
+		cfg.ClientCAFile = cleanAndExpandPath(cfg.ClientCAFile)
+	}
+	if cfg.WalletClientCert != "" {
+		cfg.WalletClientCert = cleanAndExpandPath(cfg.WalletClientCert)
+		cfg.WalletClientKey = cleanAndExpandPath(cfg.WalletClientKey)
+	}
+	if cfg.OfflineSignDir != "" {
+		cfg.OfflineSignDir = cleanAndExpandPath(cfg.OfflineSignDir)
+	}
+	if cfg.EscrowMinConf < 0 {
+		err := fmt.Errorf("%s: escrowminconf must not be negative",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return loadConfigError(err)
+	}
+	if cfg.AddressPoolSize < 0 || cfg.GapLimit < 1 ||
+		cfg.AddressPoolSize > cfg.GapLimit {
+		str := "%s: addresspoolsize must not be negative and must " +
+			"not exceed the gap limit, which must be positive"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return loadConfigError(err)
+	}
+	if _, err := wallet.ParseGapPolicy(cfg.GapPolicy); err != nil {
+		err := fmt.Errorf("%s: %v", funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return loadConfigError(err)
+	}
 
 	// TumbleBit defaults
+	if cfg.PuzzleProfile != 0 {
+		profile, err := puzzle.LookupProfile(cfg.PuzzleProfile)
+		if err == nil && cfg.PuzzleDifficulty != 0 {
+			err = errors.New("puzzledifficulty and puzzleprofile " +
+				"are mutually exclusive")
+		}
+		if err != nil {
+			err := fmt.Errorf("%s: %v -- supported profiles %v",
+				funcName, err, puzzle.ProfileSecurityLevels())
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return loadConfigError(err)
+		}
+		cfg.PuzzleDifficulty = profile.ModulusBits
+	}
 	if cfg.PuzzleDifficulty == 0 {
 		cfg.PuzzleDifficulty = tumbler.PuzzleDifficulty
 	}
+	if err := puzzle.ValidateDifficulty(cfg.PuzzleDifficulty); err != nil {
+		err := fmt.Errorf("%s: %v", funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return loadConfigError(err)
+	}
 	if cfg.EpochDuration == 0 {
-		cfg.EpochDuration = tumbler.EpochDuration
+		cfg.EpochDuration = activeNet.EpochDuration
 	}
 	if cfg.EpochRenewal == 0 {
-		cfg.EpochRenewal = tumbler.EpochRenewal
+		cfg.EpochRenewal = activeNet.EpochRenewal
+	}
+	if cfg.EpochDuration < 0 || cfg.EpochRenewal < 0 ||
+		cfg.EpochRenewal > cfg.EpochDuration {
+		err := fmt.Errorf("%s: epochrenewal must be positive and not "+
+			"exceed epochduration", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return loadConfigError(err)
+	}
+	if cfg.RelativeLockTime && cfg.EpochDuration > contract.MaxRelativeLockTime {
+		err := fmt.Errorf("%s: epochduration must not exceed %d blocks "+
+			"with relative locktimes", funcName,
+			contract.MaxRelativeLockTime)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return loadConfigError(err)
+	}
+	if cfg.Parallelism < 0 {
+		err := fmt.Errorf("%s: parallelism must not be negative",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return loadConfigError(err)
+	}
+	if cfg.EscrowBudget.Amount < 0 {
+		err := fmt.Errorf("%s: escrowbudget must not be negative",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return loadConfigError(err)
+	}
+	if cfg.ScriptPruneDepth < 1 {
+		err := fmt.Errorf("%s: scriptprunedepth must be positive",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return loadConfigError(err)
+	}
+	if _, err := puzzle.LookupScheme(cfg.PuzzleScheme); err != nil {
+		err := fmt.Errorf("%s: %v -- supported schemes %v", funcName,
+			err, puzzle.SchemeNames())
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return loadConfigError(err)
+	}
+	if cfg.PKCS11Module == "" &&
+		(cfg.PKCS11Token != "" || cfg.PKCS11PIN != "") {
+		err := fmt.Errorf("%s: pkcs11token and pkcs11pin require "+
+			"pkcs11module", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return loadConfigError(err)
+	}
+	if cfg.PKCS11Module != "" {
+		cfg.PKCS11Module = cleanAndExpandPath(cfg.PKCS11Module)
+	}
+	if cfg.RealTransactionCount == 0 {
+		cfg.RealTransactionCount = activeNet.RealTransactionCount
+	}
+	if cfg.FakeTransactionCount == 0 {
+		cfg.FakeTransactionCount = activeNet.FakeTransactionCount
+	}
+	if cfg.RealPreimageCount == 0 {
+		cfg.RealPreimageCount = activeNet.RealPreimageCount
+	}
+	if cfg.FakePreimageCount == 0 {
+		cfg.FakePreimageCount = activeNet.FakePreimageCount
+	}
+	if err := cfg.feePolicy().Validate(); err != nil {
+		err := fmt.Errorf("%s: invalid fee policy: %v", funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return loadConfigError(err)
+	}
+	if err := cfg.batchPolicy().Validate(); err != nil {
+		err := fmt.Errorf("%s: invalid batching policy: %v", funcName,
+			err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return loadConfigError(err)
+	}
+	if err := cfg.feeBumpPolicy().Validate(); err != nil {
+		err := fmt.Errorf("%s: invalid fee bumping policy: %v",
+			funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return loadConfigError(err)
+	}
+	if err := cfg.solverPolicy().Validate(); err != nil {
+		err := fmt.Errorf("%s: invalid solver policy: %v", funcName,
+			err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return loadConfigError(err)
+	}
+	if err := cfg.deadlines().Validate(); err != nil {
+		err := fmt.Errorf("%s: invalid session deadlines: %v",
+			funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return loadConfigError(err)
+	}
+	if err := cfg.parameters().ValidateWith(activeNet.MinSecurityBits); err != nil {
+		err := fmt.Errorf("%s: invalid protocol parameters: %v",
+			funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return loadConfigError(err)
+	}
+
+	if _, err := cfg.rpcTimeouts(); err != nil {
+		err := fmt.Errorf("%s: invalid --rpctimeout: %v", funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return loadConfigError(err)
+	}
+
+	if err := cfg.loadSecrets(); err != nil {
+		err := fmt.Errorf("%s: %v", funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return loadConfigError(err)
+	}
+
+	// Creating the account derives its keys and requires the wallet
+	// passphrase.
+	if cfg.CreateAccount {
+		if cfg.AccountName == "" {
+			cfg.AccountName = defaultAccountName
+		}
+		if cfg.WalletPassword == "" {
+			str := "%s: --createaccount requires the wallet passphrase"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return loadConfigError(err)
+		}
+	}
+	if cfg.UnlockAccounts && cfg.WalletPassword == "" {
+		str := "%s: --unlockaccounts requires the wallet passphrase"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return loadConfigError(err)
 	}
 
 	return &cfg, remainingArgs, nil
 }
+
+// loadSecrets obtains the wallet passphrase from the encrypted secrets file
+// or prompts for it as requested.  With --createsecrets the secrets file is
+// created instead and the process exits.
+func (cfg *config) loadSecrets() error {
+	if cfg.SecretsFile != "" {
+		cfg.SecretsFile = cleanAndExpandPath(cfg.SecretsFile)
+	}
+	switch {
+	case cfg.CreateSecrets && cfg.SecretsFile == "":
+		return errors.New("--createsecrets requires --secretsfile")
+	case cfg.PromptPass && cfg.SecretsFile != "":
+		return errors.New("--promptpass and --secretsfile can't be " +
+			"used together")
+	case cfg.CreateSecrets:
+		if err := secrets.Create(cfg.SecretsFile, cfg.WalletPassword); err != nil {
+			return err
+		}
+		fmt.Printf("Created secrets file %s\n", cfg.SecretsFile)
+		os.Exit(0)
+	case cfg.PromptPass:
+		pass, err := secrets.Prompt("Wallet passphrase: ")
+		if err != nil {
+			return err
+		}
+		cfg.WalletPassword = string(pass)
+	case cfg.SecretsFile != "" && cfg.WalletPassword == "":
+		s, err := secrets.Load(cfg.SecretsFile)
+		if err != nil {
+			return err
+		}
+		cfg.WalletPassword = s.WalletPassword
+	}
+	return nil
+}
+
+// parameters returns TumbleBit protocol parameters specified in the
+// configuration.
+func (cfg *config) parameters() *tumbler.Parameters {
+	return &tumbler.Parameters{
+		RealTransactionCount: cfg.RealTransactionCount,
+		FakeTransactionCount: cfg.FakeTransactionCount,
+		RealPreimageCount:    cfg.RealPreimageCount,
+		FakePreimageCount:    cfg.FakePreimageCount,
+	}
+}
+
+// feePolicy returns the commission policy specified by the config.
+func (cfg *config) feePolicy() *tumbler.FeePolicy {
+	return &tumbler.FeePolicy{
+		Fixed: contract.Amount(cfg.FixedFee.Amount),
+		Rate:  cfg.FeeRate,
+	}
+}
+
+// rpcTimeouts parses timeouts of TumblerService methods specified in the
+// config.
+func (cfg *config) rpcTimeouts() (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration, len(cfg.RPCTimeouts))
+	for _, t := range cfg.RPCTimeouts {
+		parts := strings.SplitN(t, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%q is not in the method=duration "+
+				"format", t)
+		}
+		method := strings.TrimSpace(parts[0])
+		if _, ok := rpcserver.DefaultTimeouts[method]; !ok {
+			return nil, fmt.Errorf("unknown method %q, timeouts "+
+				"apply to %v", method, rpcserver.TimeoutMethods())
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, err
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("timeout of %s must be positive",
+				method)
+		}
+		timeouts[method] = d
+	}
+	return timeouts, nil
+}
+
+// batchPolicy returns the cash-out batching policy specified by the config.
+func (cfg *config) batchPolicy() *tumbler.BatchPolicy {
+	return &tumbler.BatchPolicy{
+		Window: cfg.BatchWindow,
+		Jitter: cfg.BatchJitter,
+	}
+}
+
+// feeBumpPolicy returns the policy of replacing fulfilling transactions
+// specified by the config.
+func (cfg *config) feeBumpPolicy() *tumbler.FeeBumpPolicy {
+	return &tumbler.FeeBumpPolicy{
+		Expiry:     cfg.FeeBumpExpiry,
+		Factor:     cfg.FeeBumpFactor,
+		MaxFeeRate: int64(cfg.MaxBumpFeeRate.Amount),
+	}
+}
+
+// solverPolicy returns the limits of requests for solution promises
+// specified by the config.
+func (cfg *config) solverPolicy() *tumbler.SolverPolicy {
+	return &tumbler.SolverPolicy{
+		ClientQuota: cfg.ClientPuzzleQuota,
+		WorkBits:    cfg.SolverWorkBits,
+		Concurrency: cfg.SolverConcurrency,
+	}
+}
+
+// keyProvider returns the provider generating puzzle keys selected by the
+// config, which must be closed once the tumbler has stopped.
+func (cfg *config) keyProvider() (puzzle.KeyProvider, error) {
+	if cfg.PKCS11Module == "" {
+		return puzzle.MemoryProvider, nil
+	}
+	return puzzle.NewPKCS11Provider(&puzzle.PKCS11Config{
+		Module:     cfg.PKCS11Module,
+		TokenLabel: cfg.PKCS11Token,
+		PIN:        cfg.PKCS11PIN,
+	})
+}
+
+// deadlines returns the session deadlines specified by the config.
+func (cfg *config) deadlines() *tumbler.Deadlines {
+	return &tumbler.Deadlines{
+		Session:           cfg.SessionTimeout,
+		EscrowSetup:       cfg.EscrowSetupTimeout,
+		PromiseValidation: cfg.PromiseTimeout,
+		OfferConfirmation: cfg.OfferTimeout,
+		CashOut:           cfg.CashOutTimeout,
+		ClientDelay:       cfg.ClientDelay,
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package contract
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/decred/dcrd/dcrutil"
+)
+
+// amountDecimals is the number of decimal places of a coin amount in atoms.
+const amountDecimals = 8
+
+// Amount is a quantity of coins in atoms. Contract amounts, commissions
+// and channel balances are amounts rather than plain integers, so that
+// they aren't mixed up with other quantities. Atoms received over RPC or
+// loaded from storage are converted with AmountFromAtoms, which rejects
+// values no transaction could carry.
+type Amount dcrutil.Amount
+
+// AmountFromAtoms converts the number of atoms to an amount. Negative
+// numbers and numbers exceeding the coin supply are rejected.
+func AmountFromAtoms(atoms int64) (Amount, error) {
+	a := Amount(atoms)
+	if err := a.Validate(); err != nil {
+		return 0, err
+	}
+	return a, nil
+}
+
+// NewAmount converts the number of coins to an amount rounded to the
+// nearest atom.
+func NewAmount(coins float64) (Amount, error) {
+	a, err := dcrutil.NewAmount(coins)
+	if err != nil {
+		return 0, err
+	}
+	return AmountFromAtoms(int64(a))
+}
+
+// ParseAmount parses the decimal number of coins, optionally followed by
+// the DCR unit. The number is parsed exactly rather than as a floating
+// point number, and more decimal places than atoms can represent are
+// rejected instead of being rounded away.
+func ParseAmount(s string) (Amount, error) {
+	num := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "DCR"))
+	whole, frac := num, ""
+	if i := strings.IndexByte(num, '.'); i >= 0 {
+		whole, frac = num[:i], num[i+1:]
+	}
+	if whole+frac == "" || !isDigits(whole) || !isDigits(frac) {
+		return 0, fmt.Errorf("malformed amount %q", s)
+	}
+	if len(frac) > amountDecimals {
+		return 0, fmt.Errorf("amount %q has more than %d decimal places",
+			s, amountDecimals)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	coins, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || coins > int64(dcrutil.MaxAmount/dcrutil.AtomsPerCoin) {
+		return 0, fmt.Errorf("amount %q exceeds the coin supply", s)
+	}
+	atoms, err := strconv.ParseInt(frac+strings.Repeat("0",
+		amountDecimals-len(frac)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("malformed amount %q", s)
+	}
+	return AmountFromAtoms(coins*dcrutil.AtomsPerCoin + atoms)
+}
+
+// isDigits returns true if the string consists of decimal digits only.
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate makes sure the amount is neither negative nor exceeds the coin
+// supply.
+func (a Amount) Validate() error {
+	if a < 0 || a > Amount(dcrutil.MaxAmount) {
+		return fmt.Errorf("amount of %d atoms is out of range", int64(a))
+	}
+	return nil
+}
+
+// Add returns the sum of the amounts. The sum must not exceed the coin
+// supply.
+func (a Amount) Add(b Amount) (Amount, error) {
+	if err := a.Validate(); err != nil {
+		return 0, err
+	}
+	if err := b.Validate(); err != nil {
+		return 0, err
+	}
+	return AmountFromAtoms(int64(a) + int64(b))
+}
+
+// Sub returns the difference of the amounts. The difference must not be
+// negative.
+func (a Amount) Sub(b Amount) (Amount, error) {
+	if err := a.Validate(); err != nil {
+		return 0, err
+	}
+	if err := b.Validate(); err != nil {
+		return 0, err
+	}
+	return AmountFromAtoms(int64(a) - int64(b))
+}
+
+// Atoms returns the amount in atoms.
+func (a Amount) Atoms() int64 {
+	return int64(a)
+}
+
+// ToCoin returns the amount in coins.
+func (a Amount) ToCoin() float64 {
+	return dcrutil.Amount(a).ToCoin()
+}
+
+// Format formats the amount in the specified unit.
+func (a Amount) Format(u dcrutil.AmountUnit) string {
+	return dcrutil.Amount(a).Format(u)
+}
+
+// String formats the amount in coins.
+func (a Amount) String() string {
+	return dcrutil.Amount(a).String()
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package contract
+
+import (
+	"testing"
+
+	"github.com/decred/dcrd/dcrutil"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		s      string
+		amount Amount
+		valid  bool
+	}{
+		{"1", dcrutil.AtomsPerCoin, true},
+		{"1 DCR", dcrutil.AtomsPerCoin, true},
+		{"0.1", dcrutil.AtomsPerCoin / 10, true},
+		{".5", dcrutil.AtomsPerCoin / 2, true},
+		{"2.", 2 * dcrutil.AtomsPerCoin, true},
+		{"0.00000001", 1, true},
+		{"20999999.99999999", Amount(dcrutil.MaxAmount - 1), true},
+		{"21000000", Amount(dcrutil.MaxAmount), true},
+		{"0.000000001", 0, false},
+		{"21000000.00000001", 0, false},
+		{"99999999999999999999", 0, false},
+		{"-1", 0, false},
+		{"1e8", 0, false},
+		{"1.2.3", 0, false},
+		{".", 0, false},
+		{"", 0, false},
+	}
+	for _, test := range tests {
+		amount, err := ParseAmount(test.s)
+		if (err == nil) != test.valid {
+			t.Errorf("%q: unexpected result %v", test.s, err)
+			continue
+		}
+		if amount != test.amount {
+			t.Errorf("%q: amount %d, expected %d", test.s, amount,
+				test.amount)
+		}
+	}
+}
+
+func TestAmountFromAtoms(t *testing.T) {
+	if _, err := AmountFromAtoms(-1); err == nil {
+		t.Error("negative amount accepted")
+	}
+	if _, err := AmountFromAtoms(dcrutil.MaxAmount + 1); err == nil {
+		t.Error("amount exceeding the coin supply accepted")
+	}
+	if _, err := Denomination.Sub(Denomination + 1); err == nil {
+		t.Error("negative difference accepted")
+	}
+	sum, err := Denomination.Add(Denomination)
+	if err != nil || sum != 2*Denomination {
+		t.Errorf("sum %d, expected %d: %v", sum, 2*Denomination, err)
+	}
+}
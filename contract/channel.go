@@ -0,0 +1,108 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package contract
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/decred/dcrd/chaincfg"
+)
+
+var (
+	// ErrChannelExhausted is returned when a payment is attempted on a
+	// channel that doesn't have enough funds left.
+	ErrChannelExhausted = errors.New("channel capacity exhausted")
+)
+
+// Channel keeps track of a payment channel where a single escrow funded
+// by the payer is used to pay for multiple puzzle solutions over the
+// course of an epoch. Every payment transfers one contract denomination
+// along with the receiver's commission; the accumulated balance is cashed
+// out at once.
+type Channel struct {
+	EscrowHash []byte // Hash of the transaction funding the channel
+	Capacity   Amount // Total amount escrowed by the payer
+	Fee        Amount // Commission charged for every payment
+	Balance    Amount // Amount transferred to the receiver so far
+	Payments   int    // Number of completed payments
+}
+
+// NewChannel creates a new payment channel able to carry the specified
+// capacity which must be a multiple of the contract denomination plus
+// the commission charged for every payment.
+func NewChannel(escrowHash []byte, capacity, fee Amount) (*Channel, error) {
+	if fee < 0 || fee >= contractValue {
+		return nil, fmt.Errorf("attempted channel fee: %v", fee)
+	}
+	if capacity <= 0 || capacity.Validate() != nil ||
+		capacity%(contractValue+fee) != 0 {
+		return nil, fmt.Errorf("attempted channel capacity: %v", capacity)
+	}
+	ch := &Channel{
+		EscrowHash: escrowHash,
+		Capacity:   capacity,
+		Fee:        fee,
+	}
+	return ch, nil
+}
+
+// Pay transfers a single contract denomination and the commission over
+// the channel.
+func (ch *Channel) Pay(amount Amount) error {
+	if amount != contractValue+ch.Fee {
+		return fmt.Errorf("attempted payment amount: %v", amount)
+	}
+	if ch.Balance+amount > ch.Capacity {
+		return ErrChannelExhausted
+	}
+	ch.Balance += amount
+	ch.Payments++
+	return nil
+}
+
+// Remaining returns the amount that can still be transferred.
+func (ch *Channel) Remaining() Amount {
+	return ch.Capacity - ch.Balance
+}
+
+// Exhausted returns true if no more payments can be made.
+func (ch *Channel) Exhausted() bool {
+	return ch.Remaining() < contractValue+ch.Fee
+}
+
+func (ch *Channel) String() string {
+	return fmt.Sprintf("Channel{ hash=%x capacity=%d fee=%d balance=%d "+
+		"payments=%d }", ch.EscrowHash, ch.Capacity, ch.Fee, ch.Balance,
+		ch.Payments)
+}
+
+// NewChannelEscrow creates a contract template describing the escrow
+// funding the channel. It's used to validate the funding transaction.
+func NewChannelEscrow(chainParams *chaincfg.Params, ch *Channel, lockTime int32) *Contract {
+	return &Contract{
+		Amount:      ch.Capacity,
+		ChainParams: chainParams,
+		LockTime:    lockTime,
+		Channel:     ch,
+	}
+}
+
+// NewChannelPayment creates a contract template for the offer spending
+// the channel escrow. Unlike the contract returned by New, its amount is
+// the cumulative balance transferred over the channel, while remaining
+// funds are returned to the sender upon redemption.
+func NewChannelPayment(chainParams *chaincfg.Params, ch *Channel, lockTime int32) (*Contract, error) {
+	if ch.Balance == 0 {
+		return nil, errors.New("no payments were made over the channel")
+	}
+	c := &Contract{
+		Amount:      ch.Balance,
+		ChainParams: chainParams,
+		LockTime:    lockTime,
+		Channel:     ch,
+	}
+	return c, nil
+}
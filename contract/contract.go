@@ -7,24 +7,29 @@
 package contract
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 
 	"github.com/decred/dcrd/chaincfg"
-	"github.com/decred/dcrd/chaincfg/chainec"
 	"github.com/decred/dcrd/dcrutil"
 	"github.com/decred/dcrd/wire"
+	"github.com/decred/tumblebit/chain"
+	"github.com/decred/tumblebit/chain/decred"
 )
 
 const (
 	// This overrides all contract amount values until we support multiple
 	// or arbitrary denominations.
-	contractValue = dcrutil.AtomsPerCoin // One buck.
+	contractValue Amount = dcrutil.AtomsPerCoin // One buck.
 
 	// Add more information when printing out the contract.
 	verbosePrintout = true
 )
 
+// Denomination is the amount of every contract.
+const Denomination = contractValue
+
 type addressRole int
 
 const (
@@ -35,14 +40,20 @@ const (
 	MaxAddressRole
 )
 
-type addressType int
+const (
+	PayToEdwardsPubKey    = chain.PayToEdwardsPubKey
+	PayToPubKey           = chain.PayToPubKey
+	PayToPubKeyHash       = chain.PayToPubKeyHash
+	PayToSecSchnorrPubKey = chain.PayToSecSchnorrPubKey
+	PayToScriptHash       = chain.PayToScriptHash
+)
+
+type transactionType int
 
 const (
-	PayToEdwardsPubKey addressType = 1 << iota
-	PayToPubKey
-	PayToPubKeyHash
-	PayToSecSchnorrPubKey
-	PayToScriptHash
+	EscrowTransaction transactionType = iota
+	RefundTransaction
+	RedeemTransaction
 )
 
 var addressName = [...]string{
@@ -55,17 +66,17 @@ var addressName = [...]string{
 // Contract structure represents the contract associated with a client.
 type Contract struct {
 	// Generic sender and receiver of funds.
-	SenderAddr         dcrutil.Address
+	SenderAddr         chain.Address
 	SenderAddrStr      string
 	SenderScriptAddr   []byte
-	ReceiverAddr       dcrutil.Address
+	ReceiverAddr       chain.Address
 	ReceiverAddrStr    string
 	ReceiverScriptAddr []byte
 
 	// Escrow set up by the tumbler or the client.
 	EscrowTx        *wire.MsgTx
 	EscrowBytes     []byte
-	EscrowAddr      dcrutil.Address // P2SH address
+	EscrowAddr      chain.Address // P2SH address
 	EscrowAddrStr   string
 	EscrowPayScript []byte
 	EscrowScript    []byte
@@ -75,34 +86,73 @@ type Contract struct {
 	// Refunding transaction used with an escrow that tumbler sets up.
 	RefundTx         *wire.MsgTx
 	RefundBytes      []byte
-	RefundAddr       dcrutil.Address
+	RefundAddr       chain.Address
 	RefundAddrStr    string
 	RefundScript     []byte
 	RefundScriptAddr []byte
 	RefundSig        []byte
 	RefundHash       []byte
 
+	// RefundPending is set while the refund signature awaits an offline
+	// signer. The escrow must not be published until it's cleared.
+	RefundPending bool
+
 	// Fulfill the offer transaction and redeem escrowed funds.
 	RedeemTx         *wire.MsgTx
 	RedeemBytes      []byte
-	RedeemAddr       dcrutil.Address
+	RedeemAddr       chain.Address
 	RedeemAddrStr    string
 	RedeemScript     []byte
 	RedeemScriptAddr []byte
 	RedeemSig        []byte
 	RedeemHash       []byte
 
-	Amount      int64
+	// FeeRate is the fee rate in atoms per kilobyte paid by the redeeming
+	// transaction, FeePerKb if it's zero.
+	FeeRate int64
+
+	// RedeemExpiry is the block height after which the redeeming
+	// transaction expires unless it has been mined, so that it may be
+	// replaced with one paying a higher fee. It never expires if zero.
+	RedeemExpiry uint32
+
+	Amount      Amount
 	LockTime    int32
+	LockType    LockType
+	HashLock    HashLock
 	ChainParams *chaincfg.Params
+
+	// Chain backend composing scripts and addresses. The Decred backend
+	// for ChainParams is used when it's not set.
+	Chain chain.Backend
+
+	// Payment channel the contract belongs to, if any.
+	Channel *Channel
 }
 
+// LockType selects how the locktime of a contract is enforced.
+type LockType uint8
+
+const (
+	// AbsoluteLock contracts may be refunded once the blockchain reaches
+	// the height specified by the locktime.
+	AbsoluteLock LockType = iota
+
+	// RelativeLock contracts may be refunded once the escrow has been
+	// confirmed for the number of blocks specified by the locktime.
+	RelativeLock
+)
+
+// MaxRelativeLockTime is the largest number of blocks a relative locktime
+// may span.
+const MaxRelativeLockTime = wire.SequenceLockTimeMask
+
 // New creates a new contract template that can be either refunded by
 // refundAddr or redeemed by redeemAddr for a specified amount and after
 // the specified locktime.
-func New(chainParams *chaincfg.Params, amount int64, lockTime int32) (*Contract, error) {
+func New(chainParams *chaincfg.Params, amount Amount, lockTime int32) (*Contract, error) {
 	if amount != contractValue {
-		return nil, fmt.Errorf("attempted contract amount: %d", amount)
+		return nil, fmt.Errorf("attempted contract amount: %v", amount)
 	}
 	c := &Contract{
 		Amount:      contractValue,
@@ -112,6 +162,20 @@ func New(chainParams *chaincfg.Params, amount int64, lockTime int32) (*Contract,
 	return c, nil
 }
 
+// NewOffer creates a contract template for an offer paying the contract
+// denomination along with the specified commission of the tumbler.
+func NewOffer(chainParams *chaincfg.Params, fee Amount, lockTime int32) (*Contract, error) {
+	if fee < 0 || fee >= contractValue {
+		return nil, fmt.Errorf("attempted offer fee: %v", fee)
+	}
+	c := &Contract{
+		Amount:      contractValue + fee,
+		ChainParams: chainParams,
+		LockTime:    lockTime,
+	}
+	return c, nil
+}
+
 // SetAddress sets an address in the contract according to the role
 // specified by the address type. It panics when called with an incorrect
 // address type, otherwise address is decoded and verified to be valid in
@@ -121,17 +185,14 @@ func (c *Contract) SetAddress(t addressRole, a, pk string) error {
 		panic("unknown address role")
 	}
 
-	addr, err := dcrutil.DecodeAddress(pk)
+	codec := c.chain()
+	addr, err := codec.DecodeAddress(pk)
 	if err != nil {
 		return fmt.Errorf("failed to decode %s pubkey: %v",
 			addressName[t], err)
 	}
-	if !addr.IsForNet(c.ChainParams) {
-		return fmt.Errorf("address %v is not intended for use on %v", a,
-			c.ChainParams.Name)
-	}
 
-	check, err := dcrutil.DecodeAddress(a)
+	check, err := codec.DecodeAddress(a)
 	if err != nil {
 		return fmt.Errorf("failed to decode %s address: %v",
 			addressName[t], err)
@@ -145,7 +206,7 @@ func (c *Contract) SetAddress(t addressRole, a, pk string) error {
 	case ReceiverAddress:
 		// Addresses must have an associated secp256k1 private key and
 		// therefore must be P2PK or P2PKH (P2SH is not allowed).
-		if !checkAddressType(check, PayToPubKey|PayToPubKeyHash) {
+		if !c.checkAddressType(check, PayToPubKey|PayToPubKeyHash) {
 			return fmt.Errorf("address %v is not a secp256k1 P2PK "+
 				"or P2PKH", a)
 		}
@@ -154,7 +215,7 @@ func (c *Contract) SetAddress(t addressRole, a, pk string) error {
 		c.ReceiverScriptAddr = addr.ScriptAddress()
 	case RedeemAddress:
 		// Make sure the refund address is P2PKH
-		if !checkAddressType(check, PayToPubKeyHash) {
+		if !c.checkAddressType(check, PayToPubKeyHash) {
 			return fmt.Errorf("address %v is not P2PKH", a)
 		}
 		c.RedeemAddr = addr
@@ -162,7 +223,7 @@ func (c *Contract) SetAddress(t addressRole, a, pk string) error {
 		c.RedeemScriptAddr = addr.ScriptAddress()
 	case RefundAddress:
 		// Make sure the refund address is P2PKH
-		if !checkAddressType(check, PayToPubKeyHash) {
+		if !c.checkAddressType(check, PayToPubKeyHash) {
 			return fmt.Errorf("address %v is not a secp256k1 P2PKH",
 				a)
 		}
@@ -172,7 +233,7 @@ func (c *Contract) SetAddress(t addressRole, a, pk string) error {
 	case SenderAddress:
 		// Addresses must have an associated secp256k1 private key and
 		// therefore must be P2PK or P2PKH (P2SH is not allowed).
-		if !checkAddressType(check, PayToPubKey|PayToPubKeyHash) {
+		if !c.checkAddressType(check, PayToPubKey|PayToPubKeyHash) {
 			return fmt.Errorf("address %v is not a secp256k1 P2PK "+
 				"or P2PKH", a)
 		}
@@ -185,28 +246,38 @@ func (c *Contract) SetAddress(t addressRole, a, pk string) error {
 	return nil
 }
 
-func checkAddressType(addr dcrutil.Address, allowed addressType) bool {
-	var found addressType
-	switch a := addr.(type) {
-	case *dcrutil.AddressEdwardsPubKey:
-		found = PayToEdwardsPubKey
-	case *dcrutil.AddressSecpPubKey:
-		found = PayToPubKey
-	case *dcrutil.AddressPubKeyHash:
-		if a.DSA(a.Net()) == chainec.ECTypeSecp256k1 {
-			found = PayToPubKeyHash
-		}
-	case *dcrutil.AddressSecSchnorrPubKey:
-		found = PayToSecSchnorrPubKey
-	case *dcrutil.AddressScriptHash:
-		found = PayToScriptHash
+// ParseTransaction decodes a serialized transaction obtained from the
+// blockchain and records it in the contract according to its type.
+func (c *Contract) ParseTransaction(t transactionType, txBytes []byte) error {
+	var tx wire.MsgTx
+	if err := tx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+		return fmt.Errorf("failed to deserialize tx: %v", err)
+	}
+	hash := tx.TxHash()
+
+	switch t {
+	case EscrowTransaction:
+		c.EscrowTx, c.EscrowBytes, c.EscrowHash = &tx, txBytes, hash[:]
+	case RefundTransaction:
+		c.RefundTx, c.RefundBytes, c.RefundHash = &tx, txBytes, hash[:]
+	case RedeemTransaction:
+		c.RedeemTx, c.RedeemBytes, c.RedeemHash = &tx, txBytes, hash[:]
 	default:
-		return false
+		panic("unknown transaction type")
 	}
-	if found&allowed != 0 {
-		return true
+	return nil
+}
+
+// chain returns the chain backend of the contract.
+func (c *Contract) chain() chain.Backend {
+	if c.Chain == nil {
+		c.Chain = decred.New(c.ChainParams)
 	}
-	return false
+	return c.Chain
+}
+
+func (c *Contract) checkAddressType(addr chain.Address, allowed chain.AddressType) bool {
+	return c.chain().AddressType(addr)&allowed != 0
 }
 
 func (c *Contract) String() string {
@@ -270,8 +341,9 @@ func (c *Contract) String() string {
 		if verbosePrintout && len(c.RedeemBytes) > 0 {
 			str += fmt.Sprintf("txlen=%d ", len(c.RedeemBytes))
 		}
+		// The redeem script reveals preimages of the offer.
 		if verbosePrintout && len(c.RedeemScript) > 0 {
-			str += fmt.Sprintf("scriptlen=%d ", len(c.RedeemScript))
+			str += fmt.Sprintf("script=%v ", Secret(c.RedeemScript))
 		}
 		str += "} "
 	}
@@ -281,6 +353,12 @@ func (c *Contract) String() string {
 	if c.LockTime > 0 {
 		str += fmt.Sprintf("locktime=%d ", c.LockTime)
 	}
+	if c.LockType == RelativeLock {
+		str += "relative "
+	}
+	if c.Channel != nil {
+		str += c.Channel.String() + " "
+	}
 	str += "}"
 	return str
 }
@@ -0,0 +1,157 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package contract
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/decred/dcrd/txscript"
+	"github.com/decred/dcrd/wire"
+)
+
+// Spending paths of contract scripts.
+const (
+	redeemPath = "redeem"
+	refundPath = "refund"
+)
+
+// VerboseDiagnostics makes script failures include the stacks of the script
+// engine and the disassembly of the failing script. It must be set before
+// contracts are verified.
+var VerboseDiagnostics bool
+
+// scriptNames names the scripts executed when a contract output is spent
+// by their index in the script engine.
+var scriptNames = [...]string{
+	"signature script",
+	"escrow output script",
+	"contract script",
+}
+
+// ScriptFailure describes where the script engine has failed to verify the
+// transaction spending the contract.
+type ScriptFailure struct {
+	// Path is the spending path of the contract, redeem or refund.
+	Path string
+	// Script names the failing script, empty if the engine couldn't
+	// be started or the final stack is invalid.
+	Script string
+	// Opcode is the disassembled opcode that has failed along with its
+	// offset in the script, if any.
+	Opcode string
+	Err    error
+
+	// Stack and AltStack are the stacks of the engine before the failing
+	// opcode was executed, or once execution has completed, with the
+	// top item last. Disasm is the disassembly of the failing script.
+	// They are only captured with VerboseDiagnostics.
+	Stack    [][]byte
+	AltStack [][]byte
+	Disasm   string
+}
+
+func (f *ScriptFailure) Error() string {
+	var s string
+	switch {
+	case f.Script == "":
+		s = fmt.Sprintf("%s path: %v", f.Path, f.Err)
+	case f.Opcode == "":
+		s = fmt.Sprintf("%s path: %s: %v", f.Path, f.Script, f.Err)
+	default:
+		s = fmt.Sprintf("%s path: %s at %s: %v", f.Path, f.Script,
+			f.Opcode, f.Err)
+	}
+	if f.Stack != nil || f.AltStack != nil {
+		s += fmt.Sprintf(" (stack %s, alt stack %s)",
+			FormatStack(f.Stack), FormatStack(f.AltStack))
+	}
+	if f.Disasm != "" {
+		s += fmt.Sprintf(" in script %q", f.Disasm)
+	}
+	return s
+}
+
+// FormatStack formats the stack items as hex strings, with the top item
+// last.
+func FormatStack(stack [][]byte) string {
+	items := make([]string, len(stack))
+	for i, item := range stack {
+		items[i] = hex.EncodeToString(item)
+	}
+	return "[" + strings.Join(items, " ") + "]"
+}
+
+// execute runs the script engine over the transaction spending the
+// output script step by step, so that failures are reported along with
+// the failing script and opcode, and with VerboseDiagnostics the stacks
+// and the disassembly of the script.
+func execute(pkScript []byte, spendTx *wire.MsgTx, path string) error {
+	e, err := txscript.NewEngine(pkScript, spendTx, 0, verifyFlags,
+		txscript.DefaultScriptVersion, txscript.NewSigCache(10))
+	if err != nil {
+		return &ScriptFailure{Path: path, Err: err}
+	}
+	verbose := VerboseDiagnostics
+	for done := false; !done; {
+		// The opcode is disassembled and the stacks are captured
+		// before it's executed.
+		pc, _ := e.DisasmPC()
+		var stack, altStack [][]byte
+		if verbose {
+			stack, altStack = e.GetStack(), e.GetAltStack()
+		}
+		done, err = e.Step()
+		if err != nil {
+			idx, opcode := parsePC(pc)
+			f := &ScriptFailure{
+				Path:     path,
+				Script:   scriptName(idx),
+				Opcode:   opcode,
+				Err:      err,
+				Stack:    stack,
+				AltStack: altStack,
+			}
+			if verbose && idx >= 0 {
+				f.Disasm, _ = e.DisasmScript(idx)
+			}
+			return f
+		}
+	}
+	if err = e.CheckErrorCondition(true); err != nil {
+		f := &ScriptFailure{Path: path, Err: err}
+		if verbose {
+			f.Stack, f.AltStack = e.GetStack(), e.GetAltStack()
+		}
+		return f
+	}
+	return nil
+}
+
+// parsePC splits the program counter disassembled by the engine, which is
+// formatted as script index:offset: opcode, into the index of the script,
+// -1 if it can't be parsed, and the offset along with the opcode.
+func parsePC(pc string) (int, string) {
+	parts := strings.SplitN(pc, ":", 2)
+	if len(parts) != 2 {
+		return -1, ""
+	}
+	idx, err := strconv.ParseUint(parts[0], 16, 8)
+	if err != nil {
+		return -1, strings.TrimSpace(parts[1])
+	}
+	return int(idx), strings.TrimSpace(parts[1])
+}
+
+// scriptName returns the name of the script executed by the engine at the
+// index.
+func scriptName(idx int) string {
+	if idx < 0 || idx >= len(scriptNames) {
+		return "unknown script"
+	}
+	return scriptNames[idx]
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package contract
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/decred/dcrd/dcrutil"
+	"github.com/decred/dcrd/txscript"
+	"github.com/decred/dcrd/wire"
+)
+
+// DecodeAndValidateEscrow decodes an escrow transaction and its script
+// received from the counterparty and makes sure that the script locks
+// funds for the sender and the receiver of the contract until its
+// locktime and that the transaction pays exactly the contract amount to
+// the P2SH address of the script. Addresses, the amount and the locktime
+// of the contract must be set beforehand. The escrow is recorded in the
+// contract once it has been validated.
+func (c *Contract) DecodeAndValidateEscrow(txBytes, script []byte) error {
+	if c.SenderAddr == nil || c.ReceiverAddr == nil {
+		return errors.New("escrow addresses are not set")
+	}
+
+	expected, err := c.buildEscrowContract()
+	if err != nil {
+		return fmt.Errorf("failed to compose escrow contract: %v", err)
+	}
+	if !bytes.Equal(script, expected) {
+		return errors.New("escrow script doesn't match the contract")
+	}
+
+	var tx wire.MsgTx
+	if err = tx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+		return fmt.Errorf("failed to deserialize escrow tx: %v", err)
+	}
+	if len(tx.TxIn) == 0 {
+		return errors.New("escrow tx has no inputs")
+	}
+	if c.LockType != RelativeLock && tx.LockTime >= uint32(c.LockTime) {
+		return fmt.Errorf("escrow tx is locked until %d", tx.LockTime)
+	}
+
+	addr, err := c.chain().ScriptHashAddress(script)
+	if err != nil {
+		return fmt.Errorf("failed to generate a new script hash: %v", err)
+	}
+	payScript, err := c.chain().PayToAddrScript(addr)
+	if err != nil {
+		return fmt.Errorf("failed to create a new script address: %v", err)
+	}
+
+	outputs := 0
+	for _, out := range tx.TxOut {
+		if out.Version != txscript.DefaultScriptVersion ||
+			!bytes.Equal(out.PkScript, payScript) {
+			continue
+		}
+		if out.Value != c.Amount.Atoms() {
+			return fmt.Errorf("escrow tx pays %v instead of %v",
+				dcrutil.Amount(out.Value), c.Amount)
+		}
+		outputs++
+	}
+	switch {
+	case outputs == 0:
+		return errors.New("escrow tx does not contain a P2SH contract " +
+			"payment")
+	case outputs > 1:
+		return errors.New("escrow tx contains multiple P2SH contract " +
+			"payments")
+	}
+
+	hash := tx.TxHash()
+	c.EscrowScript = script
+	c.EscrowAddr = addr
+	c.EscrowAddrStr = addr.String()
+	c.EscrowPayScript = payScript
+	c.EscrowTx, c.EscrowBytes, c.EscrowHash = &tx, txBytes, hash[:]
+	return nil
+}
+
+// EscrowOutput returns the index of the output of the escrow transaction
+// paying to the escrow script.
+func (c *Contract) EscrowOutput() (uint32, error) {
+	if c.EscrowTx == nil {
+		return 0, errors.New("contract doesn't have an escrow transaction")
+	}
+	for i, out := range c.EscrowTx.TxOut {
+		if bytes.Equal(out.PkScript, c.EscrowPayScript) {
+			return uint32(i), nil
+		}
+	}
+	return 0, errors.New("escrow tx does not contain a P2SH contract " +
+		"payment")
+}
+
+// EscrowFee returns the transaction fee paid by the escrow transaction.
+// False is returned if the values of its inputs are unknown.
+func (c *Contract) EscrowFee() (int64, bool) {
+	if c.EscrowTx == nil {
+		return 0, false
+	}
+	var fee int64
+	for _, in := range c.EscrowTx.TxIn {
+		if in.ValueIn == wire.NullValueIn {
+			return 0, false
+		}
+		fee += in.ValueIn
+	}
+	for _, out := range c.EscrowTx.TxOut {
+		fee -= out.Value
+	}
+	return fee, true
+}
+
+// RedeemFee returns the transaction fee paid by the redeeming transaction
+// out of the contract amount. False is returned if the redeeming
+// transaction hasn't been built.
+func (c *Contract) RedeemFee() (int64, bool) {
+	if c.RedeemTx == nil || len(c.RedeemTx.TxOut) == 0 {
+		return 0, false
+	}
+	return c.Amount.Atoms() - c.RedeemTx.TxOut[0].Value, true
+}
@@ -0,0 +1,328 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package contract
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrd/chaincfg/chainec"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrutil"
+	"github.com/decred/dcrd/txscript"
+	"github.com/decred/dcrd/wire"
+)
+
+const testLockTime = 1010
+
+func newTestAddress(t *testing.T) (string, string) {
+	priv, _, _, err := chainec.Secp256k1.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, pub := chainec.Secp256k1.PrivKeyFromBytes(priv)
+	addr, err := dcrutil.NewAddressSecpPubKey(pub.SerializeCompressed(),
+		&chaincfg.SimNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return addr.EncodeAddress(), addr.String()
+}
+
+// newTestContract returns an escrow contract between two new addresses.
+func newTestContract(t *testing.T, lockTime int32) *Contract {
+	c, err := New(&chaincfg.SimNetParams, Denomination, lockTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, pkey := newTestAddress(t)
+	if err = c.SetAddress(SenderAddress, addr, pkey); err != nil {
+		t.Fatal(err)
+	}
+	addr, pkey = newTestAddress(t)
+	if err = c.SetAddress(ReceiverAddress, addr, pkey); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// copyParties returns a contract with the addresses of c and the
+// specified locktime.
+func copyParties(t *testing.T, c *Contract, lockTime int32) *Contract {
+	d, err := New(c.ChainParams, c.Amount, lockTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.SenderAddr, d.SenderScriptAddr = c.SenderAddr, c.SenderScriptAddr
+	d.ReceiverAddr, d.ReceiverScriptAddr = c.ReceiverAddr,
+		c.ReceiverScriptAddr
+	return d
+}
+
+// escrowTx serializes a transaction paying the specified values to the
+// P2SH address of the escrow script of the contract.
+func escrowTx(t *testing.T, c *Contract, values ...Amount) []byte {
+	if err := c.AddEscrowScript(); err != nil {
+		t.Fatal(err)
+	}
+	tx := wire.NewMsgTx()
+	prevOut := wire.NewOutPoint(&chainhash.Hash{1}, 0, wire.TxTreeRegular)
+	tx.AddTxIn(wire.NewTxIn(prevOut, nil))
+	for _, v := range values {
+		tx.AddTxOut(wire.NewTxOut(v.Atoms(), c.EscrowPayScript))
+	}
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeAndValidateEscrow(t *testing.T) {
+	tumbler := newTestContract(t, testLockTime)
+	txBytes := escrowTx(t, tumbler, Denomination)
+
+	c := copyParties(t, tumbler, testLockTime)
+	if err := c.DecodeAndValidateEscrow(txBytes, tumbler.EscrowScript); err != nil {
+		t.Fatalf("valid escrow rejected: %v", err)
+	}
+	if c.EscrowTx == nil || len(c.EscrowHash) == 0 ||
+		c.EscrowAddrStr != tumbler.EscrowAddrStr {
+		t.Fatal("escrow wasn't recorded in the contract")
+	}
+
+	// An escrow locked for longer than advertised.
+	late := copyParties(t, tumbler, testLockTime+1)
+	tests := []struct {
+		name    string
+		txBytes []byte
+		script  []byte
+	}{
+		{"garbage tx", []byte{0x01, 0x02}, tumbler.EscrowScript},
+		{"wrong locktime", escrowTx(t, late, Denomination),
+			late.EscrowScript},
+		{"wrong amount", escrowTx(t, copyParties(t, tumbler,
+			testLockTime), Denomination-1), tumbler.EscrowScript},
+		{"no payment", escrowTx(t, copyParties(t, tumbler,
+			testLockTime)), tumbler.EscrowScript},
+		{"double payment", escrowTx(t, copyParties(t, tumbler,
+			testLockTime), Denomination, Denomination),
+			tumbler.EscrowScript},
+		{"script mismatch", txBytes, late.EscrowScript},
+	}
+	for _, test := range tests {
+		c := copyParties(t, tumbler, testLockTime)
+		err := c.DecodeAndValidateEscrow(test.txBytes, test.script)
+		if err == nil {
+			t.Errorf("%s: invalid escrow accepted", test.name)
+		}
+		if c.EscrowTx != nil {
+			t.Errorf("%s: invalid escrow recorded", test.name)
+		}
+	}
+}
+
+// TestRelativeLockEscrow makes sure escrows locked with relative locktimes
+// aren't mistaken for absolute ones and are refunded by transactions that
+// satisfy OP_CHECKSEQUENCEVERIFY.
+func TestRelativeLockEscrow(t *testing.T) {
+	tumbler := newTestContract(t, testLockTime)
+	tumbler.LockType = RelativeLock
+	txBytes := escrowTx(t, tumbler, Denomination)
+
+	c := copyParties(t, tumbler, testLockTime)
+	if err := c.DecodeAndValidateEscrow(txBytes, tumbler.EscrowScript); err == nil {
+		t.Fatal("relative escrow matched an absolute contract")
+	}
+	c.LockType = RelativeLock
+	if err := c.DecodeAndValidateEscrow(txBytes, tumbler.EscrowScript); err != nil {
+		t.Fatalf("valid escrow rejected: %v", err)
+	}
+
+	addr, pkey := newTestAddress(t)
+	if err := c.SetAddress(RefundAddress, addr, pkey); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.BuildRefundTx(); err != nil {
+		t.Fatal(err)
+	}
+	tx := c.RefundTx
+	if tx.Version < csvTxVersion || tx.LockTime != 0 ||
+		tx.TxIn[0].Sequence != testLockTime {
+		t.Fatalf("unexpected refund tx: version %d, locktime %d, "+
+			"sequence %d", tx.Version, tx.LockTime, tx.TxIn[0].Sequence)
+	}
+}
+
+// TestScriptFailure makes sure failing refunds are reported along with the
+// failing script and opcode, and that contracts survive serialization.
+func TestScriptFailure(t *testing.T) {
+	tumbler := newTestContract(t, testLockTime)
+	txBytes := escrowTx(t, tumbler, Denomination)
+
+	c := copyParties(t, tumbler, testLockTime)
+	if err := c.DecodeAndValidateEscrow(txBytes, tumbler.EscrowScript); err != nil {
+		t.Fatalf("valid escrow rejected: %v", err)
+	}
+	addr, pkey := newTestAddress(t)
+	if err := c.SetAddress(RefundAddress, addr, pkey); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.BuildRefundTx(); err != nil {
+		t.Fatal(err)
+	}
+	c.RefundSig = []byte{0x30, byte(txscript.SigHashAll)}
+	if err := c.AddRefundScript(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := c.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := Deserialize(b, c.ChainParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(d.RefundBytes, c.RefundBytes) ||
+		!bytes.Equal(d.EscrowScript, c.EscrowScript) ||
+		d.SenderAddrStr != c.SenderAddrStr {
+		t.Fatal("contract didn't survive serialization")
+	}
+
+	err = d.VerifyRefundTx()
+	f, ok := err.(*ScriptFailure)
+	if !ok {
+		t.Fatalf("unexpected result of a malformed refund: %v", err)
+	}
+	if f.Path != refundPath || f.Script != "contract script" ||
+		!strings.Contains(f.Opcode, "OP_CHECKSIG") {
+		t.Fatalf("unexpected failure: %v", f)
+	}
+	if f.Stack != nil || f.Disasm != "" {
+		t.Fatal("diagnostics captured without VerboseDiagnostics")
+	}
+
+	// The signature and the public key are on the stack when the
+	// signature check fails.
+	VerboseDiagnostics = true
+	defer func() { VerboseDiagnostics = false }()
+	f, ok = d.VerifyRefundTx().(*ScriptFailure)
+	if !ok || len(f.Stack) != 2 || !bytes.Equal(f.Stack[0], d.RefundSig) ||
+		!strings.Contains(f.Disasm, "OP_CHECKSIG") {
+		t.Fatalf("unexpected verbose failure: %v", f)
+	}
+}
+
+func TestSplitRefund(t *testing.T) {
+	tumbler := newTestContract(t, testLockTime)
+	txBytes := escrowTx(t, tumbler, Denomination)
+
+	c := copyParties(t, tumbler, testLockTime)
+	if err := c.DecodeAndValidateEscrow(txBytes, tumbler.EscrowScript); err != nil {
+		t.Fatalf("valid escrow rejected: %v", err)
+	}
+	addr, pkey := newTestAddress(t)
+	if err := c.SetAddress(RefundAddress, addr, pkey); err != nil {
+		t.Fatal(err)
+	}
+
+	payouts := make([]Payout, 3)
+	for i := range payouts {
+		payouts[i].Address, _ = newTestAddress(t)
+		payouts[i].Weight = uint32(i + 1)
+	}
+	if err := c.BuildRefundTx(payouts...); err != nil {
+		t.Fatal(err)
+	}
+	outs := c.RefundTx.TxOut
+	if len(outs) != len(payouts) {
+		t.Fatalf("refund has %d outputs, expected %d", len(outs),
+			len(payouts))
+	}
+	var total int64
+	for i, out := range outs {
+		total += out.Value
+		if d := out.Value - outs[0].Value*int64(i+1); d < -5 || d > 5 {
+			t.Fatalf("output %d of %d isn't proportional to its weight",
+				i, out.Value)
+		}
+	}
+	if total >= int64(Denomination) ||
+		total < int64(Denomination-Denomination/100) {
+		t.Fatalf("unexpected refunded amount %d", total)
+	}
+
+	payouts[1].Weight = 0
+	if err := c.BuildRefundTx(payouts...); err == nil {
+		t.Fatal("payout without weight accepted")
+	}
+}
+
+// TestMarshal makes sure contracts are restored on the network they name
+// along with their payment channel, and that records written before
+// versioning are still restored.
+func TestMarshal(t *testing.T) {
+	c := newTestContract(t, testLockTime)
+	txBytes := escrowTx(t, c, Denomination)
+	if err := c.ParseTransaction(EscrowTransaction, txBytes); err != nil {
+		t.Fatal(err)
+	}
+	c.Channel = &Channel{
+		EscrowHash: chainhash.HashB([]byte("channel")),
+		Capacity:   3 * Denomination,
+		Balance:    Denomination,
+		Payments:   1,
+	}
+
+	b, err := c.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := Unmarshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.ChainParams != &chaincfg.SimNetParams ||
+		!bytes.Equal(d.EscrowBytes, c.EscrowBytes) ||
+		d.ReceiverAddrStr != c.ReceiverAddrStr ||
+		d.Channel == nil || d.Channel.String() != c.Channel.String() {
+		t.Fatal("contract didn't survive serialization")
+	}
+	if _, err = Deserialize(b, &chaincfg.MainNetParams); err == nil {
+		t.Fatal("contract restored on another network")
+	}
+
+	// Records without a version don't name their network.
+	var r map[string]interface{}
+	if err = json.Unmarshal(b, &r); err != nil {
+		t.Fatal(err)
+	}
+	delete(r, "version")
+	delete(r, "network")
+	legacy, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Unmarshal(legacy); err == nil {
+		t.Fatal("contract restored without a network")
+	}
+	if _, err = Deserialize(legacy, c.ChainParams); err != nil {
+		t.Fatalf("failed to restore a legacy record: %v", err)
+	}
+
+	r["version"] = recordVersion + 1
+	future, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Deserialize(future, c.ChainParams); err == nil {
+		t.Fatal("contract of an unknown version restored")
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package contract
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/txscript"
+	"golang.org/x/crypto/ripemd160"
+)
+
+// HashLock selects the hash function offer contracts lock funds with.
+// The tumbler redeems the offer by revealing preimages of the hashes.
+type HashLock uint32
+
+const (
+	// HashLockRIPEMD160 locks funds with RIPEMD-160 hashes of 20 byte
+	// preimages.
+	HashLockRIPEMD160 HashLock = iota
+
+	// HashLockSHA256 locks funds with SHA-256 hashes of 32 byte
+	// preimages.
+	HashLockSHA256
+)
+
+var hashLockNames = [...]string{
+	HashLockRIPEMD160: "ripemd160",
+	HashLockSHA256:    "sha256",
+}
+
+func (h HashLock) String() string {
+	if int(h) < len(hashLockNames) {
+		return hashLockNames[h]
+	}
+	return fmt.Sprintf("HashLock(%d)", h)
+}
+
+// Valid returns true if the hash lock is known.
+func (h HashLock) Valid() bool {
+	return int(h) < len(hashLockNames)
+}
+
+// PreimageSize returns the size of preimages revealed to redeem the offer.
+func (h HashLock) PreimageSize() int {
+	if h == HashLockSHA256 {
+		return sha256.Size
+	}
+	return ripemd160.Size
+}
+
+// Opcode returns the script opcode hashing preimages.
+func (h HashLock) Opcode() byte {
+	if h == HashLockSHA256 {
+		return txscript.OP_SHA256
+	}
+	return txscript.OP_RIPEMD160
+}
+
+// KeyHash returns the hash the tumbler commits to the preimage with. For
+// SHA-256 hash locks it's the hash the offer locks funds with, while
+// RIPEMD-160 hash locks keep the BLAKE-256 commitments of earlier
+// protocol versions.
+func (h HashLock) KeyHash(preimage []byte) []byte {
+	if h == HashLockSHA256 {
+		hash := sha256.Sum256(preimage)
+		return hash[:]
+	}
+	return chainhash.HashB(preimage)
+}
@@ -0,0 +1,540 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package contract
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrwallet/walletdb"
+	_ "github.com/decred/dcrwallet/walletdb/bdb" // bolt database driver
+	"github.com/decred/tumblebit/chain"
+)
+
+// ErrContractNotFound is returned when the journal doesn't have a record
+// of the requested contract.
+var ErrContractNotFound = errors.New("contract not found")
+
+// ErrHistoryNotFound is returned when the journal doesn't have a record of
+// the requested session.
+var ErrHistoryNotFound = errors.New("session history not found")
+
+// recordVersion is the version of the serialized form of contracts written
+// by Marshal. Records written before versioning have version zero and
+// don't name their network.
+const recordVersion = 1
+
+// networks are the networks serialized contracts may refer to by name.
+var networks = []*chaincfg.Params{
+	&chaincfg.MainNetParams,
+	&chaincfg.TestNet2Params,
+	&chaincfg.SimNetParams,
+}
+
+// journalBucket is the name of the bucket holding contract records keyed
+// by the escrow transaction hash.
+var journalBucket = []byte("contracts")
+
+// historyBucket is the name of the bucket holding audit trails of sessions
+// keyed by the session identifier.
+var historyBucket = []byte("history")
+
+// scriptsBucket is the name of the bucket holding lifecycle records of
+// escrow scripts imported into the wallet keyed by the P2SH address.
+var scriptsBucket = []byte("scripts")
+
+// Journal is a persistent storage of contracts. It keeps serialized
+// transactions, scripts and signatures so that either party can
+// reconstruct and re-broadcast refunding and redeeming transactions after
+// a restart. The tumbler also keeps audit trails of its sessions there.
+type Journal struct {
+	db          walletdb.DB
+	chainParams *chaincfg.Params
+}
+
+// OpenJournal opens the contract journal stored in the bolt database at
+// the specified path, creating it if necessary.
+func OpenJournal(path string, chainParams *chaincfg.Params) (*Journal, error) {
+	var db walletdb.DB
+	var err error
+	if _, err = os.Stat(path); os.IsNotExist(err) {
+		db, err = walletdb.Create("bdb", path)
+	} else {
+		db, err = walletdb.Open("bdb", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open contract journal: %v", err)
+	}
+
+	err = walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+		buckets := [][]byte{journalBucket, historyBucket, scriptsBucket}
+		for _, name := range buckets {
+			if tx.ReadWriteBucket(name) != nil {
+				continue
+			}
+			if _, err := tx.CreateTopLevelBucket(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize contract journal: %v",
+			err)
+	}
+
+	return &Journal{db: db, chainParams: chainParams}, nil
+}
+
+// Close closes the underlying database.
+func (j *Journal) Close() error {
+	return j.db.Close()
+}
+
+// journalAddress is a pair of a public key and an address encoding
+// required to restore one of the contract addresses with SetAddress.
+type journalAddress struct {
+	Address   string `json:"address"`
+	PublicKey string `json:"pubkey"`
+}
+
+// journalChannel is the serialized form of the payment channel of the
+// contract.
+type journalChannel struct {
+	EscrowHash []byte `json:"escrowhash"`
+	Capacity   int64  `json:"capacity"`
+	Fee        int64  `json:"fee,omitempty"`
+	Balance    int64  `json:"balance,omitempty"`
+	Payments   int    `json:"payments,omitempty"`
+}
+
+// journalRecord is the serialized form of the contract.
+type journalRecord struct {
+	Version uint32 `json:"version,omitempty"`
+	Network string `json:"network,omitempty"`
+
+	Sender   *journalAddress `json:"sender,omitempty"`
+	Receiver *journalAddress `json:"receiver,omitempty"`
+	Refund   *journalAddress `json:"refund,omitempty"`
+	Redeem   *journalAddress `json:"redeem,omitempty"`
+
+	EscrowBytes     []byte `json:"escrowtx,omitempty"`
+	EscrowAddr      string `json:"escrowaddr,omitempty"`
+	EscrowPayScript []byte `json:"escrowpayscript,omitempty"`
+	EscrowScript    []byte `json:"escrowscript,omitempty"`
+	EscrowSig       []byte `json:"escrowsig,omitempty"`
+	EscrowHash      []byte `json:"escrowhash"`
+
+	RefundBytes  []byte `json:"refundtx,omitempty"`
+	RefundScript []byte `json:"refundscript,omitempty"`
+	RefundSig    []byte `json:"refundsig,omitempty"`
+	RefundHash   []byte `json:"refundhash,omitempty"`
+
+	RefundPending bool `json:"refundpending,omitempty"`
+
+	RedeemBytes  []byte `json:"redeemtx,omitempty"`
+	RedeemScript []byte `json:"redeemscript,omitempty"`
+	RedeemSig    []byte `json:"redeemsig,omitempty"`
+	RedeemHash   []byte `json:"redeemhash,omitempty"`
+	FeeRate      int64  `json:"feerate,omitempty"`
+	RedeemExpiry uint32 `json:"redeemexpiry,omitempty"`
+
+	Amount   int64    `json:"amount"`
+	LockTime int32    `json:"locktime"`
+	LockType LockType `json:"locktype,omitempty"`
+	HashLock HashLock `json:"hashlock,omitempty"`
+
+	Channel *journalChannel `json:"channel,omitempty"`
+}
+
+func newJournalAddress(addrStr string, addr chain.Address) *journalAddress {
+	if addrStr == "" || addr == nil {
+		return nil
+	}
+	return &journalAddress{Address: addrStr, PublicKey: addr.String()}
+}
+
+// journalKey returns the hash of the escrow transaction identifying the
+// contract. The payee learns only the serialized escrow transaction from
+// the tumbler, so the hash is computed when it hasn't been recorded yet.
+func journalKey(c *Contract) ([]byte, error) {
+	if len(c.EscrowHash) > 0 {
+		return c.EscrowHash, nil
+	}
+	if c.EscrowTx != nil {
+		hash := c.EscrowTx.TxHash()
+		return hash[:], nil
+	}
+	if len(c.EscrowBytes) > 0 {
+		return c.chain().TxHash(c.EscrowBytes)
+	}
+	return nil, errors.New("contract doesn't have an escrow transaction")
+}
+
+// Save records the current state of the contract in the journal replacing
+// any previously saved state of the same contract.
+func (j *Journal) Save(c *Contract) error {
+	key, err := journalKey(c)
+	if err != nil {
+		return err
+	}
+	value, err := c.Marshal()
+	if err != nil {
+		return err
+	}
+
+	return walletdb.Update(j.db, func(tx walletdb.ReadWriteTx) error {
+		return tx.ReadWriteBucket(journalBucket).Put(key, value)
+	})
+}
+
+// Marshal returns the serialized form of the contract kept by the journal
+// and exported by clients. It's versioned and refers to the network of the
+// contract by name, so that Unmarshal restores the contract without
+// knowing the network beforehand.
+func (c *Contract) Marshal() ([]byte, error) {
+	if c.ChainParams == nil {
+		return nil, errors.New("contract doesn't have chain parameters")
+	}
+	key, err := journalKey(c)
+	if err != nil {
+		return nil, err
+	}
+
+	r := journalRecord{
+		Version:         recordVersion,
+		Network:         c.ChainParams.Name,
+		Sender:          newJournalAddress(c.SenderAddrStr, c.SenderAddr),
+		Receiver:        newJournalAddress(c.ReceiverAddrStr, c.ReceiverAddr),
+		Refund:          newJournalAddress(c.RefundAddrStr, c.RefundAddr),
+		Redeem:          newJournalAddress(c.RedeemAddrStr, c.RedeemAddr),
+		EscrowBytes:     c.EscrowBytes,
+		EscrowAddr:      c.EscrowAddrStr,
+		EscrowPayScript: c.EscrowPayScript,
+		EscrowScript:    c.EscrowScript,
+		EscrowSig:       c.EscrowSig,
+		EscrowHash:      key,
+		RefundBytes:     c.RefundBytes,
+		RefundScript:    c.RefundScript,
+		RefundSig:       c.RefundSig,
+		RefundHash:      c.RefundHash,
+		RefundPending:   c.RefundPending,
+		RedeemBytes:     c.RedeemBytes,
+		RedeemScript:    c.RedeemScript,
+		RedeemSig:       c.RedeemSig,
+		RedeemHash:      c.RedeemHash,
+		FeeRate:         c.FeeRate,
+		RedeemExpiry:    c.RedeemExpiry,
+		Amount:          c.Amount.Atoms(),
+		LockTime:        c.LockTime,
+		LockType:        c.LockType,
+		HashLock:        c.HashLock,
+	}
+	if ch := c.Channel; ch != nil {
+		r.Channel = &journalChannel{
+			EscrowHash: ch.EscrowHash,
+			Capacity:   ch.Capacity.Atoms(),
+			Fee:        ch.Fee.Atoms(),
+			Balance:    ch.Balance.Atoms(),
+			Payments:   ch.Payments,
+		}
+	}
+	value, err := json.Marshal(&r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize contract: %v", err)
+	}
+	return value, nil
+}
+
+// Load reconstructs the contract identified by the escrow transaction
+// hash.
+func (j *Journal) Load(escrowHash []byte) (*Contract, error) {
+	var c *Contract
+	err := walletdb.View(j.db, func(tx walletdb.ReadTx) error {
+		value := tx.ReadBucket(journalBucket).Get(escrowHash)
+		if value == nil {
+			return ErrContractNotFound
+		}
+		var err error
+		c, err = j.decode(value)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// List reconstructs all contracts recorded in the journal.
+func (j *Journal) List() ([]*Contract, error) {
+	var contracts []*Contract
+	err := walletdb.View(j.db, func(tx walletdb.ReadTx) error {
+		b := tx.ReadBucket(journalBucket)
+		return b.ForEach(func(k, v []byte) error {
+			c, err := j.decode(v)
+			if err != nil {
+				return fmt.Errorf("contract %x: %v", k, err)
+			}
+			contracts = append(contracts, c)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return contracts, nil
+}
+
+// AppendHistory appends the serialized entry to the audit trail of the
+// session. Recorded entries are never modified or removed.
+func (j *Journal) AppendHistory(session []byte, entry json.RawMessage) error {
+	return walletdb.Update(j.db, func(tx walletdb.ReadWriteTx) error {
+		b := tx.ReadWriteBucket(historyBucket)
+		var entries []json.RawMessage
+		if value := b.Get(session); value != nil {
+			if err := json.Unmarshal(value, &entries); err != nil {
+				return fmt.Errorf("failed to deserialize history "+
+					"of session %x: %v", session, err)
+			}
+		}
+		entries = append(entries, entry)
+		value, err := json.Marshal(entries)
+		if err != nil {
+			return fmt.Errorf("failed to serialize history: %v", err)
+		}
+		return b.Put(session, value)
+	})
+}
+
+// History returns serialized entries of the audit trail of the session,
+// oldest first. ErrHistoryNotFound is returned if nothing was recorded for
+// the session.
+func (j *Journal) History(session []byte) ([]json.RawMessage, error) {
+	var entries []json.RawMessage
+	err := walletdb.View(j.db, func(tx walletdb.ReadTx) error {
+		value := tx.ReadBucket(historyBucket).Get(session)
+		if value == nil {
+			return ErrHistoryNotFound
+		}
+		if err := json.Unmarshal(value, &entries); err != nil {
+			return fmt.Errorf("failed to deserialize history of "+
+				"session %x: %v", session, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ScriptRecord tracks an escrow script imported into the wallet from the
+// import until the contract is resolved and buried deep enough for the
+// script to be pruned.
+type ScriptRecord struct {
+	Address    string `json:"address"`
+	Script     []byte `json:"script"`
+	EscrowHash []byte `json:"escrowhash,omitempty"`
+
+	// Height of the chain when the script was imported.
+	ImportHeight int32 `json:"importheight"`
+
+	// Height of the chain when the contract was resolved, either by a
+	// transaction spending the escrow or by abandoning the contract
+	// before the escrow was known, zero while it's unresolved.
+	ResolveHeight int32  `json:"resolveheight,omitempty"`
+	Spender       []byte `json:"spender,omitempty"`
+
+	Prunable bool `json:"prunable,omitempty"`
+}
+
+// SaveScript records the lifecycle of the imported escrow script
+// replacing any previously saved record for the same address.
+func (j *Journal) SaveScript(r *ScriptRecord) error {
+	value, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to serialize script record: %v", err)
+	}
+	return walletdb.Update(j.db, func(tx walletdb.ReadWriteTx) error {
+		return tx.ReadWriteBucket(scriptsBucket).Put([]byte(r.Address),
+			value)
+	})
+}
+
+// Scripts returns records of all escrow scripts imported into the wallet.
+func (j *Journal) Scripts() ([]*ScriptRecord, error) {
+	var records []*ScriptRecord
+	err := walletdb.View(j.db, func(tx walletdb.ReadTx) error {
+		b := tx.ReadBucket(scriptsBucket)
+		return b.ForEach(func(k, v []byte) error {
+			var r ScriptRecord
+			if err := json.Unmarshal(v, &r); err != nil {
+				return fmt.Errorf("failed to deserialize script "+
+					"record %s: %v", k, err)
+			}
+			records = append(records, &r)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// decode restores the contract from its serialized form.
+func (j *Journal) decode(value []byte) (*Contract, error) {
+	return Deserialize(value, j.chainParams)
+}
+
+// Unmarshal restores the contract from its serialized form returned by
+// Marshal on the network the contract refers to. Records which don't
+// name their network must be restored with Deserialize.
+func Unmarshal(value []byte) (*Contract, error) {
+	var r journalRecord
+	if err := json.Unmarshal(value, &r); err != nil {
+		return nil, fmt.Errorf("failed to deserialize contract: %v", err)
+	}
+	for _, params := range networks {
+		if params.Name == r.Network {
+			return r.contract(params)
+		}
+	}
+	if r.Network == "" {
+		return nil, errors.New("serialized contract doesn't name its " +
+			"network")
+	}
+	return nil, fmt.Errorf("unknown network %q of the serialized contract",
+		r.Network)
+}
+
+// Deserialize restores the contract of the network from its serialized
+// form returned by Marshal. The contract must belong to the network if it
+// names one.
+func Deserialize(value []byte, chainParams *chaincfg.Params) (*Contract, error) {
+	var r journalRecord
+	if err := json.Unmarshal(value, &r); err != nil {
+		return nil, fmt.Errorf("failed to deserialize contract: %v", err)
+	}
+	if r.Network != "" && r.Network != chainParams.Name {
+		return nil, fmt.Errorf("serialized contract belongs to network "+
+			"%s", r.Network)
+	}
+	return r.contract(chainParams)
+}
+
+// contract restores the contract of the network from the record.
+func (r *journalRecord) contract(chainParams *chaincfg.Params) (*Contract, error) {
+	if r.Version > recordVersion {
+		return nil, fmt.Errorf("unsupported contract version %d",
+			r.Version)
+	}
+
+	amount, err := AmountFromAtoms(r.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("invalid contract amount: %v", err)
+	}
+	c := &Contract{
+		EscrowPayScript: r.EscrowPayScript,
+		EscrowScript:    r.EscrowScript,
+		EscrowSig:       r.EscrowSig,
+		RefundScript:    r.RefundScript,
+		RefundSig:       r.RefundSig,
+		RefundPending:   r.RefundPending,
+		RedeemScript:    r.RedeemScript,
+		RedeemSig:       r.RedeemSig,
+		FeeRate:         r.FeeRate,
+		RedeemExpiry:    r.RedeemExpiry,
+		Amount:          amount,
+		LockTime:        r.LockTime,
+		LockType:        r.LockType,
+		HashLock:        r.HashLock,
+		ChainParams:     chainParams,
+	}
+
+	addrs := []struct {
+		role addressRole
+		addr *journalAddress
+	}{
+		{SenderAddress, r.Sender},
+		{ReceiverAddress, r.Receiver},
+		{RefundAddress, r.Refund},
+		{RedeemAddress, r.Redeem},
+	}
+	for _, a := range addrs {
+		if a.addr == nil {
+			continue
+		}
+		err := c.SetAddress(a.role, a.addr.Address, a.addr.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if r.EscrowAddr != "" {
+		addr, err := c.chain().DecodeAddress(r.EscrowAddr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode escrow address: %v",
+				err)
+		}
+		c.EscrowAddr = addr
+		c.EscrowAddrStr = r.EscrowAddr
+	}
+
+	txs := []struct {
+		t     transactionType
+		bytes []byte
+	}{
+		{EscrowTransaction, r.EscrowBytes},
+		{RefundTransaction, r.RefundBytes},
+		{RedeemTransaction, r.RedeemBytes},
+	}
+	for _, tx := range txs {
+		if len(tx.bytes) == 0 {
+			continue
+		}
+		if err := c.ParseTransaction(tx.t, tx.bytes); err != nil {
+			return nil, err
+		}
+	}
+
+	// Transactions may have been recorded before they were completed,
+	// therefore hashes reported by the wallet take precedence.
+	c.EscrowHash = r.EscrowHash
+	if len(r.RefundHash) > 0 {
+		c.RefundHash = r.RefundHash
+	}
+	if len(r.RedeemHash) > 0 {
+		c.RedeemHash = r.RedeemHash
+	}
+
+	if ch := r.Channel; ch != nil {
+		c.Channel = &Channel{
+			EscrowHash: ch.EscrowHash,
+			Payments:   ch.Payments,
+		}
+		amounts := []struct {
+			dst   *Amount
+			atoms int64
+		}{
+			{&c.Channel.Capacity, ch.Capacity},
+			{&c.Channel.Fee, ch.Fee},
+			{&c.Channel.Balance, ch.Balance},
+		}
+		for _, a := range amounts {
+			*a.dst, err = AmountFromAtoms(a.atoms)
+			if err != nil {
+				return nil, fmt.Errorf("invalid channel amount: %v",
+					err)
+			}
+		}
+	}
+
+	return c, nil
+}
@@ -0,0 +1,38 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package contract
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Secrets such as puzzle solutions and preimages unlocking offers must not
+// leak into logs. They are formatted through Secret, which redacts them
+// unless the package is built with the insecuredebug tag:
+//
+//	go build -tags insecuredebug
+
+// Secret is a byte string printed only by insecure debug builds.
+type Secret []byte
+
+// Format implements fmt.Formatter, writing the secret in hex or a
+// placeholder with its length regardless of the verb.
+func (s Secret) Format(f fmt.State, verb rune) {
+	if insecureDebug {
+		fmt.Fprintf(f, "%x", []byte(s))
+		return
+	}
+	fmt.Fprintf(f, "<redacted %d bytes>", len(s))
+}
+
+// TraceSecrets formats a list of secrets for tracing.
+func TraceSecrets(secrets [][]byte) string {
+	strs := make([]string, len(secrets))
+	for i, s := range secrets {
+		strs[i] = fmt.Sprint(Secret(s))
+	}
+	return "[" + strings.Join(strs, " ") + "]"
+}
@@ -0,0 +1,11 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build insecuredebug
+
+package contract
+
+// insecureDebug reveals secrets in logs and contract printouts. Never
+// deploy binaries built with the insecuredebug tag.
+const insecureDebug = true
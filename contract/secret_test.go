@@ -0,0 +1,35 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package contract
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSecretRedaction(t *testing.T) {
+	if insecureDebug {
+		t.Skip("secrets are revealed by insecure debug builds")
+	}
+	secret := []byte{0xde, 0xad, 0xbe, 0xef}
+	for _, verb := range []string{"%v", "%s", "%x", "%X", "%q", "%+v"} {
+		str := fmt.Sprintf(verb, Secret(secret))
+		if strings.Contains(strings.ToLower(str), "deadbeef") {
+			t.Errorf("%s revealed the secret: %s", verb, str)
+		}
+	}
+	if str := TraceSecrets([][]byte{secret, secret}); strings.Contains(str, "deadbeef") {
+		t.Errorf("secrets were revealed: %s", str)
+	}
+
+	c := &Contract{
+		RedeemAddrStr: "redeem",
+		RedeemScript:  append([]byte{0x48}, secret...),
+	}
+	if str := c.String(); strings.Contains(str, "deadbeef") {
+		t.Errorf("contract printout revealed the redeem script: %s", str)
+	}
+}
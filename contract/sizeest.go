@@ -22,6 +22,16 @@ const (
 	escrowSigScriptSize = 1 + 73 + 1
 )
 
+// PreimagePushesSize returns the size of data pushes of count preimages
+// revealed in the signature script redeeming an offer locked with the
+// hash lock.  Each of them takes:
+//
+//   - OP_DATA_20 or OP_DATA_32
+//   - 20 or 32 bytes preimage
+func PreimagePushesSize(count int, lock HashLock) int {
+	return count * (1 + lock.PreimageSize())
+}
+
 func sumOutputSerializeSizes(outputs []*wire.TxOut) (serializeSize int) {
 	for _, txOut := range outputs {
 		serializeSize += txOut.SerializeSize()
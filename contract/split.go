@@ -0,0 +1,92 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package contract
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/decred/dcrd/dcrutil"
+	"github.com/decred/dcrd/wire"
+	"github.com/decred/dcrwallet/wallet/txrules"
+	"github.com/decred/tumblebit/chain"
+)
+
+// Payout is an output of a transaction spending the contract, which
+// receives a share of the spent funds proportional to its weight. Paying
+// out to several addresses with random weights avoids round amounts that
+// single out redeemed and refunded contracts.
+type Payout struct {
+	Address string
+	Weight  uint32
+}
+
+// payoutOutputs composes outputs paying to the payouts, or to the address
+// alone if there are none. Their values are assigned by splitValue once
+// the fee is known.
+func (con *Contract) payoutOutputs(addr chain.Address, payouts []Payout) ([]*wire.TxOut, error) {
+	if len(payouts) == 0 {
+		script, err := con.chain().PayToAddrScript(addr)
+		if err != nil {
+			return nil, err
+		}
+		return []*wire.TxOut{wire.NewTxOut(0, script)}, nil
+	}
+
+	outs := make([]*wire.TxOut, len(payouts))
+	for i, p := range payouts {
+		if p.Weight == 0 {
+			return nil, fmt.Errorf("payout to %s has no weight",
+				p.Address)
+		}
+		a, err := con.chain().DecodeAddress(p.Address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode payout address: %v",
+				err)
+		}
+		if !con.checkAddressType(a, PayToPubKeyHash) {
+			return nil, fmt.Errorf("payout address %v is not P2PKH",
+				p.Address)
+		}
+		script, err := con.chain().PayToAddrScript(a)
+		if err != nil {
+			return nil, err
+		}
+		outs[i] = wire.NewTxOut(0, script)
+	}
+	return outs, nil
+}
+
+// splitValue distributes the value among the outputs proportionally to the
+// weights of the payouts, or assigns it to the only output if there are
+// none. The last output receives what's left after rounding. The name of
+// the transaction is used to report dust outputs.
+func splitValue(name string, outs []*wire.TxOut, value int64, payouts []Payout) error {
+	if len(payouts) == 0 {
+		outs[0].Value = value
+	} else {
+		total := new(big.Int)
+		for _, p := range payouts {
+			total.Add(total, big.NewInt(int64(p.Weight)))
+		}
+		left := value
+		for i, p := range payouts[:len(payouts)-1] {
+			share := big.NewInt(value)
+			share.Mul(share, big.NewInt(int64(p.Weight)))
+			share.Div(share, total)
+			outs[i].Value = share.Int64()
+			left -= outs[i].Value
+		}
+		outs[len(payouts)-1].Value = left
+	}
+
+	for _, out := range outs {
+		if out.Value <= 0 || txrules.IsDustOutput(out, feePerKb) {
+			return fmt.Errorf("%s output value of %v is dust", name,
+				dcrutil.Amount(out.Value))
+		}
+	}
+	return nil
+}
@@ -18,6 +18,18 @@ import (
 
 const feePerKb = 1e5
 
+// FeePerKb is the fee rate in atoms per kilobyte paid by transactions
+// spending contracts.
+const FeePerKb = feePerKb
+
+// redeemFeeRate returns the fee rate paid by the redeeming transaction.
+func (con *Contract) redeemFeeRate() dcrutil.Amount {
+	if con.FeeRate > 0 {
+		return dcrutil.Amount(con.FeeRate)
+	}
+	return feePerKb
+}
+
 const verifyFlags = txscript.ScriptBip16 |
 	txscript.ScriptVerifyDERSignatures |
 	txscript.ScriptVerifyStrictEncoding |
@@ -29,137 +41,73 @@ const verifyFlags = txscript.ScriptBip16 |
 	txscript.ScriptVerifyLowS |
 	txscript.ScriptVerifySHA256
 
-func (con *Contract) AddEscrowScript() error {
-	var err error
+// csvTxVersion is the minimum version of transactions spending outputs
+// locked with OP_CHECKSEQUENCEVERIFY.
+const csvTxVersion = 2
 
-	con.EscrowScript, err = buildEscrowContract(con.SenderScriptAddr,
-		con.ReceiverScriptAddr, int64(con.LockTime))
-	if err != nil {
-		return fmt.Errorf("failed to compose escrow contract: %v", err)
-	}
-	con.EscrowAddr, err = dcrutil.NewAddressScriptHash(con.EscrowScript,
-		con.ChainParams)
-	con.EscrowAddrStr = con.EscrowAddr.String()
-	if err != nil {
-		return fmt.Errorf("failed to generate a new script hash: %v", err)
-	}
-	con.EscrowPayScript, err = txscript.PayToAddrScript(con.EscrowAddr)
-	if err != nil {
-		return fmt.Errorf("failed to create a new script address: %v", err)
+// buildEscrowContract composes the escrow script enforcing the locktime
+// according to the lock type of the contract.
+func (con *Contract) buildEscrowContract() ([]byte, error) {
+	if con.LockType == RelativeLock {
+		return con.chain().EscrowScriptCSV(con.SenderScriptAddr,
+			con.ReceiverScriptAddr, int64(con.LockTime))
 	}
-	return nil
+	return con.chain().EscrowScript(con.SenderScriptAddr,
+		con.ReceiverScriptAddr, int64(con.LockTime))
 }
 
-// buildEscrowContract returns an output script that may be redeemed by one
-// of two signature scripts:
-//
-//   <payer sig> <redeemer sig> 1
-//
-//   <payer sig> 0
-//
-// The first signature script is the normal redemption path done by the
-// other party and requires both tumbler and client signatures. The second
-// signature script is the refund path performed by us, but the refund can
-// only be performed after locktime.
-func buildEscrowContract(pkPayer, pkRedeemer []byte, locktime int64) ([]byte, error) {
-	b := txscript.NewScriptBuilder()
-
-	b.AddOp(txscript.OP_IF) // Normal redeem path
-	{
-		// Check 2-of-2 multisig.
-		b.AddOp(txscript.OP_2)
-		b.AddData(pkPayer)
-		b.AddData(pkRedeemer)
-		b.AddOp(txscript.OP_2)
-		b.AddOp(txscript.OP_CHECKMULTISIG)
-	}
-	b.AddOp(txscript.OP_ELSE) // Refund path
-	{
-		// Verify locktime and drop it off the stack (which is not done
-		// by CLTV).
-		b.AddInt64(locktime)
-		b.AddOp(txscript.OP_CHECKLOCKTIMEVERIFY)
-		b.AddOp(txscript.OP_DROP)
-
-		// Verify our signature is being used to redeem the output.
-		b.AddData(pkPayer)
-		b.AddOp(txscript.OP_CHECKSIG)
-	}
-	b.AddOp(txscript.OP_ENDIF)
-
-	return b.Script()
+// buildOfferContract composes the offer script enforcing the locktime
+// according to the lock type of the contract.
+func (con *Contract) buildOfferContract(hashes [][]byte, hashOp byte) ([]byte, error) {
+	if con.LockType == RelativeLock {
+		return con.chain().OfferScriptCSV(con.SenderScriptAddr,
+			con.ReceiverScriptAddr, hashes, hashOp, int64(con.LockTime))
+	}
+	return con.chain().OfferScript(con.SenderScriptAddr,
+		con.ReceiverScriptAddr, hashes, hashOp, int64(con.LockTime))
 }
 
-func (con *Contract) AddOfferScript(hashes [][]byte, hashOp byte) error {
+func (con *Contract) AddEscrowScript() error {
 	var err error
 
-	con.EscrowScript, err = buildOfferContract(con.SenderScriptAddr,
-		con.ReceiverScriptAddr, hashes, hashOp, int64(con.LockTime))
+	con.EscrowScript, err = con.buildEscrowContract()
 	if err != nil {
 		return fmt.Errorf("failed to compose escrow contract: %v", err)
 	}
-	con.EscrowAddr, err = dcrutil.NewAddressScriptHash(con.EscrowScript,
-		con.ChainParams)
-	con.EscrowAddrStr = con.EscrowAddr.String()
+	return con.addEscrowAddress()
+}
+
+// addEscrowAddress derives the P2SH address of the escrow script and the
+// output script paying to it.
+func (con *Contract) addEscrowAddress() error {
+	var err error
+
+	con.EscrowAddr, err = con.chain().ScriptHashAddress(con.EscrowScript)
 	if err != nil {
 		return fmt.Errorf("failed to generate a new script hash: %v", err)
 	}
-	con.EscrowPayScript, err = txscript.PayToAddrScript(con.EscrowAddr)
+	con.EscrowAddrStr = con.EscrowAddr.String()
+	con.EscrowPayScript, err = con.chain().PayToAddrScript(con.EscrowAddr)
 	if err != nil {
 		return fmt.Errorf("failed to create a new script address: %v", err)
 	}
 	return nil
 }
 
-// buildOfferContract returns an output script that may be redeemed by one
-// of two signature scripts:
-//
-//   OP_RIPEMD160, h1, OP_EQUALVERIFY
-//   OP_RIPEMD160, h2, OP_EQUALVERIFY
-//   ...
-//   <redeemer sig> 1
-//
-//  Or:
-//
-//   <payer sig> 0
-//
-// The first signature script is the normal redemption path done by the
-// other party and requires hash preimages and a tumbler signature. The
-// second signature script is the refund path performed by the client,
-// but the refund can only be performed after locktime.
-func buildOfferContract(pkPayer, pkRedeemer []byte, hashes [][]byte, hashOp byte, locktime int64) ([]byte, error) {
-	b := txscript.NewScriptBuilder()
-
-	b.AddOp(txscript.OP_IF) // Normal redeem path
-	{
-		for _, h := range hashes {
-			b.AddOp(hashOp)
-			b.AddData(h)
-			b.AddOp(txscript.OP_EQUALVERIFY)
-		}
-		// Check redeemer's signature.
-		b.AddData(pkRedeemer)
-		b.AddOp(txscript.OP_CHECKSIG)
-	}
-	b.AddOp(txscript.OP_ELSE) // Refund path
-	{
-		// Verify locktime and drop it off the stack (which is not done
-		// by CLTV).
-		b.AddInt64(locktime)
-		b.AddOp(txscript.OP_CHECKLOCKTIMEVERIFY)
-		b.AddOp(txscript.OP_DROP)
+func (con *Contract) AddOfferScript(hashes [][]byte, hashOp byte) error {
+	var err error
 
-		// Verify our signature is being used to redeem the output.
-		b.AddData(pkPayer)
-		b.AddOp(txscript.OP_CHECKSIG)
+	con.EscrowScript, err = con.buildOfferContract(hashes, hashOp)
+	if err != nil {
+		return fmt.Errorf("failed to compose escrow contract: %v", err)
 	}
-	b.AddOp(txscript.OP_ENDIF)
-
-	return b.Script()
+	return con.addEscrowAddress()
 }
 
 // BuildRefundTx creates a refund transaction that spends escrowed funds.
-func (con *Contract) BuildRefundTx() error {
+// The funds are split among the payouts if any are specified, otherwise
+// they are paid to the refund address.
+func (con *Contract) BuildRefundTx(payouts ...Payout) error {
 	var err error
 
 	// XXX: temporary compat with the old code
@@ -186,26 +134,33 @@ func (con *Contract) BuildRefundTx() error {
 		return errors.New("contract tx does not contain a P2SH contract payment")
 	}
 
-	refundOutScript, err := txscript.PayToAddrScript(con.RefundAddr)
+	outs, err := con.payoutOutputs(con.RefundAddr, payouts)
 	if err != nil {
 		return err
 	}
 
 	tx := wire.NewMsgTx()
-	tx.LockTime = uint32(con.LockTime)
-	tx.AddTxOut(wire.NewTxOut(0, refundOutScript)) // amount set below
+	if con.LockType == RelativeLock {
+		tx.Version = csvTxVersion
+	} else {
+		tx.LockTime = uint32(con.LockTime)
+	}
+	tx.TxOut = outs // amounts set below
 	refundSize := estimateRefundSerializeSize(con.EscrowScript,
 		tx.TxOut)
 	refundFee := txrules.FeeForSerializeSize(feePerKb, refundSize)
-	tx.TxOut[0].Value = con.EscrowTx.TxOut[contractOutPoint.Index].Value -
-		int64(refundFee)
-	if txrules.IsDustOutput(tx.TxOut[0], feePerKb) {
-		return fmt.Errorf("refund output value of %v is dust",
-			dcrutil.Amount(tx.TxOut[0].Value))
+	err = splitValue("refund", outs,
+		con.EscrowTx.TxOut[contractOutPoint.Index].Value-int64(refundFee),
+		payouts)
+	if err != nil {
+		return err
 	}
 
 	txIn := wire.NewTxIn(&contractOutPoint, nil)
 	txIn.Sequence = 0
+	if con.LockType == RelativeLock {
+		txIn.Sequence = uint32(con.LockTime)
+	}
 	tx.AddTxIn(txIn)
 
 	var buf bytes.Buffer
@@ -222,7 +177,7 @@ func (con *Contract) BuildRefundTx() error {
 func (con *Contract) AddRefundScript() error {
 	var err error
 
-	con.RefundScript, err = refundP2SHContract(con.EscrowScript,
+	con.RefundScript, err = con.chain().RefundSigScript(con.EscrowScript,
 		con.RefundSig)
 	if err != nil {
 		return fmt.Errorf("failed to compose a refund contract: %v", err)
@@ -239,44 +194,28 @@ func (con *Contract) AddRefundScript() error {
 }
 
 // VerifyRefundTx makes sure that resulting refund script executes correctly.
+// A *ScriptFailure describing the failing script is returned otherwise.
 func (con *Contract) VerifyRefundTx() error {
-	contractOutPoint := wire.OutPoint{
-		Hash:  con.EscrowTx.TxHash(),
-		Index: ^uint32(0),
-	}
+	contractOut := -1
 	for i, o := range con.EscrowTx.TxOut {
 		if bytes.Equal(o.PkScript, con.EscrowPayScript) {
-			contractOutPoint.Index = uint32(i)
+			contractOut = i
 			break
 		}
 	}
-
-	e, err := txscript.NewEngine(
-		con.EscrowTx.TxOut[contractOutPoint.Index].PkScript,
-		con.RefundTx, 0, verifyFlags, txscript.DefaultScriptVersion,
-		txscript.NewSigCache(10))
-	if err != nil {
-		return err
-	}
-	if err = e.Execute(); err != nil {
-		return err
+	if contractOut == -1 {
+		return errors.New("transaction does not contain a contract output")
 	}
-	return nil
-}
 
-// refundP2SHContract returns the signature script to refund a contract
-// output using the contract author's signature after the locktime has
-// been reached. This function assumes P2SH and appends the contract as
-// the final data push.
-func refundP2SHContract(contract, sig []byte) ([]byte, error) {
-	b := txscript.NewScriptBuilder()
-	b.AddData(sig)
-	b.AddInt64(0)
-	b.AddData(contract)
-	return b.Script()
+	return execute(con.EscrowTx.TxOut[contractOut].PkScript, con.RefundTx,
+		refundPath)
 }
 
-func (con *Contract) BuildRedeemTx(sigScriptAddSize int) error {
+// BuildRedeemTx creates a redeem transaction that spends escrowed funds
+// with a signature script of sigScriptAddSize bytes in addition to the
+// contract. The funds are split among the payouts if any are specified,
+// otherwise they are paid to the redeem address.
+func (con *Contract) BuildRedeemTx(sigScriptAddSize int, payouts ...Payout) error {
 	var err error
 
 	// XXX: temporary compat with the old code
@@ -304,7 +243,7 @@ func (con *Contract) BuildRedeemTx(sigScriptAddSize int) error {
 		return errors.New("transaction does not contain a contract output")
 	}
 
-	outScript, err := txscript.PayToAddrScript(con.RedeemAddr)
+	outs, err := con.payoutOutputs(con.RedeemAddr, payouts)
 	if err != nil {
 		return err
 	}
@@ -317,17 +256,32 @@ func (con *Contract) BuildRedeemTx(sigScriptAddSize int) error {
 	}
 
 	tx := wire.NewMsgTx()
-	tx.LockTime = uint32(con.LockTime)
+	if con.LockType != RelativeLock {
+		tx.LockTime = uint32(con.LockTime)
+	}
+	tx.Expiry = con.RedeemExpiry
 	tx.AddTxIn(wire.NewTxIn(&contractOutPoint, nil))
-	tx.AddTxOut(wire.NewTxOut(0, outScript)) // amount set below
+	tx.TxOut = outs // amounts set below
+
+	// Return funds that weren't transferred over the channel back
+	// to the sender.
+	escrowValue := con.EscrowTx.TxOut[contractOut].Value
+	if con.Channel != nil && escrowValue > con.Amount.Atoms() {
+		changeScript, err := con.chain().PayToAddrScript(con.SenderAddr)
+		if err != nil {
+			return err
+		}
+		tx.AddTxOut(wire.NewTxOut(escrowValue-con.Amount.Atoms(),
+			changeScript))
+		escrowValue = con.Amount.Atoms()
+	}
+
 	redeemSize := estimateRedeemSerializeSize(con.EscrowScript, tx.TxOut,
 		sigScriptAddSize)
-	fee := txrules.FeeForSerializeSize(feePerKb, redeemSize)
-	tx.TxOut[0].Value = con.EscrowTx.TxOut[contractOut].Value -
-		int64(fee)
-	if txrules.IsDustOutput(tx.TxOut[0], feePerKb) {
-		return fmt.Errorf("redeem output value of %v is dust",
-			dcrutil.Amount(tx.TxOut[0].Value))
+	fee := txrules.FeeForSerializeSize(con.redeemFeeRate(), redeemSize)
+	err = splitValue("redeem", outs, escrowValue-int64(fee), payouts)
+	if err != nil {
+		return err
 	}
 
 	var buf bytes.Buffer
@@ -343,7 +297,7 @@ func (con *Contract) BuildRedeemTx(sigScriptAddSize int) error {
 func (con *Contract) AddRedeemScript(secrets [][]byte) error {
 	var err error
 
-	con.RedeemScript, err = redeemP2SHContract(con.EscrowScript,
+	con.RedeemScript, err = con.chain().RedeemSigScript(con.EscrowScript,
 		con.RedeemSig, secrets)
 	if err != nil {
 		return err
@@ -359,6 +313,30 @@ func (con *Contract) AddRedeemScript(secrets [][]byte) error {
 	return nil
 }
 
+// AddEscrowRedeemScript completes the transaction redeeming the escrow set
+// up by the tumbler with signatures of both parties, where senderSig is
+// the signature of the tumbler.
+func (con *Contract) AddEscrowRedeemScript(senderSig []byte) error {
+	var err error
+
+	con.RedeemScript, err = con.chain().EscrowRedeemSigScript(con.EscrowScript,
+		senderSig, con.RedeemSig)
+	if err != nil {
+		return err
+	}
+	con.RedeemTx.TxIn[0].SignatureScript = con.RedeemScript
+
+	var buf bytes.Buffer
+	buf.Grow(con.RedeemTx.SerializeSize())
+
+	con.RedeemTx.Serialize(&buf)
+	con.RedeemBytes = buf.Bytes()
+
+	return nil
+}
+
+// VerifyRedeemTx makes sure that resulting redeem script executes correctly.
+// A *ScriptFailure describing the failing script is returned otherwise.
 func (con *Contract) VerifyRedeemTx() error {
 	contractHash := dcrutil.Hash160(con.EscrowScript)
 	contractOut := -1
@@ -375,39 +353,8 @@ func (con *Contract) VerifyRedeemTx() error {
 		return errors.New("transaction does not contain a contract output")
 	}
 
-	txHash := con.EscrowTx.TxHash()
-	contractOutPoint := wire.OutPoint{
-		Hash:  txHash,
-		Index: uint32(contractOut),
-		Tree:  0,
-	}
-
-	e, err := txscript.NewEngine(
-		con.EscrowTx.TxOut[contractOutPoint.Index].PkScript,
-		con.RedeemTx, 0, verifyFlags, txscript.DefaultScriptVersion,
-		txscript.NewSigCache(10))
-	if err != nil {
-		return err
-	}
-	if err = e.Execute(); err != nil {
-		return err
-	}
-	return nil
-}
-
-// redeemP2SHContract returns the signature script to redeem a contract
-// output using the redeemer's signature and secret values. This function
-// assumes P2SH and appends the contract as the final data push.
-func redeemP2SHContract(contract, sig []byte, secrets [][]byte) ([]byte, error) {
-	b := txscript.NewScriptBuilder()
-	b.AddData(sig)
-	for i, secret := range secrets {
-		fmt.Printf("secret %d: %x\n", i, secret)
-		b.AddData(secret)
-	}
-	b.AddInt64(1)
-	b.AddData(contract)
-	return b.Script()
+	return execute(con.EscrowTx.TxOut[contractOut].PkScript, con.RedeemTx,
+		redeemPath)
 }
 
 func (con *Contract) ExtractRedeemDataPushes(in uint32) ([][]byte, error) {
@@ -420,7 +367,7 @@ func (con *Contract) ExtractRedeemDataPushes(in uint32) ([][]byte, error) {
 		}
 		con.RedeemTx = &tx
 	}
-	data, err := txscript.PushedData(con.RedeemTx.TxIn[in].SignatureScript)
+	data, err := con.chain().PushedData(con.RedeemTx.TxIn[in].SignatureScript)
 	if err != nil {
 		return nil, fmt.Errorf("failed to extract data pushes from "+
 			"input %d of a redeeming signature script: %v", in, err)
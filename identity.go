@@ -0,0 +1,65 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/agl/ed25519"
+	"github.com/decred/tumblebit/tumbler"
+)
+
+// loadIdentityKey reads the operator identity key from the file specified
+// by the config or generates a new one if the file doesn't exist. The key
+// must be kept for as long as the tumbler is operated, since clients and
+// auditors recognize epoch manifests by it.
+func loadIdentityKey() (*tumbler.IdentityKey, error) {
+	b, err := ioutil.ReadFile(cfg.IdentityKey.Value)
+	if os.IsNotExist(err) {
+		return generateIdentityKey()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	priv, err := hex.DecodeString(string(bytes.TrimSpace(b)))
+	if err != nil || len(priv) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("malformed identity key in %s",
+			cfg.IdentityKey.Value)
+	}
+	key := &tumbler.IdentityKey{
+		PublicKey:  new([ed25519.PublicKeySize]byte),
+		PrivateKey: new([ed25519.PrivateKeySize]byte),
+	}
+	copy(key.PrivateKey[:], priv)
+	copy(key.PublicKey[:], priv[32:])
+	return key, nil
+}
+
+// generateIdentityKey generates a new identity key and writes it to the
+// file specified by the config.
+func generateIdentityKey() (*tumbler.IdentityKey, error) {
+	log.Infof("Generating the identity key...")
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(filepath.Dir(cfg.IdentityKey.Value), 0700)
+	if err != nil {
+		return nil, err
+	}
+	err = ioutil.WriteFile(cfg.IdentityKey.Value,
+		[]byte(hex.EncodeToString(priv[:])+"\n"), 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &tumbler.IdentityKey{PublicKey: pub, PrivateKey: priv}, nil
+}
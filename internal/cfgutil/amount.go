@@ -6,10 +6,8 @@
 package cfgutil
 
 import (
-	"strconv"
-	"strings"
-
 	"github.com/decred/dcrd/dcrutil"
+	"github.com/decred/tumblebit/contract"
 )
 
 // AmountFlag embeds a dcrutil.Amount and implements the flags.Marshaler and
@@ -28,17 +26,14 @@ func (a *AmountFlag) MarshalFlag() (string, error) {
 	return a.Amount.String(), nil
 }
 
-// UnmarshalFlag satisifes the flags.Unmarshaler interface.
+// UnmarshalFlag satisifes the flags.Unmarshaler interface. Amounts are
+// parsed exactly, so values with more decimal places than atoms can
+// represent are rejected rather than rounded.
 func (a *AmountFlag) UnmarshalFlag(value string) error {
-	value = strings.TrimSuffix(value, " DCR")
-	valueF64, err := strconv.ParseFloat(value, 64)
-	if err != nil {
-		return err
-	}
-	amount, err := dcrutil.NewAmount(valueF64)
+	amount, err := contract.ParseAmount(value)
 	if err != nil {
 		return err
 	}
-	a.Amount = amount
+	a.Amount = dcrutil.Amount(amount)
 	return nil
 }
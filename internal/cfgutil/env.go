@@ -0,0 +1,71 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cfgutil
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	flags "github.com/jessevdk/go-flags"
+)
+
+// EnvName returns the name of the environment variable overriding the
+// option with the long name, e.g. TUMBLEBIT_WALLETPASSWORD for the
+// walletpassword option and the TUMBLEBIT_ prefix.
+func EnvName(prefix, longName string) string {
+	return prefix + strings.ToUpper(strings.Replace(longName, "-", "_", -1))
+}
+
+// EnvDescription describes the precedence of config sources for the help
+// output of parsers whose options are overridden with ParseEnv.
+func EnvDescription(prefix, example string) string {
+	return fmt.Sprintf("Options are read from the config file, then from "+
+		"environment variables named after their long names with the "+
+		"%s prefix, e.g. %s for --%s, and finally from the command "+
+		"line.  Every source overrides the previous ones.  Options "+
+		"that may be specified multiple times take one value per "+
+		"line of the environment variable.", prefix,
+		EnvName(prefix, example), example)
+}
+
+// ParseEnv sets options of the parser from the environment variables named
+// after them by EnvName. Values are parsed as if they were read from a
+// config file, so that the environment is layered between a config file
+// and the command line when ParseEnv is called in between parsing them.
+// Every line of the value of a variable is a separate value of the option.
+func ParseEnv(parser *flags.Parser, prefix string) error {
+	var ini bytes.Buffer
+	var visit func(g *flags.Group)
+	visit = func(g *flags.Group) {
+		for _, opt := range g.Options() {
+			if opt.LongName == "" {
+				continue
+			}
+			value, ok := os.LookupEnv(EnvName(prefix, opt.LongName))
+			if !ok {
+				continue
+			}
+			for _, v := range strings.Split(value, "\n") {
+				fmt.Fprintf(&ini, "%s = %s\n", opt.LongName,
+					strconv.Quote(v))
+			}
+		}
+		for _, sub := range g.Groups() {
+			visit(sub)
+		}
+	}
+	visit(parser.Command.Group)
+	if ini.Len() == 0 {
+		return nil
+	}
+
+	if err := flags.NewIniParser(parser).Parse(&ini); err != nil {
+		return fmt.Errorf("environment: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cfgutil
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	flags "github.com/jessevdk/go-flags"
+)
+
+func TestParseEnv(t *testing.T) {
+	type config struct {
+		Password  string          `long:"walletpassword"`
+		Listeners []string        `long:"grpclisten"`
+		TestNet   bool            `long:"testnet"`
+		Level     string          `long:"debuglevel"`
+		Dir       *ExplicitString `long:"appdata"`
+		Retries   int             `long:"wallet-retries"`
+	}
+	cfg := config{Level: "info", Dir: NewExplicitString("default")}
+	parser := flags.NewParser(&cfg, flags.None)
+
+	file := "debuglevel = debug\nwalletpassword = file\nwallet-retries = 2\n"
+	if err := flags.NewIniParser(parser).Parse(strings.NewReader(file)); err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		"TEST_WALLETPASSWORD": " #secret; \"quoted\" ",
+		"TEST_GRPCLISTEN":     "127.0.0.1:1\n[::1]:2",
+		"TEST_TESTNET":        "1",
+		"TEST_APPDATA":        "/data",
+		"TEST_WALLET_RETRIES": "5",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+	if err := ParseEnv(parser, "TEST_"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.ParseArgs([]string{"--wallet-retries=7"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Password != env["TEST_WALLETPASSWORD"] {
+		t.Errorf("password %q", cfg.Password)
+	}
+	if !reflect.DeepEqual(cfg.Listeners, []string{"127.0.0.1:1", "[::1]:2"}) {
+		t.Errorf("listeners %q", cfg.Listeners)
+	}
+	if !cfg.TestNet {
+		t.Error("testnet unset")
+	}
+	if cfg.Level != "debug" {
+		t.Errorf("config file value overridden: %q", cfg.Level)
+	}
+	if cfg.Dir.Value != "/data" || !cfg.Dir.ExplicitlySet() {
+		t.Errorf("appdata %q", cfg.Dir.Value)
+	}
+	if cfg.Retries != 7 {
+		t.Errorf("command line value overridden: %d", cfg.Retries)
+	}
+
+	os.Setenv("TEST_WALLET_RETRIES", "many")
+	if err := ParseEnv(parser, "TEST_"); err == nil {
+		t.Error("malformed value parsed")
+	}
+}
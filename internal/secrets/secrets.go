@@ -0,0 +1,245 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package secrets keeps configuration secrets, such as the wallet
+// passphrase, out of plaintext configuration files. Secrets are stored in
+// a file encrypted with NaCl secretbox under a key derived from a
+// passphrase with scrypt, or prompted for at startup.
+package secrets
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// Version of the secrets file format.
+const Version = 1
+
+// Default scrypt parameters of new secrets files.
+const (
+	scryptN = 1 << 16
+	scryptR = 8
+	scryptP = 1
+)
+
+const (
+	keySize   = 32
+	saltSize  = 32
+	nonceSize = 24
+)
+
+// ErrBadPassphrase is returned when the secrets file can't be decrypted
+// with the provided passphrase.
+var ErrBadPassphrase = errors.New("invalid passphrase for the secrets file")
+
+// Secrets holds configuration options that must not be stored in
+// plaintext.
+type Secrets struct {
+	WalletPassword string `json:"walletpassword"`
+}
+
+// kdfParams describes the derivation of the encryption key from the
+// passphrase.
+type kdfParams struct {
+	Salt []byte `json:"salt"`
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+}
+
+// file is the serialized form of the secrets file.
+type file struct {
+	Version int       `json:"version"`
+	KDF     kdfParams `json:"kdf"`
+	Nonce   []byte    `json:"nonce"`
+	Box     []byte    `json:"box"`
+}
+
+func (p *kdfParams) deriveKey(passphrase []byte) (*[keySize]byte, error) {
+	k, err := scrypt.Key(passphrase, p.Salt, p.N, p.R, p.P, keySize)
+	if err != nil {
+		return nil, err
+	}
+	var key [keySize]byte
+	copy(key[:], k)
+	zero(k)
+	return &key, nil
+}
+
+// Encrypt serializes and encrypts the secrets with a key derived from the
+// passphrase.
+func Encrypt(s *Secrets, passphrase []byte) ([]byte, error) {
+	f := file{
+		Version: Version,
+		KDF: kdfParams{
+			Salt: make([]byte, saltSize),
+			N:    scryptN,
+			R:    scryptR,
+			P:    scryptP,
+		},
+		Nonce: make([]byte, nonceSize),
+	}
+	if _, err := io.ReadFull(rand.Reader, f.KDF.Salt); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(rand.Reader, f.Nonce); err != nil {
+		return nil, err
+	}
+	key, err := f.KDF.deriveKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(key[:])
+
+	plaintext, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(plaintext)
+	var nonce [nonceSize]byte
+	copy(nonce[:], f.Nonce)
+	f.Box = secretbox.Seal(nil, plaintext, &nonce, key)
+
+	return json.MarshalIndent(&f, "", "  ")
+}
+
+// Decrypt decrypts the secrets serialized by Encrypt. ErrBadPassphrase is
+// returned if the passphrase is wrong or the data has been tampered with.
+func Decrypt(data, passphrase []byte) (*Secrets, error) {
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("malformed secrets file: %v", err)
+	}
+	if f.Version != Version {
+		return nil, fmt.Errorf("unsupported secrets file version %d",
+			f.Version)
+	}
+	if len(f.Nonce) != nonceSize {
+		return nil, errors.New("malformed secrets file: bad nonce")
+	}
+	key, err := f.KDF.deriveKey(passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("malformed secrets file: %v", err)
+	}
+	defer zero(key[:])
+
+	var nonce [nonceSize]byte
+	copy(nonce[:], f.Nonce)
+	plaintext, ok := secretbox.Open(nil, f.Box, &nonce, key)
+	if !ok {
+		return nil, ErrBadPassphrase
+	}
+	defer zero(plaintext)
+
+	var s Secrets
+	if err = json.Unmarshal(plaintext, &s); err != nil {
+		return nil, fmt.Errorf("malformed secrets: %v", err)
+	}
+	return &s, nil
+}
+
+// ReadFile decrypts the secrets file.
+func ReadFile(path string, passphrase []byte) (*Secrets, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Decrypt(data, passphrase)
+}
+
+// WriteFile encrypts the secrets and writes them to a new file readable
+// only by the owner. An existing file is never overwritten.
+func WriteFile(path string, s *Secrets, passphrase []byte) error {
+	data, err := Encrypt(s, passphrase)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Prompt asks for a passphrase on the terminal without echoing it. When
+// standard input isn't a terminal, the passphrase is read from a single
+// line of input.
+func Prompt(prompt string) ([]byte, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	defer fmt.Fprintln(os.Stderr)
+
+	fd := int(os.Stdin.Fd())
+	if terminal.IsTerminal(fd) {
+		return terminal.ReadPassword(fd)
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadBytes('\n')
+	if err != nil && (err != io.EOF || len(line) == 0) {
+		return nil, err
+	}
+	return bytes.TrimRight(line, "\r\n"), nil
+}
+
+// Create interactively creates a new secrets file holding the wallet
+// password. The wallet password is prompted for unless provided.
+func Create(path, walletPassword string) error {
+	if walletPassword == "" {
+		pass, err := Prompt("Wallet passphrase: ")
+		if err != nil {
+			return err
+		}
+		walletPassword = string(pass)
+		zero(pass)
+	}
+
+	passphrase, err := Prompt("New secrets file passphrase: ")
+	if err != nil {
+		return err
+	}
+	defer zero(passphrase)
+	confirm, err := Prompt("Confirm passphrase: ")
+	if err != nil {
+		return err
+	}
+	defer zero(confirm)
+	if !bytes.Equal(passphrase, confirm) {
+		return errors.New("passphrases don't match")
+	}
+	if len(passphrase) == 0 {
+		return errors.New("passphrase must not be empty")
+	}
+
+	return WriteFile(path, &Secrets{WalletPassword: walletPassword},
+		passphrase)
+}
+
+// Load prompts for the passphrase of the secrets file and decrypts it.
+func Load(path string) (*Secrets, error) {
+	passphrase, err := Prompt(fmt.Sprintf("Passphrase for %s: ", path))
+	if err != nil {
+		return nil, err
+	}
+	defer zero(passphrase)
+	return ReadFile(path, passphrase)
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
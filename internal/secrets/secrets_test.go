@@ -0,0 +1,34 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package secrets
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	s := &Secrets{WalletPassword: "wallet passphrase"}
+	data, err := Encrypt(s, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte(s.WalletPassword)) {
+		t.Fatal("wallet passphrase stored in plaintext")
+	}
+
+	d, err := Decrypt(data, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.WalletPassword != s.WalletPassword {
+		t.Fatalf("decrypted %q, want %q", d.WalletPassword,
+			s.WalletPassword)
+	}
+
+	if _, err = Decrypt(data, []byte("wrong")); err != ErrBadPassphrase {
+		t.Fatalf("decrypting with a wrong passphrase: %v", err)
+	}
+}
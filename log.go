@@ -6,15 +6,18 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/btcsuite/btclog"
 	"github.com/jrick/logrotate/rotator"
 
 	"github.com/decred/tumblebit/rpc/rpcserver"
 	"github.com/decred/tumblebit/tumbler"
+	"github.com/decred/tumblebit/wallet"
 )
 
 // logWriter implements an io.Writer that outputs to both standard output and
@@ -48,12 +51,15 @@ var (
 	log        = backendLog.Logger("DCRT")
 	tumblerLog = backendLog.Logger("TMBL")
 	grpcLog    = backendLog.Logger("GRPC")
+	walletLog  = backendLog.Logger("WLLT")
 )
 
 // Initialize package-global logger variables.
 func init() {
 	tumbler.UseLogger(tumblerLog)
 	rpcserver.UseLogger(grpcLog)
+	rpcserver.UseSubsystemLoggers(subsystemLoggers)
+	wallet.UseLogger(walletLog)
 }
 
 // subsystemLoggers maps each subsystem identifier to its associated logger.
@@ -61,6 +67,7 @@ var subsystemLoggers = map[string]btclog.Logger{
 	"DCRT": log,
 	"TMBL": tumblerLog,
 	"GRPC": grpcLog,
+	"WLLT": walletLog,
 }
 
 // initLogRotator initializes the logging rotater to write logs to logFile and
@@ -82,6 +89,45 @@ func initLogRotator(logFile string) {
 	logRotator = r
 }
 
+// jsonLog writes structured session events as JSON lines to a rotated log
+// file alongside the text log.  It's nil unless enabled with --jsonlog.
+var jsonLog *jsonLogger
+
+type jsonLogger struct {
+	mu      sync.Mutex
+	rotator *rotator.Rotator
+}
+
+// initJSONLog initializes the rotated JSON log in logFile.
+func initJSONLog(logFile string) {
+	r, err := rotator.New(logFile, 10*1024, false, 3)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create file rotator: %v\n", err)
+		os.Exit(1)
+	}
+	jsonLog = &jsonLogger{rotator: r}
+}
+
+// logEvent writes the session event as a single JSON record.
+func (l *jsonLogger) logEvent(ev *tumbler.SessionEvent) {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		log.Errorf("Failed to encode a session event: %v", err)
+		return
+	}
+	b = append(b, '\n')
+	l.mu.Lock()
+	l.rotator.Write(b)
+	l.mu.Unlock()
+}
+
+// Close flushes and closes the JSON log.
+func (l *jsonLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rotator.Close()
+}
+
 // setLogLevel sets the logging level for provided subsystem.  Invalid
 // subsystems are ignored.  Uninitialized subsystems are dynamically created as
 // needed.
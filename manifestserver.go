@@ -0,0 +1,130 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/decred/tumblebit/tumbler"
+)
+
+// manifestTimeout limits the time spent serving a single manifest request.
+const manifestTimeout = 30 * time.Second
+
+// manifestEntry is the JSON encoding of a signed epoch manifest. The epoch
+// and fingerprint fields make the endpoint usable as the key transparency
+// log of dcrtumble.
+type manifestEntry struct {
+	Version      uint32 `json:"version"`
+	Network      string `json:"network"`
+	Epoch        int32  `json:"epoch"`
+	Denomination int64  `json:"denomination"`
+	Fingerprint  string `json:"fingerprint"`
+	Address      string `json:"address"`
+	IdentityKey  string `json:"identitykey"`
+	Signature    string `json:"signature"`
+}
+
+// manifestHandler serves manifests of the epoch selected with the epoch
+// query parameter, or of the current epoch if it's omitted.
+type manifestHandler struct {
+	tb *tumbler.Tumbler
+}
+
+func (h manifestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var epoch int64
+	if e := r.URL.Query().Get("epoch"); e != "" {
+		var err error
+		epoch, err = strconv.ParseInt(e, 10, 32)
+		if err != nil || epoch <= 0 {
+			http.Error(w, "invalid epoch", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), manifestTimeout)
+	defer cancel()
+	m, err := h.tb.EpochManifest(ctx, int32(epoch))
+	switch err {
+	case nil:
+	case tumbler.ErrEpochNotFound:
+		http.Error(w, "unknown epoch", http.StatusNotFound)
+		return
+	default:
+		log.Errorf("Failed to serve the manifest of epoch %d: %v",
+			epoch, err)
+		http.Error(w, "temporary failure",
+			http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&manifestEntry{
+		Version:      m.Version,
+		Network:      m.Network,
+		Epoch:        m.Epoch,
+		Denomination: m.Denomination.Atoms(),
+		Fingerprint:  hex.EncodeToString(m.PuzzleKeyHash),
+		Address:      m.Address,
+		IdentityKey:  hex.EncodeToString(m.IdentityKey),
+		Signature:    hex.EncodeToString(m.Signature),
+	})
+}
+
+// startManifestServer publishes epoch manifests signed by the tumbler and
+// the audit logs of epochs, if any, on the configured address until the
+// context is cancelled. They are served over HTTPS with the RPC key pairs
+// unless server TLS is disabled.
+func startManifestServer(ctx context.Context, tb *tumbler.Tumbler, audit *auditLog, certs *rpcCerts) error {
+	lis, err := net.Listen("tcp", cfg.ManifestListen)
+	if err != nil {
+		return err
+	}
+	if !cfg.DisableServerTLS {
+		lis = tls.NewListener(lis, &tls.Config{
+			GetCertificate: certs.getCertificate,
+			MinVersion:     tls.VersionTLS12,
+		})
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/manifest", manifestHandler{tb: tb})
+	if audit != nil {
+		mux.Handle("/audit", auditHandler{log: audit})
+	}
+	server := &http.Server{
+		Handler:      mux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: manifestTimeout + 10*time.Second,
+	}
+
+	go func() {
+		log.Infof("Manifest server listening on %s", lis.Addr())
+		err := server.Serve(lis)
+		if err != nil && err != http.ErrServerClosed {
+			log.Errorf("Manifest server failed: %v", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(),
+			metricsShutdownTimeout)
+		defer cancel()
+		server.Shutdown(sctx)
+	}()
+
+	return nil
+}
@@ -0,0 +1,403 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package metrics implements counters, gauges and histograms exported in
+// the Prometheus text exposition format.
+//
+// Only the subset of the format required by the tumbler is supported:
+// metrics are either unlabeled or partitioned by a single label.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefBuckets are default histogram buckets suitable for measuring the
+// latency of operations in seconds.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// collector is a metric family that can be written out.
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+// Registry is a collection of metrics exported together.
+type Registry struct {
+	mu         sync.Mutex
+	names      map[string]struct{}
+	collectors []collector
+}
+
+// NewRegistry returns an empty metrics registry.
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]struct{})}
+}
+
+func (r *Registry) register(name string, c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.names[name]; exists {
+		panic(fmt.Sprintf("metric %s is already registered", name))
+	}
+	r.names[name] = struct{}{}
+	r.collectors = append(r.collectors, c)
+}
+
+// WriteTo writes all registered metrics in the text exposition format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	collectors := make([]collector, len(r.collectors))
+	copy(collectors, r.collectors)
+	r.mu.Unlock()
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, c := range collectors {
+		c.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// ServeHTTP implements http.Handler serving scrape requests.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	r.WriteTo(w)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+func writeHeader(w *bufio.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func labelPair(label, value string) string {
+	return fmt.Sprintf(`%s="%s"`, label, labelEscaper.Replace(value))
+}
+
+// Counter is a monotonically increasing value.
+type Counter struct {
+	v uint64 // atomic
+}
+
+// Inc increments the counter by one.
+func (c *Counter) Inc() {
+	atomic.AddUint64(&c.v, 1)
+}
+
+// Add increments the counter by n.
+func (c *Counter) Add(n uint64) {
+	atomic.AddUint64(&c.v, n)
+}
+
+// Value returns the current value of the counter.
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.v)
+}
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	v int64 // atomic
+}
+
+// Inc increments the gauge by one.
+func (g *Gauge) Inc() {
+	atomic.AddInt64(&g.v, 1)
+}
+
+// Dec decrements the gauge by one.
+func (g *Gauge) Dec() {
+	atomic.AddInt64(&g.v, -1)
+}
+
+// Set sets the gauge to the value.
+func (g *Gauge) Set(v int64) {
+	atomic.StoreInt64(&g.v, v)
+}
+
+// Value returns the current value of the gauge.
+func (g *Gauge) Value() int64 {
+	return atomic.LoadInt64(&g.v)
+}
+
+// vec partitions a metric family by values of a single label.
+type vec struct {
+	name  string
+	help  string
+	typ   string
+	label string
+
+	mu      sync.Mutex
+	metrics map[string]interface{}
+	new     func() interface{}
+}
+
+func (v *vec) with(value string) interface{} {
+	v.mu.Lock()
+	m, ok := v.metrics[value]
+	if !ok {
+		m = v.new()
+		v.metrics[value] = m
+	}
+	v.mu.Unlock()
+	return m
+}
+
+func (v *vec) write(w *bufio.Writer) {
+	v.mu.Lock()
+	values := make([]string, 0, len(v.metrics))
+	for value := range v.metrics {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	metrics := make([]interface{}, len(values))
+	for i, value := range values {
+		metrics[i] = v.metrics[value]
+	}
+	v.mu.Unlock()
+
+	writeHeader(w, v.name, v.help, v.typ)
+	for i, m := range metrics {
+		var s string
+		switch m := m.(type) {
+		case *Counter:
+			s = strconv.FormatUint(m.Value(), 10)
+		case *Gauge:
+			s = strconv.FormatInt(m.Value(), 10)
+		}
+		fmt.Fprintf(w, "%s{%s} %s\n", v.name, labelPair(v.label,
+			values[i]), s)
+	}
+}
+
+// CounterVec is a family of counters partitioned by a label.
+type CounterVec struct {
+	vec
+}
+
+// NewCounterVec registers a family of counters partitioned by the label.
+func (r *Registry) NewCounterVec(name, help, label string) *CounterVec {
+	v := &CounterVec{vec{
+		name:    name,
+		help:    help,
+		typ:     "counter",
+		label:   label,
+		metrics: make(map[string]interface{}),
+		new:     func() interface{} { return new(Counter) },
+	}}
+	r.register(name, v)
+	return v
+}
+
+// With returns the counter for the label value creating it if necessary.
+func (v *CounterVec) With(value string) *Counter {
+	return v.with(value).(*Counter)
+}
+
+// GaugeVec is a family of gauges partitioned by a label.
+type GaugeVec struct {
+	vec
+}
+
+// NewGaugeVec registers a family of gauges partitioned by the label.
+func (r *Registry) NewGaugeVec(name, help, label string) *GaugeVec {
+	v := &GaugeVec{vec{
+		name:    name,
+		help:    help,
+		typ:     "gauge",
+		label:   label,
+		metrics: make(map[string]interface{}),
+		new:     func() interface{} { return new(Gauge) },
+	}}
+	r.register(name, v)
+	return v
+}
+
+// With returns the gauge for the label value creating it if necessary.
+func (v *GaugeVec) With(value string) *Gauge {
+	return v.with(value).(*Gauge)
+}
+
+// gaugeFunc is a gauge whose value is obtained when it's collected.
+type gaugeFunc struct {
+	name string
+	help string
+	fn   func() float64
+}
+
+func (g *gaugeFunc) write(w *bufio.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.fn()))
+}
+
+// NewGaugeFunc registers a gauge whose value is reported by the function
+// every time metrics are collected. The function must be safe for
+// concurrent use.
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(name, &gaugeFunc{name: name, help: help, fn: fn})
+}
+
+// Histogram counts observed values in configurable buckets.
+type Histogram struct {
+	name    string
+	help    string
+	buckets []float64
+
+	mu     sync.Mutex
+	counts []uint64 // Per bucket, the last one is +Inf
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram registers a histogram with buckets given by their upper
+// bounds in increasing order.
+func (r *Registry) NewHistogram(name, help string, buckets []float64) *Histogram {
+	h := newHistogram(name, help, buckets)
+	r.register(name, h)
+	return h
+}
+
+func newHistogram(name, help string, buckets []float64) *Histogram {
+	if !sort.Float64sAreSorted(buckets) {
+		panic("histogram buckets must be sorted")
+	}
+	return &Histogram{
+		name:    name,
+		help:    help,
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)+1),
+	}
+}
+
+// Observe adds the value to the histogram.
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+	h.mu.Lock()
+	h.counts[i]++
+	h.count++
+	h.sum += v
+	h.mu.Unlock()
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	writeHeader(w, h.name, h.help, "histogram")
+	h.writeSamples(w, "")
+}
+
+// writeSamples writes the buckets, sum and count of the histogram with the
+// label pair, if any.
+func (h *Histogram) writeSamples(w *bufio.Writer, label string) {
+	h.mu.Lock()
+	counts := make([]uint64, len(h.counts))
+	copy(counts, h.counts)
+	count, sum := h.count, h.sum
+	h.mu.Unlock()
+
+	var prefix, labels string
+	if label != "" {
+		prefix = label + ","
+		labels = "{" + label + "}"
+	}
+	var cumulative uint64
+	for i, c := range counts {
+		cumulative += c
+		le := math.Inf(1)
+		if i < len(h.buckets) {
+			le = h.buckets[i]
+		}
+		fmt.Fprintf(w, "%s_bucket{%s%s} %d\n", h.name, prefix,
+			labelPair("le", formatFloat(le)), cumulative)
+	}
+	fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labels, formatFloat(sum))
+	fmt.Fprintf(w, "%s_count%s %d\n", h.name, labels, count)
+}
+
+// HistogramVec is a family of histograms partitioned by a label.
+type HistogramVec struct {
+	name    string
+	help    string
+	label   string
+	buckets []float64
+
+	mu         sync.Mutex
+	histograms map[string]*Histogram
+}
+
+// NewHistogramVec registers a family of histograms with the buckets
+// partitioned by the label.
+func (r *Registry) NewHistogramVec(name, help, label string, buckets []float64) *HistogramVec {
+	if !sort.Float64sAreSorted(buckets) {
+		panic("histogram buckets must be sorted")
+	}
+	v := &HistogramVec{
+		name:       name,
+		help:       help,
+		label:      label,
+		buckets:    buckets,
+		histograms: make(map[string]*Histogram),
+	}
+	r.register(name, v)
+	return v
+}
+
+// With returns the histogram for the label value creating it if necessary.
+func (v *HistogramVec) With(value string) *Histogram {
+	v.mu.Lock()
+	h, ok := v.histograms[value]
+	if !ok {
+		h = newHistogram(v.name, v.help, v.buckets)
+		v.histograms[value] = h
+	}
+	v.mu.Unlock()
+	return h
+}
+
+func (v *HistogramVec) write(w *bufio.Writer) {
+	v.mu.Lock()
+	values := make([]string, 0, len(v.histograms))
+	for value := range v.histograms {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	histograms := make([]*Histogram, len(values))
+	for i, value := range values {
+		histograms[i] = v.histograms[value]
+	}
+	v.mu.Unlock()
+
+	writeHeader(w, v.name, v.help, "histogram")
+	for i, h := range histograms {
+		h.writeSamples(w, labelPair(v.label, values[i]))
+	}
+}
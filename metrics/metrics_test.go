@@ -0,0 +1,87 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	r := NewRegistry()
+
+	sessions := r.NewGaugeVec("sessions", "Active sessions", "state")
+	sessions.With("b").Inc()
+	sessions.With("a").Inc()
+	sessions.With("a").Inc()
+	sessions.With("b").Dec()
+
+	errs := r.NewCounterVec("errors_total", "Errors", "method")
+	errs.With(`Get"x"`).Add(3)
+
+	r.NewGaugeFunc("epochs", "Epochs", func() float64 { return 2 })
+
+	h := r.NewHistogram("latency_seconds", "Latency", []float64{0.1, 1})
+	h.Observe(0.05)
+	h.Observe(0.1)
+	h.Observe(0.5)
+	h.Observe(3)
+
+	hv := r.NewHistogramVec("call_seconds", "Calls", "method", []float64{1})
+	hv.With("b").Observe(2)
+	hv.With("a").Observe(0.5)
+
+	var buf bytes.Buffer
+	n, err := r.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Fatalf("reported %d bytes written, got %d", n, buf.Len())
+	}
+
+	expected := `# HELP sessions Active sessions
+# TYPE sessions gauge
+sessions{state="a"} 2
+sessions{state="b"} 0
+# HELP errors_total Errors
+# TYPE errors_total counter
+errors_total{method="Get\"x\""} 3
+# HELP epochs Epochs
+# TYPE epochs gauge
+epochs 2
+# HELP latency_seconds Latency
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 2
+latency_seconds_bucket{le="1"} 3
+latency_seconds_bucket{le="+Inf"} 4
+latency_seconds_sum 3.65
+latency_seconds_count 4
+# HELP call_seconds Calls
+# TYPE call_seconds histogram
+call_seconds_bucket{method="a",le="1"} 1
+call_seconds_bucket{method="a",le="+Inf"} 1
+call_seconds_sum{method="a"} 0.5
+call_seconds_count{method="a"} 1
+call_seconds_bucket{method="b",le="1"} 0
+call_seconds_bucket{method="b",le="+Inf"} 1
+call_seconds_sum{method="b"} 2
+call_seconds_count{method="b"} 1
+`
+	if buf.String() != expected {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestDuplicateRegistration(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("duplicate metric was registered")
+		}
+	}()
+	r := NewRegistry()
+	r.NewGaugeFunc("epochs", "Epochs", func() float64 { return 0 })
+	r.NewCounterVec("epochs", "Epochs", "state")
+}
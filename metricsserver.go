@@ -0,0 +1,52 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/decred/tumblebit/metrics"
+)
+
+// metricsShutdownTimeout limits the time spent completing scrape requests
+// in progress during shutdown.
+const metricsShutdownTimeout = 5 * time.Second
+
+// startMetricsServer serves metrics collected in the registry over HTTP
+// on the configured address until the context is cancelled.
+func startMetricsServer(ctx context.Context, registry *metrics.Registry) error {
+	lis, err := net.Listen("tcp", cfg.MetricsListen)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", registry)
+	server := &http.Server{
+		Handler:      mux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		log.Infof("Metrics server listening on %s", lis.Addr())
+		err := server.Serve(lis)
+		if err != nil && err != http.ErrServerClosed {
+			log.Errorf("Metrics server failed: %v", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(),
+			metricsShutdownTimeout)
+		defer cancel()
+		server.Shutdown(sctx)
+	}()
+
+	return nil
+}
@@ -5,14 +5,57 @@
 
 package netparams
 
-import "github.com/decred/dcrd/chaincfg"
+import (
+	"time"
+
+	"github.com/decred/dcrd/chaincfg"
+)
 
 // Params is used to group parameters for various networks such as the main
 // network and test networks.
 type Params struct {
 	*chaincfg.Params
 	WalletClientPort  string
+	WalletJSONRPCPort string
 	TumblerServerPort string
+	Protocol
+}
+
+// Protocol groups defaults of the TumbleBit protocol parameters of a
+// network. Production networks use values providing the intended security
+// of the fairness tests, while the simulation network trades security for
+// short epochs and quick exchanges.
+type Protocol struct {
+	// Number of blocks funds stay escrowed within an epoch and between
+	// two consecutive epochs.
+	EpochDuration int32
+	EpochRenewal  int32
+
+	// Expected time between two blocks, used to estimate deadlines.
+	ConfirmationInterval time.Duration
+
+	// Number of real and fake items of the fairness tests of the
+	// Puzzle-Promise and Puzzle-Solver protocols.
+	RealTransactionCount int
+	FakeTransactionCount int
+	RealPreimageCount    int
+	FakePreimageCount    int
+
+	// Minimum security level in bits of the fairness tests.
+	MinSecurityBits int
+}
+
+// productionProtocol provides about 80 bits of security with epochs of
+// ten blocks.
+var productionProtocol = Protocol{
+	EpochDuration:        10,
+	EpochRenewal:         5,
+	ConfirmationInterval: 5 * time.Minute,
+	RealTransactionCount: 42,
+	FakeTransactionCount: 42,
+	RealPreimageCount:    15,
+	FakePreimageCount:    285,
+	MinSecurityBits:      80,
 }
 
 // MainNetParams contains parameters specific running tumblebit and
@@ -20,7 +63,9 @@ type Params struct {
 var MainNetParams = Params{
 	Params:            &chaincfg.MainNetParams,
 	WalletClientPort:  "9111",
+	WalletJSONRPCPort: "9110",
 	TumblerServerPort: "9191",
+	Protocol:          productionProtocol,
 }
 
 // TestNet2Params contains parameters specific running tumblebit and
@@ -28,7 +73,9 @@ var MainNetParams = Params{
 var TestNet2Params = Params{
 	Params:            &chaincfg.TestNet2Params,
 	WalletClientPort:  "19111",
+	WalletJSONRPCPort: "19110",
 	TumblerServerPort: "19191",
+	Protocol:          productionProtocol,
 }
 
 // SimNetParams contains parameters specific to the simulation test network
@@ -36,5 +83,16 @@ var TestNet2Params = Params{
 var SimNetParams = Params{
 	Params:            &chaincfg.SimNetParams,
 	WalletClientPort:  "19558",
+	WalletJSONRPCPort: "19557",
 	TumblerServerPort: "19598",
+	Protocol: Protocol{
+		EpochDuration:        6,
+		EpochRenewal:         3,
+		ConfirmationInterval: 30 * time.Second,
+		RealTransactionCount: 8,
+		FakeTransactionCount: 8,
+		RealPreimageCount:    4,
+		FakePreimageCount:    28,
+		MinSecurityBits:      12,
+	},
 }
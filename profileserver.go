@@ -0,0 +1,76 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	rpprof "runtime/pprof"
+	"time"
+)
+
+// profileShutdownTimeout limits the time spent completing profile requests
+// in progress during shutdown.
+const profileShutdownTimeout = 5 * time.Second
+
+// startProfileServer serves runtime profiling data in the format expected
+// by go tool pprof over HTTP on the configured address until the context
+// is cancelled.
+func startProfileServer(ctx context.Context) error {
+	lis, err := net.Listen("tcp", cfg.ProfileListen)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	// CPU profiles and traces are written for as long as requested, so
+	// responses aren't limited in time.
+	server := &http.Server{
+		Handler:     mux,
+		ReadTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		log.Infof("Profile server listening on %s", lis.Addr())
+		err := server.Serve(lis)
+		if err != nil && err != http.ErrServerClosed {
+			log.Errorf("Profile server failed: %v", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(),
+			profileShutdownTimeout)
+		defer cancel()
+		server.Shutdown(sctx)
+	}()
+
+	return nil
+}
+
+// writeMemProfile writes a heap profile to the file.
+func writeMemProfile(path string) {
+	f, err := os.Create(path)
+	if err != nil {
+		log.Errorf("Unable to create the memory profile: %v", err)
+		return
+	}
+	defer f.Close()
+
+	// Collect garbage so that the profile is up to date.
+	runtime.GC()
+	if err = rpprof.WriteHeapProfile(f); err != nil {
+		log.Errorf("Unable to write the memory profile: %v", err)
+	}
+}
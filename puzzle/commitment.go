@@ -0,0 +1,96 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package puzzle
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"golang.org/x/crypto/blake2s"
+)
+
+// Versions of index list commitment schemes.
+const (
+	// CommitmentLegacy commits to the salted hash of the encoded index
+	// list produced by HashIndexList.
+	CommitmentLegacy = 0
+
+	// CommitmentV1 prefixes the encoded index list with a domain tag, the
+	// version, the protocol phase and the length of the list.
+	CommitmentV1 = 1
+)
+
+// commitmentTag separates index list commitments from other uses of the
+// hash function.
+const commitmentTag = "tumblebit/index-list-commitment"
+
+// Phase identifies the step of the protocol an index list is committed to.
+type Phase uint8
+
+// Index lists committed to in the course of the protocol.
+const (
+	PhaseFakeTransactions Phase = iota + 1
+	PhaseRealTransactions
+)
+
+// ErrCommitmentMismatch is returned when the index list doesn't match the
+// commitment.
+var ErrCommitmentMismatch = errors.New("index list doesn't match the " +
+	"commitment")
+
+// IndexCommitment describes how an index list revealed in a particular
+// phase of the protocol is committed to.
+type IndexCommitment struct {
+	Version uint32
+	Phase   Phase
+}
+
+// Commit produces a salted commitment to the index list.
+func (c IndexCommitment) Commit(salt []byte, indexList []int) ([]byte, error) {
+	switch c.Version {
+	case CommitmentLegacy:
+		return HashIndexList(salt, indexList)
+	case CommitmentV1:
+	default:
+		return nil, fmt.Errorf("unknown commitment version %d", c.Version)
+	}
+
+	if len(indexList) > math.MaxUint16 {
+		return nil, fmt.Errorf("index list is too long: %d",
+			len(indexList))
+	}
+	buf, err := EncodeIndexList(indexList)
+	if err != nil {
+		return nil, err
+	}
+	h, err := blake2s.New256(salt)
+	if err != nil {
+		return nil, err
+	}
+	var hdr [4]byte
+	hdr[0] = byte(c.Version)
+	hdr[1] = byte(c.Phase)
+	binary.LittleEndian.PutUint16(hdr[2:], uint16(len(indexList)))
+	h.Write([]byte(commitmentTag))
+	h.Write(hdr[:])
+	h.Write(buf)
+	return h.Sum(nil), nil
+}
+
+// Verify makes sure the commitment was produced for the index list with
+// the salt. ErrCommitmentMismatch is returned if it wasn't.
+func (c IndexCommitment) Verify(salt []byte, indexList []int, commitment []byte) error {
+	expected, err := c.Commit(salt, indexList)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(expected, commitment) != 1 {
+		return ErrCommitmentMismatch
+	}
+	return nil
+}
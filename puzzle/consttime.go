@@ -0,0 +1,26 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package puzzle
+
+import (
+	"crypto/subtle"
+	"math/big"
+)
+
+// equalValues returns whether big-endian encodings a and b represent the
+// same value of the group modulo n. Both are padded to the size of the
+// modulus before they are compared in constant time, so that the time
+// taken doesn't depend on their leading zeros either.
+func equalValues(n *big.Int, a, b []byte) bool {
+	size := (n.BitLen() + 7) / 8
+	if len(a) > size || len(b) > size {
+		return false
+	}
+	pa := make([]byte, size)
+	pb := make([]byte, size)
+	copy(pa[size-len(a):], a)
+	copy(pb[size-len(b):], b)
+	return subtle.ConstantTimeCompare(pa, pb) == 1
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package puzzle
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Limits of the puzzle difficulty, i.e. the size of the RSA modulus of
+// puzzle keys in bits.
+const (
+	// MinDifficulty provides 112 bits of security, the least considered
+	// acceptable for new keys.
+	MinDifficulty = 2048
+
+	// MaxDifficulty provides 256 bits of security.
+	MaxDifficulty = 15360
+)
+
+// DifficultyProfile describes puzzle keys providing a level of security.
+type DifficultyProfile struct {
+	// SecurityBits is the estimated strength of puzzle keys, i.e. the
+	// base 2 logarithm of the work required to factor the modulus.
+	SecurityBits int
+
+	// ModulusBits is the size of the RSA modulus of puzzle keys.
+	ModulusBits int
+
+	// Primes is the number of prime factors of the modulus.
+	Primes int
+}
+
+// Strength of RSA moduli of common sizes as estimated by NIST SP 800-57.
+var securityLevels = []struct {
+	modulusBits  int
+	securityBits int
+}{
+	{1024, 80},
+	{2048, 112},
+	{3072, 128},
+	{7680, 192},
+	{15360, 256},
+}
+
+// profiles lists presets selectable by bits of security.
+var profiles = map[int]DifficultyProfile{
+	128: ProfileForDifficulty(3072),
+	192: ProfileForDifficulty(7680),
+	256: ProfileForDifficulty(15360),
+}
+
+// LookupProfile returns the difficulty profile providing the specified
+// bits of security.
+func LookupProfile(securityBits int) (DifficultyProfile, error) {
+	p, ok := profiles[securityBits]
+	if !ok {
+		return DifficultyProfile{}, fmt.Errorf("no puzzle difficulty "+
+			"profile provides %d bits of security", securityBits)
+	}
+	return p, nil
+}
+
+// ProfileSecurityLevels returns bits of security of all profiles in an
+// ascending order.
+func ProfileSecurityLevels() []int {
+	levels := make([]int, 0, len(profiles))
+	for bits := range profiles {
+		levels = append(levels, bits)
+	}
+	sort.Ints(levels)
+	return levels
+}
+
+// ProfileForDifficulty describes puzzle keys with the modulus of the
+// specified size. Moduli between common sizes are assumed to provide the
+// security of the smaller one.
+func ProfileForDifficulty(difficulty int) DifficultyProfile {
+	p := DifficultyProfile{
+		ModulusBits: difficulty,
+		Primes:      primeCount(difficulty),
+	}
+	for _, l := range securityLevels {
+		if difficulty >= l.modulusBits {
+			p.SecurityBits = l.securityBits
+		}
+	}
+	return p
+}
+
+// ValidateDifficulty makes sure puzzle keys of the difficulty are secure
+// and can be generated in a reasonable time.
+func ValidateDifficulty(difficulty int) error {
+	switch {
+	case difficulty < MinDifficulty || difficulty > MaxDifficulty:
+		return fmt.Errorf("puzzle difficulty %d is out of range "+
+			"[%d, %d]", difficulty, MinDifficulty, MaxDifficulty)
+	case difficulty%8 != 0:
+		return fmt.Errorf("puzzle difficulty %d is not a multiple of 8",
+			difficulty)
+	}
+	return nil
+}
+
+// primeCount determines the safe number of primes for a specified
+// difficulty according to the following paper by M. Jason Hinek:
+// http://www.cacr.math.uwaterloo.ca/techreports/2006/cacr2006-16.pdf
+func primeCount(difficulty int) int {
+	switch {
+	case difficulty >= 8192:
+		return 5
+	case difficulty >= 4096:
+		return 4
+	case difficulty >= 1024:
+		return 3
+	}
+	return 2
+}
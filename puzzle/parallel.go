@@ -0,0 +1,59 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package puzzle
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// ForEach calls fn for every index in [0, n) using at most the specified
+// number of concurrent workers, or one per CPU if it isn't positive, and
+// returns the first error encountered. Remaining work is abandoned as soon
+// as a call fails or the context is cancelled. It's meant for verifying
+// and computing puzzles and signatures of an exchange, which are
+// independent of each other.
+func ForEach(ctx context.Context, workers, n int, fn func(i int) error) error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	next := int64(-1)
+	for w := 0; w < workers; w++ {
+		g.Go(func() error {
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= n {
+					return nil
+				}
+				if err := gctx.Err(); err != nil {
+					return err
+				}
+				if err := fn(i); err != nil {
+					return err
+				}
+			}
+		})
+	}
+	return g.Wait()
+}
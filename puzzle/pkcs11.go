@@ -0,0 +1,212 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build pkcs11
+
+package puzzle
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+)
+
+// pkcs11Provider generates puzzle keys on a token of a hardware security
+// module. Keys are session objects which can't be extracted from the
+// token and are deleted by the token once the provider is closed, just
+// like keys held in memory are lost when the process exits.
+type pkcs11Provider struct {
+	ctx *pkcs11.Ctx
+
+	// Operations of a session must not be interleaved.
+	mu      sync.Mutex
+	session pkcs11.SessionHandle
+}
+
+// NewPKCS11Provider loads the PKCS#11 library of the module and logs in to
+// the configured token.
+func NewPKCS11Provider(cfg *PKCS11Config) (KeyProvider, error) {
+	ctx := pkcs11.New(cfg.Module)
+	if ctx == nil {
+		return nil, fmt.Errorf("failed to load PKCS#11 module %s",
+			cfg.Module)
+	}
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, fmt.Errorf("failed to initialize PKCS#11 module: %v",
+			err)
+	}
+	slot, err := findToken(ctx, cfg.TokenLabel)
+	if err != nil {
+		unload(ctx)
+		return nil, err
+	}
+	session, err := ctx.OpenSession(slot,
+		pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		unload(ctx)
+		return nil, fmt.Errorf("failed to open a session: %v", err)
+	}
+	if err = ctx.Login(session, pkcs11.CKU_USER, cfg.PIN); err != nil {
+		ctx.CloseSession(session)
+		unload(ctx)
+		return nil, fmt.Errorf("failed to log in to the token: %v", err)
+	}
+	return &pkcs11Provider{ctx: ctx, session: session}, nil
+}
+
+// unload finalizes and unloads the PKCS#11 library.
+func unload(ctx *pkcs11.Ctx) {
+	ctx.Finalize()
+	ctx.Destroy()
+}
+
+// findToken returns the slot of the token with the label or of the first
+// token present if the label is empty.
+func findToken(ctx *pkcs11.Ctx, label string) (uint, error) {
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list PKCS#11 slots: %v", err)
+	}
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err != nil {
+			continue
+		}
+		if label == "" || info.Label == label {
+			return slot, nil
+		}
+	}
+	if label == "" {
+		return 0, errors.New("no PKCS#11 token is present")
+	}
+	return 0, fmt.Errorf("no PKCS#11 token labeled %q", label)
+}
+
+// GenerateKey generates a key pair on the token. The modulus of keys
+// generated by tokens is the product of two primes regardless of the
+// difficulty.
+func (p *pkcs11Provider) GenerateKey(difficulty int) (*PuzzleKey, error) {
+	mech := []*pkcs11.Mechanism{
+		pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN, nil),
+	}
+	public := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, false),
+		pkcs11.NewAttribute(pkcs11.CKA_ENCRYPT, true),
+		pkcs11.NewAttribute(pkcs11.CKA_MODULUS_BITS, difficulty),
+		pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT,
+			[]byte{0x01, 0x00, 0x01}),
+	}
+	private := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, false),
+		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+		pkcs11.NewAttribute(pkcs11.CKA_DECRYPT, true),
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pubObj, privObj, err := p.ctx.GenerateKeyPair(p.session, mech, public,
+		private)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate a key pair: %v", err)
+	}
+	attrs, err := p.ctx.GetAttributeValue(p.session, pubObj,
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+		})
+	// Only the private key is needed on the token.
+	p.ctx.DestroyObject(p.session, pubObj)
+	if err == nil && len(attrs) != 2 {
+		err = errors.New("missing attributes")
+	}
+	if err != nil {
+		p.ctx.DestroyObject(p.session, privObj)
+		return nil, fmt.Errorf("failed to read the public key: %v", err)
+	}
+
+	pub := &rsa.PublicKey{N: new(big.Int).SetBytes(attrs[0].Value)}
+	e := new(big.Int).SetBytes(attrs[1].Value)
+	if !e.IsInt64() || e.Int64() > 1<<31-1 {
+		p.ctx.DestroyObject(p.session, privObj)
+		return nil, errors.New("public exponent too large")
+	}
+	pub.E = int(e.Int64())
+
+	pk, err := NewExternalKey(pub, &pkcs11Key{p: p, obj: privObj,
+		size: (pub.N.BitLen() + 7) / 8})
+	if err != nil {
+		p.ctx.DestroyObject(p.session, privObj)
+		return nil, err
+	}
+	return pk, nil
+}
+
+// Close logs out of the token, which deletes the keys generated by the
+// provider, and unloads the module.
+func (p *pkcs11Provider) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ctx.Logout(p.session)
+	err := p.ctx.CloseSession(p.session)
+	unload(p.ctx)
+	return err
+}
+
+// pkcs11Key is a private key held on the token.
+type pkcs11Key struct {
+	p         *pkcs11Provider
+	obj       pkcs11.ObjectHandle
+	size      int
+	destroyed bool // Protected by the provider mutex
+}
+
+// Decrypt decrypts the ciphertext with the raw RSA mechanism of the token.
+func (k *pkcs11Key) Decrypt(c *big.Int) (*big.Int, error) {
+	// The ciphertext must be as long as the modulus.
+	b := c.Bytes()
+	if len(b) > k.size {
+		return nil, errors.New("value too large")
+	}
+	in := make([]byte, k.size)
+	copy(in[k.size-len(b):], b)
+
+	k.p.mu.Lock()
+	defer k.p.mu.Unlock()
+	if k.destroyed {
+		return nil, errors.New("puzzle key has been destroyed")
+	}
+	mech := []*pkcs11.Mechanism{
+		pkcs11.NewMechanism(pkcs11.CKM_RSA_X_509, nil),
+	}
+	if err := k.p.ctx.DecryptInit(k.p.session, mech, k.obj); err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %v", err)
+	}
+	out, err := k.p.ctx.Decrypt(k.p.session, in)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %v", err)
+	}
+	return new(big.Int).SetBytes(out), nil
+}
+
+// Destroy deletes the private key from the token.
+func (k *pkcs11Key) Destroy() {
+	k.p.mu.Lock()
+	defer k.p.mu.Unlock()
+	if !k.destroyed {
+		k.p.ctx.DestroyObject(k.p.session, k.obj)
+		k.destroyed = true
+	}
+}
@@ -0,0 +1,16 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build !pkcs11
+
+package puzzle
+
+import "errors"
+
+// NewPKCS11Provider fails unless PKCS#11 support is enabled with the
+// pkcs11 build tag, which requires cgo.
+func NewPKCS11Provider(cfg *PKCS11Config) (KeyProvider, error) {
+	return nil, errors.New("PKCS#11 support requires building with the " +
+		"pkcs11 tag")
+}
@@ -0,0 +1,253 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package puzzle
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var (
+	// ErrChainLength is returned when the chain doesn't have a quotient
+	// for every puzzle or a secret for every puzzle when secrets are
+	// known.
+	ErrChainLength = errors.New("promise chain lengths don't match")
+
+	// ErrChainOrigin is returned when the first quotient of the chain
+	// isn't one.
+	ErrChainOrigin = errors.New("first quotient of the promise chain " +
+		"isn't one")
+
+	// ErrChainSecrets is returned when the chain is extended without
+	// knowing secrets of its puzzles.
+	ErrChainSecrets = errors.New("secrets of the promise chain are unknown")
+)
+
+// PromiseChain links puzzles of the real transactions promised in the
+// puzzle-promise protocol together with quotients of their secrets. The
+// first quotient is always one and every following quotient q_i links the
+// i'th puzzle to the preceding one:
+//
+//	z_i = z_(i-1) * q_i^e mod N
+//
+// The tumbler, knowing the secrets s_i with s_i = s_(i-1) * q_i mod N,
+// builds the chain with Extend and the payee, knowing only the puzzles and
+// the quotients, checks it with Verify. Once the payee learns any secret,
+// all of them can be recovered. Payees of payment channels are instead
+// given the puzzles alone and Unlock them one payment at a time.
+type PromiseChain struct {
+	Key       *PuzzlePubKey
+	Puzzles   [][]byte
+	Quotients [][]byte
+
+	// Secrets of the puzzles, only known to the tumbler.
+	Secrets [][]byte
+}
+
+// NewPromiseChain creates the chain of the puzzles from their secrets.
+func NewPromiseChain(pk *PuzzlePubKey, puzzles, secrets [][]byte) (*PromiseChain, error) {
+	if len(puzzles) != len(secrets) {
+		return nil, ErrChainLength
+	}
+	c := &PromiseChain{Key: pk, Secrets: [][]byte{}}
+	for i := range puzzles {
+		if err := c.Extend(puzzles[i], secrets[i]); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Len returns the number of puzzles in the chain.
+func (c *PromiseChain) Len() int {
+	return len(c.Puzzles)
+}
+
+// Extend appends the puzzle solved by the secret to the chain and links
+// it to the last puzzle of the chain. Secrets of all puzzles of the chain
+// must be known.
+func (c *PromiseChain) Extend(puzzle, secret []byte) error {
+	if c.Key == nil || c.Key.N == nil || c.Key.N.Sign() <= 0 {
+		return errors.New("promise chain doesn't have a puzzle key")
+	}
+	if c.Secrets == nil && len(c.Puzzles) > 0 {
+		return ErrChainSecrets
+	}
+	if len(c.Secrets) != len(c.Puzzles) ||
+		len(c.Quotients) != len(c.Puzzles) {
+		return ErrChainLength
+	}
+	if err := c.checkValue("puzzle", puzzle); err != nil {
+		return err
+	}
+	if err := c.checkValue("secret", secret); err != nil {
+		return err
+	}
+	if !ValidatePuzzle(c.Key, puzzle, secret) {
+		return errors.New("secret doesn't solve the puzzle")
+	}
+
+	q, err := c.nextQuotient(secret)
+	if err != nil {
+		return err
+	}
+
+	c.Puzzles = append(c.Puzzles, puzzle)
+	c.Quotients = append(c.Quotients, q)
+	c.Secrets = append(c.Secrets, secret)
+	return nil
+}
+
+// Unlock records the secret of the next puzzle of a chain revealed one
+// puzzle at a time, such as promises made to the payee of a payment
+// channel. The payee knows all puzzles of the chain but neither their
+// secrets nor quotients, so that every payment solving the next puzzle
+// unlocks a single promise. The quotient linking the secret to the
+// preceding one is appended to the chain, and the number of unlocked
+// puzzles is returned.
+func (c *PromiseChain) Unlock(secret []byte) (int, error) {
+	if c.Key == nil || c.Key.N == nil || c.Key.N.Sign() <= 0 {
+		return 0, errors.New("promise chain doesn't have a puzzle key")
+	}
+	n := len(c.Secrets)
+	if len(c.Quotients) != n {
+		return n, ErrChainLength
+	}
+	if n >= len(c.Puzzles) {
+		return n, errors.New("all puzzles of the promise chain are " +
+			"unlocked")
+	}
+	if err := c.checkValue("secret", secret); err != nil {
+		return n, err
+	}
+	if !ValidatePuzzle(c.Key, c.Puzzles[n], secret) {
+		return n, fmt.Errorf("secret doesn't solve puzzle %d", n)
+	}
+
+	q, err := c.nextQuotient(secret)
+	if err != nil {
+		return n, err
+	}
+	c.Quotients = append(c.Quotients, q)
+	c.Secrets = append(c.Secrets, secret)
+	return n + 1, nil
+}
+
+// Unlocked returns the number of puzzles of the chain with known secrets.
+func (c *PromiseChain) Unlocked() int {
+	return len(c.Secrets)
+}
+
+// nextQuotient returns the quotient linking the secret to the last known
+// secret of the chain, or one if no secrets are known yet.
+func (c *PromiseChain) nextQuotient(secret []byte) ([]byte, error) {
+	n := len(c.Secrets)
+	if n == 0 {
+		return bigOne.Bytes(), nil
+	}
+	// q = s_i / s_(i-1) mod N = s_i * s_(i-1)^-1 mod N
+	prev := new(big.Int).SetBytes(c.Secrets[n-1])
+	inv, ok := modInverse(prev, c.Key.N)
+	if !ok {
+		return nil, errors.New("malformed secret")
+	}
+	q := new(big.Int).SetBytes(secret)
+	q.Mul(q, inv)
+	q.Mod(q, c.Key.N)
+	return q.Bytes(), nil
+}
+
+// Validate checks the structure of the chain: every puzzle has a
+// quotient and, if secrets are known, a secret, all values are members of
+// the group and the first quotient is one. Links aren't verified.
+func (c *PromiseChain) Validate() error {
+	if c.Key == nil || c.Key.N == nil || c.Key.N.Sign() <= 0 {
+		return errors.New("promise chain doesn't have a puzzle key")
+	}
+	if len(c.Puzzles) == 0 {
+		return errors.New("promise chain is empty")
+	}
+	if len(c.Quotients) != len(c.Puzzles) ||
+		(c.Secrets != nil && len(c.Secrets) != len(c.Puzzles)) {
+		return ErrChainLength
+	}
+	if new(big.Int).SetBytes(c.Quotients[0]).Cmp(bigOne) != 0 {
+		return ErrChainOrigin
+	}
+	for i := range c.Puzzles {
+		if err := c.checkValue("puzzle", c.Puzzles[i]); err != nil {
+			return err
+		}
+		if err := c.checkValue("quotient", c.Quotients[i]); err != nil {
+			return err
+		}
+		if c.Secrets == nil {
+			continue
+		}
+		if err := c.checkValue("secret", c.Secrets[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyLink verifies that the i'th quotient links the i'th puzzle to the
+// preceding one and, if secrets are known, the i'th secret to the
+// preceding one. The first puzzle is linked to itself, so that the chain
+// of n puzzles has n links. Links don't depend on each other and may be
+// verified concurrently after the chain has been validated.
+func (c *PromiseChain) VerifyLink(i int) error {
+	if i < 0 || i >= len(c.Puzzles) || i >= len(c.Quotients) {
+		return fmt.Errorf("link %d is out of the promise chain", i)
+	}
+	if i == 0 {
+		if new(big.Int).SetBytes(c.Quotients[0]).Cmp(bigOne) != 0 {
+			return ErrChainOrigin
+		}
+		return nil
+	}
+
+	if !VerifyQuotient(c.Key, c.Quotients, c.Puzzles, i) {
+		return fmt.Errorf("quotient %d doesn't link puzzles", i)
+	}
+	if c.Secrets == nil {
+		return nil
+	}
+	if i >= len(c.Secrets) {
+		return ErrChainLength
+	}
+	s := new(big.Int).SetBytes(c.Secrets[i-1])
+	s.Mul(s, new(big.Int).SetBytes(c.Quotients[i]))
+	s.Mod(s, c.Key.N)
+	if !equalValues(c.Key.N, c.Secrets[i], s.Bytes()) {
+		return fmt.Errorf("quotient %d doesn't link secrets", i)
+	}
+	return nil
+}
+
+// Verify validates the chain and verifies all of its links.
+func (c *PromiseChain) Verify() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	for i := range c.Puzzles {
+		if err := c.VerifyLink(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkValue makes sure the value is a member of the multiplicative group
+// modulo N in its canonical encoding, i.e. it's non-zero, less than N and
+// doesn't have leading zeros.
+func (c *PromiseChain) checkValue(name string, value []byte) error {
+	v := new(big.Int).SetBytes(value)
+	if v.Sign() == 0 || v.Cmp(c.Key.N) >= 0 || len(value) != len(v.Bytes()) {
+		return fmt.Errorf("%s is out of range", name)
+	}
+	return nil
+}
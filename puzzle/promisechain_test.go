@@ -0,0 +1,208 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package puzzle
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+	"testing/quick"
+)
+
+// newTestChain creates a chain of n puzzles along with their secrets.
+func newTestChain(t *testing.T, pk *PuzzleKey, n int) *PromiseChain {
+	puzzles := make([][]byte, n)
+	secrets := make([][]byte, n)
+	for i := range puzzles {
+		var err error
+		puzzles[i], _, secrets[i], err = NewPuzzlePromise(pk, []byte{byte(i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	c, err := NewPromiseChain(pk.PublicKey(), puzzles, secrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestPromiseChain(t *testing.T) {
+	priv, err := GeneratePuzzleKey(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk := priv.PublicKey()
+	c := newTestChain(t, priv, 8)
+	if err = c.Verify(); err != nil {
+		t.Fatalf("chain didn't verify: %v", err)
+	}
+
+	// Quotients match the ones computed from secrets.
+	quotients, err := Quotients(pk, c.Secrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range quotients {
+		if !bytes.Equal(quotients[i], c.Quotients[i]) {
+			t.Fatalf("quotient %d mismatch", i)
+		}
+	}
+
+	// The payee only knows puzzles and quotients.
+	payee := &PromiseChain{Key: pk, Puzzles: c.Puzzles,
+		Quotients: c.Quotients}
+	if err = payee.Verify(); err != nil {
+		t.Fatalf("chain of the payee didn't verify: %v", err)
+	}
+	if err = payee.Extend(c.Puzzles[0], c.Secrets[0]); err != ErrChainSecrets {
+		t.Fatalf("extended the chain without secrets: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		chain *PromiseChain
+		err   error
+	}{
+		{"no key", &PromiseChain{Puzzles: c.Puzzles,
+			Quotients: c.Quotients}, nil},
+		{"empty", &PromiseChain{Key: pk}, nil},
+		{"short quotients", &PromiseChain{Key: pk, Puzzles: c.Puzzles,
+			Quotients: c.Quotients[:7]}, ErrChainLength},
+		{"short secrets", &PromiseChain{Key: pk, Puzzles: c.Puzzles,
+			Quotients: c.Quotients, Secrets: c.Secrets[1:]},
+			ErrChainLength},
+		{"origin", &PromiseChain{Key: pk, Puzzles: c.Puzzles[1:],
+			Quotients: c.Quotients[1:]}, ErrChainOrigin},
+		{"swapped puzzles", &PromiseChain{Key: pk,
+			Puzzles:   [][]byte{c.Puzzles[1], c.Puzzles[0]},
+			Quotients: c.Quotients[:2]}, nil},
+		{"zero quotient", &PromiseChain{Key: pk, Puzzles: c.Puzzles[:2],
+			Quotients: [][]byte{c.Quotients[0], {}}}, nil},
+		{"modulus", &PromiseChain{Key: pk, Puzzles: c.Puzzles[:2],
+			Quotients: [][]byte{c.Quotients[0], pk.N.Bytes()}}, nil},
+		{"leading zero", &PromiseChain{Key: pk, Puzzles: c.Puzzles[:2],
+			Quotients: [][]byte{c.Quotients[0],
+				append([]byte{0}, c.Quotients[1]...)}}, nil},
+	}
+	for _, test := range tests {
+		err := test.chain.Verify()
+		if err == nil {
+			t.Errorf("%s: chain verified", test.name)
+			continue
+		}
+		if test.err != nil && err != test.err {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+	}
+
+	for _, i := range []int{-1, c.Len()} {
+		if err = c.VerifyLink(i); err == nil {
+			t.Errorf("link %d out of the chain verified", i)
+		}
+	}
+
+	// Secrets must solve puzzles they extend the chain with.
+	err = c.Extend(c.Puzzles[0], c.Secrets[1])
+	if err == nil || c.Len() != 8 {
+		t.Fatal("extended the chain with a wrong secret")
+	}
+	if _, err = NewPromiseChain(pk, c.Puzzles, c.Secrets[1:]); err != ErrChainLength {
+		t.Fatalf("created a chain with missing secrets: %v", err)
+	}
+}
+
+// TestPromiseChainUnlock unlocks a chain one puzzle at a time the way the
+// payee of a payment channel does.
+func TestPromiseChainUnlock(t *testing.T) {
+	priv, err := GeneratePuzzleKey(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk := priv.PublicKey()
+	c := newTestChain(t, priv, 4)
+
+	payee := &PromiseChain{Key: pk, Puzzles: c.Puzzles}
+	if _, err = payee.Unlock(c.Secrets[1]); err == nil {
+		t.Fatal("unlocked a puzzle out of order")
+	}
+	for i := range c.Secrets {
+		n, err := payee.Unlock(c.Secrets[i])
+		if err != nil {
+			t.Fatalf("failed to unlock puzzle %d: %v", i, err)
+		}
+		if n != i+1 || payee.Unlocked() != n {
+			t.Fatalf("unlocked %d puzzles, expected %d", n, i+1)
+		}
+		if !bytes.Equal(payee.Quotients[i], c.Quotients[i]) {
+			t.Fatalf("quotient %d mismatch", i)
+		}
+		if err = payee.VerifyLink(i); err != nil {
+			t.Fatalf("link %d didn't verify: %v", i, err)
+		}
+	}
+	if err = payee.Verify(); err != nil {
+		t.Fatalf("unlocked chain didn't verify: %v", err)
+	}
+	if _, err = payee.Unlock(c.Secrets[0]); err == nil {
+		t.Fatal("unlocked a puzzle beyond the chain")
+	}
+}
+
+// TestPromiseChainFuzz makes sure that chains with corrupted puzzles,
+// quotients or secrets and chains of random values never verify and never
+// panic.
+func TestPromiseChainFuzz(t *testing.T) {
+	priv, err := GeneratePuzzleKey(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk := priv.PublicKey()
+	c := newTestChain(t, priv, 6)
+
+	config := &quick.Config{Rand: rand.New(rand.NewSource(1))}
+
+	corrupt := func(which uint8, index, pos uint16, mask byte) bool {
+		if mask == 0 {
+			mask = 1
+		}
+		values := [][][]byte{c.Puzzles, c.Quotients, c.Secrets}
+		list := values[int(which)%len(values)]
+		i := int(index) % len(list)
+		if len(list[i]) == 0 {
+			return true
+		}
+		v := append([]byte(nil), list[i]...)
+		v[int(pos)%len(v)] ^= mask
+
+		fuzzed := &PromiseChain{
+			Key:       pk,
+			Puzzles:   append([][]byte(nil), c.Puzzles...),
+			Quotients: append([][]byte(nil), c.Quotients...),
+			Secrets:   append([][]byte(nil), c.Secrets...),
+		}
+		[][][]byte{fuzzed.Puzzles, fuzzed.Quotients,
+			fuzzed.Secrets}[int(which)%len(values)][i] = v
+		return fuzzed.Verify() != nil
+	}
+	if err := quick.Check(corrupt, config); err != nil {
+		t.Fatal(err)
+	}
+
+	random := func(puzzles, quotients [][]byte) bool {
+		if len(quotients) > 0 {
+			quotients[0] = []byte{1}
+		}
+		fuzzed := &PromiseChain{Key: pk, Puzzles: puzzles,
+			Quotients: quotients}
+		for i := 0; i < fuzzed.Len(); i++ {
+			fuzzed.VerifyLink(i)
+		}
+		return len(puzzles) < 2 || fuzzed.Verify() != nil
+	}
+	if err := quick.Check(random, config); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package puzzle
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"math/big"
+)
+
+// KeyProvider generates puzzle keys and holds their private parts. Keys
+// are generated in memory unless a provider backed by a hardware security
+// module is configured.
+type KeyProvider interface {
+	// GenerateKey generates a puzzle key with a modulus of the
+	// difficulty in bits.
+	GenerateKey(difficulty int) (*PuzzleKey, error)
+
+	// Close releases the resources of the provider. Keys generated by
+	// the provider must not be used afterwards.
+	Close() error
+}
+
+// PrivateKey is the private part of a puzzle key held outside of the
+// process by a key provider.
+type PrivateKey interface {
+	// Decrypt performs a raw RSA decryption of the ciphertext.
+	Decrypt(c *big.Int) (*big.Int, error)
+
+	// Destroy deletes the private key.
+	Destroy()
+}
+
+// MemoryProvider generates puzzle keys held in memory with
+// GeneratePuzzleKey.
+var MemoryProvider KeyProvider = memoryProvider{}
+
+type memoryProvider struct{}
+
+func (memoryProvider) GenerateKey(difficulty int) (*PuzzleKey, error) {
+	return GeneratePuzzleKey(difficulty)
+}
+
+func (memoryProvider) Close() error {
+	return nil
+}
+
+// NewExternalKey returns a puzzle key with the public key whose private
+// part is held by a key provider. Decryptions are blinded the same way as
+// with keys held in memory.
+func NewExternalKey(pub *rsa.PublicKey, priv PrivateKey) (*PuzzleKey, error) {
+	if pub == nil || pub.N == nil || pub.N.Sign() <= 0 || pub.E < 2 {
+		return nil, errors.New("invalid public key")
+	}
+	pk := &PuzzleKey{
+		rsakey:   &rsa.PrivateKey{PublicKey: *pub},
+		external: priv,
+	}
+	var err error
+	pk.factor, pk.inverse, err = newBlindingFactor(rand.Reader, pub)
+	if err != nil {
+		return nil, err
+	}
+	return pk, nil
+}
+
+// PKCS11Config selects the token of a hardware security module puzzle
+// keys are generated on through its PKCS#11 interface.
+type PKCS11Config struct {
+	// Module is the path of the PKCS#11 library of the module.
+	Module string
+
+	// TokenLabel selects the token with the label, the first token
+	// present is used if it's empty.
+	TokenLabel string
+
+	// PIN authenticates the user of the token.
+	PIN string
+}
@@ -10,9 +10,9 @@ package puzzle
 import (
 	"crypto/rand"
 	"crypto/rsa"
-	"crypto/subtle"
 	"errors"
 	"fmt"
+	"io"
 	"math/big"
 
 	"golang.org/x/crypto/blake2s"
@@ -58,7 +58,7 @@ func ValidatePuzzle(pk *PuzzlePubKey, puzzle, secret []byte) bool {
 		return false
 	}
 	check := createPuzzle(pk, bigSecret)
-	return subtle.ConstantTimeCompare(check, puzzle) == 1
+	return equalValues(pk.N, check, puzzle)
 }
 
 // ValidateBlindedPuzzle makes sure that the encrypted secret is a correct
@@ -70,7 +70,7 @@ func ValidateBlindedPuzzle(pk *PuzzlePubKey, blinding, puzzle []byte, secret []b
 		return false
 	}
 	check := UnblindPuzzle(pk, puzzle, createPuzzle(pk, bigSecret))
-	return subtle.ConstantTimeCompare(check, blinding) == 1
+	return equalValues(pk.N, check, blinding)
 }
 
 func RevealSolution(promise []byte, secret []byte) ([]byte, error) {
@@ -108,11 +108,11 @@ var bigOne = big.NewInt(1)
 
 // newBlindingFactor allocates and returns a non-zero random number less
 // than than modulus and its multiplicative inverse in Z/nZ.
-func newBlindingFactor(priv *rsa.PublicKey) (*big.Int, *big.Int, error) {
+func newBlindingFactor(random io.Reader, priv *rsa.PublicKey) (*big.Int, *big.Int, error) {
 	var r, ir *big.Int
 	var err error
 	for {
-		r, err = rand.Int(rand.Reader, priv.N)
+		r, err = rand.Int(random, priv.N)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -132,7 +132,13 @@ func newBlindingFactor(priv *rsa.PublicKey) (*big.Int, *big.Int, error) {
 // and the factor as well as the multiplicative inverse of the factor suitable
 // for UnblindPuzzle.
 func BlindPuzzle(pk *PuzzlePubKey, p []byte) ([]byte, []byte, []byte, error) {
-	r, ir, err := newBlindingFactor((*rsa.PublicKey)(pk))
+	return BlindPuzzleWithRand(rand.Reader, pk, p)
+}
+
+// BlindPuzzleWithRand is like BlindPuzzle but reads the random factor from
+// the specified source of randomness.
+func BlindPuzzleWithRand(random io.Reader, pk *PuzzlePubKey, p []byte) ([]byte, []byte, []byte, error) {
+	r, ir, err := newBlindingFactor(random, (*rsa.PublicKey)(pk))
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -164,7 +170,7 @@ func SolvePuzzle(pk *PuzzleKey, p []byte) ([]byte, error) {
 	// In order to defend against errors in the CRT computation, m^e is
 	// calculated, which should match the original ciphertext.
 	check := createPuzzle(pk.PublicKey(), m)
-	if subtle.ConstantTimeCompare(check, p) != 1 {
+	if !equalValues(pk.rsakey.N, check, p) {
 		return nil, errors.New("error in the CRT computation")
 	}
 
@@ -172,23 +178,35 @@ func SolvePuzzle(pk *PuzzleKey, p []byte) ([]byte, error) {
 }
 
 // decryptPuzzle performs an RSA decryption, resulting in a plaintext integer.
+// The ciphertext is blinded with a fresh factor for every decryption.
 func decryptPuzzle(pk *PuzzleKey, c *big.Int) (*big.Int, error) {
+	defer timeOperation("decrypt")()
+
 	var m *big.Int
 
 	priv := pk.rsakey
 
+	if pk.external == nil && priv.D.Sign() == 0 {
+		return nil, errors.New("puzzle key has been destroyed")
+	}
 	if c.Cmp(priv.N) > 0 {
 		return nil, errors.New("value too large")
 	}
 
+	r, ir := pk.nextBlinding()
 	bigE := big.NewInt(int64(priv.E))
-	rpowe := new(big.Int).Exp(pk.factor, bigE, priv.N) // N != 0
+	rpowe := new(big.Int).Exp(r, bigE, priv.N) // N != 0
 	cCopy := new(big.Int).Set(c)
 	cCopy.Mul(cCopy, rpowe)
 	cCopy.Mod(cCopy, priv.N)
 	c = cCopy
 
-	if priv.Precomputed.Dp == nil {
+	if pk.external != nil {
+		var err error
+		if m, err = pk.external.Decrypt(c); err != nil {
+			return nil, err
+		}
+	} else if priv.Precomputed.Dp == nil {
 		m = new(big.Int).Exp(c, priv.D, priv.N)
 	} else {
 		// We have the precalculated values needed for the CRT.
@@ -218,7 +236,7 @@ func decryptPuzzle(pk *PuzzleKey, c *big.Int) (*big.Int, error) {
 	}
 
 	// Unblind.
-	m.Mul(m, pk.inverse)
+	m.Mul(m, ir)
 	m.Mod(m, priv.N)
 
 	return m, nil
@@ -227,7 +245,13 @@ func decryptPuzzle(pk *PuzzleKey, c *big.Int) (*big.Int, error) {
 // NewSolutionPromise recovers a solution to the puzzle p and generates a
 // promise that puzzle p opens up to this solution.
 func NewSolutionPromise(pk *PuzzleKey, p []byte) ([]byte, []byte, []byte, error) {
-	secret := make([]byte, ripemd160.Size)
+	return NewSizedSolutionPromise(pk, p, ripemd160.Size)
+}
+
+// NewSizedSolutionPromise is like NewSolutionPromise but the secret
+// unlocking the promise has the specified size.
+func NewSizedSolutionPromise(pk *PuzzleKey, p []byte, secretSize int) ([]byte, []byte, []byte, error) {
+	secret := make([]byte, secretSize)
 	if _, err := rand.Read(secret[:]); err != nil {
 		return nil, nil, nil, err
 	}
@@ -278,7 +302,7 @@ func VerifyQuotientsWithSecrets(pk *PuzzlePubKey, qs [][]byte, secrets [][]byte)
 		q := new(big.Int).SetBytes(qs[i])
 		prod.Mul(prod, q)
 		prod.Mod(prod, pk.N)
-		if subtle.ConstantTimeCompare(secrets[i], prod.Bytes()) != 1 {
+		if !equalValues(pk.N, secrets[i], prod.Bytes()) {
 			return false
 		}
 	}
@@ -290,22 +314,32 @@ func VerifyQuotientsWithSecrets(pk *PuzzlePubKey, qs [][]byte, secrets [][]byte)
 // and i'th quotient raised to the power of e. In other words, each quotient
 // becomes a blinding factor linking puzzles together.
 func VerifyQuotients(pk *PuzzlePubKey, qs [][]byte, puzzles [][]byte) bool {
-	// Verify that i'th puzzle can be recovered as a product:
-	// z_i = z_(i-1) * q_i
-	bigE := big.NewInt(int64(pk.E))
 	for i := 1; i < len(qs); i++ {
-		z := new(big.Int).SetBytes(puzzles[i-1])
-		q := new(big.Int).SetBytes(qs[i])
-		q.Exp(q, bigE, pk.N)
-		z.Mul(z, q)
-		z.Mod(z, pk.N)
-		if subtle.ConstantTimeCompare(puzzles[i], z.Bytes()) != 1 {
+		if !VerifyQuotient(pk, qs, puzzles, i) {
 			return false
 		}
 	}
 	return true
 }
 
+// VerifyQuotient verifies the i'th quotient linking the i'th puzzle to the
+// preceding one. Quotients don't depend on each other, so that they may be
+// verified concurrently.
+func VerifyQuotient(pk *PuzzlePubKey, qs [][]byte, puzzles [][]byte, i int) bool {
+	if i < 1 || i >= len(qs) || i >= len(puzzles) {
+		return false
+	}
+	// Verify that i'th puzzle can be recovered as a product:
+	// z_i = z_(i-1) * q_i
+	bigE := big.NewInt(int64(pk.E))
+	z := new(big.Int).SetBytes(puzzles[i-1])
+	q := new(big.Int).SetBytes(qs[i])
+	q.Exp(q, bigE, pk.N)
+	z.Mul(z, q)
+	z.Mod(z, pk.N)
+	return equalValues(pk.N, puzzles[i], z.Bytes())
+}
+
 // modInverse returns the inverse of a in the multiplicative group of prime
 // order n. It requires that a be a member of the group (i.e. less than n).
 func modInverse(a, n *big.Int) (*big.Int, bool) {
@@ -6,6 +6,10 @@ package puzzle_test
 
 import (
 	"bytes"
+	"context"
+	"crypto/rsa"
+	"errors"
+	"math/big"
 	"math/rand"
 	"testing"
 
@@ -16,6 +20,18 @@ import (
 const PuzzlesAmount = 256
 
 func TestBasicPuzzlePromise(t *testing.T) {
+	for _, name := range puzzle.SchemeNames() {
+		scheme, err := puzzle.LookupScheme(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Run(name, func(t *testing.T) {
+			testBasicPuzzlePromise(t, scheme)
+		})
+	}
+}
+
+func testBasicPuzzlePromise(t *testing.T, scheme puzzle.PuzzleScheme) {
 	var err error
 
 	r := rand.New(rand.NewSource(1))
@@ -41,10 +57,13 @@ func TestBasicPuzzlePromise(t *testing.T) {
 	secrets := make([][]byte, len(txh))
 	for i := range txh {
 		puzzles[i], promises[i], secrets[i], err =
-			puzzle.NewPuzzlePromise(priv, txh[i][:])
+			scheme.NewPuzzlePromise(priv, txh[i][:])
 		if err != nil {
 			t.Fatal(err)
 		}
+		if !scheme.ValidatePuzzle(pk, puzzles[i], secrets[i]) {
+			t.Fatal("puzzle didn't validate")
+		}
 	}
 
 	solutions := make([][]byte, len(puzzles))
@@ -91,6 +110,80 @@ func TestBasicPuzzlePromise(t *testing.T) {
 	}
 }
 
+func TestPuzzleKeyZero(t *testing.T) {
+	priv, err := puzzle.GeneratePuzzleKey(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk := priv.PublicKey()
+
+	puzzles, _, _, err := puzzle.NewPuzzlePromise(priv, []byte("signature"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = puzzle.SolvePuzzle(priv, puzzles); err != nil {
+		t.Fatal(err)
+	}
+
+	priv.Zero()
+	if _, err = puzzle.SolvePuzzle(priv, puzzles); err == nil {
+		t.Fatal("destroyed key solved the puzzle")
+	}
+	if pk.N.Cmp(priv.PublicKey().N) != 0 {
+		t.Fatal("public key was destroyed")
+	}
+}
+
+// memoryKey stands in for a private key held by a key provider.
+type memoryKey struct {
+	priv      *rsa.PrivateKey
+	destroyed bool
+}
+
+func (k *memoryKey) Decrypt(c *big.Int) (*big.Int, error) {
+	if k.destroyed {
+		return nil, errors.New("destroyed")
+	}
+	return new(big.Int).Exp(c, k.priv.D, k.priv.N), nil
+}
+
+func (k *memoryKey) Destroy() {
+	k.destroyed = true
+}
+
+func TestExternalKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.New(rand.NewSource(1)), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := &memoryKey{priv: rsaKey}
+	priv, err := puzzle.NewExternalKey(&rsaKey.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	puzzles, _, secret, err := puzzle.NewPuzzlePromise(priv,
+		[]byte("signature"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	solution, err := puzzle.SolvePuzzle(priv, puzzles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(solution, secret) {
+		t.Fatal("external key solved the puzzle incorrectly")
+	}
+
+	priv.Zero()
+	if !key.destroyed {
+		t.Fatal("external key wasn't destroyed")
+	}
+	if _, err = puzzle.SolvePuzzle(priv, puzzles); err == nil {
+		t.Fatal("destroyed key solved the puzzle")
+	}
+}
+
 func tracePuzzle(t *testing.T, blocks ...[]byte) {
 	var legend = []string{
 		"secret   ",
@@ -166,3 +259,154 @@ func BenchmarkRevealSolution(b *testing.B) {
 		}
 	}
 }
+
+// benchmarkVerifyPromises verifies 42 opened puzzle promises and the
+// quotients of 42 real puzzles as payees do with the specified number of
+// workers.
+func benchmarkVerifyPromises(b *testing.B, workers int) {
+	const count = 42
+	priv, err := puzzle.GeneratePuzzleKey(2048)
+	if err != nil {
+		b.Fatal(err)
+	}
+	pk := priv.PublicKey()
+	puzzles := make([][]byte, count)
+	promises := make([][]byte, count)
+	secrets := make([][]byte, count)
+	for i := 0; i < count; i++ {
+		puzzles[i], promises[i], secrets[i], err =
+			puzzle.NewPuzzlePromise(priv, []byte{byte(i)})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	quotients, err := puzzle.Quotients(pk, secrets)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	ctx := context.Background()
+	b.ResetTimer()
+	for j := 0; j < b.N; j++ {
+		err := puzzle.ForEach(ctx, workers, 2*count-1, func(i int) error {
+			if i >= count {
+				if !puzzle.VerifyQuotient(pk, quotients, puzzles,
+					i-count+1) {
+					return errors.New("quotient didn't verify")
+				}
+				return nil
+			}
+			if !puzzle.ValidatePuzzle(pk, puzzles[i], secrets[i]) {
+				return errors.New("puzzle didn't verify")
+			}
+			_, err := puzzle.RevealSolution(promises[i], secrets[i])
+			return err
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkVerifyPromisesSerial(b *testing.B) {
+	benchmarkVerifyPromises(b, 1)
+}
+
+func BenchmarkVerifyPromisesParallel(b *testing.B) {
+	benchmarkVerifyPromises(b, 0)
+}
+
+func TestBlindPuzzleWithRand(t *testing.T) {
+	priv, err := puzzle.GeneratePuzzleKey(2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk := priv.PublicKey()
+	p := []byte("puzzle")
+
+	b1, r1, _, err := puzzle.BlindPuzzleWithRand(rand.New(rand.NewSource(1)),
+		pk, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b2, r2, _, err := puzzle.BlindPuzzleWithRand(rand.New(rand.NewSource(1)),
+		pk, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b1, b2) || !bytes.Equal(r1, r2) {
+		t.Fatal("blinding isn't reproducible from the same source")
+	}
+
+	b3, _, _, err := puzzle.BlindPuzzleWithRand(rand.New(rand.NewSource(2)),
+		pk, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(b1, b3) {
+		t.Fatal("different sources produced the same blinding")
+	}
+}
+
+func TestDifficultyProfiles(t *testing.T) {
+	for _, bits := range puzzle.ProfileSecurityLevels() {
+		p, err := puzzle.LookupProfile(bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.SecurityBits != bits {
+			t.Errorf("profile of %d bits provides %d bits of security",
+				bits, p.SecurityBits)
+		}
+		if err = puzzle.ValidateDifficulty(p.ModulusBits); err != nil {
+			t.Errorf("profile of %d bits: %v", bits, err)
+		}
+	}
+	if _, err := puzzle.LookupProfile(100); err == nil {
+		t.Error("unknown profile found")
+	}
+
+	if p := puzzle.ProfileForDifficulty(2048); p.SecurityBits != 112 ||
+		p.Primes != 3 {
+		t.Errorf("unexpected profile of 2048 bit keys: %+v", p)
+	}
+	for _, difficulty := range []int{0, 1024, 2049, 16384} {
+		if puzzle.ValidateDifficulty(difficulty) == nil {
+			t.Errorf("difficulty %d accepted", difficulty)
+		}
+	}
+}
+
+func TestWork(t *testing.T) {
+	const difficulty = 12
+
+	puzzles := [][]byte{{1, 2, 3}, {4, 5}}
+	nonce, err := puzzle.SolveWork(7, puzzles, difficulty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stamp, ok := puzzle.CheckWork(7, puzzles, nonce, difficulty)
+	if !ok {
+		t.Fatal("proof of work rejected")
+	}
+
+	// The proof is bound to the epoch and the puzzles.
+	if _, ok = puzzle.CheckWork(8, puzzles, nonce, difficulty); ok {
+		t.Error("proof of work accepted for another epoch")
+	}
+	if _, ok = puzzle.CheckWork(7, puzzles[:1], nonce, difficulty); ok {
+		t.Error("proof of work accepted for other puzzles")
+	}
+	if _, ok = puzzle.CheckWork(7, puzzles, nil, difficulty); ok {
+		t.Error("missing proof of work accepted")
+	}
+
+	// Retried requests attach different proofs.
+	other, err := puzzle.SolveWork(7, puzzles, difficulty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := puzzle.CheckWork(7, puzzles, other, difficulty); s == stamp {
+		t.Error("retried proofs of work share a stamp")
+	}
+}
@@ -10,12 +10,20 @@ import (
 	"crypto/x509"
 	"errors"
 	"math/big"
+	"sync"
 )
 
 type PuzzleKey struct {
-	rsakey  *rsa.PrivateKey
-	factor  *big.Int
-	inverse *big.Int
+	// The private key, of which only the public part is set if the
+	// private key is held by a key provider.
+	rsakey   *rsa.PrivateKey
+	external PrivateKey
+
+	// The blinding factor of the next decryption and its inverse,
+	// rotated after every decryption.
+	blindingMu sync.Mutex
+	factor     *big.Int
+	inverse    *big.Int
 }
 
 type PuzzlePubKey rsa.PublicKey
@@ -24,28 +32,76 @@ func GeneratePuzzleKey(difficulty int) (*PuzzleKey, error) {
 	var err error
 
 	pk := new(PuzzleKey)
-	// We determine the safe number of primes for a specified difficulty
-	// according to the following paper by M. Jason Hinek:
-	// http://www.cacr.math.uwaterloo.ca/techreports/2006/cacr2006-16.pdf
-	nprimes := 2
-	if difficulty >= 8192 {
-		nprimes = 5
-	} else if difficulty >= 4096 {
-		nprimes = 4
-	} else if difficulty >= 1024 {
-		nprimes = 3
-	}
+	nprimes := primeCount(difficulty)
 	pk.rsakey, err = rsa.GenerateMultiPrimeKey(rand.Reader, nprimes, difficulty)
 	if err != nil {
 		return nil, err
 	}
-	pk.factor, pk.inverse, err = newBlindingFactor(&pk.rsakey.PublicKey)
+	pk.factor, pk.inverse, err = newBlindingFactor(rand.Reader,
+		&pk.rsakey.PublicKey)
 	if err != nil {
 		return nil, err
 	}
 	return pk, nil
 }
 
+// Zero clears private key material from memory. The public key remains
+// accessible, however the key must not be used to solve puzzles anymore.
+func (pk *PuzzleKey) Zero() {
+	if pk.external != nil {
+		pk.external.Destroy()
+	}
+	if pk.rsakey != nil {
+		priv := pk.rsakey
+		zeroBigInt(priv.D)
+		for _, p := range priv.Primes {
+			zeroBigInt(p)
+		}
+		zeroBigInt(priv.Precomputed.Dp)
+		zeroBigInt(priv.Precomputed.Dq)
+		zeroBigInt(priv.Precomputed.Qinv)
+		for _, values := range priv.Precomputed.CRTValues {
+			zeroBigInt(values.Exp)
+			zeroBigInt(values.Coeff)
+			zeroBigInt(values.R)
+		}
+	}
+	pk.blindingMu.Lock()
+	zeroBigInt(pk.factor)
+	zeroBigInt(pk.inverse)
+	pk.blindingMu.Unlock()
+}
+
+// nextBlinding returns the blinding factor of the decryption about to be
+// performed along with its inverse and rotates them by squaring, so that
+// no two decryptions are blinded by the same value. Squaring keeps the
+// factor and its inverse paired without computing a new inverse, which
+// isn't constant-time.
+func (pk *PuzzleKey) nextBlinding() (*big.Int, *big.Int) {
+	pk.blindingMu.Lock()
+	defer pk.blindingMu.Unlock()
+	n := pk.rsakey.N
+	r := new(big.Int).Set(pk.factor)
+	ir := new(big.Int).Set(pk.inverse)
+	pk.factor.Mul(pk.factor, pk.factor)
+	pk.factor.Mod(pk.factor, n)
+	pk.inverse.Mul(pk.inverse, pk.inverse)
+	pk.inverse.Mod(pk.inverse, n)
+	return r, ir
+}
+
+// zeroBigInt overwrites the internal representation of x with zeroes.
+func zeroBigInt(x *big.Int) {
+	if x == nil {
+		return
+	}
+	b := x.Bits()
+	for i := range b {
+		b[i] = 0
+	}
+	x.SetInt64(0)
+}
+
 func (pk *PuzzleKey) PublicKey() *PuzzlePubKey {
 	return &PuzzlePubKey{
 		E: pk.rsakey.E,
@@ -53,7 +53,8 @@ func DecodeIndexList(indexList []byte) ([]int, error) {
 }
 
 // HashIndexList produces a salted cryptographic hash value of a binary
-// encoded index list.
+// encoded index list. It's used by the CommitmentLegacy scheme, new
+// commitments should be produced with IndexCommitment.
 func HashIndexList(salt []byte, indexList []int) ([]byte, error) {
 	buf, err := EncodeIndexList(indexList)
 	if err != nil {
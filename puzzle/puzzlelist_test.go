@@ -74,3 +74,37 @@ func TestIndexListDecode(t *testing.T) {
 		t.Fatal("didn't fail on odd number of bytes")
 	}
 }
+
+func TestIndexCommitment(t *testing.T) {
+	salt := bytes.Repeat([]byte{0x5a}, 32)
+	list := []int{3, 1, 4}
+	fakeC := IndexCommitment{Version: CommitmentV1,
+		Phase: PhaseFakeTransactions}
+	realC := IndexCommitment{Version: CommitmentV1,
+		Phase: PhaseRealTransactions}
+
+	c, err := fakeC.Commit(salt, list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = fakeC.Verify(salt, list, c); err != nil {
+		t.Fatalf("commitment didn't verify: %v", err)
+	}
+	if err = realC.Verify(salt, list, c); err != ErrCommitmentMismatch {
+		t.Fatal("commitment verified for another phase")
+	}
+	if err = fakeC.Verify(salt, []int{3, 1}, c); err != ErrCommitmentMismatch {
+		t.Fatal("commitment verified for another list")
+	}
+	legacy, err := HashIndexList(salt, list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(legacy, c) {
+		t.Fatal("commitment matches the legacy hash")
+	}
+	err = IndexCommitment{Version: CommitmentLegacy}.Verify(salt, list, legacy)
+	if err != nil {
+		t.Fatalf("legacy commitment didn't verify: %v", err)
+	}
+}
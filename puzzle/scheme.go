@@ -0,0 +1,142 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package puzzle
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"golang.org/x/crypto/blake2s"
+)
+
+// PuzzleScheme describes how the tumbler constructs puzzles concealing
+// signature promises during the Puzzle-Promise protocol. All schemes
+// operate in the RSA group of the puzzle key, therefore blinding, solving
+// and quotient verification performed by clients don't depend on the
+// scheme used by the tumbler.
+type PuzzleScheme interface {
+	// Name returns a unique name of the scheme advertised to clients.
+	Name() string
+
+	// NewPuzzlePromise creates a puzzle and a promise that encrypts the
+	// signature with the puzzle solution. It returns the puzzle, the
+	// promise and the solution.
+	NewPuzzlePromise(pk *PuzzleKey, sig []byte) ([]byte, []byte, []byte, error)
+
+	// ValidatePuzzle makes sure that the solution opens up the puzzle.
+	ValidatePuzzle(pk *PuzzlePubKey, puzzle, solution []byte) bool
+}
+
+// RSA is the original TumbleBit scheme where the puzzle is a random
+// secret encrypted with the raw RSA public key.
+var RSA PuzzleScheme = rsaScheme{}
+
+// RSAFDH is a Chaum blind signature scheme where the puzzle is a full
+// domain hash of a random message and its solution is the RSA-FDH
+// signature of that message produced by the tumbler.
+var RSAFDH PuzzleScheme = rsaFDHScheme{}
+
+var schemes = map[string]PuzzleScheme{
+	RSA.Name():    RSA,
+	RSAFDH.Name(): RSAFDH,
+}
+
+// LookupScheme returns a puzzle scheme by name.
+func LookupScheme(name string) (PuzzleScheme, error) {
+	scheme, ok := schemes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown puzzle scheme %q", name)
+	}
+	return scheme, nil
+}
+
+// SchemeNames returns a sorted list of names of supported puzzle schemes.
+func SchemeNames() []string {
+	names := make([]string, 0, len(schemes))
+	for name := range schemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type rsaScheme struct{}
+
+func (rsaScheme) Name() string {
+	return "rsa"
+}
+
+func (rsaScheme) NewPuzzlePromise(pk *PuzzleKey, sig []byte) ([]byte, []byte, []byte, error) {
+	return NewPuzzlePromise(pk, sig)
+}
+
+func (rsaScheme) ValidatePuzzle(pk *PuzzlePubKey, puzzle, solution []byte) bool {
+	return ValidatePuzzle(pk, puzzle, solution)
+}
+
+type rsaFDHScheme struct{}
+
+// fdhMessageSize is the size of random messages signed by the tumbler.
+const fdhMessageSize = 32
+
+func (rsaFDHScheme) Name() string {
+	return "rsa-fdh"
+}
+
+func (rsaFDHScheme) NewPuzzlePromise(pk *PuzzleKey, sig []byte) ([]byte, []byte, []byte, error) {
+	msg := make([]byte, fdhMessageSize)
+	if _, err := rand.Read(msg); err != nil {
+		return nil, nil, nil,
+			fmt.Errorf("failed to generate a puzzle message: %v", err)
+	}
+
+	z, err := fullDomainHash(pk.PublicKey(), msg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	puzzle := z.Bytes()
+
+	// The solution is an RSA-FDH signature of the message.
+	solution, err := SolvePuzzle(pk, puzzle)
+	if err != nil {
+		return nil, nil, nil,
+			fmt.Errorf("failed to sign the puzzle message: %v", err)
+	}
+
+	promise, err := createPromise(sig, solution)
+	if err != nil {
+		return nil, nil, nil,
+			fmt.Errorf("failed to create puzzle promise: %v", err)
+	}
+	return puzzle, promise, solution, nil
+}
+
+func (rsaFDHScheme) ValidatePuzzle(pk *PuzzlePubKey, puzzle, solution []byte) bool {
+	return ValidatePuzzle(pk, puzzle, solution)
+}
+
+// fdhDomain separates full domain hashes from other uses of BLAKE2s.
+var fdhDomain = []byte("tumblebit-rsa-fdh")
+
+// fullDomainHash maps the message onto the whole RSA group of the public
+// key. An extra 128 bits of output make the bias of the modular reduction
+// negligible.
+func fullDomainHash(pk *PuzzlePubKey, msg []byte) (*big.Int, error) {
+	size := (pk.N.BitLen()+7)/8 + 16
+	xof, err := blake2s.NewXOF(uint16(size), nil)
+	if err != nil {
+		return nil, err
+	}
+	xof.Write(fdhDomain)
+	xof.Write(msg)
+	digest := make([]byte, size)
+	if _, err = xof.Read(digest); err != nil {
+		return nil, err
+	}
+	z := new(big.Int).SetBytes(digest)
+	return z.Mod(z, pk.N), nil
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build timingleak
+
+package puzzle
+
+import (
+	"sync"
+	"time"
+)
+
+// Timing-leak instrumentation records durations of operations with the
+// private puzzle key, so that tests can check whether they depend on the
+// processed values. It's only compiled with the timingleak build tag:
+//
+//	go test -tags timingleak -run Timing ./puzzle
+var timings struct {
+	sync.Mutex
+	samples map[string][]time.Duration
+}
+
+// timeOperation starts timing the named operation and returns the function
+// recording its duration.
+func timeOperation(name string) func() {
+	start := time.Now()
+	return func() {
+		d := time.Since(start)
+		timings.Lock()
+		if timings.samples == nil {
+			timings.samples = make(map[string][]time.Duration)
+		}
+		timings.samples[name] = append(timings.samples[name], d)
+		timings.Unlock()
+	}
+}
+
+// takeTimings returns and forgets durations recorded for the operation.
+func takeTimings(name string) []time.Duration {
+	timings.Lock()
+	defer timings.Unlock()
+	samples := timings.samples[name]
+	delete(timings.samples, name)
+	return samples
+}
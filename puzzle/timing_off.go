@@ -0,0 +1,15 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build !timingleak
+
+package puzzle
+
+// timeOperation is a no-op unless timing-leak instrumentation is enabled
+// with the timingleak build tag.
+func timeOperation(name string) func() {
+	return noop
+}
+
+func noop() {}
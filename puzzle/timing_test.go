@@ -0,0 +1,92 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build timingleak
+
+package puzzle
+
+import (
+	"math"
+	mrand "math/rand"
+	"testing"
+	"time"
+)
+
+// leakThreshold is the value of Welch's t statistic above which timings of
+// two classes of inputs are considered to differ.
+const leakThreshold = 10
+
+// welch returns Welch's t statistic of two samples.
+func welch(a, b []time.Duration) float64 {
+	stats := func(s []time.Duration) (mean, variance float64) {
+		for _, d := range s {
+			mean += float64(d)
+		}
+		mean /= float64(len(s))
+		for _, d := range s {
+			variance += (float64(d) - mean) * (float64(d) - mean)
+		}
+		return mean, variance / float64(len(s)-1)
+	}
+	ma, va := stats(a)
+	mb, vb := stats(b)
+	return (ma - mb) / math.Sqrt(va/float64(len(a))+vb/float64(len(b)))
+}
+
+// TestDecryptTiming compares durations of decryptions of a fixed puzzle
+// and of random puzzles, which must not be distinguishable.
+func TestDecryptTiming(t *testing.T) {
+	const samples = 2000
+
+	pk, err := GeneratePuzzleKey(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixed, _, _, err := NewPuzzlePromise(pk, []byte{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	random := make([][]byte, samples)
+	for i := range random {
+		random[i], _, _, err = NewPuzzlePromise(pk, []byte{1})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Interleave classes randomly so that drift of the environment
+	// affects both of them.
+	classes := mrand.New(mrand.NewSource(1)).Perm(2 * samples)
+	var fixedTimes, randomTimes []time.Duration
+	takeTimings("decrypt")
+	for _, c := range classes {
+		p := fixed
+		if c < samples {
+			p = random[c]
+		}
+		if _, err := SolvePuzzle(pk, p); err != nil {
+			t.Fatal(err)
+		}
+		d := takeTimings("decrypt")
+		if len(d) != 1 {
+			t.Fatalf("recorded %d timings of a decryption", len(d))
+		}
+		if c < samples {
+			randomTimes = append(randomTimes, d[0])
+		} else {
+			fixedTimes = append(fixedTimes, d[0])
+		}
+	}
+
+	if v := welch(fixedTimes, randomTimes); math.Abs(v) > leakThreshold {
+		t.Errorf("decryption timings depend on the puzzle: t = %.2f", v)
+	}
+
+	// Blinding factors must not repeat between decryptions.
+	r1, _ := pk.nextBlinding()
+	r2, _ := pk.nextBlinding()
+	if r1.Cmp(r2) == 0 {
+		t.Error("blinding factor wasn't rotated")
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package puzzle
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"math/bits"
+
+	"golang.org/x/crypto/blake2s"
+)
+
+// Solving puzzles for the Puzzle-Solver protocol takes a private key
+// operation per puzzle while creating them is cheap. Tumblers may require
+// clients to attach a proof of work to their requests: a nonce such that
+// the hash of the nonce, the epoch and the puzzles has a number of leading
+// zero bits. The proof is bound to the puzzles so that it can't be reused
+// for other requests.
+
+// workTag separates proofs of work from other uses of the hash function.
+const workTag = "tumblebit/solver-work"
+
+const (
+	// MaxWorkBits limits the difficulty of proofs of work.
+	MaxWorkBits = 32
+
+	// workNonceSize is the size of nonces found by SolveWork.
+	workNonceSize = 8
+
+	// maxWorkNonceSize limits the size of nonces accepted by CheckWork.
+	maxWorkNonceSize = 32
+)
+
+// WorkStamp identifies a proof of work, so that it isn't accepted twice.
+type WorkStamp [blake2s.Size]byte
+
+// workPrefix hashes the epoch and the puzzles the proof of work is bound
+// to.
+func workPrefix(epoch int32, puzzles [][]byte) []byte {
+	h, _ := blake2s.New256(nil)
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(len(puzzles)))
+	h.Write(b[:])
+	for _, p := range puzzles {
+		binary.BigEndian.PutUint32(b[:], uint32(len(p)))
+		h.Write(b[:])
+		h.Write(p)
+	}
+
+	prefix := make([]byte, 0, len(workTag)+4+blake2s.Size)
+	prefix = append(prefix, workTag...)
+	binary.BigEndian.PutUint32(b[:], uint32(epoch))
+	prefix = append(prefix, b[:]...)
+	return h.Sum(prefix)
+}
+
+// leadingZeroBits returns the number of leading zero bits of the hash.
+func leadingZeroBits(hash []byte) int {
+	n := 0
+	for _, b := range hash {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
+
+// SolveWork finds a nonce proving work of the difficulty in bits for a
+// request of solution promises for the puzzles of the epoch. The search
+// starts at a random nonce, so that requests retried with the same puzzles
+// attach different proofs.
+func SolveWork(epoch int32, puzzles [][]byte, difficulty int) ([]byte, error) {
+	if difficulty <= 0 {
+		return nil, nil
+	}
+	if difficulty > MaxWorkBits {
+		difficulty = MaxWorkBits
+	}
+	var start [workNonceSize]byte
+	if _, err := rand.Read(start[:]); err != nil {
+		return nil, err
+	}
+
+	prefix := workPrefix(epoch, puzzles)
+	msg := append(prefix, start[:]...)
+	nonce := msg[len(prefix):]
+	for n := binary.BigEndian.Uint64(start[:]); ; n++ {
+		binary.BigEndian.PutUint64(nonce, n)
+		hash := blake2s.Sum256(msg)
+		if leadingZeroBits(hash[:]) >= difficulty {
+			return append([]byte(nil), nonce...), nil
+		}
+	}
+}
+
+// CheckWork returns whether the nonce proves work of the difficulty in
+// bits for a request of solution promises for the puzzles of the epoch,
+// along with the stamp identifying the proof.
+func CheckWork(epoch int32, puzzles [][]byte, nonce []byte, difficulty int) (WorkStamp, bool) {
+	if len(nonce) == 0 || len(nonce) > maxWorkNonceSize {
+		return WorkStamp{}, false
+	}
+	msg := append(workPrefix(epoch, puzzles), nonce...)
+	hash := blake2s.Sum256(msg)
+	return WorkStamp(hash), leadingZeroBits(hash[:]) >= difficulty
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/decred/tumblebit/rpc/rpcserver"
+)
+
+// restWriteTimeout limits the time spent serving a single request of the
+// REST gateway. It exceeds the default timeouts of all RPC methods.
+const restWriteTimeout = 5 * time.Minute
+
+// startRESTGateway serves the gRPC services as a REST/JSON API on the
+// configured address until the context is cancelled. The gateway shares
+// the TLS configuration, including client certificate authentication, and
+// the interceptor of the gRPC listeners.
+func startRESTGateway(ctx context.Context, certs *rpcCerts) error {
+	lis, err := net.Listen("tcp", cfg.RESTListen)
+	if err != nil {
+		return err
+	}
+	if !cfg.DisableServerTLS {
+		tlsConfig, err := serverTLSConfig(certs)
+		if err != nil {
+			lis.Close()
+			return err
+		}
+		lis = tls.NewListener(lis, tlsConfig)
+	}
+
+	server := &http.Server{
+		Handler:      rpcserver.NewGateway(interceptUnary),
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: restWriteTimeout,
+	}
+
+	go func() {
+		log.Infof("REST gateway listening on %s", lis.Addr())
+		err := server.Serve(lis)
+		if err != nil && err != http.ErrServerClosed {
+			log.Errorf("REST gateway failed: %v", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(),
+			metricsShutdownTimeout)
+		defer cancel()
+		server.Shutdown(sctx)
+	}()
+
+	return nil
+}
@@ -0,0 +1,16 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build !windows,!plan9
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+func init() {
+	rotateSignals = []os.Signal{syscall.SIGHUP}
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpcserver
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/decred/tumblebit/rpc/tumblerrpc"
+)
+
+// CertRotator manages the TLS certificates presented by the server.
+type CertRotator interface {
+	// Certs returns the PEM encoded current certificate followed by the
+	// next one along with the time of the next rotation.
+	Certs() ([]byte, time.Time)
+
+	// Rotate replaces the current certificate with the next one and
+	// creates a new next certificate.
+	Rotate() error
+}
+
+// UseCertRotator sets the rotator of the TLS certificates published with
+// GetServerCerts and rotated with the AdminService. It must be called
+// before the services are started.
+func UseCertRotator(r CertRotator) {
+	tumblerService.certs = r
+	adminService.certs = r
+}
+
+func (ts *tumblerServer) GetServerCerts(ctx context.Context, req *pb.GetServerCertsRequest) (*pb.GetServerCertsResponse, error) {
+	if ts.certs == nil {
+		return nil, ErrNoServerCerts
+	}
+	certs, rotationTime := ts.certs.Certs()
+	return &pb.GetServerCertsResponse{
+		Certs:        certs,
+		RotationTime: rotationTime.Unix(),
+	}, nil
+}
+
+func (as *adminServer) RotateTLSCert(ctx context.Context, req *pb.RotateTLSCertRequest) (*pb.RotateTLSCertResponse, error) {
+	if as.certs == nil {
+		return nil, ErrNoServerCerts
+	}
+	if err := as.certs.Rotate(); err != nil {
+		return nil, status.Errorf(codes.Internal,
+			"failed to rotate TLS certificate: %v", err)
+	}
+	certs, rotationTime := as.certs.Certs()
+	return &pb.RotateTLSCertResponse{
+		Certs:        certs,
+		RotationTime: rotationTime.Unix(),
+	}, nil
+}
@@ -0,0 +1,244 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpcserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// gatewayPrefix prefixes paths of the REST gateway.
+const gatewayPrefix = "/v1/"
+
+// maxGatewayRequestSize limits the size of request bodies accepted by the
+// REST gateway. It matches the default limit of gRPC servers.
+const maxGatewayRequestSize = 4 << 20
+
+// gatewayServices maps path segments of the REST gateway to the services
+// they expose. The health service is only served over gRPC.
+var gatewayServices = map[string]string{
+	"version": "tumblerrpc.VersionService",
+	"tumbler": "tumblerrpc.TumblerService",
+	"admin":   AdminServiceName,
+}
+
+var (
+	gatewayMarshaler   = jsonpb.Marshaler{}
+	gatewayUnmarshaler = jsonpb.Unmarshaler{AllowUnknownFields: true}
+)
+
+// gateway serves gRPC services as a REST/JSON API.
+type gateway struct {
+	interceptor grpc.UnaryServerInterceptor
+}
+
+// NewGateway returns a handler serving the services registered by
+// RegisterServices as a REST/JSON API. Every method is invoked with
+// POST /v1/<service>/<method>, e.g. POST /v1/tumbler/SetupEscrow, where
+// the service is one of version, tumbler and admin. Request and response
+// messages are encoded with the JSON mapping of proto3 and errors are
+// reported with the HTTP status corresponding to their gRPC code. The
+// OpenAPI definition of the API is served at /v1/openapi.json.
+//
+// Methods are invoked through the interceptor, which is passed the address
+// and the TLS state of the HTTP client as its peer, so the gateway is
+// subject to the same checks as gRPC clients.
+func NewGateway(interceptor grpc.UnaryServerInterceptor) http.Handler {
+	return &gateway{interceptor: interceptor}
+}
+
+func (g *gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, gatewayPrefix)
+	if path == r.URL.Path {
+		http.NotFound(w, r)
+		return
+	}
+	if path == "openapi.json" {
+		serveOpenAPI(w, r)
+		return
+	}
+
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	service, ok := gatewayServices[parts[0]]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	impl := reflect.ValueOf(serviceMap[service])
+	method := impl.MethodByName(parts[1])
+	if !method.IsValid() || !isUnaryMethod(method.Type()) {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req := reflect.New(method.Type().In(1).Elem()).Interface().(proto.Message)
+	body := http.MaxBytesReader(w, r.Body, maxGatewayRequestSize)
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		writeGatewayError(w, status.Error(codes.InvalidArgument,
+			"failed to read request"))
+		return
+	}
+	if len(bytes.TrimSpace(b)) != 0 {
+		err = gatewayUnmarshaler.Unmarshal(bytes.NewReader(b), req)
+		if err != nil {
+			writeGatewayError(w, status.Errorf(codes.InvalidArgument,
+				"malformed request: %v", err))
+			return
+		}
+	}
+
+	ctx := peer.NewContext(r.Context(), gatewayPeer(r))
+	info := &grpc.UnaryServerInfo{
+		Server:     serviceMap[service],
+		FullMethod: "/" + service + "/" + parts[1],
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		out := method.Call([]reflect.Value{reflect.ValueOf(ctx),
+			reflect.ValueOf(req)})
+		if err, _ := out[1].Interface().(error); err != nil {
+			return nil, err
+		}
+		return out[0].Interface(), nil
+	}
+	var resp interface{}
+	if g.interceptor != nil {
+		resp, err = g.interceptor(ctx, req, info, handler)
+	} else {
+		resp, err = handler(ctx, req)
+	}
+	if err != nil {
+		writeGatewayError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	gatewayMarshaler.Marshal(w, resp.(proto.Message))
+}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	messageType = reflect.TypeOf((*proto.Message)(nil)).Elem()
+)
+
+// isUnaryMethod returns whether the method implements a unary RPC.
+func isUnaryMethod(t reflect.Type) bool {
+	return t.NumIn() == 2 && t.NumOut() == 2 &&
+		t.In(0) == contextType && t.In(1).Implements(messageType) &&
+		t.Out(0).Implements(messageType) && t.Out(1) == errorType
+}
+
+// gatewayPeer describes the HTTP client as a gRPC peer.
+func gatewayPeer(r *http.Request) *peer.Peer {
+	p := &peer.Peer{Addr: gatewayAddr(r.RemoteAddr)}
+	if r.TLS != nil {
+		p.AuthInfo = credentials.TLSInfo{State: *r.TLS}
+	}
+	return p
+}
+
+// gatewayAddr is the address of an HTTP client.
+type gatewayAddr string
+
+func (a gatewayAddr) Network() string { return "tcp" }
+func (a gatewayAddr) String() string  { return string(a) }
+
+var _ net.Addr = gatewayAddr("")
+
+// gatewayError is the JSON encoding of errors reported by the gateway.
+type gatewayError struct {
+	Code    codes.Code        `json:"code"`
+	Message string            `json:"message"`
+	Details []json.RawMessage `json:"details,omitempty"`
+}
+
+// writeGatewayError reports the gRPC error along with its details.
+func writeGatewayError(w http.ResponseWriter, err error) {
+	st, _ := status.FromError(err)
+	e := &gatewayError{Code: st.Code(), Message: st.Message()}
+	for _, d := range st.Details() {
+		m, ok := d.(proto.Message)
+		if !ok {
+			continue
+		}
+		s, err := gatewayMarshaler.MarshalToString(m)
+		if err != nil {
+			continue
+		}
+		e.Details = append(e.Details, json.RawMessage(s))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus(st.Code()))
+	json.NewEncoder(w).Encode(e)
+}
+
+// httpStatus returns the HTTP status corresponding to the gRPC code.
+func httpStatus(c codes.Code) int {
+	switch c {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499 // Client closed request
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// serveOpenAPI serves the OpenAPI definition of the gateway.
+func serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	doc, err := openAPIDefinition()
+	if err != nil {
+		http.Error(w, "failed to generate the API definition",
+			http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(doc)
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpcserver
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// HealthServiceName is the name of the standard gRPC health checking
+// service.
+const HealthServiceName = "grpc.health.v1.Health"
+
+// ReflectionServiceName is the name of the gRPC server reflection service.
+// It's always ready once registered.
+const ReflectionServiceName = "grpc.reflection.v1alpha.ServerReflection"
+
+// healthServer implements the standard gRPC health checking protocol so
+// that load balancers can probe the tumbler without custom clients. The
+// overall health, queried with an empty service name, is the health of
+// the TumblerService.
+type healthServer struct{}
+
+var healthService healthServer
+
+// RegisterHealthService registers the health checking service with the
+// server. It's ready to be used immediately.
+func RegisterHealthService(server *grpc.Server) {
+	healthpb.RegisterHealthServer(server, &healthService)
+}
+
+// RegisterReflectionService registers the server reflection service
+// allowing debugging tools such as grpcurl to discover the API.
+func RegisterReflectionService(server *grpc.Server) {
+	reflection.Register(server)
+}
+
+func (*healthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	var serving bool
+	switch req.Service {
+	case "", "tumblerrpc.TumblerService":
+		serving = tumblerService.checkReady() &&
+			!tumblerService.tumbler.Draining() &&
+			tumblerService.tumbler.WalletAvailable()
+	case "tumblerrpc.VersionService", HealthServiceName:
+		serving = true
+	case AdminServiceName:
+		serving = adminService.checkReady()
+	default:
+		return nil, status.Errorf(codes.NotFound, "unknown service %s",
+			req.Service)
+	}
+
+	resp := &healthpb.HealthCheckResponse{
+		Status: healthpb.HealthCheckResponse_NOT_SERVING,
+	}
+	if serving {
+		resp.Status = healthpb.HealthCheckResponse_SERVING
+	}
+	return resp, nil
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpcserver
+
+import (
+	"context"
+	"sort"
+
+	"github.com/btcsuite/btclog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/decred/tumblebit/rpc/tumblerrpc"
+)
+
+// levelNames maps logging levels to the names they are specified with.
+var levelNames = map[btclog.Level]string{
+	btclog.LevelTrace:    "trace",
+	btclog.LevelDebug:    "debug",
+	btclog.LevelInfo:     "info",
+	btclog.LevelWarn:     "warn",
+	btclog.LevelError:    "error",
+	btclog.LevelCritical: "critical",
+	btclog.LevelOff:      "off",
+}
+
+// UseSubsystemLoggers sets the loggers of subsystems, keyed by their
+// identifiers, whose levels are adjusted with the AdminService. It must be
+// called before the service is started.
+func UseSubsystemLoggers(loggers map[string]btclog.Logger) {
+	adminService.loggers = loggers
+}
+
+// logLevels returns levels of all subsystems sorted by their identifiers.
+func (as *adminServer) logLevels() []*pb.LogLevel {
+	levels := make([]*pb.LogLevel, 0, len(as.loggers))
+	for id, l := range as.loggers {
+		levels = append(levels, &pb.LogLevel{
+			Subsystem: id,
+			Level:     levelNames[l.Level()],
+		})
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		return levels[i].Subsystem < levels[j].Subsystem
+	})
+	return levels
+}
+
+func (as *adminServer) GetLogLevels(ctx context.Context, req *pb.GetLogLevelsRequest) (*pb.GetLogLevelsResponse, error) {
+	return &pb.GetLogLevelsResponse{Levels: as.logLevels()}, nil
+}
+
+func (as *adminServer) SetLogLevel(ctx context.Context, req *pb.SetLogLevelRequest) (*pb.SetLogLevelResponse, error) {
+	level, ok := btclog.LevelFromString(req.Level)
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"invalid log level %q", req.Level)
+	}
+	if req.Subsystem == "" {
+		for _, l := range as.loggers {
+			l.SetLevel(level)
+		}
+	} else {
+		l, ok := as.loggers[req.Subsystem]
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"unknown subsystem %q", req.Subsystem)
+		}
+		l.SetLevel(level)
+	}
+
+	return &pb.SetLogLevelResponse{Levels: as.logLevels()}, nil
+}
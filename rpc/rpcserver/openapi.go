@@ -0,0 +1,254 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpcserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// protoFile is the name the descriptor of the tumblerrpc API is registered
+// under.
+const protoFile = "api.proto"
+
+var (
+	openAPIOnce sync.Once
+	openAPIDoc  []byte
+	openAPIErr  error
+)
+
+// openAPIDefinition returns the OpenAPI 2.0 definition of the gateway
+// generated from the descriptor of the tumblerrpc API. It's generated once
+// and cached.
+func openAPIDefinition() ([]byte, error) {
+	openAPIOnce.Do(func() {
+		openAPIDoc, openAPIErr = generateOpenAPI()
+	})
+	return openAPIDoc, openAPIErr
+}
+
+// fileDescriptor decodes the compressed descriptor of the tumblerrpc API.
+func fileDescriptor() (*descriptor.FileDescriptorProto, error) {
+	gz := proto.FileDescriptor(protoFile)
+	if gz == nil {
+		return nil, fmt.Errorf("descriptor of %s is not registered",
+			protoFile)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var fd descriptor.FileDescriptorProto
+	if err = proto.Unmarshal(b, &fd); err != nil {
+		return nil, err
+	}
+	return &fd, nil
+}
+
+type (
+	openAPISchema struct {
+		Type       string                    `json:"type,omitempty"`
+		Format     string                    `json:"format,omitempty"`
+		Ref        string                    `json:"$ref,omitempty"`
+		Items      *openAPISchema            `json:"items,omitempty"`
+		Enum       []string                  `json:"enum,omitempty"`
+		Properties map[string]*openAPISchema `json:"properties,omitempty"`
+	}
+	openAPIParameter struct {
+		Name     string         `json:"name"`
+		In       string         `json:"in"`
+		Required bool           `json:"required"`
+		Schema   *openAPISchema `json:"schema"`
+	}
+	openAPIResponse struct {
+		Description string         `json:"description"`
+		Schema      *openAPISchema `json:"schema,omitempty"`
+	}
+	openAPIOperation struct {
+		OperationID string                      `json:"operationId"`
+		Tags        []string                    `json:"tags"`
+		Parameters  []openAPIParameter          `json:"parameters"`
+		Responses   map[string]*openAPIResponse `json:"responses"`
+	}
+	openAPIPath struct {
+		Post *openAPIOperation `json:"post"`
+	}
+	openAPIInfo struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	}
+	openAPIDocument struct {
+		Swagger     string                    `json:"swagger"`
+		Info        openAPIInfo               `json:"info"`
+		Schemes     []string                  `json:"schemes"`
+		Consumes    []string                  `json:"consumes"`
+		Produces    []string                  `json:"produces"`
+		Paths       map[string]*openAPIPath   `json:"paths"`
+		Definitions map[string]*openAPISchema `json:"definitions"`
+	}
+)
+
+// errorDefinition names the definition of errors reported by the gateway.
+const errorDefinition = "gatewayError"
+
+func generateOpenAPI() ([]byte, error) {
+	fd, err := fileDescriptor()
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &openAPIDocument{
+		Swagger: "2.0",
+		Info: openAPIInfo{
+			Title:   "TumbleBit REST gateway",
+			Version: semverString,
+		},
+		Schemes:     []string{"https"},
+		Consumes:    []string{"application/json"},
+		Produces:    []string{"application/json"},
+		Paths:       make(map[string]*openAPIPath),
+		Definitions: make(map[string]*openAPISchema),
+	}
+
+	segments := make([]string, 0, len(gatewayServices))
+	for segment := range gatewayServices {
+		segments = append(segments, segment)
+	}
+	sort.Strings(segments)
+	for _, segment := range segments {
+		name := gatewayServices[segment]
+		for _, sd := range fd.Service {
+			if fd.GetPackage()+"."+sd.GetName() != name {
+				continue
+			}
+			for _, md := range sd.Method {
+				// Streaming methods aren't served by the
+				// gateway.
+				if md.GetClientStreaming() || md.GetServerStreaming() {
+					continue
+				}
+				path := gatewayPrefix + segment + "/" + md.GetName()
+				doc.Paths[path] = &openAPIPath{Post: &openAPIOperation{
+					OperationID: sd.GetName() + "_" + md.GetName(),
+					Tags:        []string{sd.GetName()},
+					Parameters: []openAPIParameter{{
+						Name:     "body",
+						In:       "body",
+						Required: true,
+						Schema:   definitionRef(md.GetInputType()),
+					}},
+					Responses: map[string]*openAPIResponse{
+						"200": {
+							Description: "A successful response.",
+							Schema:      definitionRef(md.GetOutputType()),
+						},
+						"default": {
+							Description: "An error response.",
+							Schema: &openAPISchema{
+								Ref: "#/definitions/" + errorDefinition,
+							},
+						},
+					},
+				}}
+			}
+		}
+	}
+
+	for _, md := range fd.MessageType {
+		props := make(map[string]*openAPISchema, len(md.Field))
+		for _, f := range md.Field {
+			schema := fieldSchema(fd, f)
+			if f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+				schema = &openAPISchema{Type: "array", Items: schema}
+			}
+			props[f.GetJsonName()] = schema
+		}
+		doc.Definitions[md.GetName()] = &openAPISchema{
+			Type:       "object",
+			Properties: props,
+		}
+	}
+	doc.Definitions[errorDefinition] = &openAPISchema{
+		Type: "object",
+		Properties: map[string]*openAPISchema{
+			"code":    {Type: "integer", Format: "int32"},
+			"message": {Type: "string"},
+			"details": {Type: "array", Items: &openAPISchema{
+				Type: "object",
+			}},
+		},
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// definitionRef refers to the definition of the fully qualified message.
+func definitionRef(typeName string) *openAPISchema {
+	name := typeName[strings.LastIndex(typeName, ".")+1:]
+	return &openAPISchema{Ref: "#/definitions/" + name}
+}
+
+// fieldSchema describes the value of the field in the JSON mapping of
+// proto3, which encodes 64-bit integers as strings and bytes in base64.
+func fieldSchema(fd *descriptor.FileDescriptorProto, f *descriptor.FieldDescriptorProto) *openAPISchema {
+	switch f.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return &openAPISchema{Type: "boolean"}
+	case descriptor.FieldDescriptorProto_TYPE_INT32,
+		descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return &openAPISchema{Type: "integer", Format: "int32"}
+	case descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return &openAPISchema{Type: "integer", Format: "int64"}
+	case descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return &openAPISchema{Type: "string", Format: "int64"}
+	case descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return &openAPISchema{Type: "string", Format: "uint64"}
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return &openAPISchema{Type: "number", Format: "float"}
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return &openAPISchema{Type: "number", Format: "double"}
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return &openAPISchema{Type: "string", Format: "byte"}
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return enumSchema(fd, f.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		return definitionRef(f.GetTypeName())
+	default:
+		return &openAPISchema{Type: "string"}
+	}
+}
+
+// enumSchema lists names of values of the fully qualified enum.
+func enumSchema(fd *descriptor.FileDescriptorProto, typeName string) *openAPISchema {
+	schema := &openAPISchema{Type: "string"}
+	name := typeName[strings.LastIndex(typeName, ".")+1:]
+	for _, ed := range fd.EnumType {
+		if ed.GetName() != name {
+			continue
+		}
+		for _, v := range ed.Value {
+			schema.Enum = append(schema.Enum, v.GetName())
+		}
+	}
+	return schema
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpcserver
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/decred/tumblebit/rpc/tumblerrpc"
+)
+
+const (
+	// defaultProfileDuration is the duration of CPU profiles captured
+	// when the request doesn't specify one.
+	defaultProfileDuration = 30 * time.Second
+
+	// maxProfileDuration limits the duration of CPU profiles.
+	maxProfileDuration = 5 * time.Minute
+)
+
+func (as *adminServer) CaptureCPUProfile(ctx context.Context, req *pb.CaptureCPUProfileRequest) (*pb.CaptureCPUProfileResponse, error) {
+	d := time.Duration(req.Seconds) * time.Second
+	switch {
+	case d == 0:
+		d = defaultProfileDuration
+	case d < 0 || d > maxProfileDuration:
+		return nil, status.Errorf(codes.InvalidArgument,
+			"profile duration must be between 1s and %v",
+			maxProfileDuration)
+	}
+
+	var buf bytes.Buffer
+	if err := pprof.StartCPUProfile(&buf); err != nil {
+		// Only one CPU profile is captured at a time.
+		return nil, status.Errorf(codes.FailedPrecondition,
+			"failed to start CPU profile: %v", err)
+	}
+	select {
+	case <-time.After(d):
+	case <-ctx.Done():
+	}
+	pprof.StopCPUProfile()
+	if ctx.Err() != nil {
+		return nil, status.Errorf(codes.Canceled,
+			"CPU profile capture interrupted: %v", ctx.Err())
+	}
+
+	return &pb.CaptureCPUProfileResponse{Profile: buf.Bytes()}, nil
+}
@@ -17,33 +17,75 @@
 package rpcserver
 
 import (
+	"bytes"
 	"context"
+	"net"
 	"sync/atomic"
+	"time"
 
+	"github.com/btcsuite/btclog"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 
+	"github.com/decred/tumblebit/contract"
+	"github.com/decred/tumblebit/puzzle"
 	pb "github.com/decred/tumblebit/rpc/tumblerrpc"
 	"github.com/decred/tumblebit/tumbler"
 )
 
 // Public API version constants
 const (
-	semverString = "0.1.0"
+	semverString = "0.2.0"
 	semverMajor  = 0
-	semverMinor  = 1
+	semverMinor  = 2
 	semverPatch  = 0
 )
 
+// minProtocolVersion is the oldest protocol version spoken by clients
+// the server is able to serve. Version 2 has made request sequence
+// numbers mandatory.
+const minProtocolVersion = 2
+
+// commitmentVersion returns the scheme clients speaking the protocol
+// version commit to transaction index lists with. Version 3 has
+// introduced domain separated commitments.
+func commitmentVersion(protocolVersion uint32) uint32 {
+	if protocolVersion >= 3 {
+		return puzzle.CommitmentV1
+	}
+	return puzzle.CommitmentLegacy
+}
+
+// serverFeatures lists features supported by the server.
+var serverFeatures = map[string]struct{}{
+	pb.FeatureCommission:        {},
+	pb.FeaturePuzzleSchemes:     {},
+	pb.FeaturePaymentChannels:   {},
+	pb.FeatureSessionResume:     {},
+	pb.FeatureSHA256HashLock:    {},
+	pb.FeatureStreamingPromises: {},
+}
+
 // versionServer provides RPC clients with the ability to query the RPC server
 // version.
 type versionServer struct{}
 
 // tumblerServer provides tumbler services for RPC clients.
 type tumblerServer struct {
+	ready    uint32 // atomic
+	tumbler  *tumbler.Tumbler
+	timeouts map[string]time.Duration
+	certs    CertRotator
+}
+
+// adminServer provides operators with control over the tumbler.
+type adminServer struct {
 	ready   uint32 // atomic
 	tumbler *tumbler.Tumbler
+	loggers map[string]btclog.Logger
+	certs   CertRotator
 }
 
 // Singleton implementations of each service.  Not all services are immediately
@@ -51,6 +93,7 @@ type tumblerServer struct {
 var (
 	versionService versionServer
 	tumblerService tumblerServer
+	adminService   adminServer
 )
 
 // RegisterServices registers implementations of each gRPC service and registers
@@ -58,11 +101,18 @@ var (
 func RegisterServices(server *grpc.Server) {
 	pb.RegisterVersionServiceServer(server, &versionService)
 	pb.RegisterTumblerServiceServer(server, &tumblerService)
+	pb.RegisterAdminServiceServer(server, &adminService)
 }
 
+// AdminServiceName is the name of the service that must only be available
+// to authorized operators.
+const AdminServiceName = "tumblerrpc.AdminService"
+
 var serviceMap = map[string]interface{}{
 	"tumblerrpc.VersionService": &versionService,
 	"tumblerrpc.TumblerService": &tumblerService,
+	AdminServiceName:            &adminService,
+	HealthServiceName:           &healthService,
 }
 
 // ServiceReady returns nil when the service is ready and a gRPC error when not.
@@ -94,6 +144,47 @@ func (*versionServer) Version(ctx context.Context, req *pb.VersionRequest) (*pb.
 	}, nil
 }
 
+// Handshake negotiates the protocol version and features with the client.
+// Features requested by the client are accepted if the server supports
+// them. An incompatibility error describing the versions supported by the
+// server and the missing features is returned if the client speaks an
+// unsupported protocol version or requires features the server lacks.
+func (*versionServer) Handshake(ctx context.Context, req *pb.HandshakeRequest) (*pb.HandshakeResponse, error) {
+	var accepted, missing []string
+	for _, f := range req.Features {
+		if _, ok := serverFeatures[f]; ok {
+			accepted = append(accepted, f)
+		}
+	}
+	for _, f := range req.RequiredFeatures {
+		if _, ok := serverFeatures[f]; !ok {
+			missing = append(missing, f)
+		}
+	}
+
+	if req.ProtocolVersion < minProtocolVersion ||
+		req.ProtocolVersion > pb.ProtocolVersion || len(missing) != 0 {
+		st, err := status.New(codes.FailedPrecondition,
+			"incompatible protocol").WithDetails(
+			&pb.ErrorDetail{Category: pb.ErrorCategory_INCOMPATIBLE},
+			&pb.IncompatibilityDetail{
+				MinProtocolVersion: minProtocolVersion,
+				MaxProtocolVersion: pb.ProtocolVersion,
+				MissingFeatures:    missing,
+			})
+		if err != nil {
+			return nil, status.Errorf(codes.FailedPrecondition,
+				"incompatible protocol")
+		}
+		return nil, st.Err()
+	}
+
+	return &pb.HandshakeResponse{
+		ProtocolVersion: req.ProtocolVersion,
+		Features:        accepted,
+	}, nil
+}
+
 // StartTumblerService starts the TumblerService.
 func StartTumblerService(server *grpc.Server, tumbler *tumbler.Tumbler) {
 	tumblerService.tumbler = tumbler
@@ -102,31 +193,224 @@ func StartTumblerService(server *grpc.Server, tumbler *tumbler.Tumbler) {
 	}
 }
 
+// StartAdminService starts the AdminService.
+func StartAdminService(server *grpc.Server, tumbler *tumbler.Tumbler) {
+	adminService.tumbler = tumbler
+	if atomic.SwapUint32(&adminService.ready, 1) != 0 {
+		panic("service already started")
+	}
+}
+
 var (
 	// ErrInProgress must be returned when concurrent access is requested.
-	ErrInProgress = status.Errorf(codes.Aborted, "operation in progress")
+	ErrInProgress = newError(codes.Aborted, "operation in progress",
+		pb.ErrorCategory_RETRYABLE, time.Second)
 
 	// ErrBadCookie can be returned to let clients know their session has
-	// already expired.
-	ErrBadCookie = status.Errorf(codes.InvalidArgument, "bad cookie")
+	// already expired or the session token is not authentic.
+	ErrBadCookie = newError(codes.InvalidArgument, "bad cookie",
+		pb.ErrorCategory_SESSION_EXPIRED, 0)
 
 	// ErrTempFailure can be returned to indicate a temporary nature of an
 	// error, prompting the client to try again later.
-	ErrTempFailure = status.Errorf(codes.Internal, "temporary failure")
+	ErrTempFailure = newError(codes.Internal, "temporary failure",
+		pb.ErrorCategory_INTERNAL, tumbler.ConfirmationInterval)
 
 	// ErrBadAddress must be returned to indicate that client has supplied
 	// an invalid address.
-	ErrBadAddress = status.Errorf(codes.InvalidArgument, "bad address")
+	ErrBadAddress = newError(codes.InvalidArgument, "bad address",
+		pb.ErrorCategory_BAD_INPUT, 0)
+
+	// ErrBadAmount must be returned to indicate that client has supplied
+	// a negative amount or one exceeding the coin supply.
+	ErrBadAmount = newError(codes.InvalidArgument, "bad amount",
+		pb.ErrorCategory_BAD_INPUT, 0)
 
 	// ErrEscrowFailed must be returned to indicate that the resource is
 	// unavailable.
-	ErrEscrowFailed = status.Errorf(codes.Unavailable, "escrow failed")
+	ErrEscrowFailed = newError(codes.Unavailable, "escrow failed",
+		pb.ErrorCategory_RETRYABLE, tumbler.ConfirmationInterval)
 
 	// ErrBadRequest is a vague error message that must be returned during
-	// the exchange to obscure which step has actually failed.
-	ErrBadRequest = status.Errorf(codes.FailedPrecondition, "bad request")
+	// the exchange to obscure which step has actually failed. Rejected
+	// transaction disclosures are detailed with a ValidationFailure.
+	ErrBadRequest = newError(codes.FailedPrecondition, "bad request",
+		pb.ErrorCategory_PROTOCOL_VIOLATION, 0)
+
+	// ErrNotConfirmed must be returned when the escrow transaction
+	// provided by the client requires more confirmations. The session
+	// remains active and the request may be retried.
+	ErrNotConfirmed = newError(codes.Unavailable, "escrow not confirmed",
+		pb.ErrorCategory_RETRYABLE, tumbler.ConfirmationInterval)
+
+	// ErrParameterMismatch must be returned when the client runs the
+	// protocol with parameters different from the ones advertised by
+	// the tumbler.
+	ErrParameterMismatch = newError(codes.FailedPrecondition,
+		"protocol parameter mismatch", pb.ErrorCategory_BAD_INPUT, 0)
+
+	// ErrInsufficientFee must be returned when the offered amount doesn't
+	// cover the denomination and the commission advertised by the
+	// tumbler.
+	ErrInsufficientFee = newError(codes.FailedPrecondition,
+		"insufficient fee", pb.ErrorCategory_BAD_INPUT, 0)
+
+	// ErrShuttingDown must be returned when a new exchange is requested
+	// while the tumbler is shutting down.
+	ErrShuttingDown = newError(codes.Unavailable, "shutting down",
+		pb.ErrorCategory_RETRYABLE, tumbler.DrainTimeout)
+
+	// ErrWalletUnavailable must be returned when a new exchange is
+	// requested while the wallet service of the tumbler is unreachable.
+	ErrWalletUnavailable = newError(codes.Unavailable,
+		"wallet is unavailable", pb.ErrorCategory_RETRYABLE,
+		tumbler.ConfirmationInterval)
+
+	// ErrSignaturePending must be returned while the tumbler awaits
+	// signatures from its offline signer. The session remains active and
+	// the request may be retried.
+	ErrSignaturePending = newError(codes.Unavailable,
+		"signature is pending", pb.ErrorCategory_RETRYABLE,
+		tumbler.ConfirmationInterval)
+
+	// ErrTimeout must be returned when the tumbler fails to complete a
+	// step of the exchange in time.
+	ErrTimeout = newError(codes.DeadlineExceeded, "timed out",
+		pb.ErrorCategory_INTERNAL, 0)
+
+	// ErrCapacityExhausted must be returned when the tumbler can't escrow
+	// more funds in the current epoch.
+	ErrCapacityExhausted = newError(codes.ResourceExhausted,
+		"capacity exhausted", pb.ErrorCategory_RETRYABLE,
+		tumbler.ConfirmationInterval)
+
+	// ErrReplay must be returned when a request carries a sequence number
+	// that isn't greater than the one of the last request accepted in the
+	// session. The session remains active.
+	ErrReplay = newError(codes.AlreadyExists, "replayed request",
+		pb.ErrorCategory_REPLAY, 0)
+
+	// ErrEscrowSpent must be returned when the escrow of the payment
+	// offer has been spent before the tumbler could cash it out.
+	ErrEscrowSpent = newError(codes.FailedPrecondition,
+		"offer escrow spent", pb.ErrorCategory_PROTOCOL_VIOLATION, 0)
+
+	// ErrQuotaExceeded must be returned when the client has requested
+	// solution promises for more puzzles than its quota of the epoch
+	// allows. Requests may be retried in the next epoch.
+	ErrQuotaExceeded = newError(codes.ResourceExhausted,
+		"puzzle quota exceeded", pb.ErrorCategory_RETRYABLE,
+		tumbler.EpochRenewal*tumbler.ConfirmationInterval)
+
+	// ErrInsufficientWork must be returned when a request creating a new
+	// session doesn't carry the proof of work advertised by the tumbler.
+	ErrInsufficientWork = newError(codes.FailedPrecondition,
+		"insufficient proof of work", pb.ErrorCategory_BAD_INPUT, 0)
+
+	// ErrUnknownEpoch must be returned when the requested epoch doesn't
+	// exist or has expired.
+	ErrUnknownEpoch = newError(codes.NotFound, "unknown epoch",
+		pb.ErrorCategory_BAD_INPUT, 0)
+
+	// ErrNoManifests must be returned when epoch manifests are requested
+	// from a tumbler without an identity key.
+	ErrNoManifests = newError(codes.Unimplemented,
+		"epoch manifests are not published", pb.ErrorCategory_INCOMPATIBLE, 0)
+
+	// ErrNoServerCerts must be returned when TLS certificates are
+	// requested from a tumbler that doesn't rotate them.
+	ErrNoServerCerts = newError(codes.Unimplemented,
+		"TLS certificates are not rotated", pb.ErrorCategory_INCOMPATIBLE, 0)
 )
 
+// newError creates a gRPC error with an attached ErrorDetail describing
+// the category of the error and when the request may be retried.
+func newError(c codes.Code, msg string, category pb.ErrorCategory, retryAfter time.Duration) error {
+	st, err := status.New(c, msg).WithDetails(&pb.ErrorDetail{
+		Category:   category,
+		RetryAfter: int64(retryAfter / time.Second),
+	})
+	if err != nil {
+		panic(err)
+	}
+	return st.Err()
+}
+
+// sessionError annotates the gRPC error err with the state of the session
+// the error has occurred in.
+func sessionError(err error, s *tumbler.Session) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	detail := &pb.ErrorDetail{State: s.State()}
+	for _, d := range st.Details() {
+		if ed, ok := d.(*pb.ErrorDetail); ok {
+			detail.Category = ed.Category
+			detail.RetryAfter = ed.RetryAfter
+		}
+	}
+	nst, err := status.New(st.Code(), st.Message()).WithDetails(detail)
+	if err != nil {
+		return st.Err()
+	}
+	return nst.Err()
+}
+
+// solverError translates rejections of requests for solution promises
+// protecting the tumbler from denial of service, nil is returned for
+// other errors.
+func solverError(err error) error {
+	switch err {
+	case tumbler.ErrQuotaExceeded:
+		return ErrQuotaExceeded
+	case tumbler.ErrInsufficientWork:
+		return ErrInsufficientWork
+	case tumbler.ErrReplay:
+		return ErrReplay
+	}
+	return nil
+}
+
+// clientHost returns the host of the client the request is served for,
+// which identifies the client for the purpose of puzzle quotas.
+func clientHost(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	addr := p.Addr.String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// validationFailure attaches the transcript of the failed check to the
+// gRPC error err if the transaction disclosure of the client has been
+// rejected with a tumbler.ValidationError. The disclosure belongs to the
+// client, so the transcript reveals nothing the client doesn't know.
+func validationFailure(err error, cause error) error {
+	ve, ok := cause.(*tumbler.ValidationError)
+	if !ok {
+		return err
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	// Checks are numbered alike in both packages.
+	failure := &pb.ValidationFailure{Check: pb.ValidationCheck(ve.Check)}
+	for _, idx := range ve.Indices {
+		failure.Indices = append(failure.Indices, uint32(idx))
+	}
+	nst, e := st.WithDetails(failure)
+	if e != nil {
+		return err
+	}
+	return nst.Err()
+}
+
 func (ts *tumblerServer) checkReady() bool {
 	return atomic.LoadUint32(&ts.ready) != 0
 }
@@ -135,31 +419,124 @@ func (ts *tumblerServer) Ping(ctx context.Context, req *pb.PingRequest) (*pb.Pin
 	return &pb.PingResponse{}, nil
 }
 
+func (ts *tumblerServer) GetTumblerInfo(ctx context.Context, req *pb.GetTumblerInfoRequest) (*pb.GetTumblerInfoResponse, error) {
+	info, err := ts.tumbler.Info()
+	if err != nil {
+		return nil, ErrTempFailure
+	}
+
+	return &pb.GetTumblerInfoResponse{
+		Epoch:                info.Epoch,
+		NextEpoch:            info.NextEpoch,
+		LockTime:             info.LockTime,
+		Denomination:         info.Denomination.Atoms(),
+		PuzzleKeyHash:        info.PuzzleKeyHash,
+		PuzzleDifficulty:     int32(info.PuzzleDifficulty),
+		FeePerKb:             info.FeePerKb,
+		EpochDuration:        info.EpochDuration,
+		EpochRenewal:         info.EpochRenewal,
+		RealTransactionCount: int32(info.RealTransactionCount),
+		FakeTransactionCount: int32(info.FakeTransactionCount),
+		RealPreimageCount:    int32(info.RealPreimageCount),
+		FakePreimageCount:    int32(info.FakePreimageCount),
+		PuzzleScheme:         info.PuzzleScheme,
+		Fee:                  info.Fee.Atoms(),
+		RelativeLockTime:     info.RelativeLockTime,
+		PuzzleSecurity:       int32(info.PuzzleSecurity),
+		PuzzlePrimes:         int32(info.PuzzlePrimes),
+		WorkBits:             int32(info.WorkBits),
+	}, nil
+}
+
+func (ts *tumblerServer) GetEpochManifest(ctx context.Context, req *pb.GetEpochManifestRequest) (*pb.GetEpochManifestResponse, error) {
+	tctx, cancel := ts.withTimeout(ctx, "GetEpochManifest")
+	defer cancel()
+	m, err := ts.tumbler.EpochManifest(tctx, req.Epoch)
+	switch {
+	case err == tumbler.ErrNoIdentityKey:
+		return nil, ErrNoManifests
+	case err == tumbler.ErrEpochNotFound:
+		return nil, ErrUnknownEpoch
+	case timedOut(tctx, err):
+		return nil, ErrTimeout
+	case err != nil:
+		return nil, ErrTempFailure
+	}
+
+	return &pb.GetEpochManifestResponse{
+		Version:       m.Version,
+		Network:       m.Network,
+		Epoch:         m.Epoch,
+		Denomination:  m.Denomination.Atoms(),
+		PuzzleKeyHash: m.PuzzleKeyHash,
+		Address:       m.Address,
+		IdentityKey:   m.IdentityKey,
+		Signature:     m.Signature,
+	}, nil
+}
+
 func (ts *tumblerServer) SetupEscrow(ctx context.Context, req *pb.SetupEscrowRequest) (*pb.SetupEscrowResponse, error) {
 	if len(req.Address) == 0 {
 		return nil, ErrBadAddress
 	}
+	amount, err := contract.AmountFromAtoms(req.Amount)
+	if err != nil {
+		return nil, ErrBadAmount
+	}
+	if ts.tumbler.Draining() {
+		return nil, ErrShuttingDown
+	}
+	if !ts.tumbler.WalletAvailable() {
+		return nil, ErrWalletUnavailable
+	}
 
 	s := tumbler.NewSession(ts.tumbler, req.Address)
 
-	escrow, err := s.SetupEscrow(ctx, &tumbler.EscrowRequest{
-		Address:   req.Address,
-		PublicKey: req.PublicKey,
-		Amount:    req.Amount,
+	tctx, cancel := ts.withTimeout(ctx, "SetupEscrow")
+	defer cancel()
+	escrow, err := s.SetupEscrow(tctx, &tumbler.EscrowRequest{
+		Address:              req.Address,
+		PublicKey:            req.PublicKey,
+		Amount:               amount,
+		RealTransactionCount: int(req.RealTransactionCount),
+		FakeTransactionCount: int(req.FakeTransactionCount),
+		CommitmentVersion:    commitmentVersion(req.ProtocolVersion),
+		AddressCommitment:    req.AddressCommitment,
+		NextEpoch:            req.NextEpoch,
 	})
+	if timedOut(tctx, err) {
+		s.FinalizeExchange(ctx, tumbler.ReasonInternalError, err)
+		return nil, sessionError(ErrTimeout, s)
+	}
+	if err == tumbler.ErrParameterMismatch {
+		s.FinalizeExchange(ctx, tumbler.ReasonFailedExchange, err)
+		return nil, sessionError(ErrParameterMismatch, s)
+	}
+	if err == tumbler.ErrCapacityExhausted {
+		s.FinalizeExchange(ctx, tumbler.ReasonFailedExchange, err)
+		return nil, sessionError(ErrCapacityExhausted, s)
+	}
+	if pe, ok := err.(*tumbler.EpochPendingError); ok {
+		s.FinalizeExchange(ctx, tumbler.ReasonFailedExchange, err)
+		return nil, sessionError(newError(codes.Unavailable,
+			"next epoch is pending", pb.ErrorCategory_RETRYABLE,
+			pe.RetryAfter), s)
+	}
 	if err != nil {
 		s.FinalizeExchange(ctx, tumbler.ReasonFailedExchange, err)
-		return nil, ErrEscrowFailed
+		return nil, sessionError(ErrEscrowFailed, s)
 	}
 
 	return &pb.SetupEscrowResponse{
-		Cookie:            s.Cookie[:],
-		Epoch:             escrow.Epoch,
-		LockTime:          escrow.LockTime,
-		Address:           escrow.Address,
-		PublicKey:         escrow.PublicKey,
-		EscrowScript:      escrow.EscrowScript,
-		EscrowTransaction: escrow.EscrowTx,
+		Cookie:               s.Token,
+		Epoch:                escrow.Epoch,
+		LockTime:             escrow.LockTime,
+		Address:              escrow.Address,
+		PublicKey:            escrow.PublicKey,
+		EscrowScript:         escrow.EscrowScript,
+		EscrowTransaction:    escrow.EscrowTx,
+		RealTransactionCount: int32(escrow.RealTransactionCount),
+		FakeTransactionCount: int32(escrow.FakeTransactionCount),
 	}, nil
 }
 
@@ -168,27 +545,47 @@ func (ts *tumblerServer) GetPuzzlePromises(ctx context.Context, req *pb.GetPuzzl
 	if !ok {
 		return nil, ErrBadCookie
 	}
-	if !s.TryLock() {
+	if !s.TryLockRequest(ctx) {
 		return nil, ErrInProgress
 	}
 	defer s.Unlock()
+	if err := s.CheckSequence(req.Sequence); err != nil {
+		return nil, sessionError(ErrReplay, s)
+	}
 
-	signatures, pubKey, err := s.SignChallengeHashes(ctx, req.TransactionHashes)
+	tctx, cancel := ts.withTimeout(ctx, "GetPuzzlePromises")
+	defer cancel()
+	signatures, pubKey, err := s.SignChallengeHashes(tctx, req.TransactionHashes)
+	if err == tumbler.ErrSignaturePending {
+		return nil, sessionError(ErrSignaturePending, s)
+	}
+	if timedOut(tctx, err) {
+		s.FinalizeExchange(ctx, tumbler.ReasonInternalError, err)
+		return nil, sessionError(ErrTimeout, s)
+	}
+	if err == tumbler.ErrParameterMismatch {
+		s.FinalizeExchange(ctx, tumbler.ReasonFailedExchange, err)
+		return nil, sessionError(ErrParameterMismatch, s)
+	}
 	if err != nil {
 		s.FinalizeExchange(ctx, tumbler.ReasonInternalError, err)
-		return nil, ErrTempFailure
+		return nil, sessionError(ErrTempFailure, s)
 	}
 
-	promise, err := s.GetPuzzlePromises(ctx, &tumbler.SignatureChallenges{
+	promise, err := s.GetPuzzlePromises(tctx, &tumbler.SignatureChallenges{
 		FakeSetHash:       req.FakeSetHash,
 		RealSetHash:       req.RealSetHash,
 		TransactionHashes: req.TransactionHashes,
 		Signatures:        signatures,
 		PublicKey:         pubKey,
 	})
+	if timedOut(tctx, err) {
+		s.FinalizeExchange(ctx, tumbler.ReasonInternalError, err)
+		return nil, sessionError(ErrTimeout, s)
+	}
 	if err != nil {
 		s.FinalizeExchange(ctx, tumbler.ReasonFailedExchange, err)
-		return nil, ErrBadRequest
+		return nil, sessionError(ErrBadRequest, s)
 	}
 
 	return &pb.GetPuzzlePromisesResponse{
@@ -204,26 +601,41 @@ func (ts *tumblerServer) FinalizeEscrow(ctx context.Context, req *pb.FinalizeEsc
 	if !ok {
 		return nil, ErrBadCookie
 	}
-	if !s.TryLock() {
+	if !s.TryLockRequest(ctx) {
 		return nil, ErrInProgress
 	}
 	defer s.Unlock()
+	if err := s.CheckSequence(req.Sequence); err != nil {
+		return nil, sessionError(ErrReplay, s)
+	}
 
-	secrets, err := s.ValidatePuzzles(ctx, &tumbler.TransactionDisclosure{
-		FakeTxList: req.FakeTxList,
-		RealTxList: req.RealTxList,
-		RandomPads: req.RandomPads,
-		Salt:       req.Salt,
+	tctx, cancel := ts.withTimeout(ctx, "FinalizeEscrow")
+	defer cancel()
+	secrets, err := s.ValidatePuzzles(tctx, &tumbler.TransactionDisclosure{
+		FakeTxList:    req.FakeTxList,
+		RealTxList:    req.RealTxList,
+		RandomPads:    req.RandomPads,
+		Salt:          req.Salt,
+		PayoutAddress: req.PayoutAddress,
+		PayoutNonce:   req.PayoutNonce,
 	})
+	if timedOut(tctx, err) {
+		s.FinalizeExchange(ctx, tumbler.ReasonInternalError, err)
+		return nil, sessionError(ErrTimeout, s)
+	}
 	if err != nil {
 		s.FinalizeExchange(ctx, tumbler.ReasonFailedExchange, err)
-		return nil, ErrBadRequest
+		return nil, validationFailure(sessionError(ErrBadRequest, s), err)
 	}
 
-	escrowHash, err := s.FinalizeEscrow(ctx)
+	escrowHash, err := s.FinalizeEscrow(tctx)
+	if timedOut(tctx, err) {
+		s.FinalizeExchange(ctx, tumbler.ReasonInternalError, err)
+		return nil, sessionError(ErrTimeout, s)
+	}
 	if err != nil {
 		s.FinalizeExchange(ctx, tumbler.ReasonFailedExchange, err)
-		return nil, ErrBadRequest
+		return nil, sessionError(ErrBadRequest, s)
 	}
 
 	return &pb.FinalizeEscrowResponse{
@@ -238,21 +650,69 @@ func (ts *tumblerServer) GetSolutionPromises(ctx context.Context, req *pb.GetSol
 		return nil, ErrBadAddress
 	}
 
-	s := tumbler.NewSession(ts.tumbler, req.Address)
+	var s *tumbler.Session
+	if len(req.Cookie) > 0 {
+		// Another round of payments over an existing channel.
+		var ok bool
+		s, ok = ts.tumbler.Lookup(req.Cookie)
+		if !ok || !s.IsChannel() {
+			return nil, ErrBadCookie
+		}
+		if !s.TryLockRequest(ctx) {
+			return nil, ErrInProgress
+		}
+		defer s.Unlock()
+		if err := s.CheckSequence(req.Sequence); err != nil {
+			return nil, sessionError(ErrReplay, s)
+		}
+	} else {
+		if ts.tumbler.Draining() {
+			return nil, ErrShuttingDown
+		}
+		if !ts.tumbler.WalletAvailable() {
+			return nil, ErrWalletUnavailable
+		}
+		s = tumbler.NewSession(ts.tumbler, req.Address)
+	}
 
-	promise, err := s.GetSolutionPromises(ctx, &tumbler.SolutionChallenges{
-		Epoch:   req.Epoch,
-		Puzzles: req.Puzzles,
+	tctx, cancel := ts.withTimeout(ctx, "GetSolutionPromises")
+	defer cancel()
+	promise, err := s.GetSolutionPromises(tctx, &tumbler.SolutionChallenges{
+		Epoch:             req.Epoch,
+		Puzzles:           req.Puzzles,
+		RealPreimageCount: int(req.RealPreimageCount),
+		FakePreimageCount: int(req.FakePreimageCount),
+		HashLock:          contract.HashLock(req.HashLock),
+		Client:            clientHost(ctx),
+		Work:              req.Work,
 	})
+	if timedOut(tctx, err) {
+		s.FinalizeExchange(ctx, tumbler.ReasonInternalError, err)
+		return nil, sessionError(ErrTimeout, s)
+	}
+	if err == tumbler.ErrWalletUnavailable && s.IsChannel() {
+		// The channel remains open for another round.
+		return nil, sessionError(ErrWalletUnavailable, s)
+	}
+	if err == tumbler.ErrParameterMismatch {
+		s.FinalizeExchange(ctx, tumbler.ReasonFailedExchange, err)
+		return nil, sessionError(ErrParameterMismatch, s)
+	}
+	if rerr := solverError(err); rerr != nil {
+		s.FinalizeExchange(ctx, tumbler.ReasonFailedExchange, err)
+		return nil, sessionError(rerr, s)
+	}
 	if err != nil {
 		s.FinalizeExchange(ctx, tumbler.ReasonFailedExchange, err)
-		return nil, ErrBadRequest
+		return nil, sessionError(ErrBadRequest, s)
 	}
 
 	return &pb.GetSolutionPromisesResponse{
-		Cookie:    s.Cookie[:],
-		Promises:  promise.Promises,
-		KeyHashes: promise.KeyHashes,
+		Cookie:            s.Token,
+		Promises:          promise.Promises,
+		KeyHashes:         promise.KeyHashes,
+		RealPreimageCount: int32(promise.RealPreimageCount),
+		FakePreimageCount: int32(promise.FakePreimageCount),
 	}, nil
 }
 
@@ -261,18 +721,27 @@ func (ts *tumblerServer) ValidateSolutions(ctx context.Context, req *pb.Validate
 	if !ok {
 		return nil, ErrBadCookie
 	}
-	if !s.TryLock() {
+	if !s.TryLockRequest(ctx) {
 		return nil, ErrInProgress
 	}
 	defer s.Unlock()
+	if err := s.CheckSequence(req.Sequence); err != nil {
+		return nil, sessionError(ErrReplay, s)
+	}
 
-	secrets, err := s.ValidateSolutions(ctx, &tumbler.PuzzleDisclosure{
+	tctx, cancel := ts.withTimeout(ctx, "ValidateSolutions")
+	defer cancel()
+	secrets, err := s.ValidateSolutions(tctx, &tumbler.PuzzleDisclosure{
 		FakePuzzleList: req.FakePuzzleList,
 		FakeFactors:    req.RandomFactors,
 	})
+	if timedOut(tctx, err) {
+		s.FinalizeExchange(ctx, tumbler.ReasonInternalError, err)
+		return nil, sessionError(ErrTimeout, s)
+	}
 	if err != nil {
 		s.FinalizeExchange(ctx, tumbler.ReasonFailedExchange, err)
-		return nil, ErrBadRequest
+		return nil, sessionError(ErrBadRequest, s)
 	}
 
 	return &pb.ValidateSolutionsResponse{
@@ -285,13 +754,24 @@ func (ts *tumblerServer) PaymentOffer(ctx context.Context, req *pb.PaymentOfferR
 	if !ok {
 		return nil, ErrBadCookie
 	}
-	if !s.TryLock() {
+	if !s.TryLockRequest(ctx) {
 		return nil, ErrInProgress
 	}
 	defer s.Unlock()
+	if err := s.CheckSequence(req.Sequence); err != nil {
+		return nil, sessionError(ErrReplay, s)
+	}
+	amount, err := contract.AmountFromAtoms(req.Amount)
+	if err != nil {
+		return nil, sessionError(ErrBadAmount, s)
+	}
+	capacity, err := contract.AmountFromAtoms(req.Capacity)
+	if err != nil {
+		return nil, sessionError(ErrBadAmount, s)
+	}
 
-	err := s.PaymentOffer(ctx, &tumbler.PaymentOffer{
-		Amount:         req.Amount,
+	offer := &tumbler.PaymentOffer{
+		Amount:         amount,
 		PublicKey:      req.PublicKey,
 		EscrowHash:     req.EscrowHash,
 		EscrowScript:   req.EscrowScript,
@@ -299,11 +779,206 @@ func (ts *tumblerServer) PaymentOffer(ctx context.Context, req *pb.PaymentOfferR
 		Puzzle:         req.Puzzle,
 		RealPuzzleList: req.RealPuzzleList,
 		RealFactors:    req.RandomFactors,
-	})
+		Capacity:       capacity,
+	}
+
+	tctx, cancel := ts.withTimeout(ctx, "PaymentOffer")
+	defer cancel()
+	if req.Capacity > 0 || s.IsChannel() {
+		secrets, err := s.ChannelPayment(tctx, offer)
+		if err == tumbler.ErrNotConfirmed {
+			return nil, sessionError(ErrNotConfirmed, s)
+		}
+		if timedOut(tctx, err) {
+			s.FinalizeExchange(ctx, tumbler.ReasonInternalError, err)
+			return nil, sessionError(ErrTimeout, s)
+		}
+		if err == tumbler.ErrInsufficientFee {
+			s.FinalizeExchange(ctx, tumbler.ReasonFailedExchange, err)
+			return nil, sessionError(ErrInsufficientFee, s)
+		}
+		if err != nil {
+			s.FinalizeExchange(ctx, tumbler.ReasonFailedExchange, err)
+			return nil, sessionError(ErrBadRequest, s)
+		}
+		return &pb.PaymentOfferResponse{
+			Secrets: secrets,
+		}, nil
+	}
+
+	err = s.PaymentOffer(tctx, offer)
+	if timedOut(tctx, err) {
+		s.FinalizeExchange(ctx, tumbler.ReasonInternalError, err)
+		return nil, sessionError(ErrTimeout, s)
+	}
+	if err == tumbler.ErrInsufficientFee {
+		s.FinalizeExchange(ctx, tumbler.ReasonFailedExchange, err)
+		return nil, sessionError(ErrInsufficientFee, s)
+	}
+	if err == tumbler.ErrEscrowSpent {
+		s.FinalizeExchange(ctx, tumbler.ReasonFailedExchange, err)
+		return nil, sessionError(ErrEscrowSpent, s)
+	}
 	if err != nil {
 		s.FinalizeExchange(ctx, tumbler.ReasonFailedExchange, err)
-		return nil, ErrBadRequest
+		return nil, sessionError(ErrBadRequest, s)
 	}
 
 	return &pb.PaymentOfferResponse{}, nil
 }
+
+func (ts *tumblerServer) ResumeSession(ctx context.Context, req *pb.ResumeSessionRequest) (*pb.ResumeSessionResponse, error) {
+	s, ok := ts.tumbler.Lookup(req.Cookie)
+	if !ok {
+		return nil, ErrBadCookie
+	}
+	if !s.TryLockRequest(ctx) {
+		return nil, ErrInProgress
+	}
+	defer s.Unlock()
+
+	st := s.Status()
+	return &pb.ResumeSessionResponse{
+		State:            st.State,
+		Epoch:            st.Epoch,
+		Expires:          st.Expires.Unix(),
+		ChannelRemaining: st.ChannelRemaining.Atoms(),
+		Sequence:         st.Sequence,
+	}, nil
+}
+
+func (as *adminServer) checkReady() bool {
+	return atomic.LoadUint32(&as.ready) != 0
+}
+
+func (as *adminServer) RotateEpoch(ctx context.Context, req *pb.RotateEpochRequest) (*pb.RotateEpochResponse, error) {
+	epoch, err := as.tumbler.RotateEpoch(ctx)
+	switch {
+	case err == tumbler.ErrShuttingDown:
+		return nil, ErrShuttingDown
+	case err != nil:
+		return nil, status.Errorf(codes.Internal,
+			"failed to rotate epoch: %v", err)
+	}
+	keyHash, err := as.tumbler.PuzzleKeyHash(epoch)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal,
+			"failed to obtain puzzle key: %v", err)
+	}
+
+	return &pb.RotateEpochResponse{
+		Epoch:         epoch,
+		PuzzleKeyHash: keyHash,
+	}, nil
+}
+
+func (as *adminServer) ListAlerts(ctx context.Context, req *pb.ListAlertsRequest) (*pb.ListAlertsResponse, error) {
+	alerts := as.tumbler.Alerts()
+	resp := &pb.ListAlertsResponse{
+		Alerts: make([]*pb.Alert, len(alerts)),
+	}
+	for i, a := range alerts {
+		resp.Alerts[i] = &pb.Alert{
+			Time:       a.Time.Unix(),
+			Session:    a.Session,
+			Address:    a.Address,
+			Epoch:      a.Epoch,
+			EscrowHash: a.EscrowHash,
+			Message:    a.Message,
+		}
+	}
+	return resp, nil
+}
+
+func (as *adminServer) GetSessionHistory(ctx context.Context, req *pb.GetSessionHistoryRequest) (*pb.GetSessionHistoryResponse, error) {
+	history, err := as.tumbler.SessionHistory(req.Session)
+	switch {
+	case err == tumbler.ErrUnknownSession:
+		return nil, status.Errorf(codes.NotFound,
+			"session %q not found", req.Session)
+	case err != nil:
+		return nil, status.Errorf(codes.Internal,
+			"failed to retrieve session history: %v", err)
+	}
+	resp := &pb.GetSessionHistoryResponse{
+		Events: make([]*pb.SessionEvent, len(history)),
+	}
+	for i, ev := range history {
+		resp.Events[i] = &pb.SessionEvent{
+			Time:       ev.Time.UnixNano(),
+			Level:      ev.Level,
+			Address:    ev.Address,
+			Epoch:      ev.Epoch,
+			State:      ev.State,
+			EscrowHash: ev.EscrowHash,
+			RedeemHash: ev.RedeemHash,
+			Reason:     ev.Reason,
+			Error:      ev.Error,
+		}
+	}
+	return resp, nil
+}
+
+func (as *adminServer) ListSessions(ctx context.Context, req *pb.ListSessionsRequest) (*pb.ListSessionsResponse, error) {
+	sessions := as.tumbler.ListSessions(&tumbler.SessionFilter{
+		State:   req.State,
+		Epoch:   req.Epoch,
+		Address: req.Address,
+	})
+	resp := &pb.ListSessionsResponse{
+		Sessions: make([]*pb.SessionInfo, len(sessions)),
+	}
+	for i, s := range sessions {
+		resp.Sessions[i] = &pb.SessionInfo{
+			Session:    s.Session,
+			Address:    s.Address,
+			Epoch:      s.Epoch,
+			State:      s.State,
+			Expires:    s.Expires.Unix(),
+			EscrowHash: s.EscrowHash,
+			Amount:     s.Amount.Atoms(),
+		}
+	}
+	return resp, nil
+}
+
+func (as *adminServer) KillSession(ctx context.Context, req *pb.KillSessionRequest) (*pb.KillSessionResponse, error) {
+	err := as.tumbler.KillSession(ctx, req.Session, req.Reason)
+	switch {
+	case err == tumbler.ErrUnknownSession:
+		return nil, status.Errorf(codes.NotFound,
+			"session %q not found", req.Session)
+	case err != nil:
+		return nil, status.Errorf(codes.Internal,
+			"failed to kill session: %v", err)
+	}
+	return &pb.KillSessionResponse{}, nil
+}
+
+func (as *adminServer) GetAccounting(ctx context.Context, req *pb.GetAccountingRequest) (*pb.GetAccountingResponse, error) {
+	accounts := as.tumbler.Accounting()
+	resp := &pb.GetAccountingResponse{
+		Accounts: make([]*pb.EpochAccount, len(accounts)),
+	}
+	for i, a := range accounts {
+		resp.Accounts[i] = &pb.EpochAccount{
+			Epoch:       a.Epoch,
+			Escrows:     int32(a.Escrows),
+			Completed:   int32(a.Completed),
+			Failed:      int32(a.Failed),
+			EscrowedIn:  a.EscrowedIn,
+			EscrowedOut: a.EscrowedOut,
+			FeesEarned:  a.FeesEarned,
+			TxFees:      a.TxFees,
+		}
+	}
+	if req.Csv {
+		var buf bytes.Buffer
+		if err := tumbler.WriteAccountingCSV(&buf, accounts); err != nil {
+			return nil, status.Errorf(codes.Internal,
+				"failed to export accounts: %v", err)
+		}
+		resp.Csv = buf.Bytes()
+	}
+	return resp, nil
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpcserver
+
+import (
+	"io"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/decred/tumblebit/rpc/tumblerrpc"
+)
+
+// streamChunkSize is the number of puzzles or promises sent in a single
+// message of a stream. Messages with RSA-sized values of the largest
+// puzzle keys remain well under the default gRPC message size limit.
+const streamChunkSize = 32
+
+// maxStreamedPuzzles limits the number of puzzles a client may upload in a
+// single stream, so that the server doesn't buffer unbounded amounts of
+// data before the request is validated.
+const maxStreamedPuzzles = 4096
+
+// chunks returns the number of messages the values are streamed in. At
+// least one message is always sent.
+func chunks(n int) int {
+	if n == 0 {
+		return 1
+	}
+	return (n + streamChunkSize - 1) / streamChunkSize
+}
+
+// chunk returns the i'th chunk of the values.
+func chunk(values [][]byte, i int) [][]byte {
+	start := i * streamChunkSize
+	if start >= len(values) {
+		return nil
+	}
+	end := start + streamChunkSize
+	if end > len(values) {
+		end = len(values)
+	}
+	return values[start:end]
+}
+
+// StreamPuzzlePromises serves GetPuzzlePromises and streams the puzzles and
+// promises in chunks. The first message carries the keys.
+func (ts *tumblerServer) StreamPuzzlePromises(req *pb.GetPuzzlePromisesRequest, stream pb.TumblerService_StreamPuzzlePromisesServer) error {
+	resp, err := ts.GetPuzzlePromises(stream.Context(), req)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < chunks(len(resp.Puzzles)); i++ {
+		msg := &pb.GetPuzzlePromisesResponse{
+			Puzzles:  chunk(resp.Puzzles, i),
+			Promises: chunk(resp.Promises, i),
+		}
+		if i == 0 {
+			msg.PublicKey = resp.PublicKey
+			msg.PuzzleKey = resp.PuzzleKey
+		}
+		if err = stream.Send(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StreamSolutionPromises receives the request with the puzzles uploaded in
+// chunks, serves GetSolutionPromises once the client closes its side of the
+// stream and streams the promises and key hashes in chunks. The first
+// message in either direction carries the remaining fields.
+func (ts *tumblerServer) StreamSolutionPromises(stream pb.TumblerService_StreamSolutionPromisesServer) error {
+	var req *pb.GetSolutionPromisesRequest
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if req == nil {
+			req = msg
+			continue
+		}
+		if len(req.Puzzles)+len(msg.Puzzles) > maxStreamedPuzzles {
+			return status.Errorf(codes.ResourceExhausted,
+				"more than %d puzzles streamed", maxStreamedPuzzles)
+		}
+		req.Puzzles = append(req.Puzzles, msg.Puzzles...)
+	}
+	if req == nil {
+		return status.Error(codes.InvalidArgument, "empty request stream")
+	}
+
+	resp, err := ts.GetSolutionPromises(stream.Context(), req)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < chunks(len(resp.Promises)); i++ {
+		msg := &pb.GetSolutionPromisesResponse{
+			Promises:  chunk(resp.Promises, i),
+			KeyHashes: chunk(resp.KeyHashes, i),
+		}
+		if i == 0 {
+			msg.Cookie = resp.Cookie
+			msg.RealPreimageCount = resp.RealPreimageCount
+			msg.FakePreimageCount = resp.FakePreimageCount
+		}
+		if err = stream.Send(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
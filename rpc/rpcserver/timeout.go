@@ -0,0 +1,72 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpcserver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DefaultTimeouts limit the time spent serving a single request of the
+// TumblerService methods that call into the wallet or perform puzzle
+// computations.
+var DefaultTimeouts = map[string]time.Duration{
+	"GetEpochManifest":    30 * time.Second,
+	"SetupEscrow":         30 * time.Second,
+	"GetPuzzlePromises":   120 * time.Second,
+	"FinalizeEscrow":      60 * time.Second,
+	"GetSolutionPromises": 120 * time.Second,
+	"ValidateSolutions":   60 * time.Second,
+	"PaymentOffer":        60 * time.Second,
+}
+
+// TimeoutMethods returns the sorted names of methods with configurable
+// timeouts.
+func TimeoutMethods() []string {
+	methods := make([]string, 0, len(DefaultTimeouts))
+	for m := range DefaultTimeouts {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// SetTimeouts overrides default timeouts of the TumblerService methods. It
+// must be called before the service is started.
+func SetTimeouts(timeouts map[string]time.Duration) error {
+	t := make(map[string]time.Duration, len(DefaultTimeouts))
+	for m, d := range DefaultTimeouts {
+		t[m] = d
+	}
+	for m, d := range timeouts {
+		if _, ok := t[m]; !ok {
+			return fmt.Errorf("unknown method %q, timeouts apply to %v",
+				m, TimeoutMethods())
+		}
+		if d <= 0 {
+			return fmt.Errorf("timeout of %s must be positive", m)
+		}
+		t[m] = d
+	}
+	tumblerService.timeouts = t
+	return nil
+}
+
+// withTimeout derives the context a request of the method is served with.
+func (ts *tumblerServer) withTimeout(ctx context.Context, method string) (context.Context, context.CancelFunc) {
+	d, ok := ts.timeouts[method]
+	if !ok {
+		d = DefaultTimeouts[method]
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// timedOut returns true if the request failed because the time allotted
+// to it has run out.
+func timedOut(ctx context.Context, err error) bool {
+	return err != nil && ctx.Err() == context.DeadlineExceeded
+}
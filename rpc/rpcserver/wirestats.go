@@ -0,0 +1,64 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpcserver
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc/stats"
+
+	"github.com/decred/tumblebit/metrics"
+)
+
+// wireStats counts bytes of messages exchanged with clients before and
+// after compression, so that savings of compression can be quantified per
+// method.
+type wireStats struct {
+	receivedPayload *metrics.CounterVec
+	receivedWire    *metrics.CounterVec
+	sentPayload     *metrics.CounterVec
+	sentWire        *metrics.CounterVec
+}
+
+type methodKey struct{}
+
+// NewWireStats returns a gRPC stats handler registering the metrics of
+// sizes of messages with the registry.
+func NewWireStats(r *metrics.Registry) stats.Handler {
+	return &wireStats{
+		receivedPayload: r.NewCounterVec("tumbler_grpc_received_payload_bytes_total",
+			"Uncompressed size of messages received by the method", "method"),
+		receivedWire: r.NewCounterVec("tumbler_grpc_received_wire_bytes_total",
+			"Size on the wire of messages received by the method", "method"),
+		sentPayload: r.NewCounterVec("tumbler_grpc_sent_payload_bytes_total",
+			"Uncompressed size of messages sent by the method", "method"),
+		sentWire: r.NewCounterVec("tumbler_grpc_sent_wire_bytes_total",
+			"Size on the wire of messages sent by the method", "method"),
+	}
+}
+
+func (ws *wireStats) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	method := info.FullMethodName[strings.LastIndex(info.FullMethodName, "/")+1:]
+	return context.WithValue(ctx, methodKey{}, method)
+}
+
+func (ws *wireStats) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	method, _ := ctx.Value(methodKey{}).(string)
+	switch s := s.(type) {
+	case *stats.InPayload:
+		ws.receivedPayload.With(method).Add(uint64(s.Length))
+		ws.receivedWire.With(method).Add(uint64(s.WireLength))
+	case *stats.OutPayload:
+		ws.sentPayload.With(method).Add(uint64(s.Length))
+		ws.sentWire.With(method).Add(uint64(s.WireLength))
+	}
+}
+
+func (ws *wireStats) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (ws *wireStats) HandleConn(ctx context.Context, s stats.ConnStats) {}
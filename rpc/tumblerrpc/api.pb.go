@@ -10,8 +10,16 @@ It is generated from these files:
 It has these top-level messages:
 	VersionRequest
 	VersionResponse
+	HandshakeRequest
+	HandshakeResponse
 	PingRequest
 	PingResponse
+	GetTumblerInfoRequest
+	GetTumblerInfoResponse
+	GetEpochManifestRequest
+	GetEpochManifestResponse
+	GetServerCertsRequest
+	GetServerCertsResponse
 	SetupEscrowRequest
 	SetupEscrowResponse
 	GetPuzzlePromisesRequest
@@ -24,6 +32,36 @@ It has these top-level messages:
 	ValidateSolutionsResponse
 	PaymentOfferRequest
 	PaymentOfferResponse
+	ResumeSessionRequest
+	ResumeSessionResponse
+	RotateEpochRequest
+	RotateEpochResponse
+	Alert
+	ListAlertsRequest
+	ListAlertsResponse
+	SessionEvent
+	GetSessionHistoryRequest
+	GetSessionHistoryResponse
+	SessionInfo
+	ListSessionsRequest
+	ListSessionsResponse
+	KillSessionRequest
+	KillSessionResponse
+	EpochAccount
+	GetAccountingRequest
+	GetAccountingResponse
+	LogLevel
+	GetLogLevelsRequest
+	GetLogLevelsResponse
+	SetLogLevelRequest
+	SetLogLevelResponse
+	RotateTLSCertRequest
+	RotateTLSCertResponse
+	CaptureCPUProfileRequest
+	CaptureCPUProfileResponse
+	ErrorDetail
+	IncompatibilityDetail
+	ValidationFailure
 */
 package tumblerrpc
 
@@ -47,6 +85,102 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+// ErrorCategory classifies failures reported by the TumblerService.
+type ErrorCategory int32
+
+const (
+	ErrorCategory_UNKNOWN ErrorCategory = 0
+	// The request may be retried later without starting over.
+	ErrorCategory_RETRYABLE ErrorCategory = 1
+	// The request violated the protocol and the session was aborted.
+	ErrorCategory_PROTOCOL_VIOLATION ErrorCategory = 2
+	// The session has expired or doesn't exist.
+	ErrorCategory_SESSION_EXPIRED ErrorCategory = 3
+	// The request contained malformed or invalid input.
+	ErrorCategory_BAD_INPUT ErrorCategory = 4
+	// The tumbler has experienced an internal failure.
+	ErrorCategory_INTERNAL ErrorCategory = 5
+	// The client and the server can't interoperate.
+	ErrorCategory_INCOMPATIBLE ErrorCategory = 6
+	// The request has been replayed or carries a stale sequence number.
+	ErrorCategory_REPLAY ErrorCategory = 7
+)
+
+var ErrorCategory_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "RETRYABLE",
+	2: "PROTOCOL_VIOLATION",
+	3: "SESSION_EXPIRED",
+	4: "BAD_INPUT",
+	5: "INTERNAL",
+	6: "INCOMPATIBLE",
+	7: "REPLAY",
+}
+var ErrorCategory_value = map[string]int32{
+	"UNKNOWN":            0,
+	"RETRYABLE":          1,
+	"PROTOCOL_VIOLATION": 2,
+	"SESSION_EXPIRED":    3,
+	"BAD_INPUT":          4,
+	"INTERNAL":           5,
+	"INCOMPATIBLE":       6,
+	"REPLAY":             7,
+}
+
+func (x ErrorCategory) String() string {
+	return proto.EnumName(ErrorCategory_name, int32(x))
+}
+func (ErrorCategory) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+// ValidationCheck identifies the check of the transaction disclosure that
+// has failed in FinalizeEscrow.
+type ValidationCheck int32
+
+const (
+	ValidationCheck_UNKNOWN_CHECK ValidationCheck = 0
+	// The index lists couldn't be decoded.
+	ValidationCheck_INDEX_LISTS ValidationCheck = 1
+	// Sizes of the index lists, the random pads or the salt don't match
+	// the challenge.
+	ValidationCheck_DISCLOSURE_SIZE ValidationCheck = 2
+	// The pay-out address doesn't open the commitment of the payee.
+	ValidationCheck_PAYOUT_COMMITMENT ValidationCheck = 3
+	// The fake index list doesn't open the fake set hash.
+	ValidationCheck_FAKE_SET_COMMITMENT ValidationCheck = 4
+	// Index lists refer to transaction hashes outside the challenge.
+	ValidationCheck_TX_REFERENCE ValidationCheck = 5
+	// Fake transaction hashes don't match their random pads.
+	ValidationCheck_FAKE_TRANSACTIONS ValidationCheck = 6
+	// The real index list doesn't open the real set hash.
+	ValidationCheck_REAL_SET_COMMITMENT ValidationCheck = 7
+)
+
+var ValidationCheck_name = map[int32]string{
+	0: "UNKNOWN_CHECK",
+	1: "INDEX_LISTS",
+	2: "DISCLOSURE_SIZE",
+	3: "PAYOUT_COMMITMENT",
+	4: "FAKE_SET_COMMITMENT",
+	5: "TX_REFERENCE",
+	6: "FAKE_TRANSACTIONS",
+	7: "REAL_SET_COMMITMENT",
+}
+var ValidationCheck_value = map[string]int32{
+	"UNKNOWN_CHECK":       0,
+	"INDEX_LISTS":         1,
+	"DISCLOSURE_SIZE":     2,
+	"PAYOUT_COMMITMENT":   3,
+	"FAKE_SET_COMMITMENT": 4,
+	"TX_REFERENCE":        5,
+	"FAKE_TRANSACTIONS":   6,
+	"REAL_SET_COMMITMENT": 7,
+}
+
+func (x ValidationCheck) String() string {
+	return proto.EnumName(ValidationCheck_name, int32(x))
+}
+func (ValidationCheck) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
 type VersionRequest struct {
 }
 
@@ -111,13 +245,74 @@ func (m *VersionResponse) GetBuildMetadata() string {
 	return ""
 }
 
+// HandshakeRequest declares the protocol version spoken by the client and
+// the features it makes use of. Features the client can't work without
+// are listed as required.
+type HandshakeRequest struct {
+	ProtocolVersion  uint32   `protobuf:"varint,1,opt,name=protocol_version,json=protocolVersion" json:"protocol_version,omitempty"`
+	Features         []string `protobuf:"bytes,2,rep,name=features" json:"features,omitempty"`
+	RequiredFeatures []string `protobuf:"bytes,3,rep,name=required_features,json=requiredFeatures" json:"required_features,omitempty"`
+}
+
+func (m *HandshakeRequest) Reset()                    { *m = HandshakeRequest{} }
+func (m *HandshakeRequest) String() string            { return proto.CompactTextString(m) }
+func (*HandshakeRequest) ProtoMessage()               {}
+func (*HandshakeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+func (m *HandshakeRequest) GetProtocolVersion() uint32 {
+	if m != nil {
+		return m.ProtocolVersion
+	}
+	return 0
+}
+
+func (m *HandshakeRequest) GetFeatures() []string {
+	if m != nil {
+		return m.Features
+	}
+	return nil
+}
+
+func (m *HandshakeRequest) GetRequiredFeatures() []string {
+	if m != nil {
+		return m.RequiredFeatures
+	}
+	return nil
+}
+
+type HandshakeResponse struct {
+	// Protocol version the server speaks with the client.
+	ProtocolVersion uint32 `protobuf:"varint,1,opt,name=protocol_version,json=protocolVersion" json:"protocol_version,omitempty"`
+	// Features requested by the client that are supported by the server.
+	Features []string `protobuf:"bytes,2,rep,name=features" json:"features,omitempty"`
+}
+
+func (m *HandshakeResponse) Reset()                    { *m = HandshakeResponse{} }
+func (m *HandshakeResponse) String() string            { return proto.CompactTextString(m) }
+func (*HandshakeResponse) ProtoMessage()               {}
+func (*HandshakeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *HandshakeResponse) GetProtocolVersion() uint32 {
+	if m != nil {
+		return m.ProtocolVersion
+	}
+	return 0
+}
+
+func (m *HandshakeResponse) GetFeatures() []string {
+	if m != nil {
+		return m.Features
+	}
+	return nil
+}
+
 type PingRequest struct {
 }
 
 func (m *PingRequest) Reset()                    { *m = PingRequest{} }
 func (m *PingRequest) String() string            { return proto.CompactTextString(m) }
 func (*PingRequest) ProtoMessage()               {}
-func (*PingRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+func (*PingRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
 
 type PingResponse struct {
 }
@@ -125,18 +320,336 @@ type PingResponse struct {
 func (m *PingResponse) Reset()                    { *m = PingResponse{} }
 func (m *PingResponse) String() string            { return proto.CompactTextString(m) }
 func (*PingResponse) ProtoMessage()               {}
-func (*PingResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+func (*PingResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+type GetTumblerInfoRequest struct {
+}
+
+func (m *GetTumblerInfoRequest) Reset()                    { *m = GetTumblerInfoRequest{} }
+func (m *GetTumblerInfoRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetTumblerInfoRequest) ProtoMessage()               {}
+func (*GetTumblerInfoRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+type GetTumblerInfoResponse struct {
+	Epoch                int32  `protobuf:"varint,1,opt,name=epoch" json:"epoch,omitempty"`
+	NextEpoch            int32  `protobuf:"varint,2,opt,name=next_epoch,json=nextEpoch" json:"next_epoch,omitempty"`
+	LockTime             int32  `protobuf:"varint,3,opt,name=lock_time,json=lockTime" json:"lock_time,omitempty"`
+	Denomination         int64  `protobuf:"varint,4,opt,name=denomination" json:"denomination,omitempty"`
+	PuzzleKeyHash        []byte `protobuf:"bytes,5,opt,name=puzzle_key_hash,json=puzzleKeyHash,proto3" json:"puzzle_key_hash,omitempty"`
+	PuzzleDifficulty     int32  `protobuf:"varint,6,opt,name=puzzle_difficulty,json=puzzleDifficulty" json:"puzzle_difficulty,omitempty"`
+	FeePerKb             int64  `protobuf:"varint,7,opt,name=fee_per_kb,json=feePerKb" json:"fee_per_kb,omitempty"`
+	EpochDuration        int32  `protobuf:"varint,8,opt,name=epoch_duration,json=epochDuration" json:"epoch_duration,omitempty"`
+	EpochRenewal         int32  `protobuf:"varint,9,opt,name=epoch_renewal,json=epochRenewal" json:"epoch_renewal,omitempty"`
+	RealTransactionCount int32  `protobuf:"varint,10,opt,name=real_transaction_count,json=realTransactionCount" json:"real_transaction_count,omitempty"`
+	FakeTransactionCount int32  `protobuf:"varint,11,opt,name=fake_transaction_count,json=fakeTransactionCount" json:"fake_transaction_count,omitempty"`
+	RealPreimageCount    int32  `protobuf:"varint,12,opt,name=real_preimage_count,json=realPreimageCount" json:"real_preimage_count,omitempty"`
+	FakePreimageCount    int32  `protobuf:"varint,13,opt,name=fake_preimage_count,json=fakePreimageCount" json:"fake_preimage_count,omitempty"`
+	PuzzleScheme         string `protobuf:"bytes,14,opt,name=puzzle_scheme,json=puzzleScheme" json:"puzzle_scheme,omitempty"`
+	// Commission charged by the tumbler for a payment of one
+	// denomination on top of the network fees.
+	Fee int64 `protobuf:"varint,15,opt,name=fee" json:"fee,omitempty"`
+	// Escrows may be refunded once they have been confirmed for
+	// lock_time blocks rather than at the lock_time block height.
+	RelativeLockTime bool `protobuf:"varint,16,opt,name=relative_lock_time,json=relativeLockTime" json:"relative_lock_time,omitempty"`
+	// Difficulty profile of puzzle keys: the estimated bits of security
+	// and the number of prime factors of the modulus.
+	PuzzleSecurity int32 `protobuf:"varint,17,opt,name=puzzle_security,json=puzzleSecurity" json:"puzzle_security,omitempty"`
+	PuzzlePrimes   int32 `protobuf:"varint,18,opt,name=puzzle_primes,json=puzzlePrimes" json:"puzzle_primes,omitempty"`
+	// Difficulty in bits of the proof of work GetSolutionPromises
+	// requests creating new sessions must carry, zero if none.
+	WorkBits int32 `protobuf:"varint,19,opt,name=work_bits,json=workBits" json:"work_bits,omitempty"`
+}
+
+func (m *GetTumblerInfoResponse) Reset()                    { *m = GetTumblerInfoResponse{} }
+func (m *GetTumblerInfoResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetTumblerInfoResponse) ProtoMessage()               {}
+func (*GetTumblerInfoResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *GetTumblerInfoResponse) GetEpoch() int32 {
+	if m != nil {
+		return m.Epoch
+	}
+	return 0
+}
+
+func (m *GetTumblerInfoResponse) GetNextEpoch() int32 {
+	if m != nil {
+		return m.NextEpoch
+	}
+	return 0
+}
+
+func (m *GetTumblerInfoResponse) GetLockTime() int32 {
+	if m != nil {
+		return m.LockTime
+	}
+	return 0
+}
+
+func (m *GetTumblerInfoResponse) GetDenomination() int64 {
+	if m != nil {
+		return m.Denomination
+	}
+	return 0
+}
+
+func (m *GetTumblerInfoResponse) GetPuzzleKeyHash() []byte {
+	if m != nil {
+		return m.PuzzleKeyHash
+	}
+	return nil
+}
+
+func (m *GetTumblerInfoResponse) GetPuzzleDifficulty() int32 {
+	if m != nil {
+		return m.PuzzleDifficulty
+	}
+	return 0
+}
+
+func (m *GetTumblerInfoResponse) GetFeePerKb() int64 {
+	if m != nil {
+		return m.FeePerKb
+	}
+	return 0
+}
+
+func (m *GetTumblerInfoResponse) GetEpochDuration() int32 {
+	if m != nil {
+		return m.EpochDuration
+	}
+	return 0
+}
+
+func (m *GetTumblerInfoResponse) GetEpochRenewal() int32 {
+	if m != nil {
+		return m.EpochRenewal
+	}
+	return 0
+}
+
+func (m *GetTumblerInfoResponse) GetRealTransactionCount() int32 {
+	if m != nil {
+		return m.RealTransactionCount
+	}
+	return 0
+}
+
+func (m *GetTumblerInfoResponse) GetFakeTransactionCount() int32 {
+	if m != nil {
+		return m.FakeTransactionCount
+	}
+	return 0
+}
+
+func (m *GetTumblerInfoResponse) GetRealPreimageCount() int32 {
+	if m != nil {
+		return m.RealPreimageCount
+	}
+	return 0
+}
+
+func (m *GetTumblerInfoResponse) GetFakePreimageCount() int32 {
+	if m != nil {
+		return m.FakePreimageCount
+	}
+	return 0
+}
+
+func (m *GetTumblerInfoResponse) GetPuzzleScheme() string {
+	if m != nil {
+		return m.PuzzleScheme
+	}
+	return ""
+}
+
+func (m *GetTumblerInfoResponse) GetFee() int64 {
+	if m != nil {
+		return m.Fee
+	}
+	return 0
+}
+
+func (m *GetTumblerInfoResponse) GetRelativeLockTime() bool {
+	if m != nil {
+		return m.RelativeLockTime
+	}
+	return false
+}
+
+func (m *GetTumblerInfoResponse) GetPuzzleSecurity() int32 {
+	if m != nil {
+		return m.PuzzleSecurity
+	}
+	return 0
+}
+
+func (m *GetTumblerInfoResponse) GetPuzzlePrimes() int32 {
+	if m != nil {
+		return m.PuzzlePrimes
+	}
+	return 0
+}
+
+func (m *GetTumblerInfoResponse) GetWorkBits() int32 {
+	if m != nil {
+		return m.WorkBits
+	}
+	return 0
+}
+
+// GetEpochManifestRequest requests the signed manifest of the epoch, or
+// of the current epoch if zero.
+type GetEpochManifestRequest struct {
+	Epoch int32 `protobuf:"varint,1,opt,name=epoch" json:"epoch,omitempty"`
+}
+
+func (m *GetEpochManifestRequest) Reset()                    { *m = GetEpochManifestRequest{} }
+func (m *GetEpochManifestRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetEpochManifestRequest) ProtoMessage()               {}
+func (*GetEpochManifestRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *GetEpochManifestRequest) GetEpoch() int32 {
+	if m != nil {
+		return m.Epoch
+	}
+	return 0
+}
+
+// GetEpochManifestResponse carries epoch parameters signed with the
+// ed25519 identity key of the tumbler operator.
+type GetEpochManifestResponse struct {
+	Version       uint32 `protobuf:"varint,1,opt,name=version" json:"version,omitempty"`
+	Network       string `protobuf:"bytes,2,opt,name=network" json:"network,omitempty"`
+	Epoch         int32  `protobuf:"varint,3,opt,name=epoch" json:"epoch,omitempty"`
+	Denomination  int64  `protobuf:"varint,4,opt,name=denomination" json:"denomination,omitempty"`
+	PuzzleKeyHash []byte `protobuf:"bytes,5,opt,name=puzzle_key_hash,json=puzzleKeyHash,proto3" json:"puzzle_key_hash,omitempty"`
+	Address       string `protobuf:"bytes,6,opt,name=address" json:"address,omitempty"`
+	IdentityKey   []byte `protobuf:"bytes,7,opt,name=identity_key,json=identityKey,proto3" json:"identity_key,omitempty"`
+	Signature     []byte `protobuf:"bytes,8,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *GetEpochManifestResponse) Reset()                    { *m = GetEpochManifestResponse{} }
+func (m *GetEpochManifestResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetEpochManifestResponse) ProtoMessage()               {}
+func (*GetEpochManifestResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *GetEpochManifestResponse) GetVersion() uint32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *GetEpochManifestResponse) GetNetwork() string {
+	if m != nil {
+		return m.Network
+	}
+	return ""
+}
+
+func (m *GetEpochManifestResponse) GetEpoch() int32 {
+	if m != nil {
+		return m.Epoch
+	}
+	return 0
+}
+
+func (m *GetEpochManifestResponse) GetDenomination() int64 {
+	if m != nil {
+		return m.Denomination
+	}
+	return 0
+}
+
+func (m *GetEpochManifestResponse) GetPuzzleKeyHash() []byte {
+	if m != nil {
+		return m.PuzzleKeyHash
+	}
+	return nil
+}
+
+func (m *GetEpochManifestResponse) GetAddress() string {
+	if m != nil {
+		return m.Address
+	}
+	return ""
+}
+
+func (m *GetEpochManifestResponse) GetIdentityKey() []byte {
+	if m != nil {
+		return m.IdentityKey
+	}
+	return nil
+}
+
+func (m *GetEpochManifestResponse) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+type GetServerCertsRequest struct {
+}
+
+func (m *GetServerCertsRequest) Reset()                    { *m = GetServerCertsRequest{} }
+func (m *GetServerCertsRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetServerCertsRequest) ProtoMessage()               {}
+func (*GetServerCertsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+type GetServerCertsResponse struct {
+	// PEM encoded TLS certificate presented by the server followed by
+	// the certificate presented after the next rotation.
+	Certs []byte `protobuf:"bytes,1,opt,name=certs,proto3" json:"certs,omitempty"`
+	// Unix time of the next rotation.
+	RotationTime int64 `protobuf:"varint,2,opt,name=rotation_time,json=rotationTime" json:"rotation_time,omitempty"`
+}
+
+func (m *GetServerCertsResponse) Reset()                    { *m = GetServerCertsResponse{} }
+func (m *GetServerCertsResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetServerCertsResponse) ProtoMessage()               {}
+func (*GetServerCertsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *GetServerCertsResponse) GetCerts() []byte {
+	if m != nil {
+		return m.Certs
+	}
+	return nil
+}
+
+func (m *GetServerCertsResponse) GetRotationTime() int64 {
+	if m != nil {
+		return m.RotationTime
+	}
+	return 0
+}
 
 type SetupEscrowRequest struct {
-	Address   string `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
-	PublicKey string `protobuf:"bytes,2,opt,name=public_key,json=publicKey" json:"public_key,omitempty"`
-	Amount    int64  `protobuf:"varint,3,opt,name=amount" json:"amount,omitempty"`
+	Address              string `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
+	PublicKey            string `protobuf:"bytes,2,opt,name=public_key,json=publicKey" json:"public_key,omitempty"`
+	Amount               int64  `protobuf:"varint,3,opt,name=amount" json:"amount,omitempty"`
+	RealTransactionCount int32  `protobuf:"varint,4,opt,name=real_transaction_count,json=realTransactionCount" json:"real_transaction_count,omitempty"`
+	FakeTransactionCount int32  `protobuf:"varint,5,opt,name=fake_transaction_count,json=fakeTransactionCount" json:"fake_transaction_count,omitempty"`
+	// Protocol version negotiated during the handshake, versions before
+	// 3 don't send it.
+	ProtocolVersion uint32 `protobuf:"varint,6,opt,name=protocol_version,json=protocolVersion" json:"protocol_version,omitempty"`
+	// Blinded hash commitment to the pay-out address, SHA-256 of a
+	// random nonce followed by the address. When set, address and
+	// public_key belong to a fresh key of the session rather than to
+	// the wallet and the pay-out address is only revealed when the
+	// escrow is finalized.
+	AddressCommitment []byte `protobuf:"bytes,7,opt,name=address_commitment,json=addressCommitment,proto3" json:"address_commitment,omitempty"`
+	// Bind the escrow to the next epoch if it's about to be created.
+	// The request then fails as retryable until the epoch exists, so
+	// that the escrow isn't bound to an epoch about to be superseded.
+	NextEpoch bool `protobuf:"varint,8,opt,name=next_epoch,json=nextEpoch" json:"next_epoch,omitempty"`
 }
 
 func (m *SetupEscrowRequest) Reset()                    { *m = SetupEscrowRequest{} }
 func (m *SetupEscrowRequest) String() string            { return proto.CompactTextString(m) }
 func (*SetupEscrowRequest) ProtoMessage()               {}
-func (*SetupEscrowRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+func (*SetupEscrowRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
 
 func (m *SetupEscrowRequest) GetAddress() string {
 	if m != nil {
@@ -159,20 +672,57 @@ func (m *SetupEscrowRequest) GetAmount() int64 {
 	return 0
 }
 
+func (m *SetupEscrowRequest) GetRealTransactionCount() int32 {
+	if m != nil {
+		return m.RealTransactionCount
+	}
+	return 0
+}
+
+func (m *SetupEscrowRequest) GetFakeTransactionCount() int32 {
+	if m != nil {
+		return m.FakeTransactionCount
+	}
+	return 0
+}
+
+func (m *SetupEscrowRequest) GetProtocolVersion() uint32 {
+	if m != nil {
+		return m.ProtocolVersion
+	}
+	return 0
+}
+
+func (m *SetupEscrowRequest) GetAddressCommitment() []byte {
+	if m != nil {
+		return m.AddressCommitment
+	}
+	return nil
+}
+
+func (m *SetupEscrowRequest) GetNextEpoch() bool {
+	if m != nil {
+		return m.NextEpoch
+	}
+	return false
+}
+
 type SetupEscrowResponse struct {
-	Cookie            []byte `protobuf:"bytes,1,opt,name=cookie,proto3" json:"cookie,omitempty"`
-	Epoch             int32  `protobuf:"varint,2,opt,name=epoch" json:"epoch,omitempty"`
-	LockTime          int32  `protobuf:"varint,3,opt,name=lock_time,json=lockTime" json:"lock_time,omitempty"`
-	Address           string `protobuf:"bytes,4,opt,name=address" json:"address,omitempty"`
-	PublicKey         string `protobuf:"bytes,5,opt,name=public_key,json=publicKey" json:"public_key,omitempty"`
-	EscrowScript      []byte `protobuf:"bytes,6,opt,name=escrow_script,json=escrowScript,proto3" json:"escrow_script,omitempty"`
-	EscrowTransaction []byte `protobuf:"bytes,7,opt,name=escrow_transaction,json=escrowTransaction,proto3" json:"escrow_transaction,omitempty"`
+	Cookie               []byte `protobuf:"bytes,1,opt,name=cookie,proto3" json:"cookie,omitempty"`
+	Epoch                int32  `protobuf:"varint,2,opt,name=epoch" json:"epoch,omitempty"`
+	LockTime             int32  `protobuf:"varint,3,opt,name=lock_time,json=lockTime" json:"lock_time,omitempty"`
+	Address              string `protobuf:"bytes,4,opt,name=address" json:"address,omitempty"`
+	PublicKey            string `protobuf:"bytes,5,opt,name=public_key,json=publicKey" json:"public_key,omitempty"`
+	EscrowScript         []byte `protobuf:"bytes,6,opt,name=escrow_script,json=escrowScript,proto3" json:"escrow_script,omitempty"`
+	EscrowTransaction    []byte `protobuf:"bytes,7,opt,name=escrow_transaction,json=escrowTransaction,proto3" json:"escrow_transaction,omitempty"`
+	RealTransactionCount int32  `protobuf:"varint,8,opt,name=real_transaction_count,json=realTransactionCount" json:"real_transaction_count,omitempty"`
+	FakeTransactionCount int32  `protobuf:"varint,9,opt,name=fake_transaction_count,json=fakeTransactionCount" json:"fake_transaction_count,omitempty"`
 }
 
 func (m *SetupEscrowResponse) Reset()                    { *m = SetupEscrowResponse{} }
 func (m *SetupEscrowResponse) String() string            { return proto.CompactTextString(m) }
 func (*SetupEscrowResponse) ProtoMessage()               {}
-func (*SetupEscrowResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+func (*SetupEscrowResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
 
 func (m *SetupEscrowResponse) GetCookie() []byte {
 	if m != nil {
@@ -223,17 +773,34 @@ func (m *SetupEscrowResponse) GetEscrowTransaction() []byte {
 	return nil
 }
 
+func (m *SetupEscrowResponse) GetRealTransactionCount() int32 {
+	if m != nil {
+		return m.RealTransactionCount
+	}
+	return 0
+}
+
+func (m *SetupEscrowResponse) GetFakeTransactionCount() int32 {
+	if m != nil {
+		return m.FakeTransactionCount
+	}
+	return 0
+}
+
 type GetPuzzlePromisesRequest struct {
 	Cookie            []byte   `protobuf:"bytes,1,opt,name=cookie,proto3" json:"cookie,omitempty"`
 	FakeSetHash       []byte   `protobuf:"bytes,2,opt,name=fake_set_hash,json=fakeSetHash,proto3" json:"fake_set_hash,omitempty"`
 	RealSetHash       []byte   `protobuf:"bytes,3,opt,name=real_set_hash,json=realSetHash,proto3" json:"real_set_hash,omitempty"`
 	TransactionHashes [][]byte `protobuf:"bytes,4,rep,name=transaction_hashes,json=transactionHashes,proto3" json:"transaction_hashes,omitempty"`
+	// Sequence number of the request within the session, must be greater
+	// than the one of any request previously made in the session.
+	Sequence uint64 `protobuf:"varint,5,opt,name=sequence" json:"sequence,omitempty"`
 }
 
 func (m *GetPuzzlePromisesRequest) Reset()                    { *m = GetPuzzlePromisesRequest{} }
 func (m *GetPuzzlePromisesRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetPuzzlePromisesRequest) ProtoMessage()               {}
-func (*GetPuzzlePromisesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+func (*GetPuzzlePromisesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
 
 func (m *GetPuzzlePromisesRequest) GetCookie() []byte {
 	if m != nil {
@@ -263,6 +830,13 @@ func (m *GetPuzzlePromisesRequest) GetTransactionHashes() [][]byte {
 	return nil
 }
 
+func (m *GetPuzzlePromisesRequest) GetSequence() uint64 {
+	if m != nil {
+		return m.Sequence
+	}
+	return 0
+}
+
 type GetPuzzlePromisesResponse struct {
 	PublicKey []byte   `protobuf:"bytes,1,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
 	PuzzleKey []byte   `protobuf:"bytes,2,opt,name=puzzle_key,json=puzzleKey,proto3" json:"puzzle_key,omitempty"`
@@ -273,7 +847,7 @@ type GetPuzzlePromisesResponse struct {
 func (m *GetPuzzlePromisesResponse) Reset()                    { *m = GetPuzzlePromisesResponse{} }
 func (m *GetPuzzlePromisesResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetPuzzlePromisesResponse) ProtoMessage()               {}
-func (*GetPuzzlePromisesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+func (*GetPuzzlePromisesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
 
 func (m *GetPuzzlePromisesResponse) GetPublicKey() []byte {
 	if m != nil {
@@ -309,12 +883,19 @@ type FinalizeEscrowRequest struct {
 	FakeTxList []byte   `protobuf:"bytes,3,opt,name=fake_tx_list,json=fakeTxList,proto3" json:"fake_tx_list,omitempty"`
 	RealTxList []byte   `protobuf:"bytes,4,opt,name=real_tx_list,json=realTxList,proto3" json:"real_tx_list,omitempty"`
 	RandomPads [][]byte `protobuf:"bytes,5,rep,name=random_pads,json=randomPads,proto3" json:"random_pads,omitempty"`
+	// Sequence number of the request within the session, must be greater
+	// than the one of any request previously made in the session.
+	Sequence uint64 `protobuf:"varint,6,opt,name=sequence" json:"sequence,omitempty"`
+	// Pay-out address and nonce opening the address commitment made in
+	// SetupEscrow, if any.
+	PayoutAddress string `protobuf:"bytes,7,opt,name=payout_address,json=payoutAddress" json:"payout_address,omitempty"`
+	PayoutNonce   []byte `protobuf:"bytes,8,opt,name=payout_nonce,json=payoutNonce,proto3" json:"payout_nonce,omitempty"`
 }
 
 func (m *FinalizeEscrowRequest) Reset()                    { *m = FinalizeEscrowRequest{} }
 func (m *FinalizeEscrowRequest) String() string            { return proto.CompactTextString(m) }
 func (*FinalizeEscrowRequest) ProtoMessage()               {}
-func (*FinalizeEscrowRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+func (*FinalizeEscrowRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
 
 func (m *FinalizeEscrowRequest) GetCookie() []byte {
 	if m != nil {
@@ -351,18 +932,39 @@ func (m *FinalizeEscrowRequest) GetRandomPads() [][]byte {
 	return nil
 }
 
-type FinalizeEscrowResponse struct {
-	EscrowHash []byte   `protobuf:"bytes,1,opt,name=escrow_hash,json=escrowHash,proto3" json:"escrow_hash,omitempty"`
-	Secrets    [][]byte `protobuf:"bytes,2,rep,name=secrets,proto3" json:"secrets,omitempty"`
-	Quotients  [][]byte `protobuf:"bytes,3,rep,name=quotients,proto3" json:"quotients,omitempty"`
+func (m *FinalizeEscrowRequest) GetSequence() uint64 {
+	if m != nil {
+		return m.Sequence
+	}
+	return 0
 }
 
-func (m *FinalizeEscrowResponse) Reset()                    { *m = FinalizeEscrowResponse{} }
-func (m *FinalizeEscrowResponse) String() string            { return proto.CompactTextString(m) }
-func (*FinalizeEscrowResponse) ProtoMessage()               {}
-func (*FinalizeEscrowResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
-
-func (m *FinalizeEscrowResponse) GetEscrowHash() []byte {
+func (m *FinalizeEscrowRequest) GetPayoutAddress() string {
+	if m != nil {
+		return m.PayoutAddress
+	}
+	return ""
+}
+
+func (m *FinalizeEscrowRequest) GetPayoutNonce() []byte {
+	if m != nil {
+		return m.PayoutNonce
+	}
+	return nil
+}
+
+type FinalizeEscrowResponse struct {
+	EscrowHash []byte   `protobuf:"bytes,1,opt,name=escrow_hash,json=escrowHash,proto3" json:"escrow_hash,omitempty"`
+	Secrets    [][]byte `protobuf:"bytes,2,rep,name=secrets,proto3" json:"secrets,omitempty"`
+	Quotients  [][]byte `protobuf:"bytes,3,rep,name=quotients,proto3" json:"quotients,omitempty"`
+}
+
+func (m *FinalizeEscrowResponse) Reset()                    { *m = FinalizeEscrowResponse{} }
+func (m *FinalizeEscrowResponse) String() string            { return proto.CompactTextString(m) }
+func (*FinalizeEscrowResponse) ProtoMessage()               {}
+func (*FinalizeEscrowResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+
+func (m *FinalizeEscrowResponse) GetEscrowHash() []byte {
 	if m != nil {
 		return m.EscrowHash
 	}
@@ -384,15 +986,29 @@ func (m *FinalizeEscrowResponse) GetQuotients() [][]byte {
 }
 
 type GetSolutionPromisesRequest struct {
-	Address string   `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
-	Epoch   int32    `protobuf:"varint,2,opt,name=epoch" json:"epoch,omitempty"`
-	Puzzles [][]byte `protobuf:"bytes,3,rep,name=puzzles,proto3" json:"puzzles,omitempty"`
+	Address           string   `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
+	Epoch             int32    `protobuf:"varint,2,opt,name=epoch" json:"epoch,omitempty"`
+	Puzzles           [][]byte `protobuf:"bytes,3,rep,name=puzzles,proto3" json:"puzzles,omitempty"`
+	Cookie            []byte   `protobuf:"bytes,4,opt,name=cookie,proto3" json:"cookie,omitempty"`
+	RealPreimageCount int32    `protobuf:"varint,5,opt,name=real_preimage_count,json=realPreimageCount" json:"real_preimage_count,omitempty"`
+	FakePreimageCount int32    `protobuf:"varint,6,opt,name=fake_preimage_count,json=fakePreimageCount" json:"fake_preimage_count,omitempty"`
+	// Sequence number of the request within the payment channel session,
+	// must be greater than the one of any request previously made in the
+	// session. Ignored when a new session is created.
+	Sequence uint64 `protobuf:"varint,7,opt,name=sequence" json:"sequence,omitempty"`
+	// Hash function the payment offer locks funds with: 0 for RIPEMD-160,
+	// 1 for SHA-256. SHA-256 requires the sha256-hash-lock feature.
+	HashLock uint32 `protobuf:"varint,8,opt,name=hash_lock,json=hashLock" json:"hash_lock,omitempty"`
+	// Nonce proving work of the difficulty advertised as work_bits by
+	// GetTumblerInfo for the epoch and the puzzles. Required when a new
+	// session is created unless work_bits is zero.
+	Work []byte `protobuf:"bytes,9,opt,name=work,proto3" json:"work,omitempty"`
 }
 
 func (m *GetSolutionPromisesRequest) Reset()                    { *m = GetSolutionPromisesRequest{} }
 func (m *GetSolutionPromisesRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetSolutionPromisesRequest) ProtoMessage()               {}
-func (*GetSolutionPromisesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+func (*GetSolutionPromisesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
 
 func (m *GetSolutionPromisesRequest) GetAddress() string {
 	if m != nil {
@@ -415,16 +1031,60 @@ func (m *GetSolutionPromisesRequest) GetPuzzles() [][]byte {
 	return nil
 }
 
+func (m *GetSolutionPromisesRequest) GetCookie() []byte {
+	if m != nil {
+		return m.Cookie
+	}
+	return nil
+}
+
+func (m *GetSolutionPromisesRequest) GetRealPreimageCount() int32 {
+	if m != nil {
+		return m.RealPreimageCount
+	}
+	return 0
+}
+
+func (m *GetSolutionPromisesRequest) GetFakePreimageCount() int32 {
+	if m != nil {
+		return m.FakePreimageCount
+	}
+	return 0
+}
+
+func (m *GetSolutionPromisesRequest) GetSequence() uint64 {
+	if m != nil {
+		return m.Sequence
+	}
+	return 0
+}
+
+func (m *GetSolutionPromisesRequest) GetHashLock() uint32 {
+	if m != nil {
+		return m.HashLock
+	}
+	return 0
+}
+
+func (m *GetSolutionPromisesRequest) GetWork() []byte {
+	if m != nil {
+		return m.Work
+	}
+	return nil
+}
+
 type GetSolutionPromisesResponse struct {
-	Cookie    []byte   `protobuf:"bytes,1,opt,name=cookie,proto3" json:"cookie,omitempty"`
-	Promises  [][]byte `protobuf:"bytes,2,rep,name=promises,proto3" json:"promises,omitempty"`
-	KeyHashes [][]byte `protobuf:"bytes,3,rep,name=key_hashes,json=keyHashes,proto3" json:"key_hashes,omitempty"`
+	Cookie            []byte   `protobuf:"bytes,1,opt,name=cookie,proto3" json:"cookie,omitempty"`
+	Promises          [][]byte `protobuf:"bytes,2,rep,name=promises,proto3" json:"promises,omitempty"`
+	KeyHashes         [][]byte `protobuf:"bytes,3,rep,name=key_hashes,json=keyHashes,proto3" json:"key_hashes,omitempty"`
+	RealPreimageCount int32    `protobuf:"varint,4,opt,name=real_preimage_count,json=realPreimageCount" json:"real_preimage_count,omitempty"`
+	FakePreimageCount int32    `protobuf:"varint,5,opt,name=fake_preimage_count,json=fakePreimageCount" json:"fake_preimage_count,omitempty"`
 }
 
 func (m *GetSolutionPromisesResponse) Reset()                    { *m = GetSolutionPromisesResponse{} }
 func (m *GetSolutionPromisesResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetSolutionPromisesResponse) ProtoMessage()               {}
-func (*GetSolutionPromisesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+func (*GetSolutionPromisesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
 
 func (m *GetSolutionPromisesResponse) GetCookie() []byte {
 	if m != nil {
@@ -447,16 +1107,33 @@ func (m *GetSolutionPromisesResponse) GetKeyHashes() [][]byte {
 	return nil
 }
 
+func (m *GetSolutionPromisesResponse) GetRealPreimageCount() int32 {
+	if m != nil {
+		return m.RealPreimageCount
+	}
+	return 0
+}
+
+func (m *GetSolutionPromisesResponse) GetFakePreimageCount() int32 {
+	if m != nil {
+		return m.FakePreimageCount
+	}
+	return 0
+}
+
 type ValidateSolutionsRequest struct {
 	Cookie         []byte   `protobuf:"bytes,1,opt,name=cookie,proto3" json:"cookie,omitempty"`
 	FakePuzzleList []byte   `protobuf:"bytes,2,opt,name=fake_puzzle_list,json=fakePuzzleList,proto3" json:"fake_puzzle_list,omitempty"`
 	RandomFactors  [][]byte `protobuf:"bytes,3,rep,name=random_factors,json=randomFactors,proto3" json:"random_factors,omitempty"`
+	// Sequence number of the request within the session, must be greater
+	// than the one of any request previously made in the session.
+	Sequence uint64 `protobuf:"varint,4,opt,name=sequence" json:"sequence,omitempty"`
 }
 
 func (m *ValidateSolutionsRequest) Reset()                    { *m = ValidateSolutionsRequest{} }
 func (m *ValidateSolutionsRequest) String() string            { return proto.CompactTextString(m) }
 func (*ValidateSolutionsRequest) ProtoMessage()               {}
-func (*ValidateSolutionsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+func (*ValidateSolutionsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
 
 func (m *ValidateSolutionsRequest) GetCookie() []byte {
 	if m != nil {
@@ -479,6 +1156,13 @@ func (m *ValidateSolutionsRequest) GetRandomFactors() [][]byte {
 	return nil
 }
 
+func (m *ValidateSolutionsRequest) GetSequence() uint64 {
+	if m != nil {
+		return m.Sequence
+	}
+	return 0
+}
+
 type ValidateSolutionsResponse struct {
 	Secrets [][]byte `protobuf:"bytes,1,rep,name=secrets,proto3" json:"secrets,omitempty"`
 }
@@ -486,7 +1170,7 @@ type ValidateSolutionsResponse struct {
 func (m *ValidateSolutionsResponse) Reset()                    { *m = ValidateSolutionsResponse{} }
 func (m *ValidateSolutionsResponse) String() string            { return proto.CompactTextString(m) }
 func (*ValidateSolutionsResponse) ProtoMessage()               {}
-func (*ValidateSolutionsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+func (*ValidateSolutionsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
 
 func (m *ValidateSolutionsResponse) GetSecrets() [][]byte {
 	if m != nil {
@@ -505,12 +1189,16 @@ type PaymentOfferRequest struct {
 	Puzzle            []byte   `protobuf:"bytes,7,opt,name=puzzle,proto3" json:"puzzle,omitempty"`
 	RealPuzzleList    []byte   `protobuf:"bytes,8,opt,name=real_puzzle_list,json=realPuzzleList,proto3" json:"real_puzzle_list,omitempty"`
 	RandomFactors     [][]byte `protobuf:"bytes,9,rep,name=random_factors,json=randomFactors,proto3" json:"random_factors,omitempty"`
+	Capacity          int64    `protobuf:"varint,10,opt,name=capacity" json:"capacity,omitempty"`
+	// Sequence number of the request within the session, must be greater
+	// than the one of any request previously made in the session.
+	Sequence uint64 `protobuf:"varint,11,opt,name=sequence" json:"sequence,omitempty"`
 }
 
 func (m *PaymentOfferRequest) Reset()                    { *m = PaymentOfferRequest{} }
 func (m *PaymentOfferRequest) String() string            { return proto.CompactTextString(m) }
 func (*PaymentOfferRequest) ProtoMessage()               {}
-func (*PaymentOfferRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+func (*PaymentOfferRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
 
 func (m *PaymentOfferRequest) GetCookie() []byte {
 	if m != nil {
@@ -575,367 +1263,1928 @@ func (m *PaymentOfferRequest) GetRandomFactors() [][]byte {
 	return nil
 }
 
+func (m *PaymentOfferRequest) GetCapacity() int64 {
+	if m != nil {
+		return m.Capacity
+	}
+	return 0
+}
+
+func (m *PaymentOfferRequest) GetSequence() uint64 {
+	if m != nil {
+		return m.Sequence
+	}
+	return 0
+}
+
 type PaymentOfferResponse struct {
+	Secrets [][]byte `protobuf:"bytes,1,rep,name=secrets,proto3" json:"secrets,omitempty"`
 }
 
 func (m *PaymentOfferResponse) Reset()                    { *m = PaymentOfferResponse{} }
 func (m *PaymentOfferResponse) String() string            { return proto.CompactTextString(m) }
 func (*PaymentOfferResponse) ProtoMessage()               {}
-func (*PaymentOfferResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+func (*PaymentOfferResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
 
-func init() {
-	proto.RegisterType((*VersionRequest)(nil), "tumblerrpc.VersionRequest")
-	proto.RegisterType((*VersionResponse)(nil), "tumblerrpc.VersionResponse")
-	proto.RegisterType((*PingRequest)(nil), "tumblerrpc.PingRequest")
-	proto.RegisterType((*PingResponse)(nil), "tumblerrpc.PingResponse")
-	proto.RegisterType((*SetupEscrowRequest)(nil), "tumblerrpc.SetupEscrowRequest")
-	proto.RegisterType((*SetupEscrowResponse)(nil), "tumblerrpc.SetupEscrowResponse")
-	proto.RegisterType((*GetPuzzlePromisesRequest)(nil), "tumblerrpc.GetPuzzlePromisesRequest")
-	proto.RegisterType((*GetPuzzlePromisesResponse)(nil), "tumblerrpc.GetPuzzlePromisesResponse")
-	proto.RegisterType((*FinalizeEscrowRequest)(nil), "tumblerrpc.FinalizeEscrowRequest")
-	proto.RegisterType((*FinalizeEscrowResponse)(nil), "tumblerrpc.FinalizeEscrowResponse")
-	proto.RegisterType((*GetSolutionPromisesRequest)(nil), "tumblerrpc.GetSolutionPromisesRequest")
-	proto.RegisterType((*GetSolutionPromisesResponse)(nil), "tumblerrpc.GetSolutionPromisesResponse")
-	proto.RegisterType((*ValidateSolutionsRequest)(nil), "tumblerrpc.ValidateSolutionsRequest")
-	proto.RegisterType((*ValidateSolutionsResponse)(nil), "tumblerrpc.ValidateSolutionsResponse")
-	proto.RegisterType((*PaymentOfferRequest)(nil), "tumblerrpc.PaymentOfferRequest")
-	proto.RegisterType((*PaymentOfferResponse)(nil), "tumblerrpc.PaymentOfferResponse")
+func (m *PaymentOfferResponse) GetSecrets() [][]byte {
+	if m != nil {
+		return m.Secrets
+	}
+	return nil
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
-
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
+// Session cookies are opaque tokens authenticated by the tumbler that
+// expire together with the session.
+type ResumeSessionRequest struct {
+	Cookie []byte `protobuf:"bytes,1,opt,name=cookie,proto3" json:"cookie,omitempty"`
+}
 
-// Client API for VersionService service
+func (m *ResumeSessionRequest) Reset()                    { *m = ResumeSessionRequest{} }
+func (m *ResumeSessionRequest) String() string            { return proto.CompactTextString(m) }
+func (*ResumeSessionRequest) ProtoMessage()               {}
+func (*ResumeSessionRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
 
-type VersionServiceClient interface {
-	Version(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*VersionResponse, error)
+func (m *ResumeSessionRequest) GetCookie() []byte {
+	if m != nil {
+		return m.Cookie
+	}
+	return nil
 }
 
-type versionServiceClient struct {
-	cc *grpc.ClientConn
+type ResumeSessionResponse struct {
+	State string `protobuf:"bytes,1,opt,name=state" json:"state,omitempty"`
+	Epoch int32  `protobuf:"varint,2,opt,name=epoch" json:"epoch,omitempty"`
+	// Unix time of the session expiration.
+	Expires int64 `protobuf:"varint,3,opt,name=expires" json:"expires,omitempty"`
+	// Funds left in the payment channel, if one is established.
+	ChannelRemaining int64 `protobuf:"varint,4,opt,name=channel_remaining,json=channelRemaining" json:"channel_remaining,omitempty"`
+	// Sequence number of the last request accepted in the session.
+	Sequence uint64 `protobuf:"varint,5,opt,name=sequence" json:"sequence,omitempty"`
 }
 
-func NewVersionServiceClient(cc *grpc.ClientConn) VersionServiceClient {
-	return &versionServiceClient{cc}
-}
+func (m *ResumeSessionResponse) Reset()                    { *m = ResumeSessionResponse{} }
+func (m *ResumeSessionResponse) String() string            { return proto.CompactTextString(m) }
+func (*ResumeSessionResponse) ProtoMessage()               {}
+func (*ResumeSessionResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
 
-func (c *versionServiceClient) Version(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*VersionResponse, error) {
-	out := new(VersionResponse)
-	err := grpc.Invoke(ctx, "/tumblerrpc.VersionService/Version", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+func (m *ResumeSessionResponse) GetState() string {
+	if m != nil {
+		return m.State
 	}
-	return out, nil
+	return ""
 }
 
-// Server API for VersionService service
-
-type VersionServiceServer interface {
-	Version(context.Context, *VersionRequest) (*VersionResponse, error)
+func (m *ResumeSessionResponse) GetEpoch() int32 {
+	if m != nil {
+		return m.Epoch
+	}
+	return 0
 }
 
-func RegisterVersionServiceServer(s *grpc.Server, srv VersionServiceServer) {
-	s.RegisterService(&_VersionService_serviceDesc, srv)
+func (m *ResumeSessionResponse) GetExpires() int64 {
+	if m != nil {
+		return m.Expires
+	}
+	return 0
 }
 
-func _VersionService_Version_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(VersionRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(VersionServiceServer).Version(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/tumblerrpc.VersionService/Version",
+func (m *ResumeSessionResponse) GetChannelRemaining() int64 {
+	if m != nil {
+		return m.ChannelRemaining
 	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(VersionServiceServer).Version(ctx, req.(*VersionRequest))
+	return 0
+}
+
+func (m *ResumeSessionResponse) GetSequence() uint64 {
+	if m != nil {
+		return m.Sequence
 	}
-	return interceptor(ctx, in, info, handler)
+	return 0
 }
 
-var _VersionService_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "tumblerrpc.VersionService",
-	HandlerType: (*VersionServiceServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "Version",
-			Handler:    _VersionService_Version_Handler,
-		},
-	},
-	Streams:  []grpc.StreamDesc{},
-	Metadata: "api.proto",
+type RotateEpochRequest struct {
 }
 
-// Client API for TumblerService service
+func (m *RotateEpochRequest) Reset()                    { *m = RotateEpochRequest{} }
+func (m *RotateEpochRequest) String() string            { return proto.CompactTextString(m) }
+func (*RotateEpochRequest) ProtoMessage()               {}
+func (*RotateEpochRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
 
-type TumblerServiceClient interface {
-	// Queries
-	Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error)
-	// Exchange between Tumbler and payees
-	SetupEscrow(ctx context.Context, in *SetupEscrowRequest, opts ...grpc.CallOption) (*SetupEscrowResponse, error)
-	GetPuzzlePromises(ctx context.Context, in *GetPuzzlePromisesRequest, opts ...grpc.CallOption) (*GetPuzzlePromisesResponse, error)
-	FinalizeEscrow(ctx context.Context, in *FinalizeEscrowRequest, opts ...grpc.CallOption) (*FinalizeEscrowResponse, error)
-	// Exchange between Tumbler and payers
-	GetSolutionPromises(ctx context.Context, in *GetSolutionPromisesRequest, opts ...grpc.CallOption) (*GetSolutionPromisesResponse, error)
-	ValidateSolutions(ctx context.Context, in *ValidateSolutionsRequest, opts ...grpc.CallOption) (*ValidateSolutionsResponse, error)
-	PaymentOffer(ctx context.Context, in *PaymentOfferRequest, opts ...grpc.CallOption) (*PaymentOfferResponse, error)
+type RotateEpochResponse struct {
+	Epoch         int32  `protobuf:"varint,1,opt,name=epoch" json:"epoch,omitempty"`
+	PuzzleKeyHash []byte `protobuf:"bytes,2,opt,name=puzzle_key_hash,json=puzzleKeyHash,proto3" json:"puzzle_key_hash,omitempty"`
 }
 
-type tumblerServiceClient struct {
-	cc *grpc.ClientConn
-}
+func (m *RotateEpochResponse) Reset()                    { *m = RotateEpochResponse{} }
+func (m *RotateEpochResponse) String() string            { return proto.CompactTextString(m) }
+func (*RotateEpochResponse) ProtoMessage()               {}
+func (*RotateEpochResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{27} }
 
-func NewTumblerServiceClient(cc *grpc.ClientConn) TumblerServiceClient {
-	return &tumblerServiceClient{cc}
+func (m *RotateEpochResponse) GetEpoch() int32 {
+	if m != nil {
+		return m.Epoch
+	}
+	return 0
 }
 
-func (c *tumblerServiceClient) Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error) {
-	out := new(PingResponse)
-	err := grpc.Invoke(ctx, "/tumblerrpc.TumblerService/Ping", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+func (m *RotateEpochResponse) GetPuzzleKeyHash() []byte {
+	if m != nil {
+		return m.PuzzleKeyHash
 	}
-	return out, nil
+	return nil
 }
 
-func (c *tumblerServiceClient) SetupEscrow(ctx context.Context, in *SetupEscrowRequest, opts ...grpc.CallOption) (*SetupEscrowResponse, error) {
-	out := new(SetupEscrowResponse)
-	err := grpc.Invoke(ctx, "/tumblerrpc.TumblerService/SetupEscrow", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+// Alert describes an event requiring attention of the operator, such as
+// a payment offer escrow spent before the tumbler cashed it out.
+type Alert struct {
+	// Unix time the alert was raised.
+	Time int64 `protobuf:"varint,1,opt,name=time" json:"time,omitempty"`
+	// Hex encoded session cookie.
+	Session    string `protobuf:"bytes,2,opt,name=session" json:"session,omitempty"`
+	Address    string `protobuf:"bytes,3,opt,name=address" json:"address,omitempty"`
+	Epoch      int32  `protobuf:"varint,4,opt,name=epoch" json:"epoch,omitempty"`
+	EscrowHash string `protobuf:"bytes,5,opt,name=escrow_hash,json=escrowHash" json:"escrow_hash,omitempty"`
+	Message    string `protobuf:"bytes,6,opt,name=message" json:"message,omitempty"`
+}
+
+func (m *Alert) Reset()                    { *m = Alert{} }
+func (m *Alert) String() string            { return proto.CompactTextString(m) }
+func (*Alert) ProtoMessage()               {}
+func (*Alert) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{28} }
+
+func (m *Alert) GetTime() int64 {
+	if m != nil {
+		return m.Time
 	}
-	return out, nil
+	return 0
 }
 
-func (c *tumblerServiceClient) GetPuzzlePromises(ctx context.Context, in *GetPuzzlePromisesRequest, opts ...grpc.CallOption) (*GetPuzzlePromisesResponse, error) {
-	out := new(GetPuzzlePromisesResponse)
-	err := grpc.Invoke(ctx, "/tumblerrpc.TumblerService/GetPuzzlePromises", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+func (m *Alert) GetSession() string {
+	if m != nil {
+		return m.Session
 	}
-	return out, nil
+	return ""
 }
 
-func (c *tumblerServiceClient) FinalizeEscrow(ctx context.Context, in *FinalizeEscrowRequest, opts ...grpc.CallOption) (*FinalizeEscrowResponse, error) {
-	out := new(FinalizeEscrowResponse)
-	err := grpc.Invoke(ctx, "/tumblerrpc.TumblerService/FinalizeEscrow", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+func (m *Alert) GetAddress() string {
+	if m != nil {
+		return m.Address
 	}
-	return out, nil
+	return ""
 }
 
-func (c *tumblerServiceClient) GetSolutionPromises(ctx context.Context, in *GetSolutionPromisesRequest, opts ...grpc.CallOption) (*GetSolutionPromisesResponse, error) {
-	out := new(GetSolutionPromisesResponse)
-	err := grpc.Invoke(ctx, "/tumblerrpc.TumblerService/GetSolutionPromises", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+func (m *Alert) GetEpoch() int32 {
+	if m != nil {
+		return m.Epoch
 	}
-	return out, nil
+	return 0
 }
 
-func (c *tumblerServiceClient) ValidateSolutions(ctx context.Context, in *ValidateSolutionsRequest, opts ...grpc.CallOption) (*ValidateSolutionsResponse, error) {
-	out := new(ValidateSolutionsResponse)
-	err := grpc.Invoke(ctx, "/tumblerrpc.TumblerService/ValidateSolutions", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+func (m *Alert) GetEscrowHash() string {
+	if m != nil {
+		return m.EscrowHash
 	}
-	return out, nil
+	return ""
 }
 
-func (c *tumblerServiceClient) PaymentOffer(ctx context.Context, in *PaymentOfferRequest, opts ...grpc.CallOption) (*PaymentOfferResponse, error) {
-	out := new(PaymentOfferResponse)
-	err := grpc.Invoke(ctx, "/tumblerrpc.TumblerService/PaymentOffer", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+func (m *Alert) GetMessage() string {
+	if m != nil {
+		return m.Message
 	}
-	return out, nil
+	return ""
 }
 
-// Server API for TumblerService service
+type ListAlertsRequest struct {
+}
+
+func (m *ListAlertsRequest) Reset()                    { *m = ListAlertsRequest{} }
+func (m *ListAlertsRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListAlertsRequest) ProtoMessage()               {}
+func (*ListAlertsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{29} }
+
+type ListAlertsResponse struct {
+	// Most recent alerts, oldest first.
+	Alerts []*Alert `protobuf:"bytes,1,rep,name=alerts" json:"alerts,omitempty"`
+}
+
+func (m *ListAlertsResponse) Reset()                    { *m = ListAlertsResponse{} }
+func (m *ListAlertsResponse) String() string            { return proto.CompactTextString(m) }
+func (*ListAlertsResponse) ProtoMessage()               {}
+func (*ListAlertsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{30} }
+
+func (m *ListAlertsResponse) GetAlerts() []*Alert {
+	if m != nil {
+		return m.Alerts
+	}
+	return nil
+}
+
+// SessionEvent records a state transition or the finalization of an
+// exchange in the audit trail of a session.
+type SessionEvent struct {
+	// Unix time of the event in nanoseconds.
+	Time       int64  `protobuf:"varint,1,opt,name=time" json:"time,omitempty"`
+	Level      string `protobuf:"bytes,2,opt,name=level" json:"level,omitempty"`
+	Address    string `protobuf:"bytes,3,opt,name=address" json:"address,omitempty"`
+	Epoch      int32  `protobuf:"varint,4,opt,name=epoch" json:"epoch,omitempty"`
+	State      string `protobuf:"bytes,5,opt,name=state" json:"state,omitempty"`
+	EscrowHash string `protobuf:"bytes,6,opt,name=escrow_hash,json=escrowHash" json:"escrow_hash,omitempty"`
+	RedeemHash string `protobuf:"bytes,7,opt,name=redeem_hash,json=redeemHash" json:"redeem_hash,omitempty"`
+	Reason     string `protobuf:"bytes,8,opt,name=reason" json:"reason,omitempty"`
+	Error      string `protobuf:"bytes,9,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *SessionEvent) Reset()                    { *m = SessionEvent{} }
+func (m *SessionEvent) String() string            { return proto.CompactTextString(m) }
+func (*SessionEvent) ProtoMessage()               {}
+func (*SessionEvent) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{31} }
+
+func (m *SessionEvent) GetTime() int64 {
+	if m != nil {
+		return m.Time
+	}
+	return 0
+}
+
+func (m *SessionEvent) GetLevel() string {
+	if m != nil {
+		return m.Level
+	}
+	return ""
+}
+
+func (m *SessionEvent) GetAddress() string {
+	if m != nil {
+		return m.Address
+	}
+	return ""
+}
+
+func (m *SessionEvent) GetEpoch() int32 {
+	if m != nil {
+		return m.Epoch
+	}
+	return 0
+}
+
+func (m *SessionEvent) GetState() string {
+	if m != nil {
+		return m.State
+	}
+	return ""
+}
+
+func (m *SessionEvent) GetEscrowHash() string {
+	if m != nil {
+		return m.EscrowHash
+	}
+	return ""
+}
+
+func (m *SessionEvent) GetRedeemHash() string {
+	if m != nil {
+		return m.RedeemHash
+	}
+	return ""
+}
+
+func (m *SessionEvent) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+func (m *SessionEvent) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+type GetSessionHistoryRequest struct {
+	// Hex encoded session cookie.
+	Session string `protobuf:"bytes,1,opt,name=session" json:"session,omitempty"`
+}
+
+func (m *GetSessionHistoryRequest) Reset()                    { *m = GetSessionHistoryRequest{} }
+func (m *GetSessionHistoryRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetSessionHistoryRequest) ProtoMessage()               {}
+func (*GetSessionHistoryRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{32} }
+
+func (m *GetSessionHistoryRequest) GetSession() string {
+	if m != nil {
+		return m.Session
+	}
+	return ""
+}
+
+type GetSessionHistoryResponse struct {
+	// Events of the session, oldest first.
+	Events []*SessionEvent `protobuf:"bytes,1,rep,name=events" json:"events,omitempty"`
+}
+
+func (m *GetSessionHistoryResponse) Reset()                    { *m = GetSessionHistoryResponse{} }
+func (m *GetSessionHistoryResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetSessionHistoryResponse) ProtoMessage()               {}
+func (*GetSessionHistoryResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{33} }
+
+func (m *GetSessionHistoryResponse) GetEvents() []*SessionEvent {
+	if m != nil {
+		return m.Events
+	}
+	return nil
+}
+
+// SessionInfo describes a session in progress. Amounts are in atoms.
+type SessionInfo struct {
+	// Hex encoded session cookie.
+	Session string `protobuf:"bytes,1,opt,name=session" json:"session,omitempty"`
+	Address string `protobuf:"bytes,2,opt,name=address" json:"address,omitempty"`
+	Epoch   int32  `protobuf:"varint,3,opt,name=epoch" json:"epoch,omitempty"`
+	State   string `protobuf:"bytes,4,opt,name=state" json:"state,omitempty"`
+	// Unix time the session expires.
+	Expires    int64  `protobuf:"varint,5,opt,name=expires" json:"expires,omitempty"`
+	EscrowHash string `protobuf:"bytes,6,opt,name=escrow_hash,json=escrowHash" json:"escrow_hash,omitempty"`
+	Amount     int64  `protobuf:"varint,7,opt,name=amount" json:"amount,omitempty"`
+}
+
+func (m *SessionInfo) Reset()                    { *m = SessionInfo{} }
+func (m *SessionInfo) String() string            { return proto.CompactTextString(m) }
+func (*SessionInfo) ProtoMessage()               {}
+func (*SessionInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{34} }
+
+func (m *SessionInfo) GetSession() string {
+	if m != nil {
+		return m.Session
+	}
+	return ""
+}
+
+func (m *SessionInfo) GetAddress() string {
+	if m != nil {
+		return m.Address
+	}
+	return ""
+}
+
+func (m *SessionInfo) GetEpoch() int32 {
+	if m != nil {
+		return m.Epoch
+	}
+	return 0
+}
+
+func (m *SessionInfo) GetState() string {
+	if m != nil {
+		return m.State
+	}
+	return ""
+}
+
+func (m *SessionInfo) GetExpires() int64 {
+	if m != nil {
+		return m.Expires
+	}
+	return 0
+}
+
+func (m *SessionInfo) GetEscrowHash() string {
+	if m != nil {
+		return m.EscrowHash
+	}
+	return ""
+}
+
+func (m *SessionInfo) GetAmount() int64 {
+	if m != nil {
+		return m.Amount
+	}
+	return 0
+}
+
+// Sessions are listed if they match all filters that are set.
+type ListSessionsRequest struct {
+	State   string `protobuf:"bytes,1,opt,name=state" json:"state,omitempty"`
+	Epoch   int32  `protobuf:"varint,2,opt,name=epoch" json:"epoch,omitempty"`
+	Address string `protobuf:"bytes,3,opt,name=address" json:"address,omitempty"`
+}
+
+func (m *ListSessionsRequest) Reset()                    { *m = ListSessionsRequest{} }
+func (m *ListSessionsRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListSessionsRequest) ProtoMessage()               {}
+func (*ListSessionsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{35} }
+
+func (m *ListSessionsRequest) GetState() string {
+	if m != nil {
+		return m.State
+	}
+	return ""
+}
+
+func (m *ListSessionsRequest) GetEpoch() int32 {
+	if m != nil {
+		return m.Epoch
+	}
+	return 0
+}
+
+func (m *ListSessionsRequest) GetAddress() string {
+	if m != nil {
+		return m.Address
+	}
+	return ""
+}
+
+type ListSessionsResponse struct {
+	// Matching sessions ordered by their expiration.
+	Sessions []*SessionInfo `protobuf:"bytes,1,rep,name=sessions" json:"sessions,omitempty"`
+}
+
+func (m *ListSessionsResponse) Reset()                    { *m = ListSessionsResponse{} }
+func (m *ListSessionsResponse) String() string            { return proto.CompactTextString(m) }
+func (*ListSessionsResponse) ProtoMessage()               {}
+func (*ListSessionsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{36} }
+
+func (m *ListSessionsResponse) GetSessions() []*SessionInfo {
+	if m != nil {
+		return m.Sessions
+	}
+	return nil
+}
+
+type KillSessionRequest struct {
+	// Hex encoded session cookie.
+	Session string `protobuf:"bytes,1,opt,name=session" json:"session,omitempty"`
+	// Reason recorded in the audit trail of the session.
+	Reason string `protobuf:"bytes,2,opt,name=reason" json:"reason,omitempty"`
+}
+
+func (m *KillSessionRequest) Reset()                    { *m = KillSessionRequest{} }
+func (m *KillSessionRequest) String() string            { return proto.CompactTextString(m) }
+func (*KillSessionRequest) ProtoMessage()               {}
+func (*KillSessionRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{37} }
+
+func (m *KillSessionRequest) GetSession() string {
+	if m != nil {
+		return m.Session
+	}
+	return ""
+}
+
+func (m *KillSessionRequest) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+type KillSessionResponse struct {
+}
+
+func (m *KillSessionResponse) Reset()                    { *m = KillSessionResponse{} }
+func (m *KillSessionResponse) String() string            { return proto.CompactTextString(m) }
+func (*KillSessionResponse) ProtoMessage()               {}
+func (*KillSessionResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{38} }
+
+// EpochAccount summarizes exchanges of an epoch. Amounts are in atoms.
+type EpochAccount struct {
+	// Block height the epoch has started at.
+	Epoch int32 `protobuf:"varint,1,opt,name=epoch" json:"epoch,omitempty"`
+	// Escrows published for payees.
+	Escrows   int32 `protobuf:"varint,2,opt,name=escrows" json:"escrows,omitempty"`
+	Completed int32 `protobuf:"varint,3,opt,name=completed" json:"completed,omitempty"`
+	Failed    int32 `protobuf:"varint,4,opt,name=failed" json:"failed,omitempty"`
+	// Offers of payers cashed out by the tumbler.
+	EscrowedIn int64 `protobuf:"varint,5,opt,name=escrowed_in,json=escrowedIn" json:"escrowed_in,omitempty"`
+	// Escrows of the tumbler paying payees.
+	EscrowedOut int64 `protobuf:"varint,6,opt,name=escrowed_out,json=escrowedOut" json:"escrowed_out,omitempty"`
+	// Commissions charged to payers.
+	FeesEarned int64 `protobuf:"varint,7,opt,name=fees_earned,json=feesEarned" json:"fees_earned,omitempty"`
+	// Transaction fees paid by the tumbler.
+	TxFees int64 `protobuf:"varint,8,opt,name=tx_fees,json=txFees" json:"tx_fees,omitempty"`
+}
+
+func (m *EpochAccount) Reset()                    { *m = EpochAccount{} }
+func (m *EpochAccount) String() string            { return proto.CompactTextString(m) }
+func (*EpochAccount) ProtoMessage()               {}
+func (*EpochAccount) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{39} }
+
+func (m *EpochAccount) GetEpoch() int32 {
+	if m != nil {
+		return m.Epoch
+	}
+	return 0
+}
+
+func (m *EpochAccount) GetEscrows() int32 {
+	if m != nil {
+		return m.Escrows
+	}
+	return 0
+}
+
+func (m *EpochAccount) GetCompleted() int32 {
+	if m != nil {
+		return m.Completed
+	}
+	return 0
+}
+
+func (m *EpochAccount) GetFailed() int32 {
+	if m != nil {
+		return m.Failed
+	}
+	return 0
+}
+
+func (m *EpochAccount) GetEscrowedIn() int64 {
+	if m != nil {
+		return m.EscrowedIn
+	}
+	return 0
+}
+
+func (m *EpochAccount) GetEscrowedOut() int64 {
+	if m != nil {
+		return m.EscrowedOut
+	}
+	return 0
+}
+
+func (m *EpochAccount) GetFeesEarned() int64 {
+	if m != nil {
+		return m.FeesEarned
+	}
+	return 0
+}
+
+func (m *EpochAccount) GetTxFees() int64 {
+	if m != nil {
+		return m.TxFees
+	}
+	return 0
+}
+
+type GetAccountingRequest struct {
+	// Also export the accounts as CSV.
+	Csv bool `protobuf:"varint,1,opt,name=csv" json:"csv,omitempty"`
+}
+
+func (m *GetAccountingRequest) Reset()                    { *m = GetAccountingRequest{} }
+func (m *GetAccountingRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetAccountingRequest) ProtoMessage()               {}
+func (*GetAccountingRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{40} }
+
+func (m *GetAccountingRequest) GetCsv() bool {
+	if m != nil {
+		return m.Csv
+	}
+	return false
+}
+
+type GetAccountingResponse struct {
+	// Accounts of epochs with exchanges, oldest first.
+	Accounts []*EpochAccount `protobuf:"bytes,1,rep,name=accounts" json:"accounts,omitempty"`
+	// CSV export of the accounts with a header row, if requested.
+	Csv []byte `protobuf:"bytes,2,opt,name=csv,proto3" json:"csv,omitempty"`
+}
+
+func (m *GetAccountingResponse) Reset()                    { *m = GetAccountingResponse{} }
+func (m *GetAccountingResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetAccountingResponse) ProtoMessage()               {}
+func (*GetAccountingResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{41} }
+
+func (m *GetAccountingResponse) GetAccounts() []*EpochAccount {
+	if m != nil {
+		return m.Accounts
+	}
+	return nil
+}
+
+func (m *GetAccountingResponse) GetCsv() []byte {
+	if m != nil {
+		return m.Csv
+	}
+	return nil
+}
+
+// LogLevel is the logging level of a subsystem of the tumbler.
+type LogLevel struct {
+	// Subsystem identifier, e.g. TMBL.
+	Subsystem string `protobuf:"bytes,1,opt,name=subsystem" json:"subsystem,omitempty"`
+	// One of trace, debug, info, warn, error, critical and off.
+	Level string `protobuf:"bytes,2,opt,name=level" json:"level,omitempty"`
+}
+
+func (m *LogLevel) Reset()                    { *m = LogLevel{} }
+func (m *LogLevel) String() string            { return proto.CompactTextString(m) }
+func (*LogLevel) ProtoMessage()               {}
+func (*LogLevel) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{42} }
+
+func (m *LogLevel) GetSubsystem() string {
+	if m != nil {
+		return m.Subsystem
+	}
+	return ""
+}
+
+func (m *LogLevel) GetLevel() string {
+	if m != nil {
+		return m.Level
+	}
+	return ""
+}
+
+type GetLogLevelsRequest struct {
+}
+
+func (m *GetLogLevelsRequest) Reset()                    { *m = GetLogLevelsRequest{} }
+func (m *GetLogLevelsRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetLogLevelsRequest) ProtoMessage()               {}
+func (*GetLogLevelsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{43} }
+
+type GetLogLevelsResponse struct {
+	// Levels of all subsystems sorted by their identifiers.
+	Levels []*LogLevel `protobuf:"bytes,1,rep,name=levels" json:"levels,omitempty"`
+}
+
+func (m *GetLogLevelsResponse) Reset()                    { *m = GetLogLevelsResponse{} }
+func (m *GetLogLevelsResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetLogLevelsResponse) ProtoMessage()               {}
+func (*GetLogLevelsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{44} }
+
+func (m *GetLogLevelsResponse) GetLevels() []*LogLevel {
+	if m != nil {
+		return m.Levels
+	}
+	return nil
+}
+
+type SetLogLevelRequest struct {
+	// Subsystem identifier, or empty to set the level of all subsystems.
+	Subsystem string `protobuf:"bytes,1,opt,name=subsystem" json:"subsystem,omitempty"`
+	Level     string `protobuf:"bytes,2,opt,name=level" json:"level,omitempty"`
+}
+
+func (m *SetLogLevelRequest) Reset()                    { *m = SetLogLevelRequest{} }
+func (m *SetLogLevelRequest) String() string            { return proto.CompactTextString(m) }
+func (*SetLogLevelRequest) ProtoMessage()               {}
+func (*SetLogLevelRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{45} }
+
+func (m *SetLogLevelRequest) GetSubsystem() string {
+	if m != nil {
+		return m.Subsystem
+	}
+	return ""
+}
+
+func (m *SetLogLevelRequest) GetLevel() string {
+	if m != nil {
+		return m.Level
+	}
+	return ""
+}
+
+type SetLogLevelResponse struct {
+	// Levels of all subsystems after the change.
+	Levels []*LogLevel `protobuf:"bytes,1,rep,name=levels" json:"levels,omitempty"`
+}
+
+func (m *SetLogLevelResponse) Reset()                    { *m = SetLogLevelResponse{} }
+func (m *SetLogLevelResponse) String() string            { return proto.CompactTextString(m) }
+func (*SetLogLevelResponse) ProtoMessage()               {}
+func (*SetLogLevelResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{46} }
+
+func (m *SetLogLevelResponse) GetLevels() []*LogLevel {
+	if m != nil {
+		return m.Levels
+	}
+	return nil
+}
+
+type RotateTLSCertRequest struct {
+}
+
+func (m *RotateTLSCertRequest) Reset()                    { *m = RotateTLSCertRequest{} }
+func (m *RotateTLSCertRequest) String() string            { return proto.CompactTextString(m) }
+func (*RotateTLSCertRequest) ProtoMessage()               {}
+func (*RotateTLSCertRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{47} }
+
+type RotateTLSCertResponse struct {
+	// PEM encoded TLS certificates after the rotation, as returned by
+	// GetServerCerts.
+	Certs        []byte `protobuf:"bytes,1,opt,name=certs,proto3" json:"certs,omitempty"`
+	RotationTime int64  `protobuf:"varint,2,opt,name=rotation_time,json=rotationTime" json:"rotation_time,omitempty"`
+}
+
+func (m *RotateTLSCertResponse) Reset()                    { *m = RotateTLSCertResponse{} }
+func (m *RotateTLSCertResponse) String() string            { return proto.CompactTextString(m) }
+func (*RotateTLSCertResponse) ProtoMessage()               {}
+func (*RotateTLSCertResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{48} }
+
+func (m *RotateTLSCertResponse) GetCerts() []byte {
+	if m != nil {
+		return m.Certs
+	}
+	return nil
+}
+
+func (m *RotateTLSCertResponse) GetRotationTime() int64 {
+	if m != nil {
+		return m.RotationTime
+	}
+	return 0
+}
+
+type CaptureCPUProfileRequest struct {
+	// Duration of the capture in seconds, 30 if zero.
+	Seconds int32 `protobuf:"varint,1,opt,name=seconds" json:"seconds,omitempty"`
+}
+
+func (m *CaptureCPUProfileRequest) Reset()                    { *m = CaptureCPUProfileRequest{} }
+func (m *CaptureCPUProfileRequest) String() string            { return proto.CompactTextString(m) }
+func (*CaptureCPUProfileRequest) ProtoMessage()               {}
+func (*CaptureCPUProfileRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{49} }
+
+func (m *CaptureCPUProfileRequest) GetSeconds() int32 {
+	if m != nil {
+		return m.Seconds
+	}
+	return 0
+}
+
+type CaptureCPUProfileResponse struct {
+	// CPU profile in the gzipped protobuf format read by go tool pprof.
+	Profile []byte `protobuf:"bytes,1,opt,name=profile,proto3" json:"profile,omitempty"`
+}
+
+func (m *CaptureCPUProfileResponse) Reset()                    { *m = CaptureCPUProfileResponse{} }
+func (m *CaptureCPUProfileResponse) String() string            { return proto.CompactTextString(m) }
+func (*CaptureCPUProfileResponse) ProtoMessage()               {}
+func (*CaptureCPUProfileResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{50} }
+
+func (m *CaptureCPUProfileResponse) GetProfile() []byte {
+	if m != nil {
+		return m.Profile
+	}
+	return nil
+}
+
+// ErrorDetail is attached to the status of failed TumblerService calls.
+type ErrorDetail struct {
+	Category ErrorCategory `protobuf:"varint,1,opt,name=category,enum=tumblerrpc.ErrorCategory" json:"category,omitempty"`
+	// Suggested delay in seconds before retrying the request.
+	RetryAfter int64 `protobuf:"varint,2,opt,name=retry_after,json=retryAfter" json:"retry_after,omitempty"`
+	// State of the session when the error has occurred.
+	State string `protobuf:"bytes,3,opt,name=state" json:"state,omitempty"`
+}
+
+func (m *ErrorDetail) Reset()                    { *m = ErrorDetail{} }
+func (m *ErrorDetail) String() string            { return proto.CompactTextString(m) }
+func (*ErrorDetail) ProtoMessage()               {}
+func (*ErrorDetail) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{51} }
+
+func (m *ErrorDetail) GetCategory() ErrorCategory {
+	if m != nil {
+		return m.Category
+	}
+	return ErrorCategory_UNKNOWN
+}
+
+func (m *ErrorDetail) GetRetryAfter() int64 {
+	if m != nil {
+		return m.RetryAfter
+	}
+	return 0
+}
+
+func (m *ErrorDetail) GetState() string {
+	if m != nil {
+		return m.State
+	}
+	return ""
+}
+
+// IncompatibilityDetail is attached to the status of a failed handshake.
+type IncompatibilityDetail struct {
+	MinProtocolVersion uint32   `protobuf:"varint,1,opt,name=min_protocol_version,json=minProtocolVersion" json:"min_protocol_version,omitempty"`
+	MaxProtocolVersion uint32   `protobuf:"varint,2,opt,name=max_protocol_version,json=maxProtocolVersion" json:"max_protocol_version,omitempty"`
+	MissingFeatures    []string `protobuf:"bytes,3,rep,name=missing_features,json=missingFeatures" json:"missing_features,omitempty"`
+}
+
+func (m *IncompatibilityDetail) Reset()                    { *m = IncompatibilityDetail{} }
+func (m *IncompatibilityDetail) String() string            { return proto.CompactTextString(m) }
+func (*IncompatibilityDetail) ProtoMessage()               {}
+func (*IncompatibilityDetail) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{52} }
+
+func (m *IncompatibilityDetail) GetMinProtocolVersion() uint32 {
+	if m != nil {
+		return m.MinProtocolVersion
+	}
+	return 0
+}
+
+func (m *IncompatibilityDetail) GetMaxProtocolVersion() uint32 {
+	if m != nil {
+		return m.MaxProtocolVersion
+	}
+	return 0
+}
+
+func (m *IncompatibilityDetail) GetMissingFeatures() []string {
+	if m != nil {
+		return m.MissingFeatures
+	}
+	return nil
+}
+
+// ValidationFailure is attached to the status of a FinalizeEscrow call
+// rejecting the transaction disclosure, so that the payee can tell whether
+// its disclosure or the tumbler is at fault.
+type ValidationFailure struct {
+	Check ValidationCheck `protobuf:"varint,1,opt,name=check,enum=tumblerrpc.ValidationCheck" json:"check,omitempty"`
+	// Indices of the offending transaction hashes of the challenge, if the
+	// check concerns individual transactions.
+	Indices []uint32 `protobuf:"varint,2,rep,packed,name=indices" json:"indices,omitempty"`
+}
+
+func (m *ValidationFailure) Reset()                    { *m = ValidationFailure{} }
+func (m *ValidationFailure) String() string            { return proto.CompactTextString(m) }
+func (*ValidationFailure) ProtoMessage()               {}
+func (*ValidationFailure) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{53} }
+
+func (m *ValidationFailure) GetCheck() ValidationCheck {
+	if m != nil {
+		return m.Check
+	}
+	return ValidationCheck_UNKNOWN_CHECK
+}
+
+func (m *ValidationFailure) GetIndices() []uint32 {
+	if m != nil {
+		return m.Indices
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*VersionRequest)(nil), "tumblerrpc.VersionRequest")
+	proto.RegisterType((*VersionResponse)(nil), "tumblerrpc.VersionResponse")
+	proto.RegisterType((*HandshakeRequest)(nil), "tumblerrpc.HandshakeRequest")
+	proto.RegisterType((*HandshakeResponse)(nil), "tumblerrpc.HandshakeResponse")
+	proto.RegisterType((*PingRequest)(nil), "tumblerrpc.PingRequest")
+	proto.RegisterType((*PingResponse)(nil), "tumblerrpc.PingResponse")
+	proto.RegisterType((*GetTumblerInfoRequest)(nil), "tumblerrpc.GetTumblerInfoRequest")
+	proto.RegisterType((*GetTumblerInfoResponse)(nil), "tumblerrpc.GetTumblerInfoResponse")
+	proto.RegisterType((*GetEpochManifestRequest)(nil), "tumblerrpc.GetEpochManifestRequest")
+	proto.RegisterType((*GetEpochManifestResponse)(nil), "tumblerrpc.GetEpochManifestResponse")
+	proto.RegisterType((*GetServerCertsRequest)(nil), "tumblerrpc.GetServerCertsRequest")
+	proto.RegisterType((*GetServerCertsResponse)(nil), "tumblerrpc.GetServerCertsResponse")
+	proto.RegisterType((*SetupEscrowRequest)(nil), "tumblerrpc.SetupEscrowRequest")
+	proto.RegisterType((*SetupEscrowResponse)(nil), "tumblerrpc.SetupEscrowResponse")
+	proto.RegisterType((*GetPuzzlePromisesRequest)(nil), "tumblerrpc.GetPuzzlePromisesRequest")
+	proto.RegisterType((*GetPuzzlePromisesResponse)(nil), "tumblerrpc.GetPuzzlePromisesResponse")
+	proto.RegisterType((*FinalizeEscrowRequest)(nil), "tumblerrpc.FinalizeEscrowRequest")
+	proto.RegisterType((*FinalizeEscrowResponse)(nil), "tumblerrpc.FinalizeEscrowResponse")
+	proto.RegisterType((*GetSolutionPromisesRequest)(nil), "tumblerrpc.GetSolutionPromisesRequest")
+	proto.RegisterType((*GetSolutionPromisesResponse)(nil), "tumblerrpc.GetSolutionPromisesResponse")
+	proto.RegisterType((*ValidateSolutionsRequest)(nil), "tumblerrpc.ValidateSolutionsRequest")
+	proto.RegisterType((*ValidateSolutionsResponse)(nil), "tumblerrpc.ValidateSolutionsResponse")
+	proto.RegisterType((*PaymentOfferRequest)(nil), "tumblerrpc.PaymentOfferRequest")
+	proto.RegisterType((*PaymentOfferResponse)(nil), "tumblerrpc.PaymentOfferResponse")
+	proto.RegisterType((*ResumeSessionRequest)(nil), "tumblerrpc.ResumeSessionRequest")
+	proto.RegisterType((*ResumeSessionResponse)(nil), "tumblerrpc.ResumeSessionResponse")
+	proto.RegisterType((*RotateEpochRequest)(nil), "tumblerrpc.RotateEpochRequest")
+	proto.RegisterType((*RotateEpochResponse)(nil), "tumblerrpc.RotateEpochResponse")
+	proto.RegisterType((*Alert)(nil), "tumblerrpc.Alert")
+	proto.RegisterType((*ListAlertsRequest)(nil), "tumblerrpc.ListAlertsRequest")
+	proto.RegisterType((*ListAlertsResponse)(nil), "tumblerrpc.ListAlertsResponse")
+	proto.RegisterType((*SessionEvent)(nil), "tumblerrpc.SessionEvent")
+	proto.RegisterType((*GetSessionHistoryRequest)(nil), "tumblerrpc.GetSessionHistoryRequest")
+	proto.RegisterType((*GetSessionHistoryResponse)(nil), "tumblerrpc.GetSessionHistoryResponse")
+	proto.RegisterType((*SessionInfo)(nil), "tumblerrpc.SessionInfo")
+	proto.RegisterType((*ListSessionsRequest)(nil), "tumblerrpc.ListSessionsRequest")
+	proto.RegisterType((*ListSessionsResponse)(nil), "tumblerrpc.ListSessionsResponse")
+	proto.RegisterType((*KillSessionRequest)(nil), "tumblerrpc.KillSessionRequest")
+	proto.RegisterType((*KillSessionResponse)(nil), "tumblerrpc.KillSessionResponse")
+	proto.RegisterType((*EpochAccount)(nil), "tumblerrpc.EpochAccount")
+	proto.RegisterType((*GetAccountingRequest)(nil), "tumblerrpc.GetAccountingRequest")
+	proto.RegisterType((*GetAccountingResponse)(nil), "tumblerrpc.GetAccountingResponse")
+	proto.RegisterType((*LogLevel)(nil), "tumblerrpc.LogLevel")
+	proto.RegisterType((*GetLogLevelsRequest)(nil), "tumblerrpc.GetLogLevelsRequest")
+	proto.RegisterType((*GetLogLevelsResponse)(nil), "tumblerrpc.GetLogLevelsResponse")
+	proto.RegisterType((*SetLogLevelRequest)(nil), "tumblerrpc.SetLogLevelRequest")
+	proto.RegisterType((*SetLogLevelResponse)(nil), "tumblerrpc.SetLogLevelResponse")
+	proto.RegisterType((*RotateTLSCertRequest)(nil), "tumblerrpc.RotateTLSCertRequest")
+	proto.RegisterType((*RotateTLSCertResponse)(nil), "tumblerrpc.RotateTLSCertResponse")
+	proto.RegisterType((*CaptureCPUProfileRequest)(nil), "tumblerrpc.CaptureCPUProfileRequest")
+	proto.RegisterType((*CaptureCPUProfileResponse)(nil), "tumblerrpc.CaptureCPUProfileResponse")
+	proto.RegisterType((*ErrorDetail)(nil), "tumblerrpc.ErrorDetail")
+	proto.RegisterType((*IncompatibilityDetail)(nil), "tumblerrpc.IncompatibilityDetail")
+	proto.RegisterType((*ValidationFailure)(nil), "tumblerrpc.ValidationFailure")
+	proto.RegisterEnum("tumblerrpc.ErrorCategory", ErrorCategory_name, ErrorCategory_value)
+	proto.RegisterEnum("tumblerrpc.ValidationCheck", ValidationCheck_name, ValidationCheck_value)
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// Client API for VersionService service
+
+type VersionServiceClient interface {
+	Version(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*VersionResponse, error)
+	Handshake(ctx context.Context, in *HandshakeRequest, opts ...grpc.CallOption) (*HandshakeResponse, error)
+}
+
+type versionServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewVersionServiceClient(cc *grpc.ClientConn) VersionServiceClient {
+	return &versionServiceClient{cc}
+}
+
+func (c *versionServiceClient) Version(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*VersionResponse, error) {
+	out := new(VersionResponse)
+	err := grpc.Invoke(ctx, "/tumblerrpc.VersionService/Version", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *versionServiceClient) Handshake(ctx context.Context, in *HandshakeRequest, opts ...grpc.CallOption) (*HandshakeResponse, error) {
+	out := new(HandshakeResponse)
+	err := grpc.Invoke(ctx, "/tumblerrpc.VersionService/Handshake", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for VersionService service
+
+type VersionServiceServer interface {
+	Version(context.Context, *VersionRequest) (*VersionResponse, error)
+	Handshake(context.Context, *HandshakeRequest) (*HandshakeResponse, error)
+}
+
+func RegisterVersionServiceServer(s *grpc.Server, srv VersionServiceServer) {
+	s.RegisterService(&_VersionService_serviceDesc, srv)
+}
+
+func _VersionService_Version_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VersionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VersionServiceServer).Version(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tumblerrpc.VersionService/Version",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VersionServiceServer).Version(ctx, req.(*VersionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VersionService_Handshake_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HandshakeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VersionServiceServer).Handshake(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tumblerrpc.VersionService/Handshake",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VersionServiceServer).Handshake(ctx, req.(*HandshakeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _VersionService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "tumblerrpc.VersionService",
+	HandlerType: (*VersionServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Version",
+			Handler:    _VersionService_Version_Handler,
+		},
+		{
+			MethodName: "Handshake",
+			Handler:    _VersionService_Handshake_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "api.proto",
+}
+
+// Client API for TumblerService service
+
+type TumblerServiceClient interface {
+	// Queries
+	Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error)
+	GetTumblerInfo(ctx context.Context, in *GetTumblerInfoRequest, opts ...grpc.CallOption) (*GetTumblerInfoResponse, error)
+	GetEpochManifest(ctx context.Context, in *GetEpochManifestRequest, opts ...grpc.CallOption) (*GetEpochManifestResponse, error)
+	GetServerCerts(ctx context.Context, in *GetServerCertsRequest, opts ...grpc.CallOption) (*GetServerCertsResponse, error)
+	// Exchange between Tumbler and payees
+	SetupEscrow(ctx context.Context, in *SetupEscrowRequest, opts ...grpc.CallOption) (*SetupEscrowResponse, error)
+	GetPuzzlePromises(ctx context.Context, in *GetPuzzlePromisesRequest, opts ...grpc.CallOption) (*GetPuzzlePromisesResponse, error)
+	FinalizeEscrow(ctx context.Context, in *FinalizeEscrowRequest, opts ...grpc.CallOption) (*FinalizeEscrowResponse, error)
+	// Exchange between Tumbler and payers
+	GetSolutionPromises(ctx context.Context, in *GetSolutionPromisesRequest, opts ...grpc.CallOption) (*GetSolutionPromisesResponse, error)
+	ValidateSolutions(ctx context.Context, in *ValidateSolutionsRequest, opts ...grpc.CallOption) (*ValidateSolutionsResponse, error)
+	PaymentOffer(ctx context.Context, in *PaymentOfferRequest, opts ...grpc.CallOption) (*PaymentOfferResponse, error)
+	// Recovery after a disconnect
+	ResumeSession(ctx context.Context, in *ResumeSessionRequest, opts ...grpc.CallOption) (*ResumeSessionResponse, error)
+	// Streaming variants of GetPuzzlePromises and GetSolutionPromises for
+	// puzzle vectors exceeding message size limits. The first message of
+	// a stream carries all fields while the following ones only continue
+	// repeated fields. Solution promises are streamed once the client
+	// closes its side of the stream.
+	StreamPuzzlePromises(ctx context.Context, in *GetPuzzlePromisesRequest, opts ...grpc.CallOption) (TumblerService_StreamPuzzlePromisesClient, error)
+	StreamSolutionPromises(ctx context.Context, opts ...grpc.CallOption) (TumblerService_StreamSolutionPromisesClient, error)
+}
+
+type tumblerServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewTumblerServiceClient(cc *grpc.ClientConn) TumblerServiceClient {
+	return &tumblerServiceClient{cc}
+}
+
+func (c *tumblerServiceClient) Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error) {
+	out := new(PingResponse)
+	err := grpc.Invoke(ctx, "/tumblerrpc.TumblerService/Ping", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tumblerServiceClient) GetTumblerInfo(ctx context.Context, in *GetTumblerInfoRequest, opts ...grpc.CallOption) (*GetTumblerInfoResponse, error) {
+	out := new(GetTumblerInfoResponse)
+	err := grpc.Invoke(ctx, "/tumblerrpc.TumblerService/GetTumblerInfo", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tumblerServiceClient) GetEpochManifest(ctx context.Context, in *GetEpochManifestRequest, opts ...grpc.CallOption) (*GetEpochManifestResponse, error) {
+	out := new(GetEpochManifestResponse)
+	err := grpc.Invoke(ctx, "/tumblerrpc.TumblerService/GetEpochManifest", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tumblerServiceClient) GetServerCerts(ctx context.Context, in *GetServerCertsRequest, opts ...grpc.CallOption) (*GetServerCertsResponse, error) {
+	out := new(GetServerCertsResponse)
+	err := grpc.Invoke(ctx, "/tumblerrpc.TumblerService/GetServerCerts", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tumblerServiceClient) SetupEscrow(ctx context.Context, in *SetupEscrowRequest, opts ...grpc.CallOption) (*SetupEscrowResponse, error) {
+	out := new(SetupEscrowResponse)
+	err := grpc.Invoke(ctx, "/tumblerrpc.TumblerService/SetupEscrow", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tumblerServiceClient) GetPuzzlePromises(ctx context.Context, in *GetPuzzlePromisesRequest, opts ...grpc.CallOption) (*GetPuzzlePromisesResponse, error) {
+	out := new(GetPuzzlePromisesResponse)
+	err := grpc.Invoke(ctx, "/tumblerrpc.TumblerService/GetPuzzlePromises", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tumblerServiceClient) FinalizeEscrow(ctx context.Context, in *FinalizeEscrowRequest, opts ...grpc.CallOption) (*FinalizeEscrowResponse, error) {
+	out := new(FinalizeEscrowResponse)
+	err := grpc.Invoke(ctx, "/tumblerrpc.TumblerService/FinalizeEscrow", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tumblerServiceClient) GetSolutionPromises(ctx context.Context, in *GetSolutionPromisesRequest, opts ...grpc.CallOption) (*GetSolutionPromisesResponse, error) {
+	out := new(GetSolutionPromisesResponse)
+	err := grpc.Invoke(ctx, "/tumblerrpc.TumblerService/GetSolutionPromises", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tumblerServiceClient) ValidateSolutions(ctx context.Context, in *ValidateSolutionsRequest, opts ...grpc.CallOption) (*ValidateSolutionsResponse, error) {
+	out := new(ValidateSolutionsResponse)
+	err := grpc.Invoke(ctx, "/tumblerrpc.TumblerService/ValidateSolutions", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tumblerServiceClient) PaymentOffer(ctx context.Context, in *PaymentOfferRequest, opts ...grpc.CallOption) (*PaymentOfferResponse, error) {
+	out := new(PaymentOfferResponse)
+	err := grpc.Invoke(ctx, "/tumblerrpc.TumblerService/PaymentOffer", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tumblerServiceClient) ResumeSession(ctx context.Context, in *ResumeSessionRequest, opts ...grpc.CallOption) (*ResumeSessionResponse, error) {
+	out := new(ResumeSessionResponse)
+	err := grpc.Invoke(ctx, "/tumblerrpc.TumblerService/ResumeSession", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tumblerServiceClient) StreamPuzzlePromises(ctx context.Context, in *GetPuzzlePromisesRequest, opts ...grpc.CallOption) (TumblerService_StreamPuzzlePromisesClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_TumblerService_serviceDesc.Streams[0], c.cc, "/tumblerrpc.TumblerService/StreamPuzzlePromises", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &tumblerServiceStreamPuzzlePromisesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TumblerService_StreamPuzzlePromisesClient interface {
+	Recv() (*GetPuzzlePromisesResponse, error)
+	grpc.ClientStream
+}
+
+type tumblerServiceStreamPuzzlePromisesClient struct {
+	grpc.ClientStream
+}
+
+func (x *tumblerServiceStreamPuzzlePromisesClient) Recv() (*GetPuzzlePromisesResponse, error) {
+	m := new(GetPuzzlePromisesResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *tumblerServiceClient) StreamSolutionPromises(ctx context.Context, opts ...grpc.CallOption) (TumblerService_StreamSolutionPromisesClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_TumblerService_serviceDesc.Streams[1], c.cc, "/tumblerrpc.TumblerService/StreamSolutionPromises", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &tumblerServiceStreamSolutionPromisesClient{stream}
+	return x, nil
+}
+
+type TumblerService_StreamSolutionPromisesClient interface {
+	Send(*GetSolutionPromisesRequest) error
+	Recv() (*GetSolutionPromisesResponse, error)
+	grpc.ClientStream
+}
+
+type tumblerServiceStreamSolutionPromisesClient struct {
+	grpc.ClientStream
+}
+
+func (x *tumblerServiceStreamSolutionPromisesClient) Send(m *GetSolutionPromisesRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *tumblerServiceStreamSolutionPromisesClient) Recv() (*GetSolutionPromisesResponse, error) {
+	m := new(GetSolutionPromisesResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Server API for TumblerService service
+
+type TumblerServiceServer interface {
+	// Queries
+	Ping(context.Context, *PingRequest) (*PingResponse, error)
+	GetTumblerInfo(context.Context, *GetTumblerInfoRequest) (*GetTumblerInfoResponse, error)
+	GetEpochManifest(context.Context, *GetEpochManifestRequest) (*GetEpochManifestResponse, error)
+	GetServerCerts(context.Context, *GetServerCertsRequest) (*GetServerCertsResponse, error)
+	// Exchange between Tumbler and payees
+	SetupEscrow(context.Context, *SetupEscrowRequest) (*SetupEscrowResponse, error)
+	GetPuzzlePromises(context.Context, *GetPuzzlePromisesRequest) (*GetPuzzlePromisesResponse, error)
+	FinalizeEscrow(context.Context, *FinalizeEscrowRequest) (*FinalizeEscrowResponse, error)
+	// Exchange between Tumbler and payers
+	GetSolutionPromises(context.Context, *GetSolutionPromisesRequest) (*GetSolutionPromisesResponse, error)
+	ValidateSolutions(context.Context, *ValidateSolutionsRequest) (*ValidateSolutionsResponse, error)
+	PaymentOffer(context.Context, *PaymentOfferRequest) (*PaymentOfferResponse, error)
+	// Recovery after a disconnect
+	ResumeSession(context.Context, *ResumeSessionRequest) (*ResumeSessionResponse, error)
+	// Streaming variants of GetPuzzlePromises and GetSolutionPromises for
+	// puzzle vectors exceeding message size limits. The first message of
+	// a stream carries all fields while the following ones only continue
+	// repeated fields. Solution promises are streamed once the client
+	// closes its side of the stream.
+	StreamPuzzlePromises(*GetPuzzlePromisesRequest, TumblerService_StreamPuzzlePromisesServer) error
+	StreamSolutionPromises(TumblerService_StreamSolutionPromisesServer) error
+}
+
+func RegisterTumblerServiceServer(s *grpc.Server, srv TumblerServiceServer) {
+	s.RegisterService(&_TumblerService_serviceDesc, srv)
+}
+
+func _TumblerService_Ping_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TumblerServiceServer).Ping(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tumblerrpc.TumblerService/Ping",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TumblerServiceServer).Ping(ctx, req.(*PingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TumblerService_GetTumblerInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTumblerInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TumblerServiceServer).GetTumblerInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tumblerrpc.TumblerService/GetTumblerInfo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TumblerServiceServer).GetTumblerInfo(ctx, req.(*GetTumblerInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TumblerService_GetEpochManifest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetEpochManifestRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TumblerServiceServer).GetEpochManifest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tumblerrpc.TumblerService/GetEpochManifest",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TumblerServiceServer).GetEpochManifest(ctx, req.(*GetEpochManifestRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TumblerService_GetServerCerts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetServerCertsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TumblerServiceServer).GetServerCerts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tumblerrpc.TumblerService/GetServerCerts",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TumblerServiceServer).GetServerCerts(ctx, req.(*GetServerCertsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TumblerService_SetupEscrow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetupEscrowRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TumblerServiceServer).SetupEscrow(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tumblerrpc.TumblerService/SetupEscrow",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TumblerServiceServer).SetupEscrow(ctx, req.(*SetupEscrowRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TumblerService_GetPuzzlePromises_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPuzzlePromisesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TumblerServiceServer).GetPuzzlePromises(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tumblerrpc.TumblerService/GetPuzzlePromises",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TumblerServiceServer).GetPuzzlePromises(ctx, req.(*GetPuzzlePromisesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TumblerService_FinalizeEscrow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FinalizeEscrowRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TumblerServiceServer).FinalizeEscrow(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tumblerrpc.TumblerService/FinalizeEscrow",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TumblerServiceServer).FinalizeEscrow(ctx, req.(*FinalizeEscrowRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TumblerService_GetSolutionPromises_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSolutionPromisesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TumblerServiceServer).GetSolutionPromises(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tumblerrpc.TumblerService/GetSolutionPromises",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TumblerServiceServer).GetSolutionPromises(ctx, req.(*GetSolutionPromisesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TumblerService_ValidateSolutions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ValidateSolutionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TumblerServiceServer).ValidateSolutions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tumblerrpc.TumblerService/ValidateSolutions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TumblerServiceServer).ValidateSolutions(ctx, req.(*ValidateSolutionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TumblerService_PaymentOffer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PaymentOfferRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TumblerServiceServer).PaymentOffer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tumblerrpc.TumblerService/PaymentOffer",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TumblerServiceServer).PaymentOffer(ctx, req.(*PaymentOfferRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TumblerService_ResumeSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResumeSessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TumblerServiceServer).ResumeSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tumblerrpc.TumblerService/ResumeSession",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TumblerServiceServer).ResumeSession(ctx, req.(*ResumeSessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
 
-type TumblerServiceServer interface {
-	// Queries
-	Ping(context.Context, *PingRequest) (*PingResponse, error)
-	// Exchange between Tumbler and payees
-	SetupEscrow(context.Context, *SetupEscrowRequest) (*SetupEscrowResponse, error)
-	GetPuzzlePromises(context.Context, *GetPuzzlePromisesRequest) (*GetPuzzlePromisesResponse, error)
-	FinalizeEscrow(context.Context, *FinalizeEscrowRequest) (*FinalizeEscrowResponse, error)
-	// Exchange between Tumbler and payers
-	GetSolutionPromises(context.Context, *GetSolutionPromisesRequest) (*GetSolutionPromisesResponse, error)
-	ValidateSolutions(context.Context, *ValidateSolutionsRequest) (*ValidateSolutionsResponse, error)
-	PaymentOffer(context.Context, *PaymentOfferRequest) (*PaymentOfferResponse, error)
+func _TumblerService_StreamPuzzlePromises_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetPuzzlePromisesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TumblerServiceServer).StreamPuzzlePromises(m, &tumblerServiceStreamPuzzlePromisesServer{stream})
 }
 
-func RegisterTumblerServiceServer(s *grpc.Server, srv TumblerServiceServer) {
-	s.RegisterService(&_TumblerService_serviceDesc, srv)
+type TumblerService_StreamPuzzlePromisesServer interface {
+	Send(*GetPuzzlePromisesResponse) error
+	grpc.ServerStream
 }
 
-func _TumblerService_Ping_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(PingRequest)
+type tumblerServiceStreamPuzzlePromisesServer struct {
+	grpc.ServerStream
+}
+
+func (x *tumblerServiceStreamPuzzlePromisesServer) Send(m *GetPuzzlePromisesResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TumblerService_StreamSolutionPromises_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TumblerServiceServer).StreamSolutionPromises(&tumblerServiceStreamSolutionPromisesServer{stream})
+}
+
+type TumblerService_StreamSolutionPromisesServer interface {
+	Send(*GetSolutionPromisesResponse) error
+	Recv() (*GetSolutionPromisesRequest, error)
+	grpc.ServerStream
+}
+
+type tumblerServiceStreamSolutionPromisesServer struct {
+	grpc.ServerStream
+}
+
+func (x *tumblerServiceStreamSolutionPromisesServer) Send(m *GetSolutionPromisesResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *tumblerServiceStreamSolutionPromisesServer) Recv() (*GetSolutionPromisesRequest, error) {
+	m := new(GetSolutionPromisesRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+var _TumblerService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "tumblerrpc.TumblerService",
+	HandlerType: (*TumblerServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Ping",
+			Handler:    _TumblerService_Ping_Handler,
+		},
+		{
+			MethodName: "GetTumblerInfo",
+			Handler:    _TumblerService_GetTumblerInfo_Handler,
+		},
+		{
+			MethodName: "GetEpochManifest",
+			Handler:    _TumblerService_GetEpochManifest_Handler,
+		},
+		{
+			MethodName: "GetServerCerts",
+			Handler:    _TumblerService_GetServerCerts_Handler,
+		},
+		{
+			MethodName: "SetupEscrow",
+			Handler:    _TumblerService_SetupEscrow_Handler,
+		},
+		{
+			MethodName: "GetPuzzlePromises",
+			Handler:    _TumblerService_GetPuzzlePromises_Handler,
+		},
+		{
+			MethodName: "FinalizeEscrow",
+			Handler:    _TumblerService_FinalizeEscrow_Handler,
+		},
+		{
+			MethodName: "GetSolutionPromises",
+			Handler:    _TumblerService_GetSolutionPromises_Handler,
+		},
+		{
+			MethodName: "ValidateSolutions",
+			Handler:    _TumblerService_ValidateSolutions_Handler,
+		},
+		{
+			MethodName: "PaymentOffer",
+			Handler:    _TumblerService_PaymentOffer_Handler,
+		},
+		{
+			MethodName: "ResumeSession",
+			Handler:    _TumblerService_ResumeSession_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamPuzzlePromises",
+			Handler:       _TumblerService_StreamPuzzlePromises_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamSolutionPromises",
+			Handler:       _TumblerService_StreamSolutionPromises_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "api.proto",
+}
+
+// Client API for AdminService service
+
+type AdminServiceClient interface {
+	RotateEpoch(ctx context.Context, in *RotateEpochRequest, opts ...grpc.CallOption) (*RotateEpochResponse, error)
+	ListAlerts(ctx context.Context, in *ListAlertsRequest, opts ...grpc.CallOption) (*ListAlertsResponse, error)
+	GetSessionHistory(ctx context.Context, in *GetSessionHistoryRequest, opts ...grpc.CallOption) (*GetSessionHistoryResponse, error)
+	GetAccounting(ctx context.Context, in *GetAccountingRequest, opts ...grpc.CallOption) (*GetAccountingResponse, error)
+	GetLogLevels(ctx context.Context, in *GetLogLevelsRequest, opts ...grpc.CallOption) (*GetLogLevelsResponse, error)
+	SetLogLevel(ctx context.Context, in *SetLogLevelRequest, opts ...grpc.CallOption) (*SetLogLevelResponse, error)
+	RotateTLSCert(ctx context.Context, in *RotateTLSCertRequest, opts ...grpc.CallOption) (*RotateTLSCertResponse, error)
+	CaptureCPUProfile(ctx context.Context, in *CaptureCPUProfileRequest, opts ...grpc.CallOption) (*CaptureCPUProfileResponse, error)
+	ListSessions(ctx context.Context, in *ListSessionsRequest, opts ...grpc.CallOption) (*ListSessionsResponse, error)
+	KillSession(ctx context.Context, in *KillSessionRequest, opts ...grpc.CallOption) (*KillSessionResponse, error)
+}
+
+type adminServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewAdminServiceClient(cc *grpc.ClientConn) AdminServiceClient {
+	return &adminServiceClient{cc}
+}
+
+func (c *adminServiceClient) RotateEpoch(ctx context.Context, in *RotateEpochRequest, opts ...grpc.CallOption) (*RotateEpochResponse, error) {
+	out := new(RotateEpochResponse)
+	err := grpc.Invoke(ctx, "/tumblerrpc.AdminService/RotateEpoch", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) ListAlerts(ctx context.Context, in *ListAlertsRequest, opts ...grpc.CallOption) (*ListAlertsResponse, error) {
+	out := new(ListAlertsResponse)
+	err := grpc.Invoke(ctx, "/tumblerrpc.AdminService/ListAlerts", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) GetSessionHistory(ctx context.Context, in *GetSessionHistoryRequest, opts ...grpc.CallOption) (*GetSessionHistoryResponse, error) {
+	out := new(GetSessionHistoryResponse)
+	err := grpc.Invoke(ctx, "/tumblerrpc.AdminService/GetSessionHistory", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) GetAccounting(ctx context.Context, in *GetAccountingRequest, opts ...grpc.CallOption) (*GetAccountingResponse, error) {
+	out := new(GetAccountingResponse)
+	err := grpc.Invoke(ctx, "/tumblerrpc.AdminService/GetAccounting", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) GetLogLevels(ctx context.Context, in *GetLogLevelsRequest, opts ...grpc.CallOption) (*GetLogLevelsResponse, error) {
+	out := new(GetLogLevelsResponse)
+	err := grpc.Invoke(ctx, "/tumblerrpc.AdminService/GetLogLevels", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) SetLogLevel(ctx context.Context, in *SetLogLevelRequest, opts ...grpc.CallOption) (*SetLogLevelResponse, error) {
+	out := new(SetLogLevelResponse)
+	err := grpc.Invoke(ctx, "/tumblerrpc.AdminService/SetLogLevel", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) RotateTLSCert(ctx context.Context, in *RotateTLSCertRequest, opts ...grpc.CallOption) (*RotateTLSCertResponse, error) {
+	out := new(RotateTLSCertResponse)
+	err := grpc.Invoke(ctx, "/tumblerrpc.AdminService/RotateTLSCert", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) CaptureCPUProfile(ctx context.Context, in *CaptureCPUProfileRequest, opts ...grpc.CallOption) (*CaptureCPUProfileResponse, error) {
+	out := new(CaptureCPUProfileResponse)
+	err := grpc.Invoke(ctx, "/tumblerrpc.AdminService/CaptureCPUProfile", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) ListSessions(ctx context.Context, in *ListSessionsRequest, opts ...grpc.CallOption) (*ListSessionsResponse, error) {
+	out := new(ListSessionsResponse)
+	err := grpc.Invoke(ctx, "/tumblerrpc.AdminService/ListSessions", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) KillSession(ctx context.Context, in *KillSessionRequest, opts ...grpc.CallOption) (*KillSessionResponse, error) {
+	out := new(KillSessionResponse)
+	err := grpc.Invoke(ctx, "/tumblerrpc.AdminService/KillSession", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for AdminService service
+
+type AdminServiceServer interface {
+	RotateEpoch(context.Context, *RotateEpochRequest) (*RotateEpochResponse, error)
+	ListAlerts(context.Context, *ListAlertsRequest) (*ListAlertsResponse, error)
+	GetSessionHistory(context.Context, *GetSessionHistoryRequest) (*GetSessionHistoryResponse, error)
+	GetAccounting(context.Context, *GetAccountingRequest) (*GetAccountingResponse, error)
+	GetLogLevels(context.Context, *GetLogLevelsRequest) (*GetLogLevelsResponse, error)
+	SetLogLevel(context.Context, *SetLogLevelRequest) (*SetLogLevelResponse, error)
+	RotateTLSCert(context.Context, *RotateTLSCertRequest) (*RotateTLSCertResponse, error)
+	CaptureCPUProfile(context.Context, *CaptureCPUProfileRequest) (*CaptureCPUProfileResponse, error)
+	ListSessions(context.Context, *ListSessionsRequest) (*ListSessionsResponse, error)
+	KillSession(context.Context, *KillSessionRequest) (*KillSessionResponse, error)
+}
+
+func RegisterAdminServiceServer(s *grpc.Server, srv AdminServiceServer) {
+	s.RegisterService(&_AdminService_serviceDesc, srv)
+}
+
+func _AdminService_RotateEpoch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RotateEpochRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(TumblerServiceServer).Ping(ctx, in)
+		return srv.(AdminServiceServer).RotateEpoch(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/tumblerrpc.TumblerService/Ping",
+		FullMethod: "/tumblerrpc.AdminService/RotateEpoch",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(TumblerServiceServer).Ping(ctx, req.(*PingRequest))
+		return srv.(AdminServiceServer).RotateEpoch(ctx, req.(*RotateEpochRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _TumblerService_SetupEscrow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(SetupEscrowRequest)
+func _AdminService_ListAlerts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAlertsRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(TumblerServiceServer).SetupEscrow(ctx, in)
+		return srv.(AdminServiceServer).ListAlerts(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/tumblerrpc.TumblerService/SetupEscrow",
+		FullMethod: "/tumblerrpc.AdminService/ListAlerts",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(TumblerServiceServer).SetupEscrow(ctx, req.(*SetupEscrowRequest))
+		return srv.(AdminServiceServer).ListAlerts(ctx, req.(*ListAlertsRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _TumblerService_GetPuzzlePromises_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetPuzzlePromisesRequest)
+func _AdminService_GetSessionHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSessionHistoryRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(TumblerServiceServer).GetPuzzlePromises(ctx, in)
+		return srv.(AdminServiceServer).GetSessionHistory(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/tumblerrpc.TumblerService/GetPuzzlePromises",
+		FullMethod: "/tumblerrpc.AdminService/GetSessionHistory",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(TumblerServiceServer).GetPuzzlePromises(ctx, req.(*GetPuzzlePromisesRequest))
+		return srv.(AdminServiceServer).GetSessionHistory(ctx, req.(*GetSessionHistoryRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _TumblerService_FinalizeEscrow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(FinalizeEscrowRequest)
+func _AdminService_GetAccounting_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAccountingRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(TumblerServiceServer).FinalizeEscrow(ctx, in)
+		return srv.(AdminServiceServer).GetAccounting(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/tumblerrpc.TumblerService/FinalizeEscrow",
+		FullMethod: "/tumblerrpc.AdminService/GetAccounting",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(TumblerServiceServer).FinalizeEscrow(ctx, req.(*FinalizeEscrowRequest))
+		return srv.(AdminServiceServer).GetAccounting(ctx, req.(*GetAccountingRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _TumblerService_GetSolutionPromises_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetSolutionPromisesRequest)
+func _AdminService_GetLogLevels_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLogLevelsRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(TumblerServiceServer).GetSolutionPromises(ctx, in)
+		return srv.(AdminServiceServer).GetLogLevels(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/tumblerrpc.TumblerService/GetSolutionPromises",
+		FullMethod: "/tumblerrpc.AdminService/GetLogLevels",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(TumblerServiceServer).GetSolutionPromises(ctx, req.(*GetSolutionPromisesRequest))
+		return srv.(AdminServiceServer).GetLogLevels(ctx, req.(*GetLogLevelsRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _TumblerService_ValidateSolutions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(ValidateSolutionsRequest)
+func _AdminService_SetLogLevel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetLogLevelRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(TumblerServiceServer).ValidateSolutions(ctx, in)
+		return srv.(AdminServiceServer).SetLogLevel(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/tumblerrpc.TumblerService/ValidateSolutions",
+		FullMethod: "/tumblerrpc.AdminService/SetLogLevel",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(TumblerServiceServer).ValidateSolutions(ctx, req.(*ValidateSolutionsRequest))
+		return srv.(AdminServiceServer).SetLogLevel(ctx, req.(*SetLogLevelRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _TumblerService_PaymentOffer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(PaymentOfferRequest)
+func _AdminService_RotateTLSCert_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RotateTLSCertRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(TumblerServiceServer).PaymentOffer(ctx, in)
+		return srv.(AdminServiceServer).RotateTLSCert(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/tumblerrpc.TumblerService/PaymentOffer",
+		FullMethod: "/tumblerrpc.AdminService/RotateTLSCert",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(TumblerServiceServer).PaymentOffer(ctx, req.(*PaymentOfferRequest))
+		return srv.(AdminServiceServer).RotateTLSCert(ctx, req.(*RotateTLSCertRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-var _TumblerService_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "tumblerrpc.TumblerService",
-	HandlerType: (*TumblerServiceServer)(nil),
+func _AdminService_CaptureCPUProfile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CaptureCPUProfileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).CaptureCPUProfile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tumblerrpc.AdminService/CaptureCPUProfile",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).CaptureCPUProfile(ctx, req.(*CaptureCPUProfileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_ListSessions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSessionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).ListSessions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tumblerrpc.AdminService/ListSessions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).ListSessions(ctx, req.(*ListSessionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_KillSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KillSessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).KillSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tumblerrpc.AdminService/KillSession",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).KillSession(ctx, req.(*KillSessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _AdminService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "tumblerrpc.AdminService",
+	HandlerType: (*AdminServiceServer)(nil),
 	Methods: []grpc.MethodDesc{
 		{
-			MethodName: "Ping",
-			Handler:    _TumblerService_Ping_Handler,
+			MethodName: "RotateEpoch",
+			Handler:    _AdminService_RotateEpoch_Handler,
 		},
 		{
-			MethodName: "SetupEscrow",
-			Handler:    _TumblerService_SetupEscrow_Handler,
+			MethodName: "ListAlerts",
+			Handler:    _AdminService_ListAlerts_Handler,
 		},
 		{
-			MethodName: "GetPuzzlePromises",
-			Handler:    _TumblerService_GetPuzzlePromises_Handler,
+			MethodName: "GetSessionHistory",
+			Handler:    _AdminService_GetSessionHistory_Handler,
 		},
 		{
-			MethodName: "FinalizeEscrow",
-			Handler:    _TumblerService_FinalizeEscrow_Handler,
+			MethodName: "GetAccounting",
+			Handler:    _AdminService_GetAccounting_Handler,
 		},
 		{
-			MethodName: "GetSolutionPromises",
-			Handler:    _TumblerService_GetSolutionPromises_Handler,
+			MethodName: "GetLogLevels",
+			Handler:    _AdminService_GetLogLevels_Handler,
 		},
 		{
-			MethodName: "ValidateSolutions",
-			Handler:    _TumblerService_ValidateSolutions_Handler,
+			MethodName: "SetLogLevel",
+			Handler:    _AdminService_SetLogLevel_Handler,
 		},
 		{
-			MethodName: "PaymentOffer",
-			Handler:    _TumblerService_PaymentOffer_Handler,
+			MethodName: "RotateTLSCert",
+			Handler:    _AdminService_RotateTLSCert_Handler,
+		},
+		{
+			MethodName: "CaptureCPUProfile",
+			Handler:    _AdminService_CaptureCPUProfile_Handler,
+		},
+		{
+			MethodName: "ListSessions",
+			Handler:    _AdminService_ListSessions_Handler,
+		},
+		{
+			MethodName: "KillSession",
+			Handler:    _AdminService_KillSession_Handler,
 		},
 	},
 	Streams:  []grpc.StreamDesc{},
@@ -945,65 +3194,194 @@ var _TumblerService_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("api.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 952 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x56, 0xcb, 0x92, 0xdb, 0x44,
-	0x14, 0x2d, 0xf9, 0x19, 0x5f, 0x3f, 0x48, 0x7a, 0xc2, 0xa0, 0x68, 0x20, 0x33, 0x08, 0x06, 0x66,
-	0x93, 0x59, 0x40, 0xb1, 0x60, 0x4b, 0x15, 0x49, 0xaa, 0x78, 0x19, 0xd9, 0x15, 0x96, 0xa6, 0x47,
-	0xbe, 0xce, 0x34, 0x96, 0xd5, 0x9a, 0xee, 0x76, 0x88, 0x67, 0xcb, 0x07, 0xf0, 0x1b, 0xf0, 0x19,
-	0xf0, 0x3f, 0xac, 0xf8, 0x01, 0xaa, 0x1f, 0x1a, 0x4b, 0xb6, 0x34, 0x66, 0xe7, 0x7b, 0xee, 0x55,
-	0xdf, 0xd3, 0xe7, 0x3e, 0xda, 0xd0, 0xa3, 0x19, 0xbb, 0xcc, 0x04, 0x57, 0x9c, 0x80, 0x5a, 0xaf,
-	0xae, 0x12, 0x14, 0x22, 0x8b, 0xc3, 0x87, 0x30, 0x7a, 0x85, 0x42, 0x32, 0x9e, 0x46, 0x78, 0xb3,
-	0x46, 0xa9, 0xc2, 0xbf, 0x3c, 0x78, 0xe7, 0x0e, 0x92, 0x19, 0x4f, 0x25, 0x92, 0x73, 0x18, 0xbd,
-	0xb1, 0xd0, 0x4c, 0x2a, 0xc1, 0xd2, 0xd7, 0xbe, 0x77, 0xe6, 0x5d, 0xf4, 0xa2, 0xa1, 0x43, 0x27,
-	0x06, 0x24, 0x8f, 0xa1, 0xbd, 0xa2, 0xbf, 0x70, 0xe1, 0x37, 0xce, 0xbc, 0x8b, 0x61, 0x64, 0x0d,
-	0x83, 0xb2, 0x94, 0x0b, 0xbf, 0xe9, 0x50, 0x6d, 0x68, 0x34, 0xa3, 0x2a, 0xbe, 0xf6, 0x5b, 0x16,
-	0x35, 0x06, 0x79, 0x0a, 0x90, 0x09, 0x14, 0x98, 0x20, 0x95, 0xe8, 0xb7, 0x4d, 0x92, 0x02, 0xa2,
-	0x89, 0x5c, 0xad, 0x59, 0x32, 0x9f, 0xad, 0x50, 0xd1, 0x39, 0x55, 0xd4, 0xef, 0x58, 0x22, 0x06,
-	0xfd, 0xce, 0x81, 0xe1, 0x10, 0xfa, 0x63, 0x96, 0xbe, 0xce, 0xaf, 0x34, 0x82, 0x81, 0x35, 0xed,
-	0x75, 0x42, 0x04, 0x32, 0x41, 0xb5, 0xce, 0xbe, 0x96, 0xb1, 0xe0, 0xbf, 0xba, 0x28, 0xe2, 0x43,
-	0x97, 0xce, 0xe7, 0x02, 0xa5, 0x74, 0xb7, 0xcb, 0x4d, 0xf2, 0x01, 0x40, 0xb6, 0xbe, 0x4a, 0x58,
-	0x3c, 0x5b, 0xe2, 0xc6, 0x5c, 0xae, 0x17, 0xf5, 0x2c, 0xf2, 0x0d, 0x6e, 0xc8, 0x31, 0x74, 0xe8,
-	0x8a, 0xaf, 0x53, 0x65, 0x6e, 0xd8, 0x8c, 0x9c, 0x15, 0xfe, 0xe3, 0xc1, 0x51, 0x29, 0x8f, 0x53,
-	0xf3, 0x18, 0x3a, 0x31, 0xe7, 0x4b, 0x86, 0x26, 0xcf, 0x20, 0x72, 0x96, 0x96, 0x04, 0x33, 0x1e,
-	0x5f, 0x9b, 0x0c, 0xed, 0xc8, 0x1a, 0xe4, 0x04, 0x7a, 0x09, 0x8f, 0x97, 0x33, 0xc5, 0x56, 0x68,
-	0x12, 0xb4, 0xa3, 0x07, 0x1a, 0x98, 0xb2, 0x15, 0x16, 0x39, 0xb7, 0xee, 0xe3, 0xdc, 0xde, 0xe5,
-	0xfc, 0x11, 0x0c, 0xd1, 0xb0, 0x9a, 0xc9, 0x58, 0xb0, 0x4c, 0x19, 0x1d, 0x07, 0xd1, 0xc0, 0x82,
-	0x13, 0x83, 0x91, 0x67, 0x40, 0x5c, 0x90, 0x12, 0x34, 0x95, 0x34, 0x56, 0x8c, 0xa7, 0x7e, 0xd7,
-	0x44, 0x3e, 0xb2, 0x9e, 0xe9, 0xd6, 0x11, 0xfe, 0xe9, 0x81, 0xff, 0x02, 0xd5, 0x78, 0x7d, 0x7b,
-	0x9b, 0xe0, 0x58, 0xf0, 0x15, 0x93, 0x28, 0x73, 0x75, 0xeb, 0x2e, 0x1d, 0xc2, 0x70, 0x41, 0x97,
-	0x38, 0x93, 0xa8, 0x66, 0xd7, 0x54, 0xda, 0xcb, 0x0f, 0xa2, 0xbe, 0x06, 0x27, 0xa8, 0x5e, 0x52,
-	0x79, 0xad, 0x63, 0x04, 0xd2, 0x64, 0x1b, 0xd3, 0xb4, 0x31, 0x1a, 0xcc, 0x63, 0x9e, 0x01, 0x29,
-	0x90, 0x34, 0x61, 0xa8, 0x45, 0x69, 0x6a, 0xae, 0x05, 0xcf, 0x4b, 0xe3, 0x08, 0x7f, 0xf7, 0xe0,
-	0x49, 0x05, 0x57, 0x57, 0xa1, 0xb2, 0x78, 0x96, 0x70, 0x41, 0x3c, 0xe3, 0xd6, 0x1f, 0xde, 0xf5,
-	0x83, 0x71, 0x6b, 0x44, 0xbb, 0x7d, 0xe8, 0x5a, 0x43, 0xfa, 0x4d, 0x93, 0x3f, 0x37, 0x49, 0x00,
-	0x0f, 0x32, 0x97, 0xcb, 0x51, 0xbb, 0xb3, 0xc3, 0x3f, 0x3c, 0x78, 0xf7, 0x39, 0x4b, 0x69, 0xc2,
-	0x6e, 0xb1, 0xdc, 0x98, 0x75, 0xd2, 0x11, 0x68, 0x49, 0x9a, 0x28, 0x47, 0xc0, 0xfc, 0x26, 0x67,
-	0x30, 0x30, 0x72, 0xaa, 0xb7, 0xb3, 0x84, 0x49, 0xe5, 0x94, 0x02, 0x8d, 0x4d, 0xdf, 0x7e, 0xcb,
-	0xa4, 0x89, 0x30, 0x62, 0xe6, 0x11, 0x2d, 0x1b, 0xa1, 0x31, 0x17, 0x71, 0x0a, 0x7d, 0x41, 0xd3,
-	0x39, 0x5f, 0xcd, 0x32, 0x3a, 0x97, 0x7e, 0xdb, 0x10, 0x05, 0x0b, 0x8d, 0xe9, 0x5c, 0x86, 0x37,
-	0x70, 0xbc, 0xcb, 0xd4, 0x09, 0x77, 0x0a, 0x7d, 0xd7, 0x31, 0xa6, 0x4e, 0x96, 0x2f, 0x58, 0xc8,
-	0x94, 0xc9, 0x87, 0xae, 0xc4, 0x58, 0xa0, 0x92, 0x7e, 0xc3, 0x6a, 0xe3, 0x4c, 0xf2, 0x3e, 0xf4,
-	0x6e, 0xd6, 0x5c, 0x31, 0x4c, 0x55, 0xae, 0xdb, 0x16, 0x08, 0x17, 0x10, 0xbc, 0x40, 0x35, 0xe1,
-	0xc9, 0x5a, 0x17, 0x71, 0xb7, 0xb9, 0xea, 0x47, 0xb7, 0x7a, 0xa6, 0x6a, 0x2b, 0x14, 0x66, 0x70,
-	0x52, 0x99, 0xe7, 0xc0, 0xe8, 0x16, 0x0b, 0xdb, 0x28, 0x17, 0x56, 0x77, 0xcb, 0x12, 0x37, 0x79,
-	0x47, 0xba, 0x9b, 0x2d, 0x71, 0xe3, 0x3a, 0xf1, 0x37, 0x0f, 0xfc, 0x57, 0x34, 0x61, 0x73, 0xaa,
-	0x30, 0xcf, 0x7b, 0x70, 0x6a, 0x2e, 0xe0, 0xa1, 0x29, 0xb3, 0x6b, 0x43, 0x53, 0x48, 0xdb, 0x06,
-	0x23, 0x8d, 0xdb, 0xb6, 0x36, 0xc5, 0x3c, 0x87, 0x91, 0x2b, 0xe6, 0x82, 0xc6, 0x8a, 0x8b, 0x9c,
-	0xc1, 0xd0, 0xa2, 0xcf, 0x2d, 0x18, 0x7e, 0x01, 0x4f, 0x2a, 0x48, 0xb8, 0x5b, 0x17, 0x8a, 0xe6,
-	0x95, 0x8a, 0x16, 0xfe, 0xdd, 0x80, 0xa3, 0x31, 0xdd, 0xac, 0x30, 0x55, 0x3f, 0x2c, 0x16, 0x28,
-	0x0e, 0xf1, 0xde, 0xae, 0xca, 0x46, 0x71, 0x55, 0xee, 0x0c, 0x5c, 0x73, 0x77, 0x5b, 0xed, 0xb4,
-	0x55, 0x6b, 0xaf, 0xad, 0xf6, 0xd6, 0x59, 0xfb, 0x7f, 0xaf, 0xb3, 0x4e, 0xcd, 0x3a, 0xd3, 0x5c,
-	0xad, 0xbc, 0x6e, 0xe3, 0x39, 0x4b, 0x6b, 0x6f, 0x06, 0xa8, 0xa8, 0xfd, 0x03, 0xab, 0xbd, 0xc6,
-	0xef, 0xd5, 0xbe, 0x57, 0xa5, 0xfd, 0x31, 0x3c, 0x2e, 0x6b, 0x68, 0x65, 0xff, 0x6c, 0x7a, 0xf7,
-	0x36, 0x4f, 0x50, 0xbc, 0x61, 0x31, 0x92, 0xaf, 0xa0, 0xeb, 0x10, 0x12, 0x5c, 0x6e, 0x5f, 0xf1,
-	0xcb, 0xf2, 0x13, 0x1e, 0x9c, 0x54, 0xfa, 0xdc, 0xa9, 0xff, 0xb6, 0x60, 0x34, 0xb5, 0xee, 0xfc,
-	0xd8, 0x2f, 0xa1, 0xa5, 0xdf, 0x47, 0xf2, 0x5e, 0xf1, 0xbb, 0xc2, 0x03, 0x1a, 0xf8, 0xfb, 0x0e,
-	0xd7, 0x1a, 0xdf, 0x43, 0xbf, 0xf0, 0xc4, 0x91, 0xa7, 0xc5, 0xc0, 0xfd, 0x37, 0x36, 0x38, 0xad,
-	0xf5, 0xbb, 0xf3, 0x7e, 0x86, 0x47, 0x7b, 0x6b, 0x99, 0x7c, 0x5c, 0xfc, 0xaa, 0xee, 0x85, 0x09,
-	0xce, 0x0f, 0x44, 0xb9, 0x0c, 0x3f, 0xc1, 0xa8, 0xbc, 0xbc, 0xc8, 0x87, 0xc5, 0x0f, 0x2b, 0x57,
-	0x70, 0x10, 0xde, 0x17, 0xe2, 0x0e, 0x5e, 0xc0, 0x51, 0xc5, 0xea, 0x20, 0x9f, 0xec, 0xd0, 0xaa,
-	0xd9, 0x61, 0xc1, 0xa7, 0x07, 0xe3, 0xb6, 0x12, 0xed, 0x8d, 0x6a, 0x59, 0xa2, 0xba, 0x75, 0x52,
-	0x96, 0xa8, 0x7e, 0xde, 0x7f, 0x84, 0x41, 0xb1, 0x21, 0x49, 0xa9, 0x6a, 0x15, 0xe3, 0x1e, 0x9c,
-	0xd5, 0x07, 0xd8, 0x23, 0xaf, 0x3a, 0xe6, 0xaf, 0xe7, 0xe7, 0xff, 0x05, 0x00, 0x00, 0xff, 0xff,
-	0x83, 0x9e, 0xe9, 0xe5, 0x87, 0x0a, 0x00, 0x00,
+	// 3022 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x59, 0xbd, 0x77, 0x23, 0x57,
+	0x15, 0x8f, 0x3e, 0x6d, 0x5d, 0x49, 0x5e, 0x79, 0xac, 0xf5, 0x6a, 0xb5, 0x49, 0xd6, 0x3b, 0x61,
+	0x13, 0x27, 0x24, 0xcb, 0xb2, 0xd9, 0x14, 0x34, 0x70, 0xb4, 0xb2, 0xbc, 0xab, 0x63, 0x5b, 0x16,
+	0x23, 0x6d, 0xbe, 0x38, 0x9c, 0xc9, 0x78, 0x74, 0x65, 0x3f, 0x3c, 0x9a, 0xd1, 0xce, 0x3c, 0x39,
+	0x76, 0xe8, 0x69, 0x38, 0x07, 0x0a, 0x7a, 0xa0, 0xa2, 0xa1, 0xe1, 0x0f, 0xa0, 0x80, 0x82, 0x86,
+	0x8a, 0x96, 0x92, 0x92, 0x86, 0x82, 0x92, 0x8a, 0xf3, 0x3e, 0xe6, 0x53, 0x33, 0x32, 0xbb, 0x24,
+	0xdd, 0xbc, 0xdf, 0xbd, 0xef, 0xcd, 0xfd, 0x7e, 0xf7, 0xbd, 0x07, 0x15, 0x63, 0x4e, 0x1e, 0xcc,
+	0x5d, 0x87, 0x3a, 0x0a, 0xd0, 0xc5, 0xec, 0xc4, 0x42, 0xd7, 0x9d, 0x9b, 0x6a, 0x03, 0x36, 0x3e,
+	0x46, 0xd7, 0x23, 0x8e, 0xad, 0xe1, 0x8b, 0x05, 0x7a, 0x54, 0xfd, 0x73, 0x0e, 0x6e, 0x04, 0x90,
+	0x37, 0x77, 0x6c, 0x0f, 0x95, 0xfb, 0xb0, 0x71, 0x21, 0x20, 0xdd, 0xa3, 0x2e, 0xb1, 0x4f, 0x5b,
+	0xb9, 0x9d, 0xdc, 0x6e, 0x45, 0xab, 0x4b, 0x74, 0xc4, 0x41, 0xa5, 0x09, 0xa5, 0x99, 0xf1, 0x13,
+	0xc7, 0x6d, 0xe5, 0x77, 0x72, 0xbb, 0x75, 0x4d, 0x0c, 0x38, 0x4a, 0x6c, 0xc7, 0x6d, 0x15, 0x24,
+	0x4a, 0x6c, 0x81, 0xce, 0x0d, 0x6a, 0x9e, 0xb5, 0x8a, 0x02, 0xe5, 0x03, 0xe5, 0x4d, 0x80, 0xb9,
+	0x8b, 0x2e, 0x5a, 0x68, 0x78, 0xd8, 0x2a, 0xf1, 0x9f, 0x44, 0x10, 0x26, 0xc8, 0xc9, 0x82, 0x58,
+	0x13, 0x7d, 0x86, 0xd4, 0x98, 0x18, 0xd4, 0x68, 0x95, 0x85, 0x20, 0x1c, 0x3d, 0x92, 0xa0, 0xfa,
+	0xb3, 0x1c, 0x34, 0x9e, 0x19, 0xf6, 0xc4, 0x3b, 0x33, 0xce, 0x51, 0x2a, 0xa6, 0xbc, 0x0b, 0x0d,
+	0xae, 0xbf, 0xe9, 0x58, 0xba, 0x94, 0x9b, 0xab, 0x51, 0xd7, 0x6e, 0xf8, 0xb8, 0xd4, 0x5b, 0x69,
+	0xc3, 0xfa, 0x14, 0x0d, 0xba, 0x70, 0xd1, 0x6b, 0xe5, 0x77, 0x0a, 0xbb, 0x15, 0x2d, 0x18, 0x2b,
+	0xdf, 0x86, 0x4d, 0x17, 0x5f, 0x2c, 0x88, 0x8b, 0x13, 0x3d, 0x60, 0x2a, 0x70, 0xa6, 0x86, 0x4f,
+	0xd8, 0x97, 0xb8, 0xfa, 0x39, 0x6c, 0x46, 0xe4, 0x90, 0xd6, 0xfc, 0x7a, 0x04, 0x51, 0xeb, 0x50,
+	0x1d, 0x12, 0xfb, 0xd4, 0xf7, 0xdb, 0x06, 0xd4, 0xc4, 0x50, 0xfc, 0x45, 0xbd, 0x05, 0x37, 0x9f,
+	0x22, 0x1d, 0x0b, 0x57, 0xf7, 0xed, 0xa9, 0xe3, 0x33, 0xfe, 0xb3, 0x04, 0xdb, 0x49, 0x8a, 0x94,
+	0xac, 0x09, 0x25, 0x9c, 0x3b, 0xe6, 0x19, 0x17, 0xa7, 0xa4, 0x89, 0x81, 0xf2, 0x06, 0x80, 0x8d,
+	0x97, 0x54, 0x17, 0xa4, 0x3c, 0x27, 0x55, 0x18, 0xd2, 0xe3, 0xe4, 0x3b, 0x50, 0xb1, 0x1c, 0xf3,
+	0x5c, 0xa7, 0x64, 0x86, 0xdc, 0xc7, 0x25, 0x6d, 0x9d, 0x01, 0x63, 0x32, 0x43, 0x45, 0x85, 0xda,
+	0x04, 0x6d, 0x67, 0x46, 0x6c, 0x83, 0x32, 0x3d, 0x99, 0xb7, 0x0b, 0x5a, 0x0c, 0x53, 0xde, 0x86,
+	0x1b, 0xf3, 0xc5, 0x57, 0x5f, 0x59, 0xa8, 0x9f, 0xe3, 0x95, 0x7e, 0x66, 0x78, 0x67, 0xdc, 0xf3,
+	0x35, 0xad, 0x2e, 0xe0, 0x03, 0xbc, 0x7a, 0x66, 0x78, 0x67, 0xcc, 0xf2, 0x92, 0x6f, 0x42, 0xa6,
+	0x53, 0x62, 0x2e, 0x2c, 0x7a, 0xc5, 0xfd, 0x5f, 0xd2, 0x1a, 0x82, 0xb0, 0x17, 0xe0, 0xca, 0xeb,
+	0x00, 0x53, 0x44, 0x7d, 0x8e, 0xae, 0x7e, 0x7e, 0xd2, 0x5a, 0xe3, 0xbf, 0x5d, 0x9f, 0x22, 0x0e,
+	0xd1, 0x3d, 0x38, 0x61, 0x71, 0xc4, 0xb5, 0xd1, 0x27, 0x0b, 0x57, 0x08, 0xb6, 0xce, 0xd7, 0xa9,
+	0x73, 0x74, 0x4f, 0x82, 0xca, 0x5b, 0x20, 0x00, 0xdd, 0x45, 0x1b, 0xbf, 0x34, 0xac, 0x56, 0x85,
+	0x73, 0xd5, 0x38, 0xa8, 0x09, 0x4c, 0x79, 0x0c, 0xdb, 0x2e, 0x1a, 0x96, 0x4e, 0x5d, 0xc3, 0xf6,
+	0x0c, 0x93, 0x4d, 0xd4, 0x4d, 0x67, 0x61, 0xd3, 0x16, 0x70, 0xee, 0x26, 0xa3, 0x8e, 0x43, 0x62,
+	0x97, 0xd1, 0xd8, 0xac, 0xa9, 0x71, 0x8e, 0x29, 0xb3, 0xaa, 0x62, 0x16, 0xa3, 0x2e, 0xcd, 0x7a,
+	0x00, 0x5b, 0xfc, 0x5f, 0x73, 0x17, 0xc9, 0xcc, 0x38, 0x45, 0x39, 0xa5, 0xc6, 0xa7, 0x6c, 0x32,
+	0xd2, 0x50, 0x52, 0x02, 0x7e, 0xfe, 0x97, 0x04, 0x7f, 0x5d, 0xf0, 0x33, 0x52, 0x9c, 0xff, 0x2d,
+	0x90, 0x36, 0xd7, 0x3d, 0xf3, 0x0c, 0x67, 0xd8, 0xda, 0xe0, 0xe9, 0x55, 0x13, 0xe0, 0x88, 0x63,
+	0x4a, 0x03, 0x0a, 0x53, 0xc4, 0xd6, 0x0d, 0x6e, 0x53, 0xf6, 0xa9, 0xbc, 0x0f, 0x8a, 0x8b, 0x96,
+	0x41, 0xc9, 0x05, 0xea, 0x61, 0x2c, 0x34, 0x76, 0x72, 0xbb, 0xeb, 0x5a, 0xc3, 0xa7, 0x1c, 0xfa,
+	0x31, 0xf1, 0x4e, 0xe0, 0x6f, 0x0f, 0xcd, 0x85, 0x4b, 0xe8, 0x55, 0x6b, 0x93, 0x0b, 0xb4, 0x21,
+	0x7f, 0x23, 0xd1, 0x88, 0x34, 0x73, 0x97, 0xcc, 0xd0, 0x6b, 0x29, 0xc2, 0xfc, 0x02, 0x1c, 0x72,
+	0x8c, 0x85, 0xdf, 0x97, 0x8e, 0x7b, 0xae, 0x9f, 0x10, 0xea, 0xb5, 0xb6, 0x44, 0xf8, 0x31, 0xe0,
+	0x09, 0xa1, 0x9e, 0xfa, 0x1d, 0xb8, 0xf5, 0x14, 0x45, 0x9c, 0x1e, 0x19, 0x36, 0x99, 0xa2, 0x47,
+	0xfd, 0x72, 0x90, 0x1a, 0xeb, 0xea, 0xcf, 0xf3, 0xd0, 0x5a, 0x9e, 0x21, 0xd3, 0xa3, 0x05, 0x6b,
+	0xf1, 0x7c, 0xf5, 0x87, 0x8c, 0x62, 0x23, 0x65, 0xbf, 0xe5, 0xf9, 0x51, 0xd1, 0xfc, 0x61, 0xf8,
+	0x9b, 0x42, 0x34, 0xa5, 0xbe, 0xce, 0xb4, 0x68, 0xc1, 0x9a, 0x31, 0x99, 0xb8, 0xe8, 0x79, 0xb2,
+	0x18, 0xfa, 0x43, 0xe5, 0x1e, 0xd4, 0xc8, 0x04, 0x6d, 0x4a, 0xe8, 0x15, 0x5b, 0x83, 0x67, 0x41,
+	0x4d, 0xab, 0xfa, 0xd8, 0x01, 0xb2, 0x34, 0xa9, 0x78, 0xe4, 0xd4, 0xe6, 0x25, 0x85, 0xe7, 0x40,
+	0x4d, 0x0b, 0x01, 0x59, 0x43, 0x46, 0xe8, 0x5e, 0xa0, 0xdb, 0x45, 0x97, 0x7a, 0x7e, 0x0d, 0x19,
+	0xc1, 0x76, 0x92, 0x10, 0x96, 0x10, 0x93, 0x01, 0xdc, 0x42, 0x35, 0x4d, 0x0c, 0x98, 0x27, 0x5d,
+	0x87, 0x72, 0xbd, 0x44, 0x6c, 0xe4, 0x85, 0xc2, 0x3e, 0xc8, 0xe2, 0x42, 0xfd, 0x6b, 0x1e, 0x94,
+	0x11, 0xd2, 0xc5, 0xbc, 0xe7, 0x99, 0xae, 0xf3, 0xa5, 0xef, 0xa8, 0x88, 0x7e, 0xb9, 0xb8, 0x7e,
+	0x6f, 0x00, 0xcc, 0x17, 0x27, 0x16, 0x31, 0xb9, 0x76, 0xc2, 0xf0, 0x15, 0x81, 0x30, 0xdd, 0xb6,
+	0xa1, 0x6c, 0xcc, 0x78, 0xbc, 0x17, 0xf8, 0xdf, 0xe4, 0x68, 0x45, 0xc2, 0x16, 0x5f, 0x29, 0x61,
+	0x4b, 0x2b, 0x12, 0x36, 0xad, 0xd6, 0x97, 0xd3, 0x6b, 0xfd, 0x07, 0xa0, 0x48, 0xc5, 0x74, 0xd3,
+	0x99, 0xcd, 0x08, 0x9d, 0xa1, 0x4d, 0xa5, 0xcf, 0x36, 0x25, 0xa5, 0x1b, 0x10, 0x12, 0x55, 0x79,
+	0x9d, 0xe7, 0x5a, 0x58, 0x95, 0xd5, 0xbf, 0xe7, 0x61, 0x2b, 0x66, 0x4c, 0xe9, 0x9f, 0x6d, 0x28,
+	0x9b, 0x8e, 0x73, 0x4e, 0x50, 0x3a, 0x48, 0x8e, 0xc2, 0x38, 0xcd, 0x47, 0xe3, 0x74, 0x65, 0x6d,
+	0x8f, 0x38, 0xa6, 0xb8, 0xca, 0x31, 0xa5, 0xa4, 0x63, 0x58, 0x59, 0xe5, 0x52, 0xe9, 0x9e, 0xe9,
+	0x92, 0x39, 0xe5, 0x16, 0xa9, 0x69, 0x35, 0x01, 0x8e, 0x38, 0xc6, 0xcc, 0x21, 0x99, 0x22, 0x16,
+	0xf7, 0xcd, 0x21, 0x28, 0x11, 0x6b, 0xaf, 0x70, 0xea, 0xfa, 0x2b, 0x39, 0xb5, 0x92, 0xed, 0x54,
+	0xf5, 0x2f, 0x39, 0x5e, 0x24, 0x86, 0xb2, 0x0c, 0x39, 0x33, 0xe2, 0xa1, 0x9f, 0x1a, 0x99, 0x06,
+	0x56, 0xa1, 0xce, 0x7f, 0xe5, 0x21, 0x15, 0xc9, 0x9c, 0x17, 0xd9, 0xc8, 0xc0, 0x11, 0x52, 0x9e,
+	0xca, 0x2a, 0xd4, 0xb9, 0x12, 0x01, 0x4f, 0x41, 0xf0, 0x30, 0xd0, 0xe7, 0xf9, 0x00, 0x94, 0xa8,
+	0xb4, 0x8c, 0x0d, 0x99, 0x03, 0x0a, 0xcc, 0x2e, 0x11, 0xca, 0x33, 0x4e, 0x60, 0x1d, 0x84, 0xc7,
+	0x24, 0xb3, 0x4d, 0xd1, 0x4f, 0x15, 0xb5, 0x60, 0xac, 0xfe, 0x32, 0x07, 0xb7, 0x53, 0xf4, 0x90,
+	0x91, 0x12, 0x77, 0xa2, 0x50, 0x26, 0xe2, 0x44, 0x4e, 0xf6, 0xcb, 0x93, 0x54, 0xa6, 0x12, 0x54,
+	0x26, 0x16, 0x1c, 0x62, 0x20, 0x9a, 0xa3, 0x9a, 0xe6, 0x0f, 0x99, 0x44, 0x73, 0xf9, 0x2f, 0x29,
+	0x76, 0x30, 0x56, 0x7f, 0x91, 0x87, 0x9b, 0xfb, 0xc4, 0x36, 0x2c, 0xf2, 0x15, 0xc6, 0xab, 0x40,
+	0x96, 0x59, 0x15, 0x28, 0x7a, 0x86, 0x45, 0xa5, 0x00, 0xfc, 0x5b, 0xd9, 0x81, 0x9a, 0xf0, 0xea,
+	0xa5, 0x6e, 0x11, 0x8f, 0x4a, 0x2b, 0x02, 0xf7, 0xe5, 0xe5, 0x21, 0xf1, 0x38, 0x87, 0x88, 0x16,
+	0xc9, 0x51, 0x14, 0x1c, 0x3c, 0x46, 0x04, 0xc7, 0x5d, 0xa8, 0xba, 0x86, 0x3d, 0x71, 0x66, 0xfa,
+	0xdc, 0x98, 0x78, 0xad, 0x12, 0x17, 0x14, 0x04, 0x34, 0x34, 0x26, 0x71, 0xc3, 0x96, 0xe3, 0x86,
+	0x65, 0xed, 0xc5, 0xdc, 0xb8, 0x72, 0x16, 0x54, 0xf7, 0x13, 0x64, 0x4d, 0xb4, 0xa9, 0x02, 0xed,
+	0x84, 0xf5, 0x59, 0xb2, 0xd9, 0x0e, 0x5b, 0x46, 0xd4, 0xdf, 0xaa, 0xc0, 0x06, 0x0c, 0x52, 0x5f,
+	0xc0, 0x76, 0xd2, 0x1e, 0xd2, 0x3d, 0x77, 0xa1, 0x2a, 0xf3, 0x83, 0x47, 0x8a, 0xb0, 0x0a, 0x08,
+	0xc8, 0xdf, 0x17, 0x3c, 0x34, 0x5d, 0xa4, 0xa2, 0x75, 0xac, 0x69, 0xfe, 0x90, 0x15, 0xfd, 0x17,
+	0x0b, 0x87, 0x12, 0xb4, 0xa9, 0xef, 0x9d, 0x10, 0x50, 0x7f, 0x9f, 0x87, 0x36, 0x2b, 0xee, 0x8e,
+	0xb5, 0x60, 0x71, 0x94, 0x8c, 0xef, 0xec, 0x72, 0x9c, 0x5e, 0x42, 0xb2, 0x03, 0x21, 0x74, 0x69,
+	0x31, 0xe6, 0xd2, 0x8c, 0x26, 0xa7, 0xf4, 0x92, 0x4d, 0x4e, 0x39, 0xab, 0xc9, 0x89, 0x7a, 0x6e,
+	0x2d, 0xe1, 0xb9, 0x3b, 0x50, 0x61, 0xe6, 0xe4, 0x5d, 0x0c, 0xf7, 0x47, 0x5d, 0x5b, 0x67, 0x00,
+	0x6b, 0x5e, 0x58, 0xac, 0xf1, 0x2d, 0xbe, 0x22, 0x62, 0x8d, 0x7d, 0xab, 0x7f, 0xcb, 0xc1, 0x9d,
+	0x54, 0x6b, 0x5d, 0x53, 0x6f, 0xa3, 0x59, 0x90, 0x8f, 0x67, 0x01, 0x4b, 0x2d, 0x7f, 0xcb, 0x0f,
+	0xac, 0x56, 0x39, 0x17, 0xdb, 0x3d, 0x7a, 0x59, 0xf6, 0x29, 0xbe, 0xa4, 0x7d, 0x4a, 0x19, 0xf6,
+	0x51, 0x7f, 0x93, 0x83, 0xd6, 0xc7, 0x86, 0x45, 0x26, 0x06, 0x45, 0x5f, 0xaf, 0x6b, 0xcb, 0xdb,
+	0x2e, 0x34, 0xc4, 0x4f, 0x44, 0x4d, 0xe0, 0x59, 0x25, 0x72, 0x72, 0x83, 0xff, 0x81, 0xc3, 0x3c,
+	0xb3, 0xee, 0xc3, 0x86, 0xcc, 0xac, 0xa9, 0x61, 0x52, 0xc7, 0xf5, 0x35, 0xac, 0x0b, 0x74, 0x5f,
+	0x80, 0x31, 0x2f, 0x15, 0x13, 0x85, 0xeb, 0x23, 0xb8, 0x9d, 0x22, 0x60, 0xd8, 0xa5, 0xf9, 0x71,
+	0x9f, 0x8b, 0xc5, 0xbd, 0xfa, 0x9f, 0x3c, 0x6c, 0x0d, 0x8d, 0x2b, 0xb6, 0x7d, 0x1e, 0x4f, 0xa7,
+	0xe8, 0x5e, 0xa7, 0x53, 0xd8, 0x40, 0xe4, 0x63, 0x0d, 0x44, 0xbc, 0x32, 0x16, 0x92, 0xdb, 0x5b,
+	0x22, 0x33, 0x8b, 0x4b, 0x99, 0xb9, 0xb4, 0xff, 0x95, 0xfe, 0xe7, 0xfd, 0xaf, 0x9c, 0xb5, 0xff,
+	0x6d, 0x43, 0x59, 0x98, 0x5e, 0x6e, 0x91, 0x72, 0xc4, 0xfc, 0x22, 0x82, 0x25, 0xe2, 0x17, 0x51,
+	0x67, 0x36, 0x78, 0xa4, 0xac, 0xf2, 0x4b, 0x25, 0xc3, 0x2f, 0xa6, 0x31, 0x37, 0x4c, 0xd6, 0xb6,
+	0x83, 0x38, 0x56, 0xf9, 0xe3, 0x98, 0xcf, 0xaa, 0x09, 0x9f, 0x3d, 0x84, 0x66, 0xdc, 0xf6, 0xd7,
+	0xba, 0xeb, 0x01, 0x34, 0x35, 0xf4, 0x16, 0x33, 0x1c, 0xa1, 0x17, 0xb9, 0xa1, 0xc8, 0x72, 0x97,
+	0xfa, 0xbb, 0x1c, 0xdc, 0x4c, 0x4c, 0x08, 0x9b, 0x52, 0x8f, 0x1a, 0x14, 0x65, 0xc5, 0x12, 0x83,
+	0xec, 0x7a, 0x85, 0x97, 0x73, 0x22, 0x4e, 0xf5, 0x4c, 0x3d, 0x7f, 0xc8, 0xce, 0x9f, 0xe6, 0x99,
+	0x61, 0xdb, 0x68, 0xe9, 0x2e, 0xce, 0x0c, 0x62, 0xb3, 0x8b, 0x10, 0xd1, 0xb9, 0x37, 0x24, 0x41,
+	0xf3, 0xf1, 0x95, 0xfb, 0x6e, 0x13, 0x14, 0xcd, 0x61, 0x22, 0xf4, 0xc4, 0x39, 0xd2, 0xef, 0xa9,
+	0xb7, 0x62, 0xe8, 0xca, 0x33, 0x79, 0xca, 0xe1, 0x20, 0x9f, 0x72, 0x38, 0x50, 0x7f, 0x9b, 0x83,
+	0x52, 0xc7, 0x42, 0x97, 0xb2, 0xe2, 0xc5, 0xbb, 0xb8, 0x1c, 0x17, 0x98, 0x7f, 0x0b, 0xdb, 0x73,
+	0x53, 0xf9, 0xc7, 0x16, 0x39, 0x8c, 0x56, 0xf9, 0x42, 0x46, 0x95, 0x2f, 0x46, 0xe5, 0x49, 0xc4,
+	0xbc, 0xbc, 0xb9, 0x89, 0xef, 0x46, 0x33, 0xf4, 0x3c, 0xe3, 0x14, 0xfd, 0x53, 0x8a, 0x1c, 0xaa,
+	0x5b, 0xb0, 0xc9, 0xe2, 0x8f, 0x4b, 0x19, 0x1c, 0x30, 0x7e, 0x00, 0x4a, 0x14, 0x0c, 0x6e, 0x4e,
+	0xca, 0x86, 0x25, 0x4f, 0x17, 0x85, 0xdd, 0xea, 0xa3, 0xcd, 0x07, 0xe1, 0x55, 0xd6, 0x03, 0xce,
+	0xab, 0x49, 0x06, 0xf5, 0x5f, 0x39, 0xa8, 0xc9, 0x30, 0xe8, 0x5d, 0xa0, 0x9d, 0xae, 0x7f, 0x13,
+	0x4a, 0x16, 0x5e, 0xa0, 0x25, 0xb5, 0x17, 0x83, 0x97, 0xd6, 0x3d, 0x88, 0xae, 0x52, 0x34, 0xba,
+	0x12, 0x16, 0x29, 0x2f, 0x59, 0x84, 0x75, 0x18, 0x38, 0x41, 0x9c, 0x09, 0x06, 0xd1, 0x21, 0x80,
+	0x80, 0x38, 0xc3, 0x36, 0x94, 0x5d, 0x34, 0x3c, 0x79, 0x39, 0x51, 0xd1, 0xe4, 0x88, 0x4b, 0xe1,
+	0xba, 0x8e, 0xcb, 0xf7, 0xa1, 0x8a, 0x26, 0x06, 0xea, 0x63, 0xde, 0x93, 0x4a, 0x95, 0x9f, 0x11,
+	0x8f, 0x3a, 0xee, 0x55, 0x64, 0xcf, 0xf6, 0xfd, 0x9c, 0x8b, 0xf9, 0x59, 0x3d, 0x82, 0xdb, 0x29,
+	0xb3, 0xa4, 0xb9, 0x1f, 0x42, 0x19, 0x2f, 0xd0, 0x0e, 0xcc, 0xdd, 0x8a, 0x9a, 0x3b, 0x6a, 0x5c,
+	0x4d, 0xf2, 0xa9, 0x7f, 0xca, 0x41, 0x55, 0x12, 0xd8, 0xc5, 0x52, 0xf6, 0x8f, 0xa3, 0x46, 0xce,
+	0x67, 0x18, 0xb9, 0x90, 0x6a, 0xe4, 0x62, 0xd4, 0xc8, 0x91, 0x64, 0x2d, 0xc5, 0x93, 0xf5, 0x5a,
+	0xf3, 0x87, 0xc5, 0x7d, 0x2d, 0x5a, 0xdc, 0xd5, 0x1f, 0xc1, 0x16, 0x8b, 0x3c, 0xa9, 0x85, 0x17,
+	0xb9, 0x2e, 0x78, 0x99, 0x12, 0x92, 0x1e, 0x40, 0xea, 0x01, 0x34, 0xe3, 0x8b, 0x4b, 0x4b, 0x7f,
+	0xc8, 0xaa, 0x85, 0xc0, 0xa4, 0xad, 0x6f, 0xa5, 0xd8, 0x9a, 0xdf, 0xd5, 0x05, 0x8c, 0xea, 0x3e,
+	0x28, 0x07, 0xc4, 0xb2, 0x12, 0xd5, 0x31, 0xdb, 0xe4, 0x61, 0x3c, 0xe5, 0xa3, 0xf1, 0xa4, 0xde,
+	0x84, 0xad, 0xd8, 0x3a, 0xf2, 0x02, 0xf1, 0xdf, 0x39, 0xa8, 0xf1, 0x52, 0xd4, 0x31, 0x79, 0xc3,
+	0x90, 0x51, 0x89, 0x98, 0x0b, 0xb8, 0x55, 0x3d, 0x69, 0x04, 0x7f, 0xc8, 0xda, 0x4c, 0xd3, 0x99,
+	0xcd, 0x2d, 0xa4, 0x38, 0x91, 0xce, 0x0c, 0x01, 0x26, 0xcd, 0xd4, 0x20, 0x16, 0x4e, 0x64, 0x32,
+	0xc9, 0x51, 0xe8, 0x38, 0x9c, 0xe8, 0xc4, 0x96, 0x6e, 0x05, 0x1f, 0xea, 0xdb, 0xac, 0x6b, 0x0e,
+	0x18, 0x9c, 0x85, 0xe8, 0xf3, 0x0a, 0x5a, 0x30, 0xe9, 0x78, 0xc1, 0x9b, 0xf7, 0x29, 0xa2, 0xa7,
+	0xa3, 0xe1, 0xda, 0x38, 0x91, 0x0e, 0x06, 0x06, 0xf5, 0x38, 0xa2, 0xdc, 0x82, 0x35, 0x7a, 0xa9,
+	0x33, 0x80, 0xe7, 0x56, 0x41, 0x2b, 0xd3, 0xcb, 0x7d, 0x44, 0x4f, 0xdd, 0x85, 0xe6, 0x53, 0xa4,
+	0x52, 0xe3, 0xf0, 0x76, 0x95, 0xdd, 0x79, 0x99, 0xde, 0x05, 0xd7, 0x7c, 0x5d, 0x63, 0x9f, 0xaa,
+	0x0e, 0x37, 0x13, 0x9c, 0xd2, 0x97, 0x8f, 0x61, 0xdd, 0x10, 0x68, 0x6a, 0xde, 0x44, 0x4d, 0xaa,
+	0x05, 0x9c, 0xfe, 0x0f, 0x44, 0x11, 0xe7, 0x3f, 0xf8, 0x3e, 0xac, 0x1f, 0x3a, 0xa7, 0x87, 0xbc,
+	0x24, 0xb1, 0x6b, 0x9a, 0xc5, 0x89, 0x77, 0xe5, 0x51, 0x9c, 0x49, 0xb7, 0x86, 0x40, 0x7a, 0x19,
+	0x63, 0x6e, 0x7d, 0x8a, 0xd4, 0x5f, 0x22, 0xa8, 0xac, 0x7b, 0xd0, 0x8c, 0xc3, 0x52, 0xec, 0xf7,
+	0xa1, 0xcc, 0xe7, 0xf9, 0x42, 0x37, 0xa3, 0x42, 0xfb, 0xec, 0x9a, 0xe4, 0x51, 0x9f, 0xf1, 0xab,
+	0x9a, 0x00, 0x96, 0x56, 0x7a, 0x15, 0x31, 0xbb, 0xb0, 0x15, 0x5b, 0xe9, 0x95, 0xc4, 0xd9, 0x86,
+	0xa6, 0xd8, 0x3b, 0xc7, 0x87, 0x23, 0x76, 0x1f, 0xe5, 0x2b, 0xab, 0xc1, 0xcd, 0x04, 0xfe, 0xff,
+	0x5f, 0x53, 0x3d, 0x86, 0x56, 0xd7, 0x98, 0xd3, 0x85, 0x8b, 0xdd, 0xe1, 0xf3, 0xa1, 0xeb, 0x4c,
+	0x89, 0x85, 0xb1, 0xe4, 0x33, 0x1d, 0x7b, 0xe2, 0xc9, 0x24, 0xf1, 0x87, 0xac, 0x65, 0x4d, 0x99,
+	0x15, 0xf6, 0x40, 0x73, 0x01, 0x49, 0x79, 0xfc, 0xa1, 0xfa, 0x53, 0xa8, 0xf6, 0x58, 0x79, 0xdf,
+	0x43, 0x6a, 0x10, 0x4b, 0xf9, 0x88, 0x35, 0x5f, 0x14, 0x4f, 0x1d, 0x57, 0x9c, 0xc8, 0x37, 0x1e,
+	0xdd, 0x8e, 0xc5, 0x16, 0x63, 0xed, 0x4a, 0x06, 0x2d, 0x60, 0x15, 0x5b, 0x0d, 0x75, 0xaf, 0x74,
+	0x63, 0x4a, 0xd1, 0x95, 0x5a, 0x01, 0x87, 0x3a, 0x0c, 0x09, 0xab, 0x5b, 0x21, 0x52, 0xdd, 0x78,
+	0x43, 0xd5, 0xb7, 0x59, 0xca, 0x1a, 0x94, 0x9c, 0x10, 0x8b, 0xd0, 0x2b, 0x29, 0xc7, 0x43, 0x68,
+	0xce, 0x88, 0xad, 0x67, 0x3c, 0x63, 0x28, 0x33, 0x62, 0x0f, 0x25, 0xc9, 0xbf, 0xdd, 0x62, 0x33,
+	0x8c, 0xcb, 0xe5, 0x19, 0x79, 0x39, 0xc3, 0xb8, 0x4c, 0xce, 0x78, 0x17, 0x1a, 0x33, 0xe2, 0x79,
+	0xc4, 0x3e, 0x4d, 0xbe, 0xb3, 0xdc, 0x90, 0x78, 0xf0, 0xcc, 0xf2, 0x05, 0x6c, 0xca, 0xf3, 0x00,
+	0x71, 0xec, 0x7d, 0x83, 0x58, 0x0b, 0x17, 0x95, 0xef, 0x42, 0xc9, 0x3c, 0x43, 0xf3, 0x5c, 0x1a,
+	0xea, 0x4e, 0xd4, 0x50, 0x21, 0x77, 0x97, 0xb1, 0x68, 0x82, 0x93, 0xf9, 0x81, 0xd8, 0x13, 0x62,
+	0xca, 0x33, 0x59, 0x5d, 0xf3, 0x87, 0xef, 0xfd, 0x2a, 0x07, 0xf5, 0x98, 0x75, 0x95, 0x2a, 0xac,
+	0x3d, 0x1f, 0x1c, 0x0c, 0x8e, 0x3f, 0x19, 0x34, 0x5e, 0x53, 0xea, 0x50, 0xd1, 0x7a, 0x63, 0xed,
+	0xb3, 0xce, 0x93, 0xc3, 0x5e, 0x23, 0xa7, 0x6c, 0x83, 0x32, 0xd4, 0x8e, 0xc7, 0xc7, 0xdd, 0xe3,
+	0x43, 0xfd, 0xe3, 0xfe, 0xf1, 0x61, 0x67, 0xdc, 0x3f, 0x1e, 0x34, 0xf2, 0xca, 0x16, 0xdc, 0x18,
+	0xf5, 0x46, 0xa3, 0xfe, 0xf1, 0x40, 0xef, 0x7d, 0x3a, 0xec, 0x6b, 0xbd, 0xbd, 0x46, 0x81, 0xcd,
+	0x7d, 0xd2, 0xd9, 0xd3, 0xfb, 0x83, 0xe1, 0xf3, 0x71, 0xa3, 0xa8, 0xd4, 0x60, 0xbd, 0x3f, 0x18,
+	0xf7, 0xb4, 0x41, 0xe7, 0xb0, 0x51, 0x52, 0x1a, 0x50, 0xeb, 0x0f, 0xba, 0xc7, 0x47, 0xc3, 0xce,
+	0xb8, 0xcf, 0xd6, 0x2e, 0x2b, 0x00, 0x65, 0xad, 0x37, 0x3c, 0xec, 0x7c, 0xd6, 0x58, 0x7b, 0xef,
+	0x8f, 0xec, 0xad, 0x2e, 0xae, 0x8a, 0xb2, 0x09, 0x75, 0x29, 0x97, 0xde, 0x7d, 0xd6, 0xeb, 0x1e,
+	0x34, 0x5e, 0x53, 0x6e, 0x40, 0xb5, 0x3f, 0xd8, 0xeb, 0x7d, 0xaa, 0x1f, 0xf6, 0x47, 0xe3, 0x51,
+	0x23, 0xc7, 0xe4, 0xd8, 0xeb, 0x8f, 0xba, 0x87, 0xc7, 0xa3, 0xe7, 0x5a, 0x4f, 0x1f, 0xf5, 0x3f,
+	0xef, 0x35, 0xf2, 0xca, 0x4d, 0xd8, 0x1c, 0x76, 0x3e, 0x3b, 0x7e, 0x3e, 0xd6, 0xbb, 0xc7, 0x47,
+	0x47, 0xfd, 0xf1, 0x51, 0x6f, 0x30, 0x6e, 0x14, 0x94, 0x5b, 0xb0, 0xb5, 0xdf, 0x39, 0xe8, 0xe9,
+	0xa3, 0x5e, 0x8c, 0x50, 0x64, 0xa2, 0x8d, 0x3f, 0xd5, 0xb5, 0xde, 0x7e, 0x4f, 0xeb, 0x0d, 0xba,
+	0xbd, 0x46, 0x89, 0xad, 0xc0, 0x59, 0xc7, 0x5a, 0x67, 0x30, 0xea, 0x74, 0x99, 0xd2, 0xa3, 0x46,
+	0x99, 0xad, 0xa0, 0xf5, 0x3a, 0x87, 0xc9, 0x15, 0xd6, 0x1e, 0xfd, 0x3a, 0x17, 0xbc, 0x3e, 0xb2,
+	0xab, 0x64, 0x62, 0xa2, 0xf2, 0x04, 0xd6, 0x82, 0xb7, 0xaf, 0x98, 0xc3, 0x62, 0x8f, 0x94, 0xed,
+	0x3b, 0xa9, 0x34, 0x99, 0x4d, 0xcf, 0xa0, 0x12, 0x3c, 0xba, 0x29, 0xaf, 0x47, 0x39, 0x93, 0x6f,
+	0x82, 0xed, 0x37, 0x32, 0xa8, 0x62, 0xa5, 0x47, 0x7f, 0xa8, 0xc0, 0x86, 0x7c, 0x27, 0xf3, 0x05,
+	0xfc, 0x1e, 0x14, 0xd9, 0x33, 0x9b, 0x12, 0xdb, 0x9f, 0x23, 0xef, 0x70, 0xed, 0xd6, 0x32, 0x41,
+	0xca, 0xf5, 0x09, 0x6c, 0xc4, 0xdf, 0xdd, 0x94, 0x7b, 0x51, 0xde, 0xd4, 0xd7, 0xba, 0xb6, 0xba,
+	0x8a, 0x45, 0x2e, 0xfc, 0x63, 0x68, 0x24, 0xdf, 0x2c, 0x94, 0xb7, 0x12, 0xf3, 0xd2, 0xde, 0x40,
+	0xda, 0xdf, 0x5a, 0xcd, 0x14, 0x93, 0x3b, 0x72, 0xd9, 0xbf, 0x24, 0xf7, 0xf2, 0x0b, 0x41, 0x5b,
+	0x5d, 0xc5, 0x22, 0x17, 0x1e, 0x40, 0x35, 0x72, 0x45, 0xad, 0xbc, 0x19, 0x6f, 0x79, 0x92, 0x0f,
+	0x01, 0xed, 0xbb, 0x99, 0x74, 0xb9, 0xde, 0x17, 0xb0, 0xb9, 0x74, 0x9d, 0xa9, 0x24, 0x75, 0x4c,
+	0xbd, 0xb5, 0x6d, 0xdf, 0xbf, 0x86, 0x2b, 0x34, 0x45, 0xfc, 0x3a, 0x2e, 0x6e, 0x8a, 0xd4, 0xab,
+	0xcb, 0xb6, 0xba, 0x8a, 0x45, 0x2e, 0x3c, 0xe5, 0x9b, 0x75, 0xf2, 0x16, 0x49, 0x79, 0x3b, 0x69,
+	0xc5, 0xf4, 0x4b, 0xb9, 0xf6, 0x3b, 0xd7, 0xf2, 0x85, 0x26, 0x5a, 0xba, 0x39, 0x89, 0x9b, 0x28,
+	0xeb, 0xe6, 0xa7, 0x7d, 0xff, 0x1a, 0x2e, 0xf9, 0x87, 0x1f, 0x42, 0x2d, 0x7a, 0xce, 0x57, 0x62,
+	0x5e, 0x4b, 0xb9, 0x7d, 0x69, 0xef, 0x64, 0x33, 0xc8, 0x25, 0xc7, 0x50, 0x8f, 0x9d, 0xeb, 0x95,
+	0xd8, 0x94, 0xb4, 0x3b, 0x82, 0xf6, 0xbd, 0x15, 0x1c, 0x72, 0x55, 0x84, 0xe6, 0x88, 0xba, 0x68,
+	0xcc, 0xbe, 0xc1, 0x80, 0x79, 0x98, 0x53, 0x66, 0xb0, 0x2d, 0x7e, 0xf3, 0x8d, 0x3b, 0x77, 0x37,
+	0xf7, 0x30, 0xf7, 0xe8, 0x1f, 0x65, 0xa8, 0x75, 0x26, 0x33, 0x12, 0x54, 0xd4, 0x01, 0x54, 0x23,
+	0xd7, 0x0a, 0xf1, 0x24, 0x5b, 0xbe, 0x85, 0x68, 0xdf, 0xcd, 0xa4, 0x4b, 0xb3, 0x1d, 0x00, 0x84,
+	0x27, 0x73, 0x25, 0x56, 0x40, 0x97, 0x8e, 0xf1, 0xed, 0x37, 0xb3, 0xc8, 0xb1, 0x8c, 0x8d, 0x1f,
+	0x3f, 0x97, 0x1c, 0x90, 0x7a, 0xa6, 0x6d, 0xdf, 0xbf, 0x86, 0x2b, 0x8c, 0x9d, 0x58, 0x9b, 0x1e,
+	0x8f, 0x9d, 0xb4, 0x5e, 0xbf, 0x7d, 0x6f, 0x05, 0x47, 0x18, 0xe4, 0xd1, 0x26, 0x3a, 0x1e, 0xe4,
+	0x29, 0x5d, 0x77, 0x7b, 0x27, 0x9b, 0x21, 0x56, 0x0c, 0x7d, 0x7c, 0xa9, 0x18, 0x26, 0x5a, 0xed,
+	0xf6, 0xdd, 0x4c, 0x7a, 0x24, 0x69, 0xa2, 0xad, 0x6f, 0x22, 0x69, 0x52, 0xba, 0xe5, 0xf6, 0xbd,
+	0x15, 0x1c, 0xa1, 0xc3, 0x96, 0xda, 0xd8, 0xb8, 0xc3, 0xb2, 0x7a, 0xe3, 0xf6, 0xfd, 0x6b, 0xb8,
+	0x42, 0xd3, 0x46, 0x8f, 0xc8, 0x71, 0xd3, 0xa6, 0x9c, 0xcc, 0xdb, 0x3b, 0xd9, 0x0c, 0xa1, 0x69,
+	0x23, 0x07, 0xdc, 0xb8, 0x69, 0x97, 0x4f, 0xd0, 0xed, 0xbb, 0x99, 0x74, 0xb1, 0xde, 0x49, 0x99,
+	0x77, 0xb1, 0x1f, 0xfe, 0x77, 0x00, 0x8a, 0x33, 0x2b, 0x8e, 0x54, 0x25, 0x00, 0x00,
 }
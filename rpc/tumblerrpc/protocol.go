@@ -0,0 +1,37 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumblerrpc
+
+// ProtocolVersion is the version of the protocol spoken over the API. It
+// must be incremented with every change that older peers can't handle, and
+// a golden transcript of the new version recorded with go test -update.
+const ProtocolVersion = 3
+
+// Features negotiated during the handshake.
+const (
+	// FeatureCommission indicates that payment offers must cover the
+	// commission advertised by the tumbler.
+	FeatureCommission = "commission"
+
+	// FeaturePuzzleSchemes indicates that the tumbler advertises the
+	// scheme its puzzles are constructed with.
+	FeaturePuzzleSchemes = "puzzle-schemes"
+
+	// FeaturePaymentChannels indicates that a single payment offer may
+	// fund multiple payments.
+	FeaturePaymentChannels = "payment-channels"
+
+	// FeatureSessionResume indicates that clients may query the state of
+	// their session with the ResumeSession call after a disconnect.
+	FeatureSessionResume = "session-resume"
+
+	// FeatureSHA256HashLock indicates that payment offers may lock funds
+	// with SHA-256 hashes of 32 byte preimages.
+	FeatureSHA256HashLock = "sha256-hash-lock"
+
+	// FeatureStreamingPromises indicates that the tumbler serves the
+	// StreamPuzzlePromises and StreamSolutionPromises calls.
+	FeatureStreamingPromises = "streaming-promises"
+)
@@ -0,0 +1,571 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumblerrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/decred/tumblebit/puzzle"
+)
+
+// Golden transcripts record the messages of a complete exchange produced by
+// a release speaking a particular protocol version. Every transcript kept
+// in testdata must be consumed by the current code: puzzles, promises and
+// secrets recorded in it must verify and its messages must decode and
+// encode to the same bytes. Run the tests with -update to record the
+// transcript of the current protocol version once it's bumped.
+var update = flag.Bool("update", false, "record the golden transcript of "+
+	"the current protocol version")
+
+const (
+	transcriptTxCount      = 4
+	transcriptPuzzleCount  = 4
+	transcriptSecretSize   = 20
+	transcriptPuzzleKeyLen = 1024
+)
+
+// transcript is an exchange recorded in a golden file. Signatures are the
+// data promised by the tumbler for every transaction hash of the escrow.
+type transcript struct {
+	ProtocolVersion int                 `json:"protocol_version"`
+	Signatures      [][]byte            `json:"signatures"`
+	Messages        []transcriptMessage `json:"messages"`
+}
+
+// transcriptMessage is a message in its wire encoding.
+type transcriptMessage struct {
+	Type string `json:"type"`
+	Wire []byte `json:"wire"`
+}
+
+func transcriptPath(version int) string {
+	return filepath.Join("testdata", fmt.Sprintf("transcript-v%d.json",
+		version))
+}
+
+func TestTranscripts(t *testing.T) {
+	if *update {
+		tr, err := recordTranscript(rand.New(rand.NewSource(1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := json.MarshalIndent(tr, "", "\t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		b = append(b, '\n')
+		err = ioutil.WriteFile(transcriptPath(ProtocolVersion), b, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join("testdata",
+		"transcript-v*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := false
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var tr transcript
+		if err = json.Unmarshal(b, &tr); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		if file != transcriptPath(tr.ProtocolVersion) {
+			t.Fatalf("%s: transcript of protocol version %d", file,
+				tr.ProtocolVersion)
+		}
+		current = current || tr.ProtocolVersion == ProtocolVersion
+		if err = verifyTranscript(&tr); err != nil {
+			t.Errorf("%s: %v", file, err)
+		}
+	}
+	if !current {
+		t.Fatalf("no transcript of protocol version %d has been "+
+			"recorded, run the tests with -update", ProtocolVersion)
+	}
+}
+
+// recordTranscript runs the Puzzle-Promise and the Puzzle-Solver protocols
+// between a client and a tumbler and records the messages exchanged. Half
+// of the transactions and puzzles are fake.
+func recordTranscript(random *rand.Rand) (*transcript, error) {
+	key, err := puzzle.GeneratePuzzleKey(transcriptPuzzleKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Zero()
+	pk := key.PublicKey()
+	puzzleKey, err := puzzle.MarshalPubKey(key)
+	if err != nil {
+		return nil, err
+	}
+	randomBytes := func(n int) []byte {
+		b := make([]byte, n)
+		random.Read(b)
+		return b
+	}
+
+	tr := &transcript{ProtocolVersion: ProtocolVersion}
+	record := func(msgs ...proto.Message) error {
+		for _, msg := range msgs {
+			b, err := proto.Marshal(msg)
+			if err != nil {
+				return err
+			}
+			tr.Messages = append(tr.Messages, transcriptMessage{
+				Type: proto.MessageName(msg),
+				Wire: b,
+			})
+		}
+		return nil
+	}
+
+	// Puzzle-Promise
+	cookie := randomBytes(32)
+	salt := randomBytes(32)
+	var fakeTxList, realTxList []int
+	var pads [][]byte
+	txHashes := make([][]byte, transcriptTxCount)
+	for i := range txHashes {
+		if i%2 == 0 {
+			pad := randomBytes(32)
+			pads = append(pads, pad)
+			txHashes[i] = puzzle.FakeTxFormat(pad)
+			fakeTxList = append(fakeTxList, i)
+		} else {
+			txHashes[i] = randomBytes(32)
+			realTxList = append(realTxList, i)
+		}
+	}
+	fakeSetHash, err := puzzle.IndexCommitment{Version: puzzle.CommitmentV1,
+		Phase: puzzle.PhaseFakeTransactions}.Commit(salt, fakeTxList)
+	if err != nil {
+		return nil, err
+	}
+	realSetHash, err := puzzle.IndexCommitment{Version: puzzle.CommitmentV1,
+		Phase: puzzle.PhaseRealTransactions}.Commit(salt, realTxList)
+	if err != nil {
+		return nil, err
+	}
+
+	puzzles := make([][]byte, len(txHashes))
+	promises := make([][]byte, len(txHashes))
+	secrets := make([][]byte, len(txHashes))
+	for i := range txHashes {
+		tr.Signatures = append(tr.Signatures, randomBytes(72))
+		puzzles[i], promises[i], secrets[i], err =
+			puzzle.NewPuzzlePromise(key, tr.Signatures[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	var fakeSecrets, realSecrets [][]byte
+	for _, idx := range fakeTxList {
+		fakeSecrets = append(fakeSecrets, secrets[idx])
+	}
+	for _, idx := range realTxList {
+		realSecrets = append(realSecrets, secrets[idx])
+	}
+	quotients, err := puzzle.Quotients(pk, realSecrets)
+	if err != nil {
+		return nil, err
+	}
+	fakeTxs, err := puzzle.EncodeIndexList(fakeTxList)
+	if err != nil {
+		return nil, err
+	}
+	realTxs, err := puzzle.EncodeIndexList(realTxList)
+	if err != nil {
+		return nil, err
+	}
+	err = record(
+		&GetPuzzlePromisesRequest{
+			Cookie:            cookie,
+			FakeSetHash:       fakeSetHash,
+			RealSetHash:       realSetHash,
+			TransactionHashes: txHashes,
+			Sequence:          1,
+		},
+		&GetPuzzlePromisesResponse{
+			PublicKey: randomBytes(33),
+			PuzzleKey: puzzleKey,
+			Puzzles:   puzzles,
+			Promises:  promises,
+		},
+		&FinalizeEscrowRequest{
+			Cookie:     cookie,
+			Salt:       salt,
+			FakeTxList: fakeTxs,
+			RealTxList: realTxs,
+			RandomPads: pads,
+			Sequence:   2,
+		},
+		&FinalizeEscrowResponse{
+			EscrowHash: randomBytes(32),
+			Secrets:    fakeSecrets,
+			Quotients:  quotients,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Puzzle-Solver for the puzzle of the first real transaction
+	p := puzzles[realTxList[0]]
+	one := big.NewInt(1).Bytes()
+	var fakePuzzleList, realPuzzleList []int
+	var fakeFactors, realFactors [][]byte
+	solverPuzzles := make([][]byte, transcriptPuzzleCount)
+	for i := range solverPuzzles {
+		var factor []byte
+		if i%2 == 1 {
+			solverPuzzles[i], factor, _, err =
+				puzzle.BlindPuzzleWithRand(random, pk, one)
+			fakeFactors = append(fakeFactors, factor)
+			fakePuzzleList = append(fakePuzzleList, i)
+		} else {
+			solverPuzzles[i], factor, _, err =
+				puzzle.BlindPuzzleWithRand(random, pk, p)
+			realFactors = append(realFactors, factor)
+			realPuzzleList = append(realPuzzleList, i)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	solverPromises := make([][]byte, len(solverPuzzles))
+	solverSecrets := make([][]byte, len(solverPuzzles))
+	keyHashes := make([][]byte, len(solverPuzzles))
+	for i := range solverPuzzles {
+		_, solverPromises[i], solverSecrets[i], err =
+			puzzle.NewSizedSolutionPromise(key, solverPuzzles[i],
+				transcriptSecretSize)
+		if err != nil {
+			return nil, err
+		}
+		keyHashes[i] = chainhash.HashB(solverSecrets[i])
+	}
+	var fakeSolverSecrets, realSolverSecrets [][]byte
+	for _, idx := range fakePuzzleList {
+		fakeSolverSecrets = append(fakeSolverSecrets, solverSecrets[idx])
+	}
+	for _, idx := range realPuzzleList {
+		realSolverSecrets = append(realSolverSecrets, solverSecrets[idx])
+	}
+	fakePuzzles, err := puzzle.EncodeIndexList(fakePuzzleList)
+	if err != nil {
+		return nil, err
+	}
+	realPuzzles, err := puzzle.EncodeIndexList(realPuzzleList)
+	if err != nil {
+		return nil, err
+	}
+	err = record(
+		&GetSolutionPromisesRequest{
+			Address:           "TsfDLrRkk9ciUuwfp2b8PawwnukYD7yAjGd",
+			Epoch:             7,
+			Puzzles:           solverPuzzles,
+			RealPreimageCount: int32(len(realPuzzleList)),
+			FakePreimageCount: int32(len(fakePuzzleList)),
+		},
+		&GetSolutionPromisesResponse{
+			Cookie:            randomBytes(32),
+			Promises:          solverPromises,
+			KeyHashes:         keyHashes,
+			RealPreimageCount: int32(len(realPuzzleList)),
+			FakePreimageCount: int32(len(fakePuzzleList)),
+		},
+		&ValidateSolutionsRequest{
+			Cookie:         cookie,
+			FakePuzzleList: fakePuzzles,
+			RandomFactors:  fakeFactors,
+			Sequence:       1,
+		},
+		&ValidateSolutionsResponse{
+			Secrets: fakeSolverSecrets,
+		},
+		&PaymentOfferRequest{
+			Cookie:            cookie,
+			Amount:            100000000,
+			PublicKey:         "TsfDLrRkk9ciUuwfp2b8PawwnukYD7yAjGd",
+			EscrowHash:        randomBytes(32),
+			EscrowScript:      randomBytes(100),
+			EscrowTransaction: randomBytes(200),
+			Puzzle:            p,
+			RealPuzzleList:    realPuzzles,
+			RandomFactors:     realFactors,
+			Sequence:          2,
+		},
+		&PaymentOfferResponse{
+			Secrets: realSolverSecrets,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return tr, nil
+}
+
+// decodeTranscript decodes the messages of the transcript, which must
+// encode to the same bytes again.
+func decodeTranscript(tr *transcript) (map[string]proto.Message, error) {
+	msgs := make(map[string]proto.Message)
+	for _, m := range tr.Messages {
+		typ := proto.MessageType(m.Type)
+		if typ == nil {
+			return nil, fmt.Errorf("unknown message %s", m.Type)
+		}
+		msg := reflect.New(typ.Elem()).Interface().(proto.Message)
+		if err := proto.Unmarshal(m.Wire, msg); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %v", m.Type, err)
+		}
+		b, err := proto.Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(b, m.Wire) {
+			return nil, fmt.Errorf("%s encodes differently", m.Type)
+		}
+		msgs[m.Type] = msg
+	}
+	return msgs, nil
+}
+
+// verifyTranscript makes sure the current code consumes the exchange
+// recorded in the transcript the way the client and the tumbler would.
+func verifyTranscript(tr *transcript) error {
+	msgs, err := decodeTranscript(tr)
+	if err != nil {
+		return err
+	}
+	var (
+		ppReq  GetPuzzlePromisesRequest
+		ppResp GetPuzzlePromisesResponse
+		feReq  FinalizeEscrowRequest
+		feResp FinalizeEscrowResponse
+		spReq  GetSolutionPromisesRequest
+		spResp GetSolutionPromisesResponse
+		vsReq  ValidateSolutionsRequest
+		vsResp ValidateSolutionsResponse
+		poReq  PaymentOfferRequest
+		poResp PaymentOfferResponse
+	)
+	for _, msg := range []proto.Message{&ppReq, &ppResp, &feReq, &feResp,
+		&spReq, &spResp, &vsReq, &vsResp, &poReq, &poResp} {
+		m, ok := msgs[proto.MessageName(msg)]
+		if !ok {
+			return fmt.Errorf("missing %s", proto.MessageName(msg))
+		}
+		proto.Merge(msg, m)
+	}
+
+	pk, err := puzzle.ParsePubKey(ppResp.PuzzleKey)
+	if err != nil {
+		return fmt.Errorf("failed to parse puzzle key: %v", err)
+	}
+	n := len(ppReq.TransactionHashes)
+	if len(ppResp.Puzzles) != n || len(ppResp.Promises) != n ||
+		len(tr.Signatures) != n {
+		return fmt.Errorf("%d puzzles and %d promises for %d "+
+			"transactions", len(ppResp.Puzzles), len(ppResp.Promises), n)
+	}
+
+	// Puzzle-Promise: the client verifies the promises of the fake
+	// transactions and the quotients linking the real ones, the tumbler
+	// verifies the commitments to the index lists.
+	fakeTxList, err := decodeList(feReq.FakeTxList, n)
+	if err != nil {
+		return err
+	}
+	realTxList, err := decodeList(feReq.RealTxList, n)
+	if err != nil {
+		return err
+	}
+	err = puzzle.IndexCommitment{Version: puzzle.CommitmentV1,
+		Phase: puzzle.PhaseFakeTransactions}.Verify(feReq.Salt,
+		fakeTxList, ppReq.FakeSetHash)
+	if err != nil {
+		return fmt.Errorf("fake transaction list: %v", err)
+	}
+	err = puzzle.IndexCommitment{Version: puzzle.CommitmentV1,
+		Phase: puzzle.PhaseRealTransactions}.Verify(feReq.Salt,
+		realTxList, ppReq.RealSetHash)
+	if err != nil {
+		return fmt.Errorf("real transaction list: %v", err)
+	}
+	if len(feReq.RandomPads) != len(fakeTxList) ||
+		len(feResp.Secrets) != len(fakeTxList) {
+		return fmt.Errorf("%d pads and %d secrets for %d fake "+
+			"transactions", len(feReq.RandomPads), len(feResp.Secrets),
+			len(fakeTxList))
+	}
+	for i, idx := range fakeTxList {
+		if !bytes.Equal(puzzle.FakeTxFormat(feReq.RandomPads[i]),
+			ppReq.TransactionHashes[idx]) {
+			return fmt.Errorf("fake transaction %d doesn't match its "+
+				"pad", idx)
+		}
+		if err = checkPromise(&pk, ppResp.Puzzles[idx],
+			ppResp.Promises[idx], feResp.Secrets[i],
+			tr.Signatures[idx]); err != nil {
+			return fmt.Errorf("fake transaction %d: %v", idx, err)
+		}
+	}
+	realPuzzles := make([][]byte, len(realTxList))
+	for i, idx := range realTxList {
+		realPuzzles[i] = ppResp.Puzzles[idx]
+	}
+	if len(feResp.Quotients) != len(realTxList) ||
+		!puzzle.VerifyQuotients(&pk, feResp.Quotients, realPuzzles) {
+		return fmt.Errorf("quotients don't link the real puzzles")
+	}
+
+	// Puzzle-Solver: the client verifies the solutions of the fake
+	// puzzles, the tumbler verifies the blinding of the real ones and the
+	// solution they reveal solves the puzzle promised for the escrow.
+	m := len(spReq.Puzzles)
+	if len(spResp.Promises) != m || len(spResp.KeyHashes) != m {
+		return fmt.Errorf("%d promises and %d key hashes for %d "+
+			"puzzles", len(spResp.Promises), len(spResp.KeyHashes), m)
+	}
+	fakePuzzleList, err := decodeList(vsReq.FakePuzzleList, m)
+	if err != nil {
+		return err
+	}
+	realPuzzleList, err := decodeList(poReq.RealPuzzleList, m)
+	if err != nil {
+		return err
+	}
+	if len(fakePuzzleList) != int(spResp.FakePreimageCount) ||
+		len(realPuzzleList) != int(spResp.RealPreimageCount) {
+		return fmt.Errorf("%d fake and %d real puzzles submitted, "+
+			"%d and %d expected", len(fakePuzzleList),
+			len(realPuzzleList), spResp.FakePreimageCount,
+			spResp.RealPreimageCount)
+	}
+	if len(vsReq.RandomFactors) != len(fakePuzzleList) ||
+		len(vsResp.Secrets) != len(fakePuzzleList) {
+		return fmt.Errorf("%d factors and %d secrets for %d fake "+
+			"puzzles", len(vsReq.RandomFactors), len(vsResp.Secrets),
+			len(fakePuzzleList))
+	}
+	for i, idx := range fakePuzzleList {
+		if !puzzle.ValidatePuzzle(&pk, spReq.Puzzles[idx],
+			vsReq.RandomFactors[i]) {
+			return fmt.Errorf("fake puzzle %d doesn't match its factor",
+				idx)
+		}
+		solution, err := revealSolution(&spResp, idx, vsResp.Secrets[i])
+		if err != nil {
+			return fmt.Errorf("fake puzzle %d: %v", idx, err)
+		}
+		if !bytes.Equal(solution, vsReq.RandomFactors[i]) {
+			return fmt.Errorf("fake puzzle %d has a wrong solution", idx)
+		}
+	}
+	escrowIdx := -1
+	for _, idx := range realTxList {
+		if bytes.Equal(ppResp.Puzzles[idx], poReq.Puzzle) {
+			escrowIdx = idx
+		}
+	}
+	if escrowIdx < 0 {
+		return fmt.Errorf("offer made for a puzzle not promised")
+	}
+	if len(poReq.RandomFactors) != len(realPuzzleList) ||
+		len(poResp.Secrets) != len(realPuzzleList) {
+		return fmt.Errorf("%d factors and %d secrets for %d real "+
+			"puzzles", len(poReq.RandomFactors), len(poResp.Secrets),
+			len(realPuzzleList))
+	}
+	for i, idx := range realPuzzleList {
+		if !puzzle.ValidateBlindedPuzzle(&pk, spReq.Puzzles[idx],
+			poReq.Puzzle, poReq.RandomFactors[i]) {
+			return fmt.Errorf("real puzzle %d isn't a blinding of the "+
+				"offered puzzle", idx)
+		}
+		blinded, err := revealSolution(&spResp, idx, poResp.Secrets[i])
+		if err != nil {
+			return fmt.Errorf("real puzzle %d: %v", idx, err)
+		}
+		inverse := new(big.Int).ModInverse(
+			new(big.Int).SetBytes(poReq.RandomFactors[i]), pk.N)
+		if inverse == nil {
+			return fmt.Errorf("real puzzle %d has a malformed factor",
+				idx)
+		}
+		solution := puzzle.UnblindPuzzle(&pk, blinded, inverse.Bytes())
+		if err = checkPromise(&pk, ppResp.Puzzles[escrowIdx],
+			ppResp.Promises[escrowIdx], solution,
+			tr.Signatures[escrowIdx]); err != nil {
+			return fmt.Errorf("real puzzle %d: %v", idx, err)
+		}
+	}
+	return nil
+}
+
+// decodeList decodes an index list and makes sure its indices are in the
+// range of the n values.
+func decodeList(list []byte, n int) ([]int, error) {
+	indices, err := puzzle.DecodeIndexList(list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode index list: %v", err)
+	}
+	b, err := puzzle.EncodeIndexList(indices)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(b, list) {
+		return nil, fmt.Errorf("index list %x encodes differently", list)
+	}
+	for _, idx := range indices {
+		if idx >= n {
+			return nil, fmt.Errorf("index %d out of range", idx)
+		}
+	}
+	return indices, nil
+}
+
+// checkPromise makes sure the secret solves the puzzle and reveals the
+// signature promised.
+func checkPromise(pk *puzzle.PuzzlePubKey, p, promise, secret, sig []byte) error {
+	if !puzzle.ValidatePuzzle(pk, p, secret) {
+		return fmt.Errorf("secret doesn't solve the puzzle")
+	}
+	revealed, err := puzzle.RevealSolution(promise, secret)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(revealed, sig) {
+		return fmt.Errorf("promise doesn't reveal the signature")
+	}
+	return nil
+}
+
+// revealSolution checks the secret against the key hash the tumbler
+// committed to and reveals the solution of the i'th puzzle promised.
+func revealSolution(resp *GetSolutionPromisesResponse, i int, secret []byte) ([]byte, error) {
+	if !bytes.Equal(chainhash.HashB(secret), resp.KeyHashes[i]) {
+		return nil, fmt.Errorf("secret doesn't match the key hash")
+	}
+	return puzzle.RevealSolution(resp.Promises[i], secret)
+}
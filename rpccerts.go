@@ -0,0 +1,343 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/decred/dcrd/certgen"
+)
+
+// The RPC key pair is rotated before its certificate expires, whenever one
+// of the rotateSignals is received and on request of the operator. The
+// certificate presented after the next rotation is generated in advance
+// and published along with the current one, both in the certificate file
+// and with GetServerCerts, so that clients pin it before it's presented
+// and keep connecting to the tumbler across a rotation.
+
+// rotateRetryInterval is the time waited before retrying a failed
+// rotation.
+const rotateRetryInterval = time.Minute
+
+// rotateSignals defines the signals that rotate the RPC key pair.
+// Conditional compilation is used to include SIGHUP on Unix.
+var rotateSignals []os.Signal
+
+// certPair is a TLS key pair along with its PEM encoded certificate and
+// key.
+type certPair struct {
+	keyPair tls.Certificate
+	cert    []byte
+	key     []byte
+}
+
+// rpcCerts holds the current and the next RPC key pairs.
+type rpcCerts struct {
+	writeKey bool
+	lifetime time.Duration
+	rotated  chan struct{}
+
+	mu      sync.RWMutex
+	current *certPair
+	next    *certPair
+}
+
+// parseCertPair parses the PEM encoded certificate and key.
+func parseCertPair(cert, key []byte) (*certPair, error) {
+	keyPair, err := tls.X509KeyPair(cert, key)
+	if err != nil {
+		return nil, err
+	}
+	keyPair.Leaf, err = x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	return &certPair{keyPair: keyPair, cert: cert, key: key}, nil
+}
+
+// newCertPair generates a key pair with a certificate valid until the
+// given time.
+func newCertPair(validUntil time.Time) (*certPair, error) {
+	org := "tumblebit autogenerated cert"
+	cert, key, err := certgen.NewTLSCertPair(cfg.TLSCurve.Curve(), org,
+		validUntil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return parseCertPair(cert, key)
+}
+
+// pemBlocks returns the separately encoded PEM blocks of the data whose
+// types end with the suffix.
+func pemBlocks(data []byte, typeSuffix string) [][]byte {
+	var blocks [][]byte
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return blocks
+		}
+		if strings.HasSuffix(block.Type, typeSuffix) {
+			blocks = append(blocks, pem.EncodeToMemory(block))
+		}
+	}
+}
+
+// writeFileAtomic writes the data to a temporary file and renames it over
+// the file so that readers never observe a partial file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// openRPCCerts creates or loads the RPC TLS key pairs specified by the
+// application config.  This function respects the cfg.OneTimeTLSKey setting.
+func openRPCCerts() (*rpcCerts, error) {
+	c := &rpcCerts{
+		writeKey: !cfg.OneTimeTLSKey,
+		lifetime: cfg.TLSCertLifetime,
+		rotated:  make(chan struct{}, 1),
+	}
+
+	// Check for existence of the TLS key file.  If one time TLS keys are
+	// enabled but a key already exists, this function should error since
+	// it's possible that a persistent certificate was copied to a remote
+	// machine.  Otherwise, generate a new keypair when the key is missing.
+	// When generating new persistent keys, overwriting an existing cert is
+	// acceptable if the previous execution used a one time TLS key.
+	// Otherwise, both the cert and key should be read from disk.
+	_, e := os.Stat(cfg.RPCKey.Value)
+	keyExists := !os.IsNotExist(e)
+	var err error
+	switch {
+	case cfg.OneTimeTLSKey && keyExists:
+		err = fmt.Errorf("one time TLS keys are enabled, but TLS key "+
+			"`%s` already exists", cfg.RPCKey)
+	case cfg.OneTimeTLSKey || !keyExists:
+		err = c.generate()
+	default:
+		err = c.load()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Certificate files written before certificates were rotated don't
+	// contain the next certificate.
+	if c.next == nil {
+		c.next, err = newCertPair(c.nextExpiry())
+		if err != nil {
+			return nil, err
+		}
+		if err = c.save(); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Infof("TLS certificate %s is rotated at %v",
+		certFingerprint(c.current.keyPair.Certificate[0]),
+		c.rotationTime().Format(time.RFC3339))
+	return c, nil
+}
+
+// generate generates the current key pair, leaving the next one to the
+// caller.
+func (c *rpcCerts) generate() error {
+	log.Infof("Generating TLS certificates...")
+
+	// Create directories for cert and key files if they do not yet exist.
+	certDir, _ := filepath.Split(cfg.RPCCert.Value)
+	keyDir, _ := filepath.Split(cfg.RPCKey.Value)
+	err := os.MkdirAll(certDir, 0700)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(keyDir, 0700)
+	if err != nil {
+		return err
+	}
+
+	c.current, err = newCertPair(time.Now().Add(c.lifetime))
+	if err != nil {
+		return err
+	}
+
+	log.Info("Done generating TLS certificates")
+	return nil
+}
+
+// load reads the current and, if present, the next key pair from the cert
+// and key files. Certificates are matched with keys regardless of the
+// order of the keys, so that files left behind by an interrupted rotation
+// are still loaded.
+func (c *rpcCerts) load() error {
+	certPEM, err := ioutil.ReadFile(cfg.RPCCert.Value)
+	if err != nil {
+		return err
+	}
+	keyPEM, err := ioutil.ReadFile(cfg.RPCKey.Value)
+	if err != nil {
+		return err
+	}
+	certs := pemBlocks(certPEM, "CERTIFICATE")
+	keys := pemBlocks(keyPEM, "PRIVATE KEY")
+
+	var pairs [2]*certPair
+	for i := 0; i < len(certs) && i < len(pairs); i++ {
+		for _, key := range keys {
+			p, err := parseCertPair(certs[i], key)
+			if err == nil {
+				pairs[i] = p
+				break
+			}
+		}
+	}
+	if pairs[0] == nil {
+		return fmt.Errorf("no key in %s matches the certificate in %s",
+			cfg.RPCKey, cfg.RPCCert)
+	}
+	c.current, c.next = pairs[0], pairs[1]
+	return nil
+}
+
+// save writes the current and the next certificate to the cert file and,
+// unless one time TLS keys are used, their keys to the key file. The cert
+// file is replaced first so that files left behind by an interrupted
+// rotation still hold the key of the current certificate.
+func (c *rpcCerts) save() error {
+	certs := make([]byte, 0, len(c.current.cert)+len(c.next.cert))
+	certs = append(certs, c.current.cert...)
+	certs = append(certs, c.next.cert...)
+	if err := writeFileAtomic(cfg.RPCCert.Value, certs); err != nil {
+		return err
+	}
+	if !c.writeKey {
+		return nil
+	}
+	keys := make([]byte, 0, len(c.current.key)+len(c.next.key))
+	keys = append(keys, c.current.key...)
+	keys = append(keys, c.next.key...)
+	return writeFileAtomic(cfg.RPCKey.Value, keys)
+}
+
+// rotationTime returns the time the current certificate is rotated at,
+// which leaves a tenth of the lifetime before it expires. The mutex must
+// be held.
+func (c *rpcCerts) rotationTime() time.Time {
+	return c.current.keyPair.Leaf.NotAfter.Add(-c.lifetime / 10)
+}
+
+// nextExpiry returns the expiry of a new next certificate, which is valid
+// for the lifetime following the rotation of the current one. The mutex
+// must be held.
+func (c *rpcCerts) nextExpiry() time.Time {
+	t := c.rotationTime()
+	if now := time.Now(); t.Before(now) {
+		t = now
+	}
+	return t.Add(c.lifetime)
+}
+
+// getCertificate returns the current key pair presented to clients.
+func (c *rpcCerts) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return &c.current.keyPair, nil
+}
+
+// Certs returns the PEM encoded current and next certificates along with
+// the time of the next rotation.
+func (c *rpcCerts) Certs() ([]byte, time.Time) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	certs := make([]byte, 0, len(c.current.cert)+len(c.next.cert))
+	certs = append(certs, c.current.cert...)
+	certs = append(certs, c.next.cert...)
+	return certs, c.rotationTime()
+}
+
+// Rotate presents the next key pair to clients from now on and generates
+// a new next key pair. The key pairs are left unchanged if they can't be
+// written to the files.
+func (c *rpcCerts) Rotate() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current, next := c.current, c.next
+	c.current = next
+	var err error
+	c.next, err = newCertPair(c.nextExpiry())
+	if err == nil {
+		err = c.save()
+	}
+	if err != nil {
+		c.current, c.next = current, next
+		return err
+	}
+
+	log.Infof("Rotated TLS certificate to %s, next rotation at %v",
+		certFingerprint(c.current.keyPair.Certificate[0]),
+		c.rotationTime().Format(time.RFC3339))
+	select {
+	case c.rotated <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// run rotates the key pairs when the current certificate is due and
+// whenever one of the rotateSignals is received until the context is
+// cancelled.
+func (c *rpcCerts) run(ctx context.Context) {
+	sigs := make(chan os.Signal, 1)
+	if len(rotateSignals) != 0 {
+		signal.Notify(sigs, rotateSignals...)
+		defer signal.Stop(sigs)
+	}
+
+	for {
+		c.mu.RLock()
+		timer := time.NewTimer(time.Until(c.rotationTime()))
+		c.mu.RUnlock()
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-c.rotated:
+			// Rotated on request, wait for the new rotation time.
+			timer.Stop()
+			continue
+		case sig := <-sigs:
+			timer.Stop()
+			log.Infof("Received signal (%s).  Rotating TLS "+
+				"certificate...", sig)
+		case <-timer.C:
+		}
+
+		if err := c.Rotate(); err != nil {
+			log.Errorf("Unable to rotate TLS certificate: %v", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(rotateRetryInterval):
+			}
+		}
+	}
+}
@@ -7,12 +7,21 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
 	"net"
 
+	"github.com/decred/tumblebit/wallet"
+
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
 )
 
+// startRPCClient connects to dcrwallet. It's also used to redial the wallet
+// once the connection has been lost.
 func startRPCClient(ctx context.Context) (*grpc.ClientConn, error) {
 	var opts []grpc.DialOption
 
@@ -21,12 +30,20 @@ func startRPCClient(ctx context.Context) (*grpc.ClientConn, error) {
 		if err != nil {
 			return nil, err
 		}
-		creds, err := credentials.NewClientTLSFromFile(cfg.CAFile.Value, host)
+		creds, err := walletCredentials(host)
 		if err != nil {
 			return nil, err
 		}
 		opts = append(opts, grpc.WithTransportCredentials(creds))
 	}
+	if cfg.WalletKeepalive > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(
+			keepalive.ClientParameters{
+				Time:                cfg.WalletKeepalive,
+				Timeout:             cfg.GRPCPingTimeout,
+				PermitWithoutStream: true,
+			}))
+	}
 
 	client, err := grpc.DialContext(ctx, cfg.RPCConnect, opts...)
 	if err != nil {
@@ -35,3 +52,71 @@ func startRPCClient(ctx context.Context) (*grpc.ClientConn, error) {
 
 	return client, nil
 }
+
+// walletCredentials returns the TLS credentials authenticating dcrwallet
+// with the CA file. When wallet client certificates are configured, the
+// certificate is presented to wallets authenticating their clients with
+// certificates. Key pairs are read at every dial so that certificates
+// renewed on disk are picked up when the connection is re-established.
+func walletCredentials(host string) (credentials.TransportCredentials, error) {
+	if cfg.WalletClientCert == "" {
+		return credentials.NewClientTLSFromFile(cfg.CAFile.Value, host)
+	}
+	tlsCfg, err := walletTLSConfig(host)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(tlsCfg), nil
+}
+
+// walletJSONRPCConfig returns the configuration of requests to the
+// JSON-RPC server of dcrwallet.
+func walletJSONRPCConfig() (*wallet.JSONRPCConfig, error) {
+	jsonCfg := &wallet.JSONRPCConfig{
+		Host:     cfg.RPCConnect,
+		User:     cfg.WalletRPCUser,
+		Password: cfg.WalletRPCPass,
+	}
+	if cfg.DisableClientTLS {
+		return jsonCfg, nil
+	}
+	host, _, err := net.SplitHostPort(cfg.RPCConnect)
+	if err != nil {
+		return nil, err
+	}
+	jsonCfg.TLS, err = walletTLSConfig(host)
+	if err != nil {
+		return nil, err
+	}
+	return jsonCfg, nil
+}
+
+// walletTLSConfig returns the TLS configuration authenticating dcrwallet
+// with the CA file and presenting the wallet client certificate if it's
+// configured.
+func walletTLSConfig(host string) (*tls.Config, error) {
+	pem, err := ioutil.ReadFile(cfg.CAFile.Value)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s",
+			cfg.CAFile.Value)
+	}
+	tlsCfg := &tls.Config{
+		ServerName: host,
+		RootCAs:    pool,
+	}
+	if cfg.WalletClientCert == "" {
+		return tlsCfg, nil
+	}
+	keyPair, err := tls.LoadX509KeyPair(cfg.WalletClientCert,
+		cfg.WalletClientKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the wallet client "+
+			"certificate: %v", err)
+	}
+	tlsCfg.Certificates = []tls.Certificate{keyPair}
+	return tlsCfg, nil
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/decred/tumblebit/metrics"
+	"github.com/decred/tumblebit/tumbler"
+)
+
+// Every request is served with an ID which is included in the logs of the
+// request and of the session it's served for. Clients may pass the ID in
+// the x-request-id metadata, otherwise one is generated. The ID is sent
+// back in the header of the response so that failures reported by clients
+// can be matched with the logs.
+
+const (
+	// requestIDKey is the metadata key carrying the ID of a request.
+	requestIDKey = "x-request-id"
+
+	// maxRequestIDLen limits the length of IDs passed by clients.
+	maxRequestIDLen = 64
+)
+
+// requestStats records the latency of requests and the panics recovered
+// while serving them per method.
+type requestStats struct {
+	latency *metrics.HistogramVec
+	panics  *metrics.CounterVec
+}
+
+// rpcRequests are the request metrics of the RPC server and the REST
+// gateway, nil unless metrics are served.
+var rpcRequests *requestStats
+
+func newRequestStats(r *metrics.Registry) *requestStats {
+	return &requestStats{
+		latency: r.NewHistogramVec("tumbler_grpc_request_seconds",
+			"Time spent serving requests of the method", "method",
+			metrics.DefBuckets),
+		panics: r.NewCounterVec("tumbler_grpc_panics_total",
+			"Panics recovered while serving requests of the method",
+			"method"),
+	}
+}
+
+// methodName returns the method segment from the full gRPC method name
+// `/package.service/method`.
+func methodName(method string) string {
+	return method[strings.LastIndex(method, "/")+1:]
+}
+
+func (rs *requestStats) observe(method string, start time.Time) {
+	if rs != nil {
+		rs.latency.With(methodName(method)).Observe(
+			time.Since(start).Seconds())
+	}
+}
+
+func (rs *requestStats) panicked(method string) {
+	if rs != nil {
+		rs.panics.With(methodName(method)).Inc()
+	}
+}
+
+// requestID returns the ID passed by the client in the metadata of the
+// request or generates one.
+func requestID(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, id := range md[requestIDKey] {
+			if validRequestID(id) {
+				return id
+			}
+		}
+	}
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// validRequestID returns whether the ID passed by a client is short and
+// made of printable ASCII characters, so that it can't forge log lines.
+func validRequestID(id string) bool {
+	if len(id) == 0 || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// recoverRequest recovers from a panic of the handler serving the request
+// and logs it along with the stack trace. The client is sent an internal
+// error carrying the request ID, the tumbler keeps serving other requests.
+func recoverRequest(method, id, addr string, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	rpcRequests.panicked(method)
+	grpcLog.Errorf("Recovered from panic: method=%s request=%s peer=%s "+
+		"panic=%q\n%s", method, id, addr, r, debug.Stack())
+	*err = status.Errorf(codes.Internal, "internal error serving "+
+		"request %s", id)
+}
+
+// requestStream is a server stream whose context carries the request ID.
+type requestStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s requestStream) Context() context.Context {
+	return s.ctx
+}
+
+// withRequestID derives the context the request with the ID is served
+// with and sends the ID back to the client.
+func withRequestID(ctx context.Context, id string) context.Context {
+	// Requests served by the REST gateway can't send headers.
+	grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, id))
+	return tumbler.WithRequestID(ctx, id)
+}
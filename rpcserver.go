@@ -7,128 +7,95 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
 	"errors"
-	"fmt"
-	"io/ioutil"
 	"net"
 	"os"
-	"path/filepath"
 	"runtime"
 	"strings"
 	"time"
 
-	"github.com/decred/dcrd/certgen"
+	"github.com/decred/tumblebit/metrics"
 	"github.com/decred/tumblebit/rpc/rpcserver"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	_ "google.golang.org/grpc/encoding/gzip" // Registers the gzip compressor
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/peer"
 )
 
-// openRPCKeyPair creates or loads the RPC TLS keypair specified by the
-// application config.  This function respects the cfg.OneTimeTLSKey setting.
-func openRPCKeyPair() (tls.Certificate, error) {
-	// Check for existence of the TLS key file.  If one time TLS keys are
-	// enabled but a key already exists, this function should error since
-	// it's possible that a persistent certificate was copied to a remote
-	// machine.  Otherwise, generate a new keypair when the key is missing.
-	// When generating new persistent keys, overwriting an existing cert is
-	// acceptable if the previous execution used a one time TLS key.
-	// Otherwise, both the cert and key should be read from disk.  If the
-	// cert is missing, the read error will occur in LoadX509KeyPair.
-	_, e := os.Stat(cfg.RPCKey.Value)
-	keyExists := !os.IsNotExist(e)
-	switch {
-	case cfg.OneTimeTLSKey && keyExists:
-		err := fmt.Errorf("one time TLS keys are enabled, but TLS key "+
-			"`%s` already exists", cfg.RPCKey)
-		return tls.Certificate{}, err
-	case cfg.OneTimeTLSKey:
-		return generateRPCKeyPair(false)
-	case !keyExists:
-		return generateRPCKeyPair(true)
-	default:
-		return tls.LoadX509KeyPair(cfg.RPCCert.Value, cfg.RPCKey.Value)
-	}
-}
-
-// generateRPCKeyPair generates a new RPC TLS keypair and writes the cert and
-// possibly also the key in PEM format to the paths specified by the config.  If
-// successful, the new keypair is returned.
-func generateRPCKeyPair(writeKey bool) (tls.Certificate, error) {
-	log.Infof("Generating TLS certificates...")
+// startRPCServer starts serving gRPC connections on the configured
+// listeners and returns the server along with its TLS key pairs. Sizes of
+// messages and latencies of requests are recorded in the metrics registry,
+// if any. Responses are compressed with the compressor of the request.
+func startRPCServer(registry *metrics.Registry) (*grpc.Server, *rpcCerts, error) {
+	var (
+		server *grpc.Server
+		certs  *rpcCerts
+		err    error
+	)
 
-	// Create directories for cert and key files if they do not yet exist.
-	certDir, _ := filepath.Split(cfg.RPCCert.Value)
-	keyDir, _ := filepath.Split(cfg.RPCKey.Value)
-	err := os.MkdirAll(certDir, 0700)
-	if err != nil {
-		return tls.Certificate{}, err
-	}
-	err = os.MkdirAll(keyDir, 0700)
-	if err != nil {
-		return tls.Certificate{}, err
+	if registry != nil {
+		rpcRequests = newRequestStats(registry)
 	}
 
-	// Generate cert pair.
-	org := "tumblebit autogenerated cert"
-	validUntil := time.Now().Add(time.Hour * 24 * 365 * 10)
-	cert, key, err := certgen.NewTLSCertPair(cfg.TLSCurve.Curve(), org,
-		validUntil, nil)
-	if err != nil {
-		return tls.Certificate{}, err
-	}
-	keyPair, err := tls.X509KeyPair(cert, key)
+	certs, err = openRPCCerts()
 	if err != nil {
-		return tls.Certificate{}, err
+		return nil, nil, err
 	}
 
-	// Write cert and (potentially) the key files.
-	err = ioutil.WriteFile(cfg.RPCCert.Value, cert, 0600)
-	if err != nil {
-		return tls.Certificate{}, err
-	}
-	if writeKey {
-		err = ioutil.WriteFile(cfg.RPCKey.Value, key, 0600)
-		if err != nil {
-			rmErr := os.Remove(cfg.RPCCert.Value)
-			if rmErr != nil {
-				log.Warnf("Cannot remove written certificates: %v",
-					rmErr)
+	if len(cfg.GRPCListeners) != 0 || len(cfg.unixListeners) != 0 {
+		listeners := makeListeners(cfg.GRPCListeners, net.Listen)
+		for _, path := range cfg.unixListeners {
+			lis, err := listenUnix(path, cfg.unixSocketMode)
+			if err != nil {
+				log.Warnf("Can't listen on %s: %v", path, err)
+				continue
 			}
-			return tls.Certificate{}, err
+			listeners = append(listeners, lis)
 		}
-	}
-
-	log.Info("Done generating TLS certificates")
-	return keyPair, nil
-}
-
-func startRPCServer() (*grpc.Server, error) {
-	var (
-		server  *grpc.Server
-		keyPair tls.Certificate
-		err     error
-	)
-
-	keyPair, err = openRPCKeyPair()
-	if err != nil {
-		return nil, err
-	}
-
-	if len(cfg.GRPCListeners) != 0 {
-		listeners := makeListeners(cfg.GRPCListeners, net.Listen)
 		if len(listeners) == 0 {
 			err := errors.New("failed to create listeners for RPC server")
-			return nil, err
+			return nil, nil, err
 		}
-		creds := credentials.NewServerTLSFromCert(&keyPair)
-		server = grpc.NewServer(
+		tlsConfig, err := serverTLSConfig(certs)
+		if err != nil {
+			return nil, nil, err
+		}
+		creds := unixPlaintextCreds{credentials.NewTLS(tlsConfig)}
+		opts := []grpc.ServerOption{
 			grpc.Creds(creds),
 			grpc.UnaryInterceptor(interceptUnary),
-		)
+			grpc.StreamInterceptor(interceptStream),
+			grpc.MaxRecvMsgSize(cfg.GRPCMaxMsgSize),
+			grpc.MaxSendMsgSize(cfg.GRPCMaxMsgSize),
+			// Clients waiting for confirmations may keep their
+			// connections idle for long, so they are allowed to
+			// ping without outstanding calls.
+			grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+				MinTime:             cfg.GRPCMinPing,
+				PermitWithoutStream: true,
+			}),
+		}
+		if cfg.GRPCKeepalive > 0 {
+			opts = append(opts, grpc.KeepaliveParams(
+				keepalive.ServerParameters{
+					Time:    cfg.GRPCKeepalive,
+					Timeout: cfg.GRPCPingTimeout,
+				}))
+		}
+		if registry != nil {
+			opts = append(opts,
+				grpc.StatsHandler(rpcserver.NewWireStats(registry)))
+		}
+		server = grpc.NewServer(opts...)
 		rpcserver.RegisterServices(server)
+		if cfg.GRPCHealth {
+			rpcserver.RegisterHealthService(server)
+		}
+		if cfg.GRPCReflection {
+			rpcserver.RegisterReflectionService(server)
+		}
 		for _, lis := range listeners {
 			lis := lis
 			go func() {
@@ -142,10 +109,50 @@ func startRPCServer() (*grpc.Server, error) {
 
 	// Error when GRPC server can be started.
 	if server == nil {
-		return nil, errors.New("no suitable RPC services can be started")
+		err = errors.New("no suitable RPC services can be started")
+		return nil, nil, err
 	}
 
-	return server, nil
+	return server, certs, nil
+}
+
+// listenUnix listens on the Unix domain socket at the path and sets its
+// permissions. A socket left behind by a previous run is replaced.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	fi, err := os.Lstat(path)
+	if err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err = os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	lis, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.Chmod(path, mode); err != nil {
+		lis.Close()
+		return nil, err
+	}
+	return lis, nil
+}
+
+// unixPlaintextCreds serves connections accepted on Unix domain sockets
+// without TLS, since access to them is controlled by the permissions of
+// the socket. Such connections carry no client certificate and therefore
+// can't be used for the admin service.
+type unixPlaintextCreds struct {
+	credentials.TransportCredentials
+}
+
+func (c unixPlaintextCreds) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	if conn.LocalAddr().Network() == "unix" {
+		return conn, nil, nil
+	}
+	return c.TransportCredentials.ServerHandshake(conn)
+}
+
+func (c unixPlaintextCreds) Clone() credentials.TransportCredentials {
+	return unixPlaintextCreds{c.TransportCredentials.Clone()}
 }
 
 // serviceName returns the package.service segment from the full gRPC method
@@ -157,24 +164,75 @@ func serviceName(method string) string {
 	return method[:strings.IndexRune(method, '/')]
 }
 
-func interceptUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+// peerAddr returns the address of the client the request is served for.
+func peerAddr(ctx context.Context) (string, bool) {
 	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "unknown", false
+	}
+	return p.Addr.String(), true
+}
+
+func interceptUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	start := time.Now()
+	id := requestID(ctx)
+	addr, ok := peerAddr(ctx)
 	if ok {
-		grpcLog.Debugf("Unary method %s invoked by %s", info.FullMethod,
-			p.Addr.String())
+		grpcLog.Debugf("Unary method %s invoked by %s, request %s",
+			info.FullMethod, addr, id)
 	}
+	defer rpcRequests.observe(info.FullMethod, start)
+	defer recoverRequest(info.FullMethod, id, addr, &err)
+	ctx = withRequestID(ctx, id)
+
 	err = rpcserver.ServiceReady(serviceName(info.FullMethod))
 	if err != nil {
 		return nil, err
 	}
+	if serviceName(info.FullMethod) == rpcserver.AdminServiceName {
+		if err = authorizeAdmin(ctx); err != nil {
+			return nil, err
+		}
+	}
 	resp, err = handler(ctx, req)
 	if err != nil && ok {
-		grpcLog.Debugf("Unary method %s invoked by %s errored: %v",
-			info.FullMethod, p.Addr.String(), err)
+		grpcLog.Debugf("Unary method %s invoked by %s, request %s, "+
+			"errored: %v", info.FullMethod, addr, id, err)
 	}
 	return resp, err
 }
 
+func interceptStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	start := time.Now()
+	id := requestID(ss.Context())
+	addr, ok := peerAddr(ss.Context())
+	if ok {
+		grpcLog.Debugf("Streaming method %s invoked by %s, request %s",
+			info.FullMethod, addr, id)
+	}
+	defer rpcRequests.observe(info.FullMethod, start)
+	defer recoverRequest(info.FullMethod, id, addr, &err)
+	ss = requestStream{ss, withRequestID(ss.Context(), id)}
+
+	service := serviceName(info.FullMethod)
+	if service != rpcserver.ReflectionServiceName {
+		if err := rpcserver.ServiceReady(service); err != nil {
+			return err
+		}
+	}
+	if service == rpcserver.AdminServiceName {
+		if err := authorizeAdmin(ss.Context()); err != nil {
+			return err
+		}
+	}
+	err = handler(srv, ss)
+	if err != nil && ok {
+		grpcLog.Debugf("Streaming method %s invoked by %s, request %s, "+
+			"errored: %v", info.FullMethod, addr, id, err)
+	}
+	return err
+}
+
 type listenFunc func(net string, laddr string) (net.Listener, error)
 
 // makeListeners splits the normalized listen addresses into IPv4 and IPv6
@@ -7,53 +7,136 @@ package shuffle
 
 import (
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
 )
 
+// maxLength is the maximum number of elements that can be shuffled.
+const maxLength = 1<<31 - 1 - 1
+
+// ErrInvalidLength is returned when the number of elements to shuffle is
+// negative or too large.
+var ErrInvalidLength = errors.New("invalid number of elements to shuffle")
+
+// Permutation maps original indexes of shuffled elements to their
+// positions after the shuffle.
+type Permutation []int
+
+// Get returns the position of the element with the original index after
+// the shuffle.
+func (p Permutation) Get(index int) int {
+	return p[index]
+}
+
+// Apply replaces every original index in the list with the position of
+// the element after the shuffle.
+func (p Permutation) Apply(indexes []int) {
+	for i, idx := range indexes {
+		indexes[i] = p[idx]
+	}
+}
+
+// Invert returns the permutation mapping positions after the shuffle to
+// the original indexes of elements.
+func (p Permutation) Invert() Permutation {
+	inv := make(Permutation, len(p))
+	for i, pos := range p {
+		inv[pos] = i
+	}
+	return inv
+}
+
+// ShuffleMap describes the permutation created by Shuffle.
 type ShuffleMap struct {
-	perm []int // permutation map
+	perm Permutation // permutation map
 }
 
 // Shuffle pseudo-randomizes the order of elements.
-// n is the number of elements. Shuffle panics if n is negative or too large.
+// n is the number of elements. Shuffle panics if n is negative or too
+// large, or if the random source fails.
 // swap swaps the elements with indexes i and j.
+//
+// Deprecated: ShuffleSecure reports failures of the random source instead.
 func Shuffle(random io.Reader, n int, swap func(i, j int)) *ShuffleMap {
-	if n < 0 || n > (1<<31-1-1) {
-		panic("invalid argument to Shuffle")
+	perm, err := ShuffleSecure(random, n, swap)
+	if err != nil {
+		panic("invalid argument to Shuffle: " + err.Error())
+	}
+	return &ShuffleMap{perm}
+}
+
+// Get returns the position of the element with the original index after
+// the shuffle.
+func (s *ShuffleMap) Get(index int) int {
+	return s.perm[index]
+}
+
+// Permutation returns the permutation created by the shuffle.
+func (s *ShuffleMap) Permutation() Permutation {
+	return s.perm
+}
+
+// ShuffleSecure randomizes the order of n elements with the uniform
+// Fisher-Yates shuffle drawing randomness from the reader, which must be
+// a cryptographically secure source for shuffles hiding secrets. swap
+// swaps the elements with indexes i and j. An error is returned if n is
+// negative or too large, or if the reader fails, in which case elements
+// are left partially shuffled and must not be used.
+func ShuffleSecure(random io.Reader, n int, swap func(i, j int)) (Permutation, error) {
+	if n < 0 || n > maxLength {
+		return nil, ErrInvalidLength
 	}
 
 	idx := make([]int, n)
 	for i := range idx {
 		idx[i] = i
 	}
-	perm := make([]int, n)
+	perm := make(Permutation, n)
 
 	// Fisher-Yates shuffle: https://en.wikipedia.org/wiki/Fisher%E2%80%93Yates_shuffle
 	for i := n - 1; i > 0; i-- {
-		j := int(uniformRandom31(random, int32(i+1)))
+		j, err := uniformRandom31(random, int32(i+1))
+		if err != nil {
+			return nil, err
+		}
 		swap(i, int(j))
 		idx[i], idx[j] = idx[j], idx[i]
 		perm[idx[i]] = i
 	}
-	return &ShuffleMap{perm}
+	if n > 0 {
+		perm[idx[0]] = 0
+	}
+	return perm, nil
 }
 
-func (s *ShuffleMap) Get(index int) int {
-	return s.perm[index]
-}
+// uniformRandom31 returns a uniformly distributed number in [0, n) using
+// Lemire's multiply-and-shift method with rejection sampling of the
+// biased range.
+func uniformRandom31(random io.Reader, n int32) (int32, error) {
+	var buf [4]byte
+	read := func() (uint32, error) {
+		if _, err := io.ReadFull(random, buf[:]); err != nil {
+			return 0, fmt.Errorf("failed to read randomness: %v", err)
+		}
+		return binary.LittleEndian.Uint32(buf[:]), nil
+	}
 
-func uniformRandom31(random io.Reader, n int32) int32 {
-	var v uint32
-	binary.Read(random, binary.LittleEndian, &v)
+	v, err := read()
+	if err != nil {
+		return 0, err
+	}
 	prod := uint64(v) * uint64(n)
 	low := uint32(prod)
 	if low < uint32(n) {
 		thresh := uint32(-n) % uint32(n)
 		for low < thresh {
-			binary.Read(random, binary.LittleEndian, &v)
+			if v, err = read(); err != nil {
+				return 0, err
+			}
 			prod = uint64(v) * uint64(n)
 			low = uint32(prod)
 		}
 	}
-	return int32(prod >> 32)
+	return int32(prod >> 32), nil
 }
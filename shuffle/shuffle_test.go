@@ -116,6 +116,65 @@ func TestSimpleShuffleSort(t *testing.T) {
 	}
 }
 
+// failingReader returns an error once its data is exhausted.
+type failingReader struct {
+	data []byte
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, errors.New("randomness source failed")
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestShuffleSecureReaderFailure(t *testing.T) {
+	// Enough randomness for a single draw followed by a short read.
+	r := &failingReader{data: make([]byte, 6)}
+	_, err := ShuffleSecure(r, 10, func(i, j int) {})
+	if err == nil {
+		t.Fatal("failure of the random source wasn't reported")
+	}
+
+	if _, err = ShuffleSecure(r, -1, func(i, j int) {}); err != ErrInvalidLength {
+		t.Fatalf("negative length: %v", err)
+	}
+}
+
+func TestPermutation(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	a := make([]int, 100)
+	for i := range a {
+		a[i] = i
+	}
+	p, err := ShuffleSecure(r, len(a), func(i, j int) {
+		a[i], a[j] = a[j], a[i]
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inv := p.Invert()
+	for i := range a {
+		if a[p.Get(i)] != i {
+			t.Fatalf("element %d isn't at position %d", i, p.Get(i))
+		}
+		if inv[p[i]] != i {
+			t.Fatalf("inverse doesn't map %d back to %d", p[i], i)
+		}
+	}
+
+	indexes := []int{3, 1, 4, 1, 5}
+	p.Apply(indexes)
+	for i, idx := range []int{3, 1, 4, 1, 5} {
+		if indexes[i] != p[idx] {
+			t.Fatalf("index %d mapped to %d", idx, indexes[i])
+		}
+	}
+}
+
 func TestUniformFactorial(t *testing.T) {
 	r := rand.New(rand.NewSource(10))
 	top := 6
@@ -137,7 +196,11 @@ func TestUniformFactorial(t *testing.T) {
 				fn   func() int
 			}{
 				{name: "uniformRandom31", fn: func() int {
-					return int(uniformRandom31(r, int32(nfact)))
+					v, err := uniformRandom31(r, int32(nfact))
+					if err != nil {
+						t.Fatal(err)
+					}
+					return int(v)
 				}},
 				{name: "Shuffle", fn: func() int {
 					// Generate permutation using Shuffle.
@@ -7,12 +7,18 @@ package main
 import (
 	"context"
 	"os"
+	"path/filepath"
 	"runtime"
 
+	"github.com/decred/tumblebit/contract"
+	"github.com/decred/tumblebit/metrics"
+	"github.com/decred/tumblebit/puzzle"
 	"github.com/decred/tumblebit/rpc/rpcserver"
 	"github.com/decred/tumblebit/tumbler"
 	"github.com/decred/tumblebit/version"
 	"github.com/decred/tumblebit/wallet"
+
+	"google.golang.org/grpc"
 )
 
 var (
@@ -51,11 +57,18 @@ func run(ctx context.Context) error {
 		return err
 	}
 	cfg = tcfg
+	contract.VerboseDiagnostics = cfg.VerboseScripts
 	defer func() {
 		if logRotator != nil {
 			logRotator.Close()
 		}
+		if jsonLog != nil {
+			jsonLog.Close()
+		}
 	}()
+	if cfg.MemProfile != "" {
+		defer writeMemProfile(cfg.MemProfile)
+	}
 
 	// Show version at startup.
 	log.Infof("Version %s (Go version %s)", version.String(), runtime.Version())
@@ -64,71 +77,244 @@ func run(ctx context.Context) error {
 		return ctx.Err()
 	}
 
-	// Connect to the wallet RPC service
-	walletClient, err := startRPCClient(ctx)
+	// Connect to the wallet RPC service. JSON-RPC requests aren't sent
+	// over a persistent connection.
+	var walletClient *grpc.ClientConn
+	var walletJSONRPC *wallet.JSONRPCConfig
+	if cfg.WalletRPC == walletRPCJSON {
+		walletJSONRPC, err = walletJSONRPCConfig()
+	} else {
+		walletClient, err = startRPCClient(ctx)
+	}
 	if err != nil {
 		log.Errorf("Unable to connect to the wallet service: %v", err)
 		return err
 	}
-	defer walletClient.Close()
+	closeWalletClient := func() {
+		if walletClient != nil {
+			walletClient.Close()
+		}
+	}
 
 	if done(ctx) {
+		closeWalletClient()
 		return ctx.Err()
 	}
 
+	// The gap policy has been validated by loadConfig.
+	gapPolicy, _ := wallet.ParseGapPolicy(cfg.GapPolicy)
 	walletCfg := wallet.Config{
 		Account:          cfg.Account,
 		AccountName:      cfg.AccountName,
 		ChainParams:      activeNet.Params,
 		WalletConnection: walletClient,
+		JSONRPC:          walletJSONRPC,
 		WalletPassword:   cfg.WalletPassword,
+		CreateAccount:    cfg.CreateAccount,
+		UnlockAccounts:   cfg.UnlockAccounts,
+		Dial:             startRPCClient,
+		Retries:          cfg.WalletRetries,
+		Backoff:          cfg.WalletBackoff,
+		HealthInterval:   cfg.HealthInterval,
+
+		RebroadcastInterval:    cfg.Rebroadcast,
+		MaxRebroadcastInterval: cfg.MaxRebroadcast,
+
+		Accounts: wallet.Accounts{
+			Funding: cfg.FundingAccount,
+			CashOut: cfg.CashOutAccount,
+			Fee:     cfg.FeeAccount,
+			Refund:  cfg.RefundAccount,
+		},
+		CoinSelection: wallet.CoinSelection{
+			SingleInput:        cfg.SingleInput,
+			AvoidPartialSpends: cfg.NoPartialSpends,
+			MinConf:            cfg.EscrowMinConf,
+		},
+		Addresses: wallet.AddressPolicy{
+			PoolSize:  cfg.AddressPoolSize,
+			GapLimit:  cfg.GapLimit,
+			GapPolicy: gapPolicy,
+		},
+	}
+	if cfg.OfflineSignDir != "" {
+		walletCfg.Signer, err = wallet.NewOfflineSigner(cfg.OfflineSignDir,
+			activeNet.Params)
+		if err != nil {
+			closeWalletClient()
+			log.Errorf("Failed to setup the offline signer: %v", err)
+			return err
+		}
+		log.Infof("Escrow signatures are requested from the offline "+
+			"signer via %s", cfg.OfflineSignDir)
 	}
 
-	// Create a wallet communication object
+	// Create a wallet communication object which takes over the
+	// connection.
 	w, err := wallet.New(ctx, &walletCfg)
 	if err != nil {
+		closeWalletClient()
 		log.Errorf("Failed to communicate with the wallet: %v", err)
 		return err
 	}
+	defer w.Close()
+	if cfg.UnlockAccounts {
+		defer func() {
+			if err := w.LockAccounts(context.Background()); err != nil {
+				log.Warnf("Failed to lock wallet accounts: %v", err)
+			}
+		}()
+	}
 
 	if done(ctx) {
 		return ctx.Err()
 	}
 
+	puzzleScheme, err := puzzle.LookupScheme(cfg.PuzzleScheme)
+	if err != nil {
+		return err
+	}
+	keyProvider, err := cfg.keyProvider()
+	if err != nil {
+		log.Errorf("Unable to open the puzzle key provider: %v", err)
+		return err
+	}
+	defer keyProvider.Close()
+
+	// Open the contract journal.
+	journalDir := filepath.Join(cfg.AppDataDir.Value, activeNet.Params.Name)
+	if err = os.MkdirAll(journalDir, 0700); err != nil {
+		log.Errorf("Failed to create the journal directory: %v", err)
+		return err
+	}
+	journal, err := contract.OpenJournal(filepath.Join(journalDir,
+		defaultJournalFilename), activeNet.Params)
+	if err != nil {
+		log.Errorf("Unable to open the contract journal: %v", err)
+		return err
+	}
+	defer journal.Close()
+
+	// Start serving metrics if requested.
+	var registry *metrics.Registry
+	if cfg.MetricsListen != "" {
+		registry = metrics.NewRegistry()
+		if err = startMetricsServer(ctx, registry); err != nil {
+			log.Errorf("Unable to start the metrics server: %v", err)
+			return err
+		}
+	}
+
+	// Start serving profiling data if requested.
+	if cfg.ProfileListen != "" {
+		if err = startProfileServer(ctx); err != nil {
+			log.Errorf("Unable to start the profile server: %v", err)
+			return err
+		}
+	}
+
+	// Load the identity key signing epoch manifests.
+	identityKey, err := loadIdentityKey()
+	if err != nil {
+		log.Errorf("Unable to load the identity key: %v", err)
+		return err
+	}
+	log.Infof("Identity key %x", identityKey.PublicKey[:])
+
 	tumblerCfg := tumbler.Config{
-		ChainParams:      activeNet.Params,
-		EpochDuration:    cfg.EpochDuration,
-		EpochRenewal:     cfg.EpochRenewal,
-		PuzzleDifficulty: cfg.PuzzleDifficulty,
-		Wallet:           w,
+		ChainParams:          activeNet.Params,
+		EpochDuration:        cfg.EpochDuration,
+		EpochRenewal:         cfg.EpochRenewal,
+		KeyRetention:         cfg.KeyRetention,
+		RelativeLockTime:     cfg.RelativeLockTime,
+		PuzzleDifficulty:     cfg.PuzzleDifficulty,
+		ConfirmationInterval: activeNet.ConfirmationInterval,
+		PuzzleScheme:         puzzleScheme,
+		KeyProvider:          keyProvider,
+		DrainTimeout:         cfg.DrainTimeout,
+		Parallelism:          cfg.Parallelism,
+		EscrowBudget:         int64(cfg.EscrowBudget.Amount),
+		ScriptPruneDepth:     cfg.ScriptPruneDepth,
+		Parameters:           cfg.parameters(),
+		FeePolicy:            cfg.feePolicy(),
+		BatchPolicy:          cfg.batchPolicy(),
+		FeeBump:              cfg.feeBumpPolicy(),
+		SolverPolicy:         cfg.solverPolicy(),
+		Deadlines:            cfg.deadlines(),
+		Wallet:               w,
+		Journal:              journal,
+		Metrics:              registry,
+		IdentityKey:          identityKey,
+	}
+	if jsonLog != nil {
+		tumblerCfg.Events = jsonLog.logEvent
+	}
+	var audit *auditLog
+	if cfg.AuditLog {
+		audit, err = newAuditLog(filepath.Join(journalDir,
+			defaultAuditDirname))
+		if err != nil {
+			log.Errorf("Unable to open the audit log: %v", err)
+			return err
+		}
+		tumblerCfg.Audit = audit.record
 	}
 
 	// Create and start the RPC server to serve client connections.
-	tumblerServer, err := startRPCServer()
+	tumblerServer, certs, err := startRPCServer(registry)
 	if err != nil {
 		log.Errorf("Unable to create a Tumbler server: %v", err)
 		return err
 	}
+	go certs.run(ctx)
 
 	tb := tumbler.NewTumbler(&tumblerCfg)
 
+	// Publish signed epoch manifests if requested.
+	if cfg.ManifestListen != "" {
+		if err = startManifestServer(ctx, tb, audit, certs); err != nil {
+			log.Errorf("Unable to start the manifest server: %v", err)
+			return err
+		}
+	}
+
 	if tumblerServer != nil {
 		// Start tumbler gRPC services.
+		timeouts, err := cfg.rpcTimeouts()
+		if err == nil {
+			err = rpcserver.SetTimeouts(timeouts)
+		}
+		if err != nil {
+			log.Errorf("Invalid RPC timeouts: %v", err)
+			return err
+		}
+		rpcserver.UseCertRotator(certs)
 		rpcserver.StartTumblerService(tumblerServer, tb)
+		if len(cfg.AdminCerts) != 0 {
+			rpcserver.StartAdminService(tumblerServer, tb)
+		}
+		if cfg.RESTListen != "" {
+			if err = startRESTGateway(ctx, certs); err != nil {
+				log.Errorf("Unable to start the REST gateway: %v", err)
+				return err
+			}
+		}
 		defer func() {
 			log.Warn("Stopping gRPC server...")
-			tumblerServer.Stop()
+			tumblerServer.GracefulStop()
 			log.Info("gRPC server shutdown")
 		}()
 	}
 
-	// Fire up the TumbleBit server
+	// Fire up the TumbleBit server.  Active exchanges are drained before
+	// Run returns upon shutdown, so the gRPC server must keep serving
+	// clients until then.
 	err = tb.Run(ctx)
 	switch err {
 	case nil:
 		log.Info("TumbleBit service stopped")
 	case context.Canceled:
-		log.Error("TumbleBit service cancelled")
+		log.Info("TumbleBit service drained")
 	default:
 		log.Errorf("Failed to setup a TumbleBit service: %v", err)
 		return err
@@ -0,0 +1,129 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumbler
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/decred/tumblebit/contract"
+)
+
+// EpochAccount summarizes exchanges of an epoch for the bookkeeping of
+// the operator. Amounts are in atoms.
+type EpochAccount struct {
+	Epoch       int32 // Block height the epoch has started at
+	Escrows     int   // Escrows published for payees
+	Completed   int   // Exchanges finalized successfully
+	Failed      int   // Exchanges finalized due to any other reason
+	EscrowedIn  int64 // Offers of payers cashed out by the tumbler
+	EscrowedOut int64 // Escrows of the tumbler paying payees
+	FeesEarned  int64 // Commissions charged to payers
+	TxFees      int64 // Transaction fees paid by the tumbler
+}
+
+// accountingHeader names the columns of the CSV accounting report.
+var accountingHeader = []string{"epoch", "escrows", "completed", "failed",
+	"escrowed_in", "escrowed_out", "fees_earned", "tx_fees"}
+
+// updateAccount applies the update to the account of the epoch starting
+// at the block height, creating the account if necessary.
+func (tb *Tumbler) updateAccount(epoch int32, update func(a *EpochAccount)) {
+	tb.accountMu.Lock()
+	defer tb.accountMu.Unlock()
+	if tb.accounts == nil {
+		tb.accounts = make(map[int32]*EpochAccount)
+	}
+	a, ok := tb.accounts[epoch]
+	if !ok {
+		a = &EpochAccount{Epoch: epoch}
+		tb.accounts[epoch] = a
+	}
+	update(a)
+}
+
+// Accounting returns accounts of epochs with exchanges since the tumbler
+// has started, oldest epoch first.
+func (tb *Tumbler) Accounting() []EpochAccount {
+	tb.accountMu.Lock()
+	defer tb.accountMu.Unlock()
+	accounts := make([]EpochAccount, 0, len(tb.accounts))
+	for _, a := range tb.accounts {
+		accounts = append(accounts, *a)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].Epoch < accounts[j].Epoch
+	})
+	return accounts
+}
+
+// WriteAccountingCSV writes the accounts as CSV records preceded by a
+// header naming the columns.
+func WriteAccountingCSV(w io.Writer, accounts []EpochAccount) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(accountingHeader); err != nil {
+		return err
+	}
+	for _, a := range accounts {
+		err := cw.Write([]string{
+			strconv.FormatInt(int64(a.Epoch), 10),
+			strconv.Itoa(a.Escrows),
+			strconv.Itoa(a.Completed),
+			strconv.Itoa(a.Failed),
+			strconv.FormatInt(a.EscrowedIn, 10),
+			strconv.FormatInt(a.EscrowedOut, 10),
+			strconv.FormatInt(a.FeesEarned, 10),
+			strconv.FormatInt(a.TxFees, 10),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// accountEscrow accounts the escrow published for the payee.
+func (s *Session) accountEscrow() {
+	txFee, _ := s.contract.EscrowFee()
+	s.tb.updateAccount(s.epoch, func(a *EpochAccount) {
+		a.Escrows++
+		a.EscrowedOut += s.contract.Amount.Atoms()
+		a.TxFees += txFee
+	})
+}
+
+// accountCashOut accounts the offer of the payer cashed out by the
+// fulfilling transaction. The commission of a payment channel is charged
+// for every payment made over it.
+func (s *Session) accountCashOut() {
+	fee := s.contract.Amount - contract.Denomination
+	if s.channel != nil {
+		fee = s.channel.Fee * contract.Amount(s.channel.Payments)
+	}
+	txFee, _ := s.contract.RedeemFee()
+	s.tb.updateAccount(s.epoch, func(a *EpochAccount) {
+		a.EscrowedIn += s.contract.Amount.Atoms()
+		a.FeesEarned += fee.Atoms()
+		a.TxFees += txFee
+	})
+}
+
+// accountExchange accounts the outcome of the exchange. Sessions which
+// haven't joined an epoch are not accounted.
+func (s *Session) accountExchange(reason int) {
+	if s.epoch == 0 {
+		return
+	}
+	s.tb.updateAccount(s.epoch, func(a *EpochAccount) {
+		if reason == ReasonSuccess {
+			a.Completed++
+		} else {
+			a.Failed++
+		}
+	})
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumbler
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/decred/tumblebit/contract"
+)
+
+// SessionInfo describes a session in progress to the operator.
+type SessionInfo struct {
+	Session    string
+	Address    string
+	Epoch      int32
+	State      string
+	Expires    time.Time
+	EscrowHash string
+	Amount     contract.Amount
+}
+
+// SessionFilter selects sessions listed to the operator. Sessions must
+// match all fields that are set.
+type SessionFilter struct {
+	State   string
+	Epoch   int32
+	Address string
+}
+
+// matches returns true if the session satisfies the filter.
+func (f *SessionFilter) matches(s *Session) bool {
+	return (f.State == "" || f.State == stateNames[s.state]) &&
+		(f.Epoch == 0 || f.Epoch == s.epoch) &&
+		(f.Address == "" || f.Address == s.address)
+}
+
+// ListSessions returns sessions in progress matching the filter ordered by
+// their expiration.
+func (tb *Tumbler) ListSessions(f *SessionFilter) []SessionInfo {
+	var sessions []SessionInfo
+	for _, s := range tb.activeSessions() {
+		if s.Finalized() || !f.matches(s) {
+			continue
+		}
+		info := SessionInfo{
+			Session: hex.EncodeToString(s.Cookie[:]),
+			Address: s.address,
+			Epoch:   s.epoch,
+			State:   stateNames[s.state],
+			Expires: s.expire,
+		}
+		if s.contract != nil {
+			info.EscrowHash = txHashString(s.contract.EscrowHash)
+			info.Amount = s.contract.Amount
+		}
+		sessions = append(sessions, info)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Expires.Before(sessions[j].Expires)
+	})
+	return sessions
+}
+
+// KillSession finalizes the session identified by the hex encoded cookie
+// as if it had expired, e.g. to return the escrow of a stuck session to
+// the budget of its epoch. The reason is recorded in the audit trail of
+// the session.
+func (tb *Tumbler) KillSession(ctx context.Context, session, reason string) error {
+	cookie, err := parseCookie(session)
+	if err != nil {
+		return err
+	}
+	s, ok := tb.sessions.lookup(cookie)
+	if !ok || s.Finalized() {
+		return ErrUnknownSession
+	}
+	var details error
+	if reason != "" {
+		details = errors.New(reason)
+	}
+	log.Warnf("Killing session %s on request of the operator", s.String())
+	s.FinalizeExchange(ctx, ReasonKilled, details)
+	return nil
+}
+
+// parseCookie decodes the hex encoded session cookie.
+func parseCookie(session string) ([16]byte, error) {
+	var cookie [16]byte
+	b, err := hex.DecodeString(session)
+	if err != nil || len(b) != len(cookie) {
+		return cookie, ErrUnknownSession
+	}
+	copy(cookie[:], b)
+	return cookie, nil
+}
@@ -0,0 +1,205 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumbler
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/decred/tumblebit/puzzle"
+)
+
+// Fairness tests of sessions can be audited by third parties that haven't
+// taken part in them. Once a test is passed, the tumbler hands a record of
+// it to the audit handler, which publishes it in an append-only log keyed
+// by epoch. Records only contain values produced by the tumbler or
+// disclosed by the client to pass the test, no secrets: the salted
+// commitments of the payee along with their openings and the quotients
+// linking the real puzzles of the Puzzle-Promise protocol, and the index
+// list of fake puzzles of the Puzzle-Solver protocol. They are enough to
+// check that every session was run with the advertised parameters.
+
+// Protocols of audit records.
+const (
+	AuditPuzzlePromise = "puzzle-promise"
+	AuditPuzzleSolver  = "puzzle-solver"
+)
+
+// AuditRecord is the public record of the fairness test of a session.
+type AuditRecord struct {
+	Epoch      int32      `json:"epoch"`
+	Protocol   string     `json:"protocol"`
+	Parameters Parameters `json:"parameters"`
+
+	// Commitments of the payee to the index lists of the Puzzle-Promise
+	// protocol, their opening and the quotients linking the real
+	// puzzles under the puzzle key of the epoch.
+	Commitment  uint32   `json:"commitment,omitempty"`
+	RealSetHash []byte   `json:"real_set_hash,omitempty"`
+	FakeSetHash []byte   `json:"fake_set_hash,omitempty"`
+	Salt        []byte   `json:"salt,omitempty"`
+	RealTxList  []byte   `json:"real_tx_list,omitempty"`
+	FakeTxList  []byte   `json:"fake_tx_list,omitempty"`
+	PuzzleKey   []byte   `json:"puzzle_key,omitempty"`
+	RealPuzzles [][]byte `json:"real_puzzles,omitempty"`
+	Quotients   [][]byte `json:"quotients,omitempty"`
+
+	// Number of puzzles solved in the Puzzle-Solver protocol and the
+	// index list of the fake ones.
+	PuzzleCount    int    `json:"puzzle_count,omitempty"`
+	FakePuzzleList []byte `json:"fake_puzzle_list,omitempty"`
+}
+
+// AuditHandler receives audit records of sessions. It's called
+// synchronously and must not block.
+type AuditHandler func(rec *AuditRecord)
+
+// audit completes the record of the fairness test of the session with
+// its epoch and the parameters of the tumbler and delivers it to the audit
+// handler, if any.
+func (s *Session) audit(rec *AuditRecord) {
+	rec.Epoch = s.epoch
+	rec.Parameters = s.tb.params
+	s.tb.audit(rec)
+}
+
+// auditPromise records the Puzzle-Promise fairness test passed with the
+// disclosure of the payee.
+func (s *Session) auditPromise(cd *TransactionDisclosure, pk *puzzle.PuzzleKey, chain *puzzle.PromiseChain) {
+	if s.tb.audit == nil {
+		return
+	}
+	key, err := puzzle.MarshalPubKey(pk)
+	if err != nil {
+		log.Warnf("Failed to record the audit of %s: %v", s.String(),
+			err)
+		return
+	}
+	s.audit(&AuditRecord{
+		Protocol:    AuditPuzzlePromise,
+		Commitment:  s.commitment,
+		RealSetHash: s.realSetHash,
+		FakeSetHash: s.fakeSetHash,
+		Salt:        cd.Salt,
+		RealTxList:  cd.RealTxList,
+		FakeTxList:  cd.FakeTxList,
+		PuzzleKey:   key,
+		RealPuzzles: chain.Puzzles,
+		Quotients:   chain.Quotients,
+	})
+}
+
+// auditSolver records the Puzzle-Solver fairness test passed with the
+// disclosure of the payer.
+func (s *Session) auditSolver(pd *PuzzleDisclosure) {
+	if s.tb.audit == nil {
+		return
+	}
+	s.audit(&AuditRecord{
+		Protocol:       AuditPuzzleSolver,
+		PuzzleCount:    len(s.puzzles),
+		FakePuzzleList: pd.FakePuzzleList,
+	})
+}
+
+// VerifyAuditRecord makes sure the fairness test of the record was run
+// with the parameters it claims and that they are secure. Parameters of
+// records must be compared with the ones advertised by the tumbler
+// separately.
+func VerifyAuditRecord(rec *AuditRecord) error {
+	p := &rec.Parameters
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	switch rec.Protocol {
+	case AuditPuzzlePromise:
+		return verifyPromiseAudit(rec)
+	case AuditPuzzleSolver:
+		fakeList, err := puzzle.DecodeIndexList(rec.FakePuzzleList)
+		if err != nil {
+			return fmt.Errorf("failed to decode puzzle index list: %v",
+				err)
+		}
+		if rec.PuzzleCount != p.RealPreimageCount+p.FakePreimageCount ||
+			len(fakeList) != p.FakePreimageCount {
+			return ErrParameterMismatch
+		}
+		return checkIndexLists(rec.PuzzleCount, fakeList)
+	}
+	return fmt.Errorf("unknown protocol %q", rec.Protocol)
+}
+
+// verifyPromiseAudit verifies the record of the Puzzle-Promise fairness
+// test.
+func verifyPromiseAudit(rec *AuditRecord) error {
+	p := &rec.Parameters
+	fakeTxList, err := puzzle.DecodeIndexList(rec.FakeTxList)
+	if err != nil {
+		return fmt.Errorf("failed to decode fake tx index list: %v", err)
+	}
+	realTxList, err := puzzle.DecodeIndexList(rec.RealTxList)
+	if err != nil {
+		return fmt.Errorf("failed to decode real tx index list: %v", err)
+	}
+	if len(fakeTxList) != p.FakeTransactionCount ||
+		len(realTxList) != p.RealTransactionCount {
+		return ErrParameterMismatch
+	}
+	err = checkIndexLists(p.RealTransactionCount+p.FakeTransactionCount,
+		fakeTxList, realTxList)
+	if err != nil {
+		return err
+	}
+
+	err = puzzle.IndexCommitment{
+		Version: rec.Commitment,
+		Phase:   puzzle.PhaseFakeTransactions,
+	}.Verify(rec.Salt, fakeTxList, rec.FakeSetHash)
+	if err != nil {
+		return fmt.Errorf("fake set didn't verify: %v", err)
+	}
+	err = puzzle.IndexCommitment{
+		Version: rec.Commitment,
+		Phase:   puzzle.PhaseRealTransactions,
+	}.Verify(rec.Salt, realTxList, rec.RealSetHash)
+	if err != nil {
+		return fmt.Errorf("real set didn't verify: %v", err)
+	}
+
+	pk, err := puzzle.ParsePubKey(rec.PuzzleKey)
+	if err != nil {
+		return fmt.Errorf("malformed puzzle key: %v", err)
+	}
+	if len(rec.RealPuzzles) != len(realTxList) {
+		return ErrParameterMismatch
+	}
+	chain := &puzzle.PromiseChain{
+		Key:       &pk,
+		Puzzles:   rec.RealPuzzles,
+		Quotients: rec.Quotients,
+	}
+	if err = chain.Verify(); err != nil {
+		return fmt.Errorf("failed to verify quotients: %v", err)
+	}
+	return nil
+}
+
+// checkIndexLists makes sure the index lists refer to distinct items of
+// the set of n items.
+func checkIndexLists(n int, lists ...[]int) error {
+	seen := make(map[int]struct{}, n)
+	for _, list := range lists {
+		for _, idx := range list {
+			if idx < 0 || idx >= n {
+				return errors.New("bad index reference")
+			}
+			if _, ok := seen[idx]; ok {
+				return errors.New("duplicate index reference")
+			}
+			seen[idx] = struct{}{}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumbler
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/decred/tumblebit/shuffle"
+)
+
+// BatchPolicy configures batching of cash-out transactions. Publishing
+// every cash-out as soon as possible lets observers correlate payers and
+// payees by timing. When batching is enabled, fulfilling transactions and
+// channel cash-outs are queued and published together in a random order
+// at epoch boundaries.
+type BatchPolicy struct {
+	// Window is the maximum amount of time a cash-out may wait for the
+	// next epoch boundary. Batching is disabled if it's zero.
+	Window time.Duration
+
+	// Jitter spreads publication of transactions in a batch over a
+	// random delay up to the specified duration.
+	Jitter time.Duration
+}
+
+// Enabled returns true if cash-outs are batched.
+func (p *BatchPolicy) Enabled() bool {
+	return p.Window > 0
+}
+
+// Validate makes sure that batching doesn't delay cash-outs past the end
+// of their sessions.
+func (p *BatchPolicy) Validate() error {
+	switch {
+	case p.Window < 0:
+		return errors.New("batching window must not be negative")
+	case p.Jitter < 0:
+		return errors.New("batching jitter must not be negative")
+	case p.Window+p.Jitter > EpochDuration*ConfirmationInterval/2:
+		return errors.New("batching window and jitter must not exceed " +
+			"half of the epoch duration")
+	}
+	return nil
+}
+
+// delay returns the maximum amount of time publication of a cash-out may
+// be postponed.
+func (p *BatchPolicy) delay() time.Duration {
+	if !p.Enabled() {
+		return 0
+	}
+	return p.Window + p.Jitter
+}
+
+// cashOut is a queued publication of cash-out transactions.
+type cashOut struct {
+	session  *Session
+	publish  func(ctx context.Context)
+	deadline time.Time
+}
+
+// batcher holds cash-outs waiting for the next batch.
+type batcher struct {
+	mu    sync.Mutex
+	queue []*cashOut
+	flush chan struct{}
+}
+
+func (b *batcher) add(c *cashOut) {
+	b.mu.Lock()
+	b.queue = append(b.queue, c)
+	b.mu.Unlock()
+}
+
+// take removes and returns all queued cash-outs if the deadline of any of
+// them is before now, or unconditionally if now is zero.
+func (b *batcher) take(now time.Time) []*cashOut {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !now.IsZero() {
+		due := false
+		for _, c := range b.queue {
+			if c.deadline.Before(now) {
+				due = true
+				break
+			}
+		}
+		if !due {
+			return nil
+		}
+	}
+	batch := b.queue
+	b.queue = nil
+	return batch
+}
+
+// epochBoundary requests the queued cash-outs to be published.
+func (b *batcher) epochBoundary() {
+	select {
+	case b.flush <- struct{}{}:
+	default:
+	}
+}
+
+// scheduleCashOut publishes cash-out transactions of the session right
+// away unless batching is enabled, in which case the publication is
+// queued until the next batch. The caller must serialize access to the
+// session.
+func (s *Session) scheduleCashOut(ctx context.Context, publish func(ctx context.Context)) {
+	if s.cashOutQueued {
+		return
+	}
+
+	tb := s.tb
+	deadline := time.Now().Add(tb.batchPolicy.Window)
+	if latest := s.expire.Add(-tb.blocksDuration(2) -
+		tb.batchPolicy.Jitter); latest.Before(deadline) {
+		deadline = latest
+	}
+	if !tb.batchPolicy.Enabled() || tb.Draining() ||
+		deadline.Before(time.Now()) {
+		publish(ctx)
+		return
+	}
+
+	s.cashOutQueued = true
+	tb.batcher.add(&cashOut{
+		session:  s,
+		publish:  publish,
+		deadline: deadline,
+	})
+	log.Debugf("Cash-out queued for %s", s.String())
+}
+
+// cashOutBatcher publishes queued cash-outs at epoch boundaries or once
+// one of them has waited for the duration of the batching window.
+func (tb *Tumbler) cashOutBatcher(ctx context.Context) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	log.Info("Started cash-out batcher coroutine")
+
+	for {
+		var batch []*cashOut
+		select {
+		case <-ctx.Done():
+			log.Debug("Cash-out batcher cancelled")
+			return ctx.Err()
+		case <-tb.batcher.flush:
+			batch = tb.batcher.take(time.Time{})
+		case now := <-ticker.C:
+			batch = tb.batcher.take(now)
+		}
+		if len(batch) > 0 {
+			tb.publishBatch(ctx, batch, tb.batchPolicy.Jitter)
+		}
+	}
+}
+
+// flushCashOuts publishes all queued cash-outs immediately.
+func (tb *Tumbler) flushCashOuts(ctx context.Context) {
+	if batch := tb.batcher.take(time.Time{}); len(batch) > 0 {
+		tb.publishBatch(ctx, batch, 0)
+	}
+}
+
+// publishBatch publishes cash-outs in a random order, each after a random
+// delay up to the jitter.
+func (tb *Tumbler) publishBatch(ctx context.Context, batch []*cashOut, jitter time.Duration) {
+	_, err := shuffle.ShuffleSecure(rand.Reader, len(batch), func(i, j int) {
+		batch[i], batch[j] = batch[j], batch[i]
+	})
+	if err != nil {
+		// The batch is still published, albeit in a predictable order.
+		log.Errorf("Failed to shuffle the batch of cash-outs: %v", err)
+	}
+	delays := make([]time.Duration, len(batch))
+	if jitter > 0 {
+		for i := range delays {
+			d, err := rand.Int(rand.Reader, big.NewInt(int64(jitter)))
+			if err == nil {
+				delays[i] = time.Duration(d.Int64())
+			}
+		}
+		sort.Slice(delays, func(i, j int) bool {
+			return delays[i] < delays[j]
+		})
+	}
+	log.Infof("Publishing a batch of %d cash-outs", len(batch))
+
+	start := time.Now()
+	for i, c := range batch {
+		if wait := delays[i] - time.Since(start); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				// Leave the rest to the next batch.
+				for _, c := range batch[i:] {
+					tb.batcher.add(c)
+				}
+				return
+			}
+		}
+
+		s := c.session
+		// Payment rounds and the drain process must not interfere.
+		for !s.TryLock() {
+			select {
+			case <-time.After(100 * time.Millisecond):
+			case <-ctx.Done():
+				for _, c := range batch[i:] {
+					tb.batcher.add(c)
+				}
+				return
+			}
+		}
+		if !s.Finalized() {
+			c.publish(ctx)
+		}
+		s.Unlock()
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumbler
+
+import (
+	"fmt"
+)
+
+// ValidationCheck identifies the check of the transaction disclosure of
+// the payee that has failed in ValidatePuzzles. Checks are numbered like
+// the ValidationCheck values of the RPC API.
+type ValidationCheck int
+
+const (
+	// CheckIndexLists fails when the index lists can't be decoded.
+	CheckIndexLists ValidationCheck = iota + 1
+
+	// CheckDisclosureSize fails when the sizes of the index lists, the
+	// random pads or the salt don't match the challenge.
+	CheckDisclosureSize
+
+	// CheckPayoutCommitment fails when the pay-out address doesn't open
+	// the commitment made by the payee.
+	CheckPayoutCommitment
+
+	// CheckFakeSetCommitment fails when the fake index list doesn't open
+	// the fake set hash.
+	CheckFakeSetCommitment
+
+	// CheckTxReference fails when an index list refers to transaction
+	// hashes outside the challenge.
+	CheckTxReference
+
+	// CheckFakeTransactions fails when fake transaction hashes don't
+	// match their random pads.
+	CheckFakeTransactions
+
+	// CheckRealSetCommitment fails when the real index list doesn't open
+	// the real set hash.
+	CheckRealSetCommitment
+)
+
+var checkNames = map[ValidationCheck]string{
+	CheckIndexLists:        "index lists",
+	CheckDisclosureSize:    "disclosure size",
+	CheckPayoutCommitment:  "pay-out commitment",
+	CheckFakeSetCommitment: "fake set commitment",
+	CheckTxReference:       "transaction reference",
+	CheckFakeTransactions:  "fake transactions",
+	CheckRealSetCommitment: "real set commitment",
+}
+
+func (c ValidationCheck) String() string {
+	if name, ok := checkNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown check %d", int(c))
+}
+
+// ValidationError is returned by ValidatePuzzles when the transaction
+// disclosure of the payee doesn't verify. It's reported to the payee as
+// the transcript of the failure, so that the payee can tell whether its
+// disclosure or the tumbler is at fault.
+type ValidationError struct {
+	Check ValidationCheck
+	// Indices of the offending transaction hashes of the challenge, if
+	// the check concerns individual transactions.
+	Indices []int
+	Err     error
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Indices) == 0 {
+		return fmt.Sprintf("%s check failed: %v", e.Check, e.Err)
+	}
+	return fmt.Sprintf("%s check failed for transactions %v: %v",
+		e.Check, e.Indices, e.Err)
+}
+
+// validationError returns a ValidationError of the check.
+func validationError(check ValidationCheck, err error, indices ...int) error {
+	return &ValidationError{Check: check, Indices: indices, Err: err}
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumbler
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"github.com/decred/tumblebit/contract"
+)
+
+// ErrCapacityExhausted is returned when escrowing the requested amount
+// would exceed the escrow budget of the current epoch.
+var ErrCapacityExhausted = errors.New("escrow capacity exhausted")
+
+// BalanceReporter is implemented by wallets able to report funds available
+// for escrows.
+type BalanceReporter interface {
+	// SpendableBalance returns the amount of funds that can be used to
+	// fund escrows.
+	SpendableBalance(ctx context.Context) (int64, error)
+}
+
+// escrowBudget limits the amount of funds escrowed within an epoch.
+type escrowBudget struct {
+	limit    int64
+	escrowed int64
+}
+
+// unlimitedBudget is the limit of epochs when neither the budget is
+// configured nor the wallet balance is known.
+const unlimitedBudget = math.MaxInt64
+
+// budgetLimit returns the configured escrow budget of an epoch.
+func (tb *Tumbler) budgetLimit() int64 {
+	if tb.escrowBudget <= 0 {
+		return unlimitedBudget
+	}
+	return tb.escrowBudget
+}
+
+// epochBudget performs the solvency check before an epoch starting at the
+// block height is created and returns its escrow budget. The configured
+// budget is capped by the spendable balance of the wallet, so that the
+// tumbler doesn't agree to escrows it can't fund. The configured budget is
+// used as is if the balance can't be obtained.
+func (tb *Tumbler) epochBudget(ctx context.Context, blockHeight int32) int64 {
+	limit := tb.budgetLimit()
+	if tb.balance == nil {
+		return limit
+	}
+	balance, err := tb.balance.SpendableBalance(ctx)
+	if err != nil {
+		log.Warnf("Failed to obtain the wallet balance for epoch %d: %v",
+			blockHeight, err)
+		return limit
+	}
+	if balance < limit {
+		if limit != unlimitedBudget {
+			log.Warnf("Spendable balance %d doesn't cover the escrow "+
+				"budget %d of epoch %d", balance, limit, blockHeight)
+		}
+		limit = balance
+	}
+	return limit
+}
+
+// reserveEscrow accounts the amount against the escrow budget of the
+// epoch. ErrCapacityExhausted is returned if the budget doesn't allow for
+// it.
+func (tb *Tumbler) reserveEscrow(blockHeight int32, amount contract.Amount) error {
+	tb.epochMu.Lock()
+	defer tb.epochMu.Unlock()
+	for _, e := range tb.epochs {
+		if e.BlockHeight != blockHeight {
+			continue
+		}
+		if amount.Atoms() > e.budget.limit-e.budget.escrowed {
+			return ErrCapacityExhausted
+		}
+		e.budget.escrowed += amount.Atoms()
+		return nil
+	}
+	return ErrEpochNotFound
+}
+
+// releaseEscrow returns the amount of an escrow that won't be published
+// to the budget of the epoch.
+func (tb *Tumbler) releaseEscrow(blockHeight int32, amount contract.Amount) {
+	tb.epochMu.Lock()
+	defer tb.epochMu.Unlock()
+	for _, e := range tb.epochs {
+		if e.BlockHeight == blockHeight {
+			e.budget.escrowed -= amount.Atoms()
+			return
+		}
+	}
+}
+
+// releaseBudget returns the amount of the escrow set up for the session
+// to the budget if the exchange is finalized before the escrow has been
+// published.
+func (s *Session) releaseBudget() {
+	if s.state < StateEscrowComplete || s.state >= StateEscrowPublished {
+		return
+	}
+	s.tb.releaseEscrow(s.epoch, s.contract.Amount)
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumbler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/decred/tumblebit/contract"
+)
+
+// Epochs, locktimes and key retention are expressed in blocks and
+// computed from block heights reported by the wallet. Wall-clock
+// deadlines, such as session expiration, are estimated from the target
+// block time of the network.
+
+// minBlockInterval is the shortest time between blocks assumed when
+// estimating deadlines. Simulation networks target very short block times
+// but produce blocks on demand.
+const minBlockInterval = time.Minute
+
+// blockInterval returns the expected time between two consecutive blocks.
+// The configured confirmation interval takes precedence over the target
+// block time of the network.
+func (tb *Tumbler) blockInterval() time.Duration {
+	if tb.blockTime > 0 {
+		return tb.blockTime
+	}
+	interval := ConfirmationInterval
+	if tb.chainParams != nil && tb.chainParams.TargetTimePerBlock > 0 {
+		interval = tb.chainParams.TargetTimePerBlock
+	}
+	if interval < minBlockInterval {
+		interval = minBlockInterval
+	}
+	return interval
+}
+
+// blocksDuration returns the expected amount of time it takes to attach
+// the specified number of blocks to the blockchain.
+func (tb *Tumbler) blocksDuration(blocks int32) time.Duration {
+	return time.Duration(blocks) * tb.blockInterval()
+}
+
+// lockTime returns the block height after which escrows of contracts
+// made in the epoch may be refunded. With relative locktimes escrows may
+// be refunded once they have been confirmed for an epoch duration
+// regardless of the epoch.
+func (tb *Tumbler) lockTime(epoch int32) int32 {
+	if tb.lockType == contract.RelativeLock {
+		return tb.epochDuration
+	}
+	return epoch + tb.epochDuration
+}
+
+// bestHeight returns the most recent block height known to the tumbler.
+func (tb *Tumbler) bestHeight() int32 {
+	return atomic.LoadInt32(&tb.height)
+}
+
+// setBestHeight records the block height unless a higher one is known.
+func (tb *Tumbler) setBestHeight(height int32) {
+	for {
+		old := atomic.LoadInt32(&tb.height)
+		if height <= old ||
+			atomic.CompareAndSwapInt32(&tb.height, old, height) {
+			return
+		}
+	}
+}
+
+// currentHeight fetches the height of the main chain tip from the wallet.
+func (tb *Tumbler) currentHeight(ctx context.Context) (int32, error) {
+	blockHeight, err := tb.wallet.CurrentBlockHeight(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("Wallet failure: %v", err)
+	}
+	if blockHeight > math.MaxInt32 {
+		return 0, fmt.Errorf("Block height is too large: %d", blockHeight)
+	}
+	tb.setBestHeight(int32(blockHeight))
+	return int32(blockHeight), nil
+}
@@ -9,16 +9,26 @@ const (
 	// the period within which Escrow, Payment and Chash-Out phases of
 	// the TumbleBit protocol take place. Incidentally, it also specifies
 	// for how long tumbler's funds are escrowed and when it can post a
-	// redeeming transaction to reclaim those funds.
+	// redeeming transaction to reclaim those funds. Expressed in a
+	// number of blocks.
 	EpochDuration = 10
 
 	// EpochRenewal defines an interval between two consecutive epochs
 	// expressed in a number of blocks.
 	EpochRenewal = EpochDuration / 2
 
-	// PuzzleDifficulty determines Tumbler's RSA group size.
-	// Perhaps should be made more generic and expressed in terms of O(2^n)
-	// complexity, where n is 128, 192 or 256 "bits of security".
+	// KeyRetention defines for how many blocks puzzle keys are retained
+	// after their epoch expires, allowing late cash-outs to complete.
+	KeyRetention = EpochRenewal
+
+	// NextEpochWindow defines how many blocks ahead of the next epoch
+	// escrows requesting it are asked to wait for it rather than being
+	// bound to the current epoch about to be superseded.
+	NextEpochWindow = 1
+
+	// PuzzleDifficulty determines Tumbler's RSA group size. Operators may
+	// select it in terms of bits of security with difficulty profiles of
+	// the puzzle package.
 	PuzzleDifficulty = 2048
 
 	// RealTransactionCount specifies a number of real transactions that
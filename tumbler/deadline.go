@@ -0,0 +1,176 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumbler
+
+import (
+	"errors"
+	"time"
+)
+
+// Stages of the exchange subject to separate deadlines.
+const (
+	stageNone = iota
+	stageEscrowSetup
+	stagePromiseValidation
+	stageOfferConfirmation
+)
+
+// stageReasons are finalization reasons reported when sessions exceed the
+// deadline of the stage.
+var stageReasons = [...]int{
+	stageNone:              ReasonSessionExpired,
+	stageEscrowSetup:       ReasonEscrowSetupTimeout,
+	stagePromiseValidation: ReasonPromiseValidationTimeout,
+	stageOfferConfirmation: ReasonOfferConfirmationTimeout,
+}
+
+// stateStage returns the stage the state of the exchange belongs to.
+func stateStage(state int) int {
+	switch state {
+	case StateEscrowComplete, StatePuzzlesPromised, StatePuzzlesValidated:
+		return stageEscrowSetup
+	case StateSolutionsPromised, StateSolutionsValidated:
+		return stagePromiseValidation
+	case StateOfferReceived:
+		return stageOfferConfirmation
+	}
+	return stageNone
+}
+
+// Deadlines limit the time sessions may spend in the stages of the
+// exchange. A stage begins when the session enters its first state and
+// sessions exceeding its deadline are finalized with the reason specific
+// to the stage. Zero durations select the defaults.
+//
+// Payment channels go through the stages on every payment round, their
+// lifetime is limited separately by the cash-out deadline.
+type Deadlines struct {
+	// Session limits the lifetime of a session regardless of its state.
+	// Defaults to an epoch duration and a block.
+	Session time.Duration
+
+	// EscrowSetup limits the time the payee has to validate puzzle
+	// promises once the tumbler has agreed to escrow funds. Unlimited
+	// by default.
+	EscrowSetup time.Duration
+
+	// PromiseValidation limits the time the payer has to validate
+	// solution promises and make a payment offer. Unlimited by default.
+	PromiseValidation time.Duration
+
+	// OfferConfirmation limits the time the escrow of the payment offer
+	// has to be confirmed. Defaults to three blocks.
+	OfferConfirmation time.Duration
+
+	// CashOut limits the time payment channels stay open once
+	// established. Channels are cashed out ahead of the deadline, the
+	// ones still open afterwards are finalized. Unlimited by default.
+	CashOut time.Duration
+
+	// ClientDelay is the longest random delay clients are expected to
+	// pause for before a request to hide the timing of the exchange.
+	// The escrow setup and promise validation deadlines are extended by
+	// the delay of every request the client makes during the stage.
+	ClientDelay time.Duration
+}
+
+// stageRequests is the number of requests the client makes during the
+// stage, each of which may be delayed by the client.
+var stageRequests = [...]int{
+	stageEscrowSetup:       2, // GetPuzzlePromises, FinalizeEscrow
+	stagePromiseValidation: 2, // ValidateSolutions, PaymentOffer
+}
+
+// Validate makes sure no deadline is negative.
+func (d *Deadlines) Validate() error {
+	if d.Session < 0 || d.EscrowSetup < 0 || d.PromiseValidation < 0 ||
+		d.OfferConfirmation < 0 || d.CashOut < 0 || d.ClientDelay < 0 {
+		return errors.New("deadlines must not be negative")
+	}
+	return nil
+}
+
+// sessionTimeout returns the lifetime of new sessions.
+func (tb *Tumbler) sessionTimeout() time.Duration {
+	if tb.deadlines.Session != 0 {
+		return tb.deadlines.Session
+	}
+	return tb.blocksDuration(tb.epochDuration + 1)
+}
+
+// stageTimeout returns the time sessions may spend in the stage, or zero
+// if only the session lifetime applies.
+func (tb *Tumbler) stageTimeout(stage int) time.Duration {
+	switch stage {
+	case stageEscrowSetup:
+		return tb.patience(stage, tb.deadlines.EscrowSetup)
+	case stagePromiseValidation:
+		return tb.patience(stage, tb.deadlines.PromiseValidation)
+	case stageOfferConfirmation:
+		if tb.deadlines.OfferConfirmation != 0 {
+			return tb.deadlines.OfferConfirmation
+		}
+		return tb.blocksDuration(3)
+	}
+	return 0
+}
+
+// patience extends the timeout of the stage by the delays clients may
+// pause for before their requests during the stage. Disabled timeouts
+// stay disabled.
+func (tb *Tumbler) patience(stage int, timeout time.Duration) time.Duration {
+	if timeout == 0 {
+		return 0
+	}
+	return timeout + time.Duration(stageRequests[stage])*
+		tb.deadlines.ClientDelay
+}
+
+// enterStage schedules the deadline of the stage the session enters with
+// the transition to the state, replacing the deadline of the previous
+// stage.
+func (s *Session) enterStage(state int) {
+	stage := stateStage(state)
+	if stage == stateStage(s.state) {
+		return
+	}
+	if s.stageTimer != nil {
+		s.tb.sched.remove(s.stageTimer)
+		s.stageTimer = nil
+	}
+	timeout := s.tb.stageTimeout(stage)
+	if timeout == 0 {
+		return
+	}
+	until := time.Now().Add(timeout)
+	if !until.Before(s.expire) {
+		// The session expires first.
+		return
+	}
+	s.stageTimer = &timer{
+		until:   until,
+		session: s,
+		reason:  stageReasons[stage],
+	}
+	s.tb.sched.add(s.stageTimer)
+}
+
+// limitChannel shortens the lifetime of the session establishing a
+// payment channel to the cash-out deadline.
+func (s *Session) limitChannel() {
+	if s.tb.deadlines.CashOut == 0 {
+		return
+	}
+	until := time.Now().Add(s.tb.deadlines.CashOut)
+	if !until.Before(s.expire) {
+		return
+	}
+	s.expire = until
+	s.tb.sched.add(&timer{
+		until:   until,
+		session: s,
+		reason:  ReasonCashOutTimeout,
+	})
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumbler
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// ErrEscrowSpent is returned when the escrow of the payment offer has been
+// spent by a transaction other than the one fulfilling the offer, e.g. the
+// refund published by the client.
+var ErrEscrowSpent = errors.New("offer escrow has been spent")
+
+// maxAlerts limits the number of alerts retained for operators.
+const maxAlerts = 100
+
+// SpendMonitor is implemented by wallets able to look up spends of
+// transaction outputs.
+type SpendMonitor interface {
+	// OutputSpent returns true if the output of the transaction
+	// identified by the hash has been spent.
+	OutputSpent(ctx context.Context, txHash []byte, index uint32) (bool, error)
+}
+
+// Alert describes an event requiring attention of the operator.
+type Alert struct {
+	Time       time.Time
+	Session    string
+	Address    string
+	Epoch      int32
+	EscrowHash string
+	Message    string
+}
+
+// raiseAlert logs the alert and retains it for the AdminService.
+func (tb *Tumbler) raiseAlert(a *Alert) {
+	log.Errorf("ALERT: %s (session %s, escrow %s)", a.Message, a.Session,
+		a.EscrowHash)
+
+	tb.alertMu.Lock()
+	tb.alerts = append(tb.alerts, a)
+	if len(tb.alerts) > maxAlerts {
+		tb.alerts = tb.alerts[len(tb.alerts)-maxAlerts:]
+	}
+	tb.alertMu.Unlock()
+}
+
+// Alerts returns alerts raised since the tumbler has started, oldest
+// first. Only the most recent ones are retained.
+func (tb *Tumbler) Alerts() []Alert {
+	tb.alertMu.Lock()
+	defer tb.alertMu.Unlock()
+	alerts := make([]Alert, len(tb.alerts))
+	for i, a := range tb.alerts {
+		alerts[i] = *a
+	}
+	return alerts
+}
+
+// checkOfferEscrow makes sure the escrow of the payment offer hasn't been
+// spent before the tumbler cashes it out. ErrEscrowSpent is returned and
+// an alert is raised otherwise. Failures to look up the spend are only
+// logged, the cash-out itself reports an unavailable escrow.
+func (s *Session) checkOfferEscrow(ctx context.Context) error {
+	if s.tb.spends == nil || s.offerEscrow == nil {
+		return nil
+	}
+	// Offer escrows pay to the contract with their first output.
+	spent, err := s.tb.spends.OutputSpent(ctx, s.offerEscrow, 0)
+	if err != nil {
+		log.Warnf("Failed to check the offer escrow of %s for spends: %v",
+			s.String(), err)
+		return nil
+	}
+	if !spent {
+		return nil
+	}
+	s.tb.raiseAlert(&Alert{
+		Time:       time.Now(),
+		Session:    hex.EncodeToString(s.Cookie[:]),
+		Address:    s.address,
+		Epoch:      s.epoch,
+		EscrowHash: txHashString(s.offerEscrow),
+		Message:    "offer escrow spent before the cash-out",
+	})
+	return ErrEscrowSpent
+}
+
+// watchOfferEscrow starts monitoring the escrow of the payment offer for
+// spends. The session is aborted as soon as the escrow is spent.
+func (s *Session) watchOfferEscrow(escrowHash []byte) {
+	if s.tb.spends == nil || s.offerEscrow != nil {
+		return
+	}
+	s.offerEscrow = escrowHash
+	s.tb.WatchConfirmations(s, offerEscrowWatch, nil)
+}
+
+// offerEscrowWatch checks the offer escrow for spends with every new block
+// until the session is finalized.
+func offerEscrowWatch(ctx context.Context, s *Session, arg interface{}) {
+	if s.Finalized() {
+		return
+	}
+	// Cash-outs and payment rounds in progress are left to complete,
+	// the escrow is checked again with the next block.
+	if !s.TryLock() {
+		s.tb.WatchConfirmations(s, offerEscrowWatch, nil)
+		return
+	}
+	defer s.Unlock()
+	if s.Finalized() {
+		return
+	}
+	if err := s.checkOfferEscrow(ctx); err != nil {
+		s.err = err
+		s.FinalizeExchange(ctx, ReasonFailedExchange, nil)
+		return
+	}
+	s.tb.WatchConfirmations(s, offerEscrowWatch, nil)
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumbler
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/tumblebit/contract"
+)
+
+// ErrUnknownSession is returned when there's no record of the requested
+// session.
+var ErrUnknownSession = errors.New("unknown session")
+
+// SessionEvent is a structured record of a state transition or the
+// finalization of an exchange meant for machine consumption.
+type SessionEvent struct {
+	Time       time.Time `json:"time"`
+	Level      string    `json:"level"`
+	Session    string    `json:"session"`
+	Address    string    `json:"address,omitempty"`
+	Epoch      int32     `json:"epoch,omitempty"`
+	State      string    `json:"state"`
+	EscrowHash string    `json:"escrow_hash,omitempty"`
+	RedeemHash string    `json:"redeem_hash,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Request    string    `json:"request,omitempty"`
+}
+
+// EventHandler receives session events. It's called synchronously and
+// must not block.
+type EventHandler func(ev *SessionEvent)
+
+// txHashString returns the hash of a transaction in its usual byte-reversed
+// encoding or an empty string if it's not known.
+func txHashString(b []byte) string {
+	hash, err := chainhash.NewHash(b)
+	if err != nil {
+		return ""
+	}
+	return hash.String()
+}
+
+// emitEvent records the event describing the current state of the session
+// in its audit trail and delivers it to the event handler, if any.
+func (s *Session) emitEvent(level string, reason string, err error) {
+	ev := &SessionEvent{
+		Time:    time.Now(),
+		Level:   level,
+		Session: hex.EncodeToString(s.Cookie[:]),
+		Address: s.address,
+		Epoch:   s.epoch,
+		State:   stateNames[s.state],
+		Reason:  reason,
+		Request: s.requestID(),
+	}
+	if s.contract != nil {
+		ev.EscrowHash = txHashString(s.contract.EscrowHash)
+		ev.RedeemHash = txHashString(s.contract.RedeemHash)
+	}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	s.recordHistory(ev)
+	if s.tb.events != nil {
+		s.tb.events(ev)
+	}
+}
+
+// recordHistory appends the event to the audit trail of the session and
+// persists it in the journal. Failures to persist the event are logged but
+// don't interrupt the exchange.
+func (s *Session) recordHistory(ev *SessionEvent) {
+	s.historyMu.Lock()
+	s.history = append(s.history, ev)
+	s.historyMu.Unlock()
+
+	if s.tb.journal == nil {
+		return
+	}
+	entry, err := json.Marshal(ev)
+	if err == nil {
+		err = s.tb.journal.AppendHistory(s.Cookie[:], entry)
+	}
+	if err != nil {
+		log.Warnf("Failed to record the history of %s: %v", s.String(),
+			err)
+	}
+}
+
+// SessionHistory returns the audit trail of the session identified by the
+// hex encoded cookie, oldest event first. Histories of finalized sessions
+// are only available if the tumbler keeps a journal.
+func (tb *Tumbler) SessionHistory(session string) ([]SessionEvent, error) {
+	cookie, err := parseCookie(session)
+	if err != nil {
+		return nil, err
+	}
+
+	if s, ok := tb.sessions.lookup(cookie); ok {
+		s.historyMu.Lock()
+		defer s.historyMu.Unlock()
+		history := make([]SessionEvent, len(s.history))
+		for i, ev := range s.history {
+			history[i] = *ev
+		}
+		return history, nil
+	}
+
+	if tb.journal == nil {
+		return nil, ErrUnknownSession
+	}
+	entries, err := tb.journal.History(cookie[:])
+	if err == contract.ErrHistoryNotFound {
+		return nil, ErrUnknownSession
+	}
+	if err != nil {
+		return nil, err
+	}
+	history := make([]SessionEvent, len(entries))
+	for i, entry := range entries {
+		if err = json.Unmarshal(entry, &history[i]); err != nil {
+			return nil, err
+		}
+	}
+	return history, nil
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumbler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/decred/tumblebit/contract"
+)
+
+// MaxFeeRate is the maximum commission rate in percent.
+const MaxFeeRate = 10
+
+// ErrInsufficientFee is returned when the offered amount doesn't cover the
+// denomination along with the tumbler's commission.
+var ErrInsufficientFee = errors.New("offered amount doesn't cover the fee")
+
+// FeePolicy describes the commission the tumbler charges for every payment
+// on top of the contract denomination. It's independent of the fee rate
+// paid to the network by contract transactions.
+type FeePolicy struct {
+	Fixed contract.Amount // Fixed commission
+	Rate  float64         // Commission rate in percent of the payment amount
+}
+
+// Validate makes sure the fee policy doesn't charge negative or
+// unreasonably high commission.
+func (p *FeePolicy) Validate() error {
+	switch {
+	case p.Fixed < 0:
+		return errors.New("fixed fee must not be negative")
+	case p.Fixed >= contract.Denomination:
+		return errors.New("fixed fee must be less than the denomination")
+	case p.Rate < 0 || math.IsNaN(p.Rate):
+		return errors.New("fee rate must not be negative")
+	case p.Rate > MaxFeeRate:
+		return fmt.Errorf("fee rate must not exceed %d%%", MaxFeeRate)
+	}
+	return nil
+}
+
+// Fee returns the commission charged for the payment of the specified
+// amount. The proportional part is rounded up to the nearest atom.
+func (p *FeePolicy) Fee(amount contract.Amount) contract.Amount {
+	return p.Fixed + contract.Amount(math.Ceil(float64(amount)*p.Rate/100))
+}
+
+// checkOfferAmount makes sure that the amount offered by the payer covers
+// the denomination and the commission. It returns the commission paid.
+func (p *FeePolicy) checkOfferAmount(amount contract.Amount) (contract.Amount, error) {
+	fee := p.Fee(contract.Denomination)
+	if amount < contract.Denomination+fee {
+		return 0, ErrInsufficientFee
+	}
+	return amount - contract.Denomination, nil
+}
@@ -0,0 +1,249 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumbler
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/decred/dcrd/dcrutil"
+	"github.com/decred/tumblebit/contract"
+)
+
+// Decred transactions can't be replaced while they are in the mempool,
+// but they may be made to expire at a block height. Transactions
+// fulfilling payment offers are published with an expiry when fee bumping
+// is enabled. Once one expires without being mined, it's rebuilt with a
+// higher fee rate, signed and published again before the payer may
+// refund the offer. Escrows set up by the tumbler and the transactions
+// cashing them out can't be replaced, since the puzzle promises made to
+// the payee commit to their hashes.
+
+const (
+	// FeeBumpFactor is the default factor the fee rate of a fulfilling
+	// transaction is multiplied by when it's replaced.
+	FeeBumpFactor = 2
+
+	// MaxBumpFeeRate is the default limit of the fee rate in atoms per
+	// kilobyte fulfilling transactions are bumped to.
+	MaxBumpFeeRate = 10 * contract.FeePerKb
+)
+
+// FeeBumpPolicy configures replacement of fulfilling transactions which
+// linger unconfirmed.
+type FeeBumpPolicy struct {
+	// Expiry is the number of blocks a fulfilling transaction may wait
+	// to be mined before it's replaced. Fees aren't bumped if it's zero.
+	Expiry int32
+
+	// Factor multiplies the fee rate with every replacement.
+	Factor float64
+
+	// MaxFeeRate limits the fee rate in atoms per kilobyte. Replacements
+	// pay the maximum rate once the refund of the offer is near.
+	MaxFeeRate int64
+}
+
+// Enabled returns true if fulfilling transactions are replaced.
+func (p *FeeBumpPolicy) Enabled() bool {
+	return p.Expiry > 0
+}
+
+// Validate makes sure that fulfilling transactions are replaced with ones
+// paying higher fees well before offers may be refunded.
+func (p *FeeBumpPolicy) Validate() error {
+	switch {
+	case p.Expiry < 0:
+		return errors.New("fee bump expiry must not be negative")
+	case !p.Enabled():
+		return nil
+	case p.Expiry > EpochDuration/4:
+		return errors.New("fee bump expiry must not exceed a quarter " +
+			"of the epoch duration")
+	case p.Factor <= 1 || math.IsNaN(p.Factor):
+		return errors.New("fee bump factor must be greater than one")
+	case p.MaxFeeRate < contract.FeePerKb:
+		return errors.New("maximum fee rate must not be lower than " +
+			"the default fee rate")
+	}
+	return nil
+}
+
+// nextFeeRate returns the fee rate of the replacement of a transaction
+// paying the rate. The maximum rate is paid if the refund is near.
+func (p *FeeBumpPolicy) nextFeeRate(rate int64, urgent bool) int64 {
+	if rate <= 0 {
+		rate = contract.FeePerKb
+	}
+	next := int64(math.Ceil(float64(rate) * p.Factor))
+	if urgent || next > p.MaxFeeRate {
+		next = p.MaxFeeRate
+	}
+	return next
+}
+
+// FeeBumper is implemented by wallets able to report confirmations of
+// fulfilling transactions and the heights offers may be refunded at.
+// Fees are only bumped with such wallets.
+type FeeBumper interface {
+	// Confirmations returns the number of confirmations of the
+	// transaction identified by the hash.
+	Confirmations(ctx context.Context, txHash []byte) (int32, error)
+
+	// RefundHeight returns the block height at which the escrow of the
+	// contract may be refunded.
+	RefundHeight(ctx context.Context, con *contract.Contract) (uint32, error)
+}
+
+// pendingSolution is a published fulfilling transaction awaiting
+// confirmation.
+type pendingSolution struct {
+	con     *contract.Contract
+	secrets [][]byte
+	session string
+	epoch   int32
+}
+
+// solutionTracker holds fulfilling transactions awaiting confirmation
+// keyed by the hash of the escrow they spend. They are only tracked in
+// memory, transactions pending across a restart are no longer replaced.
+type solutionTracker struct {
+	mu      sync.Mutex
+	pending map[string]*pendingSolution
+}
+
+// feeBumping returns true if fulfilling transactions are replaced.
+func (tb *Tumbler) feeBumping() bool {
+	return tb.feeBump.Enabled() && tb.bumper != nil
+}
+
+// solutionExpiry sets the height the fulfilling transaction of the
+// contract expires at if it may be replaced.
+func (tb *Tumbler) solutionExpiry(con *contract.Contract) {
+	if !tb.feeBumping() || tb.bestHeight() == 0 {
+		return
+	}
+	con.RedeemExpiry = uint32(tb.bestHeight() + tb.feeBump.Expiry)
+}
+
+// trackSolution watches the fulfilling transaction published for the
+// session until it's mined.
+func (s *Session) trackSolution(secrets [][]byte) {
+	tb := s.tb
+	if !tb.feeBumping() || s.contract.RedeemExpiry == 0 {
+		return
+	}
+	escrowHash := s.contract.EscrowTx.TxHash()
+	p := &pendingSolution{
+		con:     s.contract,
+		secrets: secrets,
+		session: hex.EncodeToString(s.Cookie[:]),
+		epoch:   s.epoch,
+	}
+	tb.solutions.mu.Lock()
+	if tb.solutions.pending == nil {
+		tb.solutions.pending = make(map[string]*pendingSolution)
+	}
+	tb.solutions.pending[escrowHash.String()] = p
+	tb.solutions.mu.Unlock()
+}
+
+// bumpFees replaces fulfilling transactions that have expired without
+// being mined at the height with ones paying higher fees. Transactions
+// that have been mined are no longer tracked.
+func (tb *Tumbler) bumpFees(ctx context.Context, height int32) {
+	if !tb.feeBumping() {
+		return
+	}
+	tb.solutions.mu.Lock()
+	pending := make(map[string]*pendingSolution, len(tb.solutions.pending))
+	for key, p := range tb.solutions.pending {
+		pending[key] = p
+	}
+	tb.solutions.mu.Unlock()
+
+	for key, p := range pending {
+		done := tb.bumpFee(ctx, p, height)
+		if done {
+			tb.solutions.mu.Lock()
+			delete(tb.solutions.pending, key)
+			tb.solutions.mu.Unlock()
+		}
+	}
+}
+
+// bumpFee replaces the fulfilling transaction if it can no longer be
+// mined in the block following the height. It returns true once the
+// transaction no longer needs to be tracked.
+func (tb *Tumbler) bumpFee(ctx context.Context, p *pendingSolution, height int32) bool {
+	con := p.con
+	confs, err := tb.bumper.Confirmations(ctx, con.RedeemHash)
+	if err != nil {
+		log.Warnf("Failed to check confirmations of the fulfilling tx "+
+			"%s: %v", txHashString(con.RedeemHash), err)
+		return false
+	}
+	if confs > 0 {
+		return true
+	}
+	// Transactions expire in the block at their expiry height.
+	if uint32(height)+1 < con.RedeemExpiry {
+		return false
+	}
+
+	escrowHash := con.EscrowTx.TxHash()
+	urgent := false
+	refundHeight, err := tb.bumper.RefundHeight(ctx, con)
+	switch {
+	case err != nil:
+		log.Warnf("Failed to look up the refund height of escrow %v: %v",
+			escrowHash, err)
+		urgent = true
+	case int64(height) >= int64(refundHeight):
+		tb.raiseAlert(&Alert{
+			Time:       time.Now(),
+			Session:    p.session,
+			Epoch:      p.epoch,
+			EscrowHash: escrowHash.String(),
+			Message: "offer became refundable before the fulfilling " +
+				"tx was mined",
+		})
+		return true
+	case int64(refundHeight)-int64(height) <= 2*int64(tb.feeBump.Expiry):
+		urgent = true
+	}
+
+	oldHash := con.RedeemHash
+	oldFee, _ := con.RedeemFee()
+	oldRate := con.FeeRate
+	con.FeeRate = tb.feeBump.nextFeeRate(con.FeeRate, urgent)
+	con.RedeemExpiry = uint32(height + tb.feeBump.Expiry)
+	err = tb.wallet.PublishSolution(ctx, con, p.secrets)
+	if err != nil {
+		log.Errorf("Failed to replace the fulfilling tx %s of escrow "+
+			"%v: %v", txHashString(oldHash), escrowHash, err)
+		con.FeeRate = oldRate
+		return false
+	}
+	newFee, _ := con.RedeemFee()
+	log.Infof("Replaced the expired fulfilling tx %s of escrow %v with "+
+		"%s paying %v/kB", txHashString(oldHash), escrowHash,
+		txHashString(con.RedeemHash), dcrutil.Amount(con.FeeRate))
+
+	tb.updateAccount(p.epoch, func(a *EpochAccount) {
+		a.TxFees += newFee - oldFee
+	})
+	if tb.journal != nil {
+		if err = tb.journal.Save(con); err != nil {
+			log.Warnf("Failed to journal the contract of escrow %v: %v",
+				escrowHash, err)
+		}
+	}
+	return false
+}
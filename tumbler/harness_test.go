@@ -0,0 +1,768 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumbler
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/agl/ed25519"
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrd/chaincfg/chainec"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrutil"
+	"github.com/decred/tumblebit/contract"
+	"github.com/decred/tumblebit/puzzle"
+)
+
+// mockWallet implements the Wallet interface in memory. Transactions are
+// never constructed, published transactions are merely recorded.
+type mockWallet struct {
+	mu          sync.Mutex
+	chainParams *chaincfg.Params
+	blockHeight uint32
+	confirmed   bool
+	escrows     [][]byte
+	solutions   [][][]byte
+}
+
+func newMockWallet(chainParams *chaincfg.Params) *mockWallet {
+	return &mockWallet{
+		chainParams: chainParams,
+		blockHeight: 1000,
+		confirmed:   true,
+	}
+}
+
+// newTestAddress generates a new secp256k1 key and returns the P2PKH
+// address and the encoded public key.
+func newTestAddress(chainParams *chaincfg.Params) (string, string, error) {
+	priv, _, _, err := chainec.Secp256k1.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	_, pub := chainec.Secp256k1.PrivKeyFromBytes(priv)
+	addr, err := dcrutil.NewAddressSecpPubKey(pub.SerializeCompressed(),
+		chainParams)
+	if err != nil {
+		return "", "", err
+	}
+	return addr.EncodeAddress(), addr.String(), nil
+}
+
+func (w *mockWallet) CurrentBlockHeight(ctx context.Context) (uint32, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.blockHeight, nil
+}
+
+func (w *mockWallet) NotifyBlocks(ctx context.Context, blocks chan<- int32) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (w *mockWallet) GetCashOutAddress(ctx context.Context) (string, string, error) {
+	return newTestAddress(w.chainParams)
+}
+
+func (w *mockWallet) ImportEscrowScript(ctx context.Context, con *contract.Contract) error {
+	return nil
+}
+
+func (w *mockWallet) CreateEscrow(ctx context.Context, con *contract.Contract) error {
+	addr, pkey, err := w.GetCashOutAddress(ctx)
+	if err != nil {
+		return err
+	}
+	if err = con.SetAddress(contract.SenderAddress, addr, pkey); err != nil {
+		return err
+	}
+	if err = con.AddEscrowScript(); err != nil {
+		return err
+	}
+	con.EscrowBytes = chainhash.HashB(con.EscrowScript)
+	return nil
+}
+
+func (w *mockWallet) SignHashes(ctx context.Context, con *contract.Contract, txHashes [][]byte) ([][]byte, []byte, error) {
+	return signChallengeHashes(txHashes)
+}
+
+func (w *mockWallet) ValidateOffer(ctx context.Context, con *contract.Contract, escrowHash []byte) (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.confirmed, nil
+}
+
+func (w *mockWallet) PublishEscrow(ctx context.Context, con *contract.Contract) error {
+	con.EscrowHash = chainhash.HashB(con.EscrowBytes)
+	w.mu.Lock()
+	w.escrows = append(w.escrows, con.EscrowHash)
+	w.mu.Unlock()
+	return nil
+}
+
+func (w *mockWallet) PublishSolution(ctx context.Context, con *contract.Contract, secrets [][]byte) error {
+	w.mu.Lock()
+	w.solutions = append(w.solutions, secrets)
+	w.mu.Unlock()
+	return nil
+}
+
+// TestExchange runs a complete exchange between a payee, a payer and
+// the tumbler backed by a mock wallet: escrow setup, the Puzzle-Promise
+// protocol, the Puzzle-Solver protocol and the cash-out of the solution.
+func TestExchange(t *testing.T) {
+	ctx := context.Background()
+	chainParams := &chaincfg.SimNetParams
+	w := newMockWallet(chainParams)
+
+	dir, err := ioutil.TempDir("", "tumbler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	journal, err := contract.OpenJournal(filepath.Join(dir, "journal.db"),
+		chainParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer journal.Close()
+
+	var events []*SessionEvent
+	tb := NewTumbler(&Config{
+		ChainParams:      chainParams,
+		EpochDuration:    EpochDuration,
+		EpochRenewal:     EpochRenewal,
+		PuzzleDifficulty: PuzzleDifficulty,
+		Wallet:           w,
+		Journal:          journal,
+		Events: func(ev *SessionEvent) {
+			events = append(events, ev)
+		},
+	})
+	if err := tb.createNewEpoch(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Escrow phase
+	payeeAddr, payeePubKey, err := newTestAddress(chainParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payee := NewSession(tb, payeeAddr)
+	escrow, err := payee.SetupEscrow(ctx, &EscrowRequest{
+		Address:   payeeAddr,
+		PublicKey: payeePubKey,
+		Amount:    dcrutil.AtomsPerCoin,
+	})
+	if err != nil {
+		t.Fatalf("failed to setup escrow: %v", err)
+	}
+	if escrow.LockTime != escrow.Epoch+EpochDuration {
+		t.Fatalf("bad escrow locktime %d", escrow.LockTime)
+	}
+
+	// Puzzle-Promise protocol
+	pkey, blinded, inverse := testPuzzlePromise(t, payee)
+	escrowHash, err := payee.FinalizeEscrow(ctx)
+	if err != nil {
+		t.Fatalf("failed to finalize escrow: %v", err)
+	}
+	if len(w.escrows) != 1 || !bytes.Equal(w.escrows[0], escrowHash) {
+		t.Fatal("escrow wasn't published")
+	}
+	if _, ok := tb.Lookup(payee.Token); ok {
+		t.Fatal("payee session wasn't finalized")
+	}
+	// Every state transition and the finalization must be recorded.
+	if len(events) != MaxPayeeState {
+		t.Fatalf("unexpected number of session events: %d", len(events))
+	}
+	if ev := events[len(events)-1]; ev.Reason != reasonNames[ReasonSuccess] ||
+		ev.State != stateNames[StateEscrowPublished] ||
+		ev.EscrowHash != txHashString(escrowHash) {
+		t.Fatalf("unexpected final session event: %+v", ev)
+	}
+	// The audit trail of the finalized session is kept in the journal.
+	history, err := tb.SessionHistory(hex.EncodeToString(payee.Cookie[:]))
+	if err != nil {
+		t.Fatalf("failed to retrieve session history: %v", err)
+	}
+	if len(history) != len(events) ||
+		history[len(history)-1].EscrowHash != events[len(events)-1].EscrowHash {
+		t.Fatalf("unexpected session history: %+v", history)
+	}
+
+	// Puzzle-Solver protocol
+	payerAddr, payerPubKey, err := newTestAddress(chainParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payer := NewSession(tb, payerAddr)
+	sc := newSolverChallenge(t, payer, pkey, blinded, escrow.Epoch)
+	realPzIndexes, err := puzzle.EncodeIndexList(sc.realPzList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = payer.PaymentOffer(ctx, &PaymentOffer{
+		Amount:         dcrutil.AtomsPerCoin,
+		PublicKey:      payerPubKey,
+		EscrowHash:     chainhash.HashB([]byte("offer")),
+		EscrowScript:   []byte("offer script"),
+		EscrowTx:       []byte("offer tx"),
+		Puzzle:         blinded,
+		RealPuzzleList: realPzIndexes,
+		RealFactors:    sc.realFactors,
+	})
+	if err != nil {
+		t.Fatalf("payment offer failed: %v", err)
+	}
+	if payer.state != StateSolutionPublished {
+		t.Fatalf("unexpected payer state %s", stateNames[payer.state])
+	}
+	if len(w.solutions) != 1 {
+		t.Fatal("solution wasn't published")
+	}
+
+	// Cash-out: the payee learns the solution from the blockchain
+	solution := sc.solve(t, pkey, w.solutions[0])
+	unblinded := puzzle.UnblindPuzzle(pkey, solution, inverse)
+	if !bytes.Equal(payee.secrets[0], unblinded) {
+		t.Fatal("puzzle solution didn't unlock the promise")
+	}
+
+	// Both exchanges are accounted in the epoch of the escrow.
+	accounts := tb.Accounting()
+	if len(accounts) != 1 {
+		t.Fatalf("unexpected number of epoch accounts: %d", len(accounts))
+	}
+	if a := accounts[0]; a.Epoch != escrow.Epoch || a.Escrows != 1 ||
+		a.Completed != 2 || a.Failed != 0 ||
+		a.EscrowedOut != dcrutil.AtomsPerCoin ||
+		a.EscrowedIn != dcrutil.AtomsPerCoin || a.FeesEarned != 0 {
+		t.Fatalf("unexpected epoch account: %+v", a)
+	}
+	var csv bytes.Buffer
+	if err := WriteAccountingCSV(&csv, accounts); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(csv.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("unexpected accounting report: %q", csv.String())
+	}
+}
+
+// TestExchangeUnconfirmedOffer makes sure the offer isn't fulfilled until
+// it's confirmed.
+func TestExchangeUnconfirmedOffer(t *testing.T) {
+	ctx := context.Background()
+	chainParams := &chaincfg.SimNetParams
+	w := newMockWallet(chainParams)
+	w.confirmed = false
+
+	tb := NewTumbler(&Config{
+		ChainParams:      chainParams,
+		EpochDuration:    EpochDuration,
+		EpochRenewal:     EpochRenewal,
+		PuzzleDifficulty: PuzzleDifficulty,
+		Wallet:           w,
+	})
+	if err := tb.createNewEpoch(); err != nil {
+		t.Fatal(err)
+	}
+	epoch, err := tb.getCurrentEpoch()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payee := NewSession(tb, "")
+	payee.state = StateEscrowComplete
+	payee.epoch = epoch
+	pkey, blinded, _ := testPuzzlePromise(t, payee)
+
+	payerAddr, payerPubKey, err := newTestAddress(chainParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payer := NewSession(tb, payerAddr)
+	sc := newSolverChallenge(t, payer, pkey, blinded, epoch)
+	realPzIndexes, err := puzzle.EncodeIndexList(sc.realPzList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = payer.PaymentOffer(ctx, &PaymentOffer{
+		Amount:         dcrutil.AtomsPerCoin,
+		PublicKey:      payerPubKey,
+		EscrowHash:     chainhash.HashB([]byte("offer")),
+		EscrowScript:   []byte("offer script"),
+		EscrowTx:       []byte("offer tx"),
+		Puzzle:         blinded,
+		RealPuzzleList: realPzIndexes,
+		RealFactors:    sc.realFactors,
+	})
+	if err != nil {
+		t.Fatalf("payment offer failed: %v", err)
+	}
+	if payer.state != StateOfferReceived || len(w.solutions) != 0 {
+		t.Fatal("unconfirmed offer was fulfilled")
+	}
+
+	// Confirm the offer and deliver a block notification.
+	w.confirmed = true
+	watches := tb.takeWatches()
+	if len(watches) != 1 {
+		t.Fatalf("expected a single confirmation watch, got %d",
+			len(watches))
+	}
+	if err = tb.deferredActions(ctx, watches); err != nil {
+		t.Fatal(err)
+	}
+	if payer.state != StateSolutionPublished || len(w.solutions) != 1 {
+		t.Fatal("confirmed offer wasn't fulfilled")
+	}
+}
+
+// TestRotateEpoch makes sure a rotated epoch gets a fresh puzzle key and
+// address while the key of the previous epoch remains available to
+// sessions bound to it.
+func TestRotateEpoch(t *testing.T) {
+	ctx := context.Background()
+	chainParams := &chaincfg.SimNetParams
+	w := newMockWallet(chainParams)
+
+	tb := NewTumbler(&Config{
+		ChainParams:      chainParams,
+		EpochDuration:    EpochDuration,
+		EpochRenewal:     EpochRenewal,
+		PuzzleDifficulty: PuzzleDifficulty,
+		Wallet:           w,
+	})
+	if err := tb.createNewEpoch(); err != nil {
+		t.Fatal(err)
+	}
+	old, err := tb.getCurrentEpoch()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The block height hasn't changed since the previous epoch.
+	epoch, err := tb.RotateEpoch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if epoch != old+1 {
+		t.Fatalf("expected epoch %d, got %d", old+1, epoch)
+	}
+	if current, _ := tb.getCurrentEpoch(); current != epoch {
+		t.Fatalf("new sessions are bound to epoch %d", current)
+	}
+
+	oldKey, err := tb.getPuzzleKey(old)
+	if err != nil {
+		t.Fatalf("key of the previous epoch is gone: %v", err)
+	}
+	newKey, err := tb.getPuzzleKey(epoch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldPub, err := puzzle.MarshalPubKey(oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newPub, err := puzzle.MarshalPubKey(newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(oldPub, newPub) {
+		t.Fatal("puzzle key wasn't rotated")
+	}
+	if !tb.isValidEpoch(old) {
+		t.Fatal("previous epoch was invalidated")
+	}
+
+	tb.epochMu.RLock()
+	addr := tb.epochs[len(tb.epochs)-1].Address
+	tb.epochMu.RUnlock()
+	if addr == "" {
+		t.Fatal("epoch address wasn't allocated")
+	}
+}
+
+// fundedWallet reports a fixed spendable balance.
+type fundedWallet struct {
+	*mockWallet
+	balance int64
+}
+
+func (w *fundedWallet) SpendableBalance(ctx context.Context) (int64, error) {
+	return w.balance, nil
+}
+
+// TestEscrowBudget makes sure escrows are rejected once the budget of the
+// epoch capped by the spendable balance is exhausted, and that escrows of
+// failed exchanges are returned to the budget.
+func TestEscrowBudget(t *testing.T) {
+	ctx := context.Background()
+	chainParams := &chaincfg.SimNetParams
+	w := &fundedWallet{
+		mockWallet: newMockWallet(chainParams),
+		balance:    2 * dcrutil.AtomsPerCoin,
+	}
+
+	tb := NewTumbler(&Config{
+		ChainParams:      chainParams,
+		EpochDuration:    EpochDuration,
+		EpochRenewal:     EpochRenewal,
+		PuzzleDifficulty: PuzzleDifficulty,
+		EscrowBudget:     3 * dcrutil.AtomsPerCoin,
+		Wallet:           w,
+	})
+	if err := tb.createNewEpoch(); err != nil {
+		t.Fatal(err)
+	}
+
+	setupEscrow := func() (*Session, error) {
+		addr, pubKey, err := newTestAddress(chainParams)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := NewSession(tb, addr)
+		_, err = s.SetupEscrow(ctx, &EscrowRequest{
+			Address:   addr,
+			PublicKey: pubKey,
+			Amount:    dcrutil.AtomsPerCoin,
+		})
+		return s, err
+	}
+
+	first, err := setupEscrow()
+	if err != nil {
+		t.Fatalf("failed to setup escrow: %v", err)
+	}
+	if _, err = setupEscrow(); err != nil {
+		t.Fatalf("failed to setup escrow: %v", err)
+	}
+	if _, err = setupEscrow(); err != ErrCapacityExhausted {
+		t.Fatalf("escrow exceeding the balance was set up: %v", err)
+	}
+
+	first.FinalizeExchange(ctx, ReasonFailedExchange, nil)
+	if _, err = setupEscrow(); err != nil {
+		t.Fatalf("escrow of a failed exchange wasn't released: %v", err)
+	}
+}
+
+// TestKillSession makes sure operators find sessions with filters and that
+// killed sessions return their escrows to the budget.
+func TestKillSession(t *testing.T) {
+	ctx := context.Background()
+	chainParams := &chaincfg.SimNetParams
+	w := newMockWallet(chainParams)
+
+	var last *SessionEvent
+	tb := NewTumbler(&Config{
+		ChainParams:      chainParams,
+		EpochDuration:    EpochDuration,
+		EpochRenewal:     EpochRenewal,
+		PuzzleDifficulty: PuzzleDifficulty,
+		EscrowBudget:     dcrutil.AtomsPerCoin,
+		Wallet:           w,
+		Events: func(ev *SessionEvent) {
+			last = ev
+		},
+	})
+	if err := tb.createNewEpoch(); err != nil {
+		t.Fatal(err)
+	}
+
+	setupEscrow := func() (*Session, error) {
+		addr, pubKey, err := newTestAddress(chainParams)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := NewSession(tb, addr)
+		_, err = s.SetupEscrow(ctx, &EscrowRequest{
+			Address:   addr,
+			PublicKey: pubKey,
+			Amount:    dcrutil.AtomsPerCoin,
+		})
+		return s, err
+	}
+
+	s, err := setupEscrow()
+	if err != nil {
+		t.Fatalf("failed to setup escrow: %v", err)
+	}
+	idle := NewSession(tb, s.address)
+
+	session := hex.EncodeToString(s.Cookie[:])
+	tests := []struct {
+		filter   SessionFilter
+		sessions int
+	}{
+		{SessionFilter{}, 2},
+		{SessionFilter{Address: s.address}, 2},
+		{SessionFilter{State: s.State()}, 1},
+		{SessionFilter{State: s.State(), Epoch: s.epoch}, 1},
+		{SessionFilter{Epoch: s.epoch + 1}, 0},
+		{SessionFilter{Address: "unknown"}, 0},
+	}
+	for i, test := range tests {
+		sessions := tb.ListSessions(&test.filter)
+		if len(sessions) != test.sessions {
+			t.Fatalf("test %d: listed %d sessions, expected %d", i,
+				len(sessions), test.sessions)
+		}
+	}
+	sessions := tb.ListSessions(&SessionFilter{State: s.State()})
+	if sessions[0].Session != session ||
+		sessions[0].Amount != dcrutil.AtomsPerCoin {
+		t.Fatalf("unexpected session %+v", sessions[0])
+	}
+
+	if _, err = setupEscrow(); err != ErrCapacityExhausted {
+		t.Fatalf("escrow exceeding the budget was set up: %v", err)
+	}
+	if err = tb.KillSession(ctx, session, "stuck"); err != nil {
+		t.Fatalf("failed to kill session: %v", err)
+	}
+	if err = tb.KillSession(ctx, session, "stuck"); err != ErrUnknownSession {
+		t.Fatalf("killed session was killed again: %v", err)
+	}
+	if _, ok := tb.Lookup(s.Token); ok {
+		t.Fatal("killed session wasn't finalized")
+	}
+	if last.Reason != reasonNames[ReasonKilled] || last.Error != "stuck" {
+		t.Fatalf("unexpected final session event: %+v", last)
+	}
+	if _, err = setupEscrow(); err != nil {
+		t.Fatalf("escrow of a killed session wasn't released: %v", err)
+	}
+	if len(tb.ListSessions(&SessionFilter{Address: idle.address})) != 1 {
+		t.Fatal("killed session is still listed")
+	}
+}
+
+// spendingWallet reports whether the escrow of the offer has been spent.
+type spendingWallet struct {
+	*mockWallet
+	spent bool
+}
+
+func (w *spendingWallet) OutputSpent(ctx context.Context, txHash []byte, index uint32) (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.spent, nil
+}
+
+// TestOfferEscrowSpent makes sure an offer whose escrow has been refunded
+// by the client is not fulfilled and that an alert is raised.
+func TestOfferEscrowSpent(t *testing.T) {
+	ctx := context.Background()
+	chainParams := &chaincfg.SimNetParams
+	w := &spendingWallet{mockWallet: newMockWallet(chainParams)}
+	w.confirmed = false
+
+	tb := NewTumbler(&Config{
+		ChainParams:      chainParams,
+		EpochDuration:    EpochDuration,
+		EpochRenewal:     EpochRenewal,
+		PuzzleDifficulty: PuzzleDifficulty,
+		Wallet:           w,
+	})
+	if err := tb.createNewEpoch(); err != nil {
+		t.Fatal(err)
+	}
+	epoch, err := tb.getCurrentEpoch()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payee := NewSession(tb, "")
+	payee.state = StateEscrowComplete
+	payee.epoch = epoch
+	pkey, blinded, _ := testPuzzlePromise(t, payee)
+
+	payerAddr, payerPubKey, err := newTestAddress(chainParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payer := NewSession(tb, payerAddr)
+	sc := newSolverChallenge(t, payer, pkey, blinded, epoch)
+	realPzIndexes, err := puzzle.EncodeIndexList(sc.realPzList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = payer.PaymentOffer(ctx, &PaymentOffer{
+		Amount:         dcrutil.AtomsPerCoin,
+		PublicKey:      payerPubKey,
+		EscrowHash:     chainhash.HashB([]byte("offer")),
+		EscrowScript:   []byte("offer script"),
+		EscrowTx:       []byte("offer tx"),
+		Puzzle:         blinded,
+		RealPuzzleList: realPzIndexes,
+		RealFactors:    sc.realFactors,
+	})
+	if err != nil {
+		t.Fatalf("payment offer failed: %v", err)
+	}
+
+	// The offer is confirmed, but the client refunds it before the
+	// tumbler gets to publish the solution.
+	w.confirmed = true
+	w.spent = true
+	if err = tb.deferredActions(ctx, tb.takeWatches()); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.solutions) != 0 {
+		t.Fatal("solution to a spent offer was published")
+	}
+	if !payer.Finalized() || payer.err != ErrEscrowSpent {
+		t.Fatalf("session wasn't aborted: %v", payer.err)
+	}
+	alerts := tb.Alerts()
+	if len(alerts) != 1 || alerts[0].Address != payerAddr {
+		t.Fatalf("expected an alert for the session, got %v", alerts)
+	}
+}
+
+// offlineWallet withholds refund signatures until they're released.
+type offlineWallet struct {
+	*mockWallet
+	signed bool
+}
+
+func (w *offlineWallet) CollectRefund(ctx context.Context, con *contract.Contract) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.signed {
+		return ErrSignaturePending
+	}
+	return nil
+}
+
+// TestPendingRefund makes sure no promises are made while the refund of
+// the escrow awaits an offline signature.
+func TestPendingRefund(t *testing.T) {
+	ctx := context.Background()
+	chainParams := &chaincfg.SimNetParams
+	w := &offlineWallet{mockWallet: newMockWallet(chainParams)}
+
+	tb := NewTumbler(&Config{
+		ChainParams:      chainParams,
+		EpochDuration:    EpochDuration,
+		EpochRenewal:     EpochRenewal,
+		PuzzleDifficulty: PuzzleDifficulty,
+		Wallet:           w,
+	})
+	if err := tb.createNewEpoch(); err != nil {
+		t.Fatal(err)
+	}
+
+	addr, pubKey, err := newTestAddress(chainParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSession(tb, addr)
+	_, err = s.SetupEscrow(ctx, &EscrowRequest{
+		Address:   addr,
+		PublicKey: pubKey,
+		Amount:    dcrutil.AtomsPerCoin,
+	})
+	if err != nil {
+		t.Fatalf("failed to setup escrow: %v", err)
+	}
+
+	hashes := make([][]byte, tb.params.RealTransactionCount+
+		tb.params.FakeTransactionCount)
+	for i := range hashes {
+		hashes[i] = chainhash.HashB([]byte{byte(i)})
+	}
+	if _, _, err = s.SignChallengeHashes(ctx, hashes); err != ErrSignaturePending {
+		t.Fatalf("challenges were signed without a refund: %v", err)
+	}
+	if s.Finalized() || s.state != StateEscrowComplete {
+		t.Fatal("session didn't remain ready for another attempt")
+	}
+
+	w.signed = true
+	if _, _, err = s.SignChallengeHashes(ctx, hashes); err != nil {
+		t.Fatalf("failed to sign challenges: %v", err)
+	}
+}
+
+func TestEpochManifest(t *testing.T) {
+	ctx := context.Background()
+	chainParams := &chaincfg.SimNetParams
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tb := NewTumbler(&Config{
+		ChainParams:      chainParams,
+		EpochDuration:    EpochDuration,
+		EpochRenewal:     EpochRenewal,
+		PuzzleDifficulty: PuzzleDifficulty,
+		Wallet:           newMockWallet(chainParams),
+		IdentityKey:      &IdentityKey{PublicKey: pub, PrivateKey: priv},
+	})
+	if err := tb.createNewEpoch(); err != nil {
+		t.Fatal(err)
+	}
+	epoch, err := tb.getCurrentEpoch()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := tb.EpochManifest(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Epoch != epoch {
+		t.Fatalf("manifest of epoch %d, want %d", m.Epoch, epoch)
+	}
+	keyHash, err := tb.PuzzleKeyHash(epoch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(m.PuzzleKeyHash, keyHash) {
+		t.Fatal("manifest commits to a different puzzle key")
+	}
+	if err = m.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same manifest is served for every request of the epoch.
+	again, err := tb.EpochManifest(ctx, epoch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Address != m.Address || !bytes.Equal(again.Signature, m.Signature) {
+		t.Fatal("epoch manifest changed")
+	}
+
+	forged := *m
+	forged.Address, _, err = newTestAddress(chainParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = forged.Verify(); err != ErrBadManifestSignature {
+		t.Fatalf("forged manifest verified: %v", err)
+	}
+
+	if _, err = tb.EpochManifest(ctx, epoch+1); err != ErrEpochNotFound {
+		t.Fatalf("expected ErrEpochNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumbler
+
+import (
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/tumblebit/contract"
+	"github.com/decred/tumblebit/puzzle"
+)
+
+// Info describes the current epoch schedule and protocol parameters of the
+// tumbler allowing clients to verify their compatibility before engaging
+// in an exchange.
+type Info struct {
+	Epoch                int32
+	NextEpoch            int32
+	LockTime             int32
+	RelativeLockTime     bool
+	Denomination         contract.Amount
+	PuzzleKeyHash        []byte
+	PuzzleDifficulty     int
+	PuzzleSecurity       int
+	PuzzlePrimes         int
+	PuzzleScheme         string
+	FeePerKb             int64
+	Fee                  contract.Amount
+	EpochDuration        int32
+	EpochRenewal         int32
+	RealTransactionCount int
+	FakeTransactionCount int
+	RealPreimageCount    int
+	FakePreimageCount    int
+	WorkBits             int
+}
+
+// Info returns the epoch schedule and protocol parameters. The next epoch
+// height is an estimate since epochs are renewed periodically.
+func (tb *Tumbler) Info() (*Info, error) {
+	epoch, err := tb.getCurrentEpoch()
+	if err != nil {
+		return nil, err
+	}
+	keyHash, err := tb.PuzzleKeyHash(epoch)
+	if err != nil {
+		return nil, err
+	}
+	scheme, err := tb.getPuzzleScheme(epoch)
+	if err != nil {
+		return nil, err
+	}
+	profile := puzzle.ProfileForDifficulty(tb.puzzleDifficulty)
+
+	return &Info{
+		Epoch:                epoch,
+		NextEpoch:            epoch + tb.epochRenewal,
+		LockTime:             tb.lockTime(epoch),
+		RelativeLockTime:     tb.lockType == contract.RelativeLock,
+		Denomination:         contract.Denomination,
+		PuzzleKeyHash:        keyHash,
+		PuzzleDifficulty:     tb.puzzleDifficulty,
+		PuzzleSecurity:       profile.SecurityBits,
+		PuzzlePrimes:         profile.Primes,
+		PuzzleScheme:         scheme.Name(),
+		FeePerKb:             contract.FeePerKb,
+		Fee:                  tb.feePolicy.Fee(contract.Denomination),
+		EpochDuration:        tb.epochDuration,
+		EpochRenewal:         tb.epochRenewal,
+		RealTransactionCount: tb.params.RealTransactionCount,
+		FakeTransactionCount: tb.params.FakeTransactionCount,
+		RealPreimageCount:    tb.params.RealPreimageCount,
+		FakePreimageCount:    tb.params.FakePreimageCount,
+		WorkBits:             tb.solverPolicy.WorkBits,
+	}, nil
+}
+
+// PuzzleKeyHash returns the hash of the public puzzle key of the epoch
+// clients use to make sure all of them are served with the same key.
+func (tb *Tumbler) PuzzleKeyHash(epoch int32) ([]byte, error) {
+	pk, err := tb.getPuzzleKey(epoch)
+	if err != nil {
+		return nil, err
+	}
+	key, err := puzzle.MarshalPubKey(pk)
+	if err != nil {
+		return nil, err
+	}
+	return chainhash.HashB(key), nil
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumbler
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"github.com/agl/ed25519"
+	"github.com/decred/tumblebit/contract"
+)
+
+// manifestTag separates epoch manifests from other messages signed with
+// the identity key.
+const manifestTag = "tumblebit/epoch-manifest"
+
+// ManifestVersion is the version of the serialization of epoch manifests
+// covered by the signature.
+const ManifestVersion = 1
+
+var (
+	// ErrNoIdentityKey is returned when epoch manifests are requested
+	// from a tumbler configured without an identity key.
+	ErrNoIdentityKey = errors.New("tumbler has no identity key")
+
+	// ErrBadManifestSignature is returned when the signature of an epoch
+	// manifest doesn't verify against the identity key.
+	ErrBadManifestSignature = errors.New("invalid epoch manifest signature")
+)
+
+// IdentityKey is the long-term key of the tumbler operator signing epoch
+// manifests.
+type IdentityKey struct {
+	PublicKey  *[ed25519.PublicKeySize]byte
+	PrivateKey *[ed25519.PrivateKeySize]byte
+}
+
+// EpochManifest describes the parameters of an epoch the tumbler commits
+// to by signing them with its identity key. Every client of the epoch must
+// be served the same parameters, so two valid manifests of the same epoch
+// that differ prove that the tumbler has equivocated.
+type EpochManifest struct {
+	Version       uint32
+	Network       string
+	Epoch         int32
+	Denomination  contract.Amount
+	PuzzleKeyHash []byte
+	Address       string
+	IdentityKey   []byte
+	Signature     []byte
+}
+
+// SignedMessage returns the serialization of the manifest covered by the
+// signature.
+func (m *EpochManifest) SignedMessage() ([]byte, error) {
+	if len(m.Network) > math.MaxUint8 || len(m.Address) > math.MaxUint8 ||
+		len(m.PuzzleKeyHash) > math.MaxUint8 {
+		return nil, errors.New("epoch manifest field is too long")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(manifestTag)
+	binary.Write(&buf, binary.LittleEndian, m.Version)
+	buf.WriteByte(byte(len(m.Network)))
+	buf.WriteString(m.Network)
+	binary.Write(&buf, binary.LittleEndian, m.Epoch)
+	binary.Write(&buf, binary.LittleEndian, m.Denomination.Atoms())
+	buf.WriteByte(byte(len(m.PuzzleKeyHash)))
+	buf.Write(m.PuzzleKeyHash)
+	buf.WriteByte(byte(len(m.Address)))
+	buf.WriteString(m.Address)
+	return buf.Bytes(), nil
+}
+
+// Verify makes sure the manifest was signed with its identity key.
+// ErrBadManifestSignature is returned if it wasn't.
+func (m *EpochManifest) Verify() error {
+	if len(m.IdentityKey) != ed25519.PublicKeySize ||
+		len(m.Signature) != ed25519.SignatureSize {
+		return ErrBadManifestSignature
+	}
+	msg, err := m.SignedMessage()
+	if err != nil {
+		return err
+	}
+	var pubKey [ed25519.PublicKeySize]byte
+	var sig [ed25519.SignatureSize]byte
+	copy(pubKey[:], m.IdentityKey)
+	copy(sig[:], m.Signature)
+	if !ed25519.Verify(&pubKey, msg, &sig) {
+		return ErrBadManifestSignature
+	}
+	return nil
+}
+
+// EpochManifest returns the manifest of the epoch signed with the identity
+// key. The cash-out address of the epoch is allocated unless it has been
+// already. Signatures are deterministic, so the same manifest is returned
+// for every request of the epoch. An epoch of zero selects the current
+// epoch.
+func (tb *Tumbler) EpochManifest(ctx context.Context, epoch int32) (*EpochManifest, error) {
+	if tb.identityKey == nil {
+		return nil, ErrNoIdentityKey
+	}
+	if epoch == 0 {
+		current, err := tb.getCurrentEpoch()
+		if err != nil {
+			return nil, err
+		}
+		epoch = current
+	}
+	keyHash, err := tb.PuzzleKeyHash(epoch)
+	if err != nil {
+		return nil, err
+	}
+	address, _, err := tb.getEpochAddress(ctx, epoch)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &EpochManifest{
+		Version:       ManifestVersion,
+		Epoch:         epoch,
+		Denomination:  contract.Denomination,
+		PuzzleKeyHash: keyHash,
+		Address:       address,
+		IdentityKey:   tb.identityKey.PublicKey[:],
+	}
+	if tb.chainParams != nil {
+		m.Network = tb.chainParams.Name
+	}
+	msg, err := m.SignedMessage()
+	if err != nil {
+		return nil, err
+	}
+	m.Signature = ed25519.Sign(tb.identityKey.PrivateKey, msg)[:]
+	return m, nil
+}
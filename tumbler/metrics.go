@@ -0,0 +1,117 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumbler
+
+import (
+	"context"
+	"time"
+
+	"github.com/decred/tumblebit/contract"
+	"github.com/decred/tumblebit/metrics"
+)
+
+// tumblerMetrics holds instrumentation of the tumbler.
+type tumblerMetrics struct {
+	sessions         *metrics.GaugeVec
+	exchanges        *metrics.CounterVec
+	puzzleSolve      *metrics.Histogram
+	solverRejections *metrics.CounterVec
+	walletRequests   *metrics.CounterVec
+	walletErrors     *metrics.CounterVec
+}
+
+func newTumblerMetrics(r *metrics.Registry, tb *Tumbler) *tumblerMetrics {
+	r.NewGaugeFunc("tumbler_epochs", "Number of active epochs",
+		func() float64 {
+			tb.epochMu.RLock()
+			n := len(tb.epochs)
+			tb.epochMu.RUnlock()
+			return float64(n)
+		})
+	return &tumblerMetrics{
+		sessions: r.NewGaugeVec("tumbler_sessions",
+			"Number of active sessions by the state of the exchange",
+			"state"),
+		exchanges: r.NewCounterVec("tumbler_exchanges_total",
+			"Number of finalized exchanges by the reason", "reason"),
+		puzzleSolve: r.NewHistogram("tumbler_puzzle_solve_seconds",
+			"Time spent solving a single puzzle", metrics.DefBuckets),
+		solverRejections: r.NewCounterVec(
+			"tumbler_solver_rejections_total",
+			"Number of rejected requests for solution promises by "+
+				"the reason", "reason"),
+		walletRequests: r.NewCounterVec("tumbler_wallet_requests_total",
+			"Number of wallet requests by the method", "method"),
+		walletErrors: r.NewCounterVec("tumbler_wallet_errors_total",
+			"Number of failed wallet requests by the method", "method"),
+	}
+}
+
+// observeState moves the session between the gauges of the states.
+func (m *tumblerMetrics) observeState(s *Session, from, to int) {
+	m.sessions.With(stateNames[from]).Dec()
+	m.sessions.With(stateNames[to]).Inc()
+}
+
+// meteredWallet counts requests made to the wallet and their failures.
+type meteredWallet struct {
+	Wallet
+	m *tumblerMetrics
+}
+
+func (w *meteredWallet) observe(method string, err error) error {
+	w.m.walletRequests.With(method).Inc()
+	if err != nil && err != context.Canceled && err != ErrSignaturePending {
+		w.m.walletErrors.With(method).Inc()
+	}
+	return err
+}
+
+func (w *meteredWallet) CurrentBlockHeight(ctx context.Context) (uint32, error) {
+	height, err := w.Wallet.CurrentBlockHeight(ctx)
+	return height, w.observe("CurrentBlockHeight", err)
+}
+
+func (w *meteredWallet) NotifyBlocks(ctx context.Context, blocks chan<- int32) error {
+	return w.observe("NotifyBlocks", w.Wallet.NotifyBlocks(ctx, blocks))
+}
+
+func (w *meteredWallet) GetCashOutAddress(ctx context.Context) (string, string, error) {
+	addr, pubKey, err := w.Wallet.GetCashOutAddress(ctx)
+	return addr, pubKey, w.observe("GetCashOutAddress", err)
+}
+
+func (w *meteredWallet) ImportEscrowScript(ctx context.Context, con *contract.Contract) error {
+	return w.observe("ImportEscrowScript",
+		w.Wallet.ImportEscrowScript(ctx, con))
+}
+
+func (w *meteredWallet) CreateEscrow(ctx context.Context, con *contract.Contract) error {
+	return w.observe("CreateEscrow", w.Wallet.CreateEscrow(ctx, con))
+}
+
+func (w *meteredWallet) SignHashes(ctx context.Context, con *contract.Contract, txHashes [][]byte) ([][]byte, []byte, error) {
+	sigs, pubKey, err := w.Wallet.SignHashes(ctx, con, txHashes)
+	return sigs, pubKey, w.observe("SignHashes", err)
+}
+
+func (w *meteredWallet) ValidateOffer(ctx context.Context, con *contract.Contract, escrowHash []byte) (bool, error) {
+	ok, err := w.Wallet.ValidateOffer(ctx, con, escrowHash)
+	return ok, w.observe("ValidateOffer", err)
+}
+
+func (w *meteredWallet) PublishEscrow(ctx context.Context, con *contract.Contract) error {
+	return w.observe("PublishEscrow", w.Wallet.PublishEscrow(ctx, con))
+}
+
+func (w *meteredWallet) PublishSolution(ctx context.Context, con *contract.Contract, secrets [][]byte) error {
+	return w.observe("PublishSolution",
+		w.Wallet.PublishSolution(ctx, con, secrets))
+}
+
+// since returns the number of seconds elapsed since t.
+func since(t time.Time) float64 {
+	return time.Since(t).Seconds()
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumbler
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// WatchConfirmations registers an action to be performed once the next
+// block is attached to the blockchain. Callbacks are expected to check
+// whether transactions they're interested in have received a sufficient
+// number of confirmations and register themselves again otherwise.
+func (tb *Tumbler) WatchConfirmations(s *Session, cb func(ctx context.Context, s *Session, arg interface{}), arg interface{}) {
+	a := deferredAction{
+		session:  s,
+		callback: cb,
+		argument: arg,
+	}
+	tb.watchMu.Lock()
+	if tb.watches == nil {
+		tb.watches = make(map[*Session][]*deferredAction)
+	}
+	tb.watches[s] = append(tb.watches[s], &a)
+	tb.watchMu.Unlock()
+}
+
+// removeWatches removes all confirmation watches registered for the
+// session.
+func (tb *Tumbler) removeWatches(s *Session) {
+	tb.watchMu.Lock()
+	delete(tb.watches, s)
+	tb.watchMu.Unlock()
+}
+
+// takeWatches removes and returns all registered confirmation watches.
+// Watches of every session are returned in the order of registration.
+func (tb *Tumbler) takeWatches() []*deferredAction {
+	tb.watchMu.Lock()
+	var watches []*deferredAction
+	for _, actions := range tb.watches {
+		watches = append(watches, actions...)
+	}
+	tb.watches = nil
+	tb.watchMu.Unlock()
+	return watches
+}
+
+// connectivityMonitor tracks availability of the wallet service reported
+// by the wallet. Requests made by sessions in progress are retried by the
+// wallet while it's unreachable, but no new exchanges are started.
+func (tb *Tumbler) connectivityMonitor(ctx context.Context) error {
+	log.Info("Started wallet connectivity monitor coroutine")
+
+	events := make(chan bool)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- tb.notifier.NotifyConnectivity(ctx, events)
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Debug("Wallet connectivity monitor cancelled")
+			return ctx.Err()
+		case err := <-errc:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// Connectivity reports are advisory, the tumbler keeps
+			// running without them.
+			log.Errorf("Wallet connectivity monitor failed: %v", err)
+			atomic.StoreInt32(&tb.walletDown, 0)
+			return nil
+		case available := <-events:
+			if available {
+				log.Infof("Wallet is available, accepting new " +
+					"exchanges")
+				atomic.StoreInt32(&tb.walletDown, 0)
+			} else {
+				log.Warnf("Wallet is unavailable, rejecting new " +
+					"exchanges")
+				atomic.StoreInt32(&tb.walletDown, 1)
+			}
+		}
+	}
+}
+
+// rebroadcaster lets the wallet publish unconfirmed transactions again.
+// Failures are advisory, the tumbler keeps running without it.
+func (tb *Tumbler) rebroadcaster(ctx context.Context) error {
+	log.Info("Started transaction rebroadcast coroutine")
+	err := tb.broadcaster.Rebroadcast(ctx)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	log.Errorf("Transaction rebroadcast failed: %v", err)
+	return nil
+}
+
+// confirmationMonitor subscribes to block notifications from the wallet
+// and drives registered confirmation watches every time a new block is
+// attached to the blockchain. If the notification stream fails, the
+// subscription is renewed after the expected time between blocks.
+func (tb *Tumbler) confirmationMonitor(ctx context.Context) error {
+	log.Info("Started confirmation monitor coroutine")
+
+	blocks := make(chan int32)
+	errc := make(chan error, 1)
+	subscribe := func() {
+		errc <- tb.wallet.NotifyBlocks(ctx, blocks)
+	}
+	go subscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Debug("Confirmation monitor cancelled")
+			return ctx.Err()
+		case err := <-errc:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Errorf("Block notifications failed: %v", err)
+			time.AfterFunc(tb.blockInterval(), subscribe)
+		case height := <-blocks:
+			tb.blockAttached(height)
+			watches := tb.takeWatches()
+			log.Tracef("Confirmation monitor: block %d, %d watches",
+				height, len(watches))
+			if err := tb.deferredActions(ctx, watches); err != nil {
+				return err
+			}
+			tb.pruneScripts(ctx, height)
+			tb.bumpFees(ctx, height)
+		}
+	}
+}
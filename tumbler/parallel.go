@@ -0,0 +1,18 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumbler
+
+import (
+	"context"
+
+	"github.com/decred/tumblebit/puzzle"
+)
+
+// forEach calls fn for every index in [0, n) using at most tb.parallelism
+// concurrent workers and returns the first error encountered. Remaining
+// work is abandoned as soon as a call fails or the context is cancelled.
+func (tb *Tumbler) forEach(ctx context.Context, n int, fn func(i int) error) error {
+	return puzzle.ForEach(ctx, tb.parallelism, n, fn)
+}
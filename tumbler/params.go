@@ -0,0 +1,124 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumbler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	// MinSecurityBits is the minimum acceptable security level of the
+	// fairness tests: a cheating party must not be able to guess which
+	// items are real with a probability higher than 2^-MinSecurityBits.
+	MinSecurityBits = 80
+
+	// MaxTransactionCount limits the total number of transactions the
+	// tumbler signs during the Puzzle-Promise protocol.
+	MaxTransactionCount = 512
+
+	// MaxPreimageCount limits the total number of puzzles the tumbler
+	// solves during the Puzzle-Solver protocol.
+	MaxPreimageCount = 1024
+
+	// MaxRealPreimageCount limits the number of hash locks in the offer
+	// script so that it doesn't exceed the maximum script element size.
+	MaxRealPreimageCount = 64
+)
+
+// ErrParameterMismatch is returned when the client runs the protocol with
+// parameters different from the ones used by the tumbler.
+var ErrParameterMismatch = errors.New("protocol parameter mismatch")
+
+// Parameters specify the number of real and fake items mixed together
+// during the fairness tests of the Puzzle-Promise and Puzzle-Solver
+// protocols. Clients must use exactly the same values as the tumbler.
+type Parameters struct {
+	RealTransactionCount int `json:"real_transaction_count"`
+	FakeTransactionCount int `json:"fake_transaction_count"`
+	RealPreimageCount    int `json:"real_preimage_count"`
+	FakePreimageCount    int `json:"fake_preimage_count"`
+}
+
+// DefaultParameters returns protocol parameters specified by the package
+// constants.
+func DefaultParameters() Parameters {
+	return Parameters{
+		RealTransactionCount: RealTransactionCount,
+		FakeTransactionCount: FakeTransactionCount,
+		RealPreimageCount:    RealPreimageCount,
+		FakePreimageCount:    FakePreimageCount,
+	}
+}
+
+// securityBits returns the binary logarithm of the number of ways to
+// choose real items out of the whole set, i.e. the security level of
+// the cut-and-choose test.
+func securityBits(real, fake int) float64 {
+	n, _ := math.Lgamma(float64(real + fake + 1))
+	r, _ := math.Lgamma(float64(real + 1))
+	f, _ := math.Lgamma(float64(fake + 1))
+	return (n - r - f) / math.Ln2
+}
+
+// Validate makes sure parameters provide sufficient security and don't
+// let clients exhaust tumbler's resources.
+func (p *Parameters) Validate() error {
+	return p.ValidateWith(MinSecurityBits)
+}
+
+// ValidateWith is like Validate but requires the specified security level
+// in bits instead of MinSecurityBits, so that test networks can run the
+// protocol with fewer items.
+func (p *Parameters) ValidateWith(minSecurityBits int) error {
+	switch {
+	case p.RealTransactionCount < 1 || p.FakeTransactionCount < 1:
+		return errors.New("transaction counts must be positive")
+	case p.FakeTransactionCount < p.RealTransactionCount:
+		return errors.New("fake transaction count must not be less " +
+			"than the real transaction count")
+	case p.RealTransactionCount+p.FakeTransactionCount > MaxTransactionCount:
+		return fmt.Errorf("total transaction count exceeds %d",
+			MaxTransactionCount)
+	case securityBits(p.RealTransactionCount, p.FakeTransactionCount) < float64(minSecurityBits):
+		return fmt.Errorf("transaction counts provide less than %d "+
+			"bits of security", minSecurityBits)
+	case p.RealPreimageCount < 1 || p.FakePreimageCount < 1:
+		return errors.New("preimage counts must be positive")
+	case p.RealPreimageCount > MaxRealPreimageCount:
+		return fmt.Errorf("real preimage count exceeds %d",
+			MaxRealPreimageCount)
+	case p.RealPreimageCount+p.FakePreimageCount > MaxPreimageCount:
+		return fmt.Errorf("total preimage count exceeds %d",
+			MaxPreimageCount)
+	case securityBits(p.RealPreimageCount, p.FakePreimageCount) < float64(minSecurityBits):
+		return fmt.Errorf("preimage counts provide less than %d "+
+			"bits of security", minSecurityBits)
+	}
+	return nil
+}
+
+// CheckTransactionCounts verifies that counts proposed by the client
+// during the escrow setup match the parameters of the tumbler. Zero
+// values indicate that the client hasn't advertised its parameters.
+func (p *Parameters) CheckTransactionCounts(real, fake int) error {
+	if (real != 0 && real != p.RealTransactionCount) ||
+		(fake != 0 && fake != p.FakeTransactionCount) {
+		return ErrParameterMismatch
+	}
+	return nil
+}
+
+// CheckPreimageCounts verifies that counts proposed by the client when
+// requesting solution promises match the parameters of the tumbler. Zero
+// values indicate that the client hasn't advertised its parameters.
+func (p *Parameters) CheckPreimageCounts(real, fake int) error {
+	if (real != 0 && real != p.RealPreimageCount) ||
+		(fake != 0 && fake != p.FakePreimageCount) {
+		return ErrParameterMismatch
+	}
+	return nil
+}
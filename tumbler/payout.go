@@ -0,0 +1,63 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumbler
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+)
+
+// payoutNonceSize is the size of the nonce blinding the commitment to the
+// pay-out address.
+const payoutNonceSize = 32
+
+// ErrPayoutMismatch is returned when the revealed pay-out address doesn't
+// open the commitment made by the payee.
+var ErrPayoutMismatch = errors.New("pay-out address doesn't match the " +
+	"commitment")
+
+// PayoutCommitment returns the blinded hash commitment to the pay-out
+// address, SHA-256 of the nonce followed by the address. Payees identified
+// by a fresh key of the session commit to the address in SetupEscrow and
+// only reveal it when the escrow is finalized.
+func PayoutCommitment(nonce []byte, address string) []byte {
+	h := sha256.New()
+	h.Write(nonce)
+	h.Write([]byte(address))
+	return h.Sum(nil)
+}
+
+// commitPayout records the commitment to the pay-out address of the
+// payee.
+func (s *Session) commitPayout(commitment []byte) error {
+	if len(commitment) == 0 {
+		return nil
+	}
+	if len(commitment) != sha256.Size {
+		return errors.New("bad pay-out address commitment")
+	}
+	s.payoutCommitment = commitment
+	return nil
+}
+
+// revealPayout makes sure the pay-out address opens the commitment made
+// by the payee and records it. Payees that haven't committed to an address
+// don't reveal it.
+func (s *Session) revealPayout(address string, nonce []byte) error {
+	if s.payoutCommitment == nil {
+		return nil
+	}
+	if len(address) == 0 || len(nonce) != payoutNonceSize {
+		return errors.New("pay-out address wasn't revealed")
+	}
+	commitment := PayoutCommitment(nonce, address)
+	if subtle.ConstantTimeCompare(commitment, s.payoutCommitment) != 1 {
+		return ErrPayoutMismatch
+	}
+	s.payoutAddress = address
+	log.Debugf("Pay-out address %s revealed by %s", address, s.String())
+	return nil
+}
@@ -12,21 +12,41 @@ import (
 
 // EscrowRequest asks tumbler to escrow the specified amount redeemable by
 // the owner of the public key in case it obtains a correct puzzle solution.
+// Transaction counts advertise parameters of the Puzzle-Promise protocol
+// used by the client.
 type EscrowRequest struct {
-	Address   string
-	PublicKey string
-	Amount    int64
+	Address              string
+	PublicKey            string
+	Amount               contract.Amount
+	RealTransactionCount int
+	FakeTransactionCount int
+	// Scheme the client commits to transaction index lists with.
+	CommitmentVersion uint32
+	// Commitment to the pay-out address made by payees identified by
+	// a fresh key of the session, see PayoutCommitment.
+	AddressCommitment []byte
+
+	// Whether the escrow should wait for the next epoch if it's about to
+	// be created, see NextEpochWindow.
+	NextEpoch bool
+
+	// Whether promises of the real set are unlocked one payment at a
+	// time, as payees of payment channels need. Quotients of the real
+	// puzzles are then withheld, see puzzle.PromiseChain.Unlock.
+	Incremental bool
 }
 
 // EscrowOffer presents the client with a signed but not published escrow
 // transaction set up for a particular epoch and with a specified locktime.
 type EscrowOffer struct {
-	Epoch        int32
-	LockTime     int32
-	Address      string
-	PublicKey    string
-	EscrowScript []byte
-	EscrowTx     []byte
+	Epoch                int32
+	LockTime             int32
+	Address              string
+	PublicKey            string
+	EscrowScript         []byte
+	EscrowTx             []byte
+	RealTransactionCount int
+	FakeTransactionCount int
 }
 
 // SetupEscrow creates and signs a transaction that escrows tumbler's funds
@@ -37,16 +57,45 @@ func (s *Session) SetupEscrow(ctx context.Context, er *EscrowRequest) (*EscrowOf
 		return nil, err
 	}
 
-	epoch, err := s.tb.getCurrentEpoch()
+	if err := s.tb.params.CheckTransactionCounts(er.RealTransactionCount,
+		er.FakeTransactionCount); err != nil {
+		return nil, err
+	}
+	if er.CommitmentVersion > puzzle.CommitmentV1 {
+		return nil, fmt.Errorf("unsupported commitment version %d",
+			er.CommitmentVersion)
+	}
+	s.commitment = er.CommitmentVersion
+	s.incremental = er.Incremental
+	if err := s.commitPayout(er.AddressCommitment); err != nil {
+		return nil, err
+	}
+
+	epoch, err := s.tb.escrowEpoch(er.NextEpoch)
 	if err != nil {
 		return nil, err
 	}
+	if err = s.tb.bindEpoch(s, epoch); err != nil {
+		return nil, err
+	}
 
 	s.contract, err = contract.New(s.tb.ChainParams(), er.Amount,
-		epoch+s.tb.epochDuration)
+		s.tb.lockTime(epoch))
 	if err != nil {
 		return nil, err
 	}
+	s.contract.LockType = s.tb.lockType
+
+	// Account the escrow against the budget of the epoch, the amount
+	// is returned if the escrow can't be set up.
+	if err = s.tb.reserveEscrow(epoch, er.Amount); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			s.tb.releaseEscrow(epoch, er.Amount)
+		}
+	}()
 
 	if err = s.contract.SetAddress(contract.ReceiverAddress, er.Address,
 		er.PublicKey); err != nil {
@@ -56,25 +105,39 @@ func (s *Session) SetupEscrow(ctx context.Context, er *EscrowRequest) (*EscrowOf
 	if err = s.tb.wallet.CreateEscrow(ctx, s.contract); err != nil {
 		return nil, err
 	}
-	s.epoch = epoch
 
-	s.state = StateEscrowComplete
+	s.setState(StateEscrowComplete)
 	log.Debugf("Escrow setup for %s", s.String())
 
 	return &EscrowOffer{
-		Epoch:        epoch,
-		LockTime:     epoch + s.tb.epochDuration,
-		Address:      s.contract.SenderAddrStr,
-		PublicKey:    s.contract.SenderAddr.EncodeAddress(),
-		EscrowScript: s.contract.EscrowScript,
-		EscrowTx:     s.contract.EscrowBytes,
+		Epoch:                epoch,
+		LockTime:             s.tb.lockTime(epoch),
+		Address:              s.contract.SenderAddrStr,
+		PublicKey:            s.contract.SenderAddr.EncodeAddress(),
+		EscrowScript:         s.contract.EscrowScript,
+		EscrowTx:             s.contract.EscrowBytes,
+		RealTransactionCount: s.tb.params.RealTransactionCount,
+		FakeTransactionCount: s.tb.params.FakeTransactionCount,
 	}, nil
 }
 
 // SignChallengeHashes is a helper function that asks wallet to sign
 // challenge hash values. It's not part of GetPuzzlePromises to make
-// testing feasible.
+// testing feasible. ErrSignaturePending is returned while signatures are
+// created offline, the session remains ready for another attempt.
 func (s *Session) SignChallengeHashes(ctx context.Context, hashes [][]byte) ([][]byte, []byte, error) {
+	if len(hashes) != s.tb.params.RealTransactionCount+
+		s.tb.params.FakeTransactionCount {
+		return nil, nil, ErrParameterMismatch
+	}
+
+	// Nothing is promised until the escrow can be refunded.
+	if s.tb.refunds != nil {
+		if err := s.tb.refunds.CollectRefund(ctx, s.contract); err != nil {
+			return nil, nil, err
+		}
+	}
+
 	signatures, pubKey, err := s.tb.wallet.SignHashes(ctx, s.contract, hashes)
 	if err != nil {
 		return nil, nil, err
@@ -116,11 +179,21 @@ func (s *Session) GetPuzzlePromises(ctx context.Context, cp *SignatureChallenges
 		return nil, err
 	}
 
+	if len(cp.TransactionHashes) != s.tb.params.RealTransactionCount+
+		s.tb.params.FakeTransactionCount ||
+		len(cp.Signatures) != len(cp.TransactionHashes) {
+		return nil, ErrParameterMismatch
+	}
+
 	pk, err := s.tb.getPuzzleKey(s.epoch)
 	if err != nil {
 		return nil, err
 	}
-	key, err := puzzle.MarshalPubKey(&pk)
+	key, err := puzzle.MarshalPubKey(pk)
+	if err != nil {
+		return nil, err
+	}
+	scheme, err := s.tb.getPuzzleScheme(s.epoch)
 	if err != nil {
 		return nil, err
 	}
@@ -128,12 +201,14 @@ func (s *Session) GetPuzzlePromises(ctx context.Context, cp *SignatureChallenges
 	puzzles := make([][]byte, len(cp.Signatures))
 	promises := make([][]byte, len(cp.Signatures))
 	secrets := make([][]byte, len(cp.Signatures))
-	for i := range cp.Signatures {
+	err = s.tb.forEach(ctx, len(cp.Signatures), func(i int) error {
+		var err error
 		puzzles[i], promises[i], secrets[i], err =
-			puzzle.NewPuzzlePromise(&pk, cp.Signatures[i])
-		if err != nil {
-			return nil, err
-		}
+			scheme.NewPuzzlePromise(pk, cp.Signatures[i])
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	s.secrets = secrets
@@ -141,7 +216,7 @@ func (s *Session) GetPuzzlePromises(ctx context.Context, cp *SignatureChallenges
 	s.fakeSetHash = cp.FakeSetHash
 	s.txHashes = cp.TransactionHashes
 
-	s.state = StatePuzzlesPromised
+	s.setState(StatePuzzlesPromised)
 	log.Debugf("Puzzle promises offered to %s", s.String())
 
 	return &SignaturePromises{
@@ -160,6 +235,10 @@ type TransactionDisclosure struct {
 	RealTxList []byte
 	RandomPads [][]byte
 	Salt       []byte
+	// Pay-out address and nonce opening the address commitment of the
+	// payee, if any.
+	PayoutAddress string
+	PayoutNonce   []byte
 }
 
 // TransactionSecrets provides the required proof that tumbler has signed all
@@ -178,8 +257,12 @@ type TransactionSecrets struct {
 // create associated promises showing its fairness.
 //
 // Tumbler also creates a proof that it possesses secrets needed to unlock
-// remaining puzzles by returning quotients of their secrets that can be
-// verified by the client with puzzle.VerifyQuotients.
+// remaining puzzles by returning quotients of their secrets that link the
+// real puzzles into a puzzle.PromiseChain verified by the client. Escrows
+// set up for incremental revelation only return the first quotient, so
+// that every solved puzzle unlocks the next promise of the chain alone.
+//
+// A *ValidationError is returned if the disclosure doesn't verify.
 func (s *Session) ValidatePuzzles(ctx context.Context, cd *TransactionDisclosure) (*TransactionSecrets, error) {
 	if ok, err := s.ready(StatePuzzlesValidated); !ok {
 		return nil, err
@@ -187,14 +270,14 @@ func (s *Session) ValidatePuzzles(ctx context.Context, cd *TransactionDisclosure
 
 	fakeTxList, err := puzzle.DecodeIndexList(cd.FakeTxList)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode fake tx index list: %v",
-			err)
+		return nil, validationError(CheckIndexLists,
+			fmt.Errorf("failed to decode fake tx index list: %v", err))
 	}
 
 	realTxList, err := puzzle.DecodeIndexList(cd.RealTxList)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode real tx index list: %v",
-			err)
+		return nil, validationError(CheckIndexLists,
+			fmt.Errorf("failed to decode real tx index list: %v", err))
 	}
 
 	if (len(fakeTxList) > len(s.txHashes)) ||
@@ -202,7 +285,15 @@ func (s *Session) ValidatePuzzles(ctx context.Context, cd *TransactionDisclosure
 		(len(cd.RandomPads) > len(s.txHashes)) ||
 		(len(fakeTxList) > len(cd.RandomPads)) ||
 		(len(cd.Salt) != 32) {
-		return nil, errors.New("bad input values")
+		return nil, validationError(CheckDisclosureSize,
+			errors.New("bad input values"))
+	}
+	if len(fakeTxList) != s.tb.params.FakeTransactionCount ||
+		len(realTxList) != s.tb.params.RealTransactionCount {
+		return nil, ErrParameterMismatch
+	}
+	if err = s.revealPayout(cd.PayoutAddress, cd.PayoutNonce); err != nil {
+		return nil, validationError(CheckPayoutCommitment, err)
 	}
 
 	pk, err := s.tb.getPuzzleKey(s.epoch)
@@ -211,36 +302,50 @@ func (s *Session) ValidatePuzzles(ctx context.Context, cd *TransactionDisclosure
 			"epoch %d: %v", s.epoch, err)
 	}
 
-	// Verify hash of the fake set
-	fakeSetHash, err := puzzle.HashIndexList(cd.Salt, fakeTxList)
+	// Verify commitment to the fake set
+	err = puzzle.IndexCommitment{
+		Version: s.commitment,
+		Phase:   puzzle.PhaseFakeTransactions,
+	}.Verify(cd.Salt, fakeTxList, s.fakeSetHash)
 	if err != nil {
-		return nil, fmt.Errorf("failed to hash the fake tx list: %v", err)
-	}
-	if !bytes.Equal(fakeSetHash, s.fakeSetHash) {
-		return nil, errors.New("fake set didn't verify")
+		return nil, validationError(CheckFakeSetCommitment,
+			fmt.Errorf("fake set didn't verify: %v", err))
 	}
 
-	// Verify structure of fake transactions
+	// Verify structure of fake transactions, reporting all offending
+	// ones to the client
+	var badRefs, badTxs []int
 	for i, idx := range fakeTxList {
-		if idx > len(s.txHashes) {
-			return nil, errors.New("bad tx reference")
+		if idx < 0 || idx >= len(s.txHashes) {
+			badRefs = append(badRefs, idx)
+			continue
 		}
 		if len(cd.RandomPads[i]) != 32 {
-			return nil, errors.New("bad input values")
+			badTxs = append(badTxs, idx)
+			continue
 		}
 		fkh := puzzle.FakeTxFormat(cd.RandomPads[i])
 		if !bytes.Equal(fkh, s.txHashes[idx]) {
-			return nil, errors.New("fake tx didn't verify")
+			badTxs = append(badTxs, idx)
 		}
 	}
+	if len(badRefs) != 0 {
+		return nil, validationError(CheckTxReference,
+			errors.New("bad tx reference"), badRefs...)
+	}
+	if len(badTxs) != 0 {
+		return nil, validationError(CheckFakeTransactions,
+			errors.New("fake tx didn't verify"), badTxs...)
+	}
 
-	// Verify hash of the real set
-	realSetHash, err := puzzle.HashIndexList(cd.Salt, realTxList)
+	// Verify commitment to the real set
+	err = puzzle.IndexCommitment{
+		Version: s.commitment,
+		Phase:   puzzle.PhaseRealTransactions,
+	}.Verify(cd.Salt, realTxList, s.realSetHash)
 	if err != nil {
-		return nil, fmt.Errorf("failed to hash the real tx list: %v", err)
-	}
-	if !bytes.Equal(realSetHash, s.realSetHash) {
-		return nil, errors.New("real set didn't verify")
+		return nil, validationError(CheckRealSetCommitment,
+			fmt.Errorf("real set didn't verify: %v", err))
 	}
 
 	// Reveal secrets for the fake set
@@ -250,17 +355,26 @@ func (s *Session) ValidatePuzzles(ctx context.Context, cd *TransactionDisclosure
 	}
 
 	// Prepare quotients to verify puzzles for the real set
+	realPuzzles := make([][]byte, len(realTxList))
 	realSecrets := make([][]byte, len(realTxList))
 	for i, idx := range realTxList {
-		if idx > len(s.secrets) {
-			return nil, errors.New("bad tx reference")
+		if idx < 0 || idx >= len(s.secrets) || idx >= len(s.puzzles) {
+			badRefs = append(badRefs, idx)
+			continue
 		}
+		realPuzzles[i] = s.puzzles[idx]
 		realSecrets[i] = s.secrets[idx]
 	}
-	quotients, err := puzzle.Quotients(pk.PublicKey(), realSecrets)
+	if len(badRefs) != 0 {
+		return nil, validationError(CheckTxReference,
+			errors.New("bad tx reference"), badRefs...)
+	}
+	chain, err := puzzle.NewPromiseChain(pk.PublicKey(), realPuzzles,
+		realSecrets)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate quotients: %v", err)
 	}
+	s.auditPromise(cd, pk, chain)
 
 	// Garbage-collect cached puzzles, tx hashes, ets.
 	s.puzzles = nil
@@ -268,9 +382,13 @@ func (s *Session) ValidatePuzzles(ctx context.Context, cd *TransactionDisclosure
 	s.realSetHash = nil
 	s.fakeSetHash = nil
 
-	s.state = StatePuzzlesValidated
+	s.setState(StatePuzzlesValidated)
 	log.Debugf("Promise proof offered to %s", s.String())
 
+	quotients := chain.Quotients
+	if s.incremental {
+		quotients = quotients[:1]
+	}
 	return &TransactionSecrets{
 		Secrets:   fakeSecrets,
 		Quotients: quotients,
@@ -287,7 +405,7 @@ func (s *Session) FinalizeEscrow(ctx context.Context) ([]byte, error) {
 		return nil, fmt.Errorf("failed to publish escrow tx :%v", err)
 	}
 
-	s.state = StateEscrowPublished
+	s.setState(StateEscrowPublished)
 	log.Debugf("Escrow published for %s", s.String())
 	log.Tracef("Escrow %s", s.contract.String())
 
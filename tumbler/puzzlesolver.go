@@ -5,12 +5,12 @@
 package tumbler
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"time"
 
-	"github.com/decred/dcrd/chaincfg/chainhash"
 	"github.com/decred/tumblebit/contract"
 	"github.com/decred/tumblebit/puzzle"
 )
@@ -18,16 +18,28 @@ import (
 // SolutionChallenges requests promises of puzzle solutions in order to
 // establish ability of the tumbler to solve puzzles obtained from the
 // payee.
+// Preimage counts advertise parameters of the Puzzle-Solver protocol used
+// by the client. The hash lock selects the hash function the payment offer
+// locks funds with. Requests starting new sessions are accounted against
+// the quota of the client, identified by its network address, and carry
+// a proof of work if the tumbler requires one.
 type SolutionChallenges struct {
-	Epoch   int32
-	Puzzles [][]byte
+	Epoch             int32
+	Puzzles           [][]byte
+	RealPreimageCount int
+	FakePreimageCount int
+	HashLock          contract.HashLock
+	Client            string
+	Work              []byte
 }
 
 // PurchasePromise contains solution promises that once unlocked will
 // provide solutions to all puzzles specified in the proposal.
 type SolutionPromises struct {
-	Promises  [][]byte
-	KeyHashes [][]byte
+	Promises          [][]byte
+	KeyHashes         [][]byte
+	RealPreimageCount int
+	FakePreimageCount int
 }
 
 // GetSolutionPromises obtains cryptographically concealed puzzle solution
@@ -48,39 +60,89 @@ func (s *Session) GetSolutionPromises(ctx context.Context, sc *SolutionChallenge
 		return nil, err
 	}
 
+	if err := s.tb.params.CheckPreimageCounts(sc.RealPreimageCount,
+		sc.FakePreimageCount); err != nil {
+		return nil, err
+	}
+	if len(sc.Puzzles) != s.tb.params.RealPreimageCount+
+		s.tb.params.FakePreimageCount {
+		return nil, ErrParameterMismatch
+	}
+
+	// All payments made over the channel must belong to the same epoch.
+	if s.channel != nil && sc.Epoch != s.epoch {
+		return nil, fmt.Errorf("epoch %d doesn't match the channel "+
+			"epoch %d", sc.Epoch, s.epoch)
+	}
+	if s.channel == nil && !s.tb.isValidEpoch(sc.Epoch) {
+		return nil, ErrEpochNotFound
+	}
+	if !sc.HashLock.Valid() {
+		return nil, fmt.Errorf("unsupported hash lock %v", sc.HashLock)
+	}
+	if s.channel != nil && sc.HashLock != s.hashLock {
+		return nil, fmt.Errorf("hash lock %v doesn't match the channel "+
+			"hash lock %v", sc.HashLock, s.hashLock)
+	}
+
+	// Rounds of payment channels are backed by a confirmed escrow.
+	if s.channel == nil {
+		if err := s.tb.chargeSolver(sc); err != nil {
+			return nil, err
+		}
+	}
+
+	// Keep the puzzle key from expiring until the exchange is finalized.
+	if err := s.tb.bindEpoch(s, sc.Epoch); err != nil {
+		return nil, err
+	}
 	pk, err := s.tb.getPuzzleKey(sc.Epoch)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := s.tb.solver.acquire(ctx, s.channel != nil); err != nil {
+		return nil, err
+	}
 	solutions := make([][]byte, len(sc.Puzzles))
 	promises := make([][]byte, len(sc.Puzzles))
 	secrets := make([][]byte, len(sc.Puzzles))
-	for i, p := range sc.Puzzles {
+	err = s.tb.forEach(ctx, len(sc.Puzzles), func(i int) error {
+		var err error
+		start := time.Now()
 		solutions[i], promises[i], secrets[i], err =
-			puzzle.NewSolutionPromise(&pk, p)
+			puzzle.NewSizedSolutionPromise(pk, sc.Puzzles[i],
+				sc.HashLock.PreimageSize())
 		if err != nil {
-			return nil, err
+			return err
 		}
+		s.tb.metrics.puzzleSolve.Observe(since(start))
+		return nil
+	})
+	s.tb.solver.release()
+	if err != nil {
+		return nil, err
 	}
 
 	// Make a record of submitted puzzles and the locktime.
 	s.puzzles = sc.Puzzles
 	s.solutions = solutions
 	s.secrets = secrets
-	s.epoch = sc.Epoch
+	s.hashLock = sc.HashLock
 	// Commit to generated secrets by providing their hash values
 	hashes := make([][]byte, len(secrets))
-	for i, s := range secrets {
-		hashes[i] = chainhash.HashB(s)
+	for i, secret := range secrets {
+		hashes[i] = sc.HashLock.KeyHash(secret)
 	}
 
-	s.state = StateSolutionsPromised
+	s.setState(StateSolutionsPromised)
 	log.Debugf("Solution promises offered to %s", s.String())
 
 	return &SolutionPromises{
-		Promises:  promises,
-		KeyHashes: hashes,
+		Promises:          promises,
+		KeyHashes:         hashes,
+		RealPreimageCount: s.tb.params.RealPreimageCount,
+		FakePreimageCount: s.tb.params.FakePreimageCount,
 	}, nil
 }
 
@@ -118,10 +180,14 @@ func (s *Session) ValidateSolutions(ctx context.Context, pd *PuzzleDisclosure) (
 		return nil, fmt.Errorf("failed to decode puzzle index list: %v", err)
 	}
 
-	if len(fakePuzzleList) > len(s.puzzles) {
+	if len(fakePuzzleList) > len(s.puzzles) ||
+		len(fakePuzzleList) > len(pd.FakeFactors) {
 		return nil, errors.New("failed to decode puzzle index list: " +
 			"bad input values")
 	}
+	if len(fakePuzzleList) != s.tb.params.FakePreimageCount {
+		return nil, ErrParameterMismatch
+	}
 
 	pk, err := s.tb.getPuzzleKey(s.epoch)
 	if err != nil {
@@ -147,8 +213,9 @@ func (s *Session) ValidateSolutions(ctx context.Context, pd *PuzzleDisclosure) (
 		}
 		secrets[i] = s.secrets[idx]
 	}
+	s.auditSolver(pd)
 
-	s.state = StateSolutionsValidated
+	s.setState(StateSolutionsValidated)
 	log.Debugf("Solver proof offered to %s", s.String())
 
 	return &SolutionSecrets{
@@ -164,7 +231,7 @@ func (s *Session) ValidateSolutions(ctx context.Context, pd *PuzzleDisclosure) (
 // actual puzzles as published on the blockchain by the payer. Tumbler
 // must post a solution transaction fulfilling the specified condition.
 type PaymentOffer struct {
-	Amount         int64
+	Amount         contract.Amount
 	PublicKey      string
 	EscrowHash     []byte
 	EscrowScript   []byte
@@ -172,6 +239,7 @@ type PaymentOffer struct {
 	Puzzle         []byte
 	RealPuzzleList []byte
 	RealFactors    [][]byte
+	Capacity       contract.Amount
 }
 
 // PaymentOffer validates the offer transaction and records it in the
@@ -191,6 +259,9 @@ func (s *Session) PaymentOffer(ctx context.Context, po *PaymentOffer) error {
 		return errors.New("failed to decode puzzle index list: " +
 			"bad input values")
 	}
+	if len(s.realPuzzleList) != s.tb.params.RealPreimageCount {
+		return ErrParameterMismatch
+	}
 
 	// Make sure there was no previous offer.
 	if s.contract != nil {
@@ -208,11 +279,17 @@ func (s *Session) PaymentOffer(ctx context.Context, po *PaymentOffer) error {
 		return errors.New("bad offer tx")
 	}
 
-	s.contract, err = contract.New(s.tb.ChainParams(), po.Amount, s.epoch+
-		EpochDuration)
+	fee, err := s.tb.feePolicy.checkOfferAmount(po.Amount)
+	if err != nil {
+		return err
+	}
+	s.contract, err = contract.NewOffer(s.tb.ChainParams(), fee,
+		s.tb.lockTime(s.epoch))
 	if err != nil {
 		return err
 	}
+	s.contract.LockType = s.tb.lockType
+	s.contract.HashLock = s.hashLock
 	err = s.contract.SetAddress(contract.SenderAddress, s.address,
 		po.PublicKey)
 	if err != nil {
@@ -232,12 +309,12 @@ func (s *Session) PaymentOffer(ctx context.Context, po *PaymentOffer) error {
 	}
 
 	s.contract.EscrowScript = po.EscrowScript
-	err = s.tb.wallet.ImportEscrowScript(ctx, s.contract)
+	err = s.importEscrowScript(ctx, s.contract)
 	if err != nil {
 		return fmt.Errorf("failed to import offer script: %v", err)
 	}
 
-	s.state = StateOfferReceived
+	s.setState(StateOfferReceived)
 	log.Debugf("Payment offer received from %s", s.String())
 
 	valid, err := s.tb.wallet.ValidateOffer(ctx, s.contract, po.EscrowHash)
@@ -245,12 +322,12 @@ func (s *Session) PaymentOffer(ctx context.Context, po *PaymentOffer) error {
 		return fmt.Errorf("failed to validate offer tx: %v", err)
 	}
 	if !valid {
-		now := time.Now()
-		s.deadline = now.Add(3 * ConfirmationInterval)
-		s.tb.DeferAction(s, func(ctx context.Context, s *Session, arg interface{}) {
+		s.deadline = time.Now().Add(
+			s.tb.stageTimeout(stageOfferConfirmation))
+		s.tb.WatchConfirmations(s, func(ctx context.Context, s *Session, arg interface{}) {
 			po := arg.(*PaymentOffer)
 			s.validateOffer(ctx, po)
-		}, po, now.Add(ConfirmationInterval))
+		}, po)
 		return nil
 	} else {
 		s.validateOffer(ctx, po)
@@ -281,16 +358,16 @@ func (s *Session) validateOffer(ctx context.Context, po *PaymentOffer) {
 	}
 	now := time.Now()
 	if !valid && now.After(s.deadline) {
-		s.err = fmt.Errorf("offer tx wasn't confirmed after %d seconds",
-			3*ConfirmationInterval/time.Second)
-		s.FinalizeExchange(ctx, ReasonFailedExchange, nil)
+		s.err = fmt.Errorf("offer tx wasn't confirmed after %v",
+			s.tb.stageTimeout(stageOfferConfirmation))
+		s.FinalizeExchange(ctx, ReasonOfferConfirmationTimeout, nil)
 		return
 	}
 	if !valid {
-		s.tb.DeferAction(s, func(ctx context.Context, s *Session, arg interface{}) {
+		s.tb.WatchConfirmations(s, func(ctx context.Context, s *Session, arg interface{}) {
 			po := arg.(*PaymentOffer)
 			s.validateOffer(ctx, po)
-		}, po, now.Add(ConfirmationInterval))
+		}, po)
 		return
 	}
 
@@ -306,11 +383,14 @@ func (s *Session) validateOffer(ctx context.Context, po *PaymentOffer) {
 		return
 	}
 
-	if err = s.PublishSolution(ctx, secrets); err != nil {
-		s.err = err
-		s.FinalizeExchange(ctx, ReasonFailedExchange, nil)
-		return
-	}
+	// The client may refund the offer if the cash-out is delayed.
+	s.watchOfferEscrow(po.EscrowHash)
+	s.scheduleCashOut(ctx, func(ctx context.Context) {
+		if err := s.PublishSolution(ctx, secrets); err != nil {
+			s.err = err
+			s.FinalizeExchange(ctx, ReasonFailedExchange, nil)
+		}
+	})
 }
 
 // RevealSolution completes the Puzzle-Solver protocol and reveals blinding
@@ -347,14 +427,205 @@ func (s *Session) RevealSolution(ctx context.Context, po *PaymentOffer) ([][]byt
 	return secrets, nil
 }
 
+// ChannelPayment validates the offer made over the payment channel and
+// reveals solutions to the client right away since the tumbler is able
+// to cash out the offer at any time before the end of the epoch.
+//
+// The first offer establishes the channel with the specified capacity,
+// its escrow transaction must be confirmed on the blockchain. Every
+// following offer must spend the same escrow and transfer the
+// cumulative balance of the channel. Only the latest offer along with
+// its fulfilling transaction is published during the cash-out.
+func (s *Session) ChannelPayment(ctx context.Context, po *PaymentOffer) ([][]byte, error) {
+	if ok, err := s.ready(StateOfferReceived); !ok {
+		return nil, err
+	}
+
+	var err error
+	s.realPuzzleList, err = puzzle.DecodeIndexList(po.RealPuzzleList)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode puzzle index list: %v",
+			err)
+	}
+	if len(s.realPuzzleList) > len(s.puzzles) {
+		return nil, errors.New("failed to decode puzzle index list: " +
+			"bad input values")
+	}
+	if len(s.realPuzzleList) != s.tb.params.RealPreimageCount {
+		return nil, ErrParameterMismatch
+	}
+
+	if len(po.EscrowTx) == 0 || len(po.EscrowScript) == 0 ||
+		len(po.EscrowHash) == 0 {
+		return nil, errors.New("bad offer tx")
+	}
+
+	lockTime := s.tb.lockTime(s.epoch)
+
+	if s.channel == nil {
+		ch, err := contract.NewChannel(po.EscrowHash, po.Capacity,
+			s.tb.feePolicy.Fee(contract.Denomination))
+		if err != nil {
+			return nil, err
+		}
+		escrow := contract.NewChannelEscrow(s.tb.ChainParams(), ch,
+			lockTime)
+		escrow.LockType = s.tb.lockType
+		valid, err := s.tb.wallet.ValidateOffer(ctx, escrow,
+			po.EscrowHash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to validate channel "+
+				"escrow tx: %v", err)
+		}
+		if !valid {
+			return nil, ErrNotConfirmed
+		}
+		s.channel = ch
+		s.watchOfferEscrow(ch.EscrowHash)
+		s.limitChannel()
+
+		// Cash out before the session expires leaving enough time
+		// for the cash-out to be batched.
+		s.tb.DeferAction(s, deferredCashOut, nil,
+			s.expire.Add(-s.tb.blocksDuration(2)-
+				s.tb.batchPolicy.delay()))
+	} else if !bytes.Equal(po.EscrowHash, s.channel.EscrowHash) {
+		return nil, errors.New("conflicting channel escrow tx")
+	}
+
+	secrets, err := s.RevealSolution(ctx, po)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.channel.Pay(po.Amount); err != nil {
+		return nil, err
+	}
+
+	con, err := contract.NewChannelPayment(s.tb.ChainParams(), s.channel,
+		lockTime)
+	if err != nil {
+		return nil, err
+	}
+	con.LockType = s.tb.lockType
+	con.HashLock = s.hashLock
+	err = con.SetAddress(contract.SenderAddress, s.address, po.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	epochAddr, epochPubKey, err := s.tb.getEpochAddress(ctx, s.epoch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain an address for an "+
+			"epoch %d: %v", s.epoch, err)
+	}
+	err = con.SetAddress(contract.ReceiverAddress, epochAddr, epochPubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	con.EscrowScript = po.EscrowScript
+	con.EscrowBytes = po.EscrowTx
+	err = s.importEscrowScript(ctx, con)
+	if err != nil {
+		return nil, fmt.Errorf("failed to import offer script: %v", err)
+	}
+
+	// Supersede the previous offer.
+	s.contract = con
+	s.channelSecrets = secrets
+	s.saveContract()
+
+	// There's no solution to publish until the cash-out, but the
+	// session is ready for another round or the cash-out itself.
+	s.setState(StateSolutionPublished)
+	log.Debugf("Channel payment received from %s: %s", s.String(),
+		s.channel.String())
+
+	if s.channel.Exhausted() {
+		s.scheduleCashOut(ctx, s.cashOutChannel)
+	}
+
+	return secrets, nil
+}
+
+// deferredCashOut performs the cash-out of the payment channel at the end
+// of the session unless a payment round is in progress, in which case the
+// cash-out is postponed.
+func deferredCashOut(ctx context.Context, s *Session, arg interface{}) {
+	if !s.TryLock() {
+		s.tb.DeferAction(s, deferredCashOut, nil,
+			time.Now().Add(time.Minute))
+		return
+	}
+	defer s.Unlock()
+	s.scheduleCashOut(ctx, s.cashOutChannel)
+}
+
+// cashOutChannel publishes the latest offer transaction received over the
+// payment channel and the fulfilling transaction redeeming the cumulative
+// balance of the channel.
+func (s *Session) cashOutChannel(ctx context.Context) {
+	if err := s.checkOfferEscrow(ctx); err != nil {
+		s.err = err
+		s.FinalizeExchange(ctx, ReasonFailedExchange, nil)
+		return
+	}
+	if err := s.tb.wallet.PublishEscrow(ctx, s.contract); err != nil {
+		s.err = fmt.Errorf("failed to publish channel offer tx: %v", err)
+		s.FinalizeExchange(ctx, ReasonFailedExchange, nil)
+		return
+	}
+	// The channel escrow is now spent by the offer itself.
+	s.offerEscrow = nil
+
+	if err := s.PublishSolution(ctx, s.channelSecrets); err != nil {
+		s.err = err
+		s.FinalizeExchange(ctx, ReasonFailedExchange, nil)
+		return
+	}
+}
+
+// deferredSolution publishes the solution again once the offline signer
+// had a chance to sign the fulfilling transaction.
+func deferredSolution(ctx context.Context, s *Session, arg interface{}) {
+	if !s.TryLock() {
+		s.tb.DeferAction(s, deferredSolution, arg,
+			time.Now().Add(time.Minute))
+		return
+	}
+	defer s.Unlock()
+	if err := s.PublishSolution(ctx, arg.([][]byte)); err != nil {
+		s.err = err
+		s.FinalizeExchange(ctx, ReasonFailedExchange, nil)
+	}
+}
+
+// IsChannel returns true if the session has established a payment channel.
+func (s *Session) IsChannel() bool {
+	return s.channel != nil
+}
+
 // PublishSolution publishes preimages fulfilling the offer transaction.
 func (s *Session) PublishSolution(ctx context.Context, secrets [][]byte) error {
+	if err := s.checkOfferEscrow(ctx); err != nil {
+		return err
+	}
+	s.tb.solutionExpiry(s.contract)
 	err := s.tb.wallet.PublishSolution(ctx, s.contract, secrets)
+	if err == ErrSignaturePending {
+		log.Infof("Solution for %s awaits an offline signature",
+			s.String())
+		s.tb.DeferAction(s, deferredSolution, secrets,
+			time.Now().Add(time.Minute))
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("failed to publish fulfilling tx :%v", err)
 	}
+	s.trackSolution(secrets)
 
-	s.state = StateSolutionPublished
+	s.setState(StateSolutionPublished)
 	log.Debugf("Solution published for %s", s.String())
 	log.Tracef("Solution %s", s.contract.String())
 
@@ -0,0 +1,242 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumbler
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// sessionShardCount is the number of independently locked partitions of
+// the session table. Cookies are random, therefore sessions are spread
+// evenly among shards.
+const sessionShardCount = 64
+
+// sessionShard is a partition of the session table.
+type sessionShard struct {
+	mu       sync.RWMutex
+	sessions map[[16]byte]*Session
+}
+
+// sessionTable maps cookies to active sessions.
+type sessionTable [sessionShardCount]sessionShard
+
+func (t *sessionTable) init() {
+	for i := range t {
+		t[i].sessions = make(map[[16]byte]*Session)
+	}
+}
+
+func (t *sessionTable) shard(cookie *[16]byte) *sessionShard {
+	return &t[int(cookie[0])%sessionShardCount]
+}
+
+// insert adds the session unless the cookie is already taken.
+func (t *sessionTable) insert(cookie [16]byte, s *Session) bool {
+	sh := t.shard(&cookie)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if _, exists := sh.sessions[cookie]; exists {
+		return false
+	}
+	sh.sessions[cookie] = s
+	return true
+}
+
+func (t *sessionTable) lookup(cookie [16]byte) (*Session, bool) {
+	sh := t.shard(&cookie)
+	sh.mu.RLock()
+	s, ok := sh.sessions[cookie]
+	sh.mu.RUnlock()
+	return s, ok
+}
+
+func (t *sessionTable) remove(cookie [16]byte) {
+	sh := t.shard(&cookie)
+	sh.mu.Lock()
+	delete(sh.sessions, cookie)
+	sh.mu.Unlock()
+}
+
+// snapshot returns all sessions in the table. Shards are locked one at a
+// time, so the result isn't an atomic snapshot of the whole table.
+func (t *sessionTable) snapshot() []*Session {
+	var sessions []*Session
+	for i := range t {
+		sh := &t[i]
+		sh.mu.RLock()
+		for _, s := range sh.sessions {
+			sessions = append(sessions, s)
+		}
+		sh.mu.RUnlock()
+	}
+	return sessions
+}
+
+// timer is an entry of the scheduler. Timers without an action expire
+// the session for the specified finalization reason.
+type timer struct {
+	until   time.Time
+	session *Session
+	action  *deferredAction
+	reason  int
+	index   int // Position in the heap or -1 once removed
+}
+
+// timerHeap is a min-heap of timers ordered by their deadlines.
+type timerHeap []*timer
+
+func (h timerHeap) Len() int           { return len(h) }
+func (h timerHeap) Less(i, j int) bool { return h[i].until.Before(h[j].until) }
+
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x interface{}) {
+	t := x.(*timer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *timerHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	t := old[n-1]
+	old[n-1] = nil
+	t.index = -1
+	*h = old[:n-1]
+	return t
+}
+
+// idleWait is the longest time the session ticker sleeps for when no
+// timers are scheduled. Adding a timer wakes it up earlier.
+const idleWait = time.Hour
+
+// scheduler keeps session expiration deadlines and deferred actions in a
+// heap, so that due entries are retrieved without scanning all of them.
+// Timers of a session are tracked by the session itself, allowing them to
+// be removed when the session is disconnected.
+type scheduler struct {
+	mu     sync.Mutex
+	timers timerHeap
+
+	// wake is signalled when a timer due before all others is added.
+	wake chan struct{}
+}
+
+func (sc *scheduler) init() {
+	sc.wake = make(chan struct{}, 1)
+}
+
+// add schedules the timer and associates it with its session. The session
+// ticker is woken up if the timer is due first.
+func (sc *scheduler) add(t *timer) {
+	sc.mu.Lock()
+	heap.Push(&sc.timers, t)
+	t.session.timers = append(t.session.timers, t)
+	first := t.index == 0
+	sc.mu.Unlock()
+
+	if first {
+		select {
+		case sc.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// requeue schedules the timer again unless its session has been
+// finalized. Finalization is checked with the mutex held, so the timer
+// is either rejected or removed along with the other timers of the
+// session once it's disconnected.
+func (sc *scheduler) requeue(t *timer) bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if t.session.Finalized() {
+		return false
+	}
+	heap.Push(&sc.timers, t)
+	t.session.timers = append(t.session.timers, t)
+	return true
+}
+
+// wait returns the time left until the earliest timer is due.
+func (sc *scheduler) wait(now time.Time) time.Duration {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if len(sc.timers) == 0 {
+		return idleWait
+	}
+	d := sc.timers[0].until.Sub(now)
+	switch {
+	case d < 0:
+		return 0
+	case d > idleWait:
+		return idleWait
+	}
+	return d
+}
+
+// remove unschedules the timer unless it has already fired.
+func (sc *scheduler) remove(t *timer) {
+	sc.mu.Lock()
+	if t.index >= 0 {
+		heap.Remove(&sc.timers, t.index)
+		t.session.forgetTimer(t)
+	}
+	sc.mu.Unlock()
+}
+
+// removeSession removes all timers associated with the session.
+func (sc *scheduler) removeSession(s *Session) {
+	sc.mu.Lock()
+	for _, t := range s.timers {
+		if t.index >= 0 {
+			heap.Remove(&sc.timers, t.index)
+		}
+	}
+	s.timers = nil
+	sc.mu.Unlock()
+}
+
+// due removes and returns timers with deadlines up to now in the order of
+// their deadlines.
+func (sc *scheduler) due(now time.Time) []*timer {
+	var timers []*timer
+	sc.mu.Lock()
+	for len(sc.timers) > 0 && !sc.timers[0].until.After(now) {
+		t := heap.Pop(&sc.timers).(*timer)
+		t.session.forgetTimer(t)
+		timers = append(timers, t)
+	}
+	sc.mu.Unlock()
+	return timers
+}
+
+// len returns the number of scheduled timers.
+func (sc *scheduler) len() int {
+	sc.mu.Lock()
+	n := len(sc.timers)
+	sc.mu.Unlock()
+	return n
+}
+
+// forgetTimer removes the fired timer from the list of the session's
+// timers. Scheduler mutex must be held by the caller.
+func (s *Session) forgetTimer(t *timer) {
+	for i := range s.timers {
+		if s.timers[i] == t {
+			last := len(s.timers) - 1
+			s.timers[i] = s.timers[last]
+			s.timers[last] = nil
+			s.timers = s.timers[:last]
+			return
+		}
+	}
+}
@@ -0,0 +1,389 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumbler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/decred/tumblebit/contract"
+)
+
+func TestScheduler(t *testing.T) {
+	tb := NewTumbler(&Config{})
+	now := time.Now()
+
+	s1 := &Session{expire: now.Add(time.Minute)}
+	s1.Cookie = tb.Connect(s1)
+	s2 := &Session{expire: now.Add(-time.Minute)}
+	s2.Cookie = tb.Connect(s2)
+	s3 := &Session{expire: now.Add(time.Hour)}
+	s3.Cookie = tb.Connect(s3)
+
+	var fired []int
+	cb := func(ctx context.Context, s *Session, arg interface{}) {
+		fired = append(fired, arg.(int))
+	}
+	tb.DeferAction(s1, cb, 2, now.Add(-time.Second))
+	tb.DeferAction(s1, cb, 1, now.Add(-time.Hour))
+	tb.DeferAction(s1, cb, 3, now.Add(time.Hour))
+	// Actions of the expiring session must be discarded.
+	tb.DeferAction(s2, cb, 4, now.Add(-time.Hour))
+	tb.DeferAction(s2, cb, 5, now.Add(time.Hour))
+	// Actions of the disconnected session must never fire.
+	tb.DeferAction(s3, cb, 6, now.Add(-time.Hour))
+	tb.Disconnect(s3)
+
+	if _, ok := tb.Lookup(s3.Token); ok {
+		t.Fatal("disconnected session is still registered")
+	}
+	if s, ok := tb.Lookup(s1.Token); !ok || s != s1 {
+		t.Fatal("failed to lookup a connected session")
+	}
+
+	actions, expired := tb.dueTimers(now)
+	if len(expired) != 1 || expired[0].session != s2 ||
+		expired[0].reason != ReasonSessionExpired {
+		t.Fatalf("unexpected expired sessions: %v", expired)
+	}
+	if err := tb.deferredActions(context.Background(), actions); err != nil {
+		t.Fatal(err)
+	}
+	if len(fired) != 2 || fired[0] != 1 || fired[1] != 2 {
+		t.Fatalf("unexpected deferred actions: %v", fired)
+	}
+
+	// Only the expiration timer and the last deferred action of the
+	// first session remain.
+	if n := tb.sched.len(); n != 2 || len(s1.timers) != 2 {
+		t.Fatalf("unexpected number of timers: %d", n)
+	}
+	tb.Disconnect(s1)
+	if n := tb.sched.len(); n != 0 {
+		t.Fatalf("timers left after disconnect: %d", n)
+	}
+	if n := len(tb.activeSessions()); n != 1 {
+		t.Fatalf("unexpected number of active sessions: %d", n)
+	}
+}
+
+func TestSchedulerWait(t *testing.T) {
+	tb := NewTumbler(&Config{})
+	now := time.Now()
+
+	if d := tb.sched.wait(now); d != idleWait {
+		t.Fatalf("unexpected wait without timers: %v", d)
+	}
+
+	s := &Session{expire: now.Add(time.Hour + time.Minute)}
+	s.Cookie = tb.Connect(s)
+	select {
+	case <-tb.sched.wake:
+	default:
+		t.Fatal("ticker wasn't woken up by the first timer")
+	}
+	if d := tb.sched.wait(now); d != idleWait {
+		t.Fatalf("unexpected wait for a distant timer: %v", d)
+	}
+
+	cb := func(ctx context.Context, s *Session, arg interface{}) {}
+	tb.DeferAction(s, cb, nil, now.Add(time.Minute))
+	select {
+	case <-tb.sched.wake:
+	default:
+		t.Fatal("ticker wasn't woken up by an earlier timer")
+	}
+	if d := tb.sched.wait(now); d != time.Minute {
+		t.Fatalf("unexpected wait: %v", d)
+	}
+
+	// Later timers don't wake the ticker.
+	tb.DeferAction(s, cb, nil, now.Add(time.Hour))
+	select {
+	case <-tb.sched.wake:
+		t.Fatal("ticker woken up by a later timer")
+	default:
+	}
+
+	// Timers are due exactly at their deadlines.
+	actions, _ := tb.dueTimers(now.Add(time.Minute))
+	if len(actions) != 1 {
+		t.Fatalf("unexpected deferred actions: %d", len(actions))
+	}
+	if d := tb.sched.wait(now.Add(2 * time.Hour)); d != 0 {
+		t.Fatalf("unexpected wait for an overdue timer: %v", d)
+	}
+}
+
+func TestDeferredActionsCancel(t *testing.T) {
+	tb := NewTumbler(&Config{})
+	now := time.Now()
+
+	s1 := &Session{expire: now.Add(time.Hour)}
+	s1.Cookie = tb.Connect(s1)
+	s2 := &Session{expire: now.Add(time.Hour)}
+	s2.Cookie = tb.Connect(s2)
+
+	// The first action cancels the context mid-batch.
+	ctx, cancel := context.WithCancel(context.Background())
+	var fired []int
+	cb := func(ctx context.Context, s *Session, arg interface{}) {
+		fired = append(fired, arg.(int))
+		if arg.(int) == 1 {
+			cancel()
+		}
+	}
+	tb.DeferAction(s1, cb, 1, now.Add(-3*time.Second))
+	tb.DeferAction(s1, cb, 2, now.Add(-2*time.Second))
+	tb.DeferAction(s2, cb, 3, now.Add(-time.Second))
+	actions, _ := tb.dueTimers(now)
+	if len(actions) != 3 {
+		t.Fatalf("unexpected deferred actions: %d", len(actions))
+	}
+	s2.FinalizeExchange(ctx, ReasonShutdown, nil)
+
+	if err := tb.deferredActions(ctx, actions); err != context.Canceled {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fired) != 1 {
+		t.Fatalf("actions run after cancellation: %v", fired)
+	}
+	// The remaining action of the active session is requeued with its
+	// deadline, the one of the finalized session is discarded.
+	if len(s1.timers) != 2 || len(s2.timers) != 0 {
+		t.Fatalf("unexpected timers: %d, %d", len(s1.timers),
+			len(s2.timers))
+	}
+	if n := tb.sched.len(); n != 2 {
+		t.Fatalf("unexpected number of timers: %d", n)
+	}
+
+	// Resumed processing runs the requeued action.
+	actions, _ = tb.dueTimers(now)
+	err := tb.deferredActions(context.Background(), actions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fired) != 2 || fired[1] != 2 {
+		t.Fatalf("requeued action wasn't run: %v", fired)
+	}
+
+	// Expired sessions are finalized even with a cancelled context.
+	s3 := &Session{expire: now.Add(-2 * time.Second)}
+	s3.Cookie = tb.Connect(s3)
+	s4 := &Session{expire: now.Add(-time.Second)}
+	s4.Cookie = tb.Connect(s4)
+	_, expired := tb.dueTimers(now)
+	if len(expired) != 2 {
+		t.Fatalf("unexpected expired sessions: %d", len(expired))
+	}
+	if err := tb.expireSessions(ctx, expired); err != nil {
+		t.Fatal(err)
+	}
+	if !s3.Finalized() || !s4.Finalized() || s1.Finalized() {
+		t.Fatal("expired sessions weren't finalized")
+	}
+}
+
+func TestStageDeadlines(t *testing.T) {
+	tb := NewTumbler(&Config{Deadlines: &Deadlines{
+		EscrowSetup: time.Minute,
+	}})
+	now := time.Now()
+
+	s1 := &Session{expire: now.Add(time.Hour)}
+	s1.Cookie = tb.Connect(s1)
+	s1.setState(StateEscrowComplete)
+	stage := s1.stageTimer
+	if stage == nil || stage.reason != ReasonEscrowSetupTimeout {
+		t.Fatal("escrow setup deadline wasn't scheduled")
+	}
+	// The deadline applies to the whole stage.
+	s1.setState(StatePuzzlesPromised)
+	if s1.stageTimer != stage {
+		t.Fatal("deadline rescheduled within the stage")
+	}
+
+	// Leaving the stage removes its deadline and stages without
+	// deadlines only expire with the session.
+	s2 := &Session{expire: now.Add(time.Hour),
+		contract: &contract.Contract{}}
+	s2.Cookie = tb.Connect(s2)
+	s2.setState(StateEscrowComplete)
+	s2.setState(StatePuzzlesPromised)
+	s2.setState(StatePuzzlesValidated)
+	s2.setState(StateEscrowPublished)
+	if s2.stageTimer != nil || len(s2.timers) != 1 {
+		t.Fatalf("unexpected timers: %d", len(s2.timers))
+	}
+
+	// The offer confirmation deadline defaults to three blocks.
+	s3 := &Session{expire: now.Add(time.Hour)}
+	s3.Cookie = tb.Connect(s3)
+	s3.setState(StateSolutionsPromised)
+	if s3.stageTimer != nil {
+		t.Fatal("unexpected promise validation deadline")
+	}
+	s3.setState(StateSolutionsValidated)
+	s3.setState(StateOfferReceived)
+	if s3.stageTimer == nil ||
+		s3.stageTimer.reason != ReasonOfferConfirmationTimeout {
+		t.Fatal("offer confirmation deadline wasn't scheduled")
+	}
+
+	_, expired := tb.dueTimers(now.Add(2 * time.Minute))
+	if len(expired) != 1 || expired[0].session != s1 ||
+		expired[0].reason != ReasonEscrowSetupTimeout {
+		t.Fatalf("unexpected expired sessions: %v", expired)
+	}
+	if len(s1.timers) != 0 {
+		t.Fatal("timers of the expired session weren't removed")
+	}
+}
+
+func TestClientDelay(t *testing.T) {
+	tb := NewTumbler(&Config{Deadlines: &Deadlines{
+		EscrowSetup: time.Minute,
+		ClientDelay: 10 * time.Second,
+	}})
+
+	// Both requests of the escrow setup may be delayed by the client.
+	if d := tb.stageTimeout(stageEscrowSetup); d != 80*time.Second {
+		t.Fatalf("unexpected escrow setup timeout: %v", d)
+	}
+	// Disabled deadlines aren't enabled by the delay.
+	if d := tb.stageTimeout(stagePromiseValidation); d != 0 {
+		t.Fatalf("unexpected promise validation timeout: %v", d)
+	}
+}
+
+func TestSessionToken(t *testing.T) {
+	tb := NewTumbler(&Config{})
+
+	s := &Session{expire: time.Now().Add(time.Hour)}
+	s.Cookie = tb.Connect(s)
+	if found, ok := tb.Lookup(s.Token); !ok || found != s {
+		t.Fatal("failed to lookup a session by its token")
+	}
+	if _, ok := tb.Lookup(s.Cookie[:]); ok {
+		t.Fatal("bare cookie accepted as a session token")
+	}
+
+	// Any modification of the token must be detected.
+	for i := range s.Token {
+		forged := append([]byte(nil), s.Token...)
+		forged[i] ^= 1
+		if _, ok := tb.Lookup(forged); ok {
+			t.Fatalf("forged token accepted: byte %d modified", i)
+		}
+	}
+
+	// Tokens issued by another tumbler aren't valid.
+	other := NewTumbler(&Config{})
+	if _, ok := other.Lookup(s.Token); ok {
+		t.Fatal("token accepted by another tumbler")
+	}
+
+	// Tokens of expired sessions are rejected even before the session
+	// is collected.
+	expired := &Session{expire: time.Now().Add(-time.Second)}
+	expired.Cookie = tb.Connect(expired)
+	if _, ok := tb.Lookup(expired.Token); ok {
+		t.Fatal("expired token accepted")
+	}
+}
+
+func TestSessionSequence(t *testing.T) {
+	s := &Session{}
+
+	for _, seq := range []uint64{1, 2, 5} {
+		if err := s.CheckSequence(seq); err != nil {
+			t.Fatalf("sequence %d rejected: %v", seq, err)
+		}
+	}
+	// Replayed, reordered and unnumbered requests are rejected.
+	for _, seq := range []uint64{5, 3, 0} {
+		if err := s.CheckSequence(seq); err != ErrReplay {
+			t.Fatalf("sequence %d accepted", seq)
+		}
+	}
+	if st := s.Status(); st.Sequence != 5 {
+		t.Fatalf("last accepted sequence %d, expected 5", st.Sequence)
+	}
+}
+
+const benchSessions = 10000
+
+func connectSessions(tb *Tumbler, n int) []*Session {
+	expire := time.Now().Add(time.Hour)
+	sessions := make([]*Session, n)
+	for i := range sessions {
+		s := &Session{expire: expire}
+		s.Cookie = tb.Connect(s)
+		sessions[i] = s
+	}
+	return sessions
+}
+
+// BenchmarkSessionLookup measures concurrent lookups of 10k sessions.
+func BenchmarkSessionLookup(b *testing.B) {
+	tb := NewTumbler(&Config{})
+	sessions := connectSessions(tb, benchSessions)
+	var next uint32
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := atomic.AddUint32(&next, 1) % benchSessions
+			if _, ok := tb.Lookup(sessions[i].Token); !ok {
+				b.Fatal("session not found")
+			}
+		}
+	})
+}
+
+// BenchmarkSessionChurn measures connecting and disconnecting sessions
+// in parallel while 10k other sessions are active.
+func BenchmarkSessionChurn(b *testing.B) {
+	tb := NewTumbler(&Config{})
+	connectSessions(tb, benchSessions)
+	expire := time.Now().Add(time.Hour)
+	cb := func(ctx context.Context, s *Session, arg interface{}) {}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			s := &Session{expire: expire}
+			s.Cookie = tb.Connect(s)
+			tb.DeferAction(s, cb, nil, expire)
+			tb.Disconnect(s)
+		}
+	})
+}
+
+// BenchmarkSessionTicker measures a tick of the session ticker with 10k
+// active sessions, each of which has a deferred action due.
+func BenchmarkSessionTicker(b *testing.B) {
+	tb := NewTumbler(&Config{})
+	sessions := connectSessions(tb, benchSessions)
+	cb := func(ctx context.Context, s *Session, arg interface{}) {}
+	now := time.Now()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		for _, s := range sessions {
+			tb.DeferAction(s, cb, nil, now.Add(-time.Second))
+		}
+		b.StartTimer()
+		actions, expired := tb.dueTimers(now)
+		if len(actions) != benchSessions || len(expired) != 0 {
+			b.Fatalf("unexpected timers: %d deferred, %d expired",
+				len(actions), len(expired))
+		}
+	}
+}
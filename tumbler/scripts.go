@@ -0,0 +1,253 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumbler
+
+import (
+	"context"
+	"sync"
+
+	"github.com/decred/tumblebit/contract"
+)
+
+// ScriptPruneDepth is the default number of blocks the transaction
+// resolving a contract must be buried under before its escrow script is
+// considered prunable.
+const ScriptPruneDepth = 288
+
+// ScriptResolver is implemented by wallets able to look up transactions
+// spending escrows. Lifecycles of imported escrow scripts are only tracked
+// with such wallets.
+type ScriptResolver interface {
+	// EscrowSpender returns the hash of the transaction spending the
+	// escrow of the contract, or nil if it hasn't been spent.
+	EscrowSpender(ctx context.Context, con *contract.Contract) ([]byte, error)
+
+	// Confirmations returns the number of confirmations of the
+	// transaction identified by the hash.
+	Confirmations(ctx context.Context, txHash []byte) (int32, error)
+}
+
+// ScriptPruner is implemented by wallets able to stop watching escrow
+// scripts that were imported into them.
+type ScriptPruner interface {
+	// PruneEscrowScript removes the escrow script paying to the P2SH
+	// address from the set of scripts watched by the wallet.
+	PruneEscrowScript(ctx context.Context, address string, script []byte) error
+}
+
+// importedScript is an escrow script imported into the wallet along with
+// the contract it belongs to and the session that imported it.
+type importedScript struct {
+	record  *contract.ScriptRecord
+	con     *contract.Contract
+	session *Session // Unset once the session is finalized
+}
+
+// scriptTracker tracks escrow scripts imported into the wallet until they
+// become prunable. Records of tracked scripts are protected by the mutex.
+type scriptTracker struct {
+	mu      sync.Mutex
+	scripts map[string]*importedScript // Keyed by P2SH address
+	depth   int32
+}
+
+// update modifies the record of the tracked script and returns a copy of
+// the result.
+func (st *scriptTracker) update(is *importedScript, fn func(r *contract.ScriptRecord)) contract.ScriptRecord {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	fn(is.record)
+	return *is.record
+}
+
+// importEscrowScript imports the escrow script of the contract into the
+// wallet and starts tracking its lifecycle.
+func (s *Session) importEscrowScript(ctx context.Context, con *contract.Contract) error {
+	if err := s.tb.wallet.ImportEscrowScript(ctx, con); err != nil {
+		return err
+	}
+	if s.tb.resolver == nil || con.EscrowAddrStr == "" {
+		return nil
+	}
+
+	is := &importedScript{
+		record: &contract.ScriptRecord{
+			Address:      con.EscrowAddrStr,
+			Script:       con.EscrowScript,
+			ImportHeight: s.tb.bestHeight(),
+		},
+		con:     con,
+		session: s,
+	}
+	s.tb.scripts.mu.Lock()
+	if s.tb.scripts.scripts == nil {
+		s.tb.scripts.scripts = make(map[string]*importedScript)
+	}
+	s.tb.scripts.scripts[is.record.Address] = is
+	r := *is.record
+	s.tb.scripts.mu.Unlock()
+	s.tb.saveScript(&r)
+	return nil
+}
+
+// abandonScripts releases escrow scripts imported by the finalized
+// session. Contracts whose escrow transaction was never learned are
+// resolved by the next check, the tumbler doesn't spend such escrows.
+func (s *Session) abandonScripts() {
+	s.tb.scripts.mu.Lock()
+	for _, is := range s.tb.scripts.scripts {
+		if is.session == s {
+			is.session = nil
+		}
+	}
+	s.tb.scripts.mu.Unlock()
+}
+
+// loadScripts resumes tracking of escrow scripts recorded in the journal
+// that haven't become prunable yet. Contracts of scripts without a known
+// escrow transaction belonged to sessions lost in the restart and are
+// abandoned.
+func (tb *Tumbler) loadScripts() error {
+	if tb.journal == nil || tb.resolver == nil {
+		return nil
+	}
+	records, err := tb.journal.Scripts()
+	if err != nil {
+		return err
+	}
+
+	tb.scripts.mu.Lock()
+	defer tb.scripts.mu.Unlock()
+	if tb.scripts.scripts == nil {
+		tb.scripts.scripts = make(map[string]*importedScript)
+	}
+	for _, r := range records {
+		if r.Prunable {
+			continue
+		}
+		is := &importedScript{record: r, con: &contract.Contract{}}
+		if len(r.EscrowHash) > 0 {
+			is.con, err = tb.journal.Load(r.EscrowHash)
+			if err != nil {
+				log.Warnf("Failed to load the contract of the escrow "+
+					"script %s: %v", r.Address, err)
+				continue
+			}
+		}
+		tb.scripts.scripts[r.Address] = is
+	}
+	log.Debugf("Tracking %d imported escrow scripts", len(tb.scripts.scripts))
+	return nil
+}
+
+// saveScript records the lifecycle of the script in the journal. Failures
+// are logged but don't interrupt the tumbler.
+func (tb *Tumbler) saveScript(r *contract.ScriptRecord) {
+	if tb.journal == nil {
+		return
+	}
+	if err := tb.journal.SaveScript(r); err != nil {
+		log.Warnf("Failed to journal the escrow script %s: %v", r.Address,
+			err)
+	}
+}
+
+// pruneScripts checks whether contracts of tracked escrow scripts have
+// been resolved and records scripts buried deep enough as prunable. The
+// wallet stops watching them if it's able to.
+func (tb *Tumbler) pruneScripts(ctx context.Context, height int32) {
+	if tb.resolver == nil {
+		return
+	}
+	tb.scripts.mu.Lock()
+	scripts := make([]*importedScript, 0, len(tb.scripts.scripts))
+	for _, is := range tb.scripts.scripts {
+		scripts = append(scripts, is)
+	}
+	tb.scripts.mu.Unlock()
+
+	for _, is := range scripts {
+		if ctx.Err() != nil {
+			return
+		}
+		prunable, err := tb.scriptPrunable(ctx, is, height)
+		if err != nil {
+			log.Warnf("Failed to check the escrow script %s: %v",
+				is.record.Address, err)
+			continue
+		}
+		if !prunable {
+			continue
+		}
+
+		if tb.pruner != nil {
+			err = tb.pruner.PruneEscrowScript(ctx, is.record.Address,
+				is.record.Script)
+			if err != nil {
+				log.Warnf("Failed to prune the escrow script %s: %v",
+					is.record.Address, err)
+				continue
+			}
+		}
+		r := tb.scripts.update(is, func(r *contract.ScriptRecord) {
+			r.Prunable = true
+		})
+		tb.saveScript(&r)
+		tb.scripts.mu.Lock()
+		delete(tb.scripts.scripts, r.Address)
+		tb.scripts.mu.Unlock()
+		log.Debugf("Escrow script %s is prunable", r.Address)
+	}
+}
+
+// scriptPrunable returns whether the contract of the tracked script has
+// been resolved at least the prune depth blocks ago.
+func (tb *Tumbler) scriptPrunable(ctx context.Context, is *importedScript, height int32) (bool, error) {
+	// The escrow transaction is known once the offer is validated.
+	// Contracts of finalized sessions without one are abandoned.
+	var changed bool
+	r := tb.scripts.update(is, func(r *contract.ScriptRecord) {
+		switch {
+		case len(r.EscrowHash) == 0 && is.con.EscrowTx != nil:
+			hash := is.con.EscrowTx.TxHash()
+			r.EscrowHash = hash[:]
+			changed = true
+		case len(r.EscrowHash) == 0 && is.session == nil &&
+			r.ResolveHeight == 0:
+			r.ResolveHeight = height
+			changed = true
+		}
+	})
+	if changed {
+		tb.saveScript(&r)
+	}
+
+	switch {
+	case len(r.Spender) > 0:
+		confs, err := tb.resolver.Confirmations(ctx, r.Spender)
+		if err != nil {
+			return false, err
+		}
+		return confs >= tb.scripts.depth, nil
+
+	case len(r.EscrowHash) > 0:
+		con := *is.con
+		con.EscrowHash = r.EscrowHash
+		spender, err := tb.resolver.EscrowSpender(ctx, &con)
+		if err != nil || spender == nil {
+			return false, err
+		}
+		r = tb.scripts.update(is, func(r *contract.ScriptRecord) {
+			r.Spender = spender
+			r.ResolveHeight = height
+		})
+		tb.saveScript(&r)
+		return false, nil
+
+	case r.ResolveHeight > 0:
+		return height-r.ResolveHeight >= tb.scripts.depth, nil
+	}
+	return false, nil
+}
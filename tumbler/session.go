@@ -5,9 +5,9 @@
 package tumbler
 
 import (
-	"container/list"
 	"context"
 	"fmt"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -54,13 +54,31 @@ const (
 	ReasonFailedExchange
 	// Aborting due to an internal error (i.e. broken RPC connection)
 	ReasonInternalError
+	// Aborting due to the tumbler shutdown
+	ReasonShutdown
+	// Aborting since the payee hasn't validated puzzle promises in time
+	ReasonEscrowSetupTimeout
+	// Aborting since the payer hasn't made a payment offer in time
+	ReasonPromiseValidationTimeout
+	// Aborting since the payment offer hasn't been confirmed in time
+	ReasonOfferConfirmationTimeout
+	// Aborting since the payment channel wasn't cashed out in time
+	ReasonCashOutTimeout
+	// Aborting on request of the operator
+	ReasonKilled
 )
 
 var reasonNames = [...]string{
-	ReasonSuccess:        "exchange was completed",
-	ReasonSessionExpired: "expiration timeout",
-	ReasonFailedExchange: "exchange error",
-	ReasonInternalError:  "internal error",
+	ReasonSuccess:                  "exchange was completed",
+	ReasonSessionExpired:           "expiration timeout",
+	ReasonFailedExchange:           "exchange error",
+	ReasonInternalError:            "internal error",
+	ReasonShutdown:                 "shutdown",
+	ReasonEscrowSetupTimeout:       "escrow setup timeout",
+	ReasonPromiseValidationTimeout: "promise validation timeout",
+	ReasonOfferConfirmationTimeout: "offer confirmation timeout",
+	ReasonCashOutTimeout:           "cash-out timeout",
+	ReasonKilled:                   "killed by the operator",
 }
 
 // Session keeps state of the exchange with a connected client.
@@ -69,28 +87,63 @@ type Session struct {
 	finsema int32 // Finalization semaphore
 
 	Cookie [16]byte // Identification cookie
+	Token  []byte   // Authenticated session token
 
-	tb       *Tumbler      // Associated Tumbler
-	explist  *list.Element // Expire list element
-	expire   time.Time     // When to expire
-	deadline time.Time     // Cumulative deadline for all deferred actions
+	tb       *Tumbler  // Associated Tumbler
+	timers   []*timer  // Scheduled expiration and deferred actions
+	expire   time.Time // When to expire
+	deadline time.Time // Cumulative deadline for all deferred actions
 
-	address  string             // Client's external address
-	epoch    int32              // Selected epoch
-	contract *contract.Contract // Contract in progress
-	state    int                // Current state of the exchange
-	err      error              // Asynchronous error
+	address    string             // Client's external address
+	epoch      int32              // Selected epoch
+	epochBound bool               // Whether the epoch is kept from expiring
+	contract   *contract.Contract // Contract in progress
+	state      int                // Current state of the exchange
+	err        error              // Asynchronous error
+	sequence   uint64             // Sequence number of the last request
+	request    atomic.Value       // ID of the request being served
 
 	// Puzzles that are being currently negotiated.
 	puzzles   [][]byte
 	secrets   [][]byte
 	solutions [][]byte
 	txHashes  [][]byte
-	// realSet and fakeSet are salted BLAKE2s-256 hashes.
+	// realSet and fakeSet are salted BLAKE2s-256 commitments produced
+	// with the commitment scheme chosen by the client.
 	realSetHash []byte
 	fakeSetHash []byte
+	commitment  uint32
+	// Whether quotients of the real set are withheld from the payee.
+	incremental bool
 	// realPuzzleList caches decoded values
 	realPuzzleList []int
+
+	// Payment channel established by the payer and solutions for the
+	// latest payment made over it.
+	channel        *contract.Channel
+	channelSecrets [][]byte
+
+	// Cash-out has been queued for the next batch.
+	cashOutQueued bool
+
+	// Deadline of the current stage of the exchange.
+	stageTimer *timer
+
+	// Hash of the client escrow transaction paying for the solution
+	// that is monitored for double-spends.
+	offerEscrow []byte
+
+	// Hash function the payment offer locks funds with.
+	hashLock contract.HashLock
+
+	// Commitment to the pay-out address of a payee identified by a key
+	// of the session and the address once revealed.
+	payoutCommitment []byte
+	payoutAddress    string
+
+	// Audit trail of the session.
+	historyMu sync.Mutex
+	history   []*SessionEvent
 }
 
 // NewSession creates a new Session object with a provided address.
@@ -100,38 +153,19 @@ func NewSession(tb *Tumbler, address string) *Session {
 		tb:      tb,
 	}
 
-	s.Cookie = tb.Connect(&s)
-
 	// Conservative expiration timeout
-	s.expire = time.Now().Add((EpochDuration + 1) * ConfirmationInterval)
+	s.expire = time.Now().Add(tb.sessionTimeout())
+
+	s.Cookie = tb.Connect(&s)
 
 	log.Infof("New session for %s", s.String())
 
 	return &s
 }
 
-func (s *Session) ready(next int) (bool, error) {
-	switch s.state {
-	case StateInitial:
-		if next == StateEscrowComplete || next == StateSolutionsPromised {
-			return true, nil
-		}
-	case StateEscrowPublished, StateSolutionPublished:
-		return false, fmt.Errorf("cannot advance past the final stage: "+
-			"requested %s", stateNames[next])
-	default:
-		if next == s.state+1 {
-			return true, nil
-		}
-	}
-	return false, fmt.Errorf("not ready to advance to %s from %s",
-		stateNames[next], stateNames[s.state])
-}
-
 func (s *Session) FinalizeExchange(ctx context.Context, reason int, details error) {
 	// XXX: Perform final cleanup depending on the state of the contract.
-	if reason == ReasonSuccess && (s.state != StateEscrowPublished &&
-		s.state != StateSolutionPublished) {
+	if reason == ReasonSuccess && !finalState(s.state) {
 		panic("no reason for success")
 	}
 
@@ -141,6 +175,11 @@ func (s *Session) FinalizeExchange(ctx context.Context, reason int, details erro
 	}
 
 	s.tb.Disconnect(s)
+	s.releaseBudget()
+	s.tb.unbindEpoch(s)
+	s.accountExchange(reason)
+	s.abandonScripts()
+	s.tb.metrics.exchanges.With(reasonNames[reason]).Inc()
 
 	logf := log.Info
 	message := fmt.Sprintf("Finalizing exchange for %s", s.String())
@@ -155,6 +194,34 @@ func (s *Session) FinalizeExchange(ctx context.Context, reason int, details erro
 		message += fmt.Sprintf(": %v", s.err)
 	}
 	logf(message)
+
+	level, err := "info", details
+	if reason != ReasonSuccess {
+		level = "error"
+	}
+	if err == nil {
+		err = s.err
+	}
+	s.emitEvent(level, reasonNames[reason], err)
+}
+
+// CheckSequence makes sure the request sequence number is greater than
+// the one of any request previously accepted in the session and records
+// it. ErrReplay is returned for replayed and reordered requests. Caller
+// must hold the session lock.
+func (s *Session) CheckSequence(seq uint64) error {
+	if seq <= s.sequence {
+		log.Warnf("Rejected request with sequence number %d for %s, "+
+			"last accepted %d", seq, s.String(), s.sequence)
+		return ErrReplay
+	}
+	s.sequence = seq
+	return nil
+}
+
+// Finalized returns true if the exchange has been finalized.
+func (s *Session) Finalized() bool {
+	return atomic.LoadInt32(&s.finsema) != 0
 }
 
 // TryLock attempts to acquire the semaphore and returns true if successful
@@ -163,13 +230,90 @@ func (s *Session) TryLock() bool {
 	return atomic.CompareAndSwapInt32(&s.sersema, 0, 1)
 }
 
+// TryLockRequest is like TryLock but records the ID of the request served
+// with the context, if any, so that it's included in the logs and events
+// of the session until the semaphore is released.
+func (s *Session) TryLockRequest(ctx context.Context) bool {
+	if !s.TryLock() {
+		return false
+	}
+	s.request.Store(RequestID(ctx))
+	return true
+}
+
 // Unlock releases the semaphore but panics if it was already released.
 func (s *Session) Unlock() {
+	if s.requestID() != "" {
+		s.request.Store("")
+	}
 	if atomic.SwapInt32(&s.sersema, 0) == 0 {
 		panic("semaphore was already released")
 	}
 }
 
+// requestID returns the ID of the request being served for the session.
+func (s *Session) requestID() string {
+	id, _ := s.request.Load().(string)
+	return id
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying the ID of the request served
+// with it.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the ID of the request served with the context or an
+// empty string.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// saveContract records the contract in the journal so that published
+// transactions can be recovered after a restart. Failures are logged but
+// don't interrupt the exchange.
+func (s *Session) saveContract() {
+	if s.tb.journal == nil || s.contract == nil {
+		return
+	}
+	if err := s.tb.journal.Save(s.contract); err != nil {
+		log.Warnf("Failed to journal the contract of %s: %v", s.String(),
+			err)
+	}
+}
+
+// State returns the name of the current state of the exchange.
+func (s *Session) State() string {
+	return stateNames[s.state]
+}
+
+// SessionStatus describes the progress of an exchange to a client resuming
+// the session.
+type SessionStatus struct {
+	State            string
+	Epoch            int32
+	Expires          time.Time
+	ChannelRemaining contract.Amount
+	Sequence         uint64
+}
+
+// Status returns the progress of the exchange.
+func (s *Session) Status() *SessionStatus {
+	st := &SessionStatus{
+		State:    stateNames[s.state],
+		Epoch:    s.epoch,
+		Expires:  s.expire,
+		Sequence: s.sequence,
+	}
+	if s.channel != nil {
+		st.ChannelRemaining = s.channel.Remaining()
+	}
+	return st
+}
+
 func (s *Session) String() string {
 	if len(s.address) == 0 {
 		return "not initialized"
@@ -185,5 +329,8 @@ func (s *Session) String() string {
 		}
 		str += s.expire.Format("2006-01-02 15:04:05.999")
 	}
+	if id := s.requestID(); id != "" {
+		str += " request " + id
+	}
 	return str
 }
@@ -0,0 +1,204 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumbler
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/decred/tumblebit/puzzle"
+)
+
+// Requests for solution promises cost the tumbler a private key operation
+// per puzzle while clients create puzzles for free. Clients starting new
+// sessions are limited to a quota of puzzles per epoch and may be required
+// to attach a proof of work to their requests. The work spent on puzzles
+// of an epoch is accounted along with the epoch and forgotten once it
+// expires. Requests are served a few at a time, rounds of payment channels
+// first since their escrow has already been confirmed.
+
+var (
+	// ErrQuotaExceeded is returned when a client requests solution
+	// promises for more puzzles than its quota of the epoch allows.
+	ErrQuotaExceeded = errors.New("puzzle quota of the epoch exceeded")
+
+	// ErrInsufficientWork is returned when solution promises are
+	// requested without the proof of work required by the tumbler.
+	ErrInsufficientWork = errors.New("insufficient proof of work")
+)
+
+const (
+	// ClientPuzzleQuota is the default number of puzzles a client may
+	// have solved for new sessions within an epoch.
+	ClientPuzzleQuota = 20 * (RealPreimageCount + FakePreimageCount)
+
+	// SolverConcurrency is the default number of requests for solution
+	// promises served at a time.
+	SolverConcurrency = 2
+)
+
+// SolverPolicy protects the tumbler from clients exhausting its CPU with
+// requests for solution promises.
+type SolverPolicy struct {
+	// ClientQuota limits the number of puzzles a client may have
+	// solved for new sessions within an epoch. Unlimited if zero.
+	ClientQuota int
+
+	// WorkBits is the difficulty in bits of the proof of work attached
+	// to requests starting new sessions. No work is required if zero.
+	WorkBits int
+
+	// Concurrency limits the number of requests served at a time.
+	// Unlimited if zero.
+	Concurrency int
+}
+
+// Validate makes sure that the policy is sensible.
+func (p *SolverPolicy) Validate() error {
+	switch {
+	case p.ClientQuota < 0:
+		return errors.New("client puzzle quota must not be negative")
+	case p.ClientQuota > 0 &&
+		p.ClientQuota < RealPreimageCount+FakePreimageCount:
+		return errors.New("client puzzle quota must allow for a " +
+			"single exchange")
+	case p.WorkBits < 0 || p.WorkBits > puzzle.MaxWorkBits:
+		return errors.New("proof of work difficulty out of range")
+	case p.Concurrency < 0:
+		return errors.New("solver concurrency must not be negative")
+	}
+	return nil
+}
+
+// solverLedger accounts the work spent on puzzles of an epoch.
+type solverLedger struct {
+	puzzles map[string]int                // Keyed by client
+	stamps  map[puzzle.WorkStamp]struct{} // Accepted proofs of work
+}
+
+// chargeSolver accounts the puzzles of a new session against the quota of
+// the client in the epoch, after checking the proof of work attached to
+// the request.
+func (tb *Tumbler) chargeSolver(sc *SolutionChallenges) error {
+	p := &tb.solverPolicy
+	client := sc.Client
+	var stamp puzzle.WorkStamp
+	if p.WorkBits > 0 {
+		var ok bool
+		stamp, ok = puzzle.CheckWork(sc.Epoch, sc.Puzzles, sc.Work,
+			p.WorkBits)
+		if !ok {
+			tb.metrics.solverRejections.With("work").Inc()
+			return ErrInsufficientWork
+		}
+	}
+
+	tb.epochMu.Lock()
+	defer tb.epochMu.Unlock()
+	var e *Epoch
+	for _, epoch := range tb.epochs {
+		if epoch.BlockHeight == sc.Epoch {
+			e = epoch
+			break
+		}
+	}
+	if e == nil {
+		return ErrEpochNotFound
+	}
+	l := &e.solver
+	if p.WorkBits > 0 {
+		if _, ok := l.stamps[stamp]; ok {
+			tb.metrics.solverRejections.With("replay").Inc()
+			return ErrReplay
+		}
+	}
+	if p.ClientQuota > 0 && client != "" &&
+		l.puzzles[client]+len(sc.Puzzles) > p.ClientQuota {
+		tb.metrics.solverRejections.With("quota").Inc()
+		return ErrQuotaExceeded
+	}
+
+	if p.WorkBits > 0 {
+		if l.stamps == nil {
+			l.stamps = make(map[puzzle.WorkStamp]struct{})
+		}
+		l.stamps[stamp] = struct{}{}
+	}
+	if p.ClientQuota > 0 && client != "" {
+		if l.puzzles == nil {
+			l.puzzles = make(map[string]int)
+		}
+		l.puzzles[client] += len(sc.Puzzles)
+	}
+	return nil
+}
+
+// solverQueue limits the number of requests for solution promises served
+// at a time. Waiting requests with priority are served first.
+type solverQueue struct {
+	mu      sync.Mutex
+	limit   int
+	busy    int
+	waiting [2][]chan struct{} // Requests with priority first
+}
+
+// acquire waits until the request may be served. The slot must be
+// released once it has been served unless an error is returned.
+func (q *solverQueue) acquire(ctx context.Context, priority bool) error {
+	if q.limit <= 0 {
+		return nil
+	}
+	q.mu.Lock()
+	if q.busy < q.limit {
+		q.busy++
+		q.mu.Unlock()
+		return nil
+	}
+	i := 1
+	if priority {
+		i = 0
+	}
+	ready := make(chan struct{})
+	q.waiting[i] = append(q.waiting[i], ready)
+	q.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+	}
+	q.mu.Lock()
+	for j, c := range q.waiting[i] {
+		if c == ready {
+			q.waiting[i] = append(q.waiting[i][:j],
+				q.waiting[i][j+1:]...)
+			q.mu.Unlock()
+			return ctx.Err()
+		}
+	}
+	q.mu.Unlock()
+	// The slot was handed over while the context was cancelled.
+	q.release()
+	return ctx.Err()
+}
+
+// release hands the slot over to the next waiting request.
+func (q *solverQueue) release() {
+	if q.limit <= 0 {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i := range q.waiting {
+		if len(q.waiting[i]) > 0 {
+			ready := q.waiting[i][0]
+			q.waiting[i] = q.waiting[i][1:]
+			close(ready)
+			return
+		}
+	}
+	q.busy--
+}
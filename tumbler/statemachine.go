@@ -0,0 +1,210 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumbler
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The exchange of a session advances through the states of the
+// Puzzle-Promise protocol for payees or of the Puzzle-Solver protocol for
+// payers. Every allowed advance is listed in the transition table, along
+// with a guard rejecting it under the current conditions of the session or
+// the tumbler. Hooks run when an exchange leaves or enters a state and
+// when a particular transition is taken. Once the exchange has advanced,
+// the state observers of the tumbler, which keep the session metrics and
+// the audit trail, are notified.
+
+// Transition is an advance of an exchange from one state to another.
+type Transition struct {
+	From int
+	To   int
+
+	// Guard, if set, rejects the transition with an error unless the
+	// session may take it.
+	Guard func(s *Session) error
+
+	// Action, if set, runs once the transition has been taken.
+	Action func(s *Session)
+}
+
+// StateHooks are the actions run when an exchange leaves or enters a
+// state.
+type StateHooks struct {
+	Exit  func(s *Session)
+	Entry func(s *Session)
+}
+
+// errChannelClosed is returned when the payer requests another round of the
+// Puzzle-Solver protocol over a payment channel that doesn't take further
+// payments.
+var errChannelClosed = errors.New("payment channel doesn't take further " +
+	"payments")
+
+var transitions = []Transition{
+	// Payee
+	{From: StateInitial, To: StateEscrowComplete, Guard: admitExchange},
+	{From: StateEscrowComplete, To: StatePuzzlesPromised},
+	{From: StatePuzzlesPromised, To: StatePuzzlesValidated},
+	{From: StatePuzzlesValidated, To: StateEscrowPublished},
+
+	// Payer
+	{From: StateInitial, To: StateSolutionsPromised, Guard: admitExchange},
+	{From: StateSolutionsPromised, To: StateSolutionsValidated},
+	{From: StateSolutionsValidated, To: StateOfferReceived},
+	{From: StateOfferReceived, To: StateSolutionPublished,
+		Action: cashedOut},
+
+	// Payments over a channel skip the confirmation of the offer, allow
+	// for another round of the Puzzle-Solver protocol until the funds of
+	// the channel are exhausted and are cashed out together.
+	{From: StateSolutionsValidated, To: StateSolutionPublished,
+		Guard: requireChannel},
+	{From: StateSolutionPublished, To: StateSolutionsPromised,
+		Guard: nextChannelPayment},
+	{From: StateSolutionPublished, To: StateSolutionPublished,
+		Guard: requireChannel, Action: cashedOut},
+}
+
+var stateHooks = map[int]StateHooks{
+	StateEscrowPublished: {Entry: publishedEscrow},
+}
+
+// Transitions returns the table of transitions exchanges may take.
+func Transitions() []Transition {
+	t := make([]Transition, len(transitions))
+	copy(t, transitions)
+	return t
+}
+
+// Hooks returns the hooks of the state.
+func Hooks(state int) StateHooks {
+	return stateHooks[state]
+}
+
+// StateName returns the name of the state.
+func StateName(state int) string {
+	if state < 0 || state >= len(stateNames) {
+		return fmt.Sprintf("State(%d)", state)
+	}
+	return stateNames[state]
+}
+
+// findTransition returns the transition between the states or nil if the
+// exchange may not take it.
+func findTransition(from, to int) *Transition {
+	for i := range transitions {
+		if transitions[i].From == from && transitions[i].To == to {
+			return &transitions[i]
+		}
+	}
+	return nil
+}
+
+// finalState returns whether an exchange in the state has completed.
+func finalState(state int) bool {
+	return state == StateEscrowPublished || state == StateSolutionPublished
+}
+
+// ready makes sure the exchange may advance to the next state before the
+// request advancing it is served.
+func (s *Session) ready(next int) (bool, error) {
+	t := findTransition(s.state, next)
+	if t == nil {
+		if finalState(s.state) {
+			return false, fmt.Errorf("cannot advance past the final "+
+				"stage: requested %s", StateName(next))
+		}
+		return false, fmt.Errorf("not ready to advance to %s from %s",
+			StateName(next), StateName(s.state))
+	}
+	if t.Guard != nil {
+		if err := t.Guard(s); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// setState advances the exchange to the next state. Guards must have been
+// checked with ready, advancing along a transition missing from the table
+// is a programming error.
+func (s *Session) setState(state int) {
+	from := s.state
+	t := findTransition(from, state)
+	if t == nil {
+		panic(fmt.Sprintf("invalid transition from %s to %s",
+			StateName(from), StateName(state)))
+	}
+
+	if exit := stateHooks[from].Exit; exit != nil {
+		exit(s)
+	}
+	s.enterStage(state)
+	s.state = state
+	if t.Action != nil {
+		t.Action(s)
+	}
+	if entry := stateHooks[state].Entry; entry != nil {
+		entry(s)
+	}
+
+	for _, observe := range s.tb.stateObservers {
+		observe(s, from, state)
+	}
+}
+
+// stateObserver is notified of every transition taken by an exchange.
+type stateObserver func(s *Session, from, to int)
+
+// emitStateEvent records the state the exchange has advanced to in the
+// audit trail of the session.
+func emitStateEvent(s *Session, from, to int) {
+	s.emitEvent("info", "", nil)
+}
+
+// admitExchange rejects new exchanges and payment rounds during shutdown
+// and while the wallet is unreachable.
+func admitExchange(s *Session) error {
+	if s.tb.Draining() {
+		return ErrShuttingDown
+	}
+	if !s.tb.WalletAvailable() {
+		return ErrWalletUnavailable
+	}
+	return nil
+}
+
+// requireChannel rejects transitions reserved to payment channels.
+func requireChannel(s *Session) error {
+	if s.channel == nil {
+		return errors.New("no payment channel has been established")
+	}
+	return nil
+}
+
+// nextChannelPayment allows for another round of the Puzzle-Solver protocol
+// until the funds of the channel are exhausted or its cash-out is queued.
+func nextChannelPayment(s *Session) error {
+	if s.channel == nil || s.channel.Exhausted() || s.cashOutQueued {
+		return errChannelClosed
+	}
+	return admitExchange(s)
+}
+
+// publishedEscrow journals and accounts the escrow of the payee once it's
+// published.
+func publishedEscrow(s *Session) {
+	s.saveContract()
+	s.accountEscrow()
+}
+
+// cashedOut journals and accounts the offer once the solution fulfilling
+// it is published.
+func cashedOut(s *Session) {
+	s.saveContract()
+	s.accountCashOut()
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumbler
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"time"
+)
+
+// Session tokens handed out to clients consist of the session cookie and
+// the expiration time of the session authenticated with HMAC-SHA256 under
+// a key known only to the tumbler. Forged tokens and tokens of expired
+// sessions are rejected without consulting the session table.
+const (
+	cookieSize = 16
+	tokenSize  = cookieSize + 8 + sha256.Size
+)
+
+// newTokenKey generates a random key authenticating session tokens. Since
+// sessions aren't persisted, tokens don't need to survive a restart.
+func newTokenKey() []byte {
+	key := make([]byte, sha256.Size)
+	rand.Read(key)
+	return key
+}
+
+// tokenMAC computes the authenticator of the token payload.
+func (tb *Tumbler) tokenMAC(payload []byte) []byte {
+	mac := hmac.New(sha256.New, tb.tokenKey)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// issueToken returns a token authenticating the session cookie until the
+// expiration time.
+func (tb *Tumbler) issueToken(cookie [cookieSize]byte, expire time.Time) []byte {
+	token := make([]byte, cookieSize+8, tokenSize)
+	copy(token, cookie[:])
+	binary.BigEndian.PutUint64(token[cookieSize:], uint64(expire.Unix()))
+	return append(token, tb.tokenMAC(token)...)
+}
+
+// verifyToken returns the session cookie carried by the token if the
+// token is authentic and hasn't expired.
+func (tb *Tumbler) verifyToken(token []byte) ([cookieSize]byte, bool) {
+	var cookie [cookieSize]byte
+	if len(token) != tokenSize {
+		return cookie, false
+	}
+	payload := token[:cookieSize+8]
+	if !hmac.Equal(token[cookieSize+8:], tb.tokenMAC(payload)) {
+		return cookie, false
+	}
+	expire := int64(binary.BigEndian.Uint64(payload[cookieSize:]))
+	if time.Now().Unix() > expire {
+		return cookie, false
+	}
+	copy(cookie[:], payload)
+	return cookie, true
+}
@@ -7,12 +7,11 @@
 package tumbler
 
 import (
-	"container/list"
 	"context"
 	"crypto/rand"
 	"errors"
 	"fmt"
-	"math"
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -20,41 +19,118 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/tumblebit/contract"
+	"github.com/decred/tumblebit/metrics"
 	"github.com/decred/tumblebit/puzzle"
-	"github.com/decred/tumblebit/wallet"
 )
 
+// ConfirmationInterval is the expected time between two blocks when the
+// network parameters don't specify one. It also determines how soon
+// clients are advised to retry requests that couldn't be served.
 const ConfirmationInterval = 5 * time.Minute
 
+// DrainTimeout is the default amount of time the tumbler waits for active
+// exchanges to complete during shutdown.
+const DrainTimeout = 3 * ConfirmationInterval
+
 // Tumbler describes an instance of a TumbleBit server.
 type Tumbler struct {
-	lastEpoch int32
+	lastEpoch  int32
+	height     int32 // atomic
+	draining   int32 // atomic
+	walletDown int32 // atomic
+
+	// Signaled when a new block is attached to the blockchain.
+	blocks chan struct{}
 
 	epochMu sync.RWMutex
 	epochs  []*Epoch
 
-	sessMu   sync.RWMutex
-	sessions map[[16]byte]*Session
+	sessions sessionTable
+	sched    scheduler
+	tokenKey []byte // Authenticates session tokens
+
+	identityKey *IdentityKey // Signs epoch manifests
+
+	watchMu sync.Mutex
+	watches map[*Session][]*deferredAction
 
-	tickerMu sync.Mutex
-	actions  *list.List
-	pending  *list.List
+	alertMu sync.Mutex
+	alerts  []*Alert
+
+	accountMu sync.Mutex
+	accounts  map[int32]*EpochAccount
+
+	scripts   scriptTracker
+	solutions solutionTracker
+	solver    solverQueue
 
 	epochDuration    int32
 	epochRenewal     int32
+	keyRetention     int32
+	blockTime        time.Duration
+	lockType         contract.LockType
 	puzzleDifficulty int
+	puzzleScheme     puzzle.PuzzleScheme
+	keyProvider      puzzle.KeyProvider
+	drainTimeout     time.Duration
+	parallelism      int
+	escrowBudget     int64
+	params           Parameters
+	feePolicy        FeePolicy
+	batchPolicy      BatchPolicy
+	feeBump          FeeBumpPolicy
+	solverPolicy     SolverPolicy
+	deadlines        Deadlines
+	batcher          batcher
 
 	chainParams *chaincfg.Params
-	wallet      *wallet.Wallet
+	wallet      Wallet
+	notifier    ConnectivityNotifier
+	balance     BalanceReporter
+	spends      SpendMonitor
+	resolver    ScriptResolver
+	pruner      ScriptPruner
+	bumper      FeeBumper
+	broadcaster Rebroadcaster
+	refunds     RefundCollector
+	journal     *contract.Journal
+	metrics     *tumblerMetrics
+	events      EventHandler
+	audit       AuditHandler
+
+	// Observers notified of transitions of exchanges.
+	stateObservers []stateObserver
 }
 
 // Config represents configuration options needed to initialize a tumbler.
 type Config struct {
-	ChainParams      *chaincfg.Params
-	EpochDuration    int32
-	EpochRenewal     int32
-	PuzzleDifficulty int
-	Wallet           *wallet.Wallet
+	ChainParams          *chaincfg.Params
+	EpochDuration        int32
+	EpochRenewal         int32
+	KeyRetention         int32
+	RelativeLockTime     bool
+	PuzzleDifficulty     int
+	ConfirmationInterval time.Duration
+	PuzzleScheme         puzzle.PuzzleScheme
+	KeyProvider          puzzle.KeyProvider
+	DrainTimeout         time.Duration
+	Parallelism          int
+	EscrowBudget         int64
+	ScriptPruneDepth     int32
+	Parameters           *Parameters
+	FeePolicy            *FeePolicy
+	BatchPolicy          *BatchPolicy
+	FeeBump              *FeeBumpPolicy
+	SolverPolicy         *SolverPolicy
+	Deadlines            *Deadlines
+	Wallet               Wallet
+	Journal              *contract.Journal
+	Metrics              *metrics.Registry
+	Events               EventHandler
+	Audit                AuditHandler
+	IdentityKey          *IdentityKey
 }
 
 // NewTumbler creates a new configured tumbler server object associated
@@ -63,34 +139,222 @@ func NewTumbler(cfg *Config) *Tumbler {
 	t := Tumbler{
 		epochDuration:    cfg.EpochDuration,
 		epochRenewal:     cfg.EpochRenewal,
+		keyRetention:     cfg.KeyRetention,
+		blockTime:        cfg.ConfirmationInterval,
 		puzzleDifficulty: cfg.PuzzleDifficulty,
+		puzzleScheme:     cfg.PuzzleScheme,
+		keyProvider:      cfg.KeyProvider,
+		drainTimeout:     cfg.DrainTimeout,
+		parallelism:      cfg.Parallelism,
+		escrowBudget:     cfg.EscrowBudget,
+		params:           DefaultParameters(),
 		chainParams:      cfg.ChainParams,
 		wallet:           cfg.Wallet,
-		sessions:         make(map[[16]byte]*Session),
-		actions:          list.New(),
-		pending:          list.New(),
+		journal:          cfg.Journal,
+		events:           cfg.Events,
+		audit:            cfg.Audit,
+		identityKey:      cfg.IdentityKey,
+	}
+	if cfg.RelativeLockTime {
+		t.lockType = contract.RelativeLock
+	}
+	t.sessions.init()
+	t.sched.init()
+	t.tokenKey = newTokenKey()
+	registry := cfg.Metrics
+	if registry == nil {
+		registry = metrics.NewRegistry()
+	}
+	t.metrics = newTumblerMetrics(registry, &t)
+	t.stateObservers = []stateObserver{
+		t.metrics.observeState,
+		emitStateEvent,
+	}
+	t.notifier, _ = cfg.Wallet.(ConnectivityNotifier)
+	t.balance, _ = cfg.Wallet.(BalanceReporter)
+	t.spends, _ = cfg.Wallet.(SpendMonitor)
+	t.resolver, _ = cfg.Wallet.(ScriptResolver)
+	t.pruner, _ = cfg.Wallet.(ScriptPruner)
+	t.bumper, _ = cfg.Wallet.(FeeBumper)
+	t.broadcaster, _ = cfg.Wallet.(Rebroadcaster)
+	t.refunds, _ = cfg.Wallet.(RefundCollector)
+	if cfg.Metrics != nil && t.wallet != nil {
+		t.wallet = &meteredWallet{Wallet: t.wallet, m: t.metrics}
+	}
+	if cfg.Parameters != nil {
+		t.params = *cfg.Parameters
+	}
+	if cfg.FeePolicy != nil {
+		t.feePolicy = *cfg.FeePolicy
+	}
+	if cfg.BatchPolicy != nil {
+		t.batchPolicy = *cfg.BatchPolicy
+	}
+	if cfg.FeeBump != nil {
+		t.feeBump = *cfg.FeeBump
+	}
+	if cfg.SolverPolicy != nil {
+		t.solverPolicy = *cfg.SolverPolicy
 	}
+	t.solver.limit = t.solverPolicy.Concurrency
+	if cfg.Deadlines != nil {
+		t.deadlines = *cfg.Deadlines
+	}
+	t.batcher.flush = make(chan struct{}, 1)
+	if t.puzzleScheme == nil {
+		t.puzzleScheme = puzzle.RSA
+	}
+	if t.keyProvider == nil {
+		t.keyProvider = puzzle.MemoryProvider
+	}
+	if t.epochDuration == 0 {
+		t.epochDuration = EpochDuration
+	}
+	if t.epochRenewal == 0 {
+		t.epochRenewal = EpochRenewal
+	}
+	if t.parallelism <= 0 {
+		t.parallelism = runtime.GOMAXPROCS(0)
+	}
+	t.scripts.depth = cfg.ScriptPruneDepth
+	if t.scripts.depth <= 0 {
+		t.scripts.depth = ScriptPruneDepth
+	}
+	t.blocks = make(chan struct{}, 1)
 	return &t
 }
 
+// Parameters returns protocol parameters used by the tumbler.
+func (tb *Tumbler) Parameters() Parameters {
+	return tb.params
+}
+
+// Run starts the tumbler services and blocks until the context is
+// cancelled or one of them fails. Upon cancellation the tumbler is drained:
+// new exchanges are rejected while active ones are given a chance to
+// complete before Run returns.
 func (tb *Tumbler) Run(ctx context.Context) error {
-	g, ctx := errgroup.WithContext(ctx)
+	// Services must outlive the context while the tumbler is drained.
+	wctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := tb.loadScripts(); err != nil {
+		log.Warnf("Failed to load imported escrow scripts: %v", err)
+	}
+
+	g, wctx := errgroup.WithContext(wctx)
 	g.Go(func() error {
-		return tb.epochCreator(ctx)
+		return tb.epochCreator(wctx)
 	})
 	g.Go(func() error {
-		return tb.sessionTicker(ctx)
+		return tb.sessionTicker(wctx)
 	})
-	return g.Wait()
+	g.Go(func() error {
+		return tb.confirmationMonitor(wctx)
+	})
+	if tb.notifier != nil {
+		g.Go(func() error {
+			return tb.connectivityMonitor(wctx)
+		})
+	}
+	if tb.batchPolicy.Enabled() {
+		g.Go(func() error {
+			return tb.cashOutBatcher(wctx)
+		})
+	}
+	if tb.broadcaster != nil {
+		g.Go(func() error {
+			return tb.rebroadcaster(wctx)
+		})
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-wctx.Done():
+		// One of the services has failed.
+		return g.Wait()
+	}
+
+	tb.drain(wctx)
+	cancel()
+	g.Wait()
+	tb.destroyEpochs()
+	return ctx.Err()
 }
 
-// epochCreator is responsible for periodic creation of new epochs to achieve
-// an overlapping effect.
+// Draining returns true if the tumbler is shutting down and doesn't
+// accept new exchanges.
+func (tb *Tumbler) Draining() bool {
+	return atomic.LoadInt32(&tb.draining) != 0
+}
+
+// WalletAvailable returns false if the wallet service is known to be
+// unreachable, in which case new exchanges are not accepted.
+func (tb *Tumbler) WalletAvailable() bool {
+	return atomic.LoadInt32(&tb.walletDown) == 0
+}
+
+// activeSessions returns a snapshot of sessions in progress.
+func (tb *Tumbler) activeSessions() []*Session {
+	return tb.sessions.snapshot()
+}
+
+// drain stops accepting new exchanges and waits for active sessions to
+// reach a safe state. Sessions that have nothing at stake are finalized
+// right away, payment channels are cashed out, while payment offers
+// awaiting confirmation are given until the drain timeout to complete.
+// Escrow transactions of the tumbler are published only at the very end
+// of the exchange, therefore there are no refunds to take care of.
+func (tb *Tumbler) drain(ctx context.Context) {
+	atomic.StoreInt32(&tb.draining, 1)
+	tb.flushCashOuts(ctx)
+
+	deadline := time.Now().Add(tb.drainTimeout)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	log.Infof("Draining %d active sessions", len(tb.activeSessions()))
+
+	for {
+		var busy int
+		for _, s := range tb.activeSessions() {
+			if !s.TryLock() {
+				busy++
+				continue
+			}
+			switch {
+			case s.channel != nil && s.channel.Payments > 0:
+				s.cashOutChannel(ctx)
+			case s.state == StateOfferReceived:
+				busy++
+			default:
+				s.FinalizeExchange(ctx, ReasonShutdown, nil)
+			}
+			s.Unlock()
+		}
+		if busy == 0 || time.Now().After(deadline) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+
+	for _, s := range tb.activeSessions() {
+		s.FinalizeExchange(ctx, ReasonShutdown, nil)
+	}
+	log.Info("Tumbler has been drained")
+}
+
+// epochCreator is responsible for creation of a new epoch every
+// epochRenewal blocks to achieve an overlapping effect. Besides block
+// notifications, the height of the blockchain is polled from the wallet
+// once per block interval.
 func (tb *Tumbler) epochCreator(ctx context.Context) error {
-	period := time.Duration(tb.epochRenewal) * ConfirmationInterval
-	ticker := time.NewTicker(period)
+	ticker := time.NewTicker(tb.blockInterval())
 	defer ticker.Stop()
-	log.Infof("Generating epoch every %d seconds", period/time.Second)
+	log.Infof("Generating epoch every %d blocks", tb.epochRenewal)
 
 	// Create one immediately
 	if err := tb.createNewEpoch(); err != nil {
@@ -99,75 +363,176 @@ func (tb *Tumbler) epochCreator(ctx context.Context) error {
 	}
 
 	for {
+		var height int32
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
+		case <-tb.blocks:
+			height = tb.bestHeight()
 		case <-ticker.C:
-			if err := tb.createNewEpoch(); err != nil {
+			var err error
+			if height, err = tb.currentHeight(ctx); err != nil {
 				log.Error(err)
 				continue
 			}
 		}
+		if height < atomic.LoadInt32(&tb.lastEpoch)+tb.epochRenewal {
+			continue
+		}
+		budget := tb.epochBudget(ctx, height)
+		if err := tb.newEpoch(height, budget); err != nil {
+			log.Errorf("Failed to setup new epoch: %v", err)
+			continue
+		}
+		log.Infof("Created new epoch at block height %d", height)
+		tb.batcher.epochBoundary()
+	}
+}
+
+// blockAttached records the height of a new block.
+func (tb *Tumbler) blockAttached(height int32) {
+	tb.setBestHeight(height)
+	select {
+	case tb.blocks <- struct{}{}:
+	default:
 	}
 }
 
 var (
 	ErrEpochNotFound = errors.New("no such epoch")
+
+	// ErrNotConfirmed is returned when the escrow transaction provided
+	// by the client hasn't received enough confirmations yet.
+	ErrNotConfirmed = errors.New("escrow tx isn't confirmed")
+
+	// ErrShuttingDown is returned when a new exchange is requested while
+	// the tumbler is shutting down.
+	ErrShuttingDown = errors.New("tumbler is shutting down")
+
+	// ErrWalletUnavailable is returned when a new exchange is requested
+	// while the wallet service is unreachable.
+	ErrWalletUnavailable = errors.New("wallet is unavailable")
+
+	// ErrReplay is returned when a request carries a sequence number
+	// that has already been used in the session.
+	ErrReplay = errors.New("replayed request")
 )
 
+// EpochPendingError is returned when an escrow requests the next epoch
+// which is about to be created. The escrow may be requested again once
+// the epoch is expected to have been created.
+type EpochPendingError struct {
+	RetryAfter time.Duration
+}
+
+func (e *EpochPendingError) Error() string {
+	return fmt.Sprintf("next epoch is pending, retry in %v", e.RetryAfter)
+}
+
 type Epoch struct {
 	addrMu      sync.RWMutex
 	Address     string
 	Pubkey      string
 	BlockHeight int32
 	puzzleKey   *puzzle.PuzzleKey
+	scheme      puzzle.PuzzleScheme
+	budget      escrowBudget // Protected by the tumbler epochMu
+	sessions    int          // Live sessions, protected by the tumbler epochMu
+	solver      solverLedger // Protected by the tumbler epochMu
 }
 
 // NewEpoch creates a new epoch interval starting at the specified block
 // height which acts as a way to lookup existing epochs as well as to expire
-// old ones. Each new epoch generates a unique puzzle key.
+// old ones. Each new epoch generates a unique puzzle key and uses the puzzle
+// scheme the tumbler is configured with at the time of its creation.
 func (tb *Tumbler) NewEpoch(blockHeight int32) error {
-	// Make sure we're not attempting to setup an epoch that would appear
-	// older or exactly the same as an existing one.
-	if len(tb.epochs) > 0 &&
-		tb.epochs[len(tb.epochs)-1].BlockHeight >= blockHeight {
-		return fmt.Errorf("bad block height: %d", blockHeight)
-	}
-	pk, err := puzzle.GeneratePuzzleKey(tb.puzzleDifficulty)
+	return tb.newEpoch(blockHeight, tb.budgetLimit())
+}
+
+// newEpoch creates a new epoch allowed to escrow up to the budget.
+func (tb *Tumbler) newEpoch(blockHeight int32, budget int64) error {
+	pk, err := tb.keyProvider.GenerateKey(tb.puzzleDifficulty)
 	if err != nil {
 		return err
 	}
 	e := &Epoch{
 		BlockHeight: blockHeight,
 		puzzleKey:   pk,
+		scheme:      tb.puzzleScheme,
+		budget:      escrowBudget{limit: budget},
 	}
 	tb.epochMu.Lock()
-	// Expire old epochs.
-	var n int
-	for i, e := range tb.epochs {
-		if e.BlockHeight+tb.epochDuration < blockHeight {
-			tb.epochs[i] = nil
-			n++
+	// Make sure we're not attempting to setup an epoch that would appear
+	// older or exactly the same as an existing one.
+	if len(tb.epochs) > 0 &&
+		tb.epochs[len(tb.epochs)-1].BlockHeight >= blockHeight {
+		tb.epochMu.Unlock()
+		pk.Zero()
+		return fmt.Errorf("bad block height: %d", blockHeight)
+	}
+	// Expire old epochs once their retention window has passed, unless
+	// live sessions are still bound to them.
+	var expired []*Epoch
+	epochs := tb.epochs[:0]
+	for _, e := range tb.epochs {
+		if tb.retentionPassed(e, blockHeight) && e.sessions == 0 {
+			expired = append(expired, e)
+			continue
 		}
+		epochs = append(epochs, e)
 	}
-	tb.epochs = tb.epochs[n:]
-	tb.epochs = append(tb.epochs, e)
+	for i := len(epochs); i < len(tb.epochs); i++ {
+		tb.epochs[i] = nil
+	}
+	tb.epochs = append(epochs, e)
 
 	atomic.StoreInt32(&tb.lastEpoch, blockHeight)
 	tb.epochMu.Unlock()
+
+	for _, e := range expired {
+		e.destroy("expired")
+	}
 	return nil
 }
 
+// retentionPassed returns whether the puzzle key of the epoch is no longer
+// retained at the block height.
+func (tb *Tumbler) retentionPassed(e *Epoch, blockHeight int32) bool {
+	return e.BlockHeight+tb.epochDuration+tb.keyRetention < blockHeight
+}
+
+// destroy zeroizes the puzzle key of the epoch and records the event in
+// the log along with the fingerprint of the public key.
+func (e *Epoch) destroy(reason string) {
+	var fingerprint []byte
+	if key, err := puzzle.MarshalPubKey(e.puzzleKey); err == nil {
+		fingerprint = chainhash.HashB(key)
+	}
+	e.puzzleKey.Zero()
+	log.Infof("Destroyed puzzle key %x of epoch %d (%s)", fingerprint,
+		e.BlockHeight, reason)
+}
+
+// destroyEpochs removes all epochs and destroys their keys.
+func (tb *Tumbler) destroyEpochs() {
+	tb.epochMu.Lock()
+	epochs := tb.epochs
+	tb.epochs = nil
+	tb.epochMu.Unlock()
+
+	for _, e := range epochs {
+		e.destroy("shutdown")
+	}
+}
+
 func (tb *Tumbler) createNewEpoch() error {
-	blockHeight, err := tb.wallet.CurrentBlockHeight(context.Background())
+	ctx := context.Background()
+	blockHeight, err := tb.currentHeight(ctx)
 	if err != nil {
 		// XXX: Stop tumbler
-		return fmt.Errorf("Wallet failure: %v", err)
-	}
-	if blockHeight > math.MaxInt32 {
-		return fmt.Errorf("Block height is too large: %d", blockHeight)
+		return err
 	}
-	err = tb.NewEpoch(int32(blockHeight))
+	err = tb.newEpoch(blockHeight, tb.epochBudget(ctx, blockHeight))
 	if err != nil {
 		return fmt.Errorf("Failed to setup new epoch: %v", err)
 	}
@@ -175,6 +540,38 @@ func (tb *Tumbler) createNewEpoch() error {
 	return nil
 }
 
+// RotateEpoch immediately creates a new epoch with a fresh puzzle key and
+// epoch address, e.g. when the current puzzle key is suspected to be
+// compromised. New exchanges are steered to the new epoch, while sessions
+// bound to older epochs complete using their keys which are retained until
+// the epochs expire as usual. If an epoch was already created at the
+// current block height, the new one starts at the next height. The height
+// of the new epoch is returned.
+func (tb *Tumbler) RotateEpoch(ctx context.Context) (int32, error) {
+	if tb.Draining() {
+		return 0, ErrShuttingDown
+	}
+	blockHeight, err := tb.currentHeight(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if last := atomic.LoadInt32(&tb.lastEpoch); blockHeight <= last {
+		blockHeight = last + 1
+	}
+	budget := tb.epochBudget(ctx, blockHeight)
+	if err = tb.newEpoch(blockHeight, budget); err != nil {
+		return 0, fmt.Errorf("Failed to setup new epoch: %v", err)
+	}
+	if _, _, err = tb.getEpochAddress(ctx, blockHeight); err != nil {
+		return 0, fmt.Errorf("Failed to allocate epoch address: %v",
+			err)
+	}
+	log.Warnf("Rotated puzzle key, new epoch at block height %d",
+		blockHeight)
+	tb.batcher.epochBoundary()
+	return blockHeight, nil
+}
+
 func (tb *Tumbler) getCurrentEpoch() (int32, error) {
 	if epoch := atomic.LoadInt32(&tb.lastEpoch); epoch != 0 {
 		return epoch, nil
@@ -182,7 +579,14 @@ func (tb *Tumbler) getCurrentEpoch() (int32, error) {
 	return 0, errors.New("no current epoch")
 }
 
+// isValidEpoch returns true if the epoch exists and hasn't expired. Keys of
+// expired epochs may still be retained to complete exchanges in progress,
+// but they must not be used to start new ones.
 func (tb *Tumbler) isValidEpoch(blockHeight int32) bool {
+	last := atomic.LoadInt32(&tb.lastEpoch)
+	if blockHeight+tb.epochDuration < last {
+		return false
+	}
 	tb.epochMu.RLock()
 	for _, e := range tb.epochs {
 		if e.BlockHeight == blockHeight {
@@ -194,6 +598,105 @@ func (tb *Tumbler) isValidEpoch(blockHeight int32) bool {
 	return false
 }
 
+// escrowEpoch returns the epoch new escrows are bound to. Escrows
+// requesting the next epoch within NextEpochWindow blocks of its creation
+// are rejected with an EpochPendingError, so that they aren't bound to an
+// epoch about to be superseded.
+func (tb *Tumbler) escrowEpoch(next bool) (int32, error) {
+	epoch, err := tb.getCurrentEpoch()
+	if err != nil {
+		return 0, err
+	}
+	if !next {
+		return epoch, nil
+	}
+	height := tb.bestHeight()
+	if height < epoch {
+		height = epoch
+	}
+	// The next epoch may also be overdue, waiting for a block.
+	remaining := epoch + tb.epochRenewal - height
+	if remaining <= NextEpochWindow {
+		if remaining < 1 {
+			remaining = 1
+		}
+		return 0, &EpochPendingError{
+			RetryAfter: tb.blocksDuration(remaining),
+		}
+	}
+	return epoch, nil
+}
+
+// bindEpoch binds the session to the epoch and keeps the puzzle key of the
+// epoch from expiring until the session is unbound. A session is bound to
+// a single epoch at a time.
+func (tb *Tumbler) bindEpoch(s *Session, blockHeight int32) error {
+	tb.epochMu.Lock()
+	if s.epochBound && s.epoch == blockHeight {
+		tb.epochMu.Unlock()
+		return nil
+	}
+	var epoch *Epoch
+	for _, e := range tb.epochs {
+		if e.BlockHeight == blockHeight {
+			epoch = e
+			break
+		}
+	}
+	if epoch == nil {
+		tb.epochMu.Unlock()
+		return ErrEpochNotFound
+	}
+	epoch.sessions++
+	expired := tb.unbindEpochLocked(s)
+	s.epoch = blockHeight
+	s.epochBound = true
+	tb.epochMu.Unlock()
+
+	if expired != nil {
+		expired.destroy("expired")
+	}
+	return nil
+}
+
+// unbindEpoch releases the epoch the session is bound to. The epoch
+// expires as soon as the last session bound to it is released if its
+// retention window has passed in the meantime.
+func (tb *Tumbler) unbindEpoch(s *Session) {
+	tb.epochMu.Lock()
+	expired := tb.unbindEpochLocked(s)
+	tb.epochMu.Unlock()
+
+	if expired != nil {
+		expired.destroy("expired")
+	}
+}
+
+// unbindEpochLocked releases the epoch the session is bound to and
+// returns the epoch if it has been removed. It must be called with the
+// epochMu held.
+func (tb *Tumbler) unbindEpochLocked(s *Session) *Epoch {
+	if !s.epochBound {
+		return nil
+	}
+	s.epochBound = false
+	for i, e := range tb.epochs {
+		if e.BlockHeight != s.epoch {
+			continue
+		}
+		e.sessions--
+		last := atomic.LoadInt32(&tb.lastEpoch)
+		if e.sessions > 0 || !tb.retentionPassed(e, last) {
+			return nil
+		}
+		copy(tb.epochs[i:], tb.epochs[i+1:])
+		tb.epochs[len(tb.epochs)-1] = nil
+		tb.epochs = tb.epochs[:len(tb.epochs)-1]
+		return e
+	}
+	return nil
+}
+
 // getEpochAddress allocates a new external address on demand or returns
 // one that was previously allocated.
 func (tb *Tumbler) getEpochAddress(ctx context.Context, blockHeight int32) (string, string, error) {
@@ -237,7 +740,7 @@ func (tb *Tumbler) getEpochAddress(ctx context.Context, blockHeight int32) (stri
 	}
 
 	// Allocate new external address
-	addr, pkey, err := tb.wallet.GetExtAddress(ctx)
+	addr, pkey, err := tb.wallet.GetCashOutAddress(ctx)
 	if err != nil {
 		return "", "", err
 	}
@@ -246,15 +749,26 @@ func (tb *Tumbler) getEpochAddress(ctx context.Context, blockHeight int32) (stri
 	return addr, pkey, nil
 }
 
-func (tb *Tumbler) getPuzzleKey(blockHeight int32) (puzzle.PuzzleKey, error) {
+func (tb *Tumbler) getPuzzleKey(blockHeight int32) (*puzzle.PuzzleKey, error) {
+	tb.epochMu.RLock()
+	defer tb.epochMu.RUnlock()
+	for _, e := range tb.epochs {
+		if e.BlockHeight == blockHeight {
+			return e.puzzleKey, nil
+		}
+	}
+	return nil, ErrEpochNotFound
+}
+
+func (tb *Tumbler) getPuzzleScheme(blockHeight int32) (puzzle.PuzzleScheme, error) {
 	tb.epochMu.RLock()
 	defer tb.epochMu.RUnlock()
 	for _, e := range tb.epochs {
 		if e.BlockHeight == blockHeight {
-			return *e.puzzleKey, nil
+			return e.scheme, nil
 		}
 	}
-	return puzzle.PuzzleKey{}, ErrEpochNotFound
+	return nil, ErrEpochNotFound
 }
 
 // ChainParams returns the network parameters for the blockchain
@@ -263,52 +777,47 @@ func (tb *Tumbler) ChainParams() *chaincfg.Params {
 	return tb.chainParams
 }
 
-// Connect associates session with a tumbler service.
+// Connect associates session with a tumbler service, schedules its
+// expiration and issues the session token.
 func (tb *Tumbler) Connect(s *Session) [16]byte {
 	var cookie [16]byte
 
 	s.tb = tb
 
-	tb.sessMu.Lock()
 	for {
 		rand.Read(cookie[:])
-		if _, exists := tb.sessions[cookie]; !exists {
+		if tb.sessions.insert(cookie, s) {
 			break
 		}
 	}
-	tb.sessions[cookie] = s
-	tb.sessMu.Unlock()
 
-	tb.tickerMu.Lock()
-	s.explist = tb.pending.PushBack(s)
-	tb.tickerMu.Unlock()
+	s.Token = tb.issueToken(cookie, s.expire)
+	tb.sched.add(&timer{
+		until:   s.expire,
+		session: s,
+		reason:  ReasonSessionExpired,
+	})
+	tb.metrics.sessions.With(stateNames[s.state]).Inc()
 
 	return cookie
 }
 
-// Lookup attempts to locate an active exchange by a cookie.
-func (tb *Tumbler) Lookup(key []byte) (*Session, bool) {
-	var cookie [16]byte
-	copy(cookie[:], key)
-	tb.sessMu.RLock()
-	s, ok := tb.sessions[cookie]
-	tb.sessMu.RUnlock()
-	return s, ok
+// Lookup attempts to locate an active exchange by a session token.
+func (tb *Tumbler) Lookup(token []byte) (*Session, bool) {
+	cookie, ok := tb.verifyToken(token)
+	if !ok {
+		return nil, false
+	}
+	return tb.sessions.lookup(cookie)
 }
 
-// Disconnect removes the session from the lookup table and expiration list.
+// Disconnect removes the session from the lookup table along with its
+// expiration timer and deferred actions.
 func (tb *Tumbler) Disconnect(s *Session) {
-	tb.sessMu.Lock()
-	delete(tb.sessions, s.Cookie)
-	tb.sessMu.Unlock()
-
-	tb.tickerMu.Lock()
-	tb.removeDeferredActions(s)
-	if s.explist != nil {
-		tb.pending.Remove(s.explist)
-		s.explist = nil
-	}
-	tb.tickerMu.Unlock()
+	tb.sessions.remove(s.Cookie)
+	tb.sched.removeSession(s)
+	tb.removeWatches(s)
+	tb.metrics.sessions.With(stateNames[s.state]).Dec()
 }
 
 type deferredAction struct {
@@ -316,10 +825,9 @@ type deferredAction struct {
 	callback func(ctx context.Context, s *Session, arg interface{})
 	argument interface{}
 	until    time.Time
-	entry    *list.Element
 }
 
-// DeferAction adds the session to the ticker's list of deferred actions.
+// DeferAction schedules the callback to be run by the session ticker.
 // Caller must ensure to provide the s.deferFn function pointer.
 func (tb *Tumbler) DeferAction(s *Session, cb func(ctx context.Context, s *Session, arg interface{}), arg interface{}, u time.Time) {
 	a := deferredAction{
@@ -328,36 +836,15 @@ func (tb *Tumbler) DeferAction(s *Session, cb func(ctx context.Context, s *Sessi
 		argument: arg,
 		until:    u,
 	}
-	tb.tickerMu.Lock()
-	tb.actions.PushBack(&a)
-	tb.tickerMu.Unlock()
-}
-
-// removeDeferredActions removes all deferred actions registered for the
-// session.  ticker mutex must be locked by the caller.
-func (tb *Tumbler) removeDeferredActions(s *Session) {
-	var next *list.Element
-	for e := tb.actions.Front(); e != nil; e = next {
-		next = e.Next()
-		a := e.Value.(*deferredAction)
-		if a.session == s {
-			tb.actions.Remove(e)
-		}
-	}
-}
-
-func contains(s *Session, list []*Session) bool {
-	for i := range list {
-		if list[i] == s {
-			return true
-		}
-	}
-	return false
+	tb.sched.add(&timer{until: u, session: s, action: &a})
 }
 
+// sessionTicker runs deferred actions and expires sessions as soon as
+// their timers are due. It sleeps until the earliest timer of the
+// scheduler and is woken up whenever an earlier one is added.
 func (tb *Tumbler) sessionTicker(ctx context.Context) error {
-	ticker := time.NewTicker(time.Minute)
-	defer ticker.Stop()
+	wakeup := time.NewTimer(tb.sched.wait(time.Now()))
+	defer wakeup.Stop()
 	log.Info("Started session ticker coroutine")
 
 	g, ctx := errgroup.WithContext(ctx)
@@ -367,78 +854,110 @@ func (tb *Tumbler) sessionTicker(ctx context.Context) error {
 		case <-ctx.Done():
 			log.Debug("Session ticker cancelled")
 			return g.Wait()
-		case now := <-ticker.C:
-			var actions []*deferredAction
-			var expired []*Session
-			var next *list.Element
-
-			tb.tickerMu.Lock()
-			for e := tb.pending.Front(); e != nil; e = next {
-				next = e.Next()
-				s := e.Value.(*Session)
-				if s.expire.Before(now) {
-					tb.pending.Remove(e)
-					expired = append(expired, s)
-				}
-			}
-			for e := tb.actions.Front(); e != nil; e = next {
-				next = e.Next()
-				a := e.Value.(*deferredAction)
-				if contains(a.session, expired) {
-					tb.actions.Remove(e)
-					continue
-				}
-				if a.until.Before(now) {
-					tb.actions.Remove(e)
-					actions = append(actions, a)
-				}
-			}
-			tb.tickerMu.Unlock()
+		case <-tb.sched.wake:
+		case <-wakeup.C:
+		}
+
+		now := time.Now()
+		actions, expired := tb.dueTimers(now)
+		if len(actions) > 0 || len(expired) > 0 {
 			log.Tracef("Session ticker: %d deferred, %d expired",
 				len(actions), len(expired))
-			if len(actions) > 0 {
-				g.Go(func() error {
-					return tb.deferredActions(ctx, actions)
-				})
-			}
-			if len(expired) > 0 {
-				g.Go(func() error {
-					return tb.expireSessions(ctx, expired)
-				})
+		}
+		if len(actions) > 0 {
+			g.Go(func() error {
+				return tb.deferredActions(ctx, actions)
+			})
+		}
+		if len(expired) > 0 {
+			g.Go(func() error {
+				return tb.expireSessions(ctx, expired)
+			})
+		}
+
+		if !wakeup.Stop() {
+			select {
+			case <-wakeup.C:
+			default:
 			}
 		}
+		wakeup.Reset(tb.sched.wait(now))
 	}
-	return g.Wait()
 }
 
-func (tb *Tumbler) deferredActions(ctx context.Context, actions []*deferredAction) error {
-	for _, a := range actions {
-		a.callback(ctx, a.session, a.argument)
+// dueTimers collects deferred actions and expiration timers of sessions
+// expiring before now. Deferred actions of expiring sessions are
+// discarded and only the earliest expiration of a session is reported.
+func (tb *Tumbler) dueTimers(now time.Time) ([]*deferredAction, []*timer) {
+	var actions []*deferredAction
+	var expired []*timer
+
+	timers := tb.sched.due(now)
+	expiring := make(map[*Session]struct{})
+	for _, t := range timers {
+		if t.action != nil {
+			continue
+		}
+		if _, ok := expiring[t.session]; ok {
+			continue
+		}
+		expired = append(expired, t)
+		expiring[t.session] = struct{}{}
+		tb.sched.removeSession(t.session)
+	}
+	for _, t := range timers {
+		if t.action == nil {
+			continue
+		}
+		if _, ok := expiring[t.session]; !ok {
+			actions = append(actions, t.action)
+		}
+	}
+	return actions, expired
+}
 
+// deferredActions runs due actions in the order of their deadlines. The
+// context is checked before every action, so none of them is started with
+// a cancelled context. Actions left once it's cancelled are requeued with
+// their original deadlines to be run when the ticker resumes, unless
+// their sessions have been finalized in the meantime.
+func (tb *Tumbler) deferredActions(ctx context.Context, actions []*deferredAction) error {
+	for i, a := range actions {
 		select {
 		case <-ctx.Done():
-			// XXX: remaining deferred actions aren't processed correctly
-			log.Info("Deferred action processing has been cancelled")
+			n := tb.requeueActions(actions[i:])
+			log.Infof("Deferred action processing has been cancelled, "+
+				"%d of %d remaining actions requeued", n,
+				len(actions)-i)
 			return ctx.Err()
 		default:
-			continue
 		}
+
+		a.callback(ctx, a.session, a.argument)
 	}
 	return nil
 }
 
-func (tb *Tumbler) expireSessions(ctx context.Context, expired []*Session) error {
-	for _, s := range expired {
-		s.FinalizeExchange(ctx, ReasonSessionExpired, nil)
-
-		select {
-		case <-ctx.Done():
-			// XXX: remaining expired sessions aren't finalized correctly
-			log.Info("Session expiration process has been cancelled")
-			return ctx.Err()
-		default:
-			continue
+// requeueActions schedules the actions again and returns the number of
+// actions whose sessions are still active.
+func (tb *Tumbler) requeueActions(actions []*deferredAction) int {
+	var n int
+	for _, a := range actions {
+		t := &timer{until: a.until, session: a.session, action: a}
+		if tb.sched.requeue(t) {
+			n++
 		}
 	}
+	return n
+}
+
+// expireSessions finalizes sessions whose deadlines have passed with the
+// reasons of their timers. Finalization doesn't depend on the context,
+// therefore all sessions are finalized even if it's cancelled, leaving
+// no expired session active.
+func (tb *Tumbler) expireSessions(ctx context.Context, expired []*timer) error {
+	for _, t := range expired {
+		t.session.FinalizeExchange(ctx, t.reason, nil)
+	}
 	return nil
 }
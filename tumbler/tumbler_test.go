@@ -10,19 +10,27 @@ import (
 	"crypto/rand"
 	"errors"
 	"math/big"
+	"reflect"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/decred/dcrd/chaincfg/chainec"
 	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/tumblebit/contract"
 	"github.com/decred/tumblebit/puzzle"
 	"github.com/decred/tumblebit/shuffle"
 )
 
 func TestPuzzlePromiseAndSolver(t *testing.T) {
+	var audits []*AuditRecord
 	cfg := Config{
 		EpochDuration:    EpochDuration,
 		EpochRenewal:     EpochRenewal,
 		PuzzleDifficulty: PuzzleDifficulty,
+		Audit: func(rec *AuditRecord) {
+			audits = append(audits, rec)
+		},
 	}
 
 	tb := NewTumbler(&cfg)
@@ -60,6 +68,32 @@ func TestPuzzlePromiseAndSolver(t *testing.T) {
 		t.Logf("unblinded %x\n", unblinded)
 		t.Fatal("puzzle protocol failed")
 	}
+
+	// Both fairness tests are recorded for the audit.
+	if len(audits) != 2 || audits[0].Protocol != AuditPuzzlePromise ||
+		audits[1].Protocol != AuditPuzzleSolver {
+		t.Fatalf("unexpected audit records: %+v", audits)
+	}
+	for _, rec := range audits {
+		if rec.Epoch != epoch {
+			t.Fatalf("audit record of epoch %d", rec.Epoch)
+		}
+		if err := VerifyAuditRecord(rec); err != nil {
+			t.Fatalf("failed to verify %s audit record: %v",
+				rec.Protocol, err)
+		}
+	}
+	rec := *audits[0]
+	rec.RealTxList, rec.FakeTxList = rec.FakeTxList, rec.RealTxList
+	if err := VerifyAuditRecord(&rec); err == nil {
+		t.Fatal("audit record with swapped index lists verified")
+	}
+	rec = *audits[0]
+	rec.Quotients = append([][]byte{}, rec.Quotients...)
+	rec.Quotients[1] = rec.Quotients[2]
+	if err := VerifyAuditRecord(&rec); err == nil {
+		t.Fatal("audit record with a bad quotient verified")
+	}
 }
 
 func testPuzzlePromise(t *testing.T, s *Session) (*puzzle.PuzzlePubKey, []byte, []byte) {
@@ -87,17 +121,16 @@ func testPuzzlePromise(t *testing.T, s *Session) (*puzzle.PuzzlePubKey, []byte,
 	}
 
 	// Shuffle transaction list
-	sh := shuffle.Shuffle(rand.Reader, len(txh), func(i, j int) {
+	perm, err := shuffle.ShuffleSecure(rand.Reader, len(txh), func(i, j int) {
 		txh[i], txh[j] = txh[j], txh[i]
 	})
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	// Update list indexes
-	for i := range fakeTxList {
-		fakeTxList[i] = sh.Get(fakeTxList[i])
-	}
-	for i := range realTxList {
-		realTxList[i] = sh.Get(realTxList[i])
-	}
+	perm.Apply(fakeTxList)
+	perm.Apply(realTxList)
 	// Hash them up and serve.
 	fakeSetHash, err := puzzle.HashIndexList(salt[:], fakeTxList)
 	if err != nil {
@@ -139,6 +172,22 @@ func testPuzzlePromise(t *testing.T, s *Session) (*puzzle.PuzzlePubKey, []byte,
 		t.Fatalf("failed to encode real tx indexes: %v", err)
 	}
 
+	// A tampered random pad must be reported along with the offending
+	// transaction without aborting the session.
+	badPads := append([][]byte(nil), randomPads...)
+	badPads[1] = make([]byte, 32)
+	_, err = s.ValidatePuzzles(context.TODO(), &TransactionDisclosure{
+		FakeTxList: fakeTxIndexes,
+		RealTxList: realTxIndexes,
+		RandomPads: badPads,
+		Salt:       salt[:],
+	})
+	verr, ok := err.(*ValidationError)
+	if !ok || verr.Check != CheckFakeTransactions ||
+		len(verr.Indices) != 1 || verr.Indices[0] != fakeTxList[1] {
+		t.Fatalf("unexpected result of a tampered disclosure: %v", err)
+	}
+
 	secrets, err := s.ValidatePuzzles(context.TODO(), &TransactionDisclosure{
 		FakeTxList: fakeTxIndexes,
 		RealTxList: realTxIndexes,
@@ -171,7 +220,19 @@ func testPuzzlePromise(t *testing.T, s *Session) (*puzzle.PuzzlePubKey, []byte,
 	for i, idx := range realTxList {
 		realPuzzles[i] = promise.Puzzles[idx]
 	}
-	if !puzzle.VerifyQuotients(&pkey, secrets.Quotients, realPuzzles) {
+	if s.incremental {
+		// Quotients are withheld and every real puzzle is unlocked
+		// by its own secret.
+		if len(secrets.Quotients) != 1 {
+			t.Fatal("quotients of the real set were revealed")
+		}
+		chain := &puzzle.PromiseChain{Key: &pkey, Puzzles: realPuzzles}
+		for i, idx := range realTxList {
+			if _, err = chain.Unlock(s.secrets[idx]); err != nil {
+				t.Fatalf("failed to unlock real puzzle %d: %v", i, err)
+			}
+		}
+	} else if !puzzle.VerifyQuotients(&pkey, secrets.Quotients, realPuzzles) {
 		t.Fatal("failed to verify quotients")
 	}
 
@@ -184,8 +245,19 @@ func testPuzzlePromise(t *testing.T, s *Session) (*puzzle.PuzzlePubKey, []byte,
 	return &pkey, blinding, inverse
 }
 
-func testPuzzleSolving(t *testing.T, s *Session, pkey *puzzle.PuzzlePubKey,
-	p []byte, epoch int32) []byte {
+// solverChallenge keeps client side state of the Puzzle-Solver protocol.
+type solverChallenge struct {
+	puzzles      [][]byte
+	realFactors  [][]byte
+	realInverses [][]byte
+	realPzList   []int
+	promise      *SolutionPromises
+}
+
+// newSolverChallenge mixes blindings of the puzzle p with fake puzzles,
+// obtains solution promises and validates the fake set.
+func newSolverChallenge(t *testing.T, s *Session, pkey *puzzle.PuzzlePubKey,
+	p []byte, epoch int32) *solverChallenge {
 	var err error
 
 	puzzles := make([][]byte, RealPreimageCount+FakePreimageCount)
@@ -222,17 +294,16 @@ func testPuzzleSolving(t *testing.T, s *Session, pkey *puzzle.PuzzlePubKey,
 	}
 
 	// Shuffle puzzle list
-	sh := shuffle.Shuffle(rand.Reader, len(puzzles), func(i, j int) {
+	perm, err := shuffle.ShuffleSecure(rand.Reader, len(puzzles), func(i, j int) {
 		puzzles[i], puzzles[j] = puzzles[j], puzzles[i]
 	})
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	// Update list indexes
-	for i := range fakePzList {
-		fakePzList[i] = sh.Get(fakePzList[i])
-	}
-	for i := range realPzList {
-		realPzList[i] = sh.Get(realPzList[i])
-	}
+	perm.Apply(fakePzList)
+	perm.Apply(realPzList)
 
 	promise, err := s.GetSolutionPromises(context.TODO(), &SolutionChallenges{
 		Epoch:   epoch,
@@ -276,41 +347,39 @@ func testPuzzleSolving(t *testing.T, s *Session, pkey *puzzle.PuzzlePubKey,
 		}
 	}
 
-	realPzIndexes, err := puzzle.EncodeIndexList(realPzList)
-	if err != nil {
-		t.Fatalf("failed to encode real puzzle indexes: %v", err)
+	return &solverChallenge{
+		puzzles:      puzzles,
+		realFactors:  realFactors,
+		realInverses: realInverses,
+		realPzList:   realPzList,
+		promise:      promise,
 	}
-	s.realPuzzleList = realPzList
+}
 
-	// Reveal blinding factors for real puzzles.
-	solutions, err := s.RevealSolution(context.TODO(), &PaymentOffer{
-		Puzzle:         p,
-		RealPuzzleList: realPzIndexes,
-		RealFactors:    realFactors,
-	})
-	if err != nil {
-		t.Fatal(err)
-	}
-	if len(solutions) != len(realPzList) {
+// solve verifies secrets revealed for real puzzles and returns the
+// unblinded solution of the original puzzle.
+func (sc *solverChallenge) solve(t *testing.T, pkey *puzzle.PuzzlePubKey,
+	solutions [][]byte) []byte {
+	if len(solutions) != len(sc.realPzList) {
 		t.Fatal("obtained wrong amount of solution secrets")
 	}
 	// Verify secret keys
-	puzzleSolutions := make([][]byte, len(realPzList))
-	for i, idx := range realPzList {
+	puzzleSolutions := make([][]byte, len(sc.realPzList))
+	for i, idx := range sc.realPzList {
 		if !bytes.Equal(chainhash.HashB(solutions[i]),
-			promise.KeyHashes[idx]) {
+			sc.promise.KeyHashes[idx]) {
 			t.Fatal("secret hash didn't verify")
 		}
-		solution, err := puzzle.RevealSolution(promise.Promises[idx],
+		solution, err := puzzle.RevealSolution(sc.promise.Promises[idx],
 			solutions[i])
 		if err != nil {
 			t.Fatal(err)
 		}
-		if !puzzle.ValidatePuzzle(pkey, puzzles[idx], solution) {
+		if !puzzle.ValidatePuzzle(pkey, sc.puzzles[idx], solution) {
 			t.Fatal("solution didn't verify")
 		}
 		puzzleSolutions[i] = puzzle.UnblindPuzzle(pkey, solution,
-			realInverses[i])
+			sc.realInverses[i])
 	}
 	for i := 1; i < len(puzzleSolutions); i++ {
 		if !bytes.Equal(puzzleSolutions[i], puzzleSolutions[i-1]) {
@@ -321,6 +390,100 @@ func testPuzzleSolving(t *testing.T, s *Session, pkey *puzzle.PuzzlePubKey,
 	return puzzleSolutions[0]
 }
 
+func testPuzzleSolving(t *testing.T, s *Session, pkey *puzzle.PuzzlePubKey,
+	p []byte, epoch int32) []byte {
+	sc := newSolverChallenge(t, s, pkey, p, epoch)
+
+	realPzIndexes, err := puzzle.EncodeIndexList(sc.realPzList)
+	if err != nil {
+		t.Fatalf("failed to encode real puzzle indexes: %v", err)
+	}
+	s.realPuzzleList = sc.realPzList
+
+	// Reveal blinding factors for real puzzles.
+	solutions, err := s.RevealSolution(context.TODO(), &PaymentOffer{
+		Puzzle:         p,
+		RealPuzzleList: realPzIndexes,
+		RealFactors:    sc.realFactors,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sc.solve(t, pkey, solutions)
+}
+
+// TestIncrementalPromises makes sure quotients of the real set are
+// withheld from payees unlocking promises one payment at a time.
+func TestIncrementalPromises(t *testing.T) {
+	tb := NewTumbler(&Config{
+		EpochDuration:    EpochDuration,
+		EpochRenewal:     EpochRenewal,
+		PuzzleDifficulty: PuzzleDifficulty,
+	})
+	if err := tb.NewEpoch(1234); err != nil {
+		t.Fatalf("failed to setup an epoch: %v", err)
+	}
+	epoch, err := tb.getCurrentEpoch()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewSession(tb, "")
+	s.state = StateEscrowComplete
+	s.epoch = epoch
+	s.incremental = true
+	testPuzzlePromise(t, s)
+}
+
+func TestForEach(t *testing.T) {
+	for _, parallelism := range []int{1, 4, 64} {
+		tb := NewTumbler(&Config{Parallelism: parallelism})
+
+		var calls int32
+		done := make([]bool, 50)
+		err := tb.forEach(context.Background(), len(done), func(i int) error {
+			atomic.AddInt32(&calls, 1)
+			done[i] = true
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if calls != int32(len(done)) {
+			t.Fatalf("parallelism %d: %d calls made", parallelism, calls)
+		}
+		for i := range done {
+			if !done[i] {
+				t.Fatalf("parallelism %d: index %d was not processed",
+					parallelism, i)
+			}
+		}
+
+		errFailed := errors.New("failed")
+		err = tb.forEach(context.Background(), len(done), func(i int) error {
+			if i == 10 {
+				return errFailed
+			}
+			return nil
+		})
+		if err != errFailed {
+			t.Fatalf("parallelism %d: unexpected error %v", parallelism,
+				err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err = tb.forEach(ctx, len(done), func(i int) error {
+			return nil
+		})
+		if err != context.Canceled {
+			t.Fatalf("parallelism %d: unexpected error %v", parallelism,
+				err)
+		}
+	}
+}
+
 var ecpriv chainec.PrivateKey
 var ecpub chainec.PublicKey
 
@@ -363,3 +526,421 @@ func signChallengeHashes(hashes [][]byte) ([][]byte, []byte, error) {
 	}
 	return signatures, ecpub.Serialize(), nil
 }
+
+func TestParametersValidate(t *testing.T) {
+	tests := []struct {
+		params Parameters
+		valid  bool
+	}{
+		{DefaultParameters(), true},
+		{Parameters{42, 42, 15, 285}, true},
+		{Parameters{42, 41, 15, 285}, false},
+		{Parameters{20, 20, 15, 285}, false},
+		{Parameters{42, 42, 15, 100}, false},
+		{Parameters{42, 42, 0, 285}, false},
+		{Parameters{42, 42, 100, 900}, false},
+		{Parameters{300, 300, 15, 285}, false},
+	}
+	for i, test := range tests {
+		err := test.params.Validate()
+		if (err == nil) != test.valid {
+			t.Errorf("test %d: unexpected result %v", i, err)
+		}
+	}
+
+	// Test networks may run the protocol with fewer items.
+	small := Parameters{8, 8, 4, 28}
+	if err := small.Validate(); err == nil {
+		t.Error("insecure parameters accepted")
+	}
+	if err := small.ValidateWith(12); err != nil {
+		t.Errorf("parameters rejected: %v", err)
+	}
+}
+
+func TestFeePolicy(t *testing.T) {
+	const denom = contract.Denomination
+
+	tests := []struct {
+		policy FeePolicy
+		valid  bool
+		fee    contract.Amount
+	}{
+		{FeePolicy{}, true, 0},
+		{FeePolicy{Fixed: 1000}, true, 1000},
+		{FeePolicy{Rate: 0.5}, true, denom / 200},
+		{FeePolicy{Fixed: 1000, Rate: 1}, true, 1000 + denom/100},
+		{FeePolicy{Fixed: -1}, false, 0},
+		{FeePolicy{Fixed: denom}, false, 0},
+		{FeePolicy{Rate: -0.1}, false, 0},
+		{FeePolicy{Rate: MaxFeeRate + 1}, false, 0},
+	}
+	for i, test := range tests {
+		err := test.policy.Validate()
+		if (err == nil) != test.valid {
+			t.Errorf("test %d: unexpected result %v", i, err)
+			continue
+		}
+		if !test.valid {
+			continue
+		}
+		fee := test.policy.Fee(denom)
+		if fee != test.fee {
+			t.Errorf("test %d: fee %d, expected %d", i, fee, test.fee)
+		}
+		if _, err = test.policy.checkOfferAmount(denom + fee - 1); err != ErrInsufficientFee {
+			t.Errorf("test %d: short offer accepted: %v", i, err)
+		}
+		paid, err := test.policy.checkOfferAmount(denom + fee)
+		if err != nil || paid != fee {
+			t.Errorf("test %d: offer rejected: %d %v", i, paid, err)
+		}
+	}
+}
+
+func TestFeeBumpPolicy(t *testing.T) {
+	const base = contract.FeePerKb
+
+	tests := []struct {
+		policy FeeBumpPolicy
+		valid  bool
+		rate   int64
+		urgent bool
+		next   int64
+	}{
+		{FeeBumpPolicy{}, true, 0, false, 0},
+		{FeeBumpPolicy{Expiry: -1}, false, 0, false, 0},
+		{FeeBumpPolicy{Expiry: 6, Factor: 1, MaxFeeRate: 4 * base}, false, 0, false, 0},
+		{FeeBumpPolicy{Expiry: 6, Factor: 2, MaxFeeRate: base - 1}, false, 0, false, 0},
+		{FeeBumpPolicy{Expiry: EpochDuration, Factor: 2, MaxFeeRate: 4 * base}, false, 0, false, 0},
+		{FeeBumpPolicy{Expiry: 6, Factor: 2, MaxFeeRate: 4 * base}, true, 0, false, 2 * base},
+		{FeeBumpPolicy{Expiry: 6, Factor: 1.5, MaxFeeRate: 4 * base}, true, 2 * base, false, 3 * base},
+		{FeeBumpPolicy{Expiry: 6, Factor: 2, MaxFeeRate: 4 * base}, true, 3 * base, false, 4 * base},
+		{FeeBumpPolicy{Expiry: 6, Factor: 2, MaxFeeRate: 4 * base}, true, base, true, 4 * base},
+	}
+	for i, test := range tests {
+		err := test.policy.Validate()
+		if (err == nil) != test.valid {
+			t.Errorf("test %d: unexpected result %v", i, err)
+			continue
+		}
+		if !test.valid || !test.policy.Enabled() {
+			continue
+		}
+		next := test.policy.nextFeeRate(test.rate, test.urgent)
+		if next != test.next {
+			t.Errorf("test %d: fee rate %d, expected %d", i, next,
+				test.next)
+		}
+	}
+}
+
+func TestWalletUnavailable(t *testing.T) {
+	tb := NewTumbler(&Config{})
+	s := NewSession(tb, "")
+
+	atomic.StoreInt32(&tb.walletDown, 1)
+	if _, err := s.ready(StateEscrowComplete); err != ErrWalletUnavailable {
+		t.Fatalf("exchange started without a wallet: %v", err)
+	}
+	if _, err := s.ready(StateSolutionsPromised); err != ErrWalletUnavailable {
+		t.Fatalf("exchange started without a wallet: %v", err)
+	}
+
+	atomic.StoreInt32(&tb.walletDown, 0)
+	if ok, err := s.ready(StateEscrowComplete); !ok {
+		t.Fatalf("exchange rejected: %v", err)
+	}
+}
+
+func TestEpochSessions(t *testing.T) {
+	tb := NewTumbler(&Config{
+		EpochDuration:    EpochDuration,
+		EpochRenewal:     EpochRenewal,
+		PuzzleDifficulty: PuzzleDifficulty,
+	})
+	if err := tb.NewEpoch(100); err != nil {
+		t.Fatal(err)
+	}
+
+	// Escrows requesting the next epoch wait for it near the boundary.
+	tb.setBestHeight(100 + EpochRenewal - NextEpochWindow)
+	_, err := tb.escrowEpoch(true)
+	if _, ok := err.(*EpochPendingError); !ok {
+		t.Fatalf("escrow wasn't asked to wait for the next epoch: %v", err)
+	}
+	if epoch, err := tb.escrowEpoch(false); err != nil || epoch != 100 {
+		t.Fatalf("unexpected current epoch %d: %v", epoch, err)
+	}
+
+	// Epochs with live sessions don't expire.
+	s := &Session{tb: tb}
+	if err = tb.bindEpoch(s, 100); err != nil {
+		t.Fatal(err)
+	}
+	if err = tb.NewEpoch(200); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tb.getPuzzleKey(100); err != nil {
+		t.Fatalf("key of a bound epoch expired: %v", err)
+	}
+
+	// The epoch expires once the last session is finalized.
+	tb.unbindEpoch(s)
+	if _, err = tb.getPuzzleKey(100); err != ErrEpochNotFound {
+		t.Fatalf("key of an unbound epoch was retained: %v", err)
+	}
+	if _, err = tb.getPuzzleKey(200); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSolverPolicy(t *testing.T) {
+	const count = RealPreimageCount + FakePreimageCount
+
+	for i, p := range []SolverPolicy{
+		{ClientQuota: -1},
+		{ClientQuota: count - 1},
+		{WorkBits: puzzle.MaxWorkBits + 1},
+		{Concurrency: -1},
+	} {
+		if p.Validate() == nil {
+			t.Errorf("policy %d: invalid policy accepted", i)
+		}
+	}
+
+	tb := NewTumbler(&Config{
+		EpochDuration:    EpochDuration,
+		EpochRenewal:     EpochRenewal,
+		PuzzleDifficulty: PuzzleDifficulty,
+		SolverPolicy: &SolverPolicy{
+			ClientQuota: 2 * count,
+			WorkBits:    8,
+		},
+	})
+	if err := tb.NewEpoch(100); err != nil {
+		t.Fatal(err)
+	}
+	challenges := func(client string, epoch int32, tag byte) *SolutionChallenges {
+		sc := &SolutionChallenges{Epoch: epoch, Client: client}
+		for i := 0; i < count; i++ {
+			sc.Puzzles = append(sc.Puzzles, []byte{tag, byte(i)})
+		}
+		work, err := puzzle.SolveWork(epoch, sc.Puzzles, 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sc.Work = work
+		return sc
+	}
+
+	sc := challenges("10.0.0.1", 100, 1)
+	sc.Work = nil
+	if err := tb.chargeSolver(sc); err != ErrInsufficientWork {
+		t.Fatalf("request without work accepted: %v", err)
+	}
+	sc = challenges("10.0.0.1", 100, 1)
+	if err := tb.chargeSolver(sc); err != nil {
+		t.Fatal(err)
+	}
+	if err := tb.chargeSolver(sc); err != ErrReplay {
+		t.Fatalf("replayed work accepted: %v", err)
+	}
+	if err := tb.chargeSolver(challenges("10.0.0.1", 100, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tb.chargeSolver(challenges("10.0.0.1", 100, 3)); err != ErrQuotaExceeded {
+		t.Fatalf("quota wasn't enforced: %v", err)
+	}
+	if err := tb.chargeSolver(challenges("10.0.0.2", 100, 3)); err != nil {
+		t.Fatalf("quotas of clients aren't separate: %v", err)
+	}
+	if err := tb.chargeSolver(challenges("10.0.0.2", 200, 4)); err != ErrEpochNotFound {
+		t.Fatalf("work accounted to an unknown epoch: %v", err)
+	}
+
+	// Waiting requests with priority are served first.
+	q := solverQueue{limit: 1}
+	ctx := context.Background()
+	if err := q.acquire(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan bool, 2)
+	waiting := func(n int) {
+		for {
+			q.mu.Lock()
+			w := len(q.waiting[0]) + len(q.waiting[1])
+			q.mu.Unlock()
+			if w == n {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	for i, priority := range []bool{false, true} {
+		go func(priority bool) {
+			if err := q.acquire(ctx, priority); err == nil {
+				served <- priority
+				q.release()
+			}
+		}(priority)
+		waiting(i + 1)
+	}
+	q.release()
+	if !<-served || <-served {
+		t.Fatal("request without priority served first")
+	}
+}
+
+func TestTransitions(t *testing.T) {
+	seen := make(map[[2]int]bool)
+	for _, tr := range Transitions() {
+		k := [2]int{tr.From, tr.To}
+		if seen[k] {
+			t.Fatalf("duplicate transition from %s to %s",
+				StateName(tr.From), StateName(tr.To))
+		}
+		seen[k] = true
+	}
+	if Hooks(StateEscrowPublished).Entry == nil {
+		t.Fatal("published escrows aren't accounted")
+	}
+
+	tb := NewTumbler(&Config{})
+	var observed []string
+	tb.stateObservers = append(tb.stateObservers,
+		func(s *Session, from, to int) {
+			observed = append(observed,
+				StateName(from)+" -> "+StateName(to))
+		})
+	s := NewSession(tb, "")
+
+	if _, err := s.ready(StatePuzzlesPromised); err == nil {
+		t.Fatal("exchange skipped a state")
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("exchange took an invalid transition")
+			}
+		}()
+		s.setState(StateEscrowPublished)
+	}()
+
+	s.setState(StateSolutionsPromised)
+	s.setState(StateSolutionsValidated)
+	if _, err := s.ready(StateSolutionPublished); err == nil {
+		t.Fatal("offer confirmation skipped without a channel")
+	}
+	if ok, err := s.ready(StateOfferReceived); !ok {
+		t.Fatalf("payment offer rejected: %v", err)
+	}
+	s.setState(StateOfferReceived)
+
+	expected := []string{
+		"InitialState -> SolutionsPromised",
+		"SolutionsPromised -> SolutionsValidated",
+		"SolutionsValidated -> OfferReceived",
+	}
+	if !reflect.DeepEqual(observed, expected) {
+		t.Fatalf("unexpected transitions observed: %v", observed)
+	}
+
+	s.state = StateSolutionPublished
+	if _, err := s.ready(StateSolutionsPromised); err != errChannelClosed {
+		t.Fatalf("payment round started without a channel: %v", err)
+	}
+}
+
+func TestCashOutBatching(t *testing.T) {
+	policy := BatchPolicy{Window: 10 * time.Minute, Jitter: time.Minute}
+	if err := policy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&BatchPolicy{Window: EpochDuration * ConfirmationInterval}).Validate(); err == nil {
+		t.Fatal("batching window longer than the epoch was accepted")
+	}
+
+	tb := NewTumbler(&Config{BatchPolicy: &policy})
+	ctx := context.Background()
+
+	var published []*Session
+	sessions := make([]*Session, 3)
+	for i := range sessions {
+		s := NewSession(tb, "")
+		s.scheduleCashOut(ctx, func(ctx context.Context) {
+			published = append(published, s)
+		})
+		// Repeated requests must not queue another cash-out.
+		s.scheduleCashOut(ctx, func(ctx context.Context) {
+			t.Fatal("cash-out was queued twice")
+		})
+		sessions[i] = s
+	}
+	if len(published) != 0 {
+		t.Fatal("cash-out was published before the batch")
+	}
+	if batch := tb.batcher.take(time.Now()); batch != nil {
+		t.Fatal("batch was taken before the deadline")
+	}
+
+	// Finalized sessions are skipped.
+	sessions[0].FinalizeExchange(ctx, ReasonSessionExpired, nil)
+	tb.flushCashOuts(ctx)
+	if len(published) != 2 {
+		t.Fatalf("published %d cash-outs, expected 2", len(published))
+	}
+	for _, s := range published {
+		if s == sessions[0] {
+			t.Fatal("cash-out of a finalized session was published")
+		}
+	}
+
+	// Cash-outs are published right away when batching is disabled.
+	tb = NewTumbler(&Config{})
+	s := NewSession(tb, "")
+	published = nil
+	s.scheduleCashOut(ctx, func(ctx context.Context) {
+		published = append(published, s)
+	})
+	if len(published) != 1 {
+		t.Fatal("cash-out wasn't published")
+	}
+}
+
+func TestPayoutCommitment(t *testing.T) {
+	const payout = "SsWKp7wtdTZYabYFYSc9cnxhwFEjA5g4pFc"
+	nonce := make([]byte, payoutNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		t.Fatal(err)
+	}
+
+	// Payees identified by wallet addresses don't reveal the pay-out
+	// address.
+	s := NewSession(NewTumbler(&Config{}), "")
+	if err := s.commitPayout(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.revealPayout("", nil); err != nil {
+		t.Fatalf("unexpected reveal failure: %v", err)
+	}
+
+	if err := s.commitPayout([]byte("short")); err == nil {
+		t.Fatal("malformed commitment was accepted")
+	}
+	if err := s.commitPayout(PayoutCommitment(nonce, payout)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.revealPayout("", nil); err == nil {
+		t.Fatal("missing pay-out address was accepted")
+	}
+	if err := s.revealPayout(payout+"x", nonce); err != ErrPayoutMismatch {
+		t.Fatalf("unexpected result of a mismatched reveal: %v", err)
+	}
+	if err := s.revealPayout(payout, nonce); err != nil {
+		t.Fatalf("failed to reveal the pay-out address: %v", err)
+	}
+	if s.payoutAddress != payout {
+		t.Fatalf("unexpected pay-out address %q", s.payoutAddress)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package tumbler
+
+import (
+	"context"
+
+	"github.com/decred/tumblebit/contract"
+	"github.com/decred/tumblebit/wallet"
+)
+
+// Wallet describes wallet and blockchain facilities the tumbler relies
+// upon. The wallet package provides the implementation backed by dcrwallet,
+// alternative backends and mock wallets used in tests must provide the same
+// semantics.
+type Wallet interface {
+	// CurrentBlockHeight returns the height of the main chain tip.
+	CurrentBlockHeight(ctx context.Context) (uint32, error)
+
+	// NotifyBlocks delivers heights of newly attached blocks until the
+	// context is cancelled or the notification stream fails.
+	NotifyBlocks(ctx context.Context, blocks chan<- int32) error
+
+	// GetCashOutAddress allocates a new external address of the account
+	// receiving payments and returns it along with the associated public
+	// key.
+	GetCashOutAddress(ctx context.Context) (string, string, error)
+
+	// ImportEscrowScript makes the wallet aware of the escrow script
+	// of the contract.
+	ImportEscrowScript(ctx context.Context, con *contract.Contract) error
+
+	// CreateEscrow creates and signs the escrow transaction and the
+	// associated refund transaction for the contract.
+	CreateEscrow(ctx context.Context, con *contract.Contract) error
+
+	// SignHashes signs transaction hashes with the key of the contract
+	// sender and returns signatures and the public key. It returns
+	// ErrSignaturePending while the signatures are created offline.
+	SignHashes(ctx context.Context, con *contract.Contract, txHashes [][]byte) ([][]byte, []byte, error)
+
+	// ValidateOffer checks that the escrow transaction identified by the
+	// hash has been confirmed and escrows enough funds for the contract.
+	ValidateOffer(ctx context.Context, con *contract.Contract, escrowHash []byte) (bool, error)
+
+	// PublishEscrow publishes the escrow transaction of the contract.
+	PublishEscrow(ctx context.Context, con *contract.Contract) error
+
+	// PublishSolution publishes the transaction fulfilling the offer
+	// with the provided secrets.
+	PublishSolution(ctx context.Context, con *contract.Contract, secrets [][]byte) error
+}
+
+// ErrSignaturePending is returned by the wallet when signatures have been
+// requested from an offline signer but haven't been imported yet.
+var ErrSignaturePending = wallet.ErrSignaturePending
+
+// ConnectivityNotifier is implemented by wallets able to report whether
+// the underlying wallet service is reachable.
+type ConnectivityNotifier interface {
+	// NotifyConnectivity delivers changes of the wallet availability
+	// until the context is cancelled.
+	NotifyConnectivity(ctx context.Context, events chan<- bool) error
+}
+
+// RefundCollector is implemented by wallets whose escrow refunds may be
+// signed by an offline signer after the escrow has been created.
+type RefundCollector interface {
+	// CollectRefund completes the refund transaction of the contract
+	// if its signature is still pending. It returns ErrSignaturePending
+	// until the offline signer has provided the signature.
+	CollectRefund(ctx context.Context, con *contract.Contract) error
+}
+
+// Rebroadcaster is implemented by wallets publishing unconfirmed
+// transactions again.
+type Rebroadcaster interface {
+	// Rebroadcast publishes unconfirmed transactions again until the
+	// context is cancelled.
+	Rebroadcast(ctx context.Context) error
+}
+
+// Make sure the dcrwallet backend satisfies the interface.
+var _ Wallet = (*wallet.Wallet)(nil)
+var _ ConnectivityNotifier = (*wallet.Wallet)(nil)
+var _ BalanceReporter = (*wallet.Wallet)(nil)
+var _ SpendMonitor = (*wallet.Wallet)(nil)
+var _ ScriptResolver = (*wallet.Wallet)(nil)
+var _ FeeBumper = (*wallet.Wallet)(nil)
+var _ Rebroadcaster = (*wallet.Wallet)(nil)
+var _ RefundCollector = (*wallet.Wallet)(nil)
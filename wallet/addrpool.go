@@ -0,0 +1,266 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/decred/dcrd/txscript"
+	"github.com/decred/dcrd/wire"
+	pb "github.com/decred/dcrwallet/rpc/walletrpc"
+	"github.com/decred/tumblebit/contract"
+)
+
+// Addresses are handed out of pools, one for every branch of an account,
+// that allocate them from the wallet service in batches ahead of time.
+// Handed out addresses remain pending until they are used by a published
+// transaction or an imported escrow script. Pending and pre-allocated
+// addresses together never exceed the gap limit, so addresses of aborted
+// sessions can't push used ones out of reach of the address discovery of
+// the wallet. The gap policy decides what happens when the limit is
+// reached and is passed on to the wallet service, which enforces it
+// across restarts.
+
+const (
+	// DefaultAddressPoolSize is the default number of addresses
+	// allocated ahead of time.
+	DefaultAddressPoolSize = 5
+
+	// DefaultGapLimit is the default gap limit, which matches the one of
+	// dcrwallet.
+	DefaultGapLimit = 20
+)
+
+// ErrGapLimit is returned when an address is requested while the gap
+// limit is reached and the gap policy is GapPolicyError.
+var ErrGapLimit = errors.New("address gap limit reached")
+
+// GapPolicy defines how addresses are handed out once the gap limit is
+// reached.
+type GapPolicy int
+
+const (
+	// GapPolicyWrap hands out the oldest pending address again.
+	GapPolicyWrap GapPolicy = iota
+
+	// GapPolicyError refuses to hand out addresses.
+	GapPolicyError
+
+	// GapPolicyIgnore allocates addresses beyond the gap limit.
+	GapPolicyIgnore
+)
+
+var gapPolicyNames = map[GapPolicy]string{
+	GapPolicyWrap:   "wrap",
+	GapPolicyError:  "error",
+	GapPolicyIgnore: "ignore",
+}
+
+func (p GapPolicy) String() string {
+	if name, ok := gapPolicyNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown gap policy %d", int(p))
+}
+
+// ParseGapPolicy returns the gap policy with the name.
+func ParseGapPolicy(name string) (GapPolicy, error) {
+	for p, n := range gapPolicyNames {
+		if n == name {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown gap policy %q", name)
+}
+
+// walletGapPolicy returns the gap policy of the wallet service.
+func (p GapPolicy) walletGapPolicy() pb.NextAddressRequest_GapPolicy {
+	switch p {
+	case GapPolicyError:
+		return pb.NextAddressRequest_GAP_POLICY_ERROR
+	case GapPolicyIgnore:
+		return pb.NextAddressRequest_GAP_POLICY_IGNORE
+	default:
+		return pb.NextAddressRequest_GAP_POLICY_WRAP
+	}
+}
+
+// AddressPolicy configures the allocation of addresses.
+type AddressPolicy struct {
+	// PoolSize is the number of addresses allocated ahead of time.
+	PoolSize int
+
+	// GapLimit is the maximum number of allocated addresses that
+	// haven't been used.
+	GapLimit int
+
+	// GapPolicy defines how addresses are handed out once the gap limit
+	// is reached.
+	GapPolicy GapPolicy
+}
+
+// poolAddress is an address allocated from the wallet service along with
+// its public key.
+type poolAddress struct {
+	address string
+	pubKey  string
+}
+
+// addressPool hands out addresses of a branch of an account.
+type addressPool struct {
+	policy *AddressPolicy
+	alloc  func(ctx context.Context) (string, string, error)
+
+	mu      sync.Mutex
+	free    []poolAddress
+	pending []poolAddress
+}
+
+// get hands out an address, allocating a batch of addresses if none is
+// left.
+func (p *addressPool) get(ctx context.Context) (string, string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.free) == 0 {
+		if err := p.fill(ctx); err != nil {
+			return "", "", err
+		}
+	}
+
+	var a poolAddress
+	switch {
+	case len(p.free) != 0:
+		a, p.free = p.free[0], p.free[1:]
+	case p.policy.GapPolicy == GapPolicyWrap && len(p.pending) != 0:
+		a, p.pending = p.pending[0], p.pending[1:]
+	default:
+		return "", "", ErrGapLimit
+	}
+	p.pending = append(p.pending, a)
+	return a.address, a.pubKey, nil
+}
+
+// fill allocates up to a batch of addresses without exceeding the gap
+// limit. It must be called with the mutex held.
+func (p *addressPool) fill(ctx context.Context) error {
+	n := p.policy.PoolSize
+	if n < 1 {
+		n = 1
+	}
+	if p.policy.GapPolicy != GapPolicyIgnore {
+		if room := p.policy.GapLimit - len(p.pending); n > room {
+			n = room
+		}
+	}
+	for i := 0; i < n; i++ {
+		addr, pubKey, err := p.alloc(ctx)
+		if err != nil {
+			// Hand out whatever has been allocated.
+			if len(p.free) != 0 {
+				log.Warnf("Failed to pre-allocate addresses: %v",
+					err)
+				return nil
+			}
+			return err
+		}
+		p.free = append(p.free, poolAddress{addr, pubKey})
+	}
+	return nil
+}
+
+// markUsed stops counting the addresses as pending.
+func (p *addressPool) markUsed(addrs map[string]struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pending := p.pending[:0]
+	for _, a := range p.pending {
+		if _, ok := addrs[a.address]; !ok {
+			pending = append(pending, a)
+		}
+	}
+	p.pending = pending
+}
+
+// poolKey identifies the branch of an account served by a pool.
+type poolKey struct {
+	account uint32
+	kind    pb.NextAddressRequest_Kind
+}
+
+// addressPool returns the pool of the branch of the account.
+func (w *Wallet) addressPool(account uint32, kind pb.NextAddressRequest_Kind) *addressPool {
+	w.poolsMu.Lock()
+	defer w.poolsMu.Unlock()
+
+	key := poolKey{account, kind}
+	p, ok := w.pools[key]
+	if !ok {
+		p = &addressPool{
+			policy: &w.addressPolicy,
+			alloc: func(ctx context.Context) (string, string, error) {
+				return w.nextAddress(ctx, account, kind)
+			},
+		}
+		w.pools[key] = p
+	}
+	return p
+}
+
+// markUsed stops counting the addresses as pending in all pools. Empty
+// addresses are ignored.
+func (w *Wallet) markUsed(addrs ...string) {
+	set := make(map[string]struct{}, len(addrs))
+	for _, a := range addrs {
+		if a != "" {
+			set[a] = struct{}{}
+		}
+	}
+	if len(set) == 0 {
+		return
+	}
+
+	w.poolsMu.Lock()
+	pools := make([]*addressPool, 0, len(w.pools))
+	for _, p := range w.pools {
+		pools = append(pools, p)
+	}
+	w.poolsMu.Unlock()
+
+	for _, p := range pools {
+		p.markUsed(set)
+	}
+}
+
+// markOutputsUsed marks addresses paid by outputs of the serialized
+// transaction as used.
+func (w *Wallet) markOutputsUsed(tx []byte) {
+	var msgTx wire.MsgTx
+	if err := msgTx.FromBytes(tx); err != nil {
+		return
+	}
+	var addrs []string
+	for _, out := range msgTx.TxOut {
+		_, outAddrs, _, err := txscript.ExtractPkScriptAddrs(out.Version,
+			out.PkScript, w.chainParams)
+		if err != nil {
+			continue
+		}
+		for _, a := range outAddrs {
+			addrs = append(addrs, a.EncodeAddress())
+		}
+	}
+	w.markUsed(addrs...)
+}
+
+// markContractUsed marks the addresses committed to by the escrow script
+// of the contract as used.
+func (w *Wallet) markContractUsed(con *contract.Contract) {
+	w.markUsed(con.SenderAddrStr, con.ReceiverAddrStr)
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestAddressPool(t *testing.T) {
+	newPool := func(policy AddressPolicy) (*addressPool, *int) {
+		allocated := new(int)
+		return &addressPool{
+			policy: &policy,
+			alloc: func(ctx context.Context) (string, string, error) {
+				*allocated++
+				return fmt.Sprintf("addr%d", *allocated), "", nil
+			},
+		}, allocated
+	}
+	get := func(p *addressPool) string {
+		addr, _, err := p.get(context.Background())
+		if err != nil {
+			t.Fatalf("failed to get an address: %v", err)
+		}
+		return addr
+	}
+
+	// Addresses are allocated in batches.
+	p, allocated := newPool(AddressPolicy{PoolSize: 3, GapLimit: 4})
+	if addr := get(p); addr != "addr1" || *allocated != 3 {
+		t.Fatalf("unexpected address %s after %d allocations", addr,
+			*allocated)
+	}
+	get(p)
+	get(p)
+
+	// The batch is cut short by the gap limit and the oldest pending
+	// address is handed out again once it's reached.
+	if addr := get(p); addr != "addr4" || *allocated != 4 {
+		t.Fatalf("unexpected address %s after %d allocations", addr,
+			*allocated)
+	}
+	if addr := get(p); addr != "addr1" || *allocated != 4 {
+		t.Fatalf("unexpected wrapped address %s after %d allocations",
+			addr, *allocated)
+	}
+
+	// Used addresses make room for new ones.
+	p.markUsed(map[string]struct{}{"addr2": {}, "addr3": {}})
+	if addr := get(p); addr != "addr5" || *allocated != 6 {
+		t.Fatalf("unexpected address %s after %d allocations", addr,
+			*allocated)
+	}
+
+	p, _ = newPool(AddressPolicy{PoolSize: 1, GapLimit: 1,
+		GapPolicy: GapPolicyError})
+	get(p)
+	if _, _, err := p.get(context.Background()); err != ErrGapLimit {
+		t.Fatalf("unexpected result beyond the gap limit: %v", err)
+	}
+
+	p, allocated = newPool(AddressPolicy{PoolSize: 1, GapLimit: 1,
+		GapPolicy: GapPolicyIgnore})
+	get(p)
+	if addr := get(p); addr != "addr2" || *allocated != 2 {
+		t.Fatalf("unexpected address %s beyond the gap limit", addr)
+	}
+}
+
+func TestParseGapPolicy(t *testing.T) {
+	for _, p := range []GapPolicy{GapPolicyWrap, GapPolicyError,
+		GapPolicyIgnore} {
+		parsed, err := ParseGapPolicy(p.String())
+		if err != nil || parsed != p {
+			t.Fatalf("failed to parse %s: %v", p, err)
+		}
+	}
+	if _, err := ParseGapPolicy("reuse"); err == nil {
+		t.Fatal("unknown gap policy was accepted")
+	}
+}
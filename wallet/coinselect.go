@@ -0,0 +1,287 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrutil"
+	"github.com/decred/dcrd/txscript"
+	"github.com/decred/dcrd/wire"
+	pb "github.com/decred/dcrwallet/rpc/walletrpc"
+	"github.com/decred/dcrwallet/wallet/txrules"
+	"github.com/decred/tumblebit/contract"
+)
+
+// CoinSelection is the policy of choosing unspent outputs funding escrows.
+// The wallet service selects outputs on its own if no option is set.
+type CoinSelection struct {
+	// SingleInput funds every escrow with a single unspent output, or
+	// with the outputs of a single address if partial spends are
+	// avoided, so that unrelated coins are never merged.
+	SingleInput bool
+
+	// AvoidPartialSpends spends all outputs paying to the same address
+	// together, so that an address revealed by a spend is never spent
+	// from again.
+	AvoidPartialSpends bool
+
+	// MinConf is the minimum number of confirmations of spent outputs.
+	MinConf int32
+}
+
+// Enabled returns true if the policy overrides the selection of the wallet
+// service.
+func (p *CoinSelection) Enabled() bool {
+	return p.SingleInput || p.AvoidPartialSpends || p.MinConf > 0
+}
+
+// ErrInsufficientFunds is returned when outputs satisfying the coin
+// selection policy don't cover the escrowed amount.
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// reservationTimeout is the time outputs selected for an escrow that
+// hasn't been published are withheld from other escrows.
+const reservationTimeout = 30 * time.Minute
+
+// Estimated serialization sizes of P2PKH inputs and outputs along with the
+// transaction overhead assuming single byte counts.
+const (
+	p2pkhInputSize  = 32 + 4 + 1 + 4 + 8 + 4 + 4 + 1 + 108
+	p2pkhOutputSize = 8 + 2 + 1 + 25
+	p2shOutputSize  = 8 + 2 + 1 + 23
+	txOverheadSize  = 4 + 1 + 1 + 4 + 4 + 1
+)
+
+// unspentOutput is an output available for funding.
+type unspentOutput struct {
+	outPoint wire.OutPoint
+	amount   int64
+	pkScript []byte
+}
+
+// coinGroup is a set of outputs that are spent together.
+type coinGroup struct {
+	outputs []*unspentOutput
+	amount  int64
+}
+
+// escrowFee returns the fee of an escrow transaction with n inputs and a
+// change output.
+func escrowFee(n int) int64 {
+	size := txOverheadSize + n*p2pkhInputSize + p2shOutputSize +
+		p2pkhOutputSize
+	return int64(txrules.FeeForSerializeSize(contract.FeePerKb, size))
+}
+
+// groupCoins arranges outputs into groups that must be spent together.
+func groupCoins(outputs []*unspentOutput, byAddress bool) []*coinGroup {
+	var groups []*coinGroup
+	index := make(map[string]*coinGroup)
+	for _, o := range outputs {
+		var g *coinGroup
+		if byAddress {
+			g = index[string(o.pkScript)]
+		}
+		if g == nil {
+			g = new(coinGroup)
+			groups = append(groups, g)
+			if byAddress {
+				index[string(o.pkScript)] = g
+			}
+		}
+		g.outputs = append(g.outputs, o)
+		g.amount += o.amount
+	}
+	return groups
+}
+
+// selectCoins chooses outputs covering the amount and the fee according to
+// the policy and returns them along with the fee.
+func selectCoins(outputs []*unspentOutput, amount int64, policy *CoinSelection) ([]*unspentOutput, int64, error) {
+	groups := groupCoins(outputs, policy.AvoidPartialSpends)
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].amount < groups[j].amount
+	})
+
+	// Prefer the smallest group that covers the amount on its own, it
+	// doesn't link coins and minimizes the change.
+	for _, g := range groups {
+		fee := escrowFee(len(g.outputs))
+		if g.amount >= amount+fee {
+			return g.outputs, fee, nil
+		}
+	}
+	if policy.SingleInput {
+		return nil, 0, ErrInsufficientFunds
+	}
+
+	// Merge the largest groups to involve as few coins as possible.
+	var selected []*unspentOutput
+	var total int64
+	for i := len(groups) - 1; i >= 0; i-- {
+		selected = append(selected, groups[i].outputs...)
+		total += groups[i].amount
+		fee := escrowFee(len(selected))
+		if total >= amount+fee {
+			return selected, fee, nil
+		}
+	}
+	return nil, 0, ErrInsufficientFunds
+}
+
+// unspentOutputs returns P2PKH outputs of the funding account satisfying
+// the confirmation requirement that aren't reserved by other escrows.
+func (w *Wallet) unspentOutputs(ctx context.Context) ([]*unspentOutput, error) {
+	var outputs []*unspentOutput
+	err := w.call(ctx, func(c pb.WalletServiceClient) error {
+		outputs = outputs[:0]
+		stream, err := c.UnspentOutputs(ctx, &pb.UnspentOutputsRequest{
+			Account:               w.accounts.funding,
+			RequiredConfirmations: w.coinSelection.MinConf,
+		})
+		if err != nil {
+			return err
+		}
+		for {
+			uor, err := stream.Recv()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if uor.FromCoinbase || uor.Tree != int32(wire.TxTreeRegular) ||
+				txscript.GetScriptClass(txscript.DefaultScriptVersion,
+					uor.PkScript) != txscript.PubKeyHashTy {
+				continue
+			}
+			hash, err := chainhash.NewHash(uor.TransactionHash)
+			if err != nil {
+				return err
+			}
+			outputs = append(outputs, &unspentOutput{
+				outPoint: *wire.NewOutPoint(hash, uor.OutputIndex,
+					wire.TxTreeRegular),
+				amount:   uor.Amount,
+				pkScript: uor.PkScript,
+			})
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("UnspentOutputs %v", err)
+	}
+
+	w.reservedMu.Lock()
+	defer w.reservedMu.Unlock()
+	now := time.Now()
+	for op, until := range w.reserved {
+		if !now.Before(until) {
+			delete(w.reserved, op)
+		}
+	}
+	available := outputs[:0]
+	for _, o := range outputs {
+		if _, ok := w.reserved[o.outPoint]; !ok {
+			available = append(available, o)
+		}
+	}
+	return available, nil
+}
+
+// reserve withholds the outputs from other escrows. It returns false if
+// one of them has been reserved in the meantime.
+func (w *Wallet) reserve(outputs []*unspentOutput) bool {
+	w.reservedMu.Lock()
+	defer w.reservedMu.Unlock()
+	now := time.Now()
+	for _, o := range outputs {
+		if until, ok := w.reserved[o.outPoint]; ok && now.Before(until) {
+			return false
+		}
+	}
+	for _, o := range outputs {
+		w.reserved[o.outPoint] = now.Add(reservationTimeout)
+	}
+	return true
+}
+
+// release returns the outputs to the pool available for funding.
+func (w *Wallet) release(outputs []*unspentOutput) {
+	w.reservedMu.Lock()
+	for _, o := range outputs {
+		delete(w.reserved, o.outPoint)
+	}
+	w.reservedMu.Unlock()
+}
+
+// constructEscrowTx builds an unsigned transaction paying to the escrow
+// from outputs chosen according to the coin selection policy.
+func (w *Wallet) constructEscrowTx(ctx context.Context, con *contract.Contract) ([]byte, error) {
+	outputs, err := w.unspentOutputs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	selected, fee, err := selectCoins(outputs, con.Amount.Atoms(), &w.coinSelection)
+	if err != nil {
+		return nil, err
+	}
+	if !w.reserve(selected) {
+		return nil, errors.New("selected outputs are in use")
+	}
+
+	tx := wire.NewMsgTx()
+	var total int64
+	for _, o := range selected {
+		tx.AddTxIn(wire.NewTxIn(&o.outPoint, nil))
+		total += o.amount
+	}
+	tx.AddTxOut(wire.NewTxOut(con.Amount.Atoms(), con.EscrowPayScript))
+
+	change := total - con.Amount.Atoms() - fee
+	if !txrules.IsDustAmount(dcrutil.Amount(change), p2pkhOutputSize,
+		contract.FeePerKb) {
+		addr, _, err := w.getAddress(ctx, w.accounts.funding,
+			pb.NextAddressRequest_BIP0044_INTERNAL)
+		if err != nil {
+			w.release(selected)
+			return nil, err
+		}
+		changeAddr, err := dcrutil.DecodeAddress(addr)
+		if err != nil {
+			w.release(selected)
+			return nil, err
+		}
+		changeScript, err := txscript.PayToAddrScript(changeAddr)
+		if err != nil {
+			w.release(selected)
+			return nil, err
+		}
+		tx.AddTxOut(wire.NewTxOut(change, changeScript))
+
+		// Don't reveal the change by its position.
+		var b [1]byte
+		rand.Read(b[:])
+		if b[0]&1 != 0 {
+			tx.TxOut[0], tx.TxOut[1] = tx.TxOut[1], tx.TxOut[0]
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(tx.SerializeSize())
+	if err = tx.Serialize(&buf); err != nil {
+		w.release(selected)
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"testing"
+
+	"github.com/decred/dcrd/wire"
+)
+
+func TestSelectCoins(t *testing.T) {
+	scriptA := []byte{0xa}
+	scriptB := []byte{0xb}
+	newOutput := func(index uint32, amount int64, script []byte) *unspentOutput {
+		return &unspentOutput{
+			outPoint: wire.OutPoint{Index: index},
+			amount:   amount,
+			pkScript: script,
+		}
+	}
+	outputs := []*unspentOutput{
+		newOutput(0, 3e8, scriptA),
+		newOutput(1, 2e8, scriptB),
+		newOutput(2, 1e8, scriptA),
+		newOutput(3, 5e7, scriptB),
+	}
+
+	tests := []struct {
+		name    string
+		amount  int64
+		policy  CoinSelection
+		indexes []uint32
+	}{
+		{"smallest single output", 15e7, CoinSelection{}, []uint32{1}},
+		{"merged outputs", 55e7, CoinSelection{}, []uint32{0, 1, 2}},
+		{"single input", 25e7, CoinSelection{SingleInput: true},
+			[]uint32{0}},
+		{"insufficient single input", 35e7,
+			CoinSelection{SingleInput: true}, nil},
+		{"address groups", 15e7,
+			CoinSelection{AvoidPartialSpends: true}, []uint32{1, 3}},
+		{"single address", 35e7, CoinSelection{SingleInput: true,
+			AvoidPartialSpends: true}, []uint32{0, 2}},
+		{"insufficient funds", 7e8, CoinSelection{}, nil},
+	}
+	for _, test := range tests {
+		selected, fee, err := selectCoins(outputs, test.amount,
+			&test.policy)
+		if test.indexes == nil {
+			if err != ErrInsufficientFunds {
+				t.Errorf("%s: unexpected error %v", test.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if fee != escrowFee(len(selected)) {
+			t.Errorf("%s: unexpected fee %d", test.name, fee)
+		}
+		var total int64
+		matched := len(selected) == len(test.indexes)
+		for i, o := range selected {
+			total += o.amount
+			if matched && o.outPoint.Index != test.indexes[i] {
+				matched = false
+			}
+		}
+		if !matched {
+			t.Errorf("%s: unexpected selection %v", test.name, selected)
+		}
+		if total < test.amount+fee {
+			t.Errorf("%s: selection doesn't cover the amount", test.name)
+		}
+	}
+}
@@ -0,0 +1,870 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrd/chaincfg/chainec"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrutil"
+	"github.com/decred/dcrd/txscript"
+	"github.com/decred/dcrd/wire"
+	pb "github.com/decred/dcrwallet/rpc/walletrpc"
+
+	"golang.org/x/net/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Some deployments expose only the JSON-RPC interface of dcrwallet. The
+// JSON-RPC client implements the subset of the gRPC wallet service used
+// by the wallet on top of it, requests are sent with HTTP POST and block
+// notifications are received through a websocket. Accounts are referred
+// to by name over JSON-RPC, so they are numbered by the client: the
+// default account is always 0, the imported account is math.MaxInt32 and
+// other accounts are numbered in the order they are first seen.
+
+const (
+	// unlockTimeout is the number of seconds the wallet is unlocked for
+	// by requests requiring the private passphrase.
+	unlockTimeout = 60
+
+	// spenderScanDepth is the number of recent wallet transactions that
+	// are searched for the spender of an output.
+	spenderScanDepth = 1000
+)
+
+// JSON-RPC error codes translated to gRPC status codes.
+const (
+	rpcErrNoTxInfo             = -5
+	rpcErrWalletInvalidAccount = -11
+)
+
+// JSONRPCConfig configures the connection to the JSON-RPC server of
+// dcrwallet.
+type JSONRPCConfig struct {
+	// Host is the host and port of the JSON-RPC server.
+	Host string
+
+	// User and Password authenticate requests.
+	User     string
+	Password string
+
+	// TLS configures the connection. TLS is disabled if it's nil.
+	TLS *tls.Config
+}
+
+// rpcRequest is a JSON-RPC request.
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+// rpcMessage is a JSON-RPC response or notification.
+type rpcMessage struct {
+	ID     *uint64         `json:"id"`
+	Method string          `json:"method"`
+	Params []interface{}   `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+// rpcError is an error returned by the JSON-RPC server.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("%d: %s", e.Code, e.Message)
+}
+
+// jsonRPCClient is a wallet service client talking to dcrwallet over
+// JSON-RPC. Methods not used by the wallet aren't implemented.
+type jsonRPCClient struct {
+	pb.WalletServiceClient
+
+	cfg         JSONRPCConfig
+	chainParams *chaincfg.Params
+	http        *http.Client
+	id          uint64
+
+	accountsMu sync.Mutex
+	numbers    map[string]uint32
+	names      map[uint32]string
+	next       uint32
+}
+
+// newJSONRPCClient creates a client of the JSON-RPC server.
+func newJSONRPCClient(cfg *JSONRPCConfig, chainParams *chaincfg.Params) *jsonRPCClient {
+	return &jsonRPCClient{
+		cfg:         *cfg,
+		chainParams: chainParams,
+		http: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: cfg.TLS},
+		},
+		numbers: map[string]uint32{"default": 0, "imported": math.MaxInt32},
+		names:   map[uint32]string{0: "default", math.MaxInt32: "imported"},
+		next:    1,
+	}
+}
+
+// url returns the URL of the endpoint on the server.
+func (c *jsonRPCClient) url(scheme, path string) string {
+	if c.cfg.TLS != nil {
+		scheme += "s"
+	}
+	return scheme + "://" + c.cfg.Host + path
+}
+
+// toStatus translates errors into gRPC status errors expected by the
+// wallet. Requests failing to reach the server are transient.
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	e, ok := err.(*rpcError)
+	if !ok {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	switch e.Code {
+	case rpcErrNoTxInfo, rpcErrWalletInvalidAccount:
+		return status.Error(codes.NotFound, e.Message)
+	}
+	return status.Error(codes.Unknown, e.Message)
+}
+
+// request invokes the method and decodes its result into res unless it's
+// nil.
+func (c *jsonRPCClient) request(ctx context.Context, res interface{}, method string, params ...interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	body, err := json.Marshal(&rpcRequest{
+		JSONRPC: "1.0",
+		ID:      atomic.AddUint64(&c.id, 1),
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	req, err := http.NewRequest("POST", c.url("http", "/"),
+		bytes.NewReader(body))
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(c.cfg.User, c.cfg.Password)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return toStatus(err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return status.Error(codes.Unauthenticated, resp.Status)
+	case http.StatusServiceUnavailable:
+		return status.Error(codes.Unavailable, resp.Status)
+	}
+
+	var msg rpcMessage
+	if err = json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+		return status.Errorf(codes.Unknown, "%s: malformed response: %v",
+			method, err)
+	}
+	if msg.Error != nil {
+		return toStatus(msg.Error)
+	}
+	if res == nil {
+		return nil
+	}
+	if err = json.Unmarshal(msg.Result, res); err != nil {
+		return status.Errorf(codes.Unknown, "%s: malformed result: %v",
+			method, err)
+	}
+	return nil
+}
+
+// unlock unlocks the wallet with the passphrase of a request requiring
+// private keys. Requests without the passphrase rely on the wallet or its
+// accounts being unlocked.
+func (c *jsonRPCClient) unlock(ctx context.Context, passphrase []byte) error {
+	if len(passphrase) == 0 {
+		return nil
+	}
+	return c.request(ctx, nil, "walletpassphrase", string(passphrase),
+		unlockTimeout)
+}
+
+// registerAccounts numbers newly seen accounts.
+func (c *jsonRPCClient) registerAccounts(names []string) {
+	sort.Strings(names)
+	c.accountsMu.Lock()
+	defer c.accountsMu.Unlock()
+	for _, name := range names {
+		if _, ok := c.numbers[name]; ok {
+			continue
+		}
+		c.numbers[name] = c.next
+		c.names[c.next] = name
+		c.next++
+	}
+}
+
+// accountName returns the name of the numbered account.
+func (c *jsonRPCClient) accountName(number uint32) (string, error) {
+	c.accountsMu.Lock()
+	name, ok := c.names[number]
+	c.accountsMu.Unlock()
+	if !ok {
+		return "", status.Errorf(codes.NotFound, "account %d wasn't "+
+			"found", number)
+	}
+	return name, nil
+}
+
+// hashString returns the string encoding of the transaction hash.
+func hashString(b []byte) (string, error) {
+	hash, err := chainhash.NewHash(b)
+	if err != nil {
+		return "", status.Error(codes.InvalidArgument, err.Error())
+	}
+	return hash.String(), nil
+}
+
+// decodeHex decodes the hex encoded result of the method.
+func decodeHex(method, s string) ([]byte, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, status.Errorf(codes.Unknown, "%s: malformed result: "+
+			"%v", method, err)
+	}
+	return b, nil
+}
+
+func (c *jsonRPCClient) Ping(ctx context.Context, in *pb.PingRequest, opts ...grpc.CallOption) (*pb.PingResponse, error) {
+	if err := c.request(ctx, nil, "walletinfo"); err != nil {
+		return nil, err
+	}
+	return &pb.PingResponse{}, nil
+}
+
+// Network identifies the network of the wallet by its genesis block.
+func (c *jsonRPCClient) Network(ctx context.Context, in *pb.NetworkRequest, opts ...grpc.CallOption) (*pb.NetworkResponse, error) {
+	var genesis string
+	if err := c.request(ctx, &genesis, "getblockhash", 0); err != nil {
+		return nil, err
+	}
+	for _, params := range []*chaincfg.Params{&chaincfg.MainNetParams,
+		&chaincfg.TestNet2Params, &chaincfg.SimNetParams} {
+		if params.GenesisHash.String() == genesis {
+			return &pb.NetworkResponse{
+				ActiveNetwork: uint32(params.Net),
+			}, nil
+		}
+	}
+	return nil, status.Errorf(codes.Unknown, "unknown network with "+
+		"genesis block %s", genesis)
+}
+
+func (c *jsonRPCClient) Accounts(ctx context.Context, in *pb.AccountsRequest, opts ...grpc.CallOption) (*pb.AccountsResponse, error) {
+	var balances map[string]float64
+	if err := c.request(ctx, &balances, "listaccounts"); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(balances))
+	for name := range balances {
+		names = append(names, name)
+	}
+	c.registerAccounts(names)
+
+	c.accountsMu.Lock()
+	defer c.accountsMu.Unlock()
+	ar := &pb.AccountsResponse{}
+	for _, name := range names {
+		ar.Accounts = append(ar.Accounts, &pb.AccountsResponse_Account{
+			AccountNumber: c.numbers[name],
+			AccountName:   name,
+		})
+	}
+	return ar, nil
+}
+
+func (c *jsonRPCClient) NextAccount(ctx context.Context, in *pb.NextAccountRequest, opts ...grpc.CallOption) (*pb.NextAccountResponse, error) {
+	if err := c.unlock(ctx, in.Passphrase); err != nil {
+		return nil, err
+	}
+	err := c.request(ctx, nil, "createnewaccount", in.AccountName)
+	if err != nil {
+		return nil, err
+	}
+	c.registerAccounts([]string{in.AccountName})
+	c.accountsMu.Lock()
+	number := c.numbers[in.AccountName]
+	c.accountsMu.Unlock()
+	return &pb.NextAccountResponse{AccountNumber: number}, nil
+}
+
+func (c *jsonRPCClient) BestBlock(ctx context.Context, in *pb.BestBlockRequest, opts ...grpc.CallOption) (*pb.BestBlockResponse, error) {
+	var res struct {
+		Hash   string `json:"hash"`
+		Height int64  `json:"height"`
+	}
+	if err := c.request(ctx, &res, "getbestblock"); err != nil {
+		return nil, err
+	}
+	hash, err := chainhash.NewHashFromStr(res.Hash)
+	if err != nil {
+		return nil, status.Errorf(codes.Unknown, "getbestblock: %v", err)
+	}
+	return &pb.BestBlockResponse{
+		Hash:   hash[:],
+		Height: uint32(res.Height),
+	}, nil
+}
+
+func (c *jsonRPCClient) Balance(ctx context.Context, in *pb.BalanceRequest, opts ...grpc.CallOption) (*pb.BalanceResponse, error) {
+	account, err := c.accountName(in.AccountNumber)
+	if err != nil {
+		return nil, err
+	}
+	var res struct {
+		Balances []struct {
+			AccountName string  `json:"accountname"`
+			Spendable   float64 `json:"spendable"`
+			Total       float64 `json:"total"`
+		} `json:"balances"`
+	}
+	err = c.request(ctx, &res, "getbalance", account,
+		in.RequiredConfirmations)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range res.Balances {
+		if b.AccountName != account {
+			continue
+		}
+		spendable, err := dcrutil.NewAmount(b.Spendable)
+		if err != nil {
+			return nil, status.Errorf(codes.Unknown, "getbalance: %v",
+				err)
+		}
+		total, err := dcrutil.NewAmount(b.Total)
+		if err != nil {
+			return nil, status.Errorf(codes.Unknown, "getbalance: %v",
+				err)
+		}
+		return &pb.BalanceResponse{
+			Spendable: int64(spendable),
+			Total:     int64(total),
+		}, nil
+	}
+	return &pb.BalanceResponse{}, nil
+}
+
+func (c *jsonRPCClient) ImportScript(ctx context.Context, in *pb.ImportScriptRequest, opts ...grpc.CallOption) (*pb.ImportScriptResponse, error) {
+	addr, err := dcrutil.NewAddressScriptHash(in.Script, c.chainParams)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err = c.unlock(ctx, in.Passphrase); err != nil {
+		return nil, err
+	}
+	err = c.request(ctx, nil, "importscript", hex.EncodeToString(in.Script))
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ImportScriptResponse{
+		P2ShAddress: addr.EncodeAddress(),
+		Redeemable:  true,
+	}, nil
+}
+
+// ConstructTransaction creates a transaction paying to the outputs and
+// funds it with outputs of the source account.
+func (c *jsonRPCClient) ConstructTransaction(ctx context.Context, in *pb.ConstructTransactionRequest, opts ...grpc.CallOption) (*pb.ConstructTransactionResponse, error) {
+	account, err := c.accountName(in.SourceAccount)
+	if err != nil {
+		return nil, err
+	}
+	amounts := make(map[string]float64, len(in.NonChangeOutputs))
+	for _, out := range in.NonChangeOutputs {
+		if out.Destination == nil {
+			return nil, status.Error(codes.InvalidArgument,
+				"output destination is required")
+		}
+		addr := out.Destination.Address
+		if addr == "" {
+			_, addrs, _, err := txscript.ExtractPkScriptAddrs(
+				uint16(out.Destination.ScriptVersion),
+				out.Destination.Script, c.chainParams)
+			if err != nil || len(addrs) != 1 {
+				return nil, status.Error(codes.InvalidArgument,
+					"unsupported output script")
+			}
+			addr = addrs[0].EncodeAddress()
+		}
+		amounts[addr] += dcrutil.Amount(out.Amount).ToCoin()
+	}
+
+	var unfunded string
+	err = c.request(ctx, &unfunded, "createrawtransaction",
+		[]interface{}{}, amounts)
+	if err != nil {
+		return nil, err
+	}
+	var res struct {
+		Hex string `json:"hex"`
+	}
+	err = c.request(ctx, &res, "fundrawtransaction", unfunded, account,
+		map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	tx, err := decodeHex("fundrawtransaction", res.Hex)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ConstructTransactionResponse{UnsignedTransaction: tx}, nil
+}
+
+func (c *jsonRPCClient) SignTransaction(ctx context.Context, in *pb.SignTransactionRequest, opts ...grpc.CallOption) (*pb.SignTransactionResponse, error) {
+	if err := c.unlock(ctx, in.Passphrase); err != nil {
+		return nil, err
+	}
+	var res struct {
+		Hex      string `json:"hex"`
+		Complete bool   `json:"complete"`
+	}
+	err := c.request(ctx, &res, "signrawtransaction",
+		hex.EncodeToString(in.SerializedTransaction))
+	if err != nil {
+		return nil, err
+	}
+	if !res.Complete {
+		return nil, status.Error(codes.Unknown, "transaction couldn't "+
+			"be signed completely")
+	}
+	tx, err := decodeHex("signrawtransaction", res.Hex)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.SignTransactionResponse{Transaction: tx}, nil
+}
+
+// unspentOutputsStream delivers unspent outputs listed at once.
+type unspentOutputsStream struct {
+	grpc.ClientStream
+	ctx     context.Context
+	outputs []*pb.UnspentOutputResponse
+}
+
+func (s *unspentOutputsStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *unspentOutputsStream) Recv() (*pb.UnspentOutputResponse, error) {
+	if len(s.outputs) == 0 {
+		return nil, io.EOF
+	}
+	out := s.outputs[0]
+	s.outputs = s.outputs[1:]
+	return out, nil
+}
+
+func (c *jsonRPCClient) UnspentOutputs(ctx context.Context, in *pb.UnspentOutputsRequest, opts ...grpc.CallOption) (pb.WalletService_UnspentOutputsClient, error) {
+	account, err := c.accountName(in.Account)
+	if err != nil {
+		return nil, err
+	}
+	var res []struct {
+		TxID          string  `json:"txid"`
+		Vout          uint32  `json:"vout"`
+		Tree          int8    `json:"tree"`
+		Account       string  `json:"account"`
+		ScriptPubKey  string  `json:"scriptPubKey"`
+		Amount        float64 `json:"amount"`
+		Confirmations int64   `json:"confirmations"`
+		Spendable     bool    `json:"spendable"`
+	}
+	err = c.request(ctx, &res, "listunspent", in.RequiredConfirmations)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &unspentOutputsStream{ctx: ctx}
+	for _, u := range res {
+		if u.Account != account || !u.Spendable {
+			continue
+		}
+		hash, err := chainhash.NewHashFromStr(u.TxID)
+		if err != nil {
+			return nil, status.Errorf(codes.Unknown, "listunspent: %v",
+				err)
+		}
+		pkScript, err := decodeHex("listunspent", u.ScriptPubKey)
+		if err != nil {
+			return nil, err
+		}
+		amount, err := dcrutil.NewAmount(u.Amount)
+		if err != nil {
+			return nil, status.Errorf(codes.Unknown, "listunspent: %v",
+				err)
+		}
+		s.outputs = append(s.outputs, &pb.UnspentOutputResponse{
+			TransactionHash: hash[:],
+			OutputIndex:     u.Vout,
+			Tree:            int32(u.Tree),
+			Amount:          int64(amount),
+			PkScript:        pkScript,
+		})
+	}
+	return s, nil
+}
+
+// privateKey obtains the private key of the address from the wallet.
+func (c *jsonRPCClient) privateKey(ctx context.Context, addr string, passphrase []byte) (*dcrutil.WIF, error) {
+	if err := c.unlock(ctx, passphrase); err != nil {
+		return nil, err
+	}
+	var encoded string
+	if err := c.request(ctx, &encoded, "dumpprivkey", addr); err != nil {
+		return nil, err
+	}
+	wif, err := dcrutil.DecodeWIF(encoded)
+	if err != nil {
+		return nil, status.Errorf(codes.Unknown, "dumpprivkey: %v", err)
+	}
+	return wif, nil
+}
+
+// CreateSignature signs the input with the key of the address. The
+// JSON-RPC interface can't sign inputs of nonstandard scripts, so the key
+// is obtained from the wallet.
+func (c *jsonRPCClient) CreateSignature(ctx context.Context, in *pb.CreateSignatureRequest, opts ...grpc.CallOption) (*pb.CreateSignatureResponse, error) {
+	var tx wire.MsgTx
+	if err := tx.FromBytes(in.SerializedTransaction); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	wif, err := c.privateKey(ctx, in.Address, in.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := txscript.RawTxInSignature(&tx, int(in.InputIndex),
+		in.PreviousPkScript, txscript.SigHashType(in.HashType), wif.PrivKey)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &pb.CreateSignatureResponse{
+		Signature: sig,
+		PublicKey: wif.SerializePubKey(),
+	}, nil
+}
+
+func (c *jsonRPCClient) SignHashes(ctx context.Context, in *pb.SignHashesRequest, opts ...grpc.CallOption) (*pb.SignHashesResponse, error) {
+	wif, err := c.privateKey(ctx, in.Address, in.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	sigs := make([][]byte, len(in.Hashes))
+	for i, hash := range in.Hashes {
+		r, s, err := chainec.Secp256k1.Sign(wif.PrivKey, hash)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		sigs[i] = chainec.Secp256k1.NewSignature(r, s).Serialize()
+	}
+	return &pb.SignHashesResponse{
+		Signatures: sigs,
+		PublicKey:  wif.SerializePubKey(),
+	}, nil
+}
+
+// rawTransaction returns the serialized wallet transaction identified by
+// the hash along with its number of confirmations.
+func (c *jsonRPCClient) rawTransaction(ctx context.Context, txid string) ([]byte, int32, error) {
+	var res struct {
+		Hex           string `json:"hex"`
+		Confirmations int64  `json:"confirmations"`
+	}
+	if err := c.request(ctx, &res, "gettransaction", txid, true); err != nil {
+		return nil, 0, err
+	}
+	tx, err := decodeHex("gettransaction", res.Hex)
+	if err != nil {
+		return nil, 0, err
+	}
+	return tx, int32(res.Confirmations), nil
+}
+
+// Spender looks up the transaction spending the output among recent
+// wallet transactions. Outputs of imported escrow scripts are spent by
+// transactions relevant to the wallet.
+func (c *jsonRPCClient) Spender(ctx context.Context, in *pb.SpenderRequest, opts ...grpc.CallOption) (*pb.SpenderResponse, error) {
+	txid, err := hashString(in.TransactionHash)
+	if err != nil {
+		return nil, err
+	}
+	var unspent json.RawMessage
+	err = c.request(ctx, &unspent, "gettxout", txid, in.Index,
+		wire.TxTreeRegular, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(unspent) != 0 && string(unspent) != "null" {
+		return nil, status.Error(codes.NotFound, "output is unspent")
+	}
+
+	var txs []struct {
+		TxID string `json:"txid"`
+	}
+	err = c.request(ctx, &txs, "listtransactions", "*", spenderScanDepth,
+		0, true)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(txs))
+	for i := len(txs) - 1; i >= 0; i-- {
+		if seen[txs[i].TxID] {
+			continue
+		}
+		seen[txs[i].TxID] = true
+		b, _, err := c.rawTransaction(ctx, txs[i].TxID)
+		if err != nil {
+			return nil, err
+		}
+		var tx wire.MsgTx
+		if err = tx.FromBytes(b); err != nil {
+			return nil, status.Errorf(codes.Unknown, "gettransaction: "+
+				"%v", err)
+		}
+		for j, txIn := range tx.TxIn {
+			prev := &txIn.PreviousOutPoint
+			if prev.Hash.String() == txid && prev.Index == in.Index {
+				return &pb.SpenderResponse{
+					SpenderTransaction: b,
+					InputIndex:         uint32(j),
+				}, nil
+			}
+		}
+	}
+	return nil, status.Error(codes.NotFound, "spender wasn't found")
+}
+
+func (c *jsonRPCClient) NextAddress(ctx context.Context, in *pb.NextAddressRequest, opts ...grpc.CallOption) (*pb.NextAddressResponse, error) {
+	account, err := c.accountName(in.Account)
+	if err != nil {
+		return nil, err
+	}
+	var addr string
+	switch in.Kind {
+	case pb.NextAddressRequest_BIP0044_INTERNAL:
+		err = c.request(ctx, &addr, "getrawchangeaddress", account)
+	default:
+		gapPolicy := "wrap"
+		switch in.GapPolicy {
+		case pb.NextAddressRequest_GAP_POLICY_ERROR:
+			gapPolicy = "error"
+		case pb.NextAddressRequest_GAP_POLICY_IGNORE:
+			gapPolicy = "ignore"
+		}
+		err = c.request(ctx, &addr, "getnewaddress", account, gapPolicy)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var res struct {
+		PubKey string `json:"pubkey"`
+	}
+	if err = c.request(ctx, &res, "validateaddress", addr); err != nil {
+		return nil, err
+	}
+	pubKey, err := decodeHex("validateaddress", res.PubKey)
+	if err != nil {
+		return nil, err
+	}
+	pkAddr, err := dcrutil.NewAddressSecpPubKey(pubKey, c.chainParams)
+	if err != nil {
+		return nil, status.Errorf(codes.Unknown, "validateaddress: %v",
+			err)
+	}
+	return &pb.NextAddressResponse{
+		Address:   addr,
+		PublicKey: pkAddr.String(),
+	}, nil
+}
+
+func (c *jsonRPCClient) SignMessage(ctx context.Context, in *pb.SignMessageRequest, opts ...grpc.CallOption) (*pb.SignMessageResponse, error) {
+	if err := c.unlock(ctx, in.Passphrase); err != nil {
+		return nil, err
+	}
+	var encoded string
+	err := c.request(ctx, &encoded, "signmessage", in.Address, in.Message)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, status.Errorf(codes.Unknown, "signmessage: %v", err)
+	}
+	return &pb.SignMessageResponse{Signature: sig}, nil
+}
+
+func (c *jsonRPCClient) UnlockAccount(ctx context.Context, in *pb.UnlockAccountRequest, opts ...grpc.CallOption) (*pb.UnlockAccountResponse, error) {
+	account, err := c.accountName(in.AccountNumber)
+	if err != nil {
+		return nil, err
+	}
+	err = c.request(ctx, nil, "unlockaccount", account,
+		string(in.Passphrase))
+	if err != nil {
+		return nil, err
+	}
+	return &pb.UnlockAccountResponse{}, nil
+}
+
+func (c *jsonRPCClient) LockAccount(ctx context.Context, in *pb.LockAccountRequest, opts ...grpc.CallOption) (*pb.LockAccountResponse, error) {
+	account, err := c.accountName(in.AccountNumber)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.request(ctx, nil, "lockaccount", account); err != nil {
+		return nil, err
+	}
+	return &pb.LockAccountResponse{}, nil
+}
+
+func (c *jsonRPCClient) PublishTransaction(ctx context.Context, in *pb.PublishTransactionRequest, opts ...grpc.CallOption) (*pb.PublishTransactionResponse, error) {
+	var txid string
+	err := c.request(ctx, &txid, "sendrawtransaction",
+		hex.EncodeToString(in.SignedTransaction))
+	if err != nil {
+		return nil, err
+	}
+	hash, err := chainhash.NewHashFromStr(txid)
+	if err != nil {
+		return nil, status.Errorf(codes.Unknown, "sendrawtransaction: %v",
+			err)
+	}
+	return &pb.PublishTransactionResponse{TransactionHash: hash[:]}, nil
+}
+
+func (c *jsonRPCClient) GetTransaction(ctx context.Context, in *pb.GetTransactionRequest, opts ...grpc.CallOption) (*pb.GetTransactionResponse, error) {
+	txid, err := hashString(in.TransactionHash)
+	if err != nil {
+		return nil, err
+	}
+	_, confs, err := c.rawTransaction(ctx, txid)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.GetTransactionResponse{Confirmations: confs}, nil
+}
+
+// blockNotifications delivers blocks connected to the main chain as
+// notified over the websocket.
+type blockNotifications struct {
+	grpc.ClientStream
+	ctx context.Context
+	ws  *websocket.Conn
+}
+
+func (s *blockNotifications) Context() context.Context {
+	return s.ctx
+}
+
+func (s *blockNotifications) Recv() (*pb.TransactionNotificationsResponse, error) {
+	for {
+		var msg rpcMessage
+		if err := websocket.JSON.Receive(s.ws, &msg); err != nil {
+			return nil, toStatus(err)
+		}
+		if msg.Error != nil {
+			return nil, toStatus(msg.Error)
+		}
+		if msg.Method != "blockconnected" || len(msg.Params) == 0 {
+			continue
+		}
+		encoded, ok := msg.Params[0].(string)
+		if !ok {
+			return nil, status.Error(codes.Unknown, "blockconnected: "+
+				"malformed header")
+		}
+		b, err := decodeHex("blockconnected", encoded)
+		if err != nil {
+			return nil, err
+		}
+		var header wire.BlockHeader
+		if err = header.Deserialize(bytes.NewReader(b)); err != nil {
+			return nil, status.Errorf(codes.Unknown, "blockconnected: "+
+				"%v", err)
+		}
+		hash := header.BlockHash()
+		return &pb.TransactionNotificationsResponse{
+			AttachedBlocks: []*pb.BlockDetails{{
+				Hash:   hash[:],
+				Height: int32(header.Height),
+			}},
+		}, nil
+	}
+}
+
+// TransactionNotifications subscribes to notifications of connected
+// blocks. Transactions aren't notified.
+func (c *jsonRPCClient) TransactionNotifications(ctx context.Context, in *pb.TransactionNotificationsRequest, opts ...grpc.CallOption) (pb.WalletService_TransactionNotificationsClient, error) {
+	wsCfg, err := websocket.NewConfig(c.url("ws", "/ws"),
+		c.url("http", "/"))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	wsCfg.TlsConfig = c.cfg.TLS
+	auth := base64.StdEncoding.EncodeToString([]byte(c.cfg.User + ":" +
+		c.cfg.Password))
+	wsCfg.Header.Set("Authorization", "Basic "+auth)
+	ws, err := websocket.DialConfig(wsCfg)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	go func() {
+		<-ctx.Done()
+		ws.Close()
+	}()
+
+	err = websocket.JSON.Send(ws, &rpcRequest{
+		JSONRPC: "1.0",
+		ID:      atomic.AddUint64(&c.id, 1),
+		Method:  "notifyblocks",
+		Params:  []interface{}{},
+	})
+	if err != nil {
+		ws.Close()
+		return nil, toStatus(err)
+	}
+	return &blockNotifications{ctx: ctx, ws: ws}, nil
+}
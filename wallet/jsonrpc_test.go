@@ -0,0 +1,109 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/decred/dcrd/chaincfg"
+	pb "github.com/decred/dcrwallet/rpc/walletrpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// jsonRPCServer responds to JSON-RPC requests with canned results keyed
+// by method and errors with code -5 for unknown methods.
+func jsonRPCServer(t *testing.T, results map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "user" || pass != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req rpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("malformed request: %v", err)
+			return
+		}
+		result, ok := results[req.Method]
+		if !ok {
+			w.Write([]byte(`{"result":null,"error":{"code":-5,` +
+				`"message":"No information for transaction"},"id":1}`))
+			return
+		}
+		w.Write([]byte(`{"result":` + result + `,"error":null,"id":1}`))
+	}))
+}
+
+func TestJSONRPCClient(t *testing.T) {
+	ctx := context.Background()
+	srv := jsonRPCServer(t, map[string]string{
+		"walletinfo":   `{"unlocked":true}`,
+		"getblockhash": `"` + chaincfg.SimNetParams.GenesisHash.String() + `"`,
+		"listaccounts": `{"default":1,"tumblebit":2.5,"imported":0}`,
+		"getbalance": `{"balances":[{"accountname":"tumblebit",` +
+			`"spendable":2.5,"total":3}]}`,
+	})
+	defer srv.Close()
+
+	w, err := New(ctx, &Config{
+		AccountName: "tumblebit",
+		ChainParams: &chaincfg.SimNetParams,
+		JSONRPC: &JSONRPCConfig{
+			Host:     strings.TrimPrefix(srv.URL, "http://"),
+			User:     "user",
+			Password: "pass",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.account != 1 {
+		t.Fatalf("account number %d, expected 1", w.account)
+	}
+	balance, err := w.SpendableBalance(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance != 2.5e8 {
+		t.Fatalf("balance %d, expected %d", balance, int64(2.5e8))
+	}
+
+	// Unknown transactions must be reported as not found.
+	_, err = w.client().GetTransaction(ctx, &pb.GetTransactionRequest{
+		TransactionHash: make([]byte, 32),
+	})
+	if s, ok := status.FromError(err); !ok || s.Code() != codes.NotFound {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// The wallet of another network must be rejected.
+	_, err = New(ctx, &Config{
+		ChainParams: &chaincfg.MainNetParams,
+		JSONRPC: &JSONRPCConfig{
+			Host:     strings.TrimPrefix(srv.URL, "http://"),
+			User:     "user",
+			Password: "pass",
+		},
+	})
+	if err == nil {
+		t.Fatal("network mismatch wasn't detected")
+	}
+
+	// Failed authentication isn't retried.
+	c := newJSONRPCClient(&JSONRPCConfig{
+		Host: strings.TrimPrefix(srv.URL, "http://"),
+	}, &chaincfg.SimNetParams)
+	_, err = c.Ping(ctx, &pb.PingRequest{})
+	if s, ok := status.FromError(err); !ok || s.Code() != codes.Unauthenticated {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrd/chaincfg/chainec"
+	"github.com/decred/dcrd/dcrutil"
+)
+
+// Subdirectories of the offline signing directory.
+const (
+	// Signing requests awaiting the offline signer.
+	OfflineRequestDir = "requests"
+	// Responses produced by the offline signer.
+	OfflineSignedDir = "signed"
+)
+
+// SignRequest describes signatures requested from an offline signer.
+// Either the transaction with the previous output script or a list of
+// hashes is provided.
+type SignRequest struct {
+	ID          string   `json:"id"`
+	Address     string   `json:"address"`
+	Transaction []byte   `json:"transaction,omitempty"`
+	InputIndex  uint32   `json:"inputindex"`
+	PrevScript  []byte   `json:"prevscript,omitempty"`
+	Hashes      [][]byte `json:"hashes,omitempty"`
+}
+
+// SignResponse carries signatures created by an offline signer. The
+// transaction signature must have the SIGHASH_ALL hash type appended,
+// signatures of hashes are bare DER encodings. The public key is required
+// for signatures of hashes.
+type SignResponse struct {
+	ID         string   `json:"id"`
+	Signatures [][]byte `json:"signatures"`
+	PublicKey  []byte   `json:"publickey,omitempty"`
+}
+
+// requestID derives the identifier of the request from its contents so
+// that repeated requests map onto the same response.
+func (r *SignRequest) requestID() string {
+	req := *r
+	req.ID = ""
+	b, err := json.Marshal(&req)
+	if err != nil {
+		panic(err)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// OfflineSigner keeps signing keys off the tumbler host. Signing requests
+// are exported as JSON files into the requests subdirectory of a directory
+// shared with an external signer which places responses named after the
+// request into the signed subdirectory. Until the response is available
+// the signer returns ErrSignaturePending.
+type OfflineSigner struct {
+	dir         string
+	chainParams *chaincfg.Params
+}
+
+// NewOfflineSigner creates an offline signer exchanging requests and
+// responses through the directory.
+func NewOfflineSigner(dir string, chainParams *chaincfg.Params) (*OfflineSigner, error) {
+	for _, sub := range []string{OfflineRequestDir, OfflineSignedDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0700); err != nil {
+			return nil, err
+		}
+	}
+	return &OfflineSigner{dir: dir, chainParams: chainParams}, nil
+}
+
+// sign returns the response to the request if it has been imported or
+// exports the request otherwise.
+func (s *OfflineSigner) sign(req *SignRequest) (*SignResponse, error) {
+	req.ID = req.requestID()
+	name := req.ID + ".json"
+	reqPath := filepath.Join(s.dir, OfflineRequestDir, name)
+
+	b, err := ioutil.ReadFile(filepath.Join(s.dir, OfflineSignedDir, name))
+	if err == nil {
+		var resp SignResponse
+		if err = json.Unmarshal(b, &resp); err != nil {
+			return nil, fmt.Errorf("malformed response %s: %v", req.ID,
+				err)
+		}
+		if resp.ID != req.ID {
+			return nil, fmt.Errorf("response %s doesn't match the "+
+				"request", req.ID)
+		}
+		os.Remove(reqPath)
+		return &resp, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if _, err = os.Stat(reqPath); os.IsNotExist(err) {
+		b, err = json.MarshalIndent(req, "", "\t")
+		if err != nil {
+			return nil, err
+		}
+		// Make sure the signer never observes a partial request.
+		tmp := reqPath + ".tmp"
+		if err = ioutil.WriteFile(tmp, b, 0600); err != nil {
+			return nil, err
+		}
+		if err = os.Rename(tmp, reqPath); err != nil {
+			return nil, err
+		}
+		log.Infof("Exported signing request %s for %s", req.ID,
+			req.Address)
+	}
+	return nil, ErrSignaturePending
+}
+
+// CreateSignature exports the transaction for signing. The signature is
+// verified by the caller once it's added to the transaction.
+func (s *OfflineSigner) CreateSignature(ctx context.Context, addr string, tx, prevScript []byte) ([]byte, error) {
+	resp, err := s.sign(&SignRequest{
+		Address:     addr,
+		Transaction: tx,
+		InputIndex:  0,
+		PrevScript:  prevScript,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Signatures) != 1 {
+		return nil, fmt.Errorf("response %s must contain a single "+
+			"signature", resp.ID)
+	}
+	return resp.Signatures[0], nil
+}
+
+// SignHashes exports hashes for signing. Imported signatures are checked
+// against the public key which must belong to the address.
+func (s *OfflineSigner) SignHashes(ctx context.Context, addr string, hashes [][]byte) ([][]byte, []byte, error) {
+	resp, err := s.sign(&SignRequest{
+		Address: addr,
+		Hashes:  hashes,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(resp.Signatures) != len(hashes) {
+		return nil, nil, fmt.Errorf("response %s contains %d "+
+			"signatures, expected %d", resp.ID, len(resp.Signatures),
+			len(hashes))
+	}
+
+	pkAddr, err := dcrutil.NewAddressSecpPubKey(resp.PublicKey,
+		s.chainParams)
+	if err != nil {
+		return nil, nil, fmt.Errorf("response %s: %v", resp.ID, err)
+	}
+	if addr != pkAddr.EncodeAddress() &&
+		addr != pkAddr.AddressPubKeyHash().EncodeAddress() {
+		return nil, nil, fmt.Errorf("response %s: public key doesn't "+
+			"belong to %s", resp.ID, addr)
+	}
+	pubKey, err := chainec.Secp256k1.ParsePubKey(resp.PublicKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("response %s: %v", resp.ID, err)
+	}
+	for i, sigBytes := range resp.Signatures {
+		sig, err := chainec.Secp256k1.ParseDERSignature(sigBytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("response %s: %v", resp.ID,
+				err)
+		}
+		if !chainec.Secp256k1.Verify(pubKey, hashes[i], sig.GetR(),
+			sig.GetS()) {
+			return nil, nil, errors.New("invalid signature in " +
+				"response " + resp.ID)
+		}
+	}
+
+	return resp.Signatures, resp.PublicKey, nil
+}
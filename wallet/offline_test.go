@@ -0,0 +1,111 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrd/chaincfg/chainec"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrutil"
+)
+
+func TestOfflineSignHashes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "offline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	signer, err := NewOfflineSigner(dir, &chaincfg.SimNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privBytes, _, _, err := chainec.Secp256k1.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, pub := chainec.Secp256k1.PrivKeyFromBytes(privBytes)
+	pubKey := pub.SerializeCompressed()
+	pkAddr, err := dcrutil.NewAddressSecpPubKey(pubKey,
+		&chaincfg.SimNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := pkAddr.AddressPubKeyHash().EncodeAddress()
+	hashes := [][]byte{chainhash.HashB([]byte{1}), chainhash.HashB([]byte{2})}
+
+	ctx := context.Background()
+	_, _, err = signer.SignHashes(ctx, addr, hashes)
+	if err != ErrSignaturePending {
+		t.Fatalf("unexpected error %v", err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, OfflineRequestDir,
+		"*.json"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected a single exported request: %v", files)
+	}
+	b, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req SignRequest
+	if err = json.Unmarshal(b, &req); err != nil {
+		t.Fatal(err)
+	}
+
+	// Repeating the request must not export it twice.
+	if _, _, err = signer.SignHashes(ctx, addr, hashes); err != ErrSignaturePending {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	resp := SignResponse{ID: req.ID, PublicKey: pubKey}
+	for _, h := range req.Hashes {
+		r, s, err := chainec.Secp256k1.Sign(priv, h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig := chainec.Secp256k1.NewSignature(r, s)
+		resp.Signatures = append(resp.Signatures, sig.Serialize())
+	}
+	b, err = json.Marshal(&resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, OfflineSignedDir,
+		req.ID+".json"), b, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sigs, key, err := signer.SignHashes(ctx, addr, hashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sigs) != len(hashes) || string(key) != string(pubKey) {
+		t.Fatal("unexpected signatures")
+	}
+	if _, err = os.Stat(files[0]); !os.IsNotExist(err) {
+		t.Fatal("request wasn't removed after import")
+	}
+
+	// Signatures of other hashes must be rejected.
+	resp.Signatures[0], resp.Signatures[1] = resp.Signatures[1],
+		resp.Signatures[0]
+	b, _ = json.Marshal(&resp)
+	ioutil.WriteFile(filepath.Join(dir, OfflineSignedDir, req.ID+".json"),
+		b, 0600)
+	if _, _, err = signer.SignHashes(ctx, addr, hashes); err == nil {
+		t.Fatal("mismatched signatures accepted")
+	}
+}
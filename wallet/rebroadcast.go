@@ -0,0 +1,200 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"context"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/wire"
+	"github.com/decred/tumblebit/contract"
+)
+
+// Published transactions may be evicted from mempools before they are
+// mined, e.g. when they pay lower fees than competing transactions or
+// nodes restart. Escrow, refund and redeem transactions published for a
+// contract are tracked until they are confirmed and published again with
+// exponentially growing intervals.
+
+const (
+	// DefaultRebroadcastInterval is the default interval after which an
+	// unconfirmed transaction is published again. It's doubled with
+	// every subsequent attempt.
+	DefaultRebroadcastInterval = 10 * time.Minute
+
+	// DefaultMaxRebroadcastInterval is the default limit of the interval
+	// between two attempts.
+	DefaultMaxRebroadcastInterval = 4 * time.Hour
+
+	// maxRebroadcastRejections is the number of consecutive rejections
+	// after which a transaction is no longer published, e.g. because a
+	// conflicting transaction has been mined.
+	maxRebroadcastRejections = 3
+)
+
+// broadcast is a published transaction awaiting confirmation.
+type broadcast struct {
+	tx         []byte
+	hash       chainhash.Hash
+	expiry     uint32
+	next       time.Time
+	interval   time.Duration
+	rejections int
+}
+
+// broadcasts holds published transactions keyed by the escrow of the
+// contract and the kind of the transaction, so that a replacement of a
+// transaction is tracked instead of the original one.
+type broadcasts struct {
+	mu      sync.Mutex
+	pending map[string]*broadcast
+}
+
+// broadcastKey identifies the transaction of the kind published for the
+// contract. Contracts are identified by the hash of their escrow.
+func broadcastKey(con *contract.Contract, kind string) string {
+	switch {
+	case len(con.EscrowHash) > 0:
+		return hex.EncodeToString(con.EscrowHash) + "/" + kind
+	case con.EscrowTx != nil:
+		return con.EscrowTx.TxHash().String() + "/" + kind
+	}
+	return ""
+}
+
+// trackBroadcast starts tracking the transaction of the kind published for
+// the contract until it's confirmed.
+func (w *Wallet) trackBroadcast(con *contract.Contract, kind string, tx []byte) {
+	if w.rebroadcastInterval <= 0 {
+		return
+	}
+	key := broadcastKey(con, kind)
+	if key == "" {
+		return
+	}
+	var msgTx wire.MsgTx
+	if err := msgTx.FromBytes(tx); err != nil {
+		return
+	}
+	b := &broadcast{
+		tx:       tx,
+		hash:     msgTx.TxHash(),
+		expiry:   msgTx.Expiry,
+		interval: w.rebroadcastInterval,
+		next:     time.Now().Add(w.rebroadcastInterval),
+	}
+	w.broadcasts.mu.Lock()
+	if w.broadcasts.pending == nil {
+		w.broadcasts.pending = make(map[string]*broadcast)
+	}
+	w.broadcasts.pending[key] = b
+	w.broadcasts.mu.Unlock()
+}
+
+// Rebroadcast publishes tracked transactions again until they are
+// confirmed. It runs until the context is cancelled.
+func (w *Wallet) Rebroadcast(ctx context.Context) error {
+	if w.rebroadcastInterval <= 0 {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	ticker := time.NewTicker(w.rebroadcastTick())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			w.rebroadcastDue(ctx, now)
+		}
+	}
+}
+
+// rebroadcastTick returns the interval at which tracked transactions are
+// checked.
+func (w *Wallet) rebroadcastTick() time.Duration {
+	tick := w.rebroadcastInterval / 4
+	if tick < time.Second {
+		tick = time.Second
+	}
+	return tick
+}
+
+// rebroadcastDue publishes tracked transactions due at the time again.
+// Confirmed and expired transactions are no longer tracked.
+func (w *Wallet) rebroadcastDue(ctx context.Context, now time.Time) {
+	w.broadcasts.mu.Lock()
+	due := make(map[string]*broadcast)
+	for key, b := range w.broadcasts.pending {
+		if !now.Before(b.next) {
+			due[key] = b
+		}
+	}
+	w.broadcasts.mu.Unlock()
+	if len(due) == 0 {
+		return
+	}
+
+	height, err := w.CurrentBlockHeight(ctx)
+	if err != nil {
+		log.Warnf("Failed to rebroadcast transactions: %v", err)
+		return
+	}
+	for key, b := range due {
+		if w.rebroadcast(ctx, b, height) {
+			continue
+		}
+		w.broadcasts.mu.Lock()
+		if w.broadcasts.pending[key] == b {
+			delete(w.broadcasts.pending, key)
+		}
+		w.broadcasts.mu.Unlock()
+	}
+}
+
+// rebroadcast publishes the transaction again unless it has been
+// confirmed or has expired at the height. It returns false once the
+// transaction no longer needs to be tracked.
+func (w *Wallet) rebroadcast(ctx context.Context, b *broadcast, height uint32) bool {
+	confs, err := w.Confirmations(ctx, b.hash[:])
+	if err != nil {
+		log.Warnf("Failed to check confirmations of tx %v: %v", b.hash,
+			err)
+		return true
+	}
+	if confs > 0 {
+		log.Debugf("Transaction %v has been confirmed", b.hash)
+		return false
+	}
+	// Transactions expire in the block at their expiry height.
+	if b.expiry != 0 && height+1 >= b.expiry {
+		log.Debugf("Transaction %v has expired", b.hash)
+		return false
+	}
+
+	w.broadcasts.mu.Lock()
+	b.interval *= 2
+	if b.interval > w.maxRebroadcastInterval {
+		b.interval = w.maxRebroadcastInterval
+	}
+	b.next = time.Now().Add(b.interval)
+	w.broadcasts.mu.Unlock()
+
+	if err = w.submitTransaction(ctx, b.tx); err != nil {
+		b.rejections++
+		if b.rejections >= maxRebroadcastRejections {
+			log.Warnf("Stopped rebroadcasting tx %v: %v", b.hash, err)
+			return false
+		}
+		log.Debugf("Failed to rebroadcast tx %v: %v", b.hash, err)
+		return true
+	}
+	b.rejections = 0
+	log.Debugf("Rebroadcast unconfirmed tx %v", b.hash)
+	return true
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	pb "github.com/decred/dcrwallet/rpc/walletrpc"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultRetries is the default number of times a failed wallet
+	// request is retried.
+	DefaultRetries = 5
+
+	// DefaultBackoff is the default delay before the first retry. It's
+	// doubled with every subsequent attempt.
+	DefaultBackoff = 500 * time.Millisecond
+
+	// DefaultHealthInterval is the default interval between wallet
+	// health checks.
+	DefaultHealthInterval = 30 * time.Second
+
+	// maxBackoff limits the delay between two retries.
+	maxBackoff = 30 * time.Second
+
+	// reconnectThreshold is the number of consecutive failed health
+	// checks after which the connection is re-established.
+	reconnectThreshold = 3
+)
+
+// DialFunc establishes a new connection to the wallet service.
+type DialFunc func(ctx context.Context) (*grpc.ClientConn, error)
+
+// isTransient returns true if the error indicates that the wallet service
+// couldn't be reached and the request may succeed if retried.
+func isTransient(err error) bool {
+	s, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+	switch s.Code() {
+	case codes.Unavailable, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
+
+// client returns the client of the current wallet connection.
+func (w *Wallet) client() pb.WalletServiceClient {
+	w.connMu.RLock()
+	c := w.c
+	w.connMu.RUnlock()
+	return c
+}
+
+// call invokes fn with the client of the current wallet connection and
+// retries it with exponential backoff while it fails with transient
+// errors.
+func (w *Wallet) call(ctx context.Context, fn func(c pb.WalletServiceClient) error) error {
+	backoff := w.backoff
+	for attempt := 0; ; attempt++ {
+		err := fn(w.client())
+		if err == nil || !isTransient(err) || attempt >= w.retries {
+			return err
+		}
+
+		// Add up to 25% of jitter to spread retries of concurrent
+		// requests.
+		delay := backoff + time.Duration(rand.Int63n(int64(backoff)/4+1))
+		log.Debugf("Wallet request failed, retrying in %v: %v", delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// reconnect replaces the wallet connection with a newly established one.
+func (w *Wallet) reconnect(ctx context.Context) error {
+	if w.dial == nil {
+		return nil
+	}
+	conn, err := w.dial(ctx)
+	if err != nil {
+		return err
+	}
+
+	w.connMu.Lock()
+	old := w.conn
+	w.conn = conn
+	w.c = pb.NewWalletServiceClient(conn)
+	w.connMu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+
+	// A restarted wallet has locked the accounts again.
+	if err = w.unlockAccounts(ctx); err != nil {
+		log.Errorf("Failed to unlock accounts: %v", err)
+	}
+	return nil
+}
+
+// Close closes the current wallet connection.
+func (w *Wallet) Close() error {
+	w.connMu.Lock()
+	defer w.connMu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// NotifyConnectivity periodically checks whether the wallet service is
+// reachable and delivers changes of its availability to the provided
+// channel until the context is cancelled. The connection is
+// re-established after several consecutive failed checks.
+func (w *Wallet) NotifyConnectivity(ctx context.Context, events chan<- bool) error {
+	ticker := time.NewTicker(w.healthInterval)
+	defer ticker.Stop()
+
+	available := true
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		pctx, cancel := context.WithTimeout(ctx, w.healthInterval)
+		_, err := w.client().Ping(pctx, &pb.PingRequest{})
+		cancel()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err == nil {
+			failures = 0
+			if !available {
+				log.Infof("Wallet connection restored")
+				available = true
+				select {
+				case events <- true:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			continue
+		}
+
+		failures++
+		log.Warnf("Wallet health check failed: %v", err)
+		if available {
+			available = false
+			select {
+			case events <- false:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if failures%reconnectThreshold == 0 {
+			log.Infof("Reconnecting to the wallet")
+			if err := w.reconnect(ctx); err != nil {
+				log.Errorf("Failed to reconnect to the wallet: %v",
+					err)
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrd/chaincfg/chainec"
+	"github.com/decred/dcrd/dcrutil"
+	"github.com/decred/dcrd/txscript"
+	"github.com/decred/dcrd/wire"
+)
+
+// SessionKey is a secp256k1 key generated by the client for a single
+// exchange. Unlike wallet addresses, its address can't be linked to other
+// addresses of the wallet.
+type SessionKey struct {
+	Address   string // P2PKH address of the key
+	PublicKey string // Encoded public key
+
+	priv chainec.PrivateKey
+	pub  chainec.PublicKey
+}
+
+// NewSessionKey derives a session key from the source of randomness.
+func NewSessionKey(rand io.Reader, chainParams *chaincfg.Params) (*SessionKey, error) {
+	n := chainec.Secp256k1.GetN()
+	b := make([]byte, 32)
+	for {
+		if _, err := io.ReadFull(rand, b); err != nil {
+			return nil, fmt.Errorf("failed to generate session key: %v",
+				err)
+		}
+		d := new(big.Int).SetBytes(b)
+		if d.Sign() != 0 && d.Cmp(n) < 0 {
+			break
+		}
+	}
+	priv, pub := chainec.Secp256k1.PrivKeyFromBytes(b)
+	addr, err := dcrutil.NewAddressSecpPubKey(pub.SerializeCompressed(),
+		chainParams)
+	if err != nil {
+		return nil, err
+	}
+	return &SessionKey{
+		Address:   addr.EncodeAddress(),
+		PublicKey: addr.String(),
+		priv:      priv,
+		pub:       pub,
+	}, nil
+}
+
+// CreateSignature signs the first input of the serialized transaction
+// with the session key.
+func (k *SessionKey) CreateSignature(ctx context.Context, addr string, tx, prevScript []byte) ([]byte, error) {
+	if addr != k.Address {
+		return nil, fmt.Errorf("session key doesn't belong to %s", addr)
+	}
+	var msgTx wire.MsgTx
+	if err := msgTx.Deserialize(bytes.NewReader(tx)); err != nil {
+		return nil, fmt.Errorf("failed to deserialize tx: %v", err)
+	}
+	return txscript.RawTxInSignature(&msgTx, 0, prevScript,
+		txscript.SigHashAll, k.priv)
+}
+
+// SignHashes signs hashes with the session key.
+func (k *SessionKey) SignHashes(ctx context.Context, addr string, hashes [][]byte) ([][]byte, []byte, error) {
+	if addr != k.Address {
+		return nil, nil, fmt.Errorf("session key doesn't belong to %s",
+			addr)
+	}
+	sigs := make([][]byte, len(hashes))
+	for i, hash := range hashes {
+		r, s, err := chainec.Secp256k1.Sign(k.priv, hash)
+		if err != nil {
+			return nil, nil, err
+		}
+		sigs[i] = chainec.Secp256k1.NewSignature(r, s).Serialize()
+	}
+	return sigs, k.pub.SerializeCompressed(), nil
+}
+
+// AddSessionKey makes the wallet sign with the session key on behalf of
+// its address.
+func (w *Wallet) AddSessionKey(k *SessionKey) {
+	w.sessionMu.Lock()
+	defer w.sessionMu.Unlock()
+	if w.sessionKeys == nil {
+		w.sessionKeys = make(map[string]*SessionKey)
+	}
+	w.sessionKeys[k.Address] = k
+}
+
+// signerFor returns the signer producing signatures with the key of the
+// address.
+func (w *Wallet) signerFor(addr string) Signer {
+	w.sessionMu.Lock()
+	defer w.sessionMu.Unlock()
+	if k, ok := w.sessionKeys[addr]; ok {
+		return k
+	}
+	return w.signer
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"context"
+	"errors"
+
+	pb "github.com/decred/dcrwallet/rpc/walletrpc"
+)
+
+// ErrSignaturePending is returned when a signing request has been handed
+// over to an offline signer and the signature hasn't been imported yet.
+// The request may be repeated later to obtain the signature.
+var ErrSignaturePending = errors.New("signature is pending")
+
+// Signer produces signatures with the keys of wallet addresses.
+type Signer interface {
+	// CreateSignature signs the first input of the serialized
+	// transaction spending an output with the previous script and
+	// returns the signature with the SIGHASH_ALL hash type appended.
+	CreateSignature(ctx context.Context, addr string, tx, prevScript []byte) ([]byte, error)
+
+	// SignHashes signs hashes with the key of the address and returns
+	// DER encoded signatures along with the public key.
+	SignHashes(ctx context.Context, addr string, hashes [][]byte) ([][]byte, []byte, error)
+}
+
+// walletSigner signs with keys held by the connected dcrwallet.
+type walletSigner struct {
+	w *Wallet
+}
+
+func (s *walletSigner) CreateSignature(ctx context.Context, addr string, tx, prevScript []byte) ([]byte, error) {
+	var csr *pb.CreateSignatureResponse
+	err := s.w.call(ctx, func(c pb.WalletServiceClient) (err error) {
+		csr, err = c.CreateSignature(ctx, &pb.CreateSignatureRequest{
+			Passphrase:            s.w.signingPassphrase(),
+			Address:               addr,
+			SerializedTransaction: tx,
+			InputIndex:            0,
+			HashType:              pb.CreateSignatureRequest_SIGHASH_ALL,
+			PreviousPkScript:      prevScript,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return csr.Signature, nil
+}
+
+func (s *walletSigner) SignHashes(ctx context.Context, addr string, hashes [][]byte) ([][]byte, []byte, error) {
+	var sthr *pb.SignHashesResponse
+	err := s.w.call(ctx, func(c pb.WalletServiceClient) (err error) {
+		sthr, err = c.SignHashes(ctx, &pb.SignHashesRequest{
+			Passphrase: s.w.signingPassphrase(),
+			Address:    addr,
+			Hashes:     hashes,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return sthr.Signatures, sthr.PublicKey, nil
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+
+	pb "github.com/decred/dcrwallet/rpc/walletrpc"
+	"github.com/decred/tumblebit/contract"
+)
+
+// MaxSplitOutputs is the maximum number of outputs redeemed and refunded
+// escrows are split into.
+const MaxSplitOutputs = 8
+
+// payouts splits funds paid to the address among it and additional
+// addresses of the branch of the account, up to the configured number of
+// outputs. Shares are random, each between one and two times any other,
+// and the address is placed at a random position. It returns nil if funds
+// aren't split.
+func (w *Wallet) payouts(ctx context.Context, addr string, account uint32, kind pb.NextAddressRequest_Kind) ([]contract.Payout, error) {
+	if w.splitOutputs <= 1 {
+		return nil, nil
+	}
+
+	payouts := make([]contract.Payout, w.splitOutputs)
+	payouts[0].Address = addr
+	for i := 1; i < len(payouts); i++ {
+		a, _, err := w.getAddress(ctx, account, kind)
+		if err != nil {
+			return nil, err
+		}
+		payouts[i].Address = a
+	}
+
+	var b [4]byte
+	for i := range payouts {
+		if _, err := rand.Read(b[:]); err != nil {
+			return nil, err
+		}
+		payouts[i].Weight = 1<<15 + uint32(binary.LittleEndian.Uint16(b[:]))%(1<<15)
+		j := int(binary.LittleEndian.Uint16(b[2:])) % (i + 1)
+		payouts[i], payouts[j] = payouts[j], payouts[i]
+	}
+	return payouts, nil
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	pb "github.com/decred/dcrwallet/rpc/walletrpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Signing requests carry the wallet passphrase and unlock the whole wallet
+// for the duration of every request unless the accounts of the tumbler are
+// unlocked in advance. Accounts are unlocked once at startup, again after
+// the wallet connection has been re-established, and locked on shutdown.
+
+// unlockSessions tracks accounts unlocked for signing.
+type unlockSessions struct {
+	mu       sync.Mutex
+	enabled  bool
+	unlocked map[uint32]bool
+}
+
+// signingPassphrase returns the passphrase sent with signing requests,
+// which is omitted while the accounts are unlocked.
+func (w *Wallet) signingPassphrase() []byte {
+	w.unlocks.mu.Lock()
+	defer w.unlocks.mu.Unlock()
+	if len(w.unlocks.unlocked) != 0 {
+		return nil
+	}
+	return w.passphrase
+}
+
+// tumblerAccounts returns the distinct numbers of the primary and purpose
+// accounts.
+func (w *Wallet) tumblerAccounts() []uint32 {
+	var accounts []uint32
+	seen := make(map[uint32]bool)
+	for _, a := range []uint32{w.account, w.accounts.funding,
+		w.accounts.cashOut, w.accounts.fee, w.accounts.refund} {
+		if !seen[a] {
+			seen[a] = true
+			accounts = append(accounts, a)
+		}
+	}
+	return accounts
+}
+
+// UnlockAccounts unlocks the accounts of the tumbler with the wallet
+// passphrase, so that signing requests don't carry it. Wallets unable to
+// unlock individual accounts keep receiving the passphrase with every
+// signing request.
+func (w *Wallet) UnlockAccounts(ctx context.Context) error {
+	if len(w.passphrase) == 0 {
+		return fmt.Errorf("wallet passphrase is required to unlock " +
+			"accounts")
+	}
+	w.unlocks.mu.Lock()
+	w.unlocks.enabled = true
+	w.unlocks.mu.Unlock()
+	return w.unlockAccounts(ctx)
+}
+
+// unlockAccounts unlocks the accounts of the tumbler if unlock sessions
+// are enabled.
+func (w *Wallet) unlockAccounts(ctx context.Context) error {
+	w.unlocks.mu.Lock()
+	defer w.unlocks.mu.Unlock()
+	if !w.unlocks.enabled {
+		return nil
+	}
+	w.unlocks.unlocked = nil
+
+	unlocked := make(map[uint32]bool)
+	for _, account := range w.tumblerAccounts() {
+		err := w.call(ctx, func(c pb.WalletServiceClient) error {
+			_, err := c.UnlockAccount(ctx, &pb.UnlockAccountRequest{
+				AccountNumber: account,
+				Passphrase:    w.passphrase,
+			})
+			return err
+		})
+		if s, ok := status.FromError(err); ok && s.Code() == codes.Unimplemented {
+			log.Warnf("Wallet doesn't support unlocking accounts, the " +
+				"passphrase is sent with signing requests")
+			w.lockAccounts(ctx, unlocked)
+			return nil
+		}
+		if err != nil {
+			w.lockAccounts(ctx, unlocked)
+			return fmt.Errorf("UnlockAccount %v", err)
+		}
+		unlocked[account] = true
+	}
+	w.unlocks.unlocked = unlocked
+	log.Infof("Unlocked %d wallet accounts for signing", len(unlocked))
+	return nil
+}
+
+// LockAccounts locks the accounts unlocked by UnlockAccounts. Signing
+// requests carry the passphrase again afterwards.
+func (w *Wallet) LockAccounts(ctx context.Context) error {
+	w.unlocks.mu.Lock()
+	defer w.unlocks.mu.Unlock()
+	w.unlocks.enabled = false
+	err := w.lockAccounts(ctx, w.unlocks.unlocked)
+	w.unlocks.unlocked = nil
+	return err
+}
+
+// lockAccounts locks the accounts. All accounts are attempted even if
+// some fail to be locked.
+func (w *Wallet) lockAccounts(ctx context.Context, accounts map[uint32]bool) error {
+	var firstErr error
+	for account := range accounts {
+		err := w.call(ctx, func(c pb.WalletServiceClient) error {
+			_, err := c.LockAccount(ctx, &pb.LockAccountRequest{
+				AccountNumber: account,
+			})
+			return err
+		})
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("LockAccount %v", err)
+		}
+	}
+	return firstErr
+}
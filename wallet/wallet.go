@@ -2,7 +2,8 @@
 // Use of this source code is governed by an ISC
 // license that can be found in the LICENSE file.
 
-// The wallet package implements interaction with a dcrwallet via gRPC.
+// The wallet package implements interaction with a dcrwallet via gRPC or
+// JSON-RPC.
 package wallet
 
 import (
@@ -10,6 +11,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/decred/dcrd/chaincfg"
 	"github.com/decred/dcrd/txscript"
@@ -22,42 +27,197 @@ import (
 	"google.golang.org/grpc/status"
 )
 
+// RequiredConfirmations is the number of confirmations a transaction
+// must receive before it's considered to be final.
+const RequiredConfirmations = 2
+
 // Wallet represents an interface to an established RPC connection with
 // dcrwallet software and supports tumbler with wallet and blockchain
 // services.
 type Wallet struct {
-	c pb.WalletServiceClient
+	connMu sync.RWMutex
+	conn   *grpc.ClientConn
+	c      pb.WalletServiceClient
+	dial   DialFunc
+
+	retries        int
+	backoff        time.Duration
+	healthInterval time.Duration
 
 	chainParams *chaincfg.Params
 
 	passphrase []byte
 	account    uint32
+	accounts   accountNumbers
+	signer     Signer
+	unlocks    unlockSessions
+
+	sessionMu   sync.Mutex
+	sessionKeys map[string]*SessionKey // Keyed by P2PKH address
+
+	coinSelection CoinSelection
+	reservedMu    sync.Mutex
+	reserved      map[wire.OutPoint]time.Time // Outputs funding escrows
+
+	addressPolicy AddressPolicy
+	poolsMu       sync.Mutex
+	pools         map[poolKey]*addressPool
+
+	splitOutputs int
+
+	rebroadcastInterval    time.Duration
+	maxRebroadcastInterval time.Duration
+	broadcasts             broadcasts
+}
+
+// Accounts names wallet accounts dedicated to particular purposes. The
+// primary account is used for purposes without a dedicated account.
+type Accounts struct {
+	// Funding account funds escrows and holds escrow contract keys.
+	Funding string
+	// CashOut account provides epoch addresses receiving payments.
+	CashOut string
+	// Fee account receives redeemed payments including commissions.
+	Fee string
+	// Refund account receives refunds of expired escrows.
+	Refund string
+}
+
+// accountNumbers holds resolved numbers of the purpose accounts.
+type accountNumbers struct {
+	funding uint32
+	cashOut uint32
+	fee     uint32
+	refund  uint32
 }
 
 type Config struct {
 	Account          uint32
 	AccountName      string
+	Accounts         Accounts
 	ChainParams      *chaincfg.Params
 	WalletConnection *grpc.ClientConn
 	WalletPassword   string
+
+	// JSONRPC connects to the JSON-RPC server of dcrwallet instead of
+	// the gRPC wallet service if it's set. WalletConnection and Dial
+	// aren't used.
+	JSONRPC *JSONRPCConfig
+
+	// Dial re-establishes the connection to the wallet service when it
+	// stops responding to health checks. The wallet takes ownership of
+	// the connection. Reconnection is disabled if it's nil.
+	Dial DialFunc
+
+	// Retries is the number of times a request failed due to the wallet
+	// service being unavailable is retried with exponential backoff
+	// starting with the Backoff delay.
+	Retries int
+	Backoff time.Duration
+
+	// HealthInterval is the interval between health checks.
+	HealthInterval time.Duration
+
+	// Signer creates signatures with the keys of contract addresses.
+	// The wallet service signs if it's nil.
+	Signer Signer
+
+	// CoinSelection is the policy of choosing outputs funding escrows.
+	CoinSelection CoinSelection
+
+	// Addresses is the policy of allocating addresses. The gap limit
+	// defaults to DefaultGapLimit.
+	Addresses AddressPolicy
+
+	// RebroadcastInterval is the interval after which unconfirmed
+	// transactions are published again, doubled with every attempt up
+	// to MaxRebroadcastInterval. Transactions aren't published again if
+	// it's zero.
+	RebroadcastInterval    time.Duration
+	MaxRebroadcastInterval time.Duration
+
+	// SplitOutputs is the number of outputs, each paying to a fresh
+	// address, that redeemed and refunded escrows are split into with
+	// random amounts. Funds aren't split unless it's more than one.
+	SplitOutputs int
+
+	// CreateAccount creates the account named AccountName with the
+	// wallet passphrase unless it already exists.
+	CreateAccount bool
+
+	// UnlockAccounts unlocks the accounts used by the tumbler once
+	// instead of sending the wallet passphrase with every signing
+	// request. They are locked again by LockAccounts.
+	UnlockAccounts bool
 }
 
+// ErrAccountNotFound is returned when the wallet has no account with the
+// requested name.
+var ErrAccountNotFound = errors.New("account wasn't found")
+
 // New creates a new wallet object associated with the connection conn
 // under chainParams. It also makes sure wallet is running and configured
 // for the correct network.
 func New(ctx context.Context, cfg *Config) (*Wallet, error) {
 	w := &Wallet{
-		c:           pb.NewWalletServiceClient(cfg.WalletConnection),
-		chainParams: cfg.ChainParams,
-		account:     cfg.Account,
-		passphrase:  []byte(cfg.WalletPassword),
+		conn:           cfg.WalletConnection,
+		c:              pb.NewWalletServiceClient(cfg.WalletConnection),
+		dial:           cfg.Dial,
+		retries:        cfg.Retries,
+		backoff:        cfg.Backoff,
+		healthInterval: cfg.HealthInterval,
+		chainParams:    cfg.ChainParams,
+		account:        cfg.Account,
+		passphrase:     []byte(cfg.WalletPassword),
+		signer:         cfg.Signer,
+		coinSelection:  cfg.CoinSelection,
+		reserved:       make(map[wire.OutPoint]time.Time),
+		addressPolicy:  cfg.Addresses,
+		pools:          make(map[poolKey]*addressPool),
+		splitOutputs:   cfg.SplitOutputs,
+
+		rebroadcastInterval:    cfg.RebroadcastInterval,
+		maxRebroadcastInterval: cfg.MaxRebroadcastInterval,
+	}
+	if cfg.JSONRPC != nil {
+		if cfg.JSONRPC.Host == "" {
+			return nil, errors.New("JSON-RPC server host is required")
+		}
+		w.conn = nil
+		w.c = newJSONRPCClient(cfg.JSONRPC, cfg.ChainParams)
+		w.dial = nil
+	}
+	if w.signer == nil {
+		w.signer = &walletSigner{w: w}
+	}
+	if w.retries < 0 {
+		w.retries = 0
+	}
+	if w.backoff <= 0 {
+		w.backoff = DefaultBackoff
+	}
+	if w.healthInterval <= 0 {
+		w.healthInterval = DefaultHealthInterval
+	}
+	if w.addressPolicy.GapLimit <= 0 {
+		w.addressPolicy.GapLimit = DefaultGapLimit
+	}
+	if w.maxRebroadcastInterval < w.rebroadcastInterval {
+		w.maxRebroadcastInterval = w.rebroadcastInterval
 	}
 
-	_, err := w.c.Ping(ctx, &pb.PingRequest{})
+	err := w.call(ctx, func(c pb.WalletServiceClient) error {
+		_, err := c.Ping(ctx, &pb.PingRequest{})
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("Ping %v", err)
 	}
-	nr, err := w.c.Network(ctx, &pb.NetworkRequest{})
+	var nr *pb.NetworkResponse
+	err = w.call(ctx, func(c pb.WalletServiceClient) (err error) {
+		nr, err = c.Network(ctx, &pb.NetworkRequest{})
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("Network %v", err)
 	}
@@ -67,18 +227,83 @@ func New(ctx context.Context, cfg *Config) (*Wallet, error) {
 
 	if len(cfg.AccountName) > 0 {
 		err = w.SelectAccount(ctx, cfg.AccountName)
-		if err != nil {
+		if err == ErrAccountNotFound && cfg.CreateAccount {
+			w.account, err = w.CreateAccount(ctx, cfg.AccountName)
+		}
+		switch {
+		case err == ErrAccountNotFound:
 			return nil, fmt.Errorf("account %s wasn't found", cfg.AccountName)
+		case err != nil:
+			return nil, err
+		}
+	}
+	if err = w.selectAccounts(ctx, &cfg.Accounts); err != nil {
+		return nil, err
+	}
+	if cfg.UnlockAccounts {
+		if err = w.UnlockAccounts(ctx); err != nil {
+			return nil, err
 		}
 	}
 
 	return w, nil
 }
 
+// selectAccounts resolves names of the purpose accounts. Purposes without
+// a dedicated account use the primary account.
+func (w *Wallet) selectAccounts(ctx context.Context, accounts *Accounts) error {
+	w.accounts = accountNumbers{
+		funding: w.account,
+		cashOut: w.account,
+		fee:     w.account,
+		refund:  w.account,
+	}
+	if *accounts == (Accounts{}) {
+		return nil
+	}
+
+	var ar *pb.AccountsResponse
+	err := w.call(ctx, func(c pb.WalletServiceClient) (err error) {
+		ar, err = c.Accounts(ctx, &pb.AccountsRequest{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("Accounts %v", err)
+	}
+	numbers := make(map[string]uint32, len(ar.Accounts))
+	for _, account := range ar.Accounts {
+		numbers[account.AccountName] = account.AccountNumber
+	}
+
+	for _, a := range []struct {
+		name   string
+		number *uint32
+	}{
+		{accounts.Funding, &w.accounts.funding},
+		{accounts.CashOut, &w.accounts.cashOut},
+		{accounts.Fee, &w.accounts.fee},
+		{accounts.Refund, &w.accounts.refund},
+	} {
+		if a.name == "" {
+			continue
+		}
+		number, ok := numbers[a.name]
+		if !ok {
+			return fmt.Errorf("account %s wasn't found", a.name)
+		}
+		*a.number = number
+	}
+	return nil
+}
+
 // SelectAccount looks up an account by the provided name and selects it
 // for future wallet operations.
 func (w *Wallet) SelectAccount(ctx context.Context, name string) error {
-	ar, err := w.c.Accounts(ctx, &pb.AccountsRequest{})
+	var ar *pb.AccountsResponse
+	err := w.call(ctx, func(c pb.WalletServiceClient) (err error) {
+		ar, err = c.Accounts(ctx, &pb.AccountsRequest{})
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("Accounts %v", err)
 	}
@@ -88,26 +313,77 @@ func (w *Wallet) SelectAccount(ctx context.Context, name string) error {
 			return nil
 		}
 	}
-	return fmt.Errorf("account %s wasn't found", name)
+	return ErrAccountNotFound
+}
+
+// CreateAccount creates a new account with the provided name and returns
+// its number. The wallet passphrase is required to derive the account keys.
+func (w *Wallet) CreateAccount(ctx context.Context, name string) (uint32, error) {
+	if len(w.passphrase) == 0 {
+		return 0, fmt.Errorf("wallet passphrase is required to create "+
+			"account %s", name)
+	}
+	var nr *pb.NextAccountResponse
+	err := w.call(ctx, func(c pb.WalletServiceClient) (err error) {
+		nr, err = c.NextAccount(ctx, &pb.NextAccountRequest{
+			Passphrase:  w.passphrase,
+			AccountName: name,
+		})
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("NextAccount %v", err)
+	}
+	return nr.AccountNumber, nil
 }
 
 func (w *Wallet) CurrentBlockHeight(ctx context.Context) (uint32, error) {
-	bbr, err := w.c.BestBlock(ctx, &pb.BestBlockRequest{})
+	var bbr *pb.BestBlockResponse
+	err := w.call(ctx, func(c pb.WalletServiceClient) (err error) {
+		bbr, err = c.BestBlock(ctx, &pb.BestBlockRequest{})
+		return err
+	})
 	if err != nil {
 		return 0, fmt.Errorf("Accounts %v", err)
 	}
 	return bbr.Height, nil
 }
 
+// SpendableBalance returns the amount of funds in the funding account
+// spendable with the number of confirmations required for escrow inputs.
+func (w *Wallet) SpendableBalance(ctx context.Context) (int64, error) {
+	minConf := w.coinSelection.MinConf
+	if minConf < 1 {
+		minConf = 1
+	}
+	var br *pb.BalanceResponse
+	err := w.call(ctx, func(c pb.WalletServiceClient) (err error) {
+		br, err = c.Balance(ctx, &pb.BalanceRequest{
+			AccountNumber:         w.accounts.funding,
+			RequiredConfirmations: minConf,
+		})
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("Balance %v", err)
+	}
+	return br.Spendable, nil
+}
+
 func (w *Wallet) ImportEscrowScript(ctx context.Context, con *contract.Contract) error {
-	isr, err := w.c.ImportScript(ctx, &pb.ImportScriptRequest{
-		Passphrase: w.passphrase,
-		Script:     con.EscrowScript,
+	var isr *pb.ImportScriptResponse
+	err := w.call(ctx, func(c pb.WalletServiceClient) (err error) {
+		isr, err = c.ImportScript(ctx, &pb.ImportScriptRequest{
+			Passphrase: w.passphrase,
+			Script:     con.EscrowScript,
+		})
+		return err
 	})
 	if err != nil {
 		return fmt.Errorf("ImportScript %v", err)
 	}
 	con.EscrowAddrStr = isr.P2ShAddress
+	w.markContractUsed(con)
 	return nil
 }
 
@@ -117,7 +393,8 @@ func (w *Wallet) ImportEscrowScript(ctx context.Context, con *contract.Contract)
 func (w *Wallet) CreateEscrow(ctx context.Context, con *contract.Contract) error {
 	var err error
 
-	addr, pkey, err := w.GetExtAddress(ctx)
+	addr, pkey, err := w.getAddress(ctx, w.accounts.funding,
+		pb.NextAddressRequest_BIP0044_EXTERNAL)
 	if err != nil {
 		return err
 	}
@@ -142,23 +419,41 @@ func (w *Wallet) CreateEscrow(ctx context.Context, con *contract.Contract) error
 }
 
 func (w *Wallet) createEscrowTx(ctx context.Context, con *contract.Contract) error {
-	ctr, err := w.c.ConstructTransaction(ctx, &pb.ConstructTransactionRequest{
-		SourceAccount: w.account,
-		NonChangeOutputs: []*pb.ConstructTransactionRequest_Output{{
-			Destination: &pb.ConstructTransactionRequest_OutputDestination{
-				Script:        con.EscrowPayScript,
-				ScriptVersion: 0,
-			},
-			Amount: con.Amount,
-		}},
-	})
-	if err != nil {
-		return fmt.Errorf("ConstructTransaction %v", err)
+	var unsignedTx []byte
+	if w.coinSelection.Enabled() {
+		var err error
+		unsignedTx, err = w.constructEscrowTx(ctx, con)
+		if err != nil {
+			return err
+		}
+	} else {
+		var ctr *pb.ConstructTransactionResponse
+		err := w.call(ctx, func(c pb.WalletServiceClient) (err error) {
+			ctr, err = c.ConstructTransaction(ctx, &pb.ConstructTransactionRequest{
+				SourceAccount: w.accounts.funding,
+				NonChangeOutputs: []*pb.ConstructTransactionRequest_Output{{
+					Destination: &pb.ConstructTransactionRequest_OutputDestination{
+						Script:        con.EscrowPayScript,
+						ScriptVersion: 0,
+					},
+					Amount: con.Amount.Atoms(),
+				}},
+			})
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("ConstructTransaction %v", err)
+		}
+		unsignedTx = ctr.UnsignedTransaction
 	}
 
-	str, err := w.c.SignTransaction(ctx, &pb.SignTransactionRequest{
-		Passphrase:            w.passphrase,
-		SerializedTransaction: ctr.UnsignedTransaction,
+	var str *pb.SignTransactionResponse
+	err := w.call(ctx, func(c pb.WalletServiceClient) (err error) {
+		str, err = c.SignTransaction(ctx, &pb.SignTransactionRequest{
+			Passphrase:            w.signingPassphrase(),
+			SerializedTransaction: unsignedTx,
+		})
+		return err
 	})
 	if err != nil {
 		return fmt.Errorf("SignTransaction %v", err)
@@ -169,31 +464,54 @@ func (w *Wallet) createEscrowTx(ctx context.Context, con *contract.Contract) err
 }
 
 func (w *Wallet) createRefundTx(ctx context.Context, con *contract.Contract) error {
-	addr, pkey, err := w.GetIntAddress(ctx)
+	addr, pkey, err := w.getAddress(ctx, w.accounts.refund,
+		pb.NextAddressRequest_BIP0044_INTERNAL)
 	if err != nil {
 		return err
 	}
 	if err = con.SetAddress(contract.RefundAddress, addr, pkey); err != nil {
 		return err
 	}
+	payouts, err := w.payouts(ctx, addr, w.accounts.refund,
+		pb.NextAddressRequest_BIP0044_INTERNAL)
+	if err != nil {
+		return err
+	}
 
-	if err = con.BuildRefundTx(); err != nil {
+	if err = con.BuildRefundTx(payouts...); err != nil {
 		return fmt.Errorf("failed to create a refund tx: %v", err)
 	}
 
-	csr, err := w.c.CreateSignature(ctx, &pb.CreateSignatureRequest{
-		Passphrase:            w.passphrase,
-		Address:               con.SenderAddrStr,
-		SerializedTransaction: con.RefundBytes,
-		InputIndex:            0,
-		HashType:              pb.CreateSignatureRequest_SIGHASH_ALL,
-		PreviousPkScript:      con.EscrowScript,
-	})
+	// The signature is collected from an offline signer before the
+	// escrow is published.
+	con.RefundPending = true
+	err = w.CollectRefund(ctx, con)
+	if err == ErrSignaturePending {
+		log.Infof("Refund of the escrow paying to %s awaits an offline "+
+			"signature", con.EscrowAddrStr)
+		return nil
+	}
+	return err
+}
+
+// CollectRefund completes the refund transaction of the contract with the
+// signature of the escrow sender if it's still pending. ErrSignaturePending
+// is returned until the offline signer has provided the signature.
+func (w *Wallet) CollectRefund(ctx context.Context, con *contract.Contract) error {
+	if !con.RefundPending {
+		return nil
+	}
+
+	sig, err := w.signer.CreateSignature(ctx, con.SenderAddrStr,
+		con.RefundBytes, con.EscrowScript)
+	if err == ErrSignaturePending {
+		return err
+	}
 	if err != nil {
 		return fmt.Errorf("CreateSignature %v", err)
 	}
 
-	con.RefundSig = csr.Signature
+	con.RefundSig = sig
 
 	if err = con.AddRefundScript(); err != nil {
 		return fmt.Errorf("failed to add a refund script: %v", err)
@@ -202,22 +520,35 @@ func (w *Wallet) createRefundTx(ctx context.Context, con *contract.Contract) err
 	if err = con.VerifyRefundTx(); err != nil {
 		return fmt.Errorf("failed to verify refund script: %v", err)
 	}
+	con.RefundPending = false
 
 	return nil
 }
 
 // CreateRedeem creates a transaction redeeming escrowed funds.
 func (w *Wallet) CreateRedeem(ctx context.Context, con *contract.Contract) error {
-	addr, pkey, err := w.GetIntAddress(ctx)
-	if err != nil {
-		return err
+	var err error
+
+	// The pay-out address may have been committed to beforehand.
+	if con.RedeemAddr == nil {
+		addr, pkey, err := w.GetPayoutAddress(ctx)
+		if err != nil {
+			return err
+		}
+		err = con.SetAddress(contract.RedeemAddress, addr, pkey)
+		if err != nil {
+			return err
+		}
 	}
-	if err = con.SetAddress(contract.RedeemAddress, addr, pkey); err != nil {
+
+	payouts, err := w.payouts(ctx, con.RedeemAddrStr, w.accounts.cashOut,
+		pb.NextAddressRequest_BIP0044_INTERNAL)
+	if err != nil {
 		return err
 	}
 
 	// 73 + 1 -- DER signature size
-	if err = con.BuildRedeemTx(73 + 1); err != nil {
+	if err = con.BuildRedeemTx(73+1, payouts...); err != nil {
 		return fmt.Errorf("failed to create a redeem tx: %v", err)
 	}
 
@@ -225,26 +556,33 @@ func (w *Wallet) CreateRedeem(ctx context.Context, con *contract.Contract) error
 		return err
 	}
 
-	csr, err := w.c.CreateSignature(ctx, &pb.CreateSignatureRequest{
-		Passphrase:            w.passphrase,
-		Address:               con.ReceiverAddrStr,
-		SerializedTransaction: con.RedeemBytes,
-		InputIndex:            0,
-		HashType:              pb.CreateSignatureRequest_SIGHASH_ALL,
-		PreviousPkScript:      con.EscrowScript,
-	})
+	sig, err := w.signerFor(con.ReceiverAddrStr).CreateSignature(ctx,
+		con.ReceiverAddrStr, con.RedeemBytes, con.EscrowScript)
+	if err == ErrSignaturePending {
+		return err
+	}
 	if err != nil {
 		return fmt.Errorf("CreateSignature %v", err)
 	}
 
-	con.RedeemSig = csr.Signature
+	con.RedeemSig = sig
 
 	return nil
 }
 
-// PublishRedeem publishes the redeeming transaction.
+// PublishRedeem completes the redeeming transaction created by CreateRedeem
+// with the signature of the escrow sender and publishes it. The peer
+// signature is expected to be a bare DER encoded signature of the
+// transaction hash as promised by the tumbler.
 func (w *Wallet) PublishRedeem(ctx context.Context, con *contract.Contract, peerSig []byte) error {
-	err := con.AddRedeemScript([][]byte{peerSig})
+	if len(peerSig) == 0 {
+		return errors.New("missing peer signature")
+	}
+	sig := make([]byte, 0, len(peerSig)+1)
+	sig = append(sig, peerSig...)
+	sig = append(sig, byte(txscript.SigHashAll))
+
+	err := con.AddEscrowRedeemScript(sig)
 	if err != nil {
 		return fmt.Errorf("failed to add a redeem script: %v", err)
 	}
@@ -253,39 +591,48 @@ func (w *Wallet) PublishRedeem(ctx context.Context, con *contract.Contract, peer
 		return fmt.Errorf("failed to verify redeem script: %v", err)
 	}
 
-	ptr, err := w.c.PublishTransaction(ctx, &pb.PublishTransactionRequest{
-		SignedTransaction: con.RedeemBytes,
-	})
+	hash, err := w.publishTransaction(ctx, con.RedeemBytes)
 	if err != nil {
 		return fmt.Errorf("failed to publish redeem tx: %v", err)
 	}
-	con.RedeemHash = ptr.TransactionHash
+	con.RedeemHash = hash
+	w.trackBroadcast(con, "redeem", con.RedeemBytes)
 
 	return nil
 }
 
 // PublishRefund publishes the refund transaction.
 func (w *Wallet) PublishRefund(ctx context.Context, con *contract.Contract) error {
-	ptr, err := w.c.PublishTransaction(ctx, &pb.PublishTransactionRequest{
-		SignedTransaction: con.RefundBytes,
-	})
+	hash, err := w.publishTransaction(ctx, con.RefundBytes)
 	if err != nil {
 		return fmt.Errorf("PublishTransaction %v", err)
 	}
-	con.RefundHash = ptr.TransactionHash
+	con.RefundHash = hash
+	w.trackBroadcast(con, "refund", con.RefundBytes)
 
 	return nil
 }
 
-// PublishEscrow publishes the escrow transaction.
+// PublishEscrow publishes the escrow transaction. An escrow that comes
+// with a refund transaction is only published once the refund has been
+// signed, ErrSignaturePending is returned while the signature is collected
+// from an offline signer.
 func (w *Wallet) PublishEscrow(ctx context.Context, con *contract.Contract) error {
-	ptr, err := w.c.PublishTransaction(ctx, &pb.PublishTransactionRequest{
-		SignedTransaction: con.EscrowBytes,
-	})
+	if err := w.CollectRefund(ctx, con); err != nil {
+		return err
+	}
+	if len(con.RefundBytes) != 0 && len(con.RefundScript) == 0 {
+		return errors.New("refusing to publish an escrow without a " +
+			"signed refund")
+	}
+
+	hash, err := w.publishTransaction(ctx, con.EscrowBytes)
 	if err != nil {
 		return fmt.Errorf("PublishTransaction %v", err)
 	}
-	con.EscrowHash = ptr.TransactionHash
+	con.EscrowHash = hash
+	w.markContractUsed(con)
+	w.trackBroadcast(con, "escrow", con.EscrowBytes)
 
 	return nil
 }
@@ -293,15 +640,15 @@ func (w *Wallet) PublishEscrow(ctx context.Context, con *contract.Contract) erro
 // SignHashes signs a bundle of transaction hashes and returns a bundle of
 // created signatures.
 func (w *Wallet) SignHashes(ctx context.Context, con *contract.Contract, txHashes [][]byte) ([][]byte, []byte, error) {
-	sthr, err := w.c.SignHashes(ctx, &pb.SignHashesRequest{
-		Passphrase: w.passphrase,
-		Address:    con.SenderAddrStr,
-		Hashes:     txHashes,
-	})
+	sigs, pubKey, err := w.signer.SignHashes(ctx, con.SenderAddrStr,
+		txHashes)
+	if err == ErrSignaturePending {
+		return nil, nil, err
+	}
 	if err != nil {
 		return nil, nil, fmt.Errorf("SignHashes %v", err)
 	}
-	return sthr.Signatures, sthr.PublicKey, nil
+	return sigs, pubKey, nil
 }
 
 // CreateOffer creates an escrow transaction that releases funds when hash
@@ -318,7 +665,7 @@ func (w *Wallet) CreateOffer(ctx context.Context, con *contract.Contract, hashes
 		return err
 	}
 
-	if err = con.AddOfferScript(hashes, txscript.OP_RIPEMD160); err != nil {
+	if err = con.AddOfferScript(hashes, con.HashLock.Opcode()); err != nil {
 		return fmt.Errorf("failed to create an offer script: %v", err)
 	}
 
@@ -336,9 +683,7 @@ func (w *Wallet) CreateOffer(ctx context.Context, con *contract.Contract, hashes
 // ValidateOffer retrieves the escrow transaction created by the client
 // and makes sure it has been confirmed on the blockchain.
 func (w *Wallet) ValidateOffer(ctx context.Context, con *contract.Contract, escrowHash []byte) (bool, error) {
-	gtr, err := w.c.GetTransaction(ctx, &pb.GetTransactionRequest{
-		TransactionHash: escrowHash,
-	})
+	gtr, err := w.getTransaction(ctx, escrowHash)
 	if err != nil {
 		s, ok := status.FromError(err)
 		if ok && s.Code() == codes.NotFound {
@@ -348,7 +693,7 @@ func (w *Wallet) ValidateOffer(ctx context.Context, con *contract.Contract, escr
 	}
 
 	// Make sure tx has received enough confirmations.
-	if gtr.Confirmations < 2 {
+	if gtr.Confirmations < RequiredConfirmations {
 		return false, nil
 	}
 
@@ -360,7 +705,7 @@ func (w *Wallet) ValidateOffer(ctx context.Context, con *contract.Contract, escr
 
 	// TODO: add checks
 
-	if escrowTx.TxOut[0].Value < con.Amount {
+	if escrowTx.TxOut[0].Value < con.Amount.Atoms() {
 		return false, fmt.Errorf("escrowed less than advertised: %d",
 			escrowTx.TxOut[0].Value)
 	}
@@ -374,32 +719,37 @@ func (w *Wallet) ValidateOffer(ctx context.Context, con *contract.Contract, escr
 // for hashes contained in the offer tx and thus redeems funds escrowed by
 // they payer. It publishes both offer and fulfilling transactions.
 func (w *Wallet) PublishSolution(ctx context.Context, con *contract.Contract, secrets [][]byte) error {
-	addr, pkey, err := w.GetIntAddress(ctx)
-	if err != nil {
-		return err
-	}
-	if err = con.SetAddress(contract.RedeemAddress, addr, pkey); err != nil {
-		return err
+	// Replacements of an expired fulfilling transaction pay to the same
+	// address.
+	if con.RedeemAddr == nil {
+		addr, pkey, err := w.getAddress(ctx, w.accounts.fee,
+			pb.NextAddressRequest_BIP0044_INTERNAL)
+		if err != nil {
+			return err
+		}
+		err = con.SetAddress(contract.RedeemAddress, addr, pkey)
+		if err != nil {
+			return err
+		}
 	}
 
-	// RealPreimageCount * 160 bit long RIPEMD-160 solution keys
-	if err = con.BuildRedeemTx(len(secrets) * (1 + 20)); err != nil {
+	// RealPreimageCount solution keys sized by the hash lock
+	err := con.BuildRedeemTx(contract.PreimagePushesSize(len(secrets),
+		con.HashLock))
+	if err != nil {
 		return fmt.Errorf("failed to create a redeem tx: %v", err)
 	}
 
-	csr, err := w.c.CreateSignature(ctx, &pb.CreateSignatureRequest{
-		Passphrase:            w.passphrase,
-		Address:               con.ReceiverAddrStr,
-		SerializedTransaction: con.RedeemBytes,
-		InputIndex:            0,
-		HashType:              pb.CreateSignatureRequest_SIGHASH_ALL,
-		PreviousPkScript:      con.EscrowScript,
-	})
+	sig, err := w.signer.CreateSignature(ctx, con.ReceiverAddrStr,
+		con.RedeemBytes, con.EscrowScript)
+	if err == ErrSignaturePending {
+		return err
+	}
 	if err != nil {
 		return fmt.Errorf("CreateSignature %v", err)
 	}
 
-	con.RedeemSig = csr.Signature
+	con.RedeemSig = sig
 
 	err = con.AddRedeemScript(secrets)
 	if err != nil {
@@ -410,13 +760,14 @@ func (w *Wallet) PublishSolution(ctx context.Context, con *contract.Contract, se
 		return fmt.Errorf("failed to verify redeem script: %v", err)
 	}
 
-	ptr, err := w.c.PublishTransaction(ctx, &pb.PublishTransactionRequest{
-		SignedTransaction: con.RedeemBytes,
-	})
+	hash, err := w.publishTransaction(ctx, con.RedeemBytes)
 	if err != nil {
 		return fmt.Errorf("failed to publish redeem tx: %v", err)
 	}
-	con.RedeemHash = ptr.TransactionHash
+	con.RedeemHash = hash
+	log.Tracef("Fulfilling tx %x reveals secrets %s", hash,
+		contract.TraceSecrets(secrets))
+	w.trackBroadcast(con, "redeem", con.RedeemBytes)
 
 	return nil
 }
@@ -424,9 +775,13 @@ func (w *Wallet) PublishSolution(ctx context.Context, con *contract.Contract, se
 // OfferRedeemer looks up the transaction spending the escrow and obtains
 // hash preimages used to redeem the contract.
 func (w *Wallet) OfferRedeemer(ctx context.Context, con *contract.Contract) (bool, [][]byte, error) {
-	sr, err := w.c.Spender(ctx, &pb.SpenderRequest{
-		TransactionHash: con.EscrowHash,
-		Index:           0,
+	var sr *pb.SpenderResponse
+	err := w.call(ctx, func(c pb.WalletServiceClient) (err error) {
+		sr, err = c.Spender(ctx, &pb.SpenderRequest{
+			TransactionHash: con.EscrowHash,
+			Index:           0,
+		})
+		return err
 	})
 	if err != nil {
 		s, ok := status.FromError(err)
@@ -442,9 +797,7 @@ func (w *Wallet) OfferRedeemer(ctx context.Context, con *contract.Contract) (boo
 			err)
 	}
 
-	gtr, err := w.c.GetTransaction(ctx, &pb.GetTransactionRequest{
-		TransactionHash: con.RedeemHash,
-	})
+	gtr, err := w.getTransaction(ctx, con.RedeemHash)
 	if err != nil {
 		s, ok := status.FromError(err)
 		if ok && s.Code() == codes.NotFound {
@@ -454,7 +807,7 @@ func (w *Wallet) OfferRedeemer(ctx context.Context, con *contract.Contract) (boo
 	}
 
 	// Make sure tx has received enough confirmations.
-	if gtr.Confirmations < 2 {
+	if gtr.Confirmations < RequiredConfirmations {
 		return false, nil, nil
 	}
 
@@ -466,26 +819,276 @@ func (w *Wallet) OfferRedeemer(ctx context.Context, con *contract.Contract) (boo
 	return true, data, nil
 }
 
-func (w *Wallet) GetIntAddress(ctx context.Context) (string, string, error) {
-	nar, err := w.c.NextAddress(ctx, &pb.NextAddressRequest{
-		Account:   w.account,
-		Kind:      pb.NextAddressRequest_BIP0044_INTERNAL,
-		GapPolicy: pb.NextAddressRequest_GAP_POLICY_WRAP,
+// EscrowSpent returns true if the contract output of the escrow
+// transaction has been spent.
+func (w *Wallet) EscrowSpent(ctx context.Context, con *contract.Contract) (bool, error) {
+	index, err := con.EscrowOutput()
+	if err != nil {
+		return false, err
+	}
+	return w.OutputSpent(ctx, con.EscrowHash, index)
+}
+
+// EscrowSpender returns the hash of the transaction spending the contract
+// output of the escrow, or nil if the output hasn't been spent.
+func (w *Wallet) EscrowSpender(ctx context.Context, con *contract.Contract) ([]byte, error) {
+	index, err := con.EscrowOutput()
+	if err != nil {
+		return nil, err
+	}
+	var sr *pb.SpenderResponse
+	err = w.call(ctx, func(c pb.WalletServiceClient) (err error) {
+		sr, err = c.Spender(ctx, &pb.SpenderRequest{
+			TransactionHash: con.EscrowHash,
+			Index:           index,
+		})
+		return err
 	})
 	if err != nil {
-		return "", "", fmt.Errorf("NextAddress %v", err)
+		s, ok := status.FromError(err)
+		if ok && s.Code() == codes.NotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("Spender %v", err)
 	}
-	return nar.Address, nar.PublicKey, nil
+
+	var tx wire.MsgTx
+	err = tx.Deserialize(bytes.NewReader(sr.SpenderTransaction))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode spending tx: %v", err)
+	}
+	hash := tx.TxHash()
+	return hash[:], nil
+}
+
+// Confirmations returns the number of confirmations of the transaction
+// identified by the hash, or zero if the wallet doesn't know about it.
+func (w *Wallet) Confirmations(ctx context.Context, txHash []byte) (int32, error) {
+	gtr, err := w.getTransaction(ctx, txHash)
+	if err != nil {
+		s, ok := status.FromError(err)
+		if ok && s.Code() == codes.NotFound {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("GetTransaction %v", err)
+	}
+	return gtr.Confirmations, nil
+}
+
+// RefundHeight returns the block height at which the escrow of the
+// contract may be refunded. Escrows locked with relative locktimes can't
+// be refunded until they are mined, so math.MaxUint32 is returned for
+// unconfirmed ones.
+func (w *Wallet) RefundHeight(ctx context.Context, con *contract.Contract) (uint32, error) {
+	if con.LockType != contract.RelativeLock {
+		return uint32(con.LockTime), nil
+	}
+	gtr, err := w.getTransaction(ctx, con.EscrowHash)
+	if err != nil {
+		return 0, fmt.Errorf("GetTransaction %v", err)
+	}
+	if gtr.Confirmations <= 0 {
+		return math.MaxUint32, nil
+	}
+	height, err := w.CurrentBlockHeight(ctx)
+	if err != nil {
+		return 0, err
+	}
+	mined := height - uint32(gtr.Confirmations) + 1
+	return mined + uint32(con.LockTime), nil
+}
+
+// OutputSpent returns true if the output of the transaction identified by
+// the hash has been spent.
+func (w *Wallet) OutputSpent(ctx context.Context, txHash []byte, index uint32) (bool, error) {
+	err := w.call(ctx, func(c pb.WalletServiceClient) error {
+		_, err := c.Spender(ctx, &pb.SpenderRequest{
+			TransactionHash: txHash,
+			Index:           index,
+		})
+		return err
+	})
+	if err != nil {
+		s, ok := status.FromError(err)
+		if ok && s.Code() == codes.NotFound {
+			return false, nil
+		}
+		return false, fmt.Errorf("Spender %v", err)
+	}
+	return true, nil
+}
+
+// NotifyBlocks subscribes to transaction notifications from the wallet
+// and delivers heights of newly attached blocks to the provided channel
+// until the context is cancelled or the notification stream fails.
+func (w *Wallet) NotifyBlocks(ctx context.Context, blocks chan<- int32) error {
+	stream, err := w.client().TransactionNotifications(ctx,
+		&pb.TransactionNotificationsRequest{})
+	if err != nil {
+		return fmt.Errorf("TransactionNotifications %v", err)
+	}
+	for {
+		tnr, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("TransactionNotifications %v", err)
+		}
+		for _, b := range tnr.AttachedBlocks {
+			select {
+			case blocks <- b.Height:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+func (w *Wallet) GetIntAddress(ctx context.Context) (string, string, error) {
+	return w.getAddress(ctx, w.account,
+		pb.NextAddressRequest_BIP0044_INTERNAL)
 }
 
 func (w *Wallet) GetExtAddress(ctx context.Context) (string, string, error) {
-	nar, err := w.c.NextAddress(ctx, &pb.NextAddressRequest{
-		Account:   w.account,
-		Kind:      pb.NextAddressRequest_BIP0044_EXTERNAL,
-		GapPolicy: pb.NextAddressRequest_GAP_POLICY_WRAP,
+	return w.getAddress(ctx, w.account,
+		pb.NextAddressRequest_BIP0044_EXTERNAL)
+}
+
+// GetCashOutAddress allocates a new external address of the cash-out
+// account.
+func (w *Wallet) GetCashOutAddress(ctx context.Context) (string, string, error) {
+	return w.getAddress(ctx, w.accounts.cashOut,
+		pb.NextAddressRequest_BIP0044_EXTERNAL)
+}
+
+// GetPayoutAddress allocates a new internal address of the cash-out
+// account, which receives redeemed escrows of clients.
+func (w *Wallet) GetPayoutAddress(ctx context.Context) (string, string, error) {
+	return w.getAddress(ctx, w.accounts.cashOut,
+		pb.NextAddressRequest_BIP0044_INTERNAL)
+}
+
+// getAddress hands out an address of the branch of the account from its
+// address pool.
+func (w *Wallet) getAddress(ctx context.Context, account uint32, kind pb.NextAddressRequest_Kind) (string, string, error) {
+	return w.addressPool(account, kind).get(ctx)
+}
+
+// nextAddress allocates a new address of the branch of the account.
+func (w *Wallet) nextAddress(ctx context.Context, account uint32, kind pb.NextAddressRequest_Kind) (string, string, error) {
+	var nar *pb.NextAddressResponse
+	err := w.call(ctx, func(c pb.WalletServiceClient) (err error) {
+		nar, err = c.NextAddress(ctx, &pb.NextAddressRequest{
+			Account:   account,
+			Kind:      kind,
+			GapPolicy: w.addressPolicy.GapPolicy.walletGapPolicy(),
+		})
+		return err
 	})
 	if err != nil {
 		return "", "", fmt.Errorf("NextAddress %v", err)
 	}
 	return nar.Address, nar.PublicKey, nil
 }
+
+// SignMessage signs the message with the key of the specified address.
+// Signatures are deterministic, signing the same message with the same
+// key always yields the same signature.
+func (w *Wallet) SignMessage(ctx context.Context, addr, message string) ([]byte, error) {
+	var smr *pb.SignMessageResponse
+	err := w.call(ctx, func(c pb.WalletServiceClient) (err error) {
+		smr, err = c.SignMessage(ctx, &pb.SignMessageRequest{
+			Address:    addr,
+			Message:    message,
+			Passphrase: w.signingPassphrase(),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("SignMessage %v", err)
+	}
+	return smr.Signature, nil
+}
+
+// publishTransaction publishes the serialized transaction and returns its
+// hash. Publishing is idempotent: transactions the wallet already knows
+// about, e.g. because a retried request published them before, aren't
+// submitted again and rejections of duplicates are treated as success.
+func (w *Wallet) publishTransaction(ctx context.Context, tx []byte) ([]byte, error) {
+	var msgTx wire.MsgTx
+	if err := msgTx.FromBytes(tx); err != nil {
+		return nil, fmt.Errorf("failed to decode tx: %v", err)
+	}
+	hash := msgTx.TxHash()
+
+	if w.knownTransaction(ctx, hash[:]) {
+		log.Debugf("Transaction %v has already been published", hash)
+		w.markOutputsUsed(tx)
+		return hash[:], nil
+	}
+
+	if err := w.submitTransaction(ctx, tx); err != nil {
+		return nil, err
+	}
+	w.markOutputsUsed(tx)
+	return hash[:], nil
+}
+
+// submitTransaction submits the serialized transaction to the wallet
+// service, which relays it to the network even if it already knows about
+// it. Rejections of duplicates are treated as success.
+func (w *Wallet) submitTransaction(ctx context.Context, tx []byte) error {
+	err := w.call(ctx, func(c pb.WalletServiceClient) error {
+		_, err := c.PublishTransaction(ctx, &pb.PublishTransactionRequest{
+			SignedTransaction: tx,
+		})
+		return err
+	})
+	if err != nil && isDuplicateTx(err) {
+		log.Debugf("Transaction has already been published: %v", err)
+		return nil
+	}
+	return err
+}
+
+// knownTransaction returns true if the wallet knows about the transaction
+// identified by the hash. Failed lookups are reported as unknown so that
+// the transaction is published.
+func (w *Wallet) knownTransaction(ctx context.Context, hash []byte) bool {
+	_, err := w.getTransaction(ctx, hash)
+	if err != nil {
+		s, ok := status.FromError(err)
+		if !ok || s.Code() != codes.NotFound {
+			log.Debugf("Failed to look up transaction: %v", err)
+		}
+		return false
+	}
+	return true
+}
+
+// isDuplicateTx returns true if the error rejects a transaction that has
+// already been published.
+func isDuplicateTx(err error) bool {
+	s, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+	if s.Code() == codes.AlreadyExists {
+		return true
+	}
+	msg := strings.ToLower(s.Message())
+	return strings.Contains(msg, "already have transaction") ||
+		strings.Contains(msg, "transaction already exists")
+}
+
+func (w *Wallet) getTransaction(ctx context.Context, hash []byte) (*pb.GetTransactionResponse, error) {
+	var gtr *pb.GetTransactionResponse
+	err := w.call(ctx, func(c pb.WalletServiceClient) (err error) {
+		gtr, err = c.GetTransaction(ctx, &pb.GetTransactionRequest{
+			TransactionHash: hash,
+		})
+		return err
+	})
+	return gtr, err
+}
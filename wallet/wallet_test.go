@@ -0,0 +1,263 @@
+// Copyright (c) 2018 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/chaincfg"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/wire"
+	pb "github.com/decred/dcrwallet/rpc/walletrpc"
+	"github.com/decred/tumblebit/contract"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// mockClient is a wallet service knowing about the transactions it was
+// given and rejecting published transactions with publishErr if set.
+type mockClient struct {
+	pb.WalletServiceClient
+
+	known      map[chainhash.Hash]bool
+	confs      map[chainhash.Hash]int32
+	height     uint32
+	published  int
+	publishErr error
+}
+
+func (c *mockClient) BestBlock(ctx context.Context, in *pb.BestBlockRequest, opts ...grpc.CallOption) (*pb.BestBlockResponse, error) {
+	return &pb.BestBlockResponse{Height: c.height}, nil
+}
+
+func (c *mockClient) GetTransaction(ctx context.Context, in *pb.GetTransactionRequest, opts ...grpc.CallOption) (*pb.GetTransactionResponse, error) {
+	hash, err := chainhash.NewHash(in.TransactionHash)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if !c.known[*hash] {
+		return nil, status.Error(codes.NotFound, "transaction not found")
+	}
+	return &pb.GetTransactionResponse{Confirmations: c.confs[*hash]}, nil
+}
+
+func (c *mockClient) PublishTransaction(ctx context.Context, in *pb.PublishTransactionRequest, opts ...grpc.CallOption) (*pb.PublishTransactionResponse, error) {
+	c.published++
+	if c.publishErr != nil {
+		return nil, c.publishErr
+	}
+	var tx wire.MsgTx
+	if err := tx.FromBytes(in.SignedTransaction); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	hash := tx.TxHash()
+	c.known[hash] = true
+	return &pb.PublishTransactionResponse{TransactionHash: hash[:]}, nil
+}
+
+func TestPublishTransaction(t *testing.T) {
+	tx := wire.NewMsgTx()
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 1}, nil))
+	tx.AddTxOut(wire.NewTxOut(1e8, []byte{0x51}))
+	txBytes, err := tx.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	txHash := tx.TxHash()
+
+	tests := []struct {
+		known      bool
+		publishErr error
+		published  int
+		valid      bool
+	}{
+		{false, nil, 1, true},
+		{true, nil, 0, true},
+		{false, status.Error(codes.AlreadyExists, "duplicate"), 1, true},
+		{false, status.Error(codes.Unknown, "rejected transaction: "+
+			"already have transaction "+txHash.String()), 1, true},
+		{false, status.Error(codes.InvalidArgument, "bad signature"),
+			1, false},
+	}
+	for i, test := range tests {
+		c := &mockClient{
+			known:      make(map[chainhash.Hash]bool),
+			publishErr: test.publishErr,
+		}
+		c.known[txHash] = test.known
+		w := &Wallet{c: c, chainParams: &chaincfg.SimNetParams}
+
+		hash, err := w.publishTransaction(context.Background(), txBytes)
+		if (err == nil) != test.valid {
+			t.Errorf("test %d: unexpected result %v", i, err)
+			continue
+		}
+		if c.published != test.published {
+			t.Errorf("test %d: published %d times, expected %d", i,
+				c.published, test.published)
+		}
+		if test.valid && !bytes.Equal(hash, txHash[:]) {
+			t.Errorf("test %d: hash %x, expected %v", i, hash, txHash)
+		}
+	}
+
+	// Publishing the same transaction again must not submit it twice.
+	c := &mockClient{known: make(map[chainhash.Hash]bool)}
+	w := &Wallet{c: c, chainParams: &chaincfg.SimNetParams}
+	for i := 0; i < 2; i++ {
+		if _, err = w.publishTransaction(context.Background(), txBytes); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if c.published != 1 {
+		t.Fatalf("published %d times", c.published)
+	}
+}
+
+func TestRebroadcast(t *testing.T) {
+	ctx := context.Background()
+	tx := wire.NewMsgTx()
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 1}, nil))
+	tx.AddTxOut(wire.NewTxOut(1e8, []byte{0x51}))
+	tx.Expiry = 1000
+	txBytes, err := tx.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	txHash := tx.TxHash()
+
+	c := &mockClient{
+		known:  map[chainhash.Hash]bool{txHash: true},
+		confs:  make(map[chainhash.Hash]int32),
+		height: 900,
+	}
+	w := &Wallet{
+		c:                      c,
+		chainParams:            &chaincfg.SimNetParams,
+		rebroadcastInterval:    time.Minute,
+		maxRebroadcastInterval: 3 * time.Minute,
+	}
+	con := &contract.Contract{EscrowHash: chainhash.HashB([]byte("escrow"))}
+	pending := func() int {
+		w.broadcasts.mu.Lock()
+		defer w.broadcasts.mu.Unlock()
+		return len(w.broadcasts.pending)
+	}
+
+	w.trackBroadcast(con, "refund", txBytes)
+	w.rebroadcastDue(ctx, time.Now())
+	if c.published != 0 {
+		t.Fatal("transaction published before the interval passed")
+	}
+
+	// Intervals double up to the limit.
+	for i, interval := range []time.Duration{2, 3, 3} {
+		w.rebroadcastDue(ctx, time.Now().Add(time.Hour))
+		if c.published != i+1 {
+			t.Fatalf("published %d times, expected %d", c.published, i+1)
+		}
+		b := w.broadcasts.pending[broadcastKey(con, "refund")]
+		if b.interval != interval*time.Minute {
+			t.Fatalf("attempt %d: interval %v", i, b.interval)
+		}
+	}
+
+	// Confirmed transactions are no longer published.
+	c.confs[txHash] = 1
+	w.rebroadcastDue(ctx, time.Now().Add(time.Hour))
+	if pending() != 0 || c.published != 3 {
+		t.Fatal("confirmed transaction published")
+	}
+
+	// Neither are expired ones.
+	c.confs[txHash] = 0
+	c.height = 999
+	w.trackBroadcast(con, "refund", txBytes)
+	w.rebroadcastDue(ctx, time.Now().Add(time.Hour))
+	if pending() != 0 || c.published != 3 {
+		t.Fatal("expired transaction published")
+	}
+
+	// Transactions are dropped after repeated rejections.
+	c.height = 900
+	c.publishErr = status.Error(codes.InvalidArgument, "double spend")
+	w.trackBroadcast(con, "refund", txBytes)
+	for i := 0; i < maxRebroadcastRejections; i++ {
+		if pending() != 1 {
+			t.Fatalf("transaction dropped after %d rejections", i)
+		}
+		w.rebroadcastDue(ctx, time.Now().Add(time.Hour))
+	}
+	if pending() != 0 {
+		t.Fatal("rejected transaction is still tracked")
+	}
+}
+
+// lockingClient unlocks and locks accounts, failing to unlock them with
+// unlockErr if set.
+type lockingClient struct {
+	pb.WalletServiceClient
+
+	unlocked  map[uint32]bool
+	unlockErr error
+}
+
+func (c *lockingClient) UnlockAccount(ctx context.Context, in *pb.UnlockAccountRequest, opts ...grpc.CallOption) (*pb.UnlockAccountResponse, error) {
+	if c.unlockErr != nil {
+		return nil, c.unlockErr
+	}
+	c.unlocked[in.AccountNumber] = true
+	return &pb.UnlockAccountResponse{}, nil
+}
+
+func (c *lockingClient) LockAccount(ctx context.Context, in *pb.LockAccountRequest, opts ...grpc.CallOption) (*pb.LockAccountResponse, error) {
+	delete(c.unlocked, in.AccountNumber)
+	return &pb.LockAccountResponse{}, nil
+}
+
+func TestUnlockAccounts(t *testing.T) {
+	ctx := context.Background()
+	c := &lockingClient{unlocked: make(map[uint32]bool)}
+	w := &Wallet{
+		c:          c,
+		passphrase: []byte("passphrase"),
+		account:    1,
+		accounts:   accountNumbers{funding: 1, cashOut: 2, fee: 1, refund: 1},
+	}
+
+	if err := w.UnlockAccounts(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.unlocked) != 2 || !c.unlocked[1] || !c.unlocked[2] {
+		t.Fatalf("unexpected unlocked accounts %v", c.unlocked)
+	}
+	if w.signingPassphrase() != nil {
+		t.Fatal("passphrase is sent with unlocked accounts")
+	}
+
+	if err := w.LockAccounts(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.unlocked) != 0 {
+		t.Fatalf("accounts %v weren't locked", c.unlocked)
+	}
+	if !bytes.Equal(w.signingPassphrase(), w.passphrase) {
+		t.Fatal("passphrase isn't sent with locked accounts")
+	}
+
+	// Wallets unable to unlock accounts keep receiving the passphrase.
+	c.unlockErr = status.Error(codes.Unimplemented, "unknown method")
+	if err := w.UnlockAccounts(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(w.signingPassphrase(), w.passphrase) {
+		t.Fatal("passphrase isn't sent to wallets without unlock sessions")
+	}
+}